@@ -6,9 +6,13 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Yusufdot101/goBankBackend/internal/app"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
 	"github.com/Yusufdot101/goBankBackend/internal/jsonlog"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
 // declare the variables. we will use the -X linker flag of the go build to burn-in the
@@ -47,6 +51,99 @@ func main() {
 	flag.IntVar(&config.Limiter.Burst, "limiter-burst", 4, "Rate limiter burst")
 	flag.BoolVar(&config.Limiter.Enabled, "limiter-enabled", false, "Enable rate limiter")
 
+	flag.BoolVar(
+		&config.Scheduler.Enabled, "scheduler-enabled", true,
+		"Enable the scheduler that runs standing orders, transfer batches, interest, expiries, "+
+			"budget alerts and statements",
+	)
+	flag.DurationVar(
+		&config.Scheduler.Interval, "scheduler-interval", time.Minute,
+		"How often the scheduler looks for due standing orders and uploaded batches",
+	)
+
+	flag.DurationVar(
+		&config.Events.Interval, "events-interval", time.Second,
+		"How often the events in the outbox are published and the due webhook deliveries sent",
+	)
+
+	flag.BoolVar(
+		&config.Statements.Email, "statements-email", false,
+		"Email every account its monthly statement, needs the scheduler",
+	)
+
+	flag.StringVar(
+		&config.Limits.Currency, "limit-currency", money.DefaultCurrency,
+		"The currency the limits are in, other currencies count at the current rate",
+	)
+	config.Limits.PerTransaction = money.MustParse("5000")
+	config.Limits.Daily = money.MustParse("10000")
+	config.Limits.Weekly = money.MustParse("25000")
+	flag.Var(
+		&config.Limits.PerTransaction, "limit-per-transaction",
+		"Default most a user can send or withdraw at once, 0 for no limit",
+	)
+	flag.Var(
+		&config.Limits.Daily, "limit-daily",
+		"Default most a user can send and withdraw in 24 hours, 0 for no limit",
+	)
+	flag.Var(
+		&config.Limits.Weekly, "limit-weekly",
+		"Default most a user can send and withdraw in 7 days, 0 for no limit",
+	)
+
+	// the fraud rules, an empty action turns a rule off
+	config.Fraud = fraud.Rules{
+		LargeAmount:           money.MustParse("3000"),
+		LargeAmountAction:     fraud.ActionReview,
+		VelocityCount:         10,
+		VelocityWindow:        10 * time.Minute,
+		VelocityAction:        fraud.ActionBlock,
+		NewRecipientAction:    fraud.ActionAllow,
+		NewAccountAge:         7 * 24 * time.Hour,
+		NewAccountDrainAction: fraud.ActionReview,
+	}
+	flag.StringVar(
+		&config.Fraud.Currency, "fraud-currency", money.DefaultCurrency,
+		"The currency the fraud rule amounts are in, other currencies count at the current rate",
+	)
+	flag.Var(
+		&config.Fraud.LargeAmount, "fraud-large-amount",
+		"Transfers and withdrawals over this amount set off the large amount fraud rule",
+	)
+	flag.Var(
+		&config.Fraud.LargeAmountAction, "fraud-large-amount-action",
+		"ALLOW, REVIEW or BLOCK a large amount, empty to turn the rule off",
+	)
+	flag.IntVar(
+		&config.Fraud.VelocityCount, "fraud-velocity-count", config.Fraud.VelocityCount,
+		"More transfers and withdrawals than this in the velocity window set off the velocity rule",
+	)
+	flag.DurationVar(
+		&config.Fraud.VelocityWindow, "fraud-velocity-window", config.Fraud.VelocityWindow,
+		"The window transfers and withdrawals are counted in for the velocity rule",
+	)
+	flag.Var(
+		&config.Fraud.VelocityAction, "fraud-velocity-action",
+		"ALLOW, REVIEW or BLOCK too many operations at once, empty to turn the rule off",
+	)
+	flag.Var(
+		&config.Fraud.NewRecipientAction, "fraud-new-recipient-action",
+		"ALLOW, REVIEW or BLOCK the first transfer to an account, empty to turn the rule off",
+	)
+	flag.DurationVar(
+		&config.Fraud.NewAccountAge, "fraud-new-account-age", config.Fraud.NewAccountAge,
+		"How long an account counts as new for the new account drain rule",
+	)
+	flag.Var(
+		&config.Fraud.NewAccountDrainAction, "fraud-new-account-drain-action",
+		"ALLOW, REVIEW or BLOCK a new account sending all its balance, empty to turn the rule off",
+	)
+
+	flag.Var(
+		&config.SavingsInterest, "savings-interest-tiers",
+		`Yearly interest in percent paid on savings from each balance up, e.g. "0:1.5,10000:2"`,
+	)
+
 	displayVersion := flag.Bool("version", false, "Display application version and exit")
 	flag.Parse()
 
@@ -62,6 +159,14 @@ func main() {
 
 	logger := jsonlog.New(os.Stdout, 0)
 
+	v := validator.New()
+	if fraud.ValidateRules(v, config.Fraud); !v.IsValid() {
+		logger.PrintFatal(fmt.Errorf("fraud rules: %v", v.Errors), nil)
+	}
+	if !money.ValidCurrency(config.Limits.Currency) {
+		logger.PrintFatal(fmt.Errorf("limit currency %q: invalid", config.Limits.Currency), nil)
+	}
+
 	db, err := app.OpenDB(config)
 	if err != nil {
 		logger.PrintFatal(err, nil)
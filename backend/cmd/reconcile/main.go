@@ -0,0 +1,53 @@
+// reconcile checks the balance of every account against its money movements and its ledger
+// postings once and exits, for running from cron. it exits with status 1 when it finds any account
+// that doesn't add up, after recording them and logging a summary
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/Yusufdot101/goBankBackend/internal/app"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonlog"
+)
+
+func main() {
+	var config app.Config
+
+	flag.StringVar(&config.DB.DSN, "db-dsn", "", "PostgreSQL DSN")
+	flag.StringVar(
+		&config.DB.IdleConnTimout, "db-idle-conn-timout", "15m",
+		"PostgreSQL idle connection timout",
+	)
+	flag.Parse()
+
+	if config.DB.DSN == "" {
+		config.DB.DSN = os.Getenv("DB_DSN")
+	}
+	config.DB.MaxOpenConns = 1
+	config.DB.MaxIdleConns = 1
+
+	logger := jsonlog.New(os.Stdout, 0)
+
+	db, err := app.OpenDB(config)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	defer db.Close()
+
+	application := &app.Application{
+		Config: config,
+		Logger: logger,
+		DB:     db,
+	}
+
+	run, err := application.Reconcile("command")
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	if len(run.Discrepancies) > 0 {
+		db.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,99 @@
+package account
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+const (
+	TypeChecking = "CHECKING"
+	TypeSavings  = "SAVINGS"
+)
+
+const (
+	StatusActive = "ACTIVE"
+	StatusFrozen = "FROZEN"
+	StatusClosed = "CLOSED"
+)
+
+// Account holds one balance of a user in a single currency, a user can have as many accounts as
+// they like. Balance is the ledger balance, everything that has been posted to the account, and
+// AvailableBalance is what is left of it to spend once the money set aside by holds is taken off.
+// an account with an overdraft can also go below zero, as far as its OverdraftLimit, and is charged
+// OverdraftInterestRate percent a day on what it owes. a frozen account can still be paid into but
+// nothing can leave it, and a closed one is done with
+type Account struct {
+	ID                    int64        `json:"id"`
+	CreatedAt             time.Time    `json:"created_at"`
+	UserID                int64        `json:"user_id"`
+	Number                string       `json:"number"`
+	Type                  string       `json:"type"`
+	Status                string       `json:"status"`
+	Currency              string       `json:"currency"`
+	Balance               money.Amount `json:"balance"`
+	HeldAmount            money.Amount `json:"held_amount"`
+	AvailableBalance      money.Amount `json:"available_balance"`
+	OverdraftLimit        money.Amount `json:"overdraft_limit"`
+	OverdraftInterestRate fx.Decimal   `json:"overdraft_daily_interest_rate"`
+	StatusReason          string       `json:"status_reason,omitempty"`
+	StatusChangedBy       string       `json:"status_changed_by,omitempty"`
+	ClosedAt              *time.Time   `json:"closed_at,omitempty"`
+	Version               int32        `json:"version"`
+}
+
+// Spendable is what can still be spent from the account, the available balance and whatever is
+// left of the overdraft
+func (a *Account) Spendable() money.Amount {
+	return a.AvailableBalance + a.OverdraftLimit
+}
+
+// IsActive is whether money can leave the account
+func (a *Account) IsActive() bool {
+	return a.Status == StatusActive
+}
+
+// CanReceive is whether money can be paid into the account, frozen accounts included
+func (a *Account) CanReceive() bool {
+	return a.Status != StatusClosed
+}
+
+// ValidNumber is whether the account number is all digits and ends in the right check digits. the
+// last two digits are worked out like an IBAN's, with ISO 7064 mod 97-10, so the whole number
+// leaves 1 when divided by 97. that catches every mistyped digit and almost every pair of digits
+// typed the wrong way round
+func ValidNumber(number string) bool {
+	if len(number) < 3 {
+		return false
+	}
+
+	// done a digit at a time, the number can be longer than an int
+	remainder := 0
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+		remainder = (remainder*10 + int(r-'0')) % 97
+	}
+	return remainder == 1
+}
+
+func ValidateAccount(v *validator.Validator, account *Account) {
+	v.CheckAddError(account.UserID != 0, "user ID", "must be given")
+	v.CheckAddError(
+		validator.ValueInList(account.Type, TypeChecking, TypeSavings), "type", "invalid",
+	)
+	v.CheckAddError(
+		validator.ValueInList(account.Status, StatusActive, StatusFrozen, StatusClosed),
+		"status", "invalid",
+	)
+	v.CheckAddError(money.ValidCurrency(account.Currency), "currency", "invalid")
+}
+
+func ValidateStatusChange(v *validator.Validator, reason, changedBy string) {
+	v.CheckAddError(reason != "", "reason", "must be given")
+	v.CheckAddError(len(reason) <= 500, "reason", "must not be more than 500 bytes long")
+	v.CheckAddError(changedBy != "", "changed by", "must be given")
+}
@@ -0,0 +1,256 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+var ErrEditConflict = errors.New("edit conflict")
+
+type Repository struct {
+	DB *sql.DB
+}
+
+const selectAccounts = `
+	SELECT id, created_at, user_id, number, type, status, currency, balance,
+		` + ledger.HeldAmountColumn + `, overdraft_limit, overdraft_daily_interest_rate,
+		status_reason, status_changed_by, closed_at, version
+	FROM accounts
+`
+
+// the account number is left to the database, it hands them out from a sequence
+func (r *Repository) Insert(account *Account) error {
+	query := `
+		INSERT INTO accounts (user_id, type, status, currency)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, number, balance, version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.DB.QueryRowContext(
+		ctx, query, account.UserID, account.Type, account.Status, account.Currency,
+	).Scan(
+		&account.ID,
+		&account.CreatedAt,
+		&account.Number,
+		&account.Balance,
+		&account.Version,
+	)
+}
+
+func (r *Repository) Get(accountID int64) (*Account, error) {
+	query := selectAccounts + `
+		WHERE id = $1
+	`
+
+	return r.getOne(query, accountID)
+}
+
+func (r *Repository) GetByNumber(number string) (*Account, error) {
+	query := selectAccounts + `
+		WHERE number = $1
+	`
+
+	return r.getOne(query, number)
+}
+
+// GetPrimary gets the account money goes to when no account is named, the user's oldest open
+// checking account, or their oldest open account if they have no checking account
+func (r *Repository) GetPrimary(userID int64) (*Account, error) {
+	query := selectAccounts + `
+		WHERE user_id = $1 AND status <> 'CLOSED'
+		ORDER BY type = 'CHECKING' DESC, id
+		LIMIT 1
+	`
+
+	return r.getOne(query, userID)
+}
+
+func (r *Repository) getOne(query string, args ...any) (*Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	account, err := scanAccount(r.DB.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return account, nil
+}
+
+// UpdateStatus saves the new status of the account, if it has not changed since it was read
+func (r *Repository) UpdateStatus(account *Account) error {
+	query := `
+		UPDATE accounts
+		SET status = $1, status_reason = $2, status_changed_by = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(
+		ctx, query, account.Status, account.StatusReason, account.StatusChangedBy, account.ID,
+		account.Version,
+	).Scan(&account.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CloseTx closes the account in one database transaction. the account row is locked and read again
+// first, so that nothing goes in or out of it while prepare checks it can be closed. prepare is
+// told whether the account has loans that are not paid off or loan requests that are not answered,
+// and returns the entry that pays out what is left in the account, nil when there is nothing to pay
+// out. the payout is recorded as a transaction too, like a withdrawal over the counter
+func (r *Repository) CloseTx(
+	account *Account, prepare func(locked *Account, outstandingLoans bool) (*ledger.Entry, error),
+) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		query := selectAccounts + `
+			WHERE id = $1
+			FOR UPDATE
+		`
+		locked, err := scanAccount(tx.QueryRowContext(ctx, query, account.ID))
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return user.ErrNoRecord
+			default:
+				return err
+			}
+		}
+		if locked.Status == StatusClosed {
+			return ErrEditConflict
+		}
+
+		query = `
+			SELECT EXISTS (
+				SELECT 1 FROM loans
+				WHERE account_id = $1 AND action = 'took' AND remaining_amount > 0
+			) OR EXISTS (
+				SELECT 1 FROM loan_requests
+				WHERE account_id = $1 AND status = 'PENDING'
+			)
+		`
+		var outstandingLoans bool
+		err = tx.QueryRowContext(ctx, query, account.ID).Scan(&outstandingLoans)
+		if err != nil {
+			return err
+		}
+
+		entry, err := prepare(locked, outstandingLoans)
+		if err != nil {
+			return err
+		}
+		if entry != nil {
+			err = ledger.PostInTx(ctx, tx, entry)
+			if err != nil {
+				return err
+			}
+
+			query = `
+				INSERT INTO transactions
+					(user_id, account_id, currency, action, amount, performed_by)
+				VALUES ($1, $2, $3, 'CLOSING_PAYOUT', $4, $5)
+			`
+			_, err = tx.ExecContext(
+				ctx, query, locked.UserID, locked.ID, locked.Currency, locked.Balance,
+				account.StatusChangedBy,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		query = `
+			UPDATE accounts
+			SET status = 'CLOSED', status_reason = $1, status_changed_by = $2, closed_at = NOW(),
+				version = version + 1
+			WHERE id = $3
+			RETURNING status, balance, closed_at, version
+		`
+		return tx.QueryRowContext(
+			ctx, query, account.StatusReason, account.StatusChangedBy, account.ID,
+		).Scan(&account.Status, &account.Balance, &account.ClosedAt, &account.Version)
+	})
+}
+
+func (r *Repository) GetAllUserAccounts(userID int64) ([]*Account, error) {
+	query := selectAccounts + `
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// scanAccount scans a row of selectAccounts, from either a *sql.Row or *sql.Rows
+func scanAccount(row interface{ Scan(dest ...any) error }) (*Account, error) {
+	account := &Account{}
+	err := row.Scan(
+		&account.ID,
+		&account.CreatedAt,
+		&account.UserID,
+		&account.Number,
+		&account.Type,
+		&account.Status,
+		&account.Currency,
+		&account.Balance,
+		&account.HeldAmount,
+		&account.OverdraftLimit,
+		&account.OverdraftInterestRate,
+		&account.StatusReason,
+		&account.StatusChangedBy,
+		&account.ClosedAt,
+		&account.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	account.AvailableBalance = account.Balance - account.HeldAmount
+
+	return account, nil
+}
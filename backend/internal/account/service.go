@@ -0,0 +1,197 @@
+package account
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type Repo interface {
+	Insert(account *Account) error
+	Get(accountID int64) (*Account, error)
+	GetByNumber(number string) (*Account, error)
+	GetPrimary(userID int64) (*Account, error)
+	GetAllUserAccounts(userID int64) ([]*Account, error)
+	UpdateStatus(account *Account) error
+	CloseTx(
+		account *Account, prepare func(locked *Account, outstandingLoans bool) (*ledger.Entry, error),
+	) error
+}
+
+type Service struct {
+	Repo Repo
+}
+
+// Open opens a new, empty account of the given type and currency for the user. the account is in the
+// default currency when none is given
+func (s *Service) Open(
+	v *validator.Validator, userID int64, accountType, currency string,
+) (*Account, error) {
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	account := &Account{
+		UserID:   userID,
+		Type:     accountType,
+		Status:   StatusActive,
+		Currency: currency,
+	}
+	if ValidateAccount(v, account); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	err := s.Repo.Insert(account)
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func (s *Service) GetAccount(accountID int64) (*Account, error) {
+	return s.Repo.Get(accountID)
+}
+
+func (s *Service) GetAccountByNumber(number string) (*Account, error) {
+	return s.Repo.GetByNumber(number)
+}
+
+// GetUserAccount gets the user's account with the given number, or their primary account when no
+// number is given. an account that belongs to someone else is reported as not found
+func (s *Service) GetUserAccount(userID int64, number string) (*Account, error) {
+	if number == "" {
+		return s.Repo.GetPrimary(userID)
+	}
+
+	account, err := s.Repo.GetByNumber(number)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.UserID != userID {
+		return nil, user.ErrNoRecord
+	}
+
+	return account, nil
+}
+
+func (s *Service) GetAllUserAccounts(userID int64) ([]*Account, error) {
+	return s.Repo.GetAllUserAccounts(userID)
+}
+
+// HasActiveAccount is whether the user has an account money can leave
+func (s *Service) HasActiveAccount(userID int64) (bool, error) {
+	accounts, err := s.Repo.GetAllUserAccounts(userID)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.ContainsFunc(accounts, (*Account).IsActive), nil
+}
+
+// Freeze stops money leaving the account, it can still be paid into
+func (s *Service) Freeze(
+	v *validator.Validator, accountNumber, reason, changedBy string,
+) (*Account, error) {
+	return s.setStatus(v, accountNumber, reason, changedBy, StatusFrozen, StatusActive)
+}
+
+func (s *Service) Unfreeze(
+	v *validator.Validator, accountNumber, reason, changedBy string,
+) (*Account, error) {
+	return s.setStatus(v, accountNumber, reason, changedBy, StatusActive, StatusFrozen)
+}
+
+// setStatus moves the account to the new status if it is in one of the statuses it can be moved from
+func (s *Service) setStatus(
+	v *validator.Validator, accountNumber, reason, changedBy, newStatus string, from ...string,
+) (*Account, error) {
+	if ValidateStatusChange(v, reason, changedBy); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	account, err := s.Repo.GetByNumber(accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(from, account.Status) {
+		v.AddError("status", fmt.Sprintf("cannot be changed from %s to %s", account.Status, newStatus))
+		return nil, validator.ErrFailedValidation
+	}
+
+	account.Status = newStatus
+	account.StatusReason = reason
+	account.StatusChangedBy = changedBy
+	err = s.Repo.UpdateStatus(account)
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// Close closes the account for good, active or frozen. it can only be closed with nothing on hold and
+// its loans paid off, and with nothing left in it unless payOut is set, in which case what is left is
+// paid out in cash
+func (s *Service) Close(
+	v *validator.Validator, accountNumber, reason, closedBy string, payOut bool,
+) (*Account, error) {
+	if ValidateStatusChange(v, reason, closedBy); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	account, err := s.Repo.GetByNumber(accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Status == StatusClosed {
+		v.AddError("account", "is already closed")
+		return nil, validator.ErrFailedValidation
+	}
+
+	account.StatusReason = reason
+	account.StatusChangedBy = closedBy
+	err = s.Repo.CloseTx(account,
+		func(locked *Account, outstandingLoans bool) (*ledger.Entry, error) {
+			v.CheckAddError(
+				!outstandingLoans, "loans",
+				"must be paid off, and loan requests answered, before the account is closed",
+			)
+			v.CheckAddError(
+				locked.HeldAmount == 0, "held amount",
+				"must be released or captured before the account is closed",
+			)
+			v.CheckAddError(locked.Balance >= 0, "account balance", "is overdrawn")
+			v.CheckAddError(
+				locked.Balance <= 0 || payOut, "account balance", "must be zero, or paid out",
+			)
+			if !v.IsValid() {
+				return nil, validator.ErrFailedValidation
+			}
+
+			if locked.Balance == 0 {
+				return nil, nil
+			}
+			// the rest goes out over the counter
+			return ledger.NewEntry(
+				"account closure payout",
+				ledger.AccountPosting(locked.ID, locked.Currency, locked.Balance.Neg()),
+				ledger.SystemPosting(ledger.AccountCash, locked.Currency, locked.Balance),
+			), nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	account.HeldAmount = 0
+	account.AvailableBalance = account.Balance
+
+	return account, nil
+}
@@ -0,0 +1,420 @@
+package account
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// ---MOCKS---
+
+type MockRepo struct {
+	InsertErr error
+	Inserted  []*Account
+
+	Accounts []*Account
+
+	UpdateStatusErr error
+
+	// CloseTx hands prepare the account as it is and OutstandingLoans, and keeps the payout entry
+	OutstandingLoans bool
+	CloseTxErr       error
+	Payout           *ledger.Entry
+}
+
+func (r *MockRepo) Insert(account *Account) error {
+	if r.InsertErr != nil {
+		return r.InsertErr
+	}
+	account.ID = int64(len(r.Accounts) + 1)
+	account.Number = "1000000000"
+	r.Inserted = append(r.Inserted, account)
+	return nil
+}
+
+func (r *MockRepo) Get(accountID int64) (*Account, error) {
+	for _, a := range r.Accounts {
+		if a.ID == accountID {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (r *MockRepo) GetByNumber(number string) (*Account, error) {
+	for _, a := range r.Accounts {
+		if a.Number == number {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (r *MockRepo) GetPrimary(userID int64) (*Account, error) {
+	for _, a := range r.Accounts {
+		if a.UserID == userID && a.Status != StatusClosed {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (r *MockRepo) GetAllUserAccounts(userID int64) ([]*Account, error) {
+	var accounts []*Account
+	for _, a := range r.Accounts {
+		if a.UserID == userID {
+			accounts = append(accounts, a)
+		}
+	}
+	return accounts, nil
+}
+
+func (r *MockRepo) UpdateStatus(account *Account) error {
+	if r.UpdateStatusErr != nil {
+		return r.UpdateStatusErr
+	}
+	account.Version++
+	return nil
+}
+
+func (r *MockRepo) CloseTx(
+	account *Account, prepare func(locked *Account, outstandingLoans bool) (*ledger.Entry, error),
+) error {
+	if r.CloseTxErr != nil {
+		return r.CloseTxErr
+	}
+	locked := *account
+	entry, err := prepare(&locked, r.OutstandingLoans)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		r.Payout = entry
+		account.Balance = 0
+	}
+	account.Status = StatusClosed
+	account.Version++
+	return nil
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name        string
+		setupRepo   func(*MockRepo)
+		userID      int64
+		accountType string
+		currency    string
+		expectedErr error
+	}{
+		{
+			name:        "checking",
+			setupRepo:   func(r *MockRepo) {},
+			userID:      1,
+			accountType: TypeChecking,
+		},
+		{
+			name:        "savings",
+			setupRepo:   func(r *MockRepo) {},
+			userID:      1,
+			accountType: TypeSavings,
+		},
+		{
+			name:        "in euros",
+			setupRepo:   func(r *MockRepo) {},
+			userID:      1,
+			accountType: TypeChecking,
+			currency:    "EUR",
+		},
+		{
+			name:        "unknown currency",
+			setupRepo:   func(r *MockRepo) {},
+			userID:      1,
+			accountType: TypeChecking,
+			currency:    "XYZ",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:        "unknown type",
+			setupRepo:   func(r *MockRepo) {},
+			userID:      1,
+			accountType: "CRYPTO",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:        "no user",
+			setupRepo:   func(r *MockRepo) {},
+			accountType: TypeChecking,
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "Insert failure",
+			setupRepo: func(r *MockRepo) {
+				r.InsertErr = errors.New("db error")
+			},
+			userID:      1,
+			accountType: TypeChecking,
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			tc.setupRepo(repo)
+			svc := Service{Repo: repo}
+
+			account, gotErr := svc.Open(validator.New(), tc.userID, tc.accountType, tc.currency)
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if len(repo.Inserted) != 1 {
+				t.Fatalf("expected 1 account to be inserted, got %d", len(repo.Inserted))
+			}
+			if account.Type != tc.accountType || account.UserID != tc.userID {
+				t.Errorf("unexpected account %+v", account)
+			}
+			wantCurrency := tc.currency
+			if wantCurrency == "" {
+				wantCurrency = money.DefaultCurrency
+			}
+			if account.Currency != wantCurrency {
+				t.Errorf("expected currency %s, got %s", wantCurrency, account.Currency)
+			}
+			if !account.IsActive() {
+				t.Errorf("expected a new account to be active, got status=%s", account.Status)
+			}
+		})
+	}
+}
+
+func TestGetUserAccount(t *testing.T) {
+	accounts := []*Account{
+		{ID: 1, UserID: 1, Number: "1000000000", Type: TypeChecking, Status: StatusActive},
+		{ID: 2, UserID: 1, Number: "1000000001", Type: TypeSavings, Status: StatusActive},
+		{ID: 3, UserID: 2, Number: "1000000002", Type: TypeChecking, Status: StatusActive},
+	}
+
+	tests := []struct {
+		name        string
+		userID      int64
+		number      string
+		wantID      int64
+		expectedErr error
+	}{
+		{name: "primary account", userID: 1, wantID: 1},
+		{name: "by number", userID: 1, number: "1000000001", wantID: 2},
+		{
+			name: "someone else's account", userID: 1, number: "1000000002",
+			expectedErr: user.ErrNoRecord,
+		},
+		{
+			name: "unknown number", userID: 1, number: "9999999999",
+			expectedErr: user.ErrNoRecord,
+		},
+		{name: "user without accounts", userID: 3, expectedErr: user.ErrNoRecord},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := Service{Repo: &MockRepo{Accounts: accounts}}
+
+			account, gotErr := svc.GetUserAccount(tc.userID, tc.number)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if account.ID != tc.wantID {
+				t.Errorf("expected account id %d, got %d", tc.wantID, account.ID)
+			}
+		})
+	}
+}
+
+func TestFreezeAndUnfreeze(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		freeze      bool
+		reason      string
+		updateErr   error
+		wantStatus  string
+		expectedErr error
+	}{
+		{name: "freeze", status: StatusActive, freeze: true, reason: "fraud", wantStatus: StatusFrozen},
+		{name: "unfreeze", status: StatusFrozen, reason: "cleared", wantStatus: StatusActive},
+		{
+			name: "freeze a frozen account", status: StatusFrozen, freeze: true, reason: "fraud",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "unfreeze an active account", status: StatusActive, reason: "cleared",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "freeze a closed account", status: StatusClosed, freeze: true, reason: "fraud",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "no reason", status: StatusActive, freeze: true,
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "edit conflict", status: StatusActive, freeze: true, reason: "fraud",
+			updateErr: ErrEditConflict, expectedErr: ErrEditConflict,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{
+				Accounts: []*Account{
+					{ID: 1, UserID: 1, Number: "1000000000", Status: tc.status, Version: 1},
+				},
+				UpdateStatusErr: tc.updateErr,
+			}
+			svc := Service{Repo: repo}
+
+			setStatus := svc.Unfreeze
+			if tc.freeze {
+				setStatus = svc.Freeze
+			}
+			account, gotErr := setStatus(validator.New(), "1000000000", tc.reason, "admin@bank.com")
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if account.Status != tc.wantStatus || account.StatusReason != tc.reason {
+				t.Errorf(
+					"expected status=%s reason=%q, got status=%s reason=%q", tc.wantStatus, tc.reason,
+					account.Status, account.StatusReason,
+				)
+			}
+			if account.Version != 2 {
+				t.Errorf("expected version=2, got %d", account.Version)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	tests := []struct {
+		name             string
+		status           string
+		balance          money.Amount
+		held             money.Amount
+		outstandingLoans bool
+		payOut           bool
+		wantPayout       bool
+		expectedErr      error
+	}{
+		{name: "empty account", status: StatusActive},
+		{name: "frozen account", status: StatusFrozen},
+		{
+			name: "pay out the rest", status: StatusActive, balance: money.MustParse("25.50"),
+			payOut: true, wantPayout: true,
+		},
+		{
+			name: "money left in it", status: StatusActive, balance: money.MustParse("25.50"),
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "money on hold", status: StatusActive, balance: money.MustParse("25.50"),
+			held: money.MustParse("5"), payOut: true, expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "loans not paid off", status: StatusActive, outstandingLoans: true,
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "already closed", status: StatusClosed,
+			expectedErr: validator.ErrFailedValidation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{
+				Accounts: []*Account{
+					{
+						ID: 1, UserID: 1, Number: "1000000000", Status: tc.status, Currency: "USD",
+						Balance: tc.balance, HeldAmount: tc.held,
+					},
+				},
+				OutstandingLoans: tc.outstandingLoans,
+			}
+			svc := Service{Repo: repo}
+
+			account, gotErr := svc.Close(
+				validator.New(), "1000000000", "customer asked", "admin@bank.com", tc.payOut,
+			)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if account.Status != StatusClosed || account.Balance != 0 {
+				t.Errorf("expected a closed, empty account, got %+v", account)
+			}
+			if gotPayout := repo.Payout != nil; gotPayout != tc.wantPayout {
+				t.Fatalf("expected payout=%v, got payout=%v", tc.wantPayout, gotPayout)
+			}
+			if !tc.wantPayout {
+				return
+			}
+
+			// everything that was left goes to cash
+			postings := repo.Payout.Postings
+			if postings[0].AccountID != 1 || postings[0].Amount != -tc.balance ||
+				postings[1].AccountCode != ledger.AccountCash || postings[1].Amount != tc.balance {
+				t.Errorf("unexpected postings %+v %+v", postings[0], postings[1])
+			}
+			v := validator.New()
+			if ledger.ValidateEntry(v, repo.Payout); !v.IsValid() {
+				t.Errorf("unbalanced entry: %v", v.Errors)
+			}
+		})
+	}
+}
+
+func TestValidNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{number: "100000000093", want: true},
+		{number: "100000009987", want: true},
+		{number: "100000000094", want: false}, // wrong check digits
+		{number: "100000000039", want: false}, // check digits swapped
+		{number: "100000001093", want: false}, // a digit mistyped
+		{number: "1000000000", want: false},   // from before there were check digits
+		{number: "10000000009a", want: false},
+		{number: "01", want: false},
+		{number: "", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.number, func(t *testing.T) {
+			if got := ValidNumber(tc.number); got != tc.want {
+				t.Errorf("expected ValidNumber(%q)=%v, got %v", tc.number, tc.want, got)
+			}
+		})
+	}
+}
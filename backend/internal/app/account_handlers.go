@@ -0,0 +1,166 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+func (app *Application) OpenAccount(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Type     string `json:"type"`
+		Currency string `json:"currency"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	accountService := account.Service{
+		Repo: &account.Repository{DB: app.DB},
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	a, err := accountService.Open(v, u.ID, input.Type, input.Currency)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusCreated, jsonutil.Envelope{
+		"message": "account opened successfully",
+		"account": a,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) GetUserAccountsByToken(w http.ResponseWriter, r *http.Request) {
+	accountService := &account.Service{
+		Repo: &account.Repository{DB: app.DB},
+	}
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return accountService.GetAllUserAccounts(userID)
+		},
+		"accounts",
+	)
+}
+
+// FreezeAccount stops money leaving an account, it can still be paid into
+func (app *Application) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	app.setAccountStatus(w, r, (*account.Service).Freeze, "account frozen successfully")
+}
+
+func (app *Application) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	app.setAccountStatus(w, r, (*account.Service).Unfreeze, "account unfrozen successfully")
+}
+
+// setAccountStatus reads the account and the reason from the request and changes the status with
+// setStatus, the admin making the request is recorded as the one who changed it
+func (app *Application) setAccountStatus(
+	w http.ResponseWriter, r *http.Request,
+	setStatus func(
+		s *account.Service, v *validator.Validator, accountNumber, reason, changedBy string,
+	) (*account.Account, error),
+	message string,
+) {
+	var input struct {
+		AccountNumber string `json:"account_number"`
+		Reason        string `json:"reason"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	accountService := &account.Service{
+		Repo: &account.Repository{DB: app.DB},
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	a, err := setStatus(accountService, v, input.AccountNumber, input.Reason, u.Email)
+	if err != nil {
+		app.accountErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": message,
+		"account": a,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// CloseAccount closes an account for good. whatever is left in it is paid out in cash when pay_out is
+// set, otherwise it has to be empty
+func (app *Application) CloseAccount(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		AccountNumber string `json:"account_number"`
+		Reason        string `json:"reason"`
+		PayOut        bool   `json:"pay_out"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	accountService := &account.Service{
+		Repo: &account.Repository{DB: app.DB},
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	a, err := accountService.Close(v, input.AccountNumber, input.Reason, u.Email, input.PayOut)
+	if err != nil {
+		app.accountErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": "account closed successfully",
+		"account": a,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) accountErrorResponse(
+	w http.ResponseWriter, r *http.Request, v *validator.Validator, err error,
+) {
+	switch {
+	case errors.Is(err, validator.ErrFailedValidation):
+		app.FailedValidationResponse(w, v.Errors)
+
+	case errors.Is(err, user.ErrNoRecord):
+		app.NotFoundResponse(w, r)
+
+	case errors.Is(err, account.ErrEditConflict):
+		app.EditConflictResponse(w)
+
+	default:
+		app.ServerError(w, r, err)
+	}
+}
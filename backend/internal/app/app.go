@@ -6,7 +6,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
+	"github.com/Yusufdot101/goBankBackend/internal/interest"
 	"github.com/Yusufdot101/goBankBackend/internal/jsonlog"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
 	_ "github.com/lib/pq"
 )
 
@@ -36,6 +39,23 @@ type Config struct {
 	CORS struct {
 		TrustedOrigins []string
 	}
+	Scheduler struct {
+		Enabled  bool
+		Interval time.Duration
+	}
+	// how often the events in the outbox are published
+	Events struct {
+		Interval time.Duration
+	}
+	// the transfer and withdrawal limits of users who don't have their own
+	Limits limit.Limits
+	// the rules transfers and withdrawals are screened for fraud with
+	Fraud      fraud.Rules
+	Statements struct {
+		Email bool
+	}
+	// the interest paid on savings, none when empty
+	SavingsInterest interest.Tiers
 }
 
 type Application struct {
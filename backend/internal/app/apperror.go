@@ -23,6 +23,9 @@ func (app *Application) ErrorResponse(w http.ResponseWriter, statusCode int, mes
 // ServerError is for errors that aren't caused by the client
 func (app *Application) ServerError(w http.ResponseWriter, r *http.Request, err error) {
 	app.LogError(err)
+	if stored, ok := r.Context().Value(serverErrorContextKey).(*error); ok {
+		*stored = err
+	}
 
 	message := "the server encountered and error and could not resolve your request"
 	app.ErrorResponse(w, http.StatusInternalServerError, message)
@@ -68,6 +71,11 @@ func (app *Application) RequireActivatedUserResponse(w http.ResponseWriter) {
 	app.ErrorResponse(w, http.StatusUnauthorized, message)
 }
 
+func (app *Application) RequireActiveAccountResponse(w http.ResponseWriter) {
+	message := "your accounts are frozen or closed, money cannot be sent from them"
+	app.ErrorResponse(w, http.StatusForbidden, message)
+}
+
 func (app *Application) RequireAuthorizedUserResponse(w http.ResponseWriter) {
 	message := "you need need to be authorized to access this resource"
 	app.ErrorResponse(w, http.StatusForbidden, message)
@@ -84,3 +92,12 @@ func (app *Application) RequirePermissionResponse(w http.ResponseWriter) {
 	message := "You do not have the necessary permission to access this resource"
 	app.ErrorResponse(w, http.StatusForbidden, message)
 }
+
+func (app *Application) IdempotencyConflictResponse(w http.ResponseWriter, err error) {
+	app.ErrorResponse(w, http.StatusConflict, err.Error())
+}
+
+func (app *Application) EditConflictResponse(w http.ResponseWriter) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	app.ErrorResponse(w, http.StatusConflict, message)
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/batch"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// batchService is shared by the handlers and the scheduler, it needs the whole transfer service to
+// make the transfers
+func (app *Application) batchService() *batch.Service {
+	userService := &user.Service{Repo: &user.Repository{DB: app.DB}}
+	accountService := &account.Service{Repo: &account.Repository{DB: app.DB}}
+
+	return &batch.Service{
+		Repo:           &batch.Repository{DB: app.DB},
+		UserService:    userService,
+		AccountService: accountService,
+		TransferService: &transfer.Service{
+			Repo:           &transfer.Repository{DB: app.DB},
+			UserService:    userService,
+			AccountService: accountService,
+			FX:             &fx.Service{Repo: &fx.Repository{DB: app.DB}},
+			Limits:         app.limitService(),
+			Fraud:          app.fraudService(),
+			Unattended:     true,
+		},
+	}
+}
+
+// UploadBatch takes a CSV file of transfers, sent as text in the csv field, to be made from one of
+// the user's accounts. the batch is run by the scheduler, so it is only accepted here
+func (app *Application) UploadBatch(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		FromAccount string `json:"from_account"`
+		Mode        string `json:"mode"`
+		CSV         string `json:"csv"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	b, err := app.batchService().Upload(
+		v, u, input.FromAccount, input.Mode, strings.NewReader(input.CSV),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusAccepted, jsonutil.Envelope{
+		"message": "batch accepted, the transfers will be made shortly",
+		"batch":   b,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// GetBatch sends one of the user's batches with what happened to each of its rows
+func (app *Application) GetBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := app.readUserBatch(w, r)
+	if !ok {
+		return
+	}
+
+	err := jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{"batch": b})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// GetBatchResults sends the rows of one of the user's batches as a CSV file, each with what
+// happened to it and the transfer it made
+func (app *Application) GetBatchResults(w http.ResponseWriter, r *http.Request) {
+	b, ok := app.readUserBatch(w, r)
+	if !ok {
+		return
+	}
+
+	// render the whole file before writing anything, so a failure can still be sent as an error
+	file := &bytes.Buffer{}
+	err := batch.WriteResultsCSV(file, b)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set(
+		"Content-Disposition",
+		`attachment; filename="batch-`+strconv.FormatInt(b.ID, 10)+`-results.csv"`,
+	)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(file.Bytes())
+}
+
+// readUserBatch reads the batch named in the request, sending the error response and returning
+// false if it can't
+func (app *Application) readUserBatch(w http.ResponseWriter, r *http.Request) (*batch.Batch, bool) {
+	var input struct {
+		BatchID int64 `json:"batch_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return nil, false
+	}
+
+	u := app.getUserContext(r)
+	b, err := app.batchService().GetBatch(input.BatchID, u.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return b, true
+}
+
+func (app *Application) GetUserBatchesByToken(w http.ResponseWriter, r *http.Request) {
+	batchService := app.batchService()
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return batchService.GetAllUserBatches(userID)
+		},
+		"batches",
+	)
+}
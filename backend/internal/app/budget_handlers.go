@@ -0,0 +1,158 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/budget"
+	"github.com/Yusufdot101/goBankBackend/internal/category"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// budgetService is shared by the handlers and the scheduler, which sets the mailer to send the
+// alerts
+func (app *Application) budgetService() *budget.Service {
+	return &budget.Service{
+		Repo:           &budget.Repository{DB: app.DB},
+		Categories:     &category.Service{Repo: &category.Repository{DB: app.DB}},
+		AccountService: &account.Service{Repo: &account.Repository{DB: app.DB}},
+	}
+}
+
+// GetUserInsightsByToken sends what the user spent on each of their categories in each month from
+// and to, as "2006-01". they default to the 12 months up to the current one
+func (app *Application) GetUserInsightsByToken(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	to := time.Now().UTC()
+	if input.To != "" {
+		to, err = time.Parse("2006-01", input.To)
+		v.CheckAddError(err == nil, "to", "must be a month like 2006-01")
+	}
+	from := time.Date(to.Year(), to.Month()-11, 1, 0, 0, 0, 0, time.UTC)
+	if input.From != "" {
+		from, err = time.Parse("2006-01", input.From)
+		v.CheckAddError(err == nil, "from", "must be a month like 2006-01")
+	}
+	if !v.IsValid() {
+		app.FailedValidationResponse(w, v.Errors)
+		return
+	}
+
+	u := app.getUserContext(r)
+	spending, err := app.budgetService().Insights(v, u.ID, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{"spending": spending})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// SaveBudget sets the monthly budget of one of the user's categories in the currency, replacing
+// the one it had. alert has the user emailed when they go over it
+func (app *Application) SaveBudget(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Category string       `json:"category"`
+		Amount   money.Amount `json:"amount"`
+		Currency string       `json:"currency"`
+		Alert    bool         `json:"alert"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	b, err := app.budgetService().Save(
+		v, u.ID, input.Category, input.Amount, input.Currency, input.Alert,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": "budget saved successfully",
+		"budget":  b,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		BudgetID int64 `json:"budget_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	u := app.getUserContext(r)
+	err = app.budgetService().Delete(input.BudgetID, u.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": "budget deleted successfully",
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// GetUserBudgetsByToken sends the user's budgets with how much of each has been spent this month
+func (app *Application) GetUserBudgetsByToken(w http.ResponseWriter, r *http.Request) {
+	budgetService := app.budgetService()
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return budgetService.GetUserProgress(userID, time.Now())
+		},
+		"budgets",
+	)
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Yusufdot101/goBankBackend/internal/category"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+func (app *Application) NewCategory(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	categoryService := category.Service{Repo: &category.Repository{DB: app.DB}}
+	c, err := categoryService.New(v, u.ID, input.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusCreated, jsonutil.Envelope{
+		"message":  "category created successfully",
+		"category": c,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CategoryID int64 `json:"category_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	u := app.getUserContext(r)
+	categoryService := category.Service{Repo: &category.Repository{DB: app.DB}}
+	err = categoryService.Delete(input.CategoryID, u.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": "category deleted successfully",
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// CategorizeTransfer files a transfer the user sent or received under one of their categories, an
+// empty category takes it out of the one it is in
+func (app *Application) CategorizeTransfer(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TransferID int64  `json:"transfer_id"`
+		Category   string `json:"category"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	categoryService := category.Service{Repo: &category.Repository{DB: app.DB}}
+	err = categoryService.CategorizeTransfer(v, u.ID, input.TransferID, input.Category)
+	app.categorizeResponse(w, r, v, err, "transfer")
+}
+
+func (app *Application) CategorizeTransaction(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TransactionID int64  `json:"transaction_id"`
+		Category      string `json:"category"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	categoryService := category.Service{Repo: &category.Repository{DB: app.DB}}
+	err = categoryService.CategorizeTransaction(v, u.ID, input.TransactionID, input.Category)
+	app.categorizeResponse(w, r, v, err, "transaction")
+}
+
+func (app *Application) categorizeResponse(
+	w http.ResponseWriter, r *http.Request, v *validator.Validator, err error, what string,
+) {
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": what + " categorized successfully",
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) GetUserCategoriesByToken(w http.ResponseWriter, r *http.Request) {
+	categoryService := &category.Service{Repo: &category.Repository{DB: app.DB}}
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return categoryService.GetAllUserCategories(userID)
+		},
+		"categories",
+	)
+}
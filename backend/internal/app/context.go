@@ -29,3 +29,12 @@ func (app *Application) setUserContext(r *http.Request, u *user.User) *http.Requ
 	ctx := context.WithValue(r.Context(), userContextKey, u)
 	return r.WithContext(ctx)
 }
+
+var serverErrorContextKey = contextKey("server error")
+
+// give the request somewhere for ServerError to leave the error it answered with, so that the
+// middleware that set it can tell why the handler failed
+func (app *Application) setServerErrorContext(r *http.Request, err *error) *http.Request {
+	ctx := context.WithValue(r.Context(), serverErrorContextKey, err)
+	return r.WithContext(ctx)
+}
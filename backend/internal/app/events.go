@@ -0,0 +1,98 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/webhook"
+)
+
+// startEventDispatcher publishes the events in the outbox to their subscribers every interval until
+// done is closed. it is one of the background tasks, so the server waits for a dispatch that is in
+// progress before it stops. the events are logged and queued for the webhooks as they are published
+func (app *Application) startEventDispatcher(done <-chan struct{}) {
+	dispatcher := &event.Dispatcher{Repo: &event.Repository{DB: app.DB}}
+	dispatcher.Subscribe(event.All, app.logEvent)
+	dispatcher.Subscribe(event.All, app.webhookService().Enqueue)
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(app.Config.Events.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				app.dispatchEvents(dispatcher, now)
+			}
+		}
+	}()
+}
+
+func (app *Application) dispatchEvents(dispatcher *event.Dispatcher, now time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.LogError(fmt.Errorf("%s", err))
+		}
+	}()
+
+	if _, err := dispatcher.Dispatch(now); err != nil {
+		app.LogError(err)
+	}
+}
+
+func (app *Application) logEvent(e *event.Event) error {
+	app.Logger.PrintInfo("event", map[string]string{
+		"id":      strconv.FormatInt(e.ID, 10),
+		"type":    e.Type,
+		"user_id": strconv.FormatInt(e.UserID, 10),
+	})
+	return nil
+}
+
+// startWebhookDeliveries sends the webhook deliveries that are due every interval until done is
+// closed. it runs apart from the dispatcher so a slow endpoint doesn't hold up the events
+func (app *Application) startWebhookDeliveries(done <-chan struct{}) {
+	webhookService := app.webhookService()
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(app.Config.Events.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				app.deliverWebhooks(webhookService, now)
+			}
+		}
+	}()
+}
+
+func (app *Application) deliverWebhooks(webhookService *webhook.Service, now time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.LogError(fmt.Errorf("%s", err))
+		}
+	}()
+
+	delivered, err := webhookService.DeliverDue(now)
+	if err != nil {
+		app.LogError(err)
+	}
+	if delivered > 0 {
+		app.Logger.PrintInfo("webhook deliveries sent", map[string]string{
+			"count": strconv.Itoa(delivered),
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/transaction"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+func (app *Application) fraudService() *fraud.Service {
+	return &fraud.Service{
+		Repo:  &fraud.Repository{DB: app.DB},
+		Rules: app.Config.Fraud,
+		FX:    &fx.Service{Repo: &fx.Repository{DB: app.DB}},
+	}
+}
+
+// fraudReviewService is the fraud service with what it needs to make the operations staff approve
+func (app *Application) fraudReviewService() *fraud.Service {
+	accountService := &account.Service{Repo: &account.Repository{DB: app.DB}}
+
+	fraudService := app.fraudService()
+	fraudService.Makers = map[string]fraud.Maker{
+		fraud.KindTransfer: &transfer.Service{
+			Repo:           &transfer.Repository{DB: app.DB},
+			AccountService: accountService,
+			FX:             &fx.Service{Repo: &fx.Repository{DB: app.DB}},
+			Limits:         app.limitService(),
+		},
+		fraud.KindWithdrawal: &transaction.Service{
+			Repo:           &transaction.Repository{DB: app.DB},
+			AccountService: accountService,
+			Limits:         app.limitService(),
+		},
+	}
+	return fraudService
+}
+
+// HeldForReviewResponse tells the user the operation wasn't made but is waiting for staff to
+// review it
+func (app *Application) HeldForReviewResponse(w http.ResponseWriter, r *http.Request) {
+	err := jsonutil.WriteJSON(w, http.StatusAccepted, jsonutil.Envelope{
+		"message": "held for review, it will be made once the bank has approved it",
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// GetFraudReviewQueue sends the oldest transfers and withdrawals held for review with the rules
+// they set off, the ones still waiting unless another status is given
+func (app *Application) GetFraudReviewQueue(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	screenings, err := app.fraudService().GetReviewQueue(v, input.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{"screenings": screenings})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// ReviewScreening approves or rejects an operation held for review, a rejection needs a note. an
+// approved operation is made as it is approved
+func (app *Application) ReviewScreening(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ScreeningID int64  `json:"screening_id"`
+		Approve     bool   `json:"approve"`
+		Note        string `json:"note"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	screening, err := app.fraudReviewService().Review(
+		v, input.ScreeningID, u.ID, input.Approve, input.Note,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message":   "screening reviewed successfully",
+		"screening": screening,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+func (app *Application) LoadExchangeRates(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Rates []struct {
+			BaseCurrency  string     `json:"base_currency"`
+			QuoteCurrency string     `json:"quote_currency"`
+			Rate          fx.Decimal `json:"rate"`
+			Spread        fx.Decimal `json:"spread"`
+			EffectiveAt   time.Time  `json:"effective_at"`
+		} `json:"rates"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	rates := make([]*fx.Rate, len(input.Rates))
+	for i, rate := range input.Rates {
+		rates[i] = &fx.Rate{
+			BaseCurrency:  rate.BaseCurrency,
+			QuoteCurrency: rate.QuoteCurrency,
+			Rate:          rate.Rate,
+			Spread:        rate.Spread,
+			EffectiveAt:   rate.EffectiveAt,
+		}
+	}
+
+	fxService := fx.Service{
+		Repo: &fx.Repository{DB: app.DB},
+	}
+
+	v := validator.New()
+	err = fxService.LoadRates(v, rates)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusCreated, jsonutil.Envelope{
+		"message": "exchange rates loaded successfully",
+		"rates":   rates,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
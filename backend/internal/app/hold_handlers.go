@@ -0,0 +1,149 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/hold"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+func (app *Application) holdService() *hold.Service {
+	return &hold.Service{
+		Repo: &hold.Repository{DB: app.DB},
+		AccountService: &account.Service{
+			Repo: &account.Repository{DB: app.DB},
+		},
+	}
+}
+
+func (app *Application) PlaceHold(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		UserID        int64        `json:"user_id"`
+		AccountNumber string       `json:"account_number"`
+		Kind          string       `json:"kind"`
+		Amount        money.Amount `json:"amount"`
+		Description   string       `json:"description"`
+		PlacedBy      string       `json:"placed_by"`
+		ExpiresAt     time.Time    `json:"expires_at"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	h, err := app.holdService().Place(
+		v, input.UserID, input.AccountNumber, input.Kind, input.Amount, input.Description,
+		input.PlacedBy, input.ExpiresAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusCreated, jsonutil.Envelope{
+		"message": "hold placed successfully",
+		"hold":    h,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		HoldID int64        `json:"hold_id"`
+		Amount money.Amount `json:"amount"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	h, err := app.holdService().Capture(v, input.HoldID, input.Amount)
+	if err != nil {
+		app.holdErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": "hold captured successfully",
+		"hold":    h,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		HoldID int64 `json:"hold_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	h, err := app.holdService().Release(v, input.HoldID)
+	if err != nil {
+		app.holdErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": "hold released successfully",
+		"hold":    h,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) holdErrorResponse(
+	w http.ResponseWriter, r *http.Request, v *validator.Validator, err error,
+) {
+	switch {
+	case errors.Is(err, validator.ErrFailedValidation):
+		app.FailedValidationResponse(w, v.Errors)
+
+	case errors.Is(err, user.ErrNoRecord):
+		app.NotFoundResponse(w, r)
+
+	// the hold was captured, released or expired since it was read
+	case errors.Is(err, hold.ErrEditConflict):
+		app.EditConflictResponse(w)
+
+	default:
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) GetUserHoldsByToken(w http.ResponseWriter, r *http.Request) {
+	holdService := app.holdService()
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return holdService.GetAllUserHolds(userID)
+		},
+		"holds",
+	)
+}
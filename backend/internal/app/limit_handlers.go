@@ -0,0 +1,77 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+func (app *Application) limitService() *limit.Service {
+	return &limit.Service{
+		Repo:     &limit.Repository{DB: app.DB},
+		FX:       &fx.Service{Repo: &fx.Repository{DB: app.DB}},
+		Defaults: app.Config.Limits,
+	}
+}
+
+// SetUserLimits sets the limits of one user. a limit that is left out, or null, goes back to the
+// default
+func (app *Application) SetUserLimits(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		UserID         int64         `json:"user_id"`
+		PerTransaction *money.Amount `json:"per_transaction"`
+		Daily          *money.Amount `json:"daily"`
+		Weekly         *money.Amount `json:"weekly"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	limits, err := app.limitService().SetUserLimits(v, &limit.Override{
+		UserID:         input.UserID,
+		PerTransaction: input.PerTransaction,
+		Daily:          input.Daily,
+		Weekly:         input.Weekly,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": "limits set successfully",
+		"limits":  limits,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) GetUserLimitsByToken(w http.ResponseWriter, r *http.Request) {
+	limitService := app.limitService()
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return limitService.GetUserLimits(userID)
+		},
+		"limits",
+	)
+}
@@ -4,16 +4,18 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
 	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
 	"github.com/Yusufdot101/goBankBackend/internal/loan"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
 func (app *Application) PayLoan(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		LoadID int64   `json:"loan_id"`
-		Amount float64 `json:"amount"`
+		LoadID int64        `json:"loan_id"`
+		Amount money.Amount `json:"amount"`
 	}
 	err := jsonutil.ReadJSON(w, r, &input)
 	if err != nil {
@@ -21,12 +23,9 @@ func (app *Application) PayLoan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userService := user.Service{
-		Repo: &user.Repository{DB: app.DB},
-	}
 	loanService := loan.Service{
-		Repo:        &loan.Repository{DB: app.DB},
-		UserService: &userService,
+		Repo:           &loan.Repository{DB: app.DB},
+		AccountService: &account.Service{Repo: &account.Repository{DB: app.DB}},
 	}
 
 	v := validator.New()
@@ -4,16 +4,18 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
 	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
-	"github.com/Yusufdot101/goBankBackend/internal/loan"
 	"github.com/Yusufdot101/goBankBackend/internal/loanrequests"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
 func (app *Application) NewLoanRequest(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Amount float64 `json:"amount"`
+		AccountNumber string       `json:"account_number"`
+		Amount        money.Amount `json:"amount"`
 	}
 
 	err := jsonutil.ReadJSON(w, r, &input)
@@ -23,17 +25,23 @@ func (app *Application) NewLoanRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	loanRequestService := loanrequests.Service{
-		Repo: &loanrequests.Repository{DB: app.DB},
+		Repo:           &loanrequests.Repository{DB: app.DB},
+		AccountService: &account.Service{Repo: &account.Repository{DB: app.DB}},
 	}
 
 	v := validator.New()
 	u := app.getUserContext(r)
-	loanRequest, err := loanRequestService.New(v, u, input.Amount, app.Config.DailyInterestRate)
+	loanRequest, err := loanRequestService.New(
+		v, u, input.AccountNumber, input.Amount, app.Config.DailyInterestRate,
+	)
 	if err != nil {
 		switch {
 		case errors.Is(err, validator.ErrFailedValidation):
 			app.FailedValidationResponse(w, v.Errors)
 
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
 		default:
 			app.ServerError(w, r, err)
 		}
@@ -62,16 +70,8 @@ func (app *Application) RespondToLoanRequest(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	userService := user.Service{
-		Repo: &user.Repository{DB: app.DB},
-	}
-	loanService := loan.Service{
-		Repo: &loan.Repository{DB: app.DB},
-	}
 	loanRequestService := loanrequests.Service{
-		Repo:        &loanrequests.Repository{DB: app.DB},
-		UserService: &userService,
-		LoanService: &loanService,
+		Repo: &loanrequests.Repository{DB: app.DB},
 	}
 
 	var message string
@@ -1,13 +1,18 @@
 package app
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/idempotency"
 	"github.com/Yusufdot101/goBankBackend/internal/permission"
 	"github.com/Yusufdot101/goBankBackend/internal/token"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
@@ -148,6 +153,32 @@ func (app *Application) requireActivatedUser(next http.HandlerFunc) http.Handler
 	return app.requireAuthorizedUser(fn)
 }
 
+// requireActiveAccount turns away users who have no account money can leave, their accounts are all
+// frozen or closed, before anything is done to send money for them. which of their accounts is used is
+// left to the services, which check that account again
+func (app *Application) requireActiveAccount(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		u := app.getUserContext(r)
+		accountService := account.Service{
+			Repo: &account.Repository{DB: app.DB},
+		}
+
+		active, err := accountService.HasActiveAccount(u.ID)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		if !active {
+			app.RequireActiveAccountResponse(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireActivatedUser(fn)
+}
+
 func (app *Application) requireAuthorizedUser(next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		u := app.getUserContext(r)
@@ -194,6 +225,121 @@ func (app *Application) requirePermission(next http.HandlerFunc, code ...string)
 	return app.requireActivatedUser(fn)
 }
 
+// responseRecorder passes the response through to the client while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotent makes the handler safe to retry when the client sends an Idempotency-Key header. the
+// first response for a key is stored and sent back for any retry with the same key, so a retried
+// request never moves money twice. a server error is stored like any other response, unless the
+// handler failed on a transaction that was rolled back, then nothing was saved and the key is
+// freed for the retry. requests without the header are handled as usual
+func (app *Application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotency.HeaderKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// read the body to fingerprint it, then put it back for the handler
+		const maxBytes = 1_048_576
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			app.BadRequestResponse(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		idempotencyService := idempotency.Service{
+			Repo: &idempotency.Repository{DB: app.DB},
+		}
+
+		u := app.getUserContext(r)
+		v := validator.New()
+		fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, body)
+		record, replay, err := idempotencyService.Begin(v, u.ID, key, fingerprint)
+		if err != nil {
+			switch {
+			case errors.Is(err, validator.ErrFailedValidation):
+				app.FailedValidationResponse(w, v.Errors)
+			case errors.Is(err, idempotency.ErrKeyReused),
+				errors.Is(err, idempotency.ErrRequestInProgress):
+				app.IdempotencyConflictResponse(w, err)
+			default:
+				app.ServerError(w, r, err)
+			}
+			return
+		}
+
+		if replay {
+			w.Header().Set("Idempotent-Replayed", "true")
+			if record.ContentType != "" {
+				w.Header().Set("Content-Type", record.ContentType)
+			}
+			w.WriteHeader(record.StatusCode)
+			w.Write(record.ResponseBody)
+			return
+		}
+
+		// a handler that panics may have moved money before it did, so its server error is kept
+		// like any other
+		defer func() {
+			if p := recover(); p != nil {
+				err := idempotencyService.Complete(record, http.StatusInternalServerError, "", nil)
+				if err != nil {
+					app.LogError(err)
+				}
+				panic(p)
+			}
+		}()
+
+		var serverErr error
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, app.setServerErrorContext(r, &serverErr))
+		// a handler that wrote nothing answered 200 all the same
+		if rec.statusCode == 0 {
+			rec.statusCode = http.StatusOK
+		}
+
+		// the key is only freed when the handler failed on a transaction that was rolled back.
+		// anything else, say a failed read after the money moved, must not be run again
+		if dbtx.NotCommitted(serverErr) {
+			if err := idempotencyService.Abandon(record); err != nil {
+				app.LogError(err)
+			}
+			return
+		}
+
+		// if the response can't be stored the key stays taken and retries are told the request is
+		// still being processed, that is safer than running it again
+		err = idempotencyService.Complete(
+			record, rec.statusCode, w.Header().Get("Content-Type"), rec.body.Bytes(),
+		)
+		if err != nil {
+			app.LogError(err)
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
 func (app *Application) enableCORS(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Vary", "Origin")
@@ -211,7 +357,9 @@ func (app *Application) enableCORS(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set(
+			"Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key",
+		)
 		// Handle preflight OPTIONS request
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/overdraft"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// overdraftService is shared by the handlers and the scheduler, which charges the interest
+func (app *Application) overdraftService() *overdraft.Service {
+	return &overdraft.Service{
+		Repo:           &overdraft.Repository{DB: app.DB},
+		AccountService: &account.Service{Repo: &account.Repository{DB: app.DB}},
+	}
+}
+
+// SetOverdraft sets how far an account can go below zero and the daily interest, in percent, it is
+// charged on what it owes. a limit of 0 takes the overdraft away
+func (app *Application) SetOverdraft(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		AccountNumber     string       `json:"account_number"`
+		Limit             money.Amount `json:"limit"`
+		DailyInterestRate fx.Decimal   `json:"daily_interest_rate"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	o, err := app.overdraftService().SetOverdraft(
+		v, input.AccountNumber, input.Limit, input.DailyInterestRate,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message":   "overdraft set successfully",
+		"overdraft": o,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
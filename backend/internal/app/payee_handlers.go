@@ -0,0 +1,105 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/payee"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// payeeService finds the recipients the way transfers do, by account number or email
+func (app *Application) payeeService() *payee.Service {
+	userService := &user.Service{Repo: &user.Repository{DB: app.DB}}
+
+	return &payee.Service{
+		Repo: &payee.Repository{DB: app.DB},
+		Recipients: &transfer.Service{
+			UserService:    userService,
+			AccountService: &account.Service{Repo: &account.Repository{DB: app.DB}},
+		},
+		UserService: userService,
+	}
+}
+
+func (app *Application) SavePayee(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name      string `json:"name"`
+		ToAccount string `json:"to_account"`
+		ToEmail   string `json:"to_email"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	p, err := app.payeeService().Save(v, u.ID, input.Name, input.ToAccount, input.ToEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusCreated, jsonutil.Envelope{
+		"message": "payee saved successfully",
+		"payee":   p,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) DeletePayee(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PayeeID int64 `json:"payee_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	u := app.getUserContext(r)
+	err = app.payeeService().Delete(input.PayeeID, u.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": "payee deleted successfully",
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) GetUserPayeesByToken(w http.ResponseWriter, r *http.Request) {
+	payeeService := app.payeeService()
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return payeeService.GetAllUserPayees(userID)
+		},
+		"payees",
+	)
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/paymentrequest"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// paymentRequestService is shared by the handlers and the scheduler, it needs the whole transfer
+// service to pay the requests
+func (app *Application) paymentRequestService() *paymentrequest.Service {
+	userService := &user.Service{Repo: &user.Repository{DB: app.DB}}
+	accountService := &account.Service{Repo: &account.Repository{DB: app.DB}}
+
+	return &paymentrequest.Service{
+		Repo:           &paymentrequest.Repository{DB: app.DB},
+		UserService:    userService,
+		AccountService: accountService,
+		TransferService: &transfer.Service{
+			Repo:           &transfer.Repository{DB: app.DB},
+			UserService:    userService,
+			AccountService: accountService,
+			FX:             &fx.Service{Repo: &fx.Repository{DB: app.DB}},
+			Limits:         app.limitService(),
+			Fraud:          app.fraudService(),
+		},
+	}
+}
+
+func (app *Application) NewPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ToAccount    string       `json:"to_account"`
+		PayerEmail   string       `json:"payer_email"`
+		PayerAccount string       `json:"payer_account"`
+		Amount       money.Amount `json:"amount"`
+		Note         string       `json:"note"`
+		ExpiresAt    time.Time    `json:"expires_at"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	request, err := app.paymentRequestService().New(
+		v, u, input.ToAccount, input.PayerEmail, input.PayerAccount, input.Amount, input.Note,
+		input.ExpiresAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusCreated, jsonutil.Envelope{
+		"message":         "payment request sent successfully",
+		"payment_request": request,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) PayPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PaymentRequestID int64  `json:"payment_request_id"`
+		FromAccount      string `json:"from_account"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	request, tr, err := app.paymentRequestService().Pay(
+		v, u, input.PaymentRequestID, input.FromAccount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		case errors.Is(err, fraud.ErrHeldForReview):
+			app.HeldForReviewResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message":         "payment request paid successfully",
+		"payment_request": request,
+		"transfer":        tr,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) DeclinePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PaymentRequestID int64 `json:"payment_request_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	request, err := app.paymentRequestService().Decline(v, input.PaymentRequestID, u.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		case errors.Is(err, paymentrequest.ErrEditConflict):
+			app.EditConflictResponse(w)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message":         "payment request declined",
+		"payment_request": request,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) GetUserIncomingPaymentRequestsByToken(
+	w http.ResponseWriter, r *http.Request,
+) {
+	paymentRequestService := app.paymentRequestService()
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return paymentRequestService.GetIncoming(userID)
+		},
+		"payment_requests",
+	)
+}
+
+func (app *Application) GetUserOutgoingPaymentRequestsByToken(
+	w http.ResponseWriter, r *http.Request,
+) {
+	paymentRequestService := app.paymentRequestService()
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return paymentRequestService.GetOutgoing(userID)
+		},
+		"payment_requests",
+	)
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/reconcile"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+// Reconcile runs a reconciliation of every account and logs a summary of it. it is used by the
+// endpoint and the reconcile command alike
+func (app *Application) Reconcile(triggeredBy string) (*reconcile.Run, error) {
+	reconcileService := reconcile.Service{
+		Repo: &reconcile.Repository{DB: app.DB},
+	}
+
+	run, err := reconcileService.Reconcile(triggeredBy)
+	if err != nil {
+		return nil, err
+	}
+
+	app.Logger.PrintInfo("reconciliation finished", run.Summary())
+	for _, d := range run.Discrepancies {
+		app.Logger.PrintInfo("reconciliation discrepancy", map[string]string{
+			"run_id":           strconv.FormatInt(run.ID, 10),
+			"account_id":       strconv.FormatInt(d.AccountID, 10),
+			"currency":         d.Currency,
+			"expected_balance": d.ExpectedBalance.String(),
+			"ledger_balance":   d.LedgerBalance.String(),
+			"account_balance":  d.AccountBalance.String(),
+		})
+	}
+
+	return run, nil
+}
+
+func (app *Application) RunReconciliation(w http.ResponseWriter, r *http.Request) {
+	u := app.getUserContext(r)
+	run, err := app.Reconcile("user:" + strconv.FormatInt(u.ID, 10))
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message":        "reconciliation finished",
+		"reconciliation": run,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// GetReconciliationReport sends the run with the given id, or the latest one when no id is given,
+// with the discrepancies it found
+func (app *Application) GetReconciliationReport(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RunID int64 `json:"run_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	reconcileService := reconcile.Service{
+		Repo: &reconcile.Repository{DB: app.DB},
+	}
+	run, err := reconcileService.GetRun(input.RunID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{"reconciliation": run})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
@@ -26,11 +26,121 @@ func (app *Application) Routes() http.Handler {
 		http.MethodPut, "/v1/tokens/deactivate", app.requireAuthorizedUser(app.DeactivateToken),
 	)
 
-	router.HandlerFunc(http.MethodPut, "/v1/transfer", app.requireActivatedUser(app.TransferMoney))
+	router.HandlerFunc(http.MethodPost, "/v1/accounts", app.requireActivatedUser(app.OpenAccount))
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/accounts/freeze",
+		app.requirePermission(app.FreezeAccount, "MANAGE_ACCOUNTS", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/accounts/unfreeze",
+		app.requirePermission(app.UnfreezeAccount, "MANAGE_ACCOUNTS", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/accounts/close",
+		app.requirePermission(
+			app.idempotent(app.CloseAccount), "MANAGE_ACCOUNTS", "ADMIN", "SUPERUSER",
+		),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/transfer", app.requireActiveAccount(app.idempotent(app.TransferMoney)),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/transfers/refund",
+		app.requireActiveAccount(app.idempotent(app.RefundTransfer)),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/transfers/reverse",
+		app.requirePermission(
+			app.idempotent(app.ReverseTransfer), "REVERSE_TRANSFERS", "ADMIN", "SUPERUSER",
+		),
+	)
+
+	router.HandlerFunc(http.MethodPost, "/v1/payees", app.requireActivatedUser(app.SavePayee))
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/payees/delete", app.requireActivatedUser(app.DeletePayee),
+	)
+
+	router.HandlerFunc(
+		http.MethodPost, "/v1/categories", app.requireActivatedUser(app.NewCategory),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/categories/delete", app.requireActivatedUser(app.DeleteCategory),
+	)
+
+	router.HandlerFunc(http.MethodPut, "/v1/budgets", app.requireActivatedUser(app.SaveBudget))
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/budgets/delete", app.requireActivatedUser(app.DeleteBudget),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/transfers/categorize",
+		app.requireActivatedUser(app.CategorizeTransfer),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/transactions/categorize",
+		app.requireActivatedUser(app.CategorizeTransaction),
+	)
+
+	router.HandlerFunc(
+		http.MethodPost, "/v1/paymentrequests", app.requireActivatedUser(app.NewPaymentRequest),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/paymentrequests/pay",
+		app.requireActiveAccount(app.idempotent(app.PayPaymentRequest)),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/paymentrequests/decline",
+		app.requireActivatedUser(app.DeclinePaymentRequest),
+	)
+
+	router.HandlerFunc(
+		http.MethodPost, "/v1/batches",
+		app.requirePermission(
+			app.idempotent(app.UploadBatch), "BULK_TRANSFERS", "ADMIN", "SUPERUSER",
+		),
+	)
+
+	router.HandlerFunc(http.MethodPut, "/v1/batches/get", app.requireActivatedUser(app.GetBatch))
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/batches/results", app.requireActivatedUser(app.GetBatchResults),
+	)
+
+	router.HandlerFunc(
+		http.MethodPost, "/v1/standingorders", app.requireActiveAccount(app.NewStandingOrder),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/standingorders/pause", app.requireActivatedUser(app.PauseStandingOrder),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/standingorders/resume",
+		app.requireActivatedUser(app.ResumeStandingOrder),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/standingorders/cancel",
+		app.requireActivatedUser(app.CancelStandingOrder),
+	)
 
 	router.HandlerFunc(http.MethodPut, "/v1/loans/get", app.requireActivatedUser(app.NewLoanRequest))
 
-	router.HandlerFunc(http.MethodPut, "/v1/loans/pay", app.requireActivatedUser(app.PayLoan))
+	router.HandlerFunc(
+		http.MethodPut, "/v1/loans/pay", app.requireActivatedUser(app.idempotent(app.PayLoan)),
+	)
 
 	router.HandlerFunc(
 		http.MethodPut, "/v1/loans/respond",
@@ -52,14 +162,86 @@ func (app *Application) Routes() http.Handler {
 		app.requirePermission(app.AddNewPermisison, "SUPERUSER"),
 	)
 
+	router.HandlerFunc(
+		http.MethodPost, "/v1/fx/rates",
+		app.requirePermission(app.LoadExchangeRates, "MANAGE_FX_RATES", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/reconciliation/run",
+		app.requirePermission(app.RunReconciliation, "RECONCILE", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/reconciliation/report",
+		app.requirePermission(app.GetReconciliationReport, "RECONCILE", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/fraud/reviews",
+		app.requirePermission(app.GetFraudReviewQueue, "REVIEW_FRAUD", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/fraud/reviews/decide",
+		app.requirePermission(app.ReviewScreening, "REVIEW_FRAUD", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPost, "/v1/webhooks",
+		app.requirePermission(app.RegisterWebhook, "MANAGE_WEBHOOKS", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/webhooks/delete",
+		app.requirePermission(app.DeleteWebhook, "MANAGE_WEBHOOKS", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/webhooks/deliveries",
+		app.requirePermission(app.GetWebhookDeliveries, "MANAGE_WEBHOOKS", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/webhooks/replay",
+		app.requirePermission(
+			app.ReplayWebhookDeliveries, "MANAGE_WEBHOOKS", "ADMIN", "SUPERUSER",
+		),
+	)
+
 	router.HandlerFunc(
 		http.MethodPut, "/v1/deposit",
-		app.requirePermission(app.DepositMoney, "DEPOSIT", "ADMIN", "SUPERUSER"),
+		app.requirePermission(app.idempotent(app.DepositMoney), "DEPOSIT", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/limits",
+		app.requirePermission(app.SetUserLimits, "MANAGE_LIMITS", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/overdrafts",
+		app.requirePermission(app.SetOverdraft, "MANAGE_OVERDRAFTS", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPost, "/v1/holds",
+		app.requirePermission(app.idempotent(app.PlaceHold), "MANAGE_HOLDS", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/holds/capture",
+		app.requirePermission(app.idempotent(app.CaptureHold), "MANAGE_HOLDS", "ADMIN", "SUPERUSER"),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/holds/release",
+		app.requirePermission(app.ReleaseHold, "MANAGE_HOLDS", "ADMIN", "SUPERUSER"),
 	)
 
 	router.HandlerFunc(
 		http.MethodPut, "/v1/withdraw",
-		app.requirePermission(app.WithdrawMoney, "WITHDDRAW", "ADMIN", "SUPERUSER"),
+		app.requirePermission(app.idempotent(app.WithdrawMoney), "WITHDDRAW", "ADMIN", "SUPERUSER"),
 	)
 
 	// used by the front end
@@ -67,11 +249,76 @@ func (app *Application) Routes() http.Handler {
 		http.MethodPut, "/v1/users/get",
 		app.requireAuthorizedUser(app.GetUserByToken),
 	)
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/accounts",
+		app.requireAuthorizedUser(app.GetUserAccountsByToken),
+	)
+
 	router.HandlerFunc(
 		http.MethodPut, "/v1/users/transfers",
 		app.requireAuthorizedUser(app.GetUserTransfersByToken),
 	)
 
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/payees",
+		app.requireAuthorizedUser(app.GetUserPayeesByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/categories",
+		app.requireAuthorizedUser(app.GetUserCategoriesByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/budgets",
+		app.requireAuthorizedUser(app.GetUserBudgetsByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/insights",
+		app.requireAuthorizedUser(app.GetUserInsightsByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/paymentrequests/incoming",
+		app.requireAuthorizedUser(app.GetUserIncomingPaymentRequestsByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/paymentrequests/outgoing",
+		app.requireAuthorizedUser(app.GetUserOutgoingPaymentRequestsByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/batches",
+		app.requireAuthorizedUser(app.GetUserBatchesByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/standingorders",
+		app.requireAuthorizedUser(app.GetUserStandingOrdersByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/statement",
+		app.requireAuthorizedUser(app.GetUserStatementByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/limits",
+		app.requireAuthorizedUser(app.GetUserLimitsByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/holds",
+		app.requireAuthorizedUser(app.GetUserHoldsByToken),
+	)
+
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/webhooks",
+		app.requireAuthorizedUser(app.GetUserWebhooksByToken),
+	)
+
 	router.HandlerFunc(
 		http.MethodPut, "/v1/users/loanrequests",
 		app.requireAuthorizedUser(app.GetUserLoanRequestsByToken),
@@ -87,6 +334,11 @@ func (app *Application) Routes() http.Handler {
 		app.requireAuthorizedUser(app.GetUserTransactionsByToken),
 	)
 
+	router.HandlerFunc(
+		http.MethodPut, "/v1/users/ledger",
+		app.requireAuthorizedUser(app.GetUserLedgerEntriesByToken),
+	)
+
 	router.HandlerFunc(http.MethodPut, "/v1/ping", app.requireAuthorizedUser(app.Healthcheck))
 
 	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router))))
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/interest"
+	"github.com/Yusufdot101/goBankBackend/internal/mailer"
+	"github.com/Yusufdot101/goBankBackend/internal/standingorder"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+// startScheduler executes the due standing orders and runs the uploaded transfer batches every
+// interval, and every hour charges the interest on overdrawn accounts, accrues and pays savings
+// interest, expires the payment requests that ran out of time, emails the alerts of the budgets
+// that were gone over and, when they are enabled, the monthly statements, until done is closed. it
+// is one of the background tasks, so the server waits for a run that is in progress before it stops
+func (app *Application) startScheduler(done <-chan struct{}) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(app.Config.Scheduler.Interval)
+		defer ticker.Stop()
+
+		hourly := time.NewTicker(time.Hour)
+		defer hourly.Stop()
+
+		app.Logger.PrintInfo("scheduler running", map[string]string{
+			"interval":         app.Config.Scheduler.Interval.String(),
+			"statements_email": strconv.FormatBool(app.Config.Statements.Email),
+		})
+		for {
+			select {
+			case <-done:
+				app.Logger.PrintInfo("scheduler stopped", nil)
+				return
+			case now := <-ticker.C:
+				app.executeStandingOrders(now)
+				app.processBatches(now)
+			case now := <-hourly.C:
+				app.chargeOverdraftInterest(now)
+				app.accrueSavingsInterest(now)
+				app.expirePaymentRequests(now)
+				app.sendBudgetAlerts(now)
+				if app.Config.Statements.Email {
+					app.sendStatements(now)
+				}
+			}
+		}
+	}()
+}
+
+func (app *Application) executeStandingOrders(now time.Time) {
+	// a panic in one run should not take the scheduler down with it
+	defer func() {
+		if err := recover(); err != nil {
+			app.LogError(fmt.Errorf("%s", err))
+		}
+	}()
+
+	executed, err := app.standingOrderService().ExecuteDue(now)
+	if err != nil {
+		app.LogError(err)
+	}
+	if executed > 0 {
+		app.Logger.PrintInfo("standing orders executed", map[string]string{
+			"count": strconv.Itoa(executed),
+		})
+	}
+}
+
+// standingOrderService is shared by the handlers and the scheduler, it needs the whole transfer
+// service to make the transfers
+func (app *Application) standingOrderService() *standingorder.Service {
+	userService := &user.Service{Repo: &user.Repository{DB: app.DB}}
+	accountService := &account.Service{Repo: &account.Repository{DB: app.DB}}
+
+	return &standingorder.Service{
+		Repo:           &standingorder.Repository{DB: app.DB},
+		UserService:    userService,
+		AccountService: accountService,
+		TransferService: &transfer.Service{
+			Repo:           &transfer.Repository{DB: app.DB},
+			UserService:    userService,
+			AccountService: accountService,
+			FX:             &fx.Service{Repo: &fx.Repository{DB: app.DB}},
+			Limits:         app.limitService(),
+			Fraud:          app.fraudService(),
+			Unattended:     true,
+		},
+	}
+}
+
+func (app *Application) processBatches(now time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.LogError(fmt.Errorf("%s", err))
+		}
+	}()
+
+	processed, err := app.batchService().ProcessPending(now)
+	if err != nil {
+		app.LogError(err)
+	}
+	if processed > 0 {
+		app.Logger.PrintInfo("transfer batches processed", map[string]string{
+			"count": strconv.Itoa(processed),
+		})
+	}
+}
+
+func (app *Application) chargeOverdraftInterest(now time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.LogError(fmt.Errorf("%s", err))
+		}
+	}()
+
+	charged, err := app.overdraftService().ChargeInterest(now)
+	if err != nil {
+		app.LogError(err)
+	}
+	if charged > 0 {
+		app.Logger.PrintInfo("overdraft interest charged", map[string]string{
+			"count": strconv.Itoa(charged),
+		})
+	}
+}
+
+// accrueSavingsInterest accrues the days that have passed and then pays what was accrued before
+// this month. the payment waits for the next run when the accrual fails, so no day is left out of it
+func (app *Application) accrueSavingsInterest(now time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.LogError(fmt.Errorf("%s", err))
+		}
+	}()
+
+	interestService := &interest.Service{
+		Repo:  &interest.Repository{DB: app.DB},
+		Tiers: app.Config.SavingsInterest,
+	}
+
+	days, err := interestService.Accrue(now)
+	if err != nil {
+		app.LogError(err)
+		return
+	}
+	if days > 0 {
+		app.Logger.PrintInfo("savings interest accrued", map[string]string{
+			"days": strconv.Itoa(days),
+		})
+	}
+
+	paid, err := interestService.PayMonthly(now)
+	if err != nil {
+		app.LogError(err)
+	}
+	if paid > 0 {
+		app.Logger.PrintInfo("savings interest paid", map[string]string{
+			"count": strconv.Itoa(paid),
+		})
+	}
+}
+
+func (app *Application) expirePaymentRequests(now time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.LogError(fmt.Errorf("%s", err))
+		}
+	}()
+
+	expired, err := app.paymentRequestService().ExpireDue(now)
+	if err != nil {
+		app.LogError(err)
+	}
+	if expired > 0 {
+		app.Logger.PrintInfo("payment requests expired", map[string]string{
+			"count": strconv.Itoa(expired),
+		})
+	}
+}
+
+func (app *Application) sendBudgetAlerts(now time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.LogError(fmt.Errorf("%s", err))
+		}
+	}()
+
+	budgetService := app.budgetService()
+	budgetService.Mailer = mailer.NewMailerFromEnv()
+
+	sent, err := budgetService.SendAlerts(now)
+	if err != nil {
+		app.LogError(err)
+	}
+	if sent > 0 {
+		app.Logger.PrintInfo("budget alerts sent", map[string]string{
+			"count": strconv.Itoa(sent),
+		})
+	}
+}
+
+func (app *Application) sendStatements(now time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.LogError(fmt.Errorf("%s", err))
+		}
+	}()
+
+	statementService := app.statementService()
+	statementService.Mailer = mailer.NewMailerFromEnv()
+
+	sent, err := statementService.SendMonthly(now)
+	if err != nil {
+		app.LogError(err)
+	}
+	if sent > 0 {
+		app.Logger.PrintInfo("statements sent", map[string]string{
+			"count": strconv.Itoa(sent),
+		})
+	}
+}
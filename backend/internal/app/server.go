@@ -19,6 +19,14 @@ func (app *Application) Serve() error {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// closed on shutdown to stop the scheduler, the event dispatcher and the webhook deliveries
+	done := make(chan struct{})
+	if app.Config.Scheduler.Enabled {
+		app.startScheduler(done)
+	}
+	app.startEventDispatcher(done)
+	app.startWebhookDeliveries(done)
+
 	// channel to hold the error, if an error occured durinng shutdown
 	shutdownError := make(chan error)
 	go func() {
@@ -42,6 +50,7 @@ func (app *Application) Serve() error {
 		}
 
 		app.Logger.PrintInfo("finishing background tasks", nil)
+		close(done)
 		app.wg.Wait()
 		shutdownError <- err
 	}()
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/standingorder"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+func (app *Application) NewStandingOrder(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		FromAccount string       `json:"from_account"`
+		ToAccount   string       `json:"to_account"`
+		ToEmail     string       `json:"to_email"`
+		Amount      money.Amount `json:"amount"`
+		Frequency   string       `json:"frequency"`
+		StartAt     time.Time    `json:"start_at"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	order, err := app.standingOrderService().New(
+		v, u, input.FromAccount, input.ToAccount, input.ToEmail, input.Amount, input.Frequency,
+		input.StartAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusCreated, jsonutil.Envelope{
+		"message":        "standing order created successfully",
+		"standing_order": order,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) PauseStandingOrder(w http.ResponseWriter, r *http.Request) {
+	app.changeStandingOrder(w, r, (*standingorder.Service).Pause, "standing order paused")
+}
+
+func (app *Application) ResumeStandingOrder(w http.ResponseWriter, r *http.Request) {
+	app.changeStandingOrder(w, r, (*standingorder.Service).Resume, "standing order resumed")
+}
+
+func (app *Application) CancelStandingOrder(w http.ResponseWriter, r *http.Request) {
+	app.changeStandingOrder(w, r, (*standingorder.Service).Cancel, "standing order cancelled")
+}
+
+// changeStandingOrder reads the id of one of the user's standing orders and applies the change to it
+func (app *Application) changeStandingOrder(
+	w http.ResponseWriter, r *http.Request,
+	change func(
+		s *standingorder.Service, v *validator.Validator, orderID, userID int64,
+	) (*standingorder.StandingOrder, error),
+	message string,
+) {
+	var input struct {
+		StandingOrderID int64 `json:"standing_order_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	order, err := change(app.standingOrderService(), v, input.StandingOrderID, u.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		case errors.Is(err, standingorder.ErrEditConflict):
+			app.EditConflictResponse(w)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message":        message,
+		"standing_order": order,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) GetUserStandingOrdersByToken(w http.ResponseWriter, r *http.Request) {
+	standingOrderService := app.standingOrderService()
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return standingOrderService.GetAllUserStandingOrders(userID)
+		},
+		"standing_orders",
+	)
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/statement"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+func (app *Application) statementService() *statement.Service {
+	return &statement.Service{
+		Repo: &statement.Repository{DB: app.DB},
+		AccountService: &account.Service{
+			Repo: &account.Repository{DB: app.DB},
+		},
+	}
+}
+
+// GetUserStatementByToken sends the statement of one of the user's accounts for the days from and
+// to, as "2006-01-02", as a PDF, a CSV file or JSON
+func (app *Application) GetUserStatementByToken(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		AccountNumber string `json:"account_number"`
+		From          string `json:"from"`
+		To            string `json:"to"`
+		Format        string `json:"format"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	from, err := time.Parse(time.DateOnly, input.From)
+	v.CheckAddError(err == nil, "from", "must be a date like 2006-01-02")
+	to, err := time.Parse(time.DateOnly, input.To)
+	v.CheckAddError(err == nil, "to", "must be a date like 2006-01-02")
+	if input.Format == "" {
+		input.Format = "pdf"
+	}
+	v.CheckAddError(
+		validator.ValueInList(input.Format, "pdf", "csv", "json"), "format",
+		"must be pdf, csv or json",
+	)
+	if !v.IsValid() {
+		app.FailedValidationResponse(w, v.Errors)
+		return
+	}
+
+	u := app.getUserContext(r)
+	s, err := app.statementService().Generate(v, u.ID, input.AccountNumber, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	if input.Format == "json" {
+		err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{"statement": s})
+		if err != nil {
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	// render the whole file before writing anything, so a failure can still be sent as an error
+	file := &bytes.Buffer{}
+	contentType := "application/pdf"
+	if input.Format == "csv" {
+		contentType = "text/csv"
+		err = statement.WriteCSV(file, s)
+	} else {
+		err = statement.WritePDF(file, s)
+	}
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set(
+		"Content-Disposition", `attachment; filename="`+s.Filename()+"."+input.Format+`"`,
+	)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(file.Bytes())
+}
@@ -4,7 +4,10 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
 	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/transaction"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
@@ -12,9 +15,12 @@ import (
 
 func (app *Application) DepositMoney(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		UserID      int64   `json:"user_id"`
-		Amount      float64 `json:"amount"`
-		PerformedBy string  `json:"performed_by"`
+		UserID        int64        `json:"user_id"`
+		AccountNumber string       `json:"account_number"`
+		Amount        money.Amount `json:"amount"`
+		PerformedBy   string       `json:"performed_by"`
+		Memo          string       `json:"memo"`
+		Reference     string       `json:"reference"`
 	}
 
 	err := jsonutil.ReadJSON(w, r, &input)
@@ -24,17 +30,17 @@ func (app *Application) DepositMoney(w http.ResponseWriter, r *http.Request) {
 	}
 
 	v := validator.New()
-	userService := &user.Service{
-		Repo: &user.Repository{DB: app.DB},
+	accountService := &account.Service{
+		Repo: &account.Repository{DB: app.DB},
 	}
-
 	transactionService := transaction.Service{
-		Repo:        &transaction.Repository{DB: app.DB},
-		UserService: userService,
+		Repo:           &transaction.Repository{DB: app.DB},
+		AccountService: accountService,
 	}
 
 	tr, err := transactionService.Deposit(
-		v, input.UserID, input.Amount, input.PerformedBy,
+		v, input.UserID, input.AccountNumber, input.Amount, input.PerformedBy,
+		input.Memo, input.Reference,
 	)
 	if err != nil {
 		switch {
@@ -63,9 +69,12 @@ func (app *Application) DepositMoney(w http.ResponseWriter, r *http.Request) {
 
 func (app *Application) WithdrawMoney(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		UserID      int64   `json:"user_id"`
-		Amount      float64 `json:"amount"`
-		PerformedBy string  `json:"performed_by"`
+		UserID        int64        `json:"user_id"`
+		AccountNumber string       `json:"account_number"`
+		Amount        money.Amount `json:"amount"`
+		PerformedBy   string       `json:"performed_by"`
+		Memo          string       `json:"memo"`
+		Reference     string       `json:"reference"`
 	}
 
 	err := jsonutil.ReadJSON(w, r, &input)
@@ -75,11 +84,18 @@ func (app *Application) WithdrawMoney(w http.ResponseWriter, r *http.Request) {
 	}
 
 	v := validator.New()
+	accountService := &account.Service{
+		Repo: &account.Repository{DB: app.DB},
+	}
 	transactionService := transaction.Service{
-		Repo: &transaction.Repository{DB: app.DB},
+		Repo:           &transaction.Repository{DB: app.DB},
+		AccountService: accountService,
+		Limits:         app.limitService(),
+		Fraud:          app.fraudService(),
 	}
 	tr, err := transactionService.Withdraw(
-		v, input.UserID, input.Amount, input.PerformedBy,
+		v, input.UserID, input.AccountNumber, input.Amount, input.PerformedBy,
+		input.Memo, input.Reference,
 	)
 	if err != nil {
 		switch {
@@ -89,6 +105,9 @@ func (app *Application) WithdrawMoney(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, user.ErrNoRecord):
 			app.NotFoundResponse(w, r)
 
+		case errors.Is(err, fraud.ErrHeldForReview):
+			app.HeldForReviewResponse(w, r)
+
 		default:
 			app.ServerError(w, r, err)
 		}
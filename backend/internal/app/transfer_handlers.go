@@ -4,7 +4,12 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
 	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/payee"
 	"github.com/Yusufdot101/goBankBackend/internal/transfer"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
@@ -12,8 +17,13 @@ import (
 
 func (app *Application) TransferMoney(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		ToEmail string  `json:"to_email"`
-		Amount  float64 `json:"amount"`
+		FromAccount string       `json:"from_account"`
+		ToAccount   string       `json:"to_account"`
+		ToEmail     string       `json:"to_email"`
+		PayeeID     int64        `json:"payee_id"`
+		Amount      money.Amount `json:"amount"`
+		Memo        string       `json:"memo"`
+		Reference   string       `json:"reference"`
 	}
 
 	err := jsonutil.ReadJSON(w, r, &input)
@@ -27,18 +37,43 @@ func (app *Application) TransferMoney(w http.ResponseWriter, r *http.Request) {
 	}
 
 	transferService := transfer.Service{
-		Repo:        &transfer.Repository{DB: app.DB},
-		UserService: &userService,
+		Repo:           &transfer.Repository{DB: app.DB},
+		UserService:    &userService,
+		AccountService: &account.Service{Repo: &account.Repository{DB: app.DB}},
+		FX:             &fx.Service{Repo: &fx.Repository{DB: app.DB}},
+		Limits:         app.limitService(),
+		Payees:         &payee.Service{Repo: &payee.Repository{DB: app.DB}},
+		Fraud:          app.fraudService(),
 	}
 
 	fromUser := app.getUserContext(r)
 	v := validator.New()
-	tr, fromUser, err := transferService.TransferMoney(v, fromUser, input.ToEmail, input.Amount)
+	var tr *transfer.Transfer
+	if input.PayeeID != 0 {
+		// a payee is the recipient, it can't be named twice
+		if input.ToAccount != "" || input.ToEmail != "" {
+			v.AddError("payee id", "cannot be given with to_account or to_email")
+			app.FailedValidationResponse(w, v.Errors)
+			return
+		}
+		tr, fromUser, err = transferService.PayPayee(
+			v, fromUser, input.FromAccount, input.PayeeID, input.Amount, input.Memo,
+			input.Reference,
+		)
+	} else {
+		tr, fromUser, err = transferService.TransferMoney(
+			v, fromUser, input.FromAccount, input.ToAccount, input.ToEmail, input.Amount,
+			input.Memo, input.Reference,
+		)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, validator.ErrFailedValidation):
 			app.FailedValidationResponse(w, v.Errors)
 
+		case errors.Is(err, fraud.ErrHeldForReview):
+			app.HeldForReviewResponse(w, r)
+
 		default:
 			app.ServerError(w, r, err)
 		}
@@ -54,3 +89,66 @@ func (app *Application) TransferMoney(w http.ResponseWriter, r *http.Request) {
 		app.ServerError(w, r, err)
 	}
 }
+
+func (app *Application) RefundTransfer(w http.ResponseWriter, r *http.Request) {
+	app.sendTransferBack(w, r, false)
+}
+
+func (app *Application) ReverseTransfer(w http.ResponseWriter, r *http.Request) {
+	app.sendTransferBack(w, r, true)
+}
+
+// sendTransferBack refunds a transfer the user received, or with reverse reverses any transfer on
+// behalf of the bank. leaving out the amount sends back all that is left of the transfer
+func (app *Application) sendTransferBack(w http.ResponseWriter, r *http.Request, reverse bool) {
+	var input struct {
+		TransferID int64        `json:"transfer_id"`
+		Amount     money.Amount `json:"amount"`
+		Reason     string       `json:"reason"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	transferService := transfer.Service{
+		Repo:           &transfer.Repository{DB: app.DB},
+		AccountService: &account.Service{Repo: &account.Repository{DB: app.DB}},
+	}
+
+	u := app.getUserContext(r)
+	v := validator.New()
+	var tr *transfer.Transfer
+	if reverse {
+		tr, err = transferService.Reverse(v, u.ID, input.TransferID, input.Amount, input.Reason)
+	} else {
+		tr, err = transferService.Refund(v, u, input.TransferID, input.Amount, input.Reason)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	message := "transfer refunded successfully"
+	if reverse {
+		message = "transfer reversed successfully"
+	}
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message":  message,
+		"transfer": tr,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
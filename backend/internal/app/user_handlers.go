@@ -4,11 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/history"
 	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
 	"github.com/Yusufdot101/goBankBackend/internal/loan"
 	"github.com/Yusufdot101/goBankBackend/internal/loanrequests"
 	"github.com/Yusufdot101/goBankBackend/internal/mailer"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/token"
 	"github.com/Yusufdot101/goBankBackend/internal/transaction"
 	"github.com/Yusufdot101/goBankBackend/internal/transfer"
@@ -71,8 +75,89 @@ func (app *Application) fetchUserData(
 		return
 	}
 
+	app.writeUserData(w, r, validator.New(), input.TokenPlaintext,
+		func(userID int64) (jsonutil.Envelope, error) {
+			data, err := fetch(userID)
+			return jsonutil.Envelope{key: data}, err
+		},
+	)
+}
+
+type userHistoryFetcher func(
+	v *validator.Validator, userID int64, q *history.Query,
+) (any, *history.Page, error)
+
+// fetchUserHistory is fetchUserData for the history endpoints. the body can also filter, sort and
+// page through the rows, from and to being days like 2006-01-02, and the page is written with its
+// total and next cursor under metadata
+func (app *Application) fetchUserHistory(
+	w http.ResponseWriter,
+	r *http.Request,
+	fetch userHistoryFetcher,
+	key string,
+) {
+	var input struct {
+		TokenPlaintext string        `json:"token"`
+		From           string        `json:"from"`
+		To             string        `json:"to"`
+		MinAmount      *money.Amount `json:"min_amount"`
+		MaxAmount      *money.Amount `json:"max_amount"`
+		Counterparty   string        `json:"counterparty"`
+		Status         string        `json:"status"`
+		Category       string        `json:"category"`
+		Sort           string        `json:"sort"`
+		Cursor         string        `json:"cursor"`
+		PageSize       int           `json:"page_size"`
+	}
+	if err := jsonutil.ReadJSON(w, r, &input); err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
 	v := validator.New()
-	if token.ValidateToken(v, input.TokenPlaintext); !v.IsValid() {
+	q := &history.Query{
+		MinAmount:    input.MinAmount,
+		MaxAmount:    input.MaxAmount,
+		Counterparty: input.Counterparty,
+		Status:       input.Status,
+		Category:     input.Category,
+		Sort:         input.Sort,
+		Cursor:       input.Cursor,
+		PageSize:     input.PageSize,
+	}
+	if input.From != "" {
+		from, err := time.Parse(time.DateOnly, input.From)
+		v.CheckAddError(err == nil, "from", "must be a date like 2006-01-02")
+		q.From = &from
+	}
+	if input.To != "" {
+		to, err := time.Parse(time.DateOnly, input.To)
+		v.CheckAddError(err == nil, "to", "must be a date like 2006-01-02")
+		q.To = &to
+	}
+	if !v.IsValid() {
+		app.FailedValidationResponse(w, v.Errors)
+		return
+	}
+
+	app.writeUserData(w, r, v, input.TokenPlaintext,
+		func(userID int64) (jsonutil.Envelope, error) {
+			data, page, err := fetch(v, userID, q)
+			return jsonutil.Envelope{key: data, "metadata": page}, err
+		},
+	)
+}
+
+// writeUserData looks up the user the token belongs to and writes the envelope fetch returns for
+// them. v is the one fetch validates with
+func (app *Application) writeUserData(
+	w http.ResponseWriter,
+	r *http.Request,
+	v *validator.Validator,
+	tokenPlaintext string,
+	fetch func(userID int64) (jsonutil.Envelope, error),
+) {
+	if token.ValidateToken(v, tokenPlaintext); !v.IsValid() {
 		app.FailedValidationResponse(w, v.Errors)
 		return
 	}
@@ -83,7 +168,7 @@ func (app *Application) fetchUserData(
 		TokenService: &tokenService,
 	}
 
-	u, err := userService.GetUserForToken(input.TokenPlaintext, token.ScopeAuthorization)
+	u, err := userService.GetUserForToken(tokenPlaintext, token.ScopeAuthorization)
 	if err != nil {
 		switch {
 		case errors.Is(err, user.ErrNoRecord):
@@ -97,6 +182,8 @@ func (app *Application) fetchUserData(
 	data, err := fetch(u.ID)
 	if err != nil {
 		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
 		case errors.Is(err, user.ErrNoRecord):
 			app.NotFoundResponse(w, r)
 		default:
@@ -105,9 +192,7 @@ func (app *Application) fetchUserData(
 		return
 	}
 
-	if err := jsonutil.WriteJSON(
-		w, http.StatusAccepted, jsonutil.Envelope{key: data},
-	); err != nil {
+	if err := jsonutil.WriteJSON(w, http.StatusAccepted, data); err != nil {
 		app.ServerError(w, r, err)
 	}
 }
@@ -117,9 +202,9 @@ func (app *Application) GetUserTransfersByToken(w http.ResponseWriter, r *http.R
 		Repo:        &transfer.Repository{DB: app.DB},
 		UserService: &user.Service{Repo: &user.Repository{DB: app.DB}},
 	}
-	app.fetchUserData(w, r,
-		func(userID int64) (any, error) {
-			return transferService.GetAllUserTransfers(userID)
+	app.fetchUserHistory(w, r,
+		func(v *validator.Validator, userID int64, q *history.Query) (any, *history.Page, error) {
+			return transferService.GetAllUserTransfers(v, userID, q)
 		},
 		"transfers",
 	)
@@ -127,12 +212,11 @@ func (app *Application) GetUserTransfersByToken(w http.ResponseWriter, r *http.R
 
 func (app *Application) GetUserLoanRequestsByToken(w http.ResponseWriter, r *http.Request) {
 	loanRequestService := &loanrequests.Service{
-		Repo:        &loanrequests.Repository{DB: app.DB},
-		UserService: &user.Service{Repo: &user.Repository{DB: app.DB}},
+		Repo: &loanrequests.Repository{DB: app.DB},
 	}
-	app.fetchUserData(w, r,
-		func(userID int64) (any, error) {
-			return loanRequestService.GetAllUserLoanRequests(userID)
+	app.fetchUserHistory(w, r,
+		func(v *validator.Validator, userID int64, q *history.Query) (any, *history.Page, error) {
+			return loanRequestService.GetAllUserLoanRequests(v, userID, q)
 		},
 		"loan_requests",
 	)
@@ -140,12 +224,11 @@ func (app *Application) GetUserLoanRequestsByToken(w http.ResponseWriter, r *htt
 
 func (app *Application) GetUserLoansByToken(w http.ResponseWriter, r *http.Request) {
 	loanService := &loan.Service{
-		Repo:        &loan.Repository{DB: app.DB},
-		UserService: &user.Service{Repo: &user.Repository{DB: app.DB}},
+		Repo: &loan.Repository{DB: app.DB},
 	}
-	app.fetchUserData(w, r,
-		func(userID int64) (any, error) {
-			return loanService.GetAllUserLoans(userID)
+	app.fetchUserHistory(w, r,
+		func(v *validator.Validator, userID int64, q *history.Query) (any, *history.Page, error) {
+			return loanService.GetAllUserLoans(v, userID, q)
 		},
 		"loans",
 	)
@@ -153,14 +236,25 @@ func (app *Application) GetUserLoansByToken(w http.ResponseWriter, r *http.Reque
 
 func (app *Application) GetUserTransactionsByToken(w http.ResponseWriter, r *http.Request) {
 	transactionService := &transaction.Service{
-		Repo:        &transaction.Repository{DB: app.DB},
-		UserService: &user.Service{Repo: &user.Repository{DB: app.DB}},
+		Repo: &transaction.Repository{DB: app.DB},
+	}
+	app.fetchUserHistory(w, r,
+		func(v *validator.Validator, userID int64, q *history.Query) (any, *history.Page, error) {
+			return transactionService.GetAllUserTransactions(v, userID, q)
+		},
+		"transactions",
+	)
+}
+
+func (app *Application) GetUserLedgerEntriesByToken(w http.ResponseWriter, r *http.Request) {
+	ledgerService := &ledger.Service{
+		Repo: &ledger.Repository{DB: app.DB},
 	}
 	app.fetchUserData(w, r,
 		func(userID int64) (any, error) {
-			return transactionService.GetAllUserTransactions(userID)
+			return ledgerService.GetUserEntries(userID)
 		},
-		"transactions",
+		"ledger_entries",
 	)
 }
 
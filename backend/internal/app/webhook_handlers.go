@@ -0,0 +1,173 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/jsonutil"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+	"github.com/Yusufdot101/goBankBackend/internal/webhook"
+)
+
+// webhookService is shared by the handlers, the event dispatcher that queues the deliveries and
+// the background task that sends them
+func (app *Application) webhookService() *webhook.Service {
+	return &webhook.Service{Repo: &webhook.Repository{DB: app.DB}}
+}
+
+// RegisterWebhook registers an endpoint to be sent the events of the types, "*" for all of them.
+// the secret the deliveries are signed with is only in this response
+func (app *Application) RegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	hook, err := app.webhookService().Register(v, u.ID, input.URL, input.EventTypes)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusCreated, jsonutil.Envelope{
+		"message": "webhook registered successfully",
+		"webhook": hook,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		WebhookID int64 `json:"webhook_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	u := app.getUserContext(r)
+	err = app.webhookService().Delete(input.WebhookID, u.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message": "webhook deleted successfully",
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// GetWebhookDeliveries sends the newest deliveries of one of the user's webhooks with every attempt
+// to send them, only the ones with the status when one is given
+func (app *Application) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		WebhookID int64  `json:"webhook_id"`
+		Status    string `json:"status"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	deliveries, err := app.webhookService().GetDeliveries(v, input.WebhookID, u.ID, input.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{"deliveries": deliveries})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+// ReplayWebhookDeliveries sends a delivery again, or every failed delivery of a webhook when only
+// the webhook is given
+func (app *Application) ReplayWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		DeliveryID int64 `json:"delivery_id"`
+		WebhookID  int64 `json:"webhook_id"`
+	}
+
+	err := jsonutil.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, err)
+		return
+	}
+
+	v := validator.New()
+	u := app.getUserContext(r)
+	replayed, err := app.webhookService().Replay(
+		v, u.ID, input.DeliveryID, input.WebhookID, time.Now().UTC(),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			app.FailedValidationResponse(w, v.Errors)
+
+		case errors.Is(err, user.ErrNoRecord):
+			app.NotFoundResponse(w, r)
+
+		default:
+			app.ServerError(w, r, err)
+		}
+		return
+	}
+
+	err = jsonutil.WriteJSON(w, http.StatusOK, jsonutil.Envelope{
+		"message":  "deliveries replayed successfully",
+		"replayed": replayed,
+	})
+	if err != nil {
+		app.ServerError(w, r, err)
+	}
+}
+
+func (app *Application) GetUserWebhooksByToken(w http.ResponseWriter, r *http.Request) {
+	webhookService := app.webhookService()
+	app.fetchUserData(w, r,
+		func(userID int64) (any, error) {
+			return webhookService.GetAllUserWebhooks(userID)
+		},
+		"webhooks",
+	)
+}
@@ -0,0 +1,137 @@
+package batch
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// the columns an uploaded file can have, in any order. the reference is sent with the transfer, so
+// the recipient can tell what it is for, and is in the results for the payer
+var columns = []string{"to_account", "to_email", "amount", "reference"}
+
+// ParseCSV reads the rows of an uploaded file, which starts with a header naming its columns. what
+// is wrong with the file as a whole is added to v under "file", and what is wrong with a row under
+// its line. reading stops once the file has more rows than a batch can have
+func ParseCSV(v *validator.Validator, r io.Reader) []*Row {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			v.AddError("file", "must not be empty")
+		} else {
+			v.AddError("file", err.Error())
+		}
+		return nil
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		// spreadsheets like to start the files they save with a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(columns, name) {
+			v.AddError("file", "unknown column "+strconv.Quote(name))
+			return nil
+		}
+		if _, ok := index[name]; ok {
+			v.AddError("file", "column "+strconv.Quote(name)+" is given twice")
+			return nil
+		}
+		index[name] = i
+	}
+	_, hasAccount := index["to_account"]
+	_, hasEmail := index["to_email"]
+	_, hasAmount := index["amount"]
+	v.CheckAddError(hasAccount || hasEmail, "file", "must have a to_account or to_email column")
+	v.CheckAddError(hasAmount, "file", "must have an amount column")
+	if !v.IsValid() {
+		return nil
+	}
+
+	var rows []*Row
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			v.AddError("file", err.Error())
+			return nil
+		}
+
+		if len(rows) == maxRows {
+			v.AddError("file", "must not have more than 1000 rows")
+			return nil
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := &Row{
+			Line:            line,
+			ToAccountNumber: field("to_account"),
+			ToUserEmail:     field("to_email"),
+			Reference:       field("reference"),
+			Status:          RowPending,
+		}
+		row.Amount, err = money.Parse(field("amount"))
+		if err != nil {
+			v.AddError(row.key(), "amount: must be an amount like 12.34")
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// WriteResultsCSV writes the rows of the batch as CSV, each with what happened to it
+func WriteResultsCSV(w io.Writer, b *Batch) error {
+	cw := csv.NewWriter(w)
+
+	records := [][]string{
+		{
+			"line", "to_account", "to_email", "amount", "reference", "status", "error",
+			"transfer_id",
+		},
+	}
+	for _, row := range b.Rows {
+		transferID := ""
+		if row.TransferID != nil {
+			transferID = strconv.FormatInt(*row.TransferID, 10)
+		}
+		records = append(records, []string{
+			strconv.Itoa(row.Line),
+			row.ToAccountNumber,
+			row.ToUserEmail,
+			row.Amount.String(),
+			row.Reference,
+			row.Status,
+			row.Error,
+			transferID,
+		})
+	}
+
+	err := cw.WriteAll(records)
+	if err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
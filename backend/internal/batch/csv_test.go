@@ -0,0 +1,119 @@
+package batch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		wantRows   []Row
+		wantErrKey string
+	}{
+		{
+			name: "valid file",
+			file: "to_account,amount,reference\n" +
+				"100000000190,10.50,march pay\n" +
+				"100000000287, 20,\n",
+			wantRows: []Row{
+				{
+					Line: 2, ToAccountNumber: "100000000190", Amount: money.MustParse("10.50"),
+					Reference: "march pay",
+				},
+				{Line: 3, ToAccountNumber: "100000000287", Amount: money.MustParse("20")},
+			},
+		},
+		{
+			name: "columns in any order with a byte order mark",
+			file: "\ufeffAmount,To_Email\n5,a@b.com\n",
+			wantRows: []Row{
+				{Line: 2, ToUserEmail: "a@b.com", Amount: money.MustParse("5")},
+			},
+		},
+		{
+			name: "quoted field over two lines",
+			file: "to_account,amount,reference\n" +
+				"100000000190,1,\"two\nlines\"\n" +
+				"100000000287,2,\n",
+			wantRows: []Row{
+				{
+					Line: 2, ToAccountNumber: "100000000190", Amount: money.MustParse("1"),
+					Reference: "two\nlines",
+				},
+				{Line: 4, ToAccountNumber: "100000000287", Amount: money.MustParse("2")},
+			},
+		},
+		{
+			name:       "empty file",
+			file:       "",
+			wantErrKey: "file",
+		},
+		{
+			name:       "unknown column",
+			file:       "to_account,amount,iban\n100000000190,1,x\n",
+			wantErrKey: "file",
+		},
+		{
+			name:       "column given twice",
+			file:       "to_account,amount,amount\n100000000190,1,2\n",
+			wantErrKey: "file",
+		},
+		{
+			name:       "no amount column",
+			file:       "to_account,reference\n100000000190,x\n",
+			wantErrKey: "file",
+		},
+		{
+			name:       "no recipient column",
+			file:       "amount,reference\n1,x\n",
+			wantErrKey: "file",
+		},
+		{
+			name:       "row with too few fields",
+			file:       "to_account,amount\n100000000190\n",
+			wantErrKey: "file",
+		},
+		{
+			name:       "bad amount",
+			file:       "to_account,amount\n100000000190,1\n100000000287,ten\n",
+			wantErrKey: "line 3",
+		},
+		{
+			name:       "too many rows",
+			file:       "to_account,amount\n" + strings.Repeat("100000000190,1\n", maxRows+1),
+			wantErrKey: "file",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v := validator.New()
+			gotRows := ParseCSV(v, strings.NewReader(tc.file))
+
+			if tc.wantErrKey != "" {
+				if _, ok := v.Errors[tc.wantErrKey]; !ok {
+					t.Fatalf("expected a %q error, got %v", tc.wantErrKey, v.Errors)
+				}
+				return
+			}
+			if !v.IsValid() {
+				t.Fatalf("unexpected errors %v", v.Errors)
+			}
+
+			if len(gotRows) != len(tc.wantRows) {
+				t.Fatalf("expected %d rows, got %d", len(tc.wantRows), len(gotRows))
+			}
+			for i, want := range tc.wantRows {
+				want.Status = RowPending
+				if *gotRows[i] != want {
+					t.Errorf("row %d: expected %+v, got %+v", i, want, *gotRows[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package batch
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+const (
+	// either every transfer of the batch is made or none is
+	ModeAllOrNothing = "ALL_OR_NOTHING"
+	// each transfer is made on its own, the ones that fail don't stop the others
+	ModeBestEffort = "BEST_EFFORT"
+)
+
+const (
+	StatusPending            = "PENDING"
+	StatusProcessing         = "PROCESSING"
+	StatusCompleted          = "COMPLETED"
+	StatusPartiallyCompleted = "PARTIALLY_COMPLETED"
+	StatusFailed             = "FAILED"
+)
+
+const (
+	RowPending   = "PENDING"
+	RowSucceeded = "SUCCEEDED"
+	RowFailed    = "FAILED"
+	// not made because the all or nothing batch it is in failed
+	RowSkipped = "SKIPPED"
+)
+
+const maxRows = 1000
+
+// Batch is a file of transfers a user uploaded to be made from one of their accounts, in its
+// currency. the scheduler makes them in the batch's Mode, and what happened to each is on its row.
+// Error is why an all or nothing batch failed as a whole
+type Batch struct {
+	ID                int64        `json:"id"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UserID            int64        `json:"user_id"`
+	FromAccountID     int64        `json:"-"`
+	FromAccountNumber string       `json:"from_account"`
+	Currency          string       `json:"currency"`
+	Mode              string       `json:"mode"`
+	Total             money.Amount `json:"total"`
+	RowCount          int          `json:"row_count"`
+	Status            string       `json:"status"`
+	Error             string       `json:"error,omitempty"`
+	StartedAt         *time.Time   `json:"started_at"`
+	FinishedAt        *time.Time   `json:"finished_at"`
+	Version           int32        `json:"version"`
+	Rows              []*Row       `json:"rows,omitempty"`
+}
+
+// Row is one transfer of a batch, to the recipient named in the file by account number or email.
+// Line is where it is in the file, the header being line 1. TransferID is only set once it is made
+type Row struct {
+	ID              int64        `json:"-"`
+	BatchID         int64        `json:"-"`
+	Line            int          `json:"line"`
+	ToAccountNumber string       `json:"to_account,omitempty"`
+	ToUserEmail     string       `json:"to_email,omitempty"`
+	Amount          money.Amount `json:"amount"`
+	Reference       string       `json:"reference,omitempty"`
+	Status          string       `json:"status"`
+	Error           string       `json:"error,omitempty"`
+	TransferID      *int64       `json:"transfer_id"`
+}
+
+// key is what the errors of the row are added under
+func (r *Row) key() string {
+	return "line " + strconv.Itoa(r.Line)
+}
+
+// finish sets the status of the batch from what happened to its rows
+func (b *Batch) finish(now time.Time) {
+	succeeded := 0
+	for _, row := range b.Rows {
+		if row.Status == RowSucceeded {
+			succeeded++
+		}
+	}
+
+	switch succeeded {
+	case len(b.Rows):
+		b.Status = StatusCompleted
+	case 0:
+		b.Status = StatusFailed
+	default:
+		b.Status = StatusPartiallyCompleted
+	}
+	b.FinishedAt = &now
+}
+
+func ValidateBatch(v *validator.Validator, b *Batch) {
+	v.CheckAddError(
+		validator.ValueInList(b.Mode, ModeAllOrNothing, ModeBestEffort), "mode",
+		"must be ALL_OR_NOTHING or BEST_EFFORT",
+	)
+	v.CheckAddError(len(b.Rows) > 0, "file", "must have at least one row")
+	v.CheckAddError(len(b.Rows) <= maxRows, "file", "must not have more than 1000 rows")
+}
+
+func ValidateRow(v *validator.Validator, row *Row) {
+	v.CheckAddError(
+		row.ToAccountNumber != "" || row.ToUserEmail != "", "to account", "must be given",
+	)
+	v.CheckAddError(
+		row.ToAccountNumber == "" || row.ToUserEmail == "", "to account",
+		"give to_account or to_email, not both",
+	)
+}
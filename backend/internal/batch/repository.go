@@ -0,0 +1,271 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+var ErrEditConflict = errors.New("edit conflict")
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// the batches are always read with the number and currency of the account they are made from
+const selectBatches = `
+	SELECT transfer_batches.id, transfer_batches.created_at, transfer_batches.user_id,
+		from_account_id, accounts.number, accounts.currency, mode, total, row_count,
+		transfer_batches.status, error, started_at, finished_at, transfer_batches.version
+	FROM transfer_batches
+	INNER JOIN accounts ON accounts.id = transfer_batches.from_account_id
+`
+
+const selectRows = `
+	SELECT id, batch_id, line, to_account_number, to_email, amount, reference, status, error,
+		transfer_id
+	FROM transfer_batch_rows
+`
+
+// InsertTx saves the batch and all its rows in one database transaction
+func (r *Repository) InsertTx(b *Batch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// in case of any issues
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO transfer_batches (user_id, from_account_id, mode, total, row_count, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, version
+	`
+	err = tx.QueryRowContext(
+		ctx, query, b.UserID, b.FromAccountID, b.Mode, b.Total, b.RowCount, b.Status,
+	).Scan(&b.ID, &b.CreatedAt, &b.Version)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO transfer_batch_rows
+			(batch_id, line, to_account_number, to_email, amount, reference, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range b.Rows {
+		row.BatchID = b.ID
+		err = stmt.QueryRowContext(
+			ctx, row.BatchID, row.Line, row.ToAccountNumber, row.ToUserEmail, row.Amount,
+			row.Reference, row.Status,
+		).Scan(&row.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Get gets the batch with its rows, if it belongs to the user
+func (r *Repository) Get(batchID, userID int64) (*Batch, error) {
+	query := selectBatches + `
+		WHERE transfer_batches.id = $1 AND transfer_batches.user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	b, err := scanBatch(r.DB.QueryRowContext(ctx, query, batchID, userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	b.Rows, err = r.GetRows(b.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// GetAllUserBatches gets the user's batches without their rows, the newest first
+func (r *Repository) GetAllUserBatches(userID int64) ([]*Batch, error) {
+	query := selectBatches + `
+		WHERE transfer_batches.user_id = $1
+		ORDER BY transfer_batches.id DESC
+	`
+
+	return r.query(query, userID)
+}
+
+// GetPending gets up to limit batches waiting to be run, without their rows, the oldest first
+func (r *Repository) GetPending(limit int) ([]*Batch, error) {
+	query := selectBatches + `
+		WHERE transfer_batches.status = 'PENDING'
+		ORDER BY transfer_batches.created_at, transfer_batches.id
+		LIMIT $1
+	`
+
+	return r.query(query, limit)
+}
+
+// GetRows gets the rows of the batch in the order they are in the file
+func (r *Repository) GetRows(batchID int64) ([]*Row, error) {
+	query := selectRows + `
+		WHERE batch_id = $1
+		ORDER BY line
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	results, err := r.DB.QueryContext(ctx, query, batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var rows []*Row
+	for results.Next() {
+		row := &Row{}
+		err := results.Scan(
+			&row.ID,
+			&row.BatchID,
+			&row.Line,
+			&row.ToAccountNumber,
+			&row.ToUserEmail,
+			&row.Amount,
+			&row.Reference,
+			&row.Status,
+			&row.Error,
+			&row.TransferID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// Update saves the status of the batch, as long as no one else has changed it since it was read
+func (r *Repository) Update(b *Batch) error {
+	query := `
+		UPDATE transfer_batches
+		SET status = $1, error = $2, started_at = $3, finished_at = $4, version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING version
+	`
+	args := []any{
+		b.Status,
+		b.Error,
+		b.StartedAt,
+		b.FinishedAt,
+		b.ID,
+		b.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(ctx, query, args...).Scan(&b.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateRow saves what happened to the row
+func (r *Repository) UpdateRow(row *Row) error {
+	query := `
+		UPDATE transfer_batch_rows
+		SET status = $1, error = $2, transfer_id = $3
+		WHERE id = $4
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, row.Status, row.Error, row.TransferID, row.ID)
+	return err
+}
+
+func (r *Repository) query(query string, args ...any) ([]*Batch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []*Batch
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return batches, nil
+}
+
+// scanBatch scans a row of selectBatches, from either a *sql.Row or *sql.Rows
+func scanBatch(row interface{ Scan(dest ...any) error }) (*Batch, error) {
+	b := &Batch{}
+	err := row.Scan(
+		&b.ID,
+		&b.CreatedAt,
+		&b.UserID,
+		&b.FromAccountID,
+		&b.FromAccountNumber,
+		&b.Currency,
+		&b.Mode,
+		&b.Total,
+		&b.RowCount,
+		&b.Status,
+		&b.Error,
+		&b.StartedAt,
+		&b.FinishedAt,
+		&b.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -0,0 +1,325 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// how many pending batches are picked up at a time
+const pendingBatchSize = 10
+
+// what a row or batch that failed for a reason of ours says, the error itself is logged
+const errProcessing = "could not be processed"
+
+type Repo interface {
+	InsertTx(b *Batch) error
+	Get(batchID, userID int64) (*Batch, error)
+	GetAllUserBatches(userID int64) ([]*Batch, error)
+	GetPending(limit int) ([]*Batch, error)
+	GetRows(batchID int64) ([]*Row, error)
+	Update(b *Batch) error
+	UpdateRow(row *Row) error
+}
+
+type UserService interface {
+	GetUser(userID int64) (*user.User, error)
+}
+
+type AccountService interface {
+	GetUserAccount(userID int64, number string) (*account.Account, error)
+}
+
+type TransferService interface {
+	TransferMoney(
+		v *validator.Validator, fromUser *user.User, fromAccountNumber, toAccountNumber,
+		toUserEmail string, amount money.Amount, memo, reference string,
+	) (*transfer.Transfer, *user.User, error)
+	TransferAll(
+		v *validator.Validator, fromUser *user.User, fromAccountNumber string,
+		payments []*transfer.Payment,
+	) error
+	RecipientAccount(
+		v *validator.Validator, toAccountNumber, toUserEmail string,
+	) (*account.Account, error)
+}
+
+type Service struct {
+	Repo            Repo
+	UserService     UserService
+	AccountService  AccountService
+	TransferService TransferService
+}
+
+// Upload checks every row of the file as a transfer from one of the user's accounts, their primary
+// account when no number is given, and that the account has enough for the total. the batch is only
+// saved if all of it is fine, and is left for the scheduler to run
+func (s *Service) Upload(
+	v *validator.Validator, u *user.User, fromAccountNumber, mode string, file io.Reader,
+) (*Batch, error) {
+	fromAccount, err := s.AccountService.GetUserAccount(u.ID, fromAccountNumber)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("from account", "not found")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	v.CheckAddError(fromAccount.IsActive(), "from account", "is not active")
+	if !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	b := &Batch{
+		UserID:            u.ID,
+		FromAccountID:     fromAccount.ID,
+		FromAccountNumber: fromAccount.Number,
+		Currency:          fromAccount.Currency,
+		Mode:              mode,
+		Status:            StatusPending,
+		Rows:              ParseCSV(v, file),
+	}
+	// the rows that can't be read are reported along with the rest, but nothing else can be checked
+	// without the file or the mode
+	ValidateBatch(v, b)
+	if v.Errors["file"] != "" || v.Errors["mode"] != "" {
+		return nil, validator.ErrFailedValidation
+	}
+
+	for _, row := range b.Rows {
+		if _, bad := v.Errors[row.key()]; bad {
+			continue
+		}
+
+		rv := validator.New()
+		err = s.checkRow(rv, fromAccount, row)
+		if err != nil {
+			if errors.Is(err, validator.ErrFailedValidation) {
+				v.AddError(row.key(), validator.Reasons(rv.Errors))
+				continue
+			}
+			return nil, err
+		}
+		b.Total += row.Amount
+	}
+	if !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	v.CheckAddError(
+		b.Total <= fromAccount.Spendable(), "account balance",
+		"insufficient funds for the total of "+b.Total.String(),
+	)
+	if !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	b.RowCount = len(b.Rows)
+	err = s.Repo.InsertTx(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// checkRow checks the row could be sent from the account as things are now. they are checked again
+// when the transfer is made
+func (s *Service) checkRow(v *validator.Validator, fromAccount *account.Account, row *Row) error {
+	if ValidateRow(v, row); !v.IsValid() {
+		return validator.ErrFailedValidation
+	}
+
+	toAccount, err := s.TransferService.RecipientAccount(v, row.ToAccountNumber, row.ToUserEmail)
+	if err != nil {
+		return err
+	}
+
+	tr := &transfer.Transfer{
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        row.Amount,
+		Reference:     row.Reference,
+	}
+	if transfer.ValidateTransfer(v, tr, fromAccount, toAccount); !v.IsValid() {
+		return validator.ErrFailedValidation
+	}
+
+	return nil
+}
+
+// ProcessPending runs the batches that are waiting to be run and returns how many were. the errors
+// of single batches do not stop the others from being run, they are returned together at the end
+func (s *Service) ProcessPending(now time.Time) (int, error) {
+	processed := 0
+	var errs []error
+	for {
+		batches, err := s.Repo.GetPending(pendingBatchSize)
+		if err != nil {
+			return processed, errors.Join(append(errs, err)...)
+		}
+
+		ranInBatch := 0
+		for _, b := range batches {
+			ran, err := s.process(b, now)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("transfer batch %d: %w", b.ID, err))
+			}
+			if ran {
+				ranInBatch++
+			}
+		}
+		processed += ranInBatch
+
+		// the batches that ran are no longer pending, so a full lot means there may be more
+		if len(batches) < pendingBatchSize || ranInBatch == 0 {
+			return processed, errors.Join(errs...)
+		}
+	}
+}
+
+// process claims the batch by marking it processing before any transfer is made, so that two
+// schedulers never run it twice. a batch left processing by a crash is not picked up again, the
+// transfers made before it stopped can be seen on its rows
+func (s *Service) process(b *Batch, now time.Time) (bool, error) {
+	b.Status = StatusProcessing
+	b.StartedAt = &now
+	err := s.Repo.Update(b)
+	if err != nil {
+		// picked up by someone else since it was read
+		if errors.Is(err, ErrEditConflict) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	b.Rows, err = s.Repo.GetRows(b.ID)
+	if err != nil {
+		return true, err
+	}
+
+	var runErr error
+	u, err := s.UserService.GetUser(b.UserID)
+	switch {
+	case err != nil:
+		b.Error = errProcessing
+		for _, row := range b.Rows {
+			row.Status = RowSkipped
+		}
+		runErr = errors.Join(err, s.saveRows(b.Rows))
+	case b.Mode == ModeAllOrNothing:
+		runErr = s.runAll(u, b)
+	default:
+		runErr = s.runEach(u, b)
+	}
+
+	b.finish(time.Now())
+	err = s.Repo.Update(b)
+	if err != nil {
+		return true, errors.Join(runErr, err)
+	}
+
+	return true, runErr
+}
+
+// runAll makes all the transfers of the batch together, or none of them. when it is refused, the
+// rows that were refused say why, the others are skipped
+func (s *Service) runAll(u *user.User, b *Batch) error {
+	payments := make([]*transfer.Payment, len(b.Rows))
+	for i, row := range b.Rows {
+		payments[i] = &transfer.Payment{
+			ToAccountNumber: row.ToAccountNumber,
+			ToUserEmail:     row.ToUserEmail,
+			Amount:          row.Amount,
+			Reference:       row.Reference,
+		}
+	}
+
+	v := validator.New()
+	err := s.TransferService.TransferAll(v, u, b.FromAccountNumber, payments)
+	if err != nil {
+		refused := errors.Is(err, validator.ErrFailedValidation)
+		for i, row := range b.Rows {
+			row.Status = RowSkipped
+			if refused && payments[i].Errors != nil {
+				row.Status = RowFailed
+				row.Error = validator.Reasons(payments[i].Errors)
+			}
+		}
+
+		switch {
+		case !refused:
+			b.Error = errProcessing
+			return errors.Join(err, s.saveRows(b.Rows))
+		case !v.IsValid():
+			b.Error = validator.Reasons(v.Errors)
+		default:
+			b.Error = "some of the rows were refused, none were made"
+		}
+		return s.saveRows(b.Rows)
+	}
+
+	for i, row := range b.Rows {
+		row.Status = RowSucceeded
+		row.TransferID = &payments[i].Transfer.ID
+	}
+	return s.saveRows(b.Rows)
+}
+
+// runEach makes the transfers of the batch one at a time, saving each row once it is made. a row
+// that is refused, like one the account no longer has enough for, doesn't stop the others
+func (s *Service) runEach(u *user.User, b *Batch) error {
+	var errs []error
+	for _, row := range b.Rows {
+		v := validator.New()
+		tr, _, err := s.TransferService.TransferMoney(
+			v, u, b.FromAccountNumber, row.ToAccountNumber, row.ToUserEmail, row.Amount, "",
+			row.Reference,
+		)
+		switch {
+		case errors.Is(err, validator.ErrFailedValidation):
+			row.Status = RowFailed
+			row.Error = validator.Reasons(v.Errors)
+		case err != nil:
+			row.Status = RowFailed
+			row.Error = errProcessing
+			errs = append(errs, fmt.Errorf("line %d: %w", row.Line, err))
+		default:
+			row.Status = RowSucceeded
+			row.TransferID = &tr.ID
+		}
+
+		err = s.Repo.UpdateRow(row)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", row.Line, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Service) saveRows(rows []*Row) error {
+	for _, row := range rows {
+		err := s.Repo.UpdateRow(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) GetBatch(batchID, userID int64) (*Batch, error) {
+	return s.Repo.Get(batchID, userID)
+}
+
+func (s *Service) GetAllUserBatches(userID int64) ([]*Batch, error) {
+	return s.Repo.GetAllUserBatches(userID)
+}
@@ -0,0 +1,525 @@
+package batch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// ---MOCKS---
+
+// MockRepo keeps the batches and their rows in memory
+type MockRepo struct {
+	Batches   []*Batch
+	InsertErr error
+}
+
+func (r *MockRepo) InsertTx(b *Batch) error {
+	if r.InsertErr != nil {
+		return r.InsertErr
+	}
+	b.ID = int64(len(r.Batches) + 1)
+	b.Version = 1
+	for i, row := range b.Rows {
+		row.ID = b.ID*10000 + int64(i)
+		row.BatchID = b.ID
+	}
+	r.Batches = append(r.Batches, b)
+	return nil
+}
+
+func (r *MockRepo) Get(batchID, userID int64) (*Batch, error) {
+	for _, b := range r.Batches {
+		if b.ID == batchID && b.UserID == userID {
+			return b, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (r *MockRepo) GetAllUserBatches(userID int64) ([]*Batch, error) {
+	return nil, nil
+}
+
+// GetPending gets copies of the pending batches without their rows, like the real one
+func (r *MockRepo) GetPending(limit int) ([]*Batch, error) {
+	var batches []*Batch
+	for _, b := range r.Batches {
+		if b.Status == StatusPending && len(batches) < limit {
+			copied := *b
+			copied.Rows = nil
+			batches = append(batches, &copied)
+		}
+	}
+	return batches, nil
+}
+
+func (r *MockRepo) GetRows(batchID int64) ([]*Row, error) {
+	var rows []*Row
+	for _, b := range r.Batches {
+		if b.ID == batchID {
+			for _, row := range b.Rows {
+				copied := *row
+				rows = append(rows, &copied)
+			}
+		}
+	}
+	return rows, nil
+}
+
+func (r *MockRepo) Update(b *Batch) error {
+	for _, saved := range r.Batches {
+		if saved.ID == b.ID {
+			if saved.Version != b.Version {
+				return ErrEditConflict
+			}
+			b.Version++
+			saved.Status, saved.Error = b.Status, b.Error
+			saved.StartedAt, saved.FinishedAt = b.StartedAt, b.FinishedAt
+			saved.Version = b.Version
+			return nil
+		}
+	}
+	return ErrEditConflict
+}
+
+func (r *MockRepo) UpdateRow(row *Row) error {
+	for _, b := range r.Batches {
+		for i, saved := range b.Rows {
+			if saved.ID == row.ID {
+				copied := *row
+				b.Rows[i] = &copied
+			}
+		}
+	}
+	return nil
+}
+
+type MockUserService struct {
+	Err error
+}
+
+func (us *MockUserService) GetUser(userID int64) (*user.User, error) {
+	if us.Err != nil {
+		return nil, us.Err
+	}
+	return &user.User{ID: userID}, nil
+}
+
+// MockAccountService looks the accounts it holds up by number or by their user, the first account
+// of a user being their primary account
+type MockAccountService struct {
+	Accounts []*account.Account
+}
+
+func (as *MockAccountService) GetUserAccount(userID int64, number string) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.UserID == userID && (number == "" || a.Number == number) {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+// MockTransferService finds the recipients by account number and makes the transfers in memory.
+// the transfers to the numbers in Refused are refused the way the real ones are, and Err fails all
+type MockTransferService struct {
+	Accounts []*account.Account
+	Refused  map[string]bool
+	Err      error
+	Made     []*transfer.Transfer
+}
+
+func (ts *MockTransferService) RecipientAccount(
+	v *validator.Validator, toAccountNumber, toUserEmail string,
+) (*account.Account, error) {
+	for _, a := range ts.Accounts {
+		if a.Number == toAccountNumber {
+			return a, nil
+		}
+	}
+	v.AddError("to account", "not found")
+	return nil, validator.ErrFailedValidation
+}
+
+func (ts *MockTransferService) TransferMoney(
+	v *validator.Validator, fromUser *user.User, fromAccountNumber, toAccountNumber,
+	toUserEmail string, amount money.Amount, memo, reference string,
+) (*transfer.Transfer, *user.User, error) {
+	if ts.Err != nil {
+		return nil, nil, ts.Err
+	}
+	if ts.Refused[toAccountNumber] {
+		v.AddError("to account", "is closed")
+		return nil, nil, validator.ErrFailedValidation
+	}
+	return ts.make(amount), fromUser, nil
+}
+
+func (ts *MockTransferService) TransferAll(
+	v *validator.Validator, fromUser *user.User, fromAccountNumber string,
+	payments []*transfer.Payment,
+) error {
+	if ts.Err != nil {
+		return ts.Err
+	}
+	refused := false
+	for _, p := range payments {
+		if ts.Refused[p.ToAccountNumber] {
+			p.Errors = map[string]string{"to account": "is closed"}
+			refused = true
+		}
+	}
+	if refused {
+		return validator.ErrFailedValidation
+	}
+	for _, p := range payments {
+		p.Transfer = ts.make(p.Amount)
+	}
+	return nil
+}
+
+func (ts *MockTransferService) make(amount money.Amount) *transfer.Transfer {
+	tr := &transfer.Transfer{ID: int64(len(ts.Made) + 1), Amount: amount}
+	ts.Made = append(ts.Made, tr)
+	return tr
+}
+
+var payer = &user.User{ID: 1}
+
+func newAccounts() []*account.Account {
+	return []*account.Account{
+		{
+			ID: 1, UserID: 1, Number: "100000000093", Status: account.StatusActive,
+			Currency: "USD", Balance: money.MustParse("100"),
+			AvailableBalance: money.MustParse("100"),
+		},
+		{
+			ID: 2, UserID: 2, Number: "100000000190", Status: account.StatusActive,
+			Currency: "USD",
+		},
+		{
+			ID: 3, UserID: 3, Number: "100000000287", Status: account.StatusActive,
+			Currency: "USD",
+		},
+	}
+}
+
+func newService(repo *MockRepo) (Service, *MockAccountService, *MockTransferService) {
+	accounts := &MockAccountService{Accounts: newAccounts()}
+	transfers := &MockTransferService{Accounts: accounts.Accounts}
+	return Service{
+		Repo:            repo,
+		UserService:     &MockUserService{},
+		AccountService:  accounts,
+		TransferService: transfers,
+	}, accounts, transfers
+}
+
+func TestUpload(t *testing.T) {
+	errDB := errors.New("db error")
+
+	tests := []struct {
+		name          string
+		setupAccounts func([]*account.Account)
+		insertErr     error
+		fromAccount   string
+		mode          string
+		file          string
+		wantTotal     money.Amount
+		wantRows      int
+		expectedErr   error
+		wantErrKeys   []string
+	}{
+		{
+			name:      "valid batch",
+			mode:      ModeAllOrNothing,
+			file:      "to_account,amount,reference\n100000000190,10,a\n100000000287,20.50,b\n",
+			wantTotal: money.MustParse("30.50"),
+			wantRows:  2,
+		},
+		{
+			name:        "named from account",
+			fromAccount: "100000000093",
+			mode:        ModeBestEffort,
+			file:        "to_account,amount\n100000000190,100\n",
+			wantTotal:   money.MustParse("100"),
+			wantRows:    1,
+		},
+		{
+			name:        "from account not found",
+			fromAccount: "100000000190",
+			mode:        ModeAllOrNothing,
+			file:        "to_account,amount\n100000000287,10\n",
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKeys: []string{"from account"},
+		},
+		{
+			name: "frozen from account",
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[0].Status = account.StatusFrozen
+			},
+			mode:        ModeAllOrNothing,
+			file:        "to_account,amount\n100000000190,10\n",
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKeys: []string{"from account"},
+		},
+		{
+			name:        "no mode",
+			file:        "to_account,amount\n100000000190,10\n",
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKeys: []string{"mode"},
+		},
+		{
+			name:        "no rows",
+			mode:        ModeAllOrNothing,
+			file:        "to_account,amount\n",
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKeys: []string{"file"},
+		},
+		{
+			name: "every bad row is reported",
+			mode: ModeAllOrNothing,
+			file: "to_account,to_email,amount\n" +
+				"100000000190,,ten\n" +
+				"100000009987,,10\n" +
+				"100000000287,a@b.com,10\n" +
+				"100000000093,,10\n" +
+				"100000000190,,0\n" +
+				"100000000287,,10\n",
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKeys: []string{"line 2", "line 3", "line 4", "line 5", "line 6"},
+		},
+		{
+			name: "closed recipient",
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[2].Status = account.StatusClosed
+			},
+			mode:        ModeBestEffort,
+			file:        "to_account,amount\n100000000190,10\n100000000287,10\n",
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKeys: []string{"line 3"},
+		},
+		{
+			name:        "total more than the balance",
+			mode:        ModeAllOrNothing,
+			file:        "to_account,amount\n100000000190,60\n100000000287,60\n",
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKeys: []string{"account balance"},
+		},
+		{
+			name:        "InsertTx failure",
+			insertErr:   errDB,
+			mode:        ModeAllOrNothing,
+			file:        "to_account,amount\n100000000190,10\n",
+			expectedErr: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{InsertErr: tc.insertErr}
+			svc, accounts, _ := newService(repo)
+			if tc.setupAccounts != nil {
+				tc.setupAccounts(accounts.Accounts)
+			}
+
+			v := validator.New()
+			gotBatch, gotErr := svc.Upload(
+				v, payer, tc.fromAccount, tc.mode, strings.NewReader(tc.file),
+			)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			for _, key := range tc.wantErrKeys {
+				if _, ok := v.Errors[key]; !ok {
+					t.Errorf("expected a %q error, got %v", key, v.Errors)
+				}
+			}
+			if len(v.Errors) > len(tc.wantErrKeys) {
+				t.Errorf("unexpected errors %v", v.Errors)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if gotBatch.Total != tc.wantTotal || gotBatch.RowCount != tc.wantRows {
+				t.Errorf(
+					"expected a total of %v in %d rows, got %v in %d", tc.wantTotal, tc.wantRows,
+					gotBatch.Total, gotBatch.RowCount,
+				)
+			}
+			if gotBatch.Status != StatusPending || len(repo.Batches) != 1 {
+				t.Errorf("expected the batch saved pending, got %+v", gotBatch)
+			}
+		})
+	}
+}
+
+func TestProcessPending(t *testing.T) {
+	errDB := errors.New("db error")
+	now := time.Now()
+
+	newBatch := func(mode string) *Batch {
+		return &Batch{
+			UserID: 1, FromAccountID: 1, FromAccountNumber: "100000000093", Currency: "USD",
+			Mode: mode, Status: StatusPending, RowCount: 2, Total: money.MustParse("30"),
+			Rows: []*Row{
+				{
+					Line: 2, ToAccountNumber: "100000000190", Amount: money.MustParse("10"),
+					Status: RowPending,
+				},
+				{
+					Line: 3, ToAccountNumber: "100000000287", Amount: money.MustParse("20"),
+					Status: RowPending,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		batches       []*Batch
+		setupTransfer func(*MockTransferService)
+		userErr       error
+		wantProcessed int
+		wantStatus    string
+		wantRows      []string
+		wantMade      int
+		wantBatchErr  bool
+		expectedErr   error
+	}{
+		{
+			name:          "all or nothing",
+			batches:       []*Batch{newBatch(ModeAllOrNothing)},
+			wantProcessed: 1,
+			wantStatus:    StatusCompleted,
+			wantRows:      []string{RowSucceeded, RowSucceeded},
+			wantMade:      2,
+		},
+		{
+			name:    "all or nothing with a refused row",
+			batches: []*Batch{newBatch(ModeAllOrNothing)},
+			setupTransfer: func(ts *MockTransferService) {
+				ts.Refused = map[string]bool{"100000000287": true}
+			},
+			wantProcessed: 1,
+			wantStatus:    StatusFailed,
+			wantRows:      []string{RowSkipped, RowFailed},
+			wantBatchErr:  true,
+		},
+		{
+			name:          "best effort",
+			batches:       []*Batch{newBatch(ModeBestEffort)},
+			wantProcessed: 1,
+			wantStatus:    StatusCompleted,
+			wantRows:      []string{RowSucceeded, RowSucceeded},
+			wantMade:      2,
+		},
+		{
+			name:    "best effort with a refused row",
+			batches: []*Batch{newBatch(ModeBestEffort)},
+			setupTransfer: func(ts *MockTransferService) {
+				ts.Refused = map[string]bool{"100000000287": true}
+			},
+			wantProcessed: 1,
+			wantStatus:    StatusPartiallyCompleted,
+			wantRows:      []string{RowSucceeded, RowFailed},
+			wantMade:      1,
+		},
+		{
+			name:    "best effort with every row refused",
+			batches: []*Batch{newBatch(ModeBestEffort)},
+			setupTransfer: func(ts *MockTransferService) {
+				ts.Refused = map[string]bool{"100000000190": true, "100000000287": true}
+			},
+			wantProcessed: 1,
+			wantStatus:    StatusFailed,
+			wantRows:      []string{RowFailed, RowFailed},
+		},
+		{
+			name:    "transfer failure",
+			batches: []*Batch{newBatch(ModeAllOrNothing)},
+			setupTransfer: func(ts *MockTransferService) {
+				ts.Err = errDB
+			},
+			wantProcessed: 1,
+			wantStatus:    StatusFailed,
+			wantRows:      []string{RowSkipped, RowSkipped},
+			wantBatchErr:  true,
+			expectedErr:   errDB,
+		},
+		{
+			name:          "user not found",
+			batches:       []*Batch{newBatch(ModeBestEffort)},
+			userErr:       errDB,
+			wantProcessed: 1,
+			wantStatus:    StatusFailed,
+			wantRows:      []string{RowSkipped, RowSkipped},
+			wantBatchErr:  true,
+			expectedErr:   errDB,
+		},
+		{
+			name: "already picked up",
+			batches: func() []*Batch {
+				b := newBatch(ModeAllOrNothing)
+				b.Status = StatusProcessing
+				return []*Batch{b}
+			}(),
+			wantStatus: StatusProcessing,
+			wantRows:   []string{RowPending, RowPending},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			for _, b := range tc.batches {
+				_ = repo.InsertTx(b)
+			}
+			svc, _, transfers := newService(repo)
+			svc.UserService = &MockUserService{Err: tc.userErr}
+			if tc.setupTransfer != nil {
+				tc.setupTransfer(transfers)
+			}
+
+			gotProcessed, gotErr := svc.ProcessPending(now)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotProcessed != tc.wantProcessed {
+				t.Errorf("expected %d processed, got %d", tc.wantProcessed, gotProcessed)
+			}
+			if len(transfers.Made) != tc.wantMade {
+				t.Errorf("expected %d transfers made, got %d", tc.wantMade, len(transfers.Made))
+			}
+
+			b := repo.Batches[0]
+			if b.Status != tc.wantStatus {
+				t.Errorf("expected status %s, got %s", tc.wantStatus, b.Status)
+			}
+			if (b.Error != "") != tc.wantBatchErr {
+				t.Errorf("unexpected batch error %q", b.Error)
+			}
+			for i, want := range tc.wantRows {
+				row := b.Rows[i]
+				if row.Status != want {
+					t.Errorf("row %d: expected status %s, got %s", i, want, row.Status)
+				}
+				if (row.TransferID != nil) != (want == RowSucceeded) {
+					t.Errorf("row %d: unexpected transfer %v", i, row.TransferID)
+				}
+				if (row.Error != "") != (want == RowFailed) {
+					t.Errorf("row %d: unexpected error %q", i, row.Error)
+				}
+			}
+		})
+	}
+}
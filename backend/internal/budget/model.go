@@ -0,0 +1,89 @@
+package budget
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// the longest period the insights can be asked for at once
+const maxInsightMonths = 24
+
+// Budget is how much the user means to spend a month on one of their categories. spending is never
+// converted, so it only counts what was spent in Currency and a user spending in two currencies
+// needs a budget in each. Alert has the user emailed the first time they go over it in a month
+type Budget struct {
+	ID         int64        `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	UserID     int64        `json:"user_id"`
+	CategoryID int64        `json:"-"`
+	Category   string       `json:"category"`
+	Amount     money.Amount `json:"amount"`
+	Currency   string       `json:"currency"`
+	Alert      bool         `json:"alert"`
+}
+
+// Spending is what the user spent in a month on a category, in one currency, split by where it
+// went. the money the user moves between their own accounts is not spending. Category is empty for
+// what is not filed under one, which loan payments never can be
+type Spending struct {
+	Month        string       `json:"month"`
+	Category     string       `json:"category"`
+	Currency     string       `json:"currency"`
+	Transfers    money.Amount `json:"transfers"`
+	Withdrawals  money.Amount `json:"withdrawals"`
+	LoanPayments money.Amount `json:"loan_payments"`
+	Total        money.Amount `json:"total"`
+}
+
+// Progress is how much of a budget has been spent in the month
+type Progress struct {
+	Budget    *Budget      `json:"budget"`
+	Month     string       `json:"month"`
+	Spent     money.Amount `json:"spent"`
+	Remaining money.Amount `json:"remaining"`
+	Exceeded  bool         `json:"exceeded"`
+}
+
+func newProgress(budget *Budget, month time.Time, spent money.Amount) *Progress {
+	return &Progress{
+		Budget:    budget,
+		Month:     month.Format(monthLayout),
+		Spent:     spent,
+		Remaining: money.Max(0, budget.Amount-spent),
+		Exceeded:  spent > budget.Amount,
+	}
+}
+
+// Alert is a budget that may need its alert sent, with the user it is for
+type Alert struct {
+	Budget *Budget
+	Name   string
+	Email  string
+}
+
+func ValidateBudget(v *validator.Validator, budget *Budget) {
+	v.CheckAddError(budget.Amount != 0, "amount", "must be given")
+	v.CheckAddError(budget.Amount > 0, "amount", "must be more than 0")
+	v.CheckAddError(money.ValidCurrency(budget.Currency), "currency", "invalid")
+}
+
+// ValidatePeriod checks the months the insights are asked for, from and to both included
+func ValidatePeriod(v *validator.Validator, from, to time.Time) {
+	v.CheckAddError(!to.Before(from), "to", "must not be before from")
+	v.CheckAddError(
+		to.Before(from.AddDate(0, maxInsightMonths, 0)), "period",
+		"must not be more than 24 months long",
+	)
+}
+
+// months are given and shown like "2006-01", in UTC
+const monthLayout = "2006-01"
+
+// month is the start of the month the time is in, in UTC
+func month(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,286 @@
+package budget
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// the budgets are always read with the name of their category
+const selectBudgets = `
+	SELECT budgets.id, budgets.created_at, budgets.updated_at, budgets.user_id,
+		budgets.category_id, categories.name, budgets.amount, budgets.currency, budgets.alert
+	FROM budgets
+	INNER JOIN categories ON categories.id = budgets.category_id
+`
+
+// Save sets the budget of the category in the currency, replacing the one it had before
+func (r *Repository) Save(budget *Budget) error {
+	query := `
+		INSERT INTO budgets (user_id, category_id, amount, currency, alert)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (category_id, currency) DO UPDATE
+		SET amount = EXCLUDED.amount, alert = EXCLUDED.alert, updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`
+	args := []any{budget.UserID, budget.CategoryID, budget.Amount, budget.Currency, budget.Alert}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.DB.QueryRowContext(ctx, query, args...).Scan(
+		&budget.ID,
+		&budget.CreatedAt,
+		&budget.UpdatedAt,
+	)
+}
+
+func (r *Repository) GetAllUserBudgets(userID int64) ([]*Budget, error) {
+	query := selectBudgets + `
+		WHERE budgets.user_id = $1
+		ORDER BY categories.name, budgets.currency
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []*Budget
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
+func (r *Repository) Delete(budgetID, userID int64) error {
+	query := `
+		DELETE FROM budgets
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, budgetID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return user.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Spending sums what the user spent from the start of from to the end of to by month, category
+// and currency. like the limits, refunds and reversals only send money back so they don't count
+func (r *Repository) Spending(userID int64, from, to time.Time) ([]*Spending, error) {
+	query := `
+		SELECT date_trunc('month', created_at AT TIME ZONE 'UTC'), COALESCE(category, ''),
+			currency,
+			COALESCE(SUM(amount) FILTER (WHERE kind = 'transfer'), 0),
+			COALESCE(SUM(amount) FILTER (WHERE kind = 'withdrawal'), 0),
+			COALESCE(SUM(amount) FILTER (WHERE kind = 'loan payment'), 0),
+			SUM(amount)
+		FROM (
+			SELECT transfers.created_at, categories.name AS category, transfers.currency,
+				transfers.amount, 'transfer' AS kind
+			FROM transfers
+			LEFT JOIN transfer_categories
+			ON transfer_categories.transfer_id = transfers.id
+				AND transfer_categories.user_id = $1
+			LEFT JOIN categories ON categories.id = transfer_categories.category_id
+			WHERE transfers.from_user_id = $1 AND transfers.to_user_id <> $1
+				AND transfers.kind = 'TRANSFER'
+				AND transfers.created_at >= $2 AND transfers.created_at < $3
+			UNION ALL
+			SELECT transactions.created_at, categories.name, transactions.currency,
+				transactions.amount, 'withdrawal'
+			FROM transactions
+			LEFT JOIN categories ON categories.id = transactions.category_id
+			WHERE transactions.user_id = $1 AND transactions.action = 'WITHDRAW'
+				AND transactions.created_at >= $2 AND transactions.created_at < $3
+			UNION ALL
+			SELECT created_at, NULL, currency, amount, 'loan payment'
+			FROM loans
+			WHERE user_id = $1 AND action = 'paid' AND created_at >= $2 AND created_at < $3
+		) AS outgoing
+		GROUP BY 1, 2, 3
+		ORDER BY 1, 2, 3
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, userID, from, to.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spending []*Spending
+	for rows.Next() {
+		var s Spending
+		var month time.Time
+		err := rows.Scan(
+			&month,
+			&s.Category,
+			&s.Currency,
+			&s.Transfers,
+			&s.Withdrawals,
+			&s.LoanPayments,
+			&s.Total,
+		)
+		if err != nil {
+			return nil, err
+		}
+		s.Month = month.Format(monthLayout)
+		spending = append(spending, &s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return spending, nil
+}
+
+// GetUnalerted returns up to limit of the budgets, after the one with the ID afterID, that alert
+// their activated users and have not done so for the month yet. whether they have been gone over
+// is for the caller to work out
+func (r *Repository) GetUnalerted(month time.Time, afterID int64, limit int) ([]*Alert, error) {
+	query := `
+		SELECT budgets.id, budgets.created_at, budgets.updated_at, budgets.user_id,
+			budgets.category_id, categories.name, budgets.amount, budgets.currency, budgets.alert,
+			users.name, users.email
+		FROM budgets
+		INNER JOIN categories ON categories.id = budgets.category_id
+		INNER JOIN users ON users.id = budgets.user_id
+		WHERE budgets.alert = TRUE AND users.activated = TRUE AND budgets.id > $2
+			AND NOT EXISTS (
+				SELECT 1 FROM budget_alerts
+				WHERE budget_alerts.budget_id = budgets.id AND budget_alerts.month = $1
+			)
+		ORDER BY budgets.id
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, month, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []*Alert
+	for rows.Next() {
+		alert := &Alert{Budget: &Budget{}}
+		err := rows.Scan(
+			&alert.Budget.ID,
+			&alert.Budget.CreatedAt,
+			&alert.Budget.UpdatedAt,
+			&alert.Budget.UserID,
+			&alert.Budget.CategoryID,
+			&alert.Budget.Category,
+			&alert.Budget.Amount,
+			&alert.Budget.Currency,
+			&alert.Budget.Alert,
+			&alert.Name,
+			&alert.Email,
+		)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+// MarkAlerted records that the budget's alert for the month is being sent. it reports false if it
+// already had been, by another server at the same time
+func (r *Repository) MarkAlerted(budgetID int64, month time.Time) (bool, error) {
+	query := `
+		INSERT INTO budget_alerts (budget_id, month)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, budgetID, month)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// UnmarkAlerted undoes MarkAlerted when the alert could not be sent, so it is tried again
+func (r *Repository) UnmarkAlerted(budgetID int64, month time.Time) error {
+	query := `
+		DELETE FROM budget_alerts
+		WHERE budget_id = $1 AND month = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, budgetID, month)
+	return err
+}
+
+// scanBudget scans a row of selectBudgets, from either a *sql.Row or *sql.Rows
+func scanBudget(row interface{ Scan(dest ...any) error }) (*Budget, error) {
+	budget := &Budget{}
+	err := row.Scan(
+		&budget.ID,
+		&budget.CreatedAt,
+		&budget.UpdatedAt,
+		&budget.UserID,
+		&budget.CategoryID,
+		&budget.Category,
+		&budget.Amount,
+		&budget.Currency,
+		&budget.Alert,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return budget, nil
+}
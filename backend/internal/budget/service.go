@@ -0,0 +1,224 @@
+package budget
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/category"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// how many budgets are checked per query for the ones that may need an alert sent
+const alertBatchSize = 100
+
+type Repo interface {
+	Save(budget *Budget) error
+	GetAllUserBudgets(userID int64) ([]*Budget, error)
+	Delete(budgetID, userID int64) error
+	Spending(userID int64, from, to time.Time) ([]*Spending, error)
+	GetUnalerted(month time.Time, afterID int64, limit int) ([]*Alert, error)
+	MarkAlerted(budgetID int64, month time.Time) (bool, error)
+	UnmarkAlerted(budgetID int64, month time.Time) error
+}
+
+type Categories interface {
+	GetCategoryByName(userID int64, name string) (*category.Category, error)
+}
+
+type AccountService interface {
+	GetUserAccount(userID int64, number string) (*account.Account, error)
+}
+
+type Mailer interface {
+	Send(recipient, templateFile string, data map[string]any) error
+}
+
+type Service struct {
+	Repo           Repo
+	Categories     Categories
+	AccountService AccountService
+	Mailer         Mailer
+}
+
+// Insights sums what the user spent on each of their categories in each month from the month of
+// from to the month of to, both included
+func (s *Service) Insights(
+	v *validator.Validator, userID int64, from, to time.Time,
+) ([]*Spending, error) {
+	from, to = month(from), month(to)
+	if ValidatePeriod(v, from, to); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	return s.Repo.Spending(userID, from, to)
+}
+
+// Save sets the monthly budget of one of the user's categories, by name, replacing the one it had
+// in the currency. the currency is that of the user's primary account when none is given
+func (s *Service) Save(
+	v *validator.Validator, userID int64, categoryName string, amount money.Amount,
+	currency string, alert bool,
+) (*Budget, error) {
+	c, err := s.Categories.GetCategoryByName(userID, categoryName)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNoRecord):
+			v.AddError("category", "not found")
+			return nil, validator.ErrFailedValidation
+		default:
+			return nil, err
+		}
+	}
+
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		primary, err := s.AccountService.GetUserAccount(userID, "")
+		if err != nil {
+			return nil, err
+		}
+		currency = primary.Currency
+	}
+
+	budget := &Budget{
+		UserID:     userID,
+		CategoryID: c.ID,
+		Category:   c.Name,
+		Amount:     amount,
+		Currency:   currency,
+		Alert:      alert,
+	}
+	if ValidateBudget(v, budget); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	err = s.Repo.Save(budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return budget, nil
+}
+
+func (s *Service) Delete(budgetID, userID int64) error {
+	return s.Repo.Delete(budgetID, userID)
+}
+
+// GetUserProgress is how much of each of the user's budgets has been spent in the month of now
+func (s *Service) GetUserProgress(userID int64, now time.Time) ([]*Progress, error) {
+	budgets, err := s.Repo.GetAllUserBudgets(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(budgets) == 0 {
+		return []*Progress{}, nil
+	}
+
+	m := month(now)
+	spent, err := s.spent(userID, m)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := make([]*Progress, 0, len(budgets))
+	for _, budget := range budgets {
+		progress = append(progress, newProgress(budget, m, spent.of(budget)))
+	}
+
+	return progress, nil
+}
+
+// SendAlerts emails the users of the budgets that alert and have been gone over in the month of
+// now, once a month. an alert that fails to send is left for the next call to try again. it
+// returns how many were sent and the last error, if any
+func (s *Service) SendAlerts(now time.Time) (int, error) {
+	m := month(now)
+
+	sent := 0
+	var lastErr error
+	afterID := int64(0)
+	for {
+		alerts, err := s.Repo.GetUnalerted(m, afterID, alertBatchSize)
+		if err != nil {
+			return sent, err
+		}
+		if len(alerts) == 0 {
+			return sent, lastErr
+		}
+
+		// the spending is only read once for a user with several budgets in the batch
+		spentByUser := make(map[int64]spentByCategory)
+		for _, alert := range alerts {
+			afterID = alert.Budget.ID
+
+			spent, ok := spentByUser[alert.Budget.UserID]
+			if !ok {
+				spent, err = s.spent(alert.Budget.UserID, m)
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				spentByUser[alert.Budget.UserID] = spent
+			}
+			progress := newProgress(alert.Budget, m, spent.of(alert.Budget))
+			if !progress.Exceeded {
+				continue
+			}
+
+			// claim the alert first so that no other server sends it as well
+			claimed, err := s.Repo.MarkAlerted(alert.Budget.ID, m)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if !claimed {
+				continue
+			}
+
+			err = s.Mailer.Send(alert.Email, "budget_exceeded.html", map[string]any{
+				"userName": alert.Name,
+				"category": alert.Budget.Category,
+				"month":    m.Format("January 2006"),
+				"currency": alert.Budget.Currency,
+				"budget":   alert.Budget.Amount,
+				"spent":    progress.Spent,
+			})
+			if err != nil {
+				lastErr = err
+				if err := s.Repo.UnmarkAlerted(alert.Budget.ID, m); err != nil {
+					lastErr = err
+				}
+				continue
+			}
+			sent++
+		}
+	}
+}
+
+// spentByCategory is what was spent in a month on each category in each currency
+type spentByCategory map[string]money.Amount
+
+func (s spentByCategory) of(budget *Budget) money.Amount {
+	return s[spendingKey(budget.Category, budget.Currency)]
+}
+
+func spendingKey(category, currency string) string {
+	return category + "/" + currency
+}
+
+// spent is what the user spent in the month
+func (s *Service) spent(userID int64, m time.Time) (spentByCategory, error) {
+	spending, err := s.Repo.Spending(userID, m, m)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := make(spentByCategory, len(spending))
+	for _, sp := range spending {
+		spent[spendingKey(sp.Category, sp.Currency)] += sp.Total
+	}
+	return spent, nil
+}
@@ -0,0 +1,449 @@
+package budget
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/category"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// MockRepo keeps the budgets in memory, replacing the one of a category in a currency like the
+// unique constraint. Spent is what each user spent in the month asked for
+type MockRepo struct {
+	Budgets []*Budget
+	Spent   map[int64][]*Spending
+	Users   map[int64]*user.User
+	Alerted map[int64]bool
+	// the periods Spending was asked for
+	From, To time.Time
+}
+
+func (r *MockRepo) Save(budget *Budget) error {
+	for i, b := range r.Budgets {
+		if b.CategoryID == budget.CategoryID && b.Currency == budget.Currency {
+			budget.ID = b.ID
+			r.Budgets[i] = budget
+			return nil
+		}
+	}
+	budget.ID = int64(len(r.Budgets) + 1)
+	r.Budgets = append(r.Budgets, budget)
+	return nil
+}
+
+func (r *MockRepo) GetAllUserBudgets(userID int64) ([]*Budget, error) {
+	var budgets []*Budget
+	for _, b := range r.Budgets {
+		if b.UserID == userID {
+			budgets = append(budgets, b)
+		}
+	}
+	return budgets, nil
+}
+
+func (r *MockRepo) Delete(budgetID, userID int64) error {
+	for i, b := range r.Budgets {
+		if b.ID == budgetID && b.UserID == userID {
+			r.Budgets = append(r.Budgets[:i], r.Budgets[i+1:]...)
+			return nil
+		}
+	}
+	return user.ErrNoRecord
+}
+
+func (r *MockRepo) Spending(userID int64, from, to time.Time) ([]*Spending, error) {
+	r.From, r.To = from, to
+	return r.Spent[userID], nil
+}
+
+func (r *MockRepo) GetUnalerted(month time.Time, afterID int64, limit int) ([]*Alert, error) {
+	var alerts []*Alert
+	for _, b := range r.Budgets {
+		if b.Alert && b.ID > afterID && !r.Alerted[b.ID] && len(alerts) < limit {
+			u := r.Users[b.UserID]
+			alerts = append(alerts, &Alert{Budget: b, Name: u.Name, Email: u.Email})
+		}
+	}
+	return alerts, nil
+}
+
+func (r *MockRepo) MarkAlerted(budgetID int64, month time.Time) (bool, error) {
+	if r.Alerted[budgetID] {
+		return false, nil
+	}
+	r.Alerted[budgetID] = true
+	return true, nil
+}
+
+func (r *MockRepo) UnmarkAlerted(budgetID int64, month time.Time) error {
+	delete(r.Alerted, budgetID)
+	return nil
+}
+
+// MockCategories has the categories it is given
+type MockCategories struct {
+	Categories []*category.Category
+}
+
+func (m *MockCategories) GetCategoryByName(userID int64, name string) (*category.Category, error) {
+	for _, c := range m.Categories {
+		if c.UserID == userID && strings.EqualFold(c.Name, strings.TrimSpace(name)) {
+			return c, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+type MockAccountService struct {
+	Accounts []*account.Account
+}
+
+func (as *MockAccountService) GetUserAccount(userID int64, number string) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.UserID == userID && (number == "" || a.Number == number) {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+type sentEmail struct {
+	recipient string
+	data      map[string]any
+}
+
+// MockMailer fails for the recipients in FailFor and keeps everything else it sends
+type MockMailer struct {
+	Sent    []sentEmail
+	FailFor map[string]bool
+}
+
+func (m *MockMailer) Send(recipient, templateFile string, data map[string]any) error {
+	if m.FailFor[recipient] {
+		return errors.New("dialer failed")
+	}
+	m.Sent = append(m.Sent, sentEmail{recipient, data})
+	return nil
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func newCategories() *MockCategories {
+	return &MockCategories{Categories: []*category.Category{
+		{ID: 1, UserID: 1, Name: "rent"},
+		{ID: 2, UserID: 1, Name: "groceries"},
+		{ID: 3, UserID: 2, Name: "rent"},
+	}}
+}
+
+func TestSave(t *testing.T) {
+	type input struct {
+		category string
+		amount   money.Amount
+		currency string
+	}
+	tests := []struct {
+		name         string
+		input        input
+		wantCategory int64
+		wantCurrency string
+		expectedErr  error
+		wantErrKey   string
+	}{
+		{
+			name:         "in the primary account's currency",
+			input:        input{category: "Rent", amount: money.MustParse("500")},
+			wantCategory: 1,
+			wantCurrency: "KES",
+		},
+		{
+			name: "in another currency",
+			input: input{
+				category: "groceries", amount: money.MustParse("50"), currency: "eur",
+			},
+			wantCategory: 2,
+			wantCurrency: "EUR",
+		},
+		{
+			name:        "unknown category",
+			input:       input{category: "travel", amount: money.MustParse("50")},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "category",
+		},
+		{
+			name:        "no amount",
+			input:       input{category: "rent"},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "amount",
+		},
+		{
+			name:        "negative amount",
+			input:       input{category: "rent", amount: money.MustParse("-5")},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "amount",
+		},
+		{
+			name:        "invalid currency",
+			input:       input{category: "rent", amount: money.MustParse("5"), currency: "XYZ"},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "currency",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			svc := Service{
+				Repo:       repo,
+				Categories: newCategories(),
+				AccountService: &MockAccountService{Accounts: []*account.Account{
+					{ID: 1, UserID: 1, Currency: "KES"},
+				}},
+			}
+
+			v := validator.New()
+			got, gotErr := svc.Save(
+				v, 1, tc.input.category, tc.input.amount, tc.input.currency, true,
+			)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if tc.wantErrKey != "" {
+				if _, ok := v.Errors[tc.wantErrKey]; !ok {
+					t.Errorf("expected an error on %q, got %v", tc.wantErrKey, v.Errors)
+				}
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if got.CategoryID != tc.wantCategory || got.Currency != tc.wantCurrency {
+				t.Errorf(
+					"expected category %d in %s, got category %d in %s", tc.wantCategory,
+					tc.wantCurrency, got.CategoryID, got.Currency,
+				)
+			}
+		})
+	}
+
+	// saving it again replaces it
+	repo := &MockRepo{}
+	svc := Service{Repo: repo, Categories: newCategories()}
+	for _, amount := range []string{"500", "600"} {
+		_, err := svc.Save(validator.New(), 1, "rent", money.MustParse(amount), "USD", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(repo.Budgets) != 1 || repo.Budgets[0].Amount != money.MustParse("600") {
+		t.Errorf("expected the one budget of 600, got %+v", repo.Budgets)
+	}
+}
+
+func TestInsights(t *testing.T) {
+	tests := []struct {
+		name        string
+		from, to    time.Time
+		wantFrom    time.Time
+		wantTo      time.Time
+		expectedErr error
+	}{
+		{
+			name:     "whole months",
+			from:     date("2026-01-15T10:00:00Z"),
+			to:       date("2026-03-31T23:59:00Z"),
+			wantFrom: date("2026-01-01T00:00:00Z"),
+			wantTo:   date("2026-03-01T00:00:00Z"),
+		},
+		{
+			name:     "one month",
+			from:     date("2026-03-01T00:00:00Z"),
+			to:       date("2026-03-20T00:00:00Z"),
+			wantFrom: date("2026-03-01T00:00:00Z"),
+			wantTo:   date("2026-03-01T00:00:00Z"),
+		},
+		{
+			name:        "to before from",
+			from:        date("2026-03-01T00:00:00Z"),
+			to:          date("2026-02-01T00:00:00Z"),
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:     "24 months",
+			from:     date("2024-04-01T00:00:00Z"),
+			to:       date("2026-03-01T00:00:00Z"),
+			wantFrom: date("2024-04-01T00:00:00Z"),
+			wantTo:   date("2026-03-01T00:00:00Z"),
+		},
+		{
+			name:        "more than 24 months",
+			from:        date("2024-03-01T00:00:00Z"),
+			to:          date("2026-03-01T00:00:00Z"),
+			expectedErr: validator.ErrFailedValidation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			svc := Service{Repo: repo}
+
+			_, gotErr := svc.Insights(validator.New(), 1, tc.from, tc.to)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				return
+			}
+			if !repo.From.Equal(tc.wantFrom) || !repo.To.Equal(tc.wantTo) {
+				t.Errorf(
+					"expected %v to %v, got %v to %v", tc.wantFrom, tc.wantTo, repo.From, repo.To,
+				)
+			}
+		})
+	}
+}
+
+func newSpent() map[int64][]*Spending {
+	return map[int64][]*Spending{
+		1: {
+			{Category: "rent", Currency: "USD", Total: money.MustParse("550")},
+			{Category: "groceries", Currency: "USD", Total: money.MustParse("40")},
+			{Category: "groceries", Currency: "EUR", Total: money.MustParse("90")},
+		},
+		2: {
+			{Category: "rent", Currency: "USD", Total: money.MustParse("700")},
+		},
+	}
+}
+
+func newBudgets() []*Budget {
+	return []*Budget{
+		{ID: 1, UserID: 1, CategoryID: 1, Category: "rent", Currency: "USD",
+			Amount: money.MustParse("500"), Alert: true},
+		{ID: 2, UserID: 1, CategoryID: 2, Category: "groceries", Currency: "USD",
+			Amount: money.MustParse("100"), Alert: true},
+		{ID: 3, UserID: 2, CategoryID: 3, Category: "rent", Currency: "USD",
+			Amount: money.MustParse("600"), Alert: true},
+		{ID: 4, UserID: 2, CategoryID: 3, Category: "rent", Currency: "EUR",
+			Amount: money.MustParse("1"), Alert: false},
+	}
+}
+
+func TestGetUserProgress(t *testing.T) {
+	repo := &MockRepo{Budgets: newBudgets(), Spent: newSpent()}
+	svc := Service{Repo: repo}
+
+	progress, err := svc.GetUserProgress(1, date("2026-10-17T12:00:00Z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 2 {
+		t.Fatalf("expected 2 budgets, got %d", len(progress))
+	}
+
+	// the groceries spent in euros don't count against the budget in dollars
+	want := []struct {
+		spent, remaining money.Amount
+		exceeded         bool
+	}{
+		{money.MustParse("550"), 0, true},
+		{money.MustParse("40"), money.MustParse("60"), false},
+	}
+	for i, p := range progress {
+		if p.Spent != want[i].spent || p.Remaining != want[i].remaining ||
+			p.Exceeded != want[i].exceeded {
+			t.Errorf("budget %d: expected %+v, got %+v", p.Budget.ID, want[i], p)
+		}
+		if p.Month != "2026-10" {
+			t.Errorf("expected month 2026-10, got %s", p.Month)
+		}
+	}
+	if !repo.From.Equal(date("2026-10-01T00:00:00Z")) {
+		t.Errorf("expected the spending of october, got from %v", repo.From)
+	}
+}
+
+func TestSendAlerts(t *testing.T) {
+	now := date("2026-10-17T12:00:00Z")
+	users := map[int64]*user.User{
+		1: {ID: 1, Name: "yusuf", Email: "y@gmail.com"},
+		2: {ID: 2, Name: "mohamed", Email: "m@gmail.com"},
+	}
+
+	tests := []struct {
+		name        string
+		alerted     map[int64]bool
+		failFor     map[string]bool
+		wantSent    int
+		wantAlerted map[int64]bool
+		wantErr     bool
+	}{
+		{
+			// groceries is under budget and the euro budget doesn't alert
+			name:        "every exceeded budget",
+			alerted:     map[int64]bool{},
+			wantSent:    2,
+			wantAlerted: map[int64]bool{1: true, 3: true},
+		},
+		{
+			name:        "already sent this month",
+			alerted:     map[int64]bool{1: true},
+			wantSent:    1,
+			wantAlerted: map[int64]bool{1: true, 3: true},
+		},
+		{
+			name:        "failed to send",
+			alerted:     map[int64]bool{},
+			failFor:     map[string]bool{"m@gmail.com": true},
+			wantSent:    1,
+			wantAlerted: map[int64]bool{1: true},
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{
+				Budgets: newBudgets(), Spent: newSpent(), Users: users, Alerted: tc.alerted,
+			}
+			mail := &MockMailer{FailFor: tc.failFor}
+			svc := Service{Repo: repo, Mailer: mail}
+
+			sent, gotErr := svc.SendAlerts(now)
+			if (gotErr != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, gotErr)
+			}
+			if sent != tc.wantSent || len(mail.Sent) != tc.wantSent {
+				t.Errorf("expected %d sent, got %d and %d emails", tc.wantSent, sent, len(mail.Sent))
+			}
+			if len(repo.Alerted) != len(tc.wantAlerted) {
+				t.Errorf("expected alerted %v, got %v", tc.wantAlerted, repo.Alerted)
+			}
+			for budgetID := range tc.wantAlerted {
+				if !repo.Alerted[budgetID] {
+					t.Errorf("expected budget %d alerted", budgetID)
+				}
+			}
+
+			for _, email := range mail.Sent {
+				if email.recipient == "y@gmail.com" &&
+					email.data["spent"] != money.MustParse("550") {
+					t.Errorf("expected spent=550, got %v", email.data["spent"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package category
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// Category is a name the user files their own transfers and transactions under, like rent or
+// groceries. the names are unique per user, ignoring case
+type Category struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+}
+
+func ValidateCategory(v *validator.Validator, category *Category) {
+	v.CheckAddError(category.Name != "", "name", "must be given")
+	v.CheckAddError(len(category.Name) <= 50, "name", "must not be more than 50 bytes long")
+}
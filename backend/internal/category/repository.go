@@ -0,0 +1,205 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+var ErrDuplicateName = errors.New("duplicate category name")
+
+type Repository struct {
+	DB *sql.DB
+}
+
+func (r *Repository) Insert(category *Category) error {
+	query := `
+		INSERT INTO categories (user_id, name)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(ctx, query, category.UserID, category.Name).Scan(
+		&category.ID,
+		&category.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case err.Error() ==
+			`pq: duplicate key value violates unique constraint "categories_user_id_name_key"`:
+			return ErrDuplicateName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetByName finds one of the user's categories, the name is matched ignoring case
+func (r *Repository) GetByName(userID int64, name string) (*Category, error) {
+	query := `
+		SELECT id, created_at, user_id, name
+		FROM categories
+		WHERE user_id = $1 AND name = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	category, err := scanCategory(r.DB.QueryRowContext(ctx, query, userID, name))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return category, nil
+}
+
+func (r *Repository) GetAllUserCategories(userID int64) ([]*Category, error) {
+	query := `
+		SELECT id, created_at, user_id, name
+		FROM categories
+		WHERE user_id = $1
+		ORDER BY name
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []*Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// Delete deletes one of the user's categories, what was filed under it is left uncategorized
+func (r *Repository) Delete(categoryID, userID int64) error {
+	query := `
+		DELETE FROM categories
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, categoryID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return user.ErrNoRecord
+	}
+
+	return nil
+}
+
+// SetTransferCategory files a transfer the user sent or received under the category, or takes it
+// out of the one it is in when categoryID is nil. the other side of the transfer is not affected
+func (r *Repository) SetTransferCategory(transferID, userID int64, categoryID *int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM transfers
+			WHERE id = $1 AND (from_user_id = $2 OR to_user_id = $2)
+		)
+	`, transferID, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return user.ErrNoRecord
+	}
+
+	if categoryID == nil {
+		_, err = r.DB.ExecContext(ctx, `
+			DELETE FROM transfer_categories
+			WHERE transfer_id = $1 AND user_id = $2
+		`, transferID, userID)
+		return err
+	}
+
+	_, err = r.DB.ExecContext(ctx, `
+		INSERT INTO transfer_categories (transfer_id, user_id, category_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (transfer_id, user_id) DO UPDATE SET category_id = EXCLUDED.category_id
+	`, transferID, userID, *categoryID)
+	return err
+}
+
+// SetTransactionCategory files one of the user's transactions under the category, or clears it
+// when categoryID is nil
+func (r *Repository) SetTransactionCategory(transactionID, userID int64, categoryID *int64) error {
+	query := `
+		UPDATE transactions
+		SET category_id = $1
+		WHERE id = $2 AND user_id = $3
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, categoryID, transactionID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return user.ErrNoRecord
+	}
+
+	return nil
+}
+
+// scanCategory scans a category, from either a *sql.Row or *sql.Rows
+func scanCategory(row interface{ Scan(dest ...any) error }) (*Category, error) {
+	category := &Category{}
+	err := row.Scan(
+		&category.ID,
+		&category.CreatedAt,
+		&category.UserID,
+		&category.Name,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
@@ -0,0 +1,105 @@
+package category
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type Repo interface {
+	Insert(category *Category) error
+	GetByName(userID int64, name string) (*Category, error)
+	GetAllUserCategories(userID int64) ([]*Category, error)
+	Delete(categoryID, userID int64) error
+	SetTransferCategory(transferID, userID int64, categoryID *int64) error
+	SetTransactionCategory(transactionID, userID int64, categoryID *int64) error
+}
+
+type Service struct {
+	Repo Repo
+}
+
+func (s *Service) New(v *validator.Validator, userID int64, name string) (*Category, error) {
+	category := &Category{
+		UserID: userID,
+		Name:   strings.TrimSpace(name),
+	}
+
+	if ValidateCategory(v, category); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	err := s.Repo.Insert(category)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrDuplicateName):
+			v.AddError("name", "already used for another category")
+			return nil, validator.ErrFailedValidation
+		default:
+			return nil, err
+		}
+	}
+
+	return category, nil
+}
+
+func (s *Service) GetAllUserCategories(userID int64) ([]*Category, error) {
+	return s.Repo.GetAllUserCategories(userID)
+}
+
+// GetCategoryByName finds one of the user's categories, the name is matched ignoring case
+func (s *Service) GetCategoryByName(userID int64, name string) (*Category, error) {
+	return s.Repo.GetByName(userID, strings.TrimSpace(name))
+}
+
+func (s *Service) Delete(categoryID, userID int64) error {
+	return s.Repo.Delete(categoryID, userID)
+}
+
+// CategorizeTransfer files a transfer the user is a party to under one of their categories by
+// name, an empty name takes it out of its category
+func (s *Service) CategorizeTransfer(
+	v *validator.Validator, userID, transferID int64, name string,
+) error {
+	categoryID, err := s.categoryID(v, userID, name)
+	if err != nil {
+		return err
+	}
+
+	return s.Repo.SetTransferCategory(transferID, userID, categoryID)
+}
+
+// CategorizeTransaction is CategorizeTransfer for the user's deposits and withdrawals
+func (s *Service) CategorizeTransaction(
+	v *validator.Validator, userID, transactionID int64, name string,
+) error {
+	categoryID, err := s.categoryID(v, userID, name)
+	if err != nil {
+		return err
+	}
+
+	return s.Repo.SetTransactionCategory(transactionID, userID, categoryID)
+}
+
+// categoryID is the id of the user's category with the name, nil for no name
+func (s *Service) categoryID(v *validator.Validator, userID int64, name string) (*int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+
+	category, err := s.GetCategoryByName(userID, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNoRecord):
+			v.AddError("category", "not found")
+			return nil, validator.ErrFailedValidation
+		default:
+			return nil, err
+		}
+	}
+
+	return &category.ID, nil
+}
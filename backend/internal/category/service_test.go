@@ -0,0 +1,258 @@
+package category
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// MockRepo keeps the categories in memory, the names are unique per user ignoring case like the
+// citext column. TransferCategories and TransactionCategories are keyed by the id of what was
+// filed, only the ones in Transfers and Transactions can be
+type MockRepo struct {
+	Categories            []*Category
+	InsertErr             error
+	Transfers             map[int64][]int64 // transfer id to the ids of its two users
+	Transactions          map[int64]int64   // transaction id to its user id
+	TransferCategories    map[int64]*int64
+	TransactionCategories map[int64]*int64
+}
+
+func (r *MockRepo) Insert(category *Category) error {
+	if r.InsertErr != nil {
+		return r.InsertErr
+	}
+	for _, c := range r.Categories {
+		if c.UserID == category.UserID && strings.EqualFold(c.Name, category.Name) {
+			return ErrDuplicateName
+		}
+	}
+	category.ID = int64(len(r.Categories) + 1)
+	r.Categories = append(r.Categories, category)
+	return nil
+}
+
+func (r *MockRepo) GetByName(userID int64, name string) (*Category, error) {
+	for _, c := range r.Categories {
+		if c.UserID == userID && strings.EqualFold(c.Name, name) {
+			return c, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (r *MockRepo) GetAllUserCategories(userID int64) ([]*Category, error) {
+	var categories []*Category
+	for _, c := range r.Categories {
+		if c.UserID == userID {
+			categories = append(categories, c)
+		}
+	}
+	return categories, nil
+}
+
+func (r *MockRepo) Delete(categoryID, userID int64) error {
+	for i, c := range r.Categories {
+		if c.ID == categoryID && c.UserID == userID {
+			r.Categories = append(r.Categories[:i], r.Categories[i+1:]...)
+			return nil
+		}
+	}
+	return user.ErrNoRecord
+}
+
+func (r *MockRepo) SetTransferCategory(transferID, userID int64, categoryID *int64) error {
+	for _, id := range r.Transfers[transferID] {
+		if id == userID {
+			r.TransferCategories[transferID] = categoryID
+			return nil
+		}
+	}
+	return user.ErrNoRecord
+}
+
+func (r *MockRepo) SetTransactionCategory(transactionID, userID int64, categoryID *int64) error {
+	if id, ok := r.Transactions[transactionID]; !ok || id != userID {
+		return user.ErrNoRecord
+	}
+	r.TransactionCategories[transactionID] = categoryID
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	errDB := errors.New("db error")
+
+	tests := []struct {
+		name        string
+		existing    []*Category
+		insertErr   error
+		userID      int64
+		input       string
+		wantName    string
+		expectedErr error
+	}{
+		{
+			name:     "valid name",
+			userID:   1,
+			input:    "  rent  ",
+			wantName: "rent",
+		},
+		{
+			name:        "no name",
+			userID:      1,
+			input:       "   ",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:        "name too long",
+			userID:      1,
+			input:       strings.Repeat("x", 51),
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:        "name already used in another case",
+			existing:    []*Category{{ID: 1, UserID: 1, Name: "rent"}},
+			userID:      1,
+			input:       "Rent",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:     "another user's category does not clash",
+			existing: []*Category{{ID: 1, UserID: 2, Name: "rent"}},
+			userID:   1,
+			input:    "rent",
+			wantName: "rent",
+		},
+		{
+			name:        "Insert failure",
+			insertErr:   errDB,
+			userID:      1,
+			input:       "rent",
+			expectedErr: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := Service{Repo: &MockRepo{Categories: tc.existing, InsertErr: tc.insertErr}}
+
+			v := validator.New()
+			got, gotErr := svc.New(v, tc.userID, tc.input)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if errors.Is(gotErr, validator.ErrFailedValidation) {
+				if _, ok := v.Errors["name"]; !ok {
+					t.Errorf("expected an error on name, got %v", v.Errors)
+				}
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if got.Name != tc.wantName || got.UserID != tc.userID {
+				t.Errorf(
+					"expected %q of user %d, got %q of user %d", tc.wantName, tc.userID,
+					got.Name, got.UserID,
+				)
+			}
+		})
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	tests := []struct {
+		name        string
+		transfer    bool // a transfer, otherwise a transaction
+		id          int64
+		userID      int64
+		category    string
+		wantID      *int64
+		expectedErr error
+	}{
+		{
+			name: "transfer the user sent", transfer: true, id: 1, userID: 1, category: "rent",
+			wantID: ptr(1),
+		},
+		{
+			name: "transfer the user received", transfer: true, id: 1, userID: 2,
+			category: "food", wantID: ptr(3),
+		},
+		{
+			name: "by name in another case", transfer: true, id: 1, userID: 1,
+			category: "RENT", wantID: ptr(1),
+		},
+		{
+			name: "transfer of other users", transfer: true, id: 1, userID: 3, category: "",
+			expectedErr: user.ErrNoRecord,
+		},
+		{
+			// category 3 belongs to user 2
+			name: "another user's category", transfer: true, id: 1, userID: 1, category: "food",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "cleared", transfer: true, id: 1, userID: 1, category: " ",
+		},
+		{
+			name: "transaction", id: 5, userID: 1, category: "groceries", wantID: ptr(2),
+		},
+		{
+			name: "another user's transaction", id: 5, userID: 2, category: "food",
+			expectedErr: user.ErrNoRecord,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{
+				Categories: []*Category{
+					{ID: 1, UserID: 1, Name: "rent"},
+					{ID: 2, UserID: 1, Name: "groceries"},
+					{ID: 3, UserID: 2, Name: "food"},
+				},
+				Transfers:             map[int64][]int64{1: {1, 2}},
+				Transactions:          map[int64]int64{5: 1},
+				TransferCategories:    map[int64]*int64{1: ptr(2)},
+				TransactionCategories: map[int64]*int64{},
+			}
+			svc := Service{Repo: repo}
+
+			v := validator.New()
+			var gotErr error
+			var got map[int64]*int64
+			if tc.transfer {
+				gotErr = svc.CategorizeTransfer(v, tc.userID, tc.id, tc.category)
+				got = repo.TransferCategories
+			} else {
+				gotErr = svc.CategorizeTransaction(v, tc.userID, tc.id, tc.category)
+				got = repo.TransactionCategories
+			}
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			gotID := got[tc.id]
+			if (gotID == nil) != (tc.wantID == nil) || gotID != nil && *gotID != *tc.wantID {
+				t.Errorf("expected category %v, got %v", deref(tc.wantID), deref(gotID))
+			}
+		})
+	}
+}
+
+func ptr(id int64) *int64 {
+	return &id
+}
+
+func deref(id *int64) any {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
@@ -0,0 +1,95 @@
+package dbtx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	maxAttempts = 5
+	timeout     = 3 * time.Second
+)
+
+// postgres error codes that mean the transaction was aborted because of a concurrent one and is
+// safe to run again from the start
+const (
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+)
+
+// Func is work done inside a transaction. a repository can take some from another package and run
+// it in the transaction it makes, so that the work is committed or rolled back with the rest
+type Func func(ctx context.Context, tx *sql.Tx) error
+
+// Run runs fn inside a serializable transaction and commits it. if postgres aborts the transaction
+// because of a concurrent one, the whole thing is retried with a fresh transaction, so fn must not
+// have side effects outside of tx
+func Run(db *sql.DB, fn Func) error {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = runOnce(db, fn)
+		if !Retryable(err) {
+			return err
+		}
+
+		// back off a little more each time so the competing transaction can finish
+		time.Sleep(time.Duration(attempt) * 10 * time.Millisecond)
+	}
+
+	return err
+}
+
+func runOnce(db *sql.DB, fn Func) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return &notCommittedError{err: err}
+	}
+
+	// rollback if anything goes wrong, it is a no-op after a commit
+	defer tx.Rollback()
+
+	err = fn(ctx, tx)
+	if err != nil {
+		return &notCommittedError{err: err}
+	}
+
+	// a failed commit is not marked, the transaction may have been committed all the same
+	return tx.Commit()
+}
+
+// notCommittedError is an error from a transaction that was rolled back, so none of it was saved
+type notCommittedError struct {
+	err error
+}
+
+func (e *notCommittedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notCommittedError) Unwrap() error {
+	return e.err
+}
+
+// NotCommitted reports whether the error came from a transaction Run rolled back, in which case
+// nothing it did was saved and the request that made it can be tried again
+func NotCommitted(err error) bool {
+	var notCommitted *notCommittedError
+	return errors.As(err, &notCommitted)
+}
+
+// Retryable reports whether the error is a serialization failure or a deadlock
+func Retryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	return pqErr.Code == codeSerializationFailure || pqErr.Code == codeDeadlockDetected
+}
@@ -0,0 +1,103 @@
+package event
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// how many events are published per query of the outbox
+	dispatchBatchSize = 100
+	// MaxAttempts is how many times an event is published before it is left in the outbox for
+	// someone to look at
+	MaxAttempts = 5
+)
+
+// All subscribes a handler to the events of every type
+const All = "*"
+
+type Repo interface {
+	GetPending(limit, maxAttempts int) ([]*Event, error)
+	MarkPublished(eventID int64, publishedAt time.Time) error
+	MarkFailed(eventID int64, reason string) error
+}
+
+// Handler is given the events it was subscribed to. an error has the event published again later,
+// to every subscriber, so it should be safe to be given one more than once
+type Handler func(e *Event) error
+
+// Dispatcher publishes the events in the outbox to the subscribers in the process, in the order
+// they were recorded. an event is only marked published once every subscriber took it
+type Dispatcher struct {
+	Repo Repo
+
+	mu          sync.RWMutex
+	subscribers map[string][]Handler
+}
+
+// Subscribe has h given the events of the type, or of every type if it is All
+func (d *Dispatcher) Subscribe(eventType string, h Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.subscribers == nil {
+		d.subscribers = make(map[string][]Handler)
+	}
+	d.subscribers[eventType] = append(d.subscribers[eventType], h)
+}
+
+// Dispatch publishes the events waiting in the outbox and returns how many were. one that a
+// subscriber fails is tried again on a later dispatch, up to MaxAttempts times
+func (d *Dispatcher) Dispatch(now time.Time) (int, error) {
+	published := 0
+	for {
+		events, err := d.Repo.GetPending(dispatchBatchSize, MaxAttempts)
+		if err != nil {
+			return published, err
+		}
+
+		failed := 0
+		for _, e := range events {
+			if err := d.publish(e); err != nil {
+				failed++
+				if err := d.Repo.MarkFailed(e.ID, err.Error()); err != nil {
+					return published, err
+				}
+				continue
+			}
+
+			if err := d.Repo.MarkPublished(e.ID, now); err != nil {
+				return published, err
+			}
+			published++
+		}
+
+		// the failed ones would come back in the next batch, they wait for the next dispatch
+		if len(events) < dispatchBatchSize || failed > 0 {
+			return published, nil
+		}
+	}
+}
+
+// publish gives the event to its subscribers, stopping at the first that fails
+func (d *Dispatcher) publish(e *Event) (err error) {
+	// a panicking subscriber fails the event rather than the dispatcher
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("subscriber panicked: %v", r)
+		}
+	}()
+
+	d.mu.RLock()
+	handlers := append(append([]Handler{}, d.subscribers[e.Type]...), d.subscribers[All]...)
+	d.mu.RUnlock()
+
+	for _, h := range handlers {
+		if err := h(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package event
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+)
+
+type MockRepo struct {
+	Pending   []*Event
+	Published []int64
+	Failed    map[int64]string
+}
+
+func (r *MockRepo) GetPending(limit, maxAttempts int) ([]*Event, error) {
+	var pending []*Event
+	for _, e := range r.Pending {
+		if e.PublishedAt == nil && e.Attempts < maxAttempts && len(pending) < limit {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+func (r *MockRepo) MarkPublished(eventID int64, publishedAt time.Time) error {
+	for _, e := range r.Pending {
+		if e.ID == eventID {
+			e.PublishedAt = &publishedAt
+		}
+	}
+	r.Published = append(r.Published, eventID)
+	return nil
+}
+
+func (r *MockRepo) MarkFailed(eventID int64, reason string) error {
+	for _, e := range r.Pending {
+		if e.ID == eventID {
+			e.Attempts++
+		}
+	}
+	if r.Failed == nil {
+		r.Failed = make(map[int64]string)
+	}
+	r.Failed[eventID] = reason
+	return nil
+}
+
+func mustNew(t *testing.T, id, userID int64, p Payload) *Event {
+	t.Helper()
+	e, err := New(userID, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ID = id
+	return e
+}
+
+func TestDecode(t *testing.T) {
+	e := mustNew(t, 1, 7, Deposit{Transaction: Transaction{
+		TransactionID: 3, Amount: money.MustParse("12.50"), Currency: "USD",
+	}})
+	if e.Type != TypeDeposit {
+		t.Fatalf("expected type %q, got %q", TypeDeposit, e.Type)
+	}
+
+	var deposit Deposit
+	if err := e.Decode(&deposit); err != nil {
+		t.Fatal(err)
+	}
+	if deposit.TransactionID != 3 || deposit.Amount != money.MustParse("12.50") {
+		t.Errorf("expected the deposit back, got %+v", deposit)
+	}
+
+	var withdrawal Withdrawal
+	if err := e.Decode(&withdrawal); !errors.Is(err, ErrWrongType) {
+		t.Errorf("expected error %v, got %v", ErrWrongType, err)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		subscribe     func(d *Dispatcher, got *[]string)
+		wantPublished []int64
+		wantFailed    []int64
+		wantGot       []string
+	}{
+		{
+			name: "to the subscribers of the type and of all",
+			subscribe: func(d *Dispatcher, got *[]string) {
+				d.Subscribe(TypeUserRegistered, func(e *Event) error {
+					*got = append(*got, "registered:"+e.Type)
+					return nil
+				})
+				d.Subscribe(All, func(e *Event) error {
+					*got = append(*got, "all:"+e.Type)
+					return nil
+				})
+			},
+			wantPublished: []int64{1, 2},
+			wantGot: []string{
+				"registered:" + TypeUserRegistered, "all:" + TypeUserRegistered,
+				"all:" + TypeTransferCompleted,
+			},
+		},
+		{
+			name:          "without subscribers",
+			subscribe:     func(d *Dispatcher, got *[]string) {},
+			wantPublished: []int64{1, 2},
+		},
+		{
+			name: "failing subscriber",
+			subscribe: func(d *Dispatcher, got *[]string) {
+				d.Subscribe(TypeTransferCompleted, func(e *Event) error {
+					return errors.New("webhook down")
+				})
+			},
+			wantPublished: []int64{1},
+			wantFailed:    []int64{2},
+		},
+		{
+			name: "panicking subscriber",
+			subscribe: func(d *Dispatcher, got *[]string) {
+				d.Subscribe(TypeUserRegistered, func(e *Event) error {
+					panic("nil map")
+				})
+			},
+			wantPublished: []int64{2},
+			wantFailed:    []int64{1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{Pending: []*Event{
+				mustNew(t, 1, 7, UserRegistered{Name: "yusuf", Email: "y@gmail.com"}),
+				mustNew(t, 2, 7, TransferCompleted{Transfer: Transfer{TransferID: 4}}),
+			}}
+			d := &Dispatcher{Repo: repo}
+			var got []string
+			tc.subscribe(d, &got)
+
+			published, err := d.Dispatch(time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(repo.Published, tc.wantPublished) || published != len(repo.Published) {
+				t.Errorf("expected %v published, got %v", tc.wantPublished, repo.Published)
+			}
+			for _, id := range tc.wantFailed {
+				if repo.Failed[id] == "" {
+					t.Errorf("expected event %d to fail, got %v", id, repo.Failed)
+				}
+			}
+			if !slices.Equal(got, tc.wantGot) {
+				t.Errorf("expected the subscribers to get %v, got %v", tc.wantGot, got)
+			}
+		})
+	}
+}
+
+// TestDispatchGivesUp checks an event that keeps failing is left in the outbox after MaxAttempts
+func TestDispatchGivesUp(t *testing.T) {
+	repo := &MockRepo{Pending: []*Event{mustNew(t, 1, 7, UserActivated{Email: "y@gmail.com"})}}
+	d := &Dispatcher{Repo: repo}
+	calls := 0
+	d.Subscribe(All, func(e *Event) error {
+		calls++
+		return errors.New("down")
+	})
+
+	for range MaxAttempts + 2 {
+		if _, err := d.Dispatch(time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != MaxAttempts {
+		t.Errorf("expected %d attempts, got %d", MaxAttempts, calls)
+	}
+}
@@ -0,0 +1,203 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+)
+
+// the types of the events, named after what changed and how
+const (
+	TypeUserRegistered     = "user.registered"
+	TypeUserActivated      = "user.activated"
+	TypeTransferCompleted  = "transfer.completed"
+	TypeTransferReversed   = "transfer.reversed"
+	TypeLoanRequested      = "loan_request.created"
+	TypeLoanAccepted       = "loan_request.accepted"
+	TypeLoanDeclined       = "loan_request.declined"
+	TypeLoanPaid           = "loan.paid"
+	TypeLoanDeleted        = "loan.deleted"
+	TypeDeposit            = "transaction.deposit"
+	TypeWithdrawal         = "transaction.withdrawal"
+	TypePermissionsGranted = "permissions.granted"
+)
+
+// Types are all the types of events there are
+var Types = []string{
+	TypeUserRegistered,
+	TypeUserActivated,
+	TypeTransferCompleted,
+	TypeTransferReversed,
+	TypeLoanRequested,
+	TypeLoanAccepted,
+	TypeLoanDeclined,
+	TypeLoanPaid,
+	TypeLoanDeleted,
+	TypeDeposit,
+	TypeWithdrawal,
+	TypePermissionsGranted,
+}
+
+// ErrWrongType is returned decoding an event into the payload of another type
+var ErrWrongType = errors.New("payload of another type")
+
+// Event is a change to the state of the bank, saved to the outbox in the same database transaction
+// as the change itself so there is never one without the other. UserID is the user it happened to
+// and Payload the JSON of one of the payloads below, the one of its Type. Attempts is how many
+// times publishing it failed, and LastError why it last did
+type Event struct {
+	ID          int64
+	CreatedAt   time.Time
+	Type        string
+	UserID      int64
+	Payload     json.RawMessage
+	Attempts    int
+	LastError   string
+	PublishedAt *time.Time
+}
+
+// Payload is what an event of a type says happened
+type Payload interface {
+	Type() string
+}
+
+// New makes the event of the payload that happened to the user
+func New(userID int64, p Payload) (*Event, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{Type: p.Type(), UserID: userID, Payload: payload}, nil
+}
+
+// Decode reads the event's payload into p, which must be of the event's type
+func (e *Event) Decode(p Payload) error {
+	if p.Type() != e.Type {
+		return ErrWrongType
+	}
+
+	return json.Unmarshal(e.Payload, p)
+}
+
+type UserRegistered struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (UserRegistered) Type() string { return TypeUserRegistered }
+
+type UserActivated struct {
+	Email string `json:"email"`
+}
+
+func (UserActivated) Type() string { return TypeUserActivated }
+
+// Transfer is what the transfer events say about the transfer, Amount leaving the from account in
+// Currency and ToAmount arriving in the to account in ToCurrency
+type Transfer struct {
+	TransferID    int64        `json:"transfer_id"`
+	Kind          string       `json:"kind"`
+	FromUserID    int64        `json:"from_user_id"`
+	FromAccountID int64        `json:"from_account_id"`
+	ToUserID      int64        `json:"to_user_id"`
+	ToAccountID   int64        `json:"to_account_id"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"`
+	ToAmount      money.Amount `json:"to_amount"`
+	ToCurrency    string       `json:"to_currency"`
+}
+
+// TransferCompleted is a transfer that was made, a refund or reversal as well
+type TransferCompleted struct {
+	Transfer
+}
+
+func (TransferCompleted) Type() string { return TypeTransferCompleted }
+
+// TransferReversed is a transfer that some of was sent back, by the refund or reversal with ID
+// ReversalID. Status is what the transfer is now
+type TransferReversed struct {
+	Transfer
+	ReversalID int64  `json:"reversal_id"`
+	Status     string `json:"status"`
+}
+
+func (TransferReversed) Type() string { return TypeTransferReversed }
+
+// LoanRequest is what the loan request events say about the request
+type LoanRequest struct {
+	LoanRequestID     int64        `json:"loan_request_id"`
+	AccountID         int64        `json:"account_id"`
+	Amount            money.Amount `json:"amount"`
+	Currency          string       `json:"currency"`
+	DailyInterestRate float64      `json:"daily_interest_rate"`
+}
+
+type LoanRequested struct {
+	LoanRequest
+}
+
+func (LoanRequested) Type() string { return TypeLoanRequested }
+
+type LoanAccepted struct {
+	LoanRequest
+}
+
+func (LoanAccepted) Type() string { return TypeLoanAccepted }
+
+type LoanDeclined struct {
+	LoanRequest
+}
+
+func (LoanDeclined) Type() string { return TypeLoanDeclined }
+
+// LoanPaid is a payment of Paid on the loan, RemainingAmount being what is still owed after it
+type LoanPaid struct {
+	LoanID          int64        `json:"loan_id"`
+	Paid            money.Amount `json:"paid"`
+	RemainingAmount money.Amount `json:"remaining_amount"`
+	Currency        string       `json:"currency"`
+}
+
+func (LoanPaid) Type() string { return TypeLoanPaid }
+
+// LoanDeleted is a loan that staff wrote off, RemainingAmount being what was still owed
+type LoanDeleted struct {
+	LoanID          int64        `json:"loan_id"`
+	DeletedByID     int64        `json:"deleted_by_id"`
+	RemainingAmount money.Amount `json:"remaining_amount"`
+	Reason          string       `json:"reason"`
+}
+
+func (LoanDeleted) Type() string { return TypeLoanDeleted }
+
+// Transaction is what the deposit and withdrawal events say about the transaction
+type Transaction struct {
+	TransactionID int64        `json:"transaction_id"`
+	AccountID     int64        `json:"account_id"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"`
+	PerformedBy   string       `json:"performed_by"`
+}
+
+type Deposit struct {
+	Transaction
+}
+
+func (Deposit) Type() string { return TypeDeposit }
+
+type Withdrawal struct {
+	Transaction
+}
+
+func (Withdrawal) Type() string { return TypeWithdrawal }
+
+// PermissionsGranted has the codes of the permissions the user was given, not the ones they had
+type PermissionsGranted struct {
+	Codes []string `json:"codes"`
+}
+
+func (PermissionsGranted) Type() string { return TypePermissionsGranted }
@@ -0,0 +1,106 @@
+package event
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// RecordInTx saves the events to the outbox in the transaction that makes the change they are
+// about, so they are only ever published if it commits. their IDs and creation times are filled in
+func RecordInTx(ctx context.Context, tx *sql.Tx, events ...*Event) error {
+	query := `
+		INSERT INTO outbox (type, user_id, payload)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	for _, e := range events {
+		err := tx.QueryRowContext(ctx, query, e.Type, e.UserID, []byte(e.Payload)).Scan(
+			&e.ID,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetPending gets up to limit of the events that haven't been published and have failed fewer than
+// maxAttempts times, oldest first
+func (r *Repository) GetPending(limit, maxAttempts int) ([]*Event, error) {
+	query := `
+		SELECT id, created_at, type, user_id, payload, attempts, last_error, published_at
+		FROM outbox
+		WHERE published_at IS NULL AND attempts < $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, maxAttempts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []*Event
+	for rows.Next() {
+		e := &Event{}
+		err = rows.Scan(
+			&e.ID,
+			&e.CreatedAt,
+			&e.Type,
+			&e.UserID,
+			&e.Payload,
+			&e.Attempts,
+			&e.LastError,
+			&e.PublishedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// MarkPublished records that every subscriber got the event
+func (r *Repository) MarkPublished(eventID int64, publishedAt time.Time) error {
+	query := `
+		UPDATE outbox
+		SET published_at = $1
+		WHERE id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, publishedAt, eventID)
+	return err
+}
+
+// MarkFailed records that publishing the event failed and why, so it is tried again later
+func (r *Repository) MarkFailed(eventID int64, reason string) error {
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = $1
+		WHERE id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, reason, eventID)
+	return err
+}
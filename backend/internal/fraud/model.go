@@ -0,0 +1,229 @@
+package fraud
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// ErrHeldForReview is returned for an operation a rule holds for review. nothing is moved yet, the
+// money is set aside with a hold, and the operation is made when staff approve it
+var ErrHeldForReview = errors.New("held for review")
+
+// ErrRequestInReview is returned screening the payment of a payment request that already has a
+// payment held for review, it can only be paid again once that one is rejected
+var ErrRequestInReview = errors.New("payment request waiting for review")
+
+const (
+	KindTransfer   = "TRANSFER"
+	KindWithdrawal = "WITHDRAWAL"
+)
+
+// the rules, each is off when it has no action
+const (
+	// the amount is over a threshold
+	RuleLargeAmount = "LARGE_AMOUNT"
+	// the user has already made a number of transfers and withdrawals in the last few minutes
+	RuleVelocity = "VELOCITY"
+	// the first transfer from the user to someone else's account
+	RuleNewRecipient = "NEW_RECIPIENT"
+	// an account opened not long ago sending or withdrawing all of its balance
+	RuleNewAccountDrain = "NEW_ACCOUNT_DRAIN"
+)
+
+const (
+	ReviewPending  = "PENDING"
+	ReviewApproved = "APPROVED"
+	ReviewRejected = "REJECTED"
+)
+
+// Action is what a rule does with an operation that sets it off. the decision on an operation is
+// the strictest action of the rules it sets off, allow when there are none
+type Action string
+
+const (
+	// the operation goes through, the rule is only recorded
+	ActionAllow Action = "ALLOW"
+	// nothing is moved until staff approve the operation
+	ActionReview Action = "REVIEW"
+	ActionBlock  Action = "BLOCK"
+)
+
+// severity orders the actions from the least strict
+var severity = map[Action]int{"": 0, ActionAllow: 1, ActionReview: 2, ActionBlock: 3}
+
+// Set parses the action from a command line flag, so Action can be used with flag.Var. an empty
+// action turns the rule off
+func (a *Action) Set(s string) error {
+	if _, ok := severity[Action(s)]; !ok {
+		return fmt.Errorf("fraud action %q: must be ALLOW, REVIEW or BLOCK", s)
+	}
+	*a = Action(s)
+	return nil
+}
+
+func (a *Action) String() string {
+	if a == nil {
+		return ""
+	}
+	return string(*a)
+}
+
+// Rules are the rules operations are screened with and the action each takes. the counts, amounts
+// and ages set when a rule goes off, a rule with no action is off. the amounts are in Currency,
+// the default currency when it is not set, and operations in others count at the current rate
+type Rules struct {
+	Currency string
+
+	LargeAmount       money.Amount
+	LargeAmountAction Action
+
+	// set off by more than VelocityCount operations in VelocityWindow, counting the one screened
+	VelocityCount  int
+	VelocityWindow time.Duration
+	VelocityAction Action
+
+	NewRecipientAction Action
+
+	// accounts younger than NewAccountAge
+	NewAccountAge         time.Duration
+	NewAccountDrainAction Action
+}
+
+// Operation is a transfer or a withdrawal about to be made. Balance is the available balance of the
+// account the money leaves, and ToAccountID and ToUserID are who a transfer is going to. NoReview
+// is for operations that can't wait for a review, like batch rows and standing orders, one that
+// would be held is refused instead. the rest is kept to make the operation if it is held for
+// review and approved
+type Operation struct {
+	Kind             string
+	UserID           int64
+	AccountID        int64
+	AccountCreatedAt time.Time
+	Balance          money.Amount
+	ToAccountID      int64
+	ToUserID         int64
+	Amount           money.Amount
+	Currency         string
+	NoReview         bool
+
+	Memo             string
+	Reference        string
+	PerformedBy      string
+	PayeeID          *int64
+	PaymentRequestID *int64
+}
+
+// Facts are what is known about an operation when it is screened. Amount is its amount in the
+// currency of the rules, Recent is how many transfers and withdrawals the user made in the velocity
+// window, and NewRecipient is whether they never sent money to the account before
+type Facts struct {
+	Amount       money.Amount
+	Recent       int
+	NewRecipient bool
+}
+
+// Hit is a rule an operation set off, with the action it took and why it went off
+type Hit struct {
+	Rule   string `json:"rule"`
+	Action Action `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// Screening is the decision on an operation and the rules it set off. one held for review waits in
+// ReviewStatus PENDING, with its money set aside by the hold HoldID, until staff approve or reject
+// it. an approved one is made as they approve it, and the transfer or transaction it made is kept
+type Screening struct {
+	ID          int64        `json:"id"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Kind        string       `json:"kind"`
+	UserID      int64        `json:"user_id"`
+	AccountID   int64        `json:"account_id"`
+	ToAccountID *int64       `json:"to_account_id"`
+	Amount      money.Amount `json:"amount"`
+	Currency    string       `json:"currency"`
+	Decision    Action       `json:"decision"`
+	Hits        []*Hit       `json:"rules"`
+
+	Memo             string `json:"memo,omitempty"`
+	Reference        string `json:"reference,omitempty"`
+	PerformedBy      string `json:"performed_by,omitempty"`
+	PayeeID          *int64 `json:"payee_id,omitempty"`
+	PaymentRequestID *int64 `json:"payment_request_id,omitempty"`
+
+	ReviewStatus  string     `json:"review_status,omitempty"`
+	HoldID        *int64     `json:"hold_id,omitempty"`
+	ReviewedBy    *int64     `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote    string     `json:"review_note,omitempty"`
+	TransferID    *int64     `json:"transfer_id,omitempty"`
+	TransactionID *int64     `json:"transaction_id,omitempty"`
+}
+
+// Evaluate returns the rules the operation sets off at now
+func (r Rules) Evaluate(op *Operation, facts Facts, now time.Time) []*Hit {
+	var hits []*Hit
+	hit := func(rule string, action Action, reason string) {
+		if severity[action] > severity[""] {
+			hits = append(hits, &Hit{Rule: rule, Action: action, Reason: reason})
+		}
+	}
+
+	if r.LargeAmount > 0 && facts.Amount > r.LargeAmount {
+		hit(RuleLargeAmount, r.LargeAmountAction,
+			"over "+r.LargeAmount.String()+" "+r.currency())
+	}
+
+	if r.VelocityCount > 0 && facts.Recent+1 > r.VelocityCount {
+		hit(RuleVelocity, r.VelocityAction,
+			strconv.Itoa(facts.Recent+1)+" operations in "+r.VelocityWindow.String())
+	}
+
+	if op.Kind == KindTransfer && op.ToUserID != op.UserID && facts.NewRecipient {
+		hit(RuleNewRecipient, r.NewRecipientAction, "first transfer to the account")
+	}
+
+	if now.Sub(op.AccountCreatedAt) < r.NewAccountAge && op.Balance > 0 &&
+		op.Amount >= op.Balance {
+		hit(RuleNewAccountDrain, r.NewAccountDrainAction,
+			"all of the balance of an account opened "+op.AccountCreatedAt.Format(time.DateOnly))
+	}
+
+	return hits
+}
+
+// currency is the currency the amounts of the rules are in
+func (r Rules) currency() string {
+	if r.Currency == "" {
+		return money.DefaultCurrency
+	}
+	return r.Currency
+}
+
+// Decide is the strictest action of the hits, allow when there are none
+func Decide(hits []*Hit) Action {
+	decision := ActionAllow
+	for _, h := range hits {
+		if severity[h.Action] > severity[decision] {
+			decision = h.Action
+		}
+	}
+	return decision
+}
+
+func ValidateRules(v *validator.Validator, rules Rules) {
+	v.CheckAddError(
+		rules.Currency == "" || money.ValidCurrency(rules.Currency), "currency", "invalid",
+	)
+	v.CheckAddError(rules.LargeAmount >= 0, "large amount", "must not be negative")
+	v.CheckAddError(rules.VelocityCount >= 0, "velocity count", "must not be negative")
+	v.CheckAddError(
+		rules.VelocityCount == 0 || rules.VelocityWindow > 0, "velocity window",
+		"must be given with a velocity count",
+	)
+	v.CheckAddError(rules.NewAccountAge >= 0, "new account age", "must not be negative")
+}
@@ -0,0 +1,271 @@
+package fraud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/hold"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/lib/pq"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// Insert records the screening and the rules it set off. the hold, given for a screening held for
+// review, is placed with it, and is ledger.ErrInsufficientFunds when the money isn't there.
+// ErrRequestInReview is returned, with nothing saved, for the payment of a payment request that
+// has one waiting for review already
+func (r *Repository) Insert(screening *Screening, h *hold.Hold) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		if screening.PaymentRequestID != nil {
+			query := `
+				SELECT EXISTS (
+					SELECT 1 FROM fraud_screenings
+					WHERE payment_request_id = $1 AND review_status = 'PENDING'
+				)
+			`
+			var inReview bool
+			err := tx.QueryRowContext(ctx, query, *screening.PaymentRequestID).Scan(&inReview)
+			if err != nil {
+				return err
+			}
+			if inReview {
+				return ErrRequestInReview
+			}
+		}
+
+		if h != nil {
+			err := hold.PlaceInTx(ctx, tx, h)
+			if err != nil {
+				return err
+			}
+			screening.HoldID = &h.ID
+		}
+
+		query := `
+			INSERT INTO fraud_screenings (
+				kind, user_id, account_id, to_account_id, amount, currency, decision, memo,
+				reference, performed_by, payee_id, payment_request_id, review_status, hold_id
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''), $14)
+			RETURNING id, created_at
+		`
+		err := tx.QueryRowContext(
+			ctx, query, screening.Kind, screening.UserID, screening.AccountID,
+			screening.ToAccountID, screening.Amount, screening.Currency, screening.Decision,
+			screening.Memo, screening.Reference, screening.PerformedBy, screening.PayeeID,
+			screening.PaymentRequestID, screening.ReviewStatus, screening.HoldID,
+		).Scan(&screening.ID, &screening.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO fraud_rule_hits (screening_id, rule, action, reason)
+			VALUES ($1, $2, $3, $4)
+		`
+		for _, h := range screening.Hits {
+			_, err = tx.ExecContext(ctx, query, screening.ID, h.Rule, h.Action, h.Reason)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CountRecent counts the transfers the user sent and the withdrawals they made since. refunds and
+// reversals aren't counted, like for the limits
+func (r *Repository) CountRecent(userID int64, since time.Time) (int, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM transfers
+			WHERE from_user_id = $1 AND kind = 'TRANSFER' AND created_at > $2)
+			+ (SELECT COUNT(*) FROM transactions
+			WHERE user_id = $1 AND action = 'WITHDRAW' AND created_at > $2)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+	err := r.DB.QueryRowContext(ctx, query, userID, since).Scan(&count)
+	return count, err
+}
+
+// HasPaid reports whether the user ever sent a transfer to the account
+func (r *Repository) HasPaid(userID, toAccountID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM transfers
+			WHERE from_user_id = $1 AND to_account_id = $2 AND kind = 'TRANSFER'
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var paid bool
+	err := r.DB.QueryRowContext(ctx, query, userID, toAccountID).Scan(&paid)
+	return paid, err
+}
+
+const screeningColumns = `
+	id, created_at, kind, user_id, account_id, to_account_id, amount, currency, decision, memo,
+	reference, performed_by, payee_id, payment_request_id, COALESCE(review_status, ''), hold_id,
+	reviewed_by, reviewed_at, review_note, transfer_id, transaction_id
+`
+
+const selectScreenings = `SELECT ` + screeningColumns + ` FROM fraud_screenings`
+
+func scanScreening(row interface{ Scan(...any) error }) (*Screening, error) {
+	var s Screening
+	err := row.Scan(
+		&s.ID, &s.CreatedAt, &s.Kind, &s.UserID, &s.AccountID, &s.ToAccountID, &s.Amount,
+		&s.Currency, &s.Decision, &s.Memo, &s.Reference, &s.PerformedBy, &s.PayeeID,
+		&s.PaymentRequestID, &s.ReviewStatus, &s.HoldID, &s.ReviewedBy, &s.ReviewedAt,
+		&s.ReviewNote, &s.TransferID, &s.TransactionID,
+	)
+	return &s, err
+}
+
+func (r *Repository) Get(screeningID int64) (*Screening, error) {
+	query := selectScreenings + `
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	screening, err := scanScreening(r.DB.QueryRowContext(ctx, query, screeningID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return screening, nil
+}
+
+// GetReviewQueue gets the oldest screenings held for review with the review status, each
+// with the rules it set off
+func (r *Repository) GetReviewQueue(status string, limit int) ([]*Screening, error) {
+	query := selectScreenings + `
+		WHERE review_status = $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var screenings []*Screening
+	byID := make(map[int64]*Screening)
+	for rows.Next() {
+		s, err := scanScreening(rows)
+		if err != nil {
+			return nil, err
+		}
+		screenings = append(screenings, s)
+		byID[s.ID] = s
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(screenings) == 0 {
+		return screenings, nil
+	}
+
+	ids := make([]int64, 0, len(screenings))
+	for _, s := range screenings {
+		ids = append(ids, s.ID)
+	}
+	query = `
+		SELECT screening_id, rule, action, reason
+		FROM fraud_rule_hits
+		WHERE screening_id = ANY($1)
+		ORDER BY id
+	`
+	hitRows, err := r.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer hitRows.Close()
+	for hitRows.Next() {
+		var screeningID int64
+		h := &Hit{}
+		if err = hitRows.Scan(&screeningID, &h.Rule, &h.Action, &h.Reason); err != nil {
+			return nil, err
+		}
+		byID[screeningID].Hits = append(byID[screeningID].Hits, h)
+	}
+	if err = hitRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return screenings, nil
+}
+
+// Reject rejects a screening that is waiting for review and releases the money held for it.
+// user.ErrNoRecord is returned when there is no such screening waiting
+func (r *Repository) Reject(screening *Screening) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		return reviewTx(ctx, tx, screening)
+	})
+}
+
+// ApproveInTx approves a screening that is waiting for review in the caller's transaction, the one
+// its operation is made in, and releases the money held for it so it can pay for the operation.
+// the screening keeps the TransferID or TransactionID it is given. user.ErrNoRecord is returned
+// when there is no such screening waiting, and hold.ErrNotActive when the hold has expired
+func ApproveInTx(ctx context.Context, tx *sql.Tx, screening *Screening) error {
+	screening.ReviewStatus = ReviewApproved
+	return reviewTx(ctx, tx, screening)
+}
+
+// reviewTx saves the review of a screening waiting for one, and releases its hold
+func reviewTx(ctx context.Context, tx *sql.Tx, screening *Screening) error {
+	query := `
+		UPDATE fraud_screenings
+		SET review_status = $1, reviewed_by = $2, reviewed_at = $3, review_note = $4,
+			transfer_id = $5, transaction_id = $6
+		WHERE id = $7 AND review_status = 'PENDING'
+		RETURNING ` + screeningColumns
+	reviewed, err := scanScreening(tx.QueryRowContext(
+		ctx, query, screening.ReviewStatus, screening.ReviewedBy, screening.ReviewedAt,
+		screening.ReviewNote, screening.TransferID, screening.TransactionID, screening.ID,
+	))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return user.ErrNoRecord
+		default:
+			return err
+		}
+	}
+	reviewed.Hits = screening.Hits
+	*screening = *reviewed
+
+	if screening.HoldID == nil {
+		return nil
+	}
+	// an approved operation is paid with the money held for it, so the hold must still be there
+	if screening.ReviewStatus == ReviewApproved {
+		return hold.ReleaseActiveInTx(ctx, tx, *screening.HoldID)
+	}
+	return hold.ReleaseInTx(ctx, tx, *screening.HoldID)
+}
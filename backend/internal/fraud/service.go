@@ -0,0 +1,246 @@
+package fraud
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/hold"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+const (
+	// how long the money of an operation held for review stays set aside
+	holdExpiry = 7 * 24 * time.Hour
+	// how many screenings are listed at most
+	maxQueueListed = 100
+)
+
+type Repo interface {
+	Insert(screening *Screening, h *hold.Hold) error
+	CountRecent(userID int64, since time.Time) (int, error)
+	HasPaid(userID, toAccountID int64) (bool, error)
+	Get(screeningID int64) (*Screening, error)
+	GetReviewQueue(status string, limit int) ([]*Screening, error)
+	Reject(screening *Screening) error
+}
+
+// Maker makes the operations of one kind that staff approve, approving the screening with
+// ApproveInTx in the database transaction the operation is made in
+type Maker interface {
+	MakeApproved(v *validator.Validator, screening *Screening) error
+}
+
+type FX interface {
+	GetRate(fromCurrency, toCurrency string) (*fx.Rate, error)
+}
+
+// Service screens operations and takes the reviews of the ones held. FX converts the amounts of
+// operations in other currencies than the rules are in, it is only needed when there are some.
+// Makers make the approved operations by their kind, they are only needed for reviews
+type Service struct {
+	Repo   Repo
+	Rules  Rules
+	FX     FX
+	Makers map[string]Maker
+}
+
+// Screen checks the operation against the rules before it is made and records the decision. an
+// operation that is blocked is refused with an error added to the validator, and the rules don't
+// say why to the user. one that is held for review gets ErrHeldForReview, and its money is set
+// aside with a hold until staff review it, unless the operation can't wait for a review, then it
+// is blocked instead
+func (s *Service) Screen(v *validator.Validator, op *Operation) error {
+	now := time.Now()
+	var err error
+	facts := Facts{Amount: op.Amount}
+	if s.Rules.LargeAmount > 0 && s.Rules.LargeAmountAction != "" {
+		facts.Amount, err = s.convert(v, op.Amount, op.Currency)
+		if err != nil {
+			return err
+		}
+	}
+	if s.Rules.VelocityCount > 0 && s.Rules.VelocityAction != "" {
+		facts.Recent, err = s.Repo.CountRecent(op.UserID, now.Add(-s.Rules.VelocityWindow))
+		if err != nil {
+			return err
+		}
+	}
+	if op.Kind == KindTransfer && op.ToUserID != op.UserID && s.Rules.NewRecipientAction != "" {
+		paid, err := s.Repo.HasPaid(op.UserID, op.ToAccountID)
+		if err != nil {
+			return err
+		}
+		facts.NewRecipient = !paid
+	}
+
+	hits := s.Rules.Evaluate(op, facts, now)
+	screening := &Screening{
+		Kind:             op.Kind,
+		UserID:           op.UserID,
+		AccountID:        op.AccountID,
+		Amount:           op.Amount,
+		Currency:         op.Currency,
+		Decision:         Decide(hits),
+		Hits:             hits,
+		Memo:             op.Memo,
+		Reference:        op.Reference,
+		PerformedBy:      op.PerformedBy,
+		PayeeID:          op.PayeeID,
+		PaymentRequestID: op.PaymentRequestID,
+	}
+	if op.ToAccountID != 0 {
+		screening.ToAccountID = &op.ToAccountID
+	}
+	noReview := op.NoReview && screening.Decision == ActionReview
+	if noReview {
+		screening.Decision = ActionBlock
+	}
+	var h *hold.Hold
+	if screening.Decision == ActionReview {
+		screening.ReviewStatus = ReviewPending
+		h = &hold.Hold{
+			UserID:      op.UserID,
+			AccountID:   op.AccountID,
+			Currency:    op.Currency,
+			Kind:        hold.KindReview,
+			Amount:      op.Amount,
+			Description: "held for review",
+			PlacedBy:    "fraud screening",
+			ExpiresAt:   now.Add(holdExpiry),
+			Status:      hold.StatusActive,
+		}
+	}
+
+	err = s.Repo.Insert(screening, h)
+	if err != nil {
+		switch {
+		case errors.Is(err, ledger.ErrInsufficientFunds):
+			v.AddError("account balance", "insufficient funds")
+			return validator.ErrFailedValidation
+		case errors.Is(err, ErrRequestInReview):
+			v.AddError("payment request", "already has a payment waiting for review")
+			return validator.ErrFailedValidation
+		}
+		return err
+	}
+
+	switch screening.Decision {
+	case ActionBlock:
+		if noReview {
+			v.AddError("fraud screening", "needs a review, make it on its own to have it reviewed")
+			return validator.ErrFailedValidation
+		}
+		v.AddError("fraud screening", "refused, contact the bank")
+		return validator.ErrFailedValidation
+	case ActionReview:
+		return ErrHeldForReview
+	}
+
+	return nil
+}
+
+// convert converts the amount to the currency of the rules at the current rate, without the spread
+// the bank keeps on a real conversion
+func (s *Service) convert(
+	v *validator.Validator, amount money.Amount, currency string,
+) (money.Amount, error) {
+	if currency == s.Rules.currency() || amount == 0 {
+		return amount, nil
+	}
+
+	rate, err := s.FX.GetRate(currency, s.Rules.currency())
+	if err != nil {
+		if errors.Is(err, fx.ErrNoRate) {
+			v.AddError(
+				"currency", "no exchange rate from "+currency+" to "+s.Rules.currency()+
+					" to screen the amount in",
+			)
+			return 0, validator.ErrFailedValidation
+		}
+		return 0, err
+	}
+
+	return amount.Mul(rate.Rate.Rat(), money.RoundHalfEven), nil
+}
+
+// GetReviewQueue gets the oldest screenings held for review with the status, the ones still
+// waiting when none is given
+func (s *Service) GetReviewQueue(v *validator.Validator, status string) ([]*Screening, error) {
+	status = strings.ToUpper(strings.TrimSpace(status))
+	if status == "" {
+		status = ReviewPending
+	}
+	v.CheckAddError(
+		validator.ValueInList(status, ReviewPending, ReviewApproved, ReviewRejected),
+		"status", "must be PENDING, APPROVED or REJECTED",
+	)
+	if !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	return s.Repo.GetReviewQueue(status, maxQueueListed)
+}
+
+// Review approves or rejects the screening held for review. an approved operation is made as it is
+// approved, checked again as things are now, and the screening stays waiting if it can't be made,
+// like when its hold expired and the money is no longer set aside. a rejected one frees the money
+// held for it
+func (s *Service) Review(
+	v *validator.Validator, screeningID, reviewerID int64, approve bool, note string,
+) (*Screening, error) {
+	note = strings.TrimSpace(note)
+	v.CheckAddError(screeningID > 0, "screening id", "must be given")
+	v.CheckAddError(approve || note != "", "note", "must be given to reject")
+	v.CheckAddError(len(note) <= 500, "note", "must not be more than 500 bytes long")
+	if !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	now := time.Now()
+	if !approve {
+		screening := &Screening{
+			ID:           screeningID,
+			ReviewStatus: ReviewRejected,
+			ReviewedBy:   &reviewerID,
+			ReviewedAt:   &now,
+			ReviewNote:   note,
+		}
+		err := s.Repo.Reject(screening)
+		if err != nil {
+			return nil, err
+		}
+		return screening, nil
+	}
+
+	screening, err := s.Repo.Get(screeningID)
+	if err != nil {
+		return nil, err
+	}
+	if screening.ReviewStatus != ReviewPending {
+		return nil, user.ErrNoRecord
+	}
+	maker, ok := s.Makers[screening.Kind]
+	if !ok {
+		return nil, fmt.Errorf("fraud: nothing makes %s operations", screening.Kind)
+	}
+
+	screening.ReviewedBy = &reviewerID
+	screening.ReviewedAt = &now
+	screening.ReviewNote = note
+	err = maker.MakeApproved(v, screening)
+	if err != nil {
+		if errors.Is(err, hold.ErrNotActive) {
+			v.AddError("hold", "expired, the money is no longer set aside, reject the screening")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	return screening, nil
+}
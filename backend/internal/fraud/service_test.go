@@ -0,0 +1,471 @@
+package fraud
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/hold"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type MockRepo struct {
+	Recent    int
+	Paid      bool
+	InsertErr error
+
+	Inserted []*Screening
+	Holds    []*hold.Hold
+	Rejected *Screening
+}
+
+// Insert records the screening and the hold placed with it, Get and Reject know screening 1 waiting
+// for review and 2 already reviewed
+func (r *MockRepo) Insert(screening *Screening, h *hold.Hold) error {
+	if r.InsertErr != nil {
+		return r.InsertErr
+	}
+	screening.ID = int64(len(r.Inserted) + 1)
+	r.Inserted = append(r.Inserted, screening)
+	if h != nil {
+		r.Holds = append(r.Holds, h)
+	}
+	return nil
+}
+
+func (r *MockRepo) CountRecent(userID int64, since time.Time) (int, error) {
+	return r.Recent, nil
+}
+
+func (r *MockRepo) HasPaid(userID, toAccountID int64) (bool, error) {
+	return r.Paid, nil
+}
+
+func (r *MockRepo) Get(screeningID int64) (*Screening, error) {
+	switch screeningID {
+	case 1:
+		return &Screening{ID: 1, Kind: KindTransfer, ReviewStatus: ReviewPending}, nil
+	case 2:
+		return &Screening{ID: 2, Kind: KindTransfer, ReviewStatus: ReviewApproved}, nil
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (r *MockRepo) GetReviewQueue(status string, limit int) ([]*Screening, error) {
+	return nil, nil
+}
+
+func (r *MockRepo) Reject(screening *Screening) error {
+	if screening.ID != 1 {
+		return user.ErrNoRecord
+	}
+	r.Rejected = screening
+	return nil
+}
+
+// MockMaker approves the screenings it makes like ApproveInTx, unless it returns Err
+type MockMaker struct {
+	Err  error
+	Made []*Screening
+}
+
+func (m *MockMaker) MakeApproved(v *validator.Validator, screening *Screening) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	transferID := int64(100)
+	screening.ReviewStatus = ReviewApproved
+	screening.TransferID = &transferID
+	m.Made = append(m.Made, screening)
+	return nil
+}
+
+// MockFX knows the rate from EUR to USD only
+type MockFX struct{}
+
+func (f *MockFX) GetRate(fromCurrency, toCurrency string) (*fx.Rate, error) {
+	if fromCurrency != "EUR" || toCurrency != "USD" {
+		return nil, fx.ErrNoRate
+	}
+	return &fx.Rate{
+		BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: fx.MustParseDecimal("1.2"),
+	}, nil
+}
+
+var testRules = Rules{
+	LargeAmount:           money.MustParse("1000"),
+	LargeAmountAction:     ActionReview,
+	VelocityCount:         3,
+	VelocityWindow:        10 * time.Minute,
+	VelocityAction:        ActionBlock,
+	NewRecipientAction:    ActionAllow,
+	NewAccountAge:         7 * 24 * time.Hour,
+	NewAccountDrainAction: ActionReview,
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	transfer := func(amount string) *Operation {
+		return &Operation{
+			Kind: KindTransfer, UserID: 1, AccountID: 10, ToAccountID: 20, ToUserID: 2,
+			AccountCreatedAt: now.Add(-30 * 24 * time.Hour), Balance: money.MustParse("5000"),
+			Amount: money.MustParse(amount), Currency: "USD",
+		}
+	}
+
+	tests := []struct {
+		name         string
+		rules        Rules
+		op           *Operation
+		facts        Facts
+		wantRules    []string
+		wantDecision Action
+	}{
+		{
+			name:         "nothing set off",
+			rules:        testRules,
+			op:           transfer("50"),
+			wantDecision: ActionAllow,
+		},
+		{
+			name:         "large amount",
+			rules:        testRules,
+			op:           transfer("1000.01"),
+			wantRules:    []string{RuleLargeAmount},
+			wantDecision: ActionReview,
+		},
+		{
+			name:         "right at the threshold",
+			rules:        testRules,
+			op:           transfer("1000"),
+			wantDecision: ActionAllow,
+		},
+		{
+			name:         "too many at once",
+			rules:        testRules,
+			op:           transfer("50"),
+			facts:        Facts{Recent: 3},
+			wantRules:    []string{RuleVelocity},
+			wantDecision: ActionBlock,
+		},
+		{
+			name:         "the last one allowed at once",
+			rules:        testRules,
+			op:           transfer("50"),
+			facts:        Facts{Recent: 2},
+			wantDecision: ActionAllow,
+		},
+		{
+			name:         "new recipient is only recorded",
+			rules:        testRules,
+			op:           transfer("50"),
+			facts:        Facts{NewRecipient: true},
+			wantRules:    []string{RuleNewRecipient},
+			wantDecision: ActionAllow,
+		},
+		{
+			name:  "own account is not a new recipient",
+			rules: testRules,
+			op: func() *Operation {
+				op := transfer("50")
+				op.ToUserID = op.UserID
+				return op
+			}(),
+			facts:        Facts{NewRecipient: true},
+			wantDecision: ActionAllow,
+		},
+		{
+			name:  "new account sending all of it",
+			rules: testRules,
+			op: func() *Operation {
+				op := transfer("200")
+				op.AccountCreatedAt = now.Add(-time.Hour)
+				op.Balance = money.MustParse("200")
+				return op
+			}(),
+			wantRules:    []string{RuleNewAccountDrain},
+			wantDecision: ActionReview,
+		},
+		{
+			name:  "old account sending all of it",
+			rules: testRules,
+			op: func() *Operation {
+				op := transfer("200")
+				op.Balance = money.MustParse("200")
+				return op
+			}(),
+			wantDecision: ActionAllow,
+		},
+		{
+			name:  "withdrawal has no recipient",
+			rules: testRules,
+			op: &Operation{
+				Kind: KindWithdrawal, UserID: 1, AccountID: 10, AccountCreatedAt: now,
+				Balance: money.MustParse("5000"), Amount: money.MustParse("2000"),
+			},
+			facts:        Facts{NewRecipient: true, Recent: 5},
+			wantRules:    []string{RuleLargeAmount, RuleVelocity},
+			wantDecision: ActionBlock,
+		},
+		{
+			name:         "rules turned off",
+			rules:        Rules{LargeAmount: money.MustParse("10"), VelocityCount: 1},
+			op:           transfer("50"),
+			facts:        Facts{Recent: 4, NewRecipient: true},
+			wantDecision: ActionAllow,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// the operations are in the currency of the rules
+			facts := tc.facts
+			facts.Amount = tc.op.Amount
+			hits := tc.rules.Evaluate(tc.op, facts, now)
+
+			var rules []string
+			for _, h := range hits {
+				rules = append(rules, h.Rule)
+			}
+			if !slices.Equal(rules, tc.wantRules) {
+				t.Errorf("expected rules %v, got %v", tc.wantRules, rules)
+			}
+			if decision := Decide(hits); decision != tc.wantDecision {
+				t.Errorf("expected decision %s, got %s", tc.wantDecision, decision)
+			}
+		})
+	}
+}
+
+func TestScreen(t *testing.T) {
+	op := &Operation{
+		Kind: KindTransfer, UserID: 1, AccountID: 10, ToAccountID: 20, ToUserID: 2,
+		AccountCreatedAt: time.Now().Add(-30 * 24 * time.Hour), Balance: money.MustParse("5000"),
+		Currency: "USD", Memo: "rent",
+	}
+
+	tests := []struct {
+		name             string
+		amount           string
+		currency         string
+		noReview         bool
+		repo             *MockRepo
+		wantErr          error
+		wantErrKey       string
+		wantReviewStatus string
+		wantInserted     int
+	}{
+		{
+			name:         "allowed",
+			amount:       "50",
+			repo:         &MockRepo{Paid: true},
+			wantInserted: 1,
+		},
+		{
+			name:             "held for review",
+			amount:           "1500",
+			repo:             &MockRepo{Paid: true},
+			wantErr:          ErrHeldForReview,
+			wantReviewStatus: ReviewPending,
+			wantInserted:     1,
+		},
+		{
+			name:             "large in the currency of the rules",
+			amount:           "900",
+			currency:         "EUR",
+			repo:             &MockRepo{Paid: true},
+			wantErr:          ErrHeldForReview,
+			wantReviewStatus: ReviewPending,
+			wantInserted:     1,
+		},
+		{
+			name:       "no rate to the currency of the rules",
+			amount:     "50",
+			currency:   "GBP",
+			repo:       &MockRepo{Paid: true},
+			wantErr:    validator.ErrFailedValidation,
+			wantErrKey: "currency",
+		},
+		{
+			name:       "payment request already in review",
+			amount:     "50",
+			repo:       &MockRepo{Paid: true, InsertErr: ErrRequestInReview},
+			wantErr:    validator.ErrFailedValidation,
+			wantErrKey: "payment request",
+		},
+		{
+			name:         "needs a review it can't wait for",
+			amount:       "1500",
+			noReview:     true,
+			repo:         &MockRepo{Paid: true},
+			wantErr:      validator.ErrFailedValidation,
+			wantErrKey:   "fraud screening",
+			wantInserted: 1,
+		},
+		{
+			name:         "blocked",
+			amount:       "50",
+			repo:         &MockRepo{Paid: true, Recent: 3},
+			wantErr:      validator.ErrFailedValidation,
+			wantErrKey:   "fraud screening",
+			wantInserted: 1,
+		},
+		{
+			name:       "no money to hold",
+			amount:     "1500",
+			repo:       &MockRepo{Paid: true, InsertErr: ledger.ErrInsufficientFunds},
+			wantErr:    validator.ErrFailedValidation,
+			wantErrKey: "account balance",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Service{Repo: tc.repo, Rules: testRules, FX: &MockFX{}}
+			op := *op
+			op.Amount = money.MustParse(tc.amount)
+			if tc.currency != "" {
+				op.Currency = tc.currency
+			}
+			op.NoReview = tc.noReview
+			v := validator.New()
+
+			err := s.Screen(v, &op)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if _, ok := v.Errors[tc.wantErrKey]; tc.wantErrKey != "" && !ok {
+				t.Errorf("expected a %s error, got %v", tc.wantErrKey, v.Errors)
+			}
+			if len(tc.repo.Inserted) != tc.wantInserted {
+				t.Fatalf("expected %d screenings recorded, got %d", tc.wantInserted,
+					len(tc.repo.Inserted))
+			}
+			if tc.wantInserted == 0 {
+				return
+			}
+			screening := tc.repo.Inserted[0]
+			if screening.ReviewStatus != tc.wantReviewStatus {
+				t.Errorf("expected review status %q, got %q", tc.wantReviewStatus,
+					screening.ReviewStatus)
+			}
+			if screening.ToAccountID == nil || *screening.ToAccountID != op.ToAccountID {
+				t.Errorf("expected the recipient recorded, got %v", screening.ToAccountID)
+			}
+			if screening.Memo != op.Memo {
+				t.Errorf("expected the memo kept to make the operation, got %q", screening.Memo)
+			}
+
+			// only the money of an operation held for review is set aside
+			held := tc.wantReviewStatus == ReviewPending
+			if held != (len(tc.repo.Holds) == 1) {
+				t.Fatalf("expected held %v, got holds %+v", held, tc.repo.Holds)
+			}
+			if held && (tc.repo.Holds[0].Amount != op.Amount ||
+				tc.repo.Holds[0].Kind != hold.KindReview || tc.repo.Holds[0].AccountID != 10) {
+				t.Errorf("expected %v held on account 10, got %+v", op.Amount, tc.repo.Holds[0])
+			}
+		})
+	}
+}
+
+func TestReview(t *testing.T) {
+	errDB := errors.New("database error")
+
+	tests := []struct {
+		name        string
+		screeningID int64
+		approve     bool
+		note        string
+		maker       *MockMaker
+		wantErr     error
+		wantStatus  string
+		wantMade    bool
+	}{
+		{
+			name:        "approve",
+			screeningID: 1,
+			approve:     true,
+			maker:       &MockMaker{},
+			wantStatus:  ReviewApproved,
+			wantMade:    true,
+		},
+		{
+			name:        "approved operation cannot be made",
+			screeningID: 1,
+			approve:     true,
+			maker:       &MockMaker{Err: validator.ErrFailedValidation},
+			wantErr:     validator.ErrFailedValidation,
+		},
+		{
+			name:        "hold expired",
+			screeningID: 1,
+			approve:     true,
+			maker:       &MockMaker{Err: hold.ErrNotActive},
+			wantErr:     validator.ErrFailedValidation,
+		},
+		{
+			name:        "maker failure",
+			screeningID: 1,
+			approve:     true,
+			maker:       &MockMaker{Err: errDB},
+			wantErr:     errDB,
+		},
+		{
+			name:        "reject",
+			screeningID: 1,
+			note:        "the customer didn't make it",
+			maker:       &MockMaker{},
+			wantStatus:  ReviewRejected,
+		},
+		{
+			name:        "reject without a note",
+			screeningID: 1,
+			maker:       &MockMaker{},
+			wantErr:     validator.ErrFailedValidation,
+		},
+		{
+			name:        "already reviewed",
+			screeningID: 2,
+			approve:     true,
+			maker:       &MockMaker{},
+			wantErr:     user.ErrNoRecord,
+		},
+		{
+			name:        "not found",
+			screeningID: 3,
+			approve:     true,
+			maker:       &MockMaker{},
+			wantErr:     user.ErrNoRecord,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			s := &Service{Repo: repo, Makers: map[string]Maker{KindTransfer: tc.maker}}
+
+			screening, err := s.Review(validator.New(), tc.screeningID, 9, tc.approve, tc.note)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if made := len(tc.maker.Made) == 1; made != tc.wantMade {
+				t.Errorf("expected the operation made %v, got %v", tc.wantMade, made)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+			if screening.ReviewStatus != tc.wantStatus || *screening.ReviewedBy != 9 {
+				t.Errorf("expected %s by 9, got %+v", tc.wantStatus, screening)
+			}
+			if tc.wantMade && screening.TransferID == nil {
+				t.Errorf("expected the transfer made kept, got %+v", screening)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package fx
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// the decimal places a rate is stored and shown to, as in the NUMERIC columns
+const decimalPlaces = 10
+
+// Decimal is an exact rate or fraction, such as an exchange rate or a spread. it is kept as a
+// fraction, so an inverted rate converts exactly, and only rounded to decimalPlaces when it is
+// stored or shown. the zero value is 0
+type Decimal struct {
+	r *big.Rat
+}
+
+// NewDecimal returns the fraction as a Decimal
+func NewDecimal(r *big.Rat) Decimal {
+	return Decimal{r: new(big.Rat).Set(r)}
+}
+
+// ParseDecimal parses a decimal number such as "0.92"
+func ParseDecimal(s string) (Decimal, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{r: r}, nil
+}
+
+// MustParseDecimal is ParseDecimal for constants, it panics when s is not a decimal
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Rat returns the exact value, a copy that can be changed
+func (d Decimal) Rat() *big.Rat {
+	if d.r == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(d.r)
+}
+
+func (d Decimal) Sign() int {
+	if d.r == nil {
+		return 0
+	}
+	return d.r.Sign()
+}
+
+// Cmp compares the decimal to the other one, like big.Rat.Cmp
+func (d Decimal) Cmp(other Decimal) int {
+	return d.Rat().Cmp(other.Rat())
+}
+
+// Inverse returns 1 divided by the decimal, exactly. it must not be 0
+func (d Decimal) Inverse() Decimal {
+	return Decimal{r: new(big.Rat).Inv(d.Rat())}
+}
+
+// String returns the decimal rounded to decimalPlaces without the trailing zeros
+func (d Decimal) String() string {
+	s := d.Rat().FloatString(decimalPlaces)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON reads the decimal from a JSON number or a string holding one
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseDecimal(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan reads the decimal from a NUMERIC column
+func (d *Decimal) Scan(src any) error {
+	switch value := src.(type) {
+	case []byte:
+		return d.UnmarshalJSON(value)
+	case string:
+		return d.UnmarshalJSON([]byte(value))
+	case int64:
+		*d = Decimal{r: new(big.Rat).SetInt64(value)}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into a decimal", src)
+	}
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
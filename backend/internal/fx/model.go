@@ -0,0 +1,62 @@
+package fx
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// Rate is what one unit of the base currency buys in the quote currency from EffectiveAt onwards.
+// Spread is the fraction of every converted amount the bank keeps, e.g. 0.01 for 1%. both are
+// exact, so converting never goes through a float
+type Rate struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	Rate          Decimal   `json:"rate"`
+	Spread        Decimal   `json:"spread"`
+	EffectiveAt   time.Time `json:"effective_at"`
+}
+
+// Invert returns the rate for converting the other way, from the quote currency to the base. the
+// inverse is exact, 1/3 stays a third rather than 0.3333333333
+func (r *Rate) Invert() *Rate {
+	return &Rate{
+		ID:            r.ID,
+		CreatedAt:     r.CreatedAt,
+		BaseCurrency:  r.QuoteCurrency,
+		QuoteCurrency: r.BaseCurrency,
+		Rate:          r.Rate.Inverse(),
+		Spread:        r.Spread,
+		EffectiveAt:   r.EffectiveAt,
+	}
+}
+
+// Convert converts an amount of the base currency to the quote currency. converted is what the
+// customer gets and spread is what the bank keeps, together they are the amount at the plain rate.
+// the customer's part is rounded down so the bank never pays out a fraction of a cent it didn't get
+func (r *Rate) Convert(amount money.Amount) (converted, spread money.Amount) {
+	rate := r.Rate.Rat()
+	full := amount.Mul(rate, money.RoundHalfEven)
+
+	kept := new(big.Rat).Sub(big.NewRat(1, 1), r.Spread.Rat())
+	converted = amount.Mul(new(big.Rat).Mul(rate, kept), money.RoundDown)
+
+	return converted, full - converted
+}
+
+func ValidateRate(v *validator.Validator, rate *Rate) {
+	v.CheckAddError(money.ValidCurrency(rate.BaseCurrency), "base currency", "invalid")
+	v.CheckAddError(money.ValidCurrency(rate.QuoteCurrency), "quote currency", "invalid")
+	v.CheckAddError(
+		rate.BaseCurrency != rate.QuoteCurrency, "quote currency", "must differ from the base currency",
+	)
+	v.CheckAddError(rate.Rate.Sign() > 0, "rate", "must be more than 0")
+	v.CheckAddError(
+		rate.Spread.Sign() >= 0 && rate.Spread.Cmp(MustParseDecimal("1")) < 0, "spread",
+		"must be between 0 and 1",
+	)
+}
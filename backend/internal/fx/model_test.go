@@ -0,0 +1,111 @@
+package fx
+
+import (
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name          string
+		rate          *Rate
+		amount        money.Amount
+		wantConverted money.Amount
+		wantSpread    money.Amount
+	}{
+		{
+			name: "no spread",
+			rate: &Rate{
+				BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: MustParseDecimal("0.92"),
+			},
+			amount:        money.MustParse("100"),
+			wantConverted: money.MustParse("92"),
+		},
+		{
+			name: "with spread",
+			rate: &Rate{
+				BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: MustParseDecimal("0.92"),
+				Spread: MustParseDecimal("0.01"),
+			},
+			amount:        money.MustParse("100"),
+			wantConverted: money.MustParse("91.08"),
+			wantSpread:    money.MustParse("0.92"),
+		},
+		{
+			name: "customer part rounded down",
+			rate: &Rate{
+				BaseCurrency: "USD", QuoteCurrency: "KES", Rate: MustParseDecimal("129.3333"),
+			},
+			amount:        money.MustParse("0.10"),
+			wantConverted: money.MustParse("12.93"),
+			wantSpread:    money.MustParse("0"),
+		},
+		{
+			name: "spread takes the rounding",
+			rate: &Rate{
+				BaseCurrency: "USD", QuoteCurrency: "GBP", Rate: MustParseDecimal("0.79"),
+				Spread: MustParseDecimal("0.005"),
+			},
+			amount:        money.MustParse("10.01"),
+			wantConverted: money.MustParse("7.86"),
+			wantSpread:    money.MustParse("0.05"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gotConverted, gotSpread := tc.rate.Convert(tc.amount)
+			if gotConverted != tc.wantConverted || gotSpread != tc.wantSpread {
+				t.Errorf(
+					"expected converted=%v spread=%v, got converted=%v spread=%v",
+					tc.wantConverted, tc.wantSpread, gotConverted, gotSpread,
+				)
+			}
+		})
+	}
+}
+
+func TestInvert(t *testing.T) {
+	rate := &Rate{
+		BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: MustParseDecimal("0.8"),
+		Spread: MustParseDecimal("0.01"),
+	}
+	inverted := rate.Invert()
+	if inverted.BaseCurrency != "EUR" || inverted.QuoteCurrency != "USD" {
+		t.Fatalf("expected EUR/USD, got %s/%s", inverted.BaseCurrency, inverted.QuoteCurrency)
+	}
+	if inverted.Rate.Cmp(MustParseDecimal("1.25")) != 0 || inverted.Spread.Cmp(rate.Spread) != 0 {
+		t.Errorf("unexpected inverted rate %+v", inverted)
+	}
+}
+
+func TestInvertIsExact(t *testing.T) {
+	rate := &Rate{BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: MustParseDecimal("3")}
+
+	// a third rounded to 10 places would lose a cent somewhere in a billion
+	converted, _ := rate.Invert().Convert(money.MustParse("3000000000"))
+	if converted != money.MustParse("1000000000") {
+		t.Errorf("expected 1000000000, got %v", converted)
+	}
+	if got := rate.Invert().Rate.String(); got != "0.3333333333" {
+		t.Errorf("expected the rate shown to 10 places, got %s", got)
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	for _, input := range []string{`0.92`, `"0.92"`} {
+		var d Decimal
+		if err := d.UnmarshalJSON([]byte(input)); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if out, _ := d.MarshalJSON(); string(out) != "0.92" {
+			t.Errorf("%s: expected 0.92 back, got %s", input, out)
+		}
+	}
+
+	var d Decimal
+	if err := d.UnmarshalJSON([]byte(`"abc"`)); err == nil {
+		t.Error("expected an error for a string that isn't a number")
+	}
+}
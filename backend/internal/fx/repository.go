@@ -0,0 +1,84 @@
+package fx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// InsertAllTx loads the rates in one database transaction, so a batch of rates is never half loaded
+func (r *Repository) InsertAllTx(rates []*Rate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// in case of any issues
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO exchange_rates (base_currency, quote_currency, rate, spread, effective_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	for _, rate := range rates {
+		err = tx.QueryRowContext(
+			ctx, query,
+			rate.BaseCurrency,
+			rate.QuoteCurrency,
+			rate.Rate,
+			rate.Spread,
+			rate.EffectiveAt,
+		).Scan(&rate.ID, &rate.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetEffective gets the rate for the pair that applies at the given time, the latest one that had
+// taken effect by then
+func (r *Repository) GetEffective(baseCurrency, quoteCurrency string, at time.Time) (*Rate, error) {
+	query := `
+		SELECT id, created_at, base_currency, quote_currency, rate, spread, effective_at
+		FROM exchange_rates
+		WHERE base_currency = $1 AND quote_currency = $2 AND effective_at <= $3
+		ORDER BY effective_at DESC, id DESC
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rate := &Rate{}
+	err := r.DB.QueryRowContext(ctx, query, baseCurrency, quoteCurrency, at).Scan(
+		&rate.ID,
+		&rate.CreatedAt,
+		&rate.BaseCurrency,
+		&rate.QuoteCurrency,
+		&rate.Rate,
+		&rate.Spread,
+		&rate.EffectiveAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return rate, nil
+}
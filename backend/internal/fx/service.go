@@ -0,0 +1,69 @@
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+var ErrNoRate = errors.New("no exchange rate for the currencies")
+
+type Repo interface {
+	InsertAllTx(rates []*Rate) error
+	GetEffective(baseCurrency, quoteCurrency string, at time.Time) (*Rate, error)
+}
+
+type Service struct {
+	Repo Repo
+}
+
+// LoadRates validates and stores a batch of rates. rates without an effective time take effect
+// straight away
+func (s *Service) LoadRates(v *validator.Validator, rates []*Rate) error {
+	v.CheckAddError(len(rates) > 0, "rates", "must be given")
+
+	now := time.Now()
+	for i, rate := range rates {
+		if rate.EffectiveAt.IsZero() {
+			rate.EffectiveAt = now
+		}
+
+		rateV := validator.New()
+		ValidateRate(rateV, rate)
+		for key, message := range rateV.Errors {
+			v.AddError(fmt.Sprintf("rates[%d] %s", i, key), message)
+		}
+	}
+	if !v.IsValid() {
+		return validator.ErrFailedValidation
+	}
+
+	return s.Repo.InsertAllTx(rates)
+}
+
+// GetRate gets the rate in effect now for converting from one currency to the other. a rate loaded
+// only for the opposite direction is inverted
+func (s *Service) GetRate(fromCurrency, toCurrency string) (*Rate, error) {
+	now := time.Now()
+
+	rate, err := s.Repo.GetEffective(fromCurrency, toCurrency, now)
+	if err == nil {
+		return rate, nil
+	}
+	if !errors.Is(err, user.ErrNoRecord) {
+		return nil, err
+	}
+
+	rate, err = s.Repo.GetEffective(toCurrency, fromCurrency, now)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			return nil, ErrNoRate
+		}
+		return nil, err
+	}
+
+	return rate.Invert(), nil
+}
@@ -0,0 +1,218 @@
+package fx
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// ---MOCKS---
+
+type MockRepo struct {
+	InsertAllTxErr error
+	Inserted       []*Rate
+
+	// Rates are the rates in effect, keyed by base and quote currency, e.g. "USD/EUR"
+	Rates           map[string]*Rate
+	GetEffectiveErr error
+}
+
+func (r *MockRepo) InsertAllTx(rates []*Rate) error {
+	if r.InsertAllTxErr != nil {
+		return r.InsertAllTxErr
+	}
+	r.Inserted = append(r.Inserted, rates...)
+	return nil
+}
+
+func (r *MockRepo) GetEffective(baseCurrency, quoteCurrency string, at time.Time) (*Rate, error) {
+	if r.GetEffectiveErr != nil {
+		return nil, r.GetEffectiveErr
+	}
+	rate, ok := r.Rates[baseCurrency+"/"+quoteCurrency]
+	if !ok {
+		return nil, user.ErrNoRecord
+	}
+	return rate, nil
+}
+
+func TestLoadRates(t *testing.T) {
+	tests := []struct {
+		name        string
+		setupRepo   func(*MockRepo)
+		rates       []*Rate
+		wantErrKey  string
+		expectedErr error
+	}{
+		{
+			name:      "valid",
+			setupRepo: func(r *MockRepo) {},
+			rates: []*Rate{
+				{
+					BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: MustParseDecimal("0.92"),
+					Spread: MustParseDecimal("0.01"),
+				},
+				{BaseCurrency: "USD", QuoteCurrency: "GBP", Rate: MustParseDecimal("0.79")},
+			},
+		},
+		{
+			name:        "no rates",
+			setupRepo:   func(r *MockRepo) {},
+			wantErrKey:  "rates",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "unknown currency",
+			setupRepo: func(r *MockRepo) {},
+			rates: []*Rate{
+				{BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: MustParseDecimal("0.92")},
+				{BaseCurrency: "USD", QuoteCurrency: "XXX", Rate: MustParseDecimal("1")},
+			},
+			wantErrKey:  "rates[1] quote currency",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "same currency",
+			setupRepo: func(r *MockRepo) {},
+			rates: []*Rate{
+				{BaseCurrency: "USD", QuoteCurrency: "USD", Rate: MustParseDecimal("1")},
+			},
+			wantErrKey:  "rates[0] quote currency",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "zero rate",
+			setupRepo: func(r *MockRepo) {},
+			rates: []*Rate{
+				{BaseCurrency: "USD", QuoteCurrency: "EUR"},
+			},
+			wantErrKey:  "rates[0] rate",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "spread of 100%",
+			setupRepo: func(r *MockRepo) {},
+			rates: []*Rate{
+				{
+					BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: MustParseDecimal("0.92"),
+					Spread: MustParseDecimal("1"),
+				},
+			},
+			wantErrKey:  "rates[0] spread",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "InsertAllTx failure",
+			setupRepo: func(r *MockRepo) {
+				r.InsertAllTxErr = errors.New("db error")
+			},
+			rates: []*Rate{
+				{BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: MustParseDecimal("0.92")},
+			},
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			tc.setupRepo(repo)
+			svc := Service{Repo: repo}
+
+			v := validator.New()
+			gotErr := svc.LoadRates(v, tc.rates)
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				if _, ok := v.Errors[tc.wantErrKey]; tc.wantErrKey != "" && !ok {
+					t.Errorf("expected an error for %q, got %v", tc.wantErrKey, v.Errors)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if len(repo.Inserted) != len(tc.rates) {
+				t.Fatalf("expected %d rates to be inserted, got %d", len(tc.rates), len(repo.Inserted))
+			}
+			for _, rate := range repo.Inserted {
+				if rate.EffectiveAt.IsZero() {
+					t.Errorf("expected the rate to take effect now, got %v", rate.EffectiveAt)
+				}
+			}
+		})
+	}
+}
+
+func TestGetRate(t *testing.T) {
+	rates := map[string]*Rate{
+		"USD/EUR": {BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: MustParseDecimal("0.8")},
+	}
+
+	tests := []struct {
+		name        string
+		setupRepo   func(*MockRepo)
+		from        string
+		to          string
+		wantRate    string
+		expectedErr error
+	}{
+		{
+			name:      "direct",
+			setupRepo: func(r *MockRepo) { r.Rates = rates },
+			from:      "USD",
+			to:        "EUR",
+			wantRate:  "0.8",
+		},
+		{
+			name:      "inverted",
+			setupRepo: func(r *MockRepo) { r.Rates = rates },
+			from:      "EUR",
+			to:        "USD",
+			wantRate:  "1.25",
+		},
+		{
+			name:        "no rate",
+			setupRepo:   func(r *MockRepo) { r.Rates = rates },
+			from:        "USD",
+			to:          "GBP",
+			expectedErr: ErrNoRate,
+		},
+		{
+			name: "GetEffective failure",
+			setupRepo: func(r *MockRepo) {
+				r.GetEffectiveErr = errors.New("db error")
+			},
+			from:        "USD",
+			to:          "EUR",
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			tc.setupRepo(repo)
+			svc := Service{Repo: repo}
+
+			rate, gotErr := svc.GetRate(tc.from, tc.to)
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if rate.BaseCurrency != tc.from || rate.QuoteCurrency != tc.to ||
+				rate.Rate.Cmp(MustParseDecimal(tc.wantRate)) != 0 {
+				t.Errorf("unexpected rate %+v", rate)
+			}
+		})
+	}
+}
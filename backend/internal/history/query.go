@@ -0,0 +1,239 @@
+package history
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// the orders the rows can be sorted in, a - in front for the largest or newest first
+var sorts = []string{"-created_at", "created_at", "-amount", "amount"}
+
+// Query is what one of the history endpoints is asked for, all of it optional. From and To are the
+// days the rows were made on and MinAmount and MaxAmount the range of their amounts, all included.
+// Counterparty is the account number or email of the other side, Status the status or action of
+// the rows and Category one of the user's categories. Sort is the order, newest first when none is
+// given, and Cursor the NextCursor of the page before
+type Query struct {
+	From         *time.Time
+	To           *time.Time
+	MinAmount    *money.Amount
+	MaxAmount    *money.Amount
+	Counterparty string
+	Status       string
+	Category     string
+	Sort         string
+	Cursor       string
+	PageSize     int
+
+	// the row the page starts after, read from the cursor
+	after *cursor
+}
+
+// Page is about the page of rows a query got. Total is how many rows there are on all the pages
+// together, and NextCursor is set while there is a page after this one
+type Page struct {
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Table is how history queries are run on one table. the columns are qualified when the rows are
+// read with other tables joined. Counterparty and Category are the conditions the filters add, with
+// %[1]s where the value goes, and a table without one can't be filtered by it. the user's ID is
+// always $1
+type Table struct {
+	ID           string
+	CreatedAt    string
+	Amount       string
+	Status       string
+	Statuses     []string
+	Counterparty string
+	Category     string
+}
+
+// ValidateQuery checks the query can be run on the table and fills in the defaults. the status is
+// matched ignoring case and set to the one the table has
+func ValidateQuery(v *validator.Validator, q *Query, t Table) {
+	if q.From != nil && q.To != nil {
+		v.CheckAddError(!q.To.Before(*q.From), "to", "must not be before from")
+	}
+	v.CheckAddError(q.MinAmount == nil || *q.MinAmount >= 0, "min amount", "must not be negative")
+	if q.MinAmount != nil && q.MaxAmount != nil {
+		v.CheckAddError(
+			*q.MaxAmount >= *q.MinAmount, "max amount", "must not be less than min amount",
+		)
+	}
+
+	q.Counterparty = strings.TrimSpace(q.Counterparty)
+	v.CheckAddError(
+		q.Counterparty == "" || t.Counterparty != "", "counterparty", "cannot be filtered on here",
+	)
+	q.Category = strings.TrimSpace(q.Category)
+	v.CheckAddError(q.Category == "" || t.Category != "", "category", "cannot be filtered on here")
+	if q.Status != "" {
+		status, ok := "", false
+		for _, s := range t.Statuses {
+			if strings.EqualFold(s, strings.TrimSpace(q.Status)) {
+				status, ok = s, true
+			}
+		}
+		v.CheckAddError(ok, "status", "must be one of "+strings.Join(t.Statuses, ", "))
+		q.Status = status
+	}
+
+	if q.Sort == "" {
+		q.Sort = sorts[0]
+	}
+	v.CheckAddError(
+		validator.ValueInList(q.Sort, sorts...), "sort",
+		"must be one of "+strings.Join(sorts, ", "),
+	)
+	if q.PageSize == 0 {
+		q.PageSize = DefaultPageSize
+	}
+	v.CheckAddError(
+		q.PageSize > 0 && q.PageSize <= MaxPageSize, "page size",
+		fmt.Sprintf("must be between 1 and %d", MaxPageSize),
+	)
+
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor)
+		// a cursor only goes on from where the same order left off
+		v.CheckAddError(err == nil && after.sort == q.Sort, "cursor", "invalid")
+		q.after = after
+	}
+}
+
+// Filter returns the conditions that keep the rows of the table the query asks for, each starting
+// with AND, and args with their values added
+func (t Table) Filter(q *Query, args []any) (string, []any) {
+	var b strings.Builder
+	add := func(condition string, value any) {
+		args = append(args, value)
+		b.WriteString(" AND " + fmt.Sprintf(condition, "$"+strconv.Itoa(len(args))))
+	}
+
+	if q.From != nil {
+		add(t.CreatedAt+" >= %s", day(*q.From))
+	}
+	if q.To != nil {
+		add(t.CreatedAt+" < %s", day(*q.To).AddDate(0, 0, 1))
+	}
+	if q.MinAmount != nil {
+		add(t.Amount+" >= %s", *q.MinAmount)
+	}
+	if q.MaxAmount != nil {
+		add(t.Amount+" <= %s", *q.MaxAmount)
+	}
+	if q.Status != "" {
+		add(t.Status+" = %s", q.Status)
+	}
+	if q.Counterparty != "" {
+		add(t.Counterparty, q.Counterparty)
+	}
+	if q.Category != "" {
+		add(t.Category, q.Category)
+	}
+
+	return b.String(), args
+}
+
+// Seek returns the condition that skips the rows up to the cursor, which is empty on the first
+// page, and the ORDER BY and LIMIT clauses of the page, with their values added to args. one row
+// more than the page size is got, to tell whether there is another page
+func (t Table) Seek(q *Query, args []any) (string, string, []any) {
+	column, direction, comparison := t.CreatedAt, "ASC", ">"
+	if strings.HasSuffix(q.Sort, "amount") {
+		column = t.Amount
+	}
+	if strings.HasPrefix(q.Sort, "-") {
+		direction, comparison = "DESC", "<"
+	}
+
+	condition := ""
+	if q.after != nil {
+		var value any = q.after.createdAt
+		if column == t.Amount {
+			value = q.after.amount
+		}
+		args = append(args, value, q.after.id)
+		condition = fmt.Sprintf(
+			" AND (%s, %s) %s ($%d, $%d)", column, t.ID, comparison, len(args)-1, len(args),
+		)
+	}
+
+	args = append(args, q.PageSize+1)
+	orderBy := fmt.Sprintf(
+		" ORDER BY %s %s, %s %s LIMIT $%d", column, direction, t.ID, direction, len(args),
+	)
+
+	return condition, orderBy, args
+}
+
+// More reports whether the n rows a Seek query got run onto another page, in which case only the
+// first PageSize of them are on this one
+func (q *Query) More(n int) bool {
+	return n > q.PageSize
+}
+
+// NextCursor is the cursor of the page after the row, the last on this one
+func (q *Query) NextCursor(createdAt time.Time, amount money.Amount, id int64) string {
+	value := createdAt.UnixNano()
+	if strings.HasSuffix(q.Sort, "amount") {
+		value = int64(amount)
+	}
+	raw := fmt.Sprintf("%s|%d|%d", q.Sort, value, id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// cursor is the row a page starts after, in the order it was sorted in. only the value of the
+// column sorted on is kept
+type cursor struct {
+	sort      string
+	createdAt time.Time
+	amount    money.Amount
+	id        int64
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("cursor has %d parts", len(parts))
+	}
+	value, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cursor{sort: parts[0], id: id}
+	if strings.HasSuffix(c.sort, "amount") {
+		c.amount = money.Amount(value)
+	} else {
+		c.createdAt = time.Unix(0, value).UTC()
+	}
+	return c, nil
+}
+
+// day is the start of the day the time is on, in UTC
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,213 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+var testTable = Table{
+	ID:           "t.id",
+	CreatedAt:    "t.created_at",
+	Amount:       "t.amount",
+	Status:       "t.status",
+	Statuses:     []string{"COMPLETED", "REVERSED"},
+	Counterparty: "t.other IN (%[1]s, %[1]s)",
+}
+
+func date(s string) *time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func amount(s string) *money.Amount {
+	a := money.MustParse(s)
+	return &a
+}
+
+func TestValidateQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      Query
+		wantErrKey string
+		wantStatus string
+		wantSort   string
+		wantSize   int
+	}{
+		{
+			name:     "defaults",
+			query:    Query{},
+			wantSort: "-created_at",
+			wantSize: DefaultPageSize,
+		},
+		{
+			name:       "status in another case",
+			query:      Query{Status: "reversed", Sort: "amount", PageSize: 5},
+			wantStatus: "REVERSED",
+			wantSort:   "amount",
+			wantSize:   5,
+		},
+		{
+			name:       "unknown status",
+			query:      Query{Status: "PENDING"},
+			wantErrKey: "status",
+		},
+		{
+			name:       "to before from",
+			query:      Query{From: date("2026-03-02"), To: date("2026-03-01")},
+			wantErrKey: "to",
+		},
+		{
+			name:       "negative min amount",
+			query:      Query{MinAmount: amount("-1")},
+			wantErrKey: "min amount",
+		},
+		{
+			name:       "max below min",
+			query:      Query{MinAmount: amount("10"), MaxAmount: amount("5")},
+			wantErrKey: "max amount",
+		},
+		{
+			name:       "category on a table without them",
+			query:      Query{Category: "rent"},
+			wantErrKey: "category",
+		},
+		{
+			name:       "unknown sort",
+			query:      Query{Sort: "status"},
+			wantErrKey: "sort",
+		},
+		{
+			name:       "page too big",
+			query:      Query{PageSize: MaxPageSize + 1},
+			wantErrKey: "page size",
+		},
+		{
+			name:       "garbage cursor",
+			query:      Query{Cursor: "not a cursor"},
+			wantErrKey: "cursor",
+		},
+		{
+			// a cursor of the newest first can't be used for the oldest first
+			name: "cursor of another order",
+			query: Query{
+				Sort:   "created_at",
+				Cursor: (&Query{Sort: "-created_at"}).NextCursor(time.Now(), 0, 1),
+			},
+			wantErrKey: "cursor",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v := validator.New()
+			q := tc.query
+			ValidateQuery(v, &q, testTable)
+
+			if tc.wantErrKey != "" {
+				if _, ok := v.Errors[tc.wantErrKey]; !ok {
+					t.Errorf("expected an error on %q, got %v", tc.wantErrKey, v.Errors)
+				}
+				return
+			}
+			if !v.IsValid() {
+				t.Fatalf("expected no errors, got %v", v.Errors)
+			}
+			if q.Status != tc.wantStatus || q.Sort != tc.wantSort || q.PageSize != tc.wantSize {
+				t.Errorf(
+					"expected status=%q sort=%q page size=%d, got status=%q sort=%q page size=%d",
+					tc.wantStatus, tc.wantSort, tc.wantSize, q.Status, q.Sort, q.PageSize,
+				)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	q := &Query{
+		From:         date("2026-03-01"),
+		To:           date("2026-03-31"),
+		MinAmount:    amount("10"),
+		Status:       "COMPLETED",
+		Counterparty: "a@b.com",
+	}
+	got, args := testTable.Filter(q, []any{int64(7)})
+
+	want := " AND t.created_at >= $2 AND t.created_at < $3 AND t.amount >= $4" +
+		" AND t.status = $5 AND t.other IN ($6, $6)"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	wantArgs := []any{
+		int64(7), *date("2026-03-01"), *date("2026-04-01"), money.MustParse("10"), "COMPLETED",
+		"a@b.com",
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("expected args %v, got %v", wantArgs, args)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 10, 30, 0, 123456000, time.UTC)
+
+	tests := []struct {
+		name          string
+		sort          string
+		wantCondition string
+		wantOrderBy   string
+		wantAfter     any
+	}{
+		{
+			name:          "newest first",
+			sort:          "-created_at",
+			wantCondition: " AND (t.created_at, t.id) < ($2, $3)",
+			wantOrderBy:   " ORDER BY t.created_at DESC, t.id DESC LIMIT $4",
+			wantAfter:     createdAt,
+		},
+		{
+			name:          "smallest first",
+			sort:          "amount",
+			wantCondition: " AND (t.amount, t.id) > ($2, $3)",
+			wantOrderBy:   " ORDER BY t.amount ASC, t.id ASC LIMIT $4",
+			wantAfter:     money.MustParse("12.50"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// the first page doesn't skip anything
+			q := &Query{Sort: tc.sort}
+			v := validator.New()
+			ValidateQuery(v, q, testTable)
+			condition, orderBy, args := testTable.Seek(q, []any{int64(7)})
+			if condition != "" || len(args) != 2 || args[1] != DefaultPageSize+1 {
+				t.Errorf("expected no condition and a limit, got %q %v", condition, args)
+			}
+
+			// the next page goes on from the cursor of the last row
+			cursor := q.NextCursor(createdAt, money.MustParse("12.50"), 42)
+			q = &Query{Sort: tc.sort, Cursor: cursor}
+			ValidateQuery(v, q, testTable)
+			if !v.IsValid() {
+				t.Fatalf("expected no errors, got %v", v.Errors)
+			}
+			condition, orderBy, args = testTable.Seek(q, []any{int64(7)})
+			if condition != tc.wantCondition || orderBy != tc.wantOrderBy {
+				t.Errorf(
+					"expected %q %q, got %q %q", tc.wantCondition, tc.wantOrderBy, condition,
+					orderBy,
+				)
+			}
+			wantArgs := []any{int64(7), tc.wantAfter, int64(42), DefaultPageSize + 1}
+			if !reflect.DeepEqual(args, wantArgs) {
+				t.Errorf("expected args %v, got %v", wantArgs, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package hold
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+const (
+	// a card payment that has been authorized but not settled yet
+	KindCard = "CARD"
+	// a large withdrawal waiting on approval
+	KindWithdrawal = "WITHDRAWAL"
+	// a transfer or withdrawal held for fraud review, placed by the screening
+	KindReview = "REVIEW"
+)
+
+const (
+	StatusActive   = "ACTIVE"
+	StatusCaptured = "CAPTURED"
+	StatusReleased = "RELEASED"
+	// an active hold past its expiry, it is never stored, the hold just stops counting
+	StatusExpired = "EXPIRED"
+)
+
+// how long a hold lasts when it is placed without an expiry
+var defaultExpiry = map[string]time.Duration{
+	KindCard:       7 * 24 * time.Hour,
+	KindWithdrawal: 24 * time.Hour,
+}
+
+// Hold sets Amount of the account's balance aside for a debit that is still pending, so that it
+// can't be spent on anything else. capturing the hold makes the debit, of all or part of the amount,
+// and releasing it, or letting it expire, frees the money again
+type Hold struct {
+	ID             int64        `json:"id"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UserID         int64        `json:"user_id"`
+	AccountID      int64        `json:"account_id"`
+	Currency       string       `json:"currency"`
+	Kind           string       `json:"kind"`
+	Amount         money.Amount `json:"amount"`
+	Description    string       `json:"description"`
+	PlacedBy       string       `json:"placed_by"`
+	ExpiresAt      time.Time    `json:"expires_at"`
+	Status         string       `json:"status"`
+	CapturedAmount money.Amount `json:"captured_amount"`
+	ResolvedAt     *time.Time   `json:"resolved_at"`
+	Version        int32        `json:"version"`
+}
+
+// settlementAccount is the bank's account the money goes to when the hold is captured
+func (h *Hold) settlementAccount() string {
+	if h.Kind == KindCard {
+		return ledger.AccountCardSettlement
+	}
+	return ledger.AccountCash
+}
+
+func ValidateHold(v *validator.Validator, hold *Hold) {
+	v.CheckAddError(hold.Amount != 0, "amount", "must be given")
+	v.CheckAddError(hold.Amount > 0, "amount", "must be greater than 0")
+	v.CheckAddError(validator.ValueInList(hold.Kind, KindCard, KindWithdrawal), "kind", "invalid")
+	v.CheckAddError(hold.PlacedBy != "", "placed by", "must be given")
+	v.CheckAddError(hold.ExpiresAt.After(time.Now()), "expires at", "must be in the future")
+	v.CheckAddError(len(hold.Description) <= 500, "description", "must not be more than 500 bytes")
+}
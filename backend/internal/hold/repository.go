@@ -0,0 +1,235 @@
+package hold
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+var ErrEditConflict = errors.New("edit conflict")
+
+// ErrNotActive is returned for a hold that has to be active but has expired or been resolved
+var ErrNotActive = errors.New("hold not active")
+
+type Repository struct {
+	DB *sql.DB
+}
+
+const selectHolds = `
+	SELECT id, created_at, user_id, account_id, currency, kind, amount, description, placed_by,
+		expires_at, status, captured_amount, resolved_at, version
+	FROM holds
+`
+
+// InsertTx places the hold if the account has the money available for it, its overdraft included.
+// the account row is locked while the hold is placed, so that it can't be spent at the same time
+func (r *Repository) InsertTx(hold *Hold) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		return PlaceInTx(ctx, tx, hold)
+	})
+}
+
+// PlaceInTx is InsertTx in the caller's transaction, for a hold placed along with something else
+func PlaceInTx(ctx context.Context, tx *sql.Tx, hold *Hold) error {
+	query := `
+		SELECT balance - ` + ledger.HeldAmountColumn + ` + overdraft_limit
+		FROM accounts
+		WHERE id = $1
+		FOR UPDATE
+	`
+	var available money.Amount
+	err := tx.QueryRowContext(ctx, query, hold.AccountID).Scan(&available)
+	if err != nil {
+		return err
+	}
+	if available < hold.Amount {
+		return ledger.ErrInsufficientFunds
+	}
+
+	query = `
+		INSERT INTO holds
+			(user_id, account_id, currency, kind, amount, description, placed_by, expires_at,
+			status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, version
+	`
+	return tx.QueryRowContext(
+		ctx, query,
+		hold.UserID,
+		hold.AccountID,
+		hold.Currency,
+		hold.Kind,
+		hold.Amount,
+		hold.Description,
+		hold.PlacedBy,
+		hold.ExpiresAt,
+		hold.Status,
+	).Scan(&hold.ID, &hold.CreatedAt, &hold.Version)
+}
+
+func (r *Repository) Get(holdID int64) (*Hold, error) {
+	query := selectHolds + `
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hold, err := scanHold(r.DB.QueryRowContext(ctx, query, holdID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return hold, nil
+}
+
+// CaptureTx marks the hold captured and posts the entry that makes the debit in one database
+// transaction. the hold stops counting before the debit is posted, so the money it set aside can pay
+// for it. if the hold is no longer active, or has changed since it was read, nothing is done
+func (r *Repository) CaptureTx(hold *Hold, entry *ledger.Entry) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		err := resolveTx(ctx, tx, hold)
+		if err != nil {
+			return err
+		}
+
+		return ledger.PostInTx(ctx, tx, entry)
+	})
+}
+
+// Release marks the hold released, if it is still active and has not changed since it was read
+func (r *Repository) Release(hold *Hold) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		return resolveTx(ctx, tx, hold)
+	})
+}
+
+// ReleaseInTx releases the hold with the ID in the caller's transaction if it is still active, for
+// a hold that is done with once something else is saved
+func ReleaseInTx(ctx context.Context, tx *sql.Tx, holdID int64) error {
+	query := `
+		UPDATE holds
+		SET status = 'RELEASED', resolved_at = NOW(), version = version + 1
+		WHERE id = $1 AND status = 'ACTIVE'
+	`
+	_, err := tx.ExecContext(ctx, query, holdID)
+	return err
+}
+
+// ReleaseActiveInTx is ReleaseInTx for a hold whose money is about to be spent, it has to still be
+// active and not expired or ErrNotActive is returned
+func ReleaseActiveInTx(ctx context.Context, tx *sql.Tx, holdID int64) error {
+	query := `
+		UPDATE holds
+		SET status = 'RELEASED', resolved_at = NOW(), version = version + 1
+		WHERE id = $1 AND status = 'ACTIVE' AND expires_at > NOW()
+	`
+	result, err := tx.ExecContext(ctx, query, holdID)
+	if err != nil {
+		return err
+	}
+	released, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if released == 0 {
+		return ErrNotActive
+	}
+
+	return nil
+}
+
+// resolveTx saves the new status of an active hold
+func resolveTx(ctx context.Context, tx *sql.Tx, hold *Hold) error {
+	query := `
+		UPDATE holds
+		SET status = $1, captured_amount = $2, resolved_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4 AND status = 'ACTIVE' AND expires_at > NOW()
+		RETURNING resolved_at, version
+	`
+	err := tx.QueryRowContext(
+		ctx, query, hold.Status, hold.CapturedAmount, hold.ID, hold.Version,
+	).Scan(&hold.ResolvedAt, &hold.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) GetAllUserHolds(userID int64) ([]*Hold, error) {
+	query := selectHolds + `
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []*Hold
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+// scanHold scans a row of selectHolds, from either a *sql.Row or *sql.Rows. an active hold that has
+// expired is returned as expired
+func scanHold(row interface{ Scan(dest ...any) error }) (*Hold, error) {
+	hold := &Hold{}
+	err := row.Scan(
+		&hold.ID,
+		&hold.CreatedAt,
+		&hold.UserID,
+		&hold.AccountID,
+		&hold.Currency,
+		&hold.Kind,
+		&hold.Amount,
+		&hold.Description,
+		&hold.PlacedBy,
+		&hold.ExpiresAt,
+		&hold.Status,
+		&hold.CapturedAmount,
+		&hold.ResolvedAt,
+		&hold.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if hold.Status == StatusActive && !hold.ExpiresAt.After(time.Now()) {
+		hold.Status = StatusExpired
+	}
+
+	return hold, nil
+}
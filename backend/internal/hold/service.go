@@ -0,0 +1,155 @@
+package hold
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type HoldRepo interface {
+	InsertTx(hold *Hold) error
+	Get(holdID int64) (*Hold, error)
+	CaptureTx(hold *Hold, entry *ledger.Entry) error
+	Release(hold *Hold) error
+	GetAllUserHolds(userID int64) ([]*Hold, error)
+}
+
+type AccountService interface {
+	GetUserAccount(userID int64, number string) (*account.Account, error)
+}
+
+type Service struct {
+	Repo           HoldRepo
+	AccountService AccountService
+}
+
+// Place sets the amount aside on one of the user's accounts, their primary account when no number is
+// given. a hold placed without an expiry lasts for the default of its kind
+func (s *Service) Place(
+	v *validator.Validator, userID int64, accountNumber, kind string, amount money.Amount,
+	description, placedBy string, expiresAt time.Time,
+) (*Hold, error) {
+	a, err := s.AccountService.GetUserAccount(userID, accountNumber)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("account", "not found")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(defaultExpiry[kind])
+	}
+
+	hold := Hold{
+		CreatedAt:   time.Now(),
+		UserID:      a.UserID,
+		AccountID:   a.ID,
+		Currency:    a.Currency,
+		Kind:        kind,
+		Amount:      amount,
+		Description: description,
+		PlacedBy:    placedBy,
+		ExpiresAt:   expiresAt,
+		Status:      StatusActive,
+	}
+
+	v.CheckAddError(a.IsActive(), "account", "is not active")
+	if ValidateHold(v, &hold); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+	if amount > a.Spendable() {
+		v.AddError("account balance", "insufficient funds")
+		return nil, validator.ErrFailedValidation
+	}
+
+	err = s.Repo.InsertTx(&hold)
+	if err != nil {
+		// the balance can change between the check and the insert
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			v.AddError("account balance", "insufficient funds")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+// Capture debits the amount of the hold, all of it when no amount is given, and releases the rest
+func (s *Service) Capture(v *validator.Validator, holdID int64, amount money.Amount) (*Hold, error) {
+	hold, err := s.activeHold(v, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	v.CheckAddError(amount > 0, "amount", "must be greater than 0")
+	v.CheckAddError(amount <= hold.Amount, "amount", "must not be more than the amount held")
+	if !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	hold.Status = StatusCaptured
+	hold.CapturedAmount = amount
+
+	entry := ledger.NewEntry(
+		"hold capture",
+		ledger.AccountPosting(hold.AccountID, hold.Currency, amount.Neg()),
+		ledger.SystemPosting(hold.settlementAccount(), hold.Currency, amount),
+	)
+	err = s.Repo.CaptureTx(hold, entry)
+	if err != nil {
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			v.AddError("account balance", "insufficient funds")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// Release frees the money the hold set aside without debiting any of it
+func (s *Service) Release(v *validator.Validator, holdID int64) (*Hold, error) {
+	hold, err := s.activeHold(v, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	hold.Status = StatusReleased
+	err = s.Repo.Release(hold)
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// activeHold gets the hold, which has to still be active to be captured or released
+func (s *Service) activeHold(v *validator.Validator, holdID int64) (*Hold, error) {
+	hold, err := s.Repo.Get(holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	if hold.Status != StatusActive {
+		v.AddError("hold", "is "+strings.ToLower(hold.Status))
+		return nil, validator.ErrFailedValidation
+	}
+
+	return hold, nil
+}
+
+func (s *Service) GetAllUserHolds(userID int64) ([]*Hold, error) {
+	return s.Repo.GetAllUserHolds(userID)
+}
@@ -0,0 +1,393 @@
+package hold
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// MockRepo keeps the one hold it is given and the entry it was captured with
+type MockRepo struct {
+	Hold       *Hold
+	Entry      *ledger.Entry
+	InsertErr  error
+	GetErr     error
+	CaptureErr error
+	ReleaseErr error
+}
+
+func (r *MockRepo) InsertTx(hold *Hold) error {
+	if r.InsertErr != nil {
+		return r.InsertErr
+	}
+	hold.ID = 1
+	r.Hold = hold
+	return nil
+}
+
+func (r *MockRepo) Get(holdID int64) (*Hold, error) {
+	if r.GetErr != nil {
+		return nil, r.GetErr
+	}
+	hold := *r.Hold
+	return &hold, nil
+}
+
+func (r *MockRepo) CaptureTx(hold *Hold, entry *ledger.Entry) error {
+	if r.CaptureErr != nil {
+		return r.CaptureErr
+	}
+	r.Hold = hold
+	r.Entry = entry
+	return nil
+}
+
+func (r *MockRepo) Release(hold *Hold) error {
+	if r.ReleaseErr != nil {
+		return r.ReleaseErr
+	}
+	r.Hold = hold
+	return nil
+}
+
+func (r *MockRepo) GetAllUserHolds(userID int64) ([]*Hold, error) {
+	return []*Hold{r.Hold}, nil
+}
+
+type MockAccountService struct {
+	Account *account.Account
+	Err     error
+}
+
+func (as *MockAccountService) GetUserAccount(userID int64, number string) (*account.Account, error) {
+	if as.Err != nil {
+		return nil, as.Err
+	}
+	return as.Account, nil
+}
+
+func TestPlace(t *testing.T) {
+	mockAccount := func() *account.Account {
+		return &account.Account{
+			ID:               1,
+			UserID:           1,
+			Currency:         "USD",
+			Status:           account.StatusActive,
+			Balance:          money.MustParse("100"),
+			HeldAmount:       money.MustParse("30"),
+			AvailableBalance: money.MustParse("70"),
+		}
+	}
+
+	tests := []struct {
+		name           string
+		setupRepo      func(*MockRepo)
+		setupAccount   func(*MockAccountService)
+		kind           string
+		amount         money.Amount
+		expiresAt      time.Time
+		wantExpiry     time.Duration
+		expectedErr    error
+		expectedErrKey string
+	}{
+		{
+			name:       "card",
+			kind:       KindCard,
+			amount:     money.MustParse("70"),
+			wantExpiry: defaultExpiry[KindCard],
+		},
+		{
+			name:       "withdrawal",
+			kind:       KindWithdrawal,
+			amount:     money.MustParse("50"),
+			wantExpiry: defaultExpiry[KindWithdrawal],
+		},
+		{
+			name:       "given expiry",
+			kind:       KindCard,
+			amount:     money.MustParse("50"),
+			expiresAt:  time.Now().Add(time.Hour),
+			wantExpiry: time.Hour,
+		},
+		{
+			name:           "more than the available balance",
+			kind:           KindCard,
+			amount:         money.MustParse("70.01"),
+			expectedErr:    validator.ErrFailedValidation,
+			expectedErrKey: "account balance",
+		},
+		{
+			name:           "amount = 0",
+			kind:           KindCard,
+			expectedErr:    validator.ErrFailedValidation,
+			expectedErrKey: "amount",
+		},
+		{
+			name:           "invalid kind",
+			kind:           "CHEQUE",
+			amount:         money.MustParse("10"),
+			expectedErr:    validator.ErrFailedValidation,
+			expectedErrKey: "kind",
+		},
+		{
+			name:           "expiry in the past",
+			kind:           KindCard,
+			amount:         money.MustParse("10"),
+			expiresAt:      time.Now().Add(-time.Hour),
+			expectedErr:    validator.ErrFailedValidation,
+			expectedErrKey: "expires at",
+		},
+		{
+			name:   "frozen account",
+			kind:   KindCard,
+			amount: money.MustParse("10"),
+			setupAccount: func(as *MockAccountService) {
+				as.Account.Status = account.StatusFrozen
+			},
+			expectedErr:    validator.ErrFailedValidation,
+			expectedErrKey: "account",
+		},
+		{
+			name:   "account not found",
+			kind:   KindCard,
+			amount: money.MustParse("10"),
+			setupAccount: func(as *MockAccountService) {
+				as.Err = user.ErrNoRecord
+			},
+			expectedErr:    validator.ErrFailedValidation,
+			expectedErrKey: "account",
+		},
+		{
+			name:   "spent before the insert",
+			kind:   KindCard,
+			amount: money.MustParse("10"),
+			setupRepo: func(r *MockRepo) {
+				r.InsertErr = ledger.ErrInsufficientFunds
+			},
+			expectedErr:    validator.ErrFailedValidation,
+			expectedErrKey: "account balance",
+		},
+		{
+			name:   "insert failure",
+			kind:   KindCard,
+			amount: money.MustParse("10"),
+			setupRepo: func(r *MockRepo) {
+				r.InsertErr = errors.New("db insert error")
+			},
+			expectedErr: errors.New("db insert error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			accountService := &MockAccountService{Account: mockAccount()}
+			if tc.setupRepo != nil {
+				tc.setupRepo(repo)
+			}
+			if tc.setupAccount != nil {
+				tc.setupAccount(accountService)
+			}
+
+			svc := Service{Repo: repo, AccountService: accountService}
+
+			v := validator.New()
+			hold, gotErr := svc.Place(
+				v, 1, "", tc.kind, tc.amount, "", "yusuf", tc.expiresAt,
+			)
+
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				if _, ok := v.Errors[tc.expectedErrKey]; tc.expectedErrKey != "" && !ok {
+					t.Errorf("expected an error for %s, got %v", tc.expectedErrKey, v.Errors)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if hold.Status != StatusActive {
+				t.Errorf("expected status=%s, got %s", StatusActive, hold.Status)
+			}
+			if hold.Currency != "USD" || hold.AccountID != 1 {
+				t.Errorf(
+					"expected the hold on account 1 in USD, got account %d in %s",
+					hold.AccountID, hold.Currency,
+				)
+			}
+			expiry := time.Until(hold.ExpiresAt)
+			if expiry > tc.wantExpiry || expiry < tc.wantExpiry-time.Minute {
+				t.Errorf("expected the hold to expire in %v, got %v", tc.wantExpiry, expiry)
+			}
+		})
+	}
+}
+
+func TestCaptureAndRelease(t *testing.T) {
+	mockHold := func(kind, status string) *Hold {
+		return &Hold{
+			ID:        1,
+			UserID:    1,
+			AccountID: 1,
+			Currency:  "USD",
+			Kind:      kind,
+			Amount:    money.MustParse("50"),
+			PlacedBy:  "yusuf",
+			ExpiresAt: time.Now().Add(time.Hour),
+			Status:    status,
+		}
+	}
+
+	tests := []struct {
+		name            string
+		hold            *Hold
+		setupRepo       func(*MockRepo)
+		release         bool
+		amount          money.Amount
+		wantStatus      string
+		wantCaptured    money.Amount
+		wantSettlement  string
+		expectedErr     error
+		expectedErrText string
+	}{
+		{
+			name:           "capture in full",
+			hold:           mockHold(KindCard, StatusActive),
+			wantStatus:     StatusCaptured,
+			wantCaptured:   money.MustParse("50"),
+			wantSettlement: ledger.AccountCardSettlement,
+		},
+		{
+			name:           "capture part of a withdrawal",
+			hold:           mockHold(KindWithdrawal, StatusActive),
+			amount:         money.MustParse("20"),
+			wantStatus:     StatusCaptured,
+			wantCaptured:   money.MustParse("20"),
+			wantSettlement: ledger.AccountCash,
+		},
+		{
+			name:            "capture more than held",
+			hold:            mockHold(KindCard, StatusActive),
+			amount:          money.MustParse("50.01"),
+			expectedErr:     validator.ErrFailedValidation,
+			expectedErrText: "must not be more than the amount held",
+		},
+		{
+			name:            "capture expired",
+			hold:            mockHold(KindCard, StatusExpired),
+			expectedErr:     validator.ErrFailedValidation,
+			expectedErrText: "is expired",
+		},
+		{
+			name:            "capture released",
+			hold:            mockHold(KindCard, StatusReleased),
+			expectedErr:     validator.ErrFailedValidation,
+			expectedErrText: "is released",
+		},
+		{
+			name: "capture changed under us",
+			hold: mockHold(KindCard, StatusActive),
+			setupRepo: func(r *MockRepo) {
+				r.CaptureErr = ErrEditConflict
+			},
+			expectedErr: ErrEditConflict,
+		},
+		{
+			name:       "release",
+			hold:       mockHold(KindCard, StatusActive),
+			release:    true,
+			wantStatus: StatusReleased,
+		},
+		{
+			name:            "release captured",
+			hold:            mockHold(KindCard, StatusCaptured),
+			release:         true,
+			expectedErr:     validator.ErrFailedValidation,
+			expectedErrText: "is captured",
+		},
+		{
+			name: "hold not found",
+			hold: mockHold(KindCard, StatusActive),
+			setupRepo: func(r *MockRepo) {
+				r.GetErr = user.ErrNoRecord
+			},
+			release:     true,
+			expectedErr: user.ErrNoRecord,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{Hold: tc.hold}
+			if tc.setupRepo != nil {
+				tc.setupRepo(repo)
+			}
+
+			svc := Service{Repo: repo, AccountService: &MockAccountService{}}
+
+			v := validator.New()
+			var hold *Hold
+			var gotErr error
+			if tc.release {
+				hold, gotErr = svc.Release(v, tc.hold.ID)
+			} else {
+				hold, gotErr = svc.Capture(v, tc.hold.ID, tc.amount)
+			}
+
+			if tc.expectedErr != nil {
+				if !errors.Is(gotErr, tc.expectedErr) {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				found := tc.expectedErrText == ""
+				for _, msg := range v.Errors {
+					found = found || msg == tc.expectedErrText
+				}
+				if !found {
+					t.Errorf("expected error %q, got %v", tc.expectedErrText, v.Errors)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if hold.Status != tc.wantStatus {
+				t.Errorf("expected status=%s, got %s", tc.wantStatus, hold.Status)
+			}
+			if hold.CapturedAmount != tc.wantCaptured {
+				t.Errorf("expected captured amount=%v, got %v", tc.wantCaptured, hold.CapturedAmount)
+			}
+
+			if tc.release {
+				if repo.Entry != nil {
+					t.Errorf("expected nothing posted for a release, got %v", repo.Entry)
+				}
+				return
+			}
+
+			v = validator.New()
+			if ledger.ValidateEntry(v, repo.Entry); !v.IsValid() {
+				t.Errorf("unbalanced entry: %v", v.Errors)
+			}
+			for _, posting := range repo.Entry.Postings {
+				switch {
+				case posting.AccountID == tc.hold.AccountID:
+					if posting.Amount != tc.wantCaptured.Neg() {
+						t.Errorf("expected a debit of %v, got %v", tc.wantCaptured, posting.Amount)
+					}
+				case posting.AccountCode != tc.wantSettlement:
+					t.Errorf(
+						"expected the credit to %s, got %s", tc.wantSettlement, posting.AccountCode,
+					)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// HeaderKey is the request header the clients send the key in
+const HeaderKey = "Idempotency-Key"
+
+// how long a key is remembered, a retry after this is treated as a new request
+const keyTimeToLive = 24 * time.Hour
+
+// Record is what we remember about the first request made with a key, so that a retry of it can be
+// answered with the same response instead of being run again
+type Record struct {
+	ID           int64
+	CreatedAt    time.Time
+	Expiry       time.Time
+	UserID       int64
+	Key          string
+	Fingerprint  string
+	StatusCode   int // 0 while the first request is still running
+	ContentType  string
+	ResponseBody []byte
+}
+
+func (r *Record) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// Fingerprint identifies a request by its method, path and body, two requests with the same key
+// must have the same fingerprint
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func ValidateKey(v *validator.Validator, key string) {
+	v.CheckAddError(key != "", "idempotency key", "must be given")
+	v.CheckAddError(len(key) <= 255, "idempotency key", "must not be more than 255 bytes long")
+}
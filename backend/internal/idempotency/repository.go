@@ -0,0 +1,109 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// Insert saves the record unless the user already has a live record for the key, in which case
+// the existing record is returned instead and nothing is saved
+func (r *Repository) Insert(record *Record) (*Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// an expired key is free to be used again
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND expiry < NOW()
+	`
+	_, err := r.DB.ExecContext(ctx, query, record.UserID, record.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		INSERT INTO idempotency_keys (expiry, user_id, key, fingerprint)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO NOTHING
+		RETURNING id, created_at
+	`
+	args := []any{
+		record.Expiry,
+		record.UserID,
+		record.Key,
+		record.Fingerprint,
+	}
+	err = r.DB.QueryRowContext(ctx, query, args...).Scan(&record.ID, &record.CreatedAt)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// the key is taken, fetch the record that holds it
+	query = `
+		SELECT id, created_at, expiry, user_id, key, fingerprint, COALESCE(status_code, 0),
+			content_type, response_body
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`
+	existing := &Record{}
+	err = r.DB.QueryRowContext(ctx, query, record.UserID, record.Key).Scan(
+		&existing.ID,
+		&existing.CreatedAt,
+		&existing.Expiry,
+		&existing.UserID,
+		&existing.Key,
+		&existing.Fingerprint,
+		&existing.StatusCode,
+		&existing.ContentType,
+		&existing.ResponseBody,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// Complete stores the response of the request that reserved the key
+func (r *Repository) Complete(record *Record) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $1, content_type = $2, response_body = $3
+		WHERE id = $4
+	`
+	args := []any{
+		record.StatusCode,
+		record.ContentType,
+		record.ResponseBody,
+		record.ID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Delete frees the key, used when the request that reserved it never got to respond
+func (r *Repository) Delete(recordID int64) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, recordID)
+	return err
+}
@@ -0,0 +1,70 @@
+package idempotency
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+var (
+	ErrKeyReused         = errors.New("idempotency key already used for a different request")
+	ErrRequestInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+type Repo interface {
+	Insert(record *Record) (*Record, error)
+	Complete(record *Record) error
+	Delete(recordID int64) error
+}
+
+type Service struct {
+	Repo Repo
+}
+
+// Begin reserves the key for the request. if the user has already made the same request with the
+// key, the stored record is returned along with true and its response should be sent back as is
+func (s *Service) Begin(
+	v *validator.Validator, userID int64, key, fingerprint string,
+) (*Record, bool, error) {
+	if ValidateKey(v, key); !v.IsValid() {
+		return nil, false, validator.ErrFailedValidation
+	}
+
+	record := &Record{
+		Expiry:      time.Now().Add(keyTimeToLive),
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+	}
+	existing, err := s.Repo.Insert(record)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing == nil {
+		return record, false, nil
+	}
+
+	switch {
+	case existing.Fingerprint != fingerprint:
+		return nil, false, ErrKeyReused
+	case !existing.Completed():
+		return nil, false, ErrRequestInProgress
+	}
+
+	return existing, true, nil
+}
+
+// Complete stores the response so that retries with the key get it back. server errors are kept
+// too, the request may have moved money before it failed
+func (s *Service) Complete(record *Record, statusCode int, contentType string, body []byte) error {
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.ResponseBody = body
+	return s.Repo.Complete(record)
+}
+
+// Abandon frees the key so the request can be tried again, only for a request that saved nothing
+func (s *Service) Abandon(record *Record) error {
+	return s.Repo.Delete(record.ID)
+}
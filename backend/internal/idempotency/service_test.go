@@ -0,0 +1,201 @@
+package idempotency
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// ---MOCKS---
+
+type MockRepo struct {
+	InsertResult *Record
+	InsertErr    error
+	Inserted     []*Record
+
+	CompleteErr error
+	Completed   []*Record
+
+	Deleted []int64
+}
+
+func (r *MockRepo) Insert(record *Record) (*Record, error) {
+	if r.InsertErr != nil {
+		return nil, r.InsertErr
+	}
+	if r.InsertResult == nil {
+		r.Inserted = append(r.Inserted, record)
+	}
+	return r.InsertResult, nil
+}
+
+func (r *MockRepo) Complete(record *Record) error {
+	if r.CompleteErr != nil {
+		return r.CompleteErr
+	}
+	r.Completed = append(r.Completed, record)
+	return nil
+}
+
+func (r *MockRepo) Delete(recordID int64) error {
+	r.Deleted = append(r.Deleted, recordID)
+	return nil
+}
+
+func TestBegin(t *testing.T) {
+	fingerprint := Fingerprint("PUT", "/v1/transfer", []byte(`{"amount": 10}`))
+
+	tests := []struct {
+		name        string
+		setupRepo   func(*MockRepo)
+		key         string
+		wantReplay  bool
+		wantRecord  bool
+		expectedErr error
+	}{
+		{
+			name:       "new key",
+			setupRepo:  func(r *MockRepo) {},
+			key:        "abc",
+			wantRecord: true,
+		},
+		{
+			name: "same request again",
+			setupRepo: func(r *MockRepo) {
+				r.InsertResult = &Record{
+					Key: "abc", Fingerprint: fingerprint, StatusCode: 200, ResponseBody: []byte("{}"),
+				}
+			},
+			key:        "abc",
+			wantReplay: true,
+			wantRecord: true,
+		},
+		{
+			name: "different request with the same key",
+			setupRepo: func(r *MockRepo) {
+				r.InsertResult = &Record{Key: "abc", Fingerprint: "other", StatusCode: 200}
+			},
+			key:         "abc",
+			expectedErr: ErrKeyReused,
+		},
+		{
+			name: "first request still running",
+			setupRepo: func(r *MockRepo) {
+				r.InsertResult = &Record{Key: "abc", Fingerprint: fingerprint}
+			},
+			key:         "abc",
+			expectedErr: ErrRequestInProgress,
+		},
+		{
+			name:        "empty key",
+			setupRepo:   func(r *MockRepo) {},
+			key:         "",
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "Insert failure",
+			setupRepo: func(r *MockRepo) {
+				r.InsertErr = errors.New("db error")
+			},
+			key:         "abc",
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			tc.setupRepo(repo)
+			svc := Service{Repo: repo}
+
+			gotRecord, gotReplay, gotErr := svc.Begin(validator.New(), 1, tc.key, fingerprint)
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if gotReplay != tc.wantReplay {
+				t.Errorf("expected replay=%v, got replay=%v", tc.wantReplay, gotReplay)
+			}
+			if (gotRecord != nil) != tc.wantRecord {
+				t.Fatalf("expected record=%v, got %v", tc.wantRecord, gotRecord)
+			}
+			if !gotReplay && len(repo.Inserted) != 1 {
+				t.Errorf("expected the key to be reserved, got %d inserts", len(repo.Inserted))
+			}
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		wantStored  bool
+		wantDeleted bool
+	}{
+		{
+			name:       "stored",
+			statusCode: 200,
+			wantStored: true,
+		},
+		{
+			name:       "refusal stored",
+			statusCode: 400,
+			wantStored: true,
+		},
+		{
+			name:       "server error stored",
+			statusCode: 500,
+			wantStored: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			svc := Service{Repo: repo}
+
+			record := &Record{ID: 1}
+			body := []byte(`{"message": "ok"}`)
+			err := svc.Complete(record, tc.statusCode, "application/json", body)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if tc.wantStored != (len(repo.Completed) == 1) {
+				t.Fatalf("expected stored %v, got %d completed records", tc.wantStored,
+					len(repo.Completed))
+			}
+			if tc.wantDeleted != (len(repo.Deleted) == 1) {
+				t.Fatalf("expected the key freed %v, got %v", tc.wantDeleted, repo.Deleted)
+			}
+			if !tc.wantStored {
+				return
+			}
+			completed := repo.Completed[0]
+			if !completed.Completed() || string(completed.ResponseBody) != `{"message": "ok"}` ||
+				completed.ContentType != "application/json" {
+				t.Errorf("unexpected record %+v", completed)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint("PUT", "/v1/transfer", []byte(`{"amount": 10}`))
+	if a != Fingerprint("PUT", "/v1/transfer", []byte(`{"amount": 10}`)) {
+		t.Error("expected the same request to have the same fingerprint")
+	}
+	if a == Fingerprint("PUT", "/v1/transfer", []byte(`{"amount": 100}`)) {
+		t.Error("expected a different body to have a different fingerprint")
+	}
+	if a == Fingerprint("PUT", "/v1/withdraw", []byte(`{"amount": 10}`)) {
+		t.Error("expected a different path to have a different fingerprint")
+	}
+}
@@ -0,0 +1,149 @@
+package interest
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// the decimal places daily interest is kept to until it is paid
+const accrualPrecision = 8
+
+const daysPerYear = 365
+
+// the most a tier can pay, in percent a year
+var maxAnnualRate = fx.MustParseDecimal("100")
+
+// Tier is a band of a savings balance and the yearly interest rate, in percent, paid on the part of
+// the balance that falls in it. a tier runs from its From up to the From of the next one
+type Tier struct {
+	From       money.Amount `json:"from"`
+	AnnualRate fx.Decimal   `json:"annual_rate"`
+}
+
+// Tiers are the interest rates paid on savings, lowest band first. each band is paid its own rate,
+// so 10000 at 1% and 2% from 5000 earns 1% on the first 5000 and 2% on the rest. the bands are in
+// the currency of the account, whichever it is. no tiers means no interest is paid
+type Tiers []Tier
+
+// Set parses the tiers from a command line flag, e.g. "0:1.5,10000:2", so Tiers can be used with
+// flag.Var
+func (t *Tiers) Set(s string) error {
+	var tiers Tiers
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		from, rate, ok := strings.Cut(part, ":")
+		if !ok {
+			return fmt.Errorf("interest tier %q: must be from:rate", part)
+		}
+		var tier Tier
+		if err := tier.From.Set(from); err != nil {
+			return fmt.Errorf("interest tier %q: %w", part, err)
+		}
+		var err error
+		tier.AnnualRate, err = fx.ParseDecimal(rate)
+		if err != nil {
+			return fmt.Errorf("interest tier %q: %w", part, err)
+		}
+		tiers = append(tiers, tier)
+	}
+
+	v := validator.New()
+	if ValidateTiers(v, tiers); !v.IsValid() {
+		return fmt.Errorf("interest tiers: %v", v.Errors)
+	}
+
+	*t = tiers
+	return nil
+}
+
+func (t *Tiers) String() string {
+	if t == nil {
+		return ""
+	}
+	parts := make([]string, len(*t))
+	for i, tier := range *t {
+		parts[i] = tier.From.String() + ":" + tier.AnnualRate.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func ValidateTiers(v *validator.Validator, tiers Tiers) {
+	if len(tiers) == 0 {
+		return
+	}
+	v.CheckAddError(tiers[0].From == 0, "tiers", "must start from 0")
+	for i, tier := range tiers {
+		v.CheckAddError(tier.AnnualRate.Sign() >= 0, "tiers", "rates must not be negative")
+		v.CheckAddError(
+			tier.AnnualRate.Cmp(maxAnnualRate) <= 0, "tiers", "rates must not be more than 100",
+		)
+		if i > 0 {
+			v.CheckAddError(tier.From > tiers[i-1].From, "tiers", "must go up")
+		}
+	}
+}
+
+// Daily is the interest a balance earns in a day, exact to the fraction of a cent. negative
+// balances earn nothing
+func (t Tiers) Daily(balance money.Amount) *big.Rat {
+	total := new(big.Rat)
+	for i, tier := range t {
+		if balance <= tier.From {
+			break
+		}
+		top := balance
+		if i+1 < len(t) && t[i+1].From < balance {
+			top = t[i+1].From
+		}
+
+		rate := new(big.Rat).Quo(tier.AnnualRate.Rat(), big.NewRat(100*daysPerYear, 1))
+		band := new(big.Rat).Mul((top - tier.From).Rat(), rate)
+		total.Add(total, band)
+	}
+	return total
+}
+
+// Accrual is the interest an account earned on one day, on its balance at the end of it
+type Accrual struct {
+	AccountID int64
+	Currency  string
+	Day       time.Time
+	Balance   money.Amount
+	Amount    *big.Rat
+}
+
+// Unpaid is the interest an account has accrued that has not been paid into it yet
+type Unpaid struct {
+	AccountID int64
+	UserID    int64
+	Currency  string
+	Amount    *big.Rat
+}
+
+// Payment is the interest paid into an account, what it accrued rounded to the cent. while that
+// comes to nothing the interest stays accrued, to be paid with the next month's
+type Payment struct {
+	Amount money.Amount
+	Entry  *ledger.Entry
+}
+
+// day truncates the time to the start of its day in UTC, the days interest is accrued for
+func day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// monthStart is the first day of the month of t in UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,241 @@
+package interest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// LastAccruedDay gets the last day interest was accrued for, ok is false if it never has been
+func (r *Repository) LastAccruedDay() (last time.Time, ok bool, err error) {
+	query := `
+		SELECT MAX(day)
+		FROM interest_accrual_days
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var day sql.NullTime
+	err = r.DB.QueryRowContext(ctx, query).Scan(&day)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return day.Time, day.Valid, nil
+}
+
+// GetDayBalances gets the balances of the savings accounts that were open and in credit at the end
+// of the day, worked out from the ledger so that a day can be accrued after it has passed
+func (r *Repository) GetDayBalances(day time.Time) ([]*Accrual, error) {
+	query := `
+		SELECT accounts.id, accounts.currency, COALESCE(SUM(postings.amount), 0) AS balance
+		FROM accounts
+		INNER JOIN ledger_accounts
+		ON ledger_accounts.account_id = accounts.id
+		INNER JOIN postings
+		ON postings.account_id = ledger_accounts.id
+		INNER JOIN journal_entries
+		ON journal_entries.id = postings.entry_id
+		WHERE accounts.type = 'SAVINGS' AND accounts.created_at < $1
+			AND (accounts.closed_at IS NULL OR accounts.closed_at >= $1)
+			AND journal_entries.created_at < $1
+		GROUP BY accounts.id, accounts.currency
+		HAVING SUM(postings.amount) > 0
+		ORDER BY accounts.id
+	`
+
+	// every posting of every savings account up to the day is summed
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	end := day.AddDate(0, 0, 1)
+	rows, err := r.DB.QueryContext(ctx, query, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accruals []*Accrual
+	for rows.Next() {
+		accrual := &Accrual{Day: day}
+		err = rows.Scan(&accrual.AccountID, &accrual.Currency, &accrual.Balance)
+		if err != nil {
+			return nil, err
+		}
+		accruals = append(accruals, accrual)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return accruals, nil
+}
+
+// AccrueDayTx saves the interest the accounts earned on the day and marks the day accrued, in one
+// database transaction. an account that already has the day accrued keeps what it has
+func (r *Repository) AccrueDayTx(day time.Time, accruals []*Accrual) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		query := `
+			INSERT INTO interest_accruals (account_id, day, balance, amount)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (account_id, day) DO NOTHING
+		`
+		for _, accrual := range accruals {
+			_, err := tx.ExecContext(
+				ctx, query, accrual.AccountID, accrual.Day, accrual.Balance,
+				accrual.Amount.FloatString(accrualPrecision),
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		query = `
+			INSERT INTO interest_accrual_days (day)
+			VALUES ($1)
+			ON CONFLICT (day) DO NOTHING
+		`
+		_, err := tx.ExecContext(ctx, query, day)
+		return err
+	})
+}
+
+// GetUnpaidAccountIDs gets the open accounts with interest accrued before the given day that has
+// not been paid yet
+func (r *Repository) GetUnpaidAccountIDs(before time.Time) ([]int64, error) {
+	query := `
+		SELECT DISTINCT interest_accruals.account_id
+		FROM interest_accruals
+		INNER JOIN accounts
+		ON accounts.id = interest_accruals.account_id
+		WHERE interest_accruals.transaction_id IS NULL AND interest_accruals.day < $1
+			AND accounts.status <> 'CLOSED'
+		ORDER BY interest_accruals.account_id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// PayTx pays the interest the account accrued before the given day in one database transaction.
+// prepare is handed what is unpaid and returns the payment, with no entry when there is nothing to
+// pay yet. the payment is recorded as an INTEREST transaction and the accruals it paid are marked
+// with it, so they can never be paid again. it returns the amount paid
+func (r *Repository) PayTx(
+	accountID int64, before time.Time, prepare func(unpaid *Unpaid) *Payment,
+) (money.Amount, error) {
+	var paid money.Amount
+	err := dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		paid = 0
+
+		// the account row is locked so that two runs can't pay the same accruals
+		query := `
+			SELECT id, user_id, currency
+			FROM accounts
+			WHERE id = $1 AND status <> 'CLOSED'
+			FOR UPDATE
+		`
+		unpaid := &Unpaid{}
+		err := tx.QueryRowContext(ctx, query, accountID).Scan(
+			&unpaid.AccountID, &unpaid.UserID, &unpaid.Currency,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return user.ErrNoRecord
+			default:
+				return err
+			}
+		}
+
+		query = `
+			SELECT COALESCE(SUM(amount), 0)::TEXT
+			FROM interest_accruals
+			WHERE account_id = $1 AND day < $2 AND transaction_id IS NULL
+		`
+		var amount string
+		err = tx.QueryRowContext(ctx, query, accountID, before).Scan(&amount)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		unpaid.Amount, ok = new(big.Rat).SetString(amount)
+		if !ok {
+			return fmt.Errorf("interest: cannot read accrued amount %q", amount)
+		}
+
+		payment := prepare(unpaid)
+		if payment.Entry == nil {
+			return nil
+		}
+
+		err = ledger.PostInTx(ctx, tx, payment.Entry)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO transactions (user_id, account_id, currency, action, amount, performed_by)
+			VALUES ($1, $2, $3, 'INTEREST', $4, 'system')
+			RETURNING id
+		`
+		var transactionID int64
+		err = tx.QueryRowContext(
+			ctx, query, unpaid.UserID, unpaid.AccountID, unpaid.Currency, payment.Amount,
+		).Scan(&transactionID)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE interest_accruals
+			SET transaction_id = $1
+			WHERE account_id = $2 AND day < $3 AND transaction_id IS NULL
+		`
+		_, err = tx.ExecContext(ctx, query, transactionID, accountID, before)
+		if err != nil {
+			return err
+		}
+
+		paid = payment.Amount
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return paid, nil
+}
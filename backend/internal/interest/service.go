@@ -0,0 +1,103 @@
+package interest
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+)
+
+type InterestRepo interface {
+	LastAccruedDay() (last time.Time, ok bool, err error)
+	GetDayBalances(day time.Time) ([]*Accrual, error)
+	AccrueDayTx(day time.Time, accruals []*Accrual) error
+	GetUnpaidAccountIDs(before time.Time) ([]int64, error)
+	PayTx(
+		accountID int64, before time.Time, prepare func(unpaid *Unpaid) *Payment,
+	) (money.Amount, error)
+}
+
+// Service accrues interest on savings accounts every day and pays it into them every month, at the
+// rates of Tiers. it does nothing when there are no tiers
+type Service struct {
+	Repo  InterestRepo
+	Tiers Tiers
+}
+
+// Accrue accrues the interest for every whole day that has passed since the last one accrued, and
+// returns how many days were. the first time it runs only yesterday is accrued, the days before the
+// engine was started earn nothing
+func (s *Service) Accrue(now time.Time) (int, error) {
+	if len(s.Tiers) == 0 {
+		return 0, nil
+	}
+
+	yesterday := day(now).AddDate(0, 0, -1)
+	next := yesterday
+	last, ok, err := s.Repo.LastAccruedDay()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		next = last.AddDate(0, 0, 1)
+	}
+
+	accrued := 0
+	for d := next; !d.After(yesterday); d = d.AddDate(0, 0, 1) {
+		accruals, err := s.Repo.GetDayBalances(d)
+		if err != nil {
+			return accrued, err
+		}
+		for _, accrual := range accruals {
+			accrual.Amount = s.Tiers.Daily(accrual.Balance)
+		}
+
+		err = s.Repo.AccrueDayTx(d, accruals)
+		if err != nil {
+			return accrued, err
+		}
+		accrued++
+	}
+
+	return accrued, nil
+}
+
+// PayMonthly pays every account the interest it accrued before the month of now, and returns how
+// many accounts were paid. interest of an account closed before it was paid is not paid
+func (s *Service) PayMonthly(now time.Time) (int, error) {
+	accountIDs, err := s.Repo.GetUnpaidAccountIDs(monthStart(now))
+	if err != nil {
+		return 0, err
+	}
+
+	paid := 0
+	for _, accountID := range accountIDs {
+		amount, err := s.Repo.PayTx(accountID, monthStart(now), payment)
+		if err != nil {
+			return paid, err
+		}
+		if amount > 0 {
+			paid++
+		}
+	}
+
+	return paid, nil
+}
+
+// payment rounds what the account accrued to the cent and pays it from the bank's savings interest
+// account
+func payment(unpaid *Unpaid) *Payment {
+	amount := money.FromRat(unpaid.Amount, money.RoundHalfEven)
+	if amount <= 0 {
+		return &Payment{}
+	}
+
+	return &Payment{
+		Amount: amount,
+		Entry: ledger.NewEntry(
+			"savings interest",
+			ledger.SystemPosting(ledger.AccountSavingsInterest, unpaid.Currency, amount.Neg()),
+			ledger.AccountPosting(unpaid.AccountID, unpaid.Currency, amount),
+		),
+	}
+}
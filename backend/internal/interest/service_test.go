@@ -0,0 +1,303 @@
+package interest
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// MockRepo keeps the accruals in memory, keyed by account and day like the table
+type MockRepo struct {
+	Balances map[int64]money.Amount // the balance of each account on every day
+	Days     []time.Time
+	Accruals map[int64]map[time.Time]*Accrual
+	Paid     map[int64]map[time.Time]bool
+	Posted   []*ledger.Entry
+	Err      error
+}
+
+func NewMockRepo() *MockRepo {
+	return &MockRepo{
+		Balances: map[int64]money.Amount{},
+		Accruals: map[int64]map[time.Time]*Accrual{},
+		Paid:     map[int64]map[time.Time]bool{},
+	}
+}
+
+func (r *MockRepo) LastAccruedDay() (time.Time, bool, error) {
+	if len(r.Days) == 0 {
+		return time.Time{}, false, r.Err
+	}
+	return r.Days[len(r.Days)-1], true, r.Err
+}
+
+func (r *MockRepo) GetDayBalances(day time.Time) ([]*Accrual, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	var accruals []*Accrual
+	for id, balance := range r.Balances {
+		if balance > 0 {
+			accruals = append(accruals, &Accrual{
+				AccountID: id, Currency: "USD", Day: day, Balance: balance,
+			})
+		}
+	}
+	return accruals, nil
+}
+
+func (r *MockRepo) AccrueDayTx(day time.Time, accruals []*Accrual) error {
+	for _, accrual := range accruals {
+		if r.Accruals[accrual.AccountID] == nil {
+			r.Accruals[accrual.AccountID] = map[time.Time]*Accrual{}
+		}
+		if _, ok := r.Accruals[accrual.AccountID][day]; !ok {
+			r.Accruals[accrual.AccountID][day] = accrual
+		}
+	}
+	r.Days = append(r.Days, day)
+	return nil
+}
+
+func (r *MockRepo) GetUnpaidAccountIDs(before time.Time) ([]int64, error) {
+	var ids []int64
+	for id, days := range r.Accruals {
+		for day := range days {
+			if day.Before(before) && !r.Paid[id][day] {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (r *MockRepo) PayTx(
+	accountID int64, before time.Time, prepare func(unpaid *Unpaid) *Payment,
+) (money.Amount, error) {
+	unpaid := &Unpaid{AccountID: accountID, Currency: "USD", Amount: new(big.Rat)}
+	for day, accrual := range r.Accruals[accountID] {
+		if day.Before(before) && !r.Paid[accountID][day] {
+			unpaid.Amount.Add(unpaid.Amount, accrual.Amount)
+		}
+	}
+
+	payment := prepare(unpaid)
+	if payment.Entry == nil {
+		return 0, nil
+	}
+	r.Posted = append(r.Posted, payment.Entry)
+	if r.Paid[accountID] == nil {
+		r.Paid[accountID] = map[time.Time]bool{}
+	}
+	for day := range r.Accruals[accountID] {
+		if day.Before(before) {
+			r.Paid[accountID][day] = true
+		}
+	}
+	return payment.Amount, nil
+}
+
+func TestTiers(t *testing.T) {
+	tests := []struct {
+		name        string
+		flag        string
+		balance     money.Amount
+		want        string // the daily interest, to 8 decimal places
+		expectedErr bool
+	}{
+		{name: "one rate", flag: "0:3.65", balance: money.MustParse("1000"), want: "0.10000000"},
+		{
+			name: "in the first band", flag: "0:3.65,5000:7.3", balance: money.MustParse("1000"),
+			want: "0.10000000",
+		},
+		{
+			name: "across two bands", flag: "0:3.65, 5000:7.3", balance: money.MustParse("6000"),
+			want: "0.70000000",
+		},
+		{
+			name: "a fraction of a cent", flag: "0:1", balance: money.MustParse("10"),
+			want: "0.00027397",
+		},
+		{
+			name: "nothing on a negative balance", flag: "0:1", balance: money.MustParse("-10"),
+			want: "0.00000000",
+		},
+		{name: "not from 0", flag: "100:1", expectedErr: true},
+		{name: "bands out of order", flag: "0:1,5000:2,1000:3", expectedErr: true},
+		{name: "negative rate", flag: "0:-1", expectedErr: true},
+		{name: "no rate", flag: "0", expectedErr: true},
+		{name: "bad amount", flag: "abc:1", expectedErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var tiers Tiers
+			err := tiers.Set(tc.flag)
+			if gotErr := err != nil; gotErr != tc.expectedErr {
+				t.Fatalf("expected error=%v, got %v", tc.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := tiers.Daily(tc.balance).FloatString(accrualPrecision); got != tc.want {
+				t.Errorf("expected daily interest %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAccrue(t *testing.T) {
+	now := time.Date(2026, 10, 10, 3, 0, 0, 0, time.UTC)
+	tiers := Tiers{{From: 0, AnnualRate: fx.MustParseDecimal("3.65")}}
+
+	tests := []struct {
+		name        string
+		tiers       Tiers
+		lastDay     *time.Time
+		err         error
+		wantDays    int
+		expectedErr error
+	}{
+		{name: "first run accrues yesterday", tiers: tiers, wantDays: 1},
+		{
+			name: "already accrued today", tiers: tiers,
+			lastDay: ptr(time.Date(2026, 10, 9, 0, 0, 0, 0, time.UTC)), wantDays: 0,
+		},
+		{
+			name: "catches up after being down", tiers: tiers,
+			lastDay: ptr(time.Date(2026, 10, 6, 0, 0, 0, 0, time.UTC)), wantDays: 3,
+		},
+		{name: "no tiers", wantDays: 0},
+		{
+			name: "repo failure", tiers: tiers, err: errors.New("db error"),
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := NewMockRepo()
+			repo.Balances[1] = money.MustParse("1000")
+			repo.Balances[2] = money.MustParse("0")
+			repo.Err = tc.err
+			if tc.lastDay != nil {
+				repo.Days = append(repo.Days, *tc.lastDay)
+			}
+			svc := Service{Repo: repo, Tiers: tc.tiers}
+
+			days, gotErr := svc.Accrue(now)
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if days != tc.wantDays || len(repo.Accruals[1]) != tc.wantDays {
+				t.Errorf(
+					"expected %d days accrued, got %d with %d accruals", tc.wantDays, days,
+					len(repo.Accruals[1]),
+				)
+			}
+			if len(repo.Accruals[2]) != 0 {
+				t.Errorf("expected no interest on an empty account, got %d accruals",
+					len(repo.Accruals[2]))
+			}
+			for _, accrual := range repo.Accruals[1] {
+				if got := accrual.Amount.FloatString(2); got != "0.10" {
+					t.Errorf("expected 0.10 a day, got %s", got)
+				}
+			}
+
+			// running again straight away accrues nothing more
+			days, err := svc.Accrue(now)
+			if err != nil || days != 0 {
+				t.Errorf("expected a second run to accrue nothing, got %d days and %v", days, err)
+			}
+		})
+	}
+}
+
+func TestPayMonthly(t *testing.T) {
+	tests := []struct {
+		name     string
+		daily    string // what the account accrues a day
+		days     int
+		wantPaid money.Amount
+	}{
+		{name: "whole cents", daily: "0.10", days: 30, wantPaid: money.MustParse("3.00")},
+		{name: "rounded to the cent", daily: "0.0034", days: 30, wantPaid: money.MustParse("0.10")},
+		{name: "less than a cent stays accrued", daily: "0.0001", days: 30},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := NewMockRepo()
+			daily, _ := new(big.Rat).SetString(tc.daily)
+			september := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+			var accruals []*Accrual
+			for i := 0; i < tc.days; i++ {
+				accruals = append(accruals, &Accrual{
+					AccountID: 1, Currency: "USD", Day: september.AddDate(0, 0, i), Amount: daily,
+				})
+			}
+			for _, accrual := range accruals {
+				repo.AccrueDayTx(accrual.Day, []*Accrual{accrual})
+			}
+			// today's interest is not paid until next month
+			october := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+			repo.AccrueDayTx(october, []*Accrual{{AccountID: 1, Day: october, Amount: daily}})
+			tiers := Tiers{{From: 0, AnnualRate: fx.MustParseDecimal("1")}}
+			svc := Service{Repo: repo, Tiers: tiers}
+
+			now := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+			paid, err := svc.PayMonthly(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantCount := 0
+			if tc.wantPaid > 0 {
+				wantCount = 1
+			}
+			if paid != wantCount {
+				t.Fatalf("expected %d accounts paid, got %d", wantCount, paid)
+			}
+			if wantCount == 0 {
+				if len(repo.Posted) != 0 {
+					t.Errorf("expected nothing posted, got %d entries", len(repo.Posted))
+				}
+				return
+			}
+
+			entry := repo.Posted[0]
+			if entry.Postings[1].AccountID != 1 || entry.Postings[1].Amount != tc.wantPaid {
+				t.Errorf("expected %v paid into account 1, got %+v", tc.wantPaid, entry.Postings[1])
+			}
+			v := validator.New()
+			if ledger.ValidateEntry(v, entry); !v.IsValid() {
+				t.Errorf("unbalanced entry: %v", v.Errors)
+			}
+
+			// a restart in the same month pays nothing again
+			paid, err = svc.PayMonthly(now.Add(time.Hour))
+			if err != nil || paid != 0 || len(repo.Posted) != 1 {
+				t.Errorf("expected a second run to pay nothing, got %d paid and %v", paid, err)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,101 @@
+package ledger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// the bank's own accounts, these hold the other side of every movement in or out of a user account.
+// each of them is kept separately for every currency it is used in
+const (
+	AccountCash            = "SYSTEM:CASH"
+	AccountLoans           = "SYSTEM:LOANS"
+	AccountOpeningBalances = "SYSTEM:OPENING_BALANCES"
+	// AccountFX is the bank's position in each currency, it takes in one currency and pays out the
+	// other when money is converted
+	AccountFX = "SYSTEM:FX"
+	// AccountFXSpread collects the spread the bank keeps on conversions
+	AccountFXSpread = "SYSTEM:FX_SPREAD"
+	// AccountCardSettlement is what the bank owes the card networks for captured card payments
+	AccountCardSettlement = "SYSTEM:CARD_SETTLEMENT"
+	// AccountOverdraftInterest collects the interest charged on overdrawn accounts
+	AccountOverdraftInterest = "SYSTEM:OVERDRAFT_INTEREST"
+	// AccountSavingsInterest pays the interest earned on savings accounts
+	AccountSavingsInterest = "SYSTEM:SAVINGS_INTEREST"
+)
+
+// Entry is a journal entry, a single money movement made up of postings that must sum to zero in
+// every currency. Forced entries are charges the bank makes, they are let through even when they
+// take an account past its overdraft limit
+type Entry struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Description string     `json:"description"`
+	Postings    []*Posting `json:"postings"`
+	Forced      bool       `json:"-"`
+}
+
+// Posting is one leg of an entry. a positive amount increases the balance of the account and a
+// negative amount decreases it
+type Posting struct {
+	ID          int64        `json:"id"`
+	EntryID     int64        `json:"entry_id"`
+	AccountCode string       `json:"account_code"`
+	AccountID   int64        `json:"account_id,omitempty"` // 0 for the bank's own accounts
+	Currency    string       `json:"currency"`
+	Amount      money.Amount `json:"amount"`
+}
+
+// CustomerAccount returns the ledger account code of the customer account with the given ID
+func CustomerAccount(accountID int64) string {
+	return fmt.Sprintf("ACCOUNT:%d", accountID)
+}
+
+// AccountPosting creates a posting against a customer account, the currency must be the one the
+// account is held in
+func AccountPosting(accountID int64, currency string, amount money.Amount) *Posting {
+	return &Posting{
+		AccountCode: CustomerAccount(accountID),
+		AccountID:   accountID,
+		Currency:    currency,
+		Amount:      amount,
+	}
+}
+
+// SystemPosting creates a posting against one of the bank's own accounts in the currency
+func SystemPosting(accountCode, currency string, amount money.Amount) *Posting {
+	return &Posting{
+		AccountCode: accountCode,
+		Currency:    currency,
+		Amount:      amount,
+	}
+}
+
+func NewEntry(description string, postings ...*Posting) *Entry {
+	return &Entry{
+		Description: description,
+		Postings:    postings,
+	}
+}
+
+func ValidateEntry(v *validator.Validator, entry *Entry) {
+	v.CheckAddError(entry.Description != "", "description", "must be given")
+	v.CheckAddError(len(entry.Postings) >= 2, "postings", "must have at least two postings")
+
+	// money can't be moved between currencies within a posting, so each currency has to balance on
+	// its own
+	sums := make(map[string]money.Amount)
+	for _, posting := range entry.Postings {
+		v.CheckAddError(posting.AccountCode != "", "account code", "must be given")
+		v.CheckAddError(money.ValidCurrency(posting.Currency), "currency", "invalid")
+		v.CheckAddError(posting.Amount != 0, "amount", "must not be 0")
+		sums[posting.Currency] += posting.Amount
+	}
+
+	for _, sum := range sums {
+		v.CheckAddError(sum == 0, "postings", "must sum to zero in every currency")
+	}
+}
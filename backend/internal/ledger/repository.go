@@ -0,0 +1,321 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+	"github.com/lib/pq"
+)
+
+// HeldAmountColumn is the sum of the active holds on the account, for queries on the accounts
+// table. expired holds no longer count
+const HeldAmountColumn = `
+	(SELECT COALESCE(SUM(holds.amount), 0) FROM holds
+	WHERE holds.account_id = accounts.id AND holds.status = 'ACTIVE' AND holds.expires_at > NOW())
+`
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidEntry      = errors.New("invalid journal entry")
+	ErrNoAccount         = errors.New("posting against an account that does not exist")
+	ErrCurrencyMismatch  = errors.New("posting in a currency the account is not held in")
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// PostTx records the entry and its postings and applies them to the account balances in a single
+// database transaction, so either every leg of the movement is applied or none of them is
+func (r *Repository) PostTx(entry *Entry) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		return postTx(ctx, tx, entry)
+	})
+}
+
+// PostInTx validates and records the entry inside a transaction owned by the caller, for
+// movements that must commit together with other rows, such as the record of a transfer
+func PostInTx(ctx context.Context, tx *sql.Tx, entry *Entry) error {
+	v := validator.New()
+	if ValidateEntry(v, entry); !v.IsValid() {
+		return fmt.Errorf("%w: %v", ErrInvalidEntry, v.Errors)
+	}
+
+	return postTx(ctx, tx, entry)
+}
+
+func postTx(ctx context.Context, tx *sql.Tx, entry *Entry) error {
+	err := lockAccountsTx(ctx, tx, entry)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO journal_entries (description)
+		VALUES ($1)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRowContext(ctx, query, entry.Description).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, posting := range entry.Postings {
+		posting.EntryID = entry.ID
+
+		accountID, err := accountIDTx(ctx, tx, posting)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO postings (entry_id, account_id, currency, amount)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`
+		err = tx.QueryRowContext(
+			ctx, query, entry.ID, accountID, posting.Currency, posting.Amount,
+		).Scan(&posting.ID)
+		if err != nil {
+			return err
+		}
+
+		if posting.AccountID == 0 {
+			continue
+		}
+
+		// keep the balance on the accounts row in step with the postings, it is only a cached sum
+		// of them and is never written anywhere else
+		query = `
+			UPDATE accounts
+			SET balance = balance + $1, version = version + 1
+			WHERE id = $2
+			RETURNING balance, ` + HeldAmountColumn + `, overdraft_limit
+		`
+		var balance, held, overdraftLimit money.Amount
+		err = tx.QueryRowContext(
+			ctx, query, posting.Amount, posting.AccountID,
+		).Scan(&balance, &held, &overdraftLimit)
+		if err != nil {
+			return err
+		}
+
+		// a debit can take the account below zero as far as its overdraft limit, less the money
+		// set aside by holds. the capture of a hold releases it first, and credits are always let
+		// through, even into an account that is still past its limit
+		if posting.Amount < 0 && !entry.Forced && balance-held < -overdraftLimit {
+			return ErrInsufficientFunds
+		}
+	}
+
+	return nil
+}
+
+// lockAccountsTx locks the rows of every account the entry touches and checks the postings are in
+// the currencies of the accounts. the rows are always locked in ID order, so two entries between
+// the same accounts can't each hold one lock and wait for the other
+func lockAccountsTx(ctx context.Context, tx *sql.Tx, entry *Entry) error {
+	var accountIDs []int64
+	for _, posting := range entry.Postings {
+		if posting.AccountID != 0 && !slices.Contains(accountIDs, posting.AccountID) {
+			accountIDs = append(accountIDs, posting.AccountID)
+		}
+	}
+	if len(accountIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT id, currency
+		FROM accounts
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(accountIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	currencies := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var currency string
+		if err = rows.Scan(&id, &currency); err != nil {
+			return err
+		}
+		currencies[id] = currency
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(currencies) != len(accountIDs) {
+		return ErrNoAccount
+	}
+
+	for _, posting := range entry.Postings {
+		if posting.AccountID != 0 && posting.Currency != currencies[posting.AccountID] {
+			return ErrCurrencyMismatch
+		}
+	}
+
+	return nil
+}
+
+// accountIDTx returns the id of the ledger account for the posting in its currency, creating the
+// account the first time it is used. the system accounts are in almost every entry, so the row is
+// only read when it exists, writing it would have every posting wait on the same row
+func accountIDTx(ctx context.Context, tx *sql.Tx, posting *Posting) (int64, error) {
+	query := `
+		SELECT id
+		FROM ledger_accounts
+		WHERE code = $1 AND currency = $2
+	`
+	var id int64
+	err := tx.QueryRowContext(ctx, query, posting.AccountCode, posting.Currency).Scan(&id)
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return id, err
+	}
+
+	// another transaction can create it first, then it is read again
+	insert := `
+		INSERT INTO ledger_accounts (code, currency, account_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (code, currency) DO NOTHING
+	`
+	accountID := sql.NullInt64{Int64: posting.AccountID, Valid: posting.AccountID != 0}
+	_, err = tx.ExecContext(ctx, insert, posting.AccountCode, posting.Currency, accountID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRowContext(ctx, query, posting.AccountCode, posting.Currency).Scan(&id)
+	return id, err
+}
+
+// Balance returns the balance of the account as derived from its postings. it is meant for
+// customer accounts, which only ever have postings in the currency they are held in
+func (r *Repository) Balance(accountCode string) (money.Amount, error) {
+	query := `
+		SELECT COALESCE(SUM(postings.amount), 0)
+		FROM postings
+		INNER JOIN ledger_accounts
+		ON ledger_accounts.id = postings.account_id
+		WHERE ledger_accounts.code = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var balance money.Amount
+	err := r.DB.QueryRowContext(ctx, query, accountCode).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// GetEntriesForAccount returns every entry that has a posting against the account, oldest first
+func (r *Repository) GetEntriesForAccount(accountCode string) ([]*Entry, error) {
+	query := `
+		SELECT journal_entries.id, journal_entries.created_at, journal_entries.description,
+			postings.id, ledger_accounts.code, COALESCE(ledger_accounts.account_id, 0),
+			postings.currency, postings.amount
+		FROM journal_entries
+		INNER JOIN postings
+		ON postings.entry_id = journal_entries.id
+		INNER JOIN ledger_accounts
+		ON ledger_accounts.id = postings.account_id
+		WHERE journal_entries.id IN (
+			SELECT postings.entry_id
+			FROM postings
+			INNER JOIN ledger_accounts
+			ON ledger_accounts.id = postings.account_id
+			WHERE ledger_accounts.code = $1
+		)
+		ORDER BY journal_entries.id, postings.id
+	`
+
+	return r.getEntries(query, accountCode)
+}
+
+// GetEntriesForUser returns every entry that has a posting against any of the user's accounts,
+// oldest first
+func (r *Repository) GetEntriesForUser(userID int64) ([]*Entry, error) {
+	query := `
+		SELECT journal_entries.id, journal_entries.created_at, journal_entries.description,
+			postings.id, ledger_accounts.code, COALESCE(ledger_accounts.account_id, 0),
+			postings.currency, postings.amount
+		FROM journal_entries
+		INNER JOIN postings
+		ON postings.entry_id = journal_entries.id
+		INNER JOIN ledger_accounts
+		ON ledger_accounts.id = postings.account_id
+		WHERE journal_entries.id IN (
+			SELECT postings.entry_id
+			FROM postings
+			INNER JOIN ledger_accounts
+			ON ledger_accounts.id = postings.account_id
+			INNER JOIN accounts
+			ON accounts.id = ledger_accounts.account_id
+			WHERE accounts.user_id = $1
+		)
+		ORDER BY journal_entries.id, postings.id
+	`
+
+	return r.getEntries(query, userID)
+}
+
+func (r *Repository) getEntries(query string, args ...any) ([]*Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		entry := &Entry{}
+		posting := &Posting{}
+		err = rows.Scan(
+			&entry.ID,
+			&entry.CreatedAt,
+			&entry.Description,
+			&posting.ID,
+			&posting.AccountCode,
+			&posting.AccountID,
+			&posting.Currency,
+			&posting.Amount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posting.EntryID = entry.ID
+
+		// the rows are ordered by entry so the postings of an entry are next to each other
+		if len(entries) > 0 && entries[len(entries)-1].ID == entry.ID {
+			entry = entries[len(entries)-1]
+		} else {
+			entries = append(entries, entry)
+		}
+		entry.Postings = append(entry.Postings, posting)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
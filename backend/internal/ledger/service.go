@@ -0,0 +1,55 @@
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type Repo interface {
+	PostTx(entry *Entry) error
+	Balance(accountCode string) (money.Amount, error)
+	GetEntriesForAccount(accountCode string) ([]*Entry, error)
+	GetEntriesForUser(userID int64) ([]*Entry, error)
+}
+
+type Service struct {
+	Repo Repo
+}
+
+// Post validates the entry and records it. every balance change in the system goes through here
+func (s *Service) Post(entry *Entry) error {
+	v := validator.New()
+	if ValidateEntry(v, entry); !v.IsValid() {
+		return fmt.Errorf("%w: %v", ErrInvalidEntry, v.Errors)
+	}
+
+	return s.Repo.PostTx(entry)
+}
+
+// AccountBalance returns the account's balance as derived from the postings on it
+func (s *Service) AccountBalance(accountID int64) (money.Amount, error) {
+	return s.Repo.Balance(CustomerAccount(accountID))
+}
+
+// CheckAccountBalance compares the balance recorded on the account with the one derived from the
+// ledger and returns the difference, a non-zero difference means the recorded balance has drifted
+func (s *Service) CheckAccountBalance(
+	accountID int64, recordedBalance money.Amount,
+) (money.Amount, error) {
+	ledgerBalance, err := s.AccountBalance(accountID)
+	if err != nil {
+		return 0, err
+	}
+
+	return recordedBalance - ledgerBalance, nil
+}
+
+func (s *Service) GetAccountEntries(accountID int64) ([]*Entry, error) {
+	return s.Repo.GetEntriesForAccount(CustomerAccount(accountID))
+}
+
+func (s *Service) GetUserEntries(userID int64) ([]*Entry, error) {
+	return s.Repo.GetEntriesForUser(userID)
+}
@@ -0,0 +1,203 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+)
+
+// ---MOCKS---
+type MockRepo struct {
+	PostTxErr error
+	Posted    []*Entry
+
+	BalanceResult money.Amount
+	BalanceErr    error
+}
+
+func (r *MockRepo) PostTx(entry *Entry) error {
+	if r.PostTxErr != nil {
+		return r.PostTxErr
+	}
+	r.Posted = append(r.Posted, entry)
+	return nil
+}
+
+func (r *MockRepo) Balance(accountCode string) (money.Amount, error) {
+	return r.BalanceResult, r.BalanceErr
+}
+
+func (r *MockRepo) GetEntriesForAccount(accountCode string) ([]*Entry, error) {
+	return nil, nil
+}
+
+func (r *MockRepo) GetEntriesForUser(userID int64) ([]*Entry, error) {
+	return nil, nil
+}
+
+func TestPost(t *testing.T) {
+	tests := []struct {
+		name        string
+		setupRepo   func(*MockRepo)
+		entry       *Entry
+		wantPosted  bool
+		expectedErr error
+	}{
+		{
+			name:      "valid",
+			setupRepo: func(r *MockRepo) {},
+			entry: NewEntry(
+				"transfer", AccountPosting(1, "USD", money.MustParse("-10")), AccountPosting(2, "USD", money.MustParse("10")),
+			),
+			wantPosted: true,
+		},
+		{
+			name:      "valid, many legs",
+			setupRepo: func(r *MockRepo) {},
+			entry: NewEntry(
+				"split", AccountPosting(1, "USD", money.MustParse("-10.5")), AccountPosting(2, "USD", money.MustParse("0.25")), SystemPosting(AccountCash, "USD", money.MustParse("10.25")),
+			),
+			wantPosted: true,
+		},
+		{
+			name:      "unbalanced",
+			setupRepo: func(r *MockRepo) {},
+			entry: NewEntry(
+				"transfer", AccountPosting(1, "USD", money.MustParse("-10")), AccountPosting(2, "USD", money.MustParse("9.99")),
+			),
+			expectedErr: ErrInvalidEntry,
+		},
+		{
+			name:      "valid, two currencies",
+			setupRepo: func(r *MockRepo) {},
+			entry: NewEntry(
+				"fx transfer",
+				AccountPosting(1, "USD", money.MustParse("-10")), SystemPosting(AccountFX, "USD", money.MustParse("10")),
+				AccountPosting(2, "EUR", money.MustParse("9")), SystemPosting(AccountFX, "EUR", money.MustParse("-9")),
+			),
+			wantPosted: true,
+		},
+		{
+			name:      "balanced overall but not per currency",
+			setupRepo: func(r *MockRepo) {},
+			entry: NewEntry(
+				"fx transfer", AccountPosting(1, "USD", money.MustParse("-10")), AccountPosting(2, "EUR", money.MustParse("10")),
+			),
+			expectedErr: ErrInvalidEntry,
+		},
+		{
+			name:      "unknown currency",
+			setupRepo: func(r *MockRepo) {},
+			entry: NewEntry(
+				"transfer", AccountPosting(1, "XYZ", money.MustParse("-10")), AccountPosting(2, "XYZ", money.MustParse("10")),
+			),
+			expectedErr: ErrInvalidEntry,
+		},
+		{
+			name:        "single posting",
+			setupRepo:   func(r *MockRepo) {},
+			entry:       NewEntry("deposit", AccountPosting(1, "USD", money.MustParse("10"))),
+			expectedErr: ErrInvalidEntry,
+		},
+		{
+			name:      "zero amount posting",
+			setupRepo: func(r *MockRepo) {},
+			entry: NewEntry(
+				"transfer", AccountPosting(1, "USD", money.MustParse("0")), AccountPosting(2, "USD", money.MustParse("0")),
+			),
+			expectedErr: ErrInvalidEntry,
+		},
+		{
+			name:      "missing description",
+			setupRepo: func(r *MockRepo) {},
+			entry: NewEntry(
+				"", AccountPosting(1, "USD", money.MustParse("-10")), AccountPosting(2, "USD", money.MustParse("10")),
+			),
+			expectedErr: ErrInvalidEntry,
+		},
+		{
+			name: "insufficient funds",
+			setupRepo: func(r *MockRepo) {
+				r.PostTxErr = ErrInsufficientFunds
+			},
+			entry: NewEntry(
+				"transfer", AccountPosting(1, "USD", money.MustParse("-10")), AccountPosting(2, "USD", money.MustParse("10")),
+			),
+			expectedErr: ErrInsufficientFunds,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			tc.setupRepo(repo)
+			svc := Service{Repo: repo}
+
+			gotErr := svc.Post(tc.entry)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+
+			if gotPosted := len(repo.Posted) == 1; gotPosted != tc.wantPosted {
+				t.Errorf("expected posted=%v, got posted=%v", tc.wantPosted, gotPosted)
+			}
+		})
+	}
+}
+
+func TestCheckAccountBalance(t *testing.T) {
+	tests := []struct {
+		name            string
+		setupRepo       func(*MockRepo)
+		recordedBalance money.Amount
+		wantDifference  money.Amount
+		expectedErr     error
+	}{
+		{
+			name: "in step",
+			setupRepo: func(r *MockRepo) {
+				r.BalanceResult = money.MustParse("100.10")
+			},
+			recordedBalance: money.MustParse("100.10"),
+			wantDifference:  money.MustParse("0"),
+		},
+		{
+			name: "drifted",
+			setupRepo: func(r *MockRepo) {
+				r.BalanceResult = money.MustParse("90")
+			},
+			recordedBalance: money.MustParse("100"),
+			wantDifference:  money.MustParse("10"),
+		},
+		{
+			name: "db failure",
+			setupRepo: func(r *MockRepo) {
+				r.BalanceErr = errors.New("db error")
+			},
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			tc.setupRepo(repo)
+			svc := Service{Repo: repo}
+
+			gotDifference, gotErr := svc.CheckAccountBalance(1, tc.recordedBalance)
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if gotDifference != tc.wantDifference {
+				t.Errorf("expected difference=%v, got difference=%v", tc.wantDifference, gotDifference)
+			}
+		})
+	}
+}
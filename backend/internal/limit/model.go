@@ -0,0 +1,62 @@
+package limit
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// Limits caps how much a user can send and withdraw across all of their accounts. the limits are in
+// Currency, and money leaving an account held in another currency counts at the current rate. the
+// daily and weekly limits are on the rolling sum of the last 24 hours and 7 days. a limit of 0
+// means there is no limit
+type Limits struct {
+	Currency       string       `json:"currency"`
+	PerTransaction money.Amount `json:"per_transaction"`
+	Daily          money.Amount `json:"daily"`
+	Weekly         money.Amount `json:"weekly"`
+}
+
+// Spent is what a user sent and withdrew in one currency over the last day and week
+type Spent struct {
+	Currency string
+	Day      money.Amount
+	Week     money.Amount
+}
+
+// Override is a user's own limits, set by an admin, in the currency of the defaults. a nil limit
+// falls back to the default
+type Override struct {
+	UserID         int64         `json:"user_id"`
+	PerTransaction *money.Amount `json:"per_transaction"`
+	Daily          *money.Amount `json:"daily"`
+	Weekly         *money.Amount `json:"weekly"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// Apply returns the defaults with the limits the override sets in place of them
+func (o *Override) Apply(defaults Limits) Limits {
+	limits := defaults
+	if o.PerTransaction != nil {
+		limits.PerTransaction = *o.PerTransaction
+	}
+	if o.Daily != nil {
+		limits.Daily = *o.Daily
+	}
+	if o.Weekly != nil {
+		limits.Weekly = *o.Weekly
+	}
+	return limits
+}
+
+func ValidateOverride(v *validator.Validator, override *Override) {
+	v.CheckAddError(override.UserID > 0, "user id", "must be given")
+	for key, amount := range map[string]*money.Amount{
+		"per transaction": override.PerTransaction,
+		"daily":           override.Daily,
+		"weekly":          override.Weekly,
+	} {
+		v.CheckAddError(amount == nil || *amount >= 0, key, "must not be negative")
+	}
+}
@@ -0,0 +1,150 @@
+package limit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+func (r *Repository) GetOverride(userID int64) (*Override, error) {
+	query := `
+		SELECT user_id, per_transaction, daily, weekly, updated_at
+		FROM user_limits
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var override Override
+	err := r.DB.QueryRowContext(ctx, query, userID).Scan(
+		&override.UserID,
+		&override.PerTransaction,
+		&override.Daily,
+		&override.Weekly,
+		&override.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return &override, nil
+}
+
+// SaveOverride sets the user's limits, replacing any they had before
+func (r *Repository) SaveOverride(override *Override) error {
+	query := `
+		INSERT INTO user_limits (user_id, per_transaction, daily, weekly)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET per_transaction = EXCLUDED.per_transaction, daily = EXCLUDED.daily,
+			weekly = EXCLUDED.weekly, updated_at = NOW()
+		RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(
+		ctx, query, override.UserID, override.PerTransaction, override.Daily, override.Weekly,
+	).Scan(&override.UpdatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "user_limits" violates foreign key constraint "user_limits_user_id_fkey"`:
+			return user.ErrNoRecord
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Spent sums what the user sent in transfers and withdrew since daySince and since weekSince, in
+// each currency they spent in. refunds and reversals only send money back, so they don't count
+func (r *Repository) Spent(userID int64, daySince, weekSince time.Time) ([]*Spent, error) {
+	var spending []*Spent
+	err := dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		spending, err = spentTx(ctx, tx, userID, daySince, weekSince)
+		return err
+	})
+	return spending, err
+}
+
+// LockSpentTx is Spent in the caller's transaction. the user's row is locked first, so that no
+// other transaction can check the user's limits until this one ends
+func (r *Repository) LockSpentTx(
+	ctx context.Context, tx *sql.Tx, userID int64, daySince, weekSince time.Time,
+) ([]*Spent, error) {
+	query := `
+		SELECT id
+		FROM users
+		WHERE id = $1
+		FOR UPDATE
+	`
+	var id int64
+	err := tx.QueryRowContext(ctx, query, userID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return spentTx(ctx, tx, userID, daySince, weekSince)
+}
+
+func spentTx(
+	ctx context.Context, tx *sql.Tx, userID int64, daySince, weekSince time.Time,
+) ([]*Spent, error) {
+	query := `
+		SELECT currency, COALESCE(SUM(amount) FILTER (WHERE created_at > $2), 0), SUM(amount)
+		FROM (
+			SELECT currency, amount, created_at
+			FROM transfers
+			WHERE from_user_id = $1 AND kind = 'TRANSFER' AND created_at > $3
+			UNION ALL
+			SELECT currency, amount, created_at
+			FROM transactions
+			WHERE user_id = $1 AND action = 'WITHDRAW' AND created_at > $3
+		) AS outgoing
+		GROUP BY currency
+	`
+
+	rows, err := tx.QueryContext(ctx, query, userID, daySince, weekSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spending []*Spent
+	for rows.Next() {
+		var s Spent
+		err = rows.Scan(&s.Currency, &s.Day, &s.Week)
+		if err != nil {
+			return nil, err
+		}
+		spending = append(spending, &s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return spending, nil
+}
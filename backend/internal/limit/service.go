@@ -0,0 +1,208 @@
+package limit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type LimitRepo interface {
+	GetOverride(userID int64) (*Override, error)
+	SaveOverride(override *Override) error
+	Spent(userID int64, daySince, weekSince time.Time) ([]*Spent, error)
+	LockSpentTx(
+		ctx context.Context, tx *sql.Tx, userID int64, daySince, weekSince time.Time,
+	) ([]*Spent, error)
+}
+
+type FX interface {
+	GetRate(fromCurrency, toCurrency string) (*fx.Rate, error)
+}
+
+// Service checks what users spend against their limits. FX converts what is spent in other
+// currencies than the limits are in, it is only needed when something is
+type Service struct {
+	Repo     LimitRepo
+	FX       FX
+	Defaults Limits
+}
+
+// GetUserLimits returns the limits that apply to the user, the defaults with any of their own in
+// place of them
+func (s *Service) GetUserLimits(userID int64) (Limits, error) {
+	override, err := s.Repo.GetOverride(userID)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			return s.defaults(), nil
+		}
+		return Limits{}, err
+	}
+
+	return override.Apply(s.defaults()), nil
+}
+
+// defaults are the default limits, in the default currency when they don't say
+func (s *Service) defaults() Limits {
+	defaults := s.Defaults
+	if defaults.Currency == "" {
+		defaults.Currency = money.DefaultCurrency
+	}
+	return defaults
+}
+
+// SetUserLimits saves the user's own limits. a nil limit goes back to the default
+func (s *Service) SetUserLimits(v *validator.Validator, override *Override) (Limits, error) {
+	if ValidateOverride(v, override); !v.IsValid() {
+		return Limits{}, validator.ErrFailedValidation
+	}
+
+	err := s.Repo.SaveOverride(override)
+	if err != nil {
+		return Limits{}, err
+	}
+
+	return override.Apply(s.defaults()), nil
+}
+
+// Check checks the user can send or withdraw the amount, in the currency, without going over their
+// limits. each limit that would be gone over is added to the validator with how much the user has
+// left of it, in the currency of the limits
+func (s *Service) Check(
+	v *validator.Validator, userID int64, currency string, amount money.Amount,
+) error {
+	limits, err := s.GetUserLimits(userID)
+	if err != nil {
+		return err
+	}
+
+	return s.check(v, limits, currency, amount, 0,
+		func(daySince, weekSince time.Time) ([]*Spent, error) {
+			return s.Repo.Spent(userID, daySince, weekSince)
+		},
+	)
+}
+
+// CheckInTx is Check made again in the database transaction the amount is spent in, once it has
+// been recorded there. the user is locked while their spending is read, so that operations made at
+// the same time can't go over the limits together, each having checked before the other was made
+func (s *Service) CheckInTx(
+	v *validator.Validator, userID int64, currency string, amount money.Amount,
+) dbtx.Func {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		limits, err := s.GetUserLimits(userID)
+		if err != nil {
+			return err
+		}
+
+		return s.check(v, limits, currency, amount, amount,
+			func(daySince, weekSince time.Time) ([]*Spent, error) {
+				return s.Repo.LockSpentTx(ctx, tx, userID, daySince, weekSince)
+			},
+		)
+	}
+}
+
+// check checks the amount against the limits, with what getSpent says the user spent. recorded is
+// how much of the amount getSpent already counts
+func (s *Service) check(
+	v *validator.Validator, limits Limits, currency string, amount, recorded money.Amount,
+	getSpent func(daySince, weekSince time.Time) ([]*Spent, error),
+) error {
+	if limits.PerTransaction == 0 && limits.Daily == 0 && limits.Weekly == 0 {
+		return nil
+	}
+
+	amount, err := s.convert(v, amount, currency, limits.Currency)
+	if err != nil {
+		return err
+	}
+
+	if limits.PerTransaction != 0 && amount > limits.PerTransaction {
+		v.AddError(
+			"per transaction limit",
+			"exceeded, at most "+limits.PerTransaction.String()+" "+limits.Currency+
+				" per transaction",
+		)
+	}
+
+	if limits.Daily != 0 || limits.Weekly != 0 {
+		now := time.Now()
+		spending, err := getSpent(now.Add(-24*time.Hour), now.Add(-7*24*time.Hour))
+		if err != nil {
+			return err
+		}
+
+		var day, week money.Amount
+		for _, spent := range spending {
+			if spent.Currency == currency {
+				spent.Day -= recorded
+				spent.Week -= recorded
+			}
+
+			converted, err := s.convert(v, spent.Day, spent.Currency, limits.Currency)
+			if err != nil {
+				return err
+			}
+			day += converted
+			converted, err = s.convert(v, spent.Week, spent.Currency, limits.Currency)
+			if err != nil {
+				return err
+			}
+			week += converted
+		}
+
+		checkRolling(v, "daily limit", limits.Daily, day, amount, limits.Currency)
+		checkRolling(v, "weekly limit", limits.Weekly, week, amount, limits.Currency)
+	}
+
+	if !v.IsValid() {
+		return validator.ErrFailedValidation
+	}
+
+	return nil
+}
+
+// convert converts the amount to the currency of the limits at the current rate, without the
+// spread the bank keeps on a real conversion
+func (s *Service) convert(
+	v *validator.Validator, amount money.Amount, fromCurrency, toCurrency string,
+) (money.Amount, error) {
+	if fromCurrency == toCurrency || amount == 0 {
+		return amount, nil
+	}
+
+	rate, err := s.FX.GetRate(fromCurrency, toCurrency)
+	if err != nil {
+		if errors.Is(err, fx.ErrNoRate) {
+			v.AddError(
+				"currency", "no exchange rate from "+fromCurrency+" to "+toCurrency+
+					" to check the limits in",
+			)
+			return 0, validator.ErrFailedValidation
+		}
+		return 0, err
+	}
+
+	return amount.Mul(rate.Rate.Rat(), money.RoundHalfEven), nil
+}
+
+// checkRolling checks the amount fits in what is left of the limit after what was already spent
+func checkRolling(
+	v *validator.Validator, key string, limit, spent, amount money.Amount, currency string,
+) {
+	if limit == 0 {
+		return
+	}
+
+	remaining := money.Max(limit-spent, 0)
+	v.CheckAddError(
+		amount <= remaining, key, "exceeded, "+remaining.String()+" "+currency+" remaining",
+	)
+}
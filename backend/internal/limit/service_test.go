@@ -0,0 +1,364 @@
+package limit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// MockRepo says the user spent Day and Week in USD, and Other in other currencies
+type MockRepo struct {
+	Override *Override
+	GetErr   error
+	SaveErr  error
+	Day      money.Amount
+	Week     money.Amount
+	Other    []*Spent
+	SpentErr error
+	Locked   bool
+}
+
+func (r *MockRepo) GetOverride(userID int64) (*Override, error) {
+	if r.GetErr != nil {
+		return nil, r.GetErr
+	}
+	if r.Override == nil {
+		return nil, user.ErrNoRecord
+	}
+	return r.Override, nil
+}
+
+func (r *MockRepo) SaveOverride(override *Override) error {
+	if r.SaveErr != nil {
+		return r.SaveErr
+	}
+	r.Override = override
+	return nil
+}
+
+func (r *MockRepo) Spent(userID int64, daySince, weekSince time.Time) ([]*Spent, error) {
+	if r.SpentErr != nil {
+		return nil, r.SpentErr
+	}
+	spending := []*Spent{{Currency: "USD", Day: r.Day, Week: r.Week}}
+	for _, other := range r.Other {
+		spent := *other
+		spending = append(spending, &spent)
+	}
+	return spending, nil
+}
+
+func (r *MockRepo) LockSpentTx(
+	ctx context.Context, tx *sql.Tx, userID int64, daySince, weekSince time.Time,
+) ([]*Spent, error) {
+	r.Locked = true
+	return r.Spent(userID, daySince, weekSince)
+}
+
+// MockFX has a rate of 2 USD to the EUR and none for other currencies
+type MockFX struct{}
+
+func (m *MockFX) GetRate(fromCurrency, toCurrency string) (*fx.Rate, error) {
+	switch {
+	case fromCurrency == "EUR" && toCurrency == "USD":
+		return &fx.Rate{Rate: fx.MustParseDecimal("2")}, nil
+	case fromCurrency == "USD" && toCurrency == "EUR":
+		return &fx.Rate{Rate: fx.MustParseDecimal("0.5")}, nil
+	}
+	return nil, fx.ErrNoRate
+}
+
+func amount(s string) *money.Amount {
+	a := money.MustParse(s)
+	return &a
+}
+
+func TestCheck(t *testing.T) {
+	errDB := errors.New("db error")
+	defaults := Limits{
+		PerTransaction: money.MustParse("500"),
+		Daily:          money.MustParse("1000"),
+		Weekly:         money.MustParse("2000"),
+	}
+
+	tests := []struct {
+		name        string
+		setupRepo   func(*MockRepo)
+		currency    string
+		amount      money.Amount
+		wantErrors  map[string]string
+		expectedErr error
+	}{
+		{
+			name:   "within the limits",
+			amount: money.MustParse("500"),
+		},
+		{
+			name:     "amount in another currency",
+			currency: "EUR",
+			amount:   money.MustParse("250.01"),
+			wantErrors: map[string]string{
+				"per transaction limit": "exceeded, at most 500.00 USD per transaction",
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "spent in another currency",
+			setupRepo: func(r *MockRepo) {
+				r.Day = money.MustParse("100")
+				r.Week = money.MustParse("100")
+				r.Other = []*Spent{{
+					Currency: "EUR", Day: money.MustParse("400"), Week: money.MustParse("400"),
+				}}
+			},
+			amount: money.MustParse("150"),
+			wantErrors: map[string]string{
+				"daily limit": "exceeded, 100.00 USD remaining",
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:     "no rate to the currency of the limits",
+			currency: "KES",
+			amount:   money.MustParse("1"),
+			wantErrors: map[string]string{
+				"currency": "no exchange rate from KES to USD to check the limits in",
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:   "over the per transaction limit",
+			amount: money.MustParse("500.01"),
+			wantErrors: map[string]string{
+				"per transaction limit": "exceeded, at most 500.00 USD per transaction",
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "over what is left today",
+			setupRepo: func(r *MockRepo) {
+				r.Day = money.MustParse("800")
+				r.Week = money.MustParse("800")
+			},
+			amount: money.MustParse("300"),
+			wantErrors: map[string]string{
+				"daily limit": "exceeded, 200.00 USD remaining",
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "over what is left this week",
+			setupRepo: func(r *MockRepo) {
+				r.Day = money.MustParse("100")
+				r.Week = money.MustParse("1900")
+			},
+			amount: money.MustParse("150"),
+			wantErrors: map[string]string{
+				"weekly limit": "exceeded, 100.00 USD remaining",
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "already spent more than the limit",
+			setupRepo: func(r *MockRepo) {
+				r.Day = money.MustParse("1200")
+				r.Week = money.MustParse("1200")
+			},
+			amount: money.MustParse("1"),
+			wantErrors: map[string]string{
+				"daily limit": "exceeded, 0.00 USD remaining",
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "user's own limits",
+			setupRepo: func(r *MockRepo) {
+				r.Override = &Override{UserID: 1, PerTransaction: amount("5000")}
+				r.Day = money.MustParse("200")
+				r.Week = money.MustParse("200")
+			},
+			amount: money.MustParse("800"),
+		},
+		{
+			name: "user without limits",
+			setupRepo: func(r *MockRepo) {
+				zero := money.Amount(0)
+				r.Override = &Override{
+					UserID: 1, PerTransaction: &zero, Daily: &zero, Weekly: &zero,
+				}
+				r.SpentErr = errDB // not needed without a daily or weekly limit
+			},
+			amount: money.MustParse("1000000"),
+		},
+		{
+			name: "GetOverride failure",
+			setupRepo: func(r *MockRepo) {
+				r.GetErr = errDB
+			},
+			amount:      money.MustParse("1"),
+			expectedErr: errDB,
+		},
+		{
+			name: "Spent failure",
+			setupRepo: func(r *MockRepo) {
+				r.SpentErr = errDB
+			},
+			amount:      money.MustParse("1"),
+			expectedErr: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			if tc.setupRepo != nil {
+				tc.setupRepo(repo)
+			}
+			svc := Service{Repo: repo, FX: &MockFX{}, Defaults: defaults}
+			currency := tc.currency
+			if currency == "" {
+				currency = "USD"
+			}
+
+			v := validator.New()
+			gotErr := svc.Check(v, 1, currency, tc.amount)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+
+			for key, msg := range tc.wantErrors {
+				if v.Errors[key] != msg {
+					t.Errorf("expected %s error %q, got %q", key, msg, v.Errors[key])
+				}
+			}
+			if len(v.Errors) != len(tc.wantErrors) {
+				t.Errorf("expected errors %v, got %v", tc.wantErrors, v.Errors)
+			}
+		})
+	}
+}
+
+func TestCheckInTx(t *testing.T) {
+	defaults := Limits{Daily: money.MustParse("1000")}
+
+	tests := []struct {
+		name       string
+		day        money.Amount
+		amount     money.Amount
+		wantErrors map[string]string
+	}{
+		{
+			name:   "the amount recorded fits",
+			day:    money.MustParse("1000"),
+			amount: money.MustParse("300"),
+		},
+		{
+			name:   "made over the limit at the same time as another",
+			day:    money.MustParse("1300"),
+			amount: money.MustParse("300"),
+			wantErrors: map[string]string{
+				"daily limit": "exceeded, 0.00 USD remaining",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{Day: tc.day, Week: tc.day}
+			svc := Service{Repo: repo, Defaults: defaults}
+
+			v := validator.New()
+			err := svc.CheckInTx(v, 1, "USD", tc.amount)(context.Background(), nil)
+			if tc.wantErrors == nil && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tc.wantErrors != nil && !errors.Is(err, validator.ErrFailedValidation) {
+				t.Fatalf("expected error %v, got %v", validator.ErrFailedValidation, err)
+			}
+			for key, msg := range tc.wantErrors {
+				if v.Errors[key] != msg {
+					t.Errorf("expected %s error %q, got %q", key, msg, v.Errors[key])
+				}
+			}
+			if !repo.Locked {
+				t.Error("expected the user locked while their spending is read")
+			}
+		})
+	}
+}
+
+func TestSetUserLimits(t *testing.T) {
+	defaults := Limits{
+		Currency:       "USD",
+		PerTransaction: money.MustParse("500"),
+		Daily:          money.MustParse("1000"),
+		Weekly:         money.MustParse("2000"),
+	}
+
+	tests := []struct {
+		name        string
+		override    *Override
+		want        Limits
+		expectedErr error
+	}{
+		{
+			name:     "some limits",
+			override: &Override{UserID: 1, Daily: amount("3000")},
+			want: Limits{
+				Currency:       "USD",
+				PerTransaction: money.MustParse("500"),
+				Daily:          money.MustParse("3000"),
+				Weekly:         money.MustParse("2000"),
+			},
+		},
+		{
+			name:     "back to the defaults",
+			override: &Override{UserID: 1},
+			want:     defaults,
+		},
+		{
+			name:        "negative limit",
+			override:    &Override{UserID: 1, Weekly: amount("-1")},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:        "no user",
+			override:    &Override{Daily: amount("3000")},
+			expectedErr: validator.ErrFailedValidation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			svc := Service{Repo: repo, Defaults: defaults}
+
+			got, gotErr := svc.SetUserLimits(validator.New(), tc.override)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if got != tc.want {
+				t.Errorf("expected limits %+v, got %+v", tc.want, got)
+			}
+
+			got, err := svc.GetUserLimits(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("expected saved limits %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package loan
 
 import (
+	"math/big"
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
@@ -10,10 +12,12 @@ type Loan struct {
 	ID                int64
 	CreatedAt         time.Time
 	UserID            int64
-	Amount            float64
+	AccountID         int64
+	Currency          string
+	Amount            money.Amount
 	Action            string
 	DailyInterestRate float64
-	RemainingAmount   float64
+	RemainingAmount   money.Amount
 	LastUpdatedAt     time.Time
 	Version           int32
 }
@@ -26,12 +30,28 @@ type LoanDeletion struct {
 	LoanID            int64
 	DebtorID          int64
 	DeletedByID       int64
-	Amount            float64
+	Amount            money.Amount
 	DailyInterestRate float64
-	RemainingAmount   float64
+	RemainingAmount   money.Amount
 	Reason            string
 }
 
+// Interest returns the simple interest owed on the amount at the daily rate, given in percent, over
+// the elapsed time. it is worked out exactly and rounded to the cent once, with banker's rounding, so
+// repeated partial payments don't drift
+func Interest(amount money.Amount, dailyInterestRate float64, elapsed time.Duration) money.Amount {
+	return InterestAt(amount, money.RatFromFloat(dailyInterestRate), elapsed)
+}
+
+// InterestAt is Interest at a daily rate that is already exact
+func InterestAt(
+	amount money.Amount, dailyInterestRate *big.Rat, elapsed time.Duration,
+) money.Amount {
+	days := big.NewRat(int64(elapsed), int64(24*time.Hour))
+	rate := new(big.Rat).Quo(dailyInterestRate, big.NewRat(100, 1))
+	return amount.Mul(new(big.Rat).Mul(days, rate), money.RoundHalfEven)
+}
+
 func ValidateLoan(v *validator.Validator, loan *Loan) {
 	v.CheckAddError(loan.Amount != 0, "amount", "must be given")
 	v.CheckAddError(loan.Amount > 0, "amount", "must be more than 0")
@@ -3,6 +3,7 @@ package loan
 import (
 	"testing"
 
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
@@ -10,7 +11,7 @@ func TestValidateLoan(t *testing.T) {
 	mockLoan := &Loan{
 		Action:            "took",
 		DailyInterestRate: 5,
-		Amount:            100,
+		Amount:            money.MustParse("100"),
 	}
 
 	tests := []struct {
@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"math"
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 )
 
@@ -15,14 +18,25 @@ type Repository struct {
 }
 
 func (r *Repository) Insert(loan *Loan) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		return InsertInTx(ctx, tx, loan)
+	})
+}
+
+// InsertInTx records the loan, or a payment on one, in the caller's transaction, so it can be saved
+// together with the money it moves
+func InsertInTx(ctx context.Context, tx *sql.Tx, loan *Loan) error {
 	query := `
 		INSERT INTO loans 
-			(user_id, amount, action, daily_interest_rate, remaining_amount, last_updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+			(user_id, account_id, currency, amount, action, daily_interest_rate, remaining_amount,
+			last_updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at
 	`
 	args := []any{
 		loan.UserID,
+		loan.AccountID,
+		loan.Currency,
 		loan.Amount,
 		loan.Action,
 		loan.DailyInterestRate,
@@ -30,10 +44,7 @@ func (r *Repository) Insert(loan *Loan) error {
 		loan.LastUpdatedAt,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	return r.DB.QueryRowContext(ctx, query, args...).Scan(
+	return tx.QueryRowContext(ctx, query, args...).Scan(
 		&loan.ID,
 		&loan.CreatedAt,
 	)
@@ -41,8 +52,8 @@ func (r *Repository) Insert(loan *Loan) error {
 
 func (r *Repository) GetByID(loanID, userID int64) (*Loan, error) {
 	query := `
-		SELECT id, created_at, user_id, amount, action, daily_interest_rate, remaining_amount, 
-			last_updated_at, version
+		SELECT id, created_at, user_id, account_id, currency, amount, action, daily_interest_rate,
+			remaining_amount, last_updated_at, version
 		FROM loans
 		WHERE id = $1 AND user_id = $2
 	`
@@ -55,6 +66,8 @@ func (r *Repository) GetByID(loanID, userID int64) (*Loan, error) {
 		&loan.ID,
 		&loan.CreatedAt,
 		&loan.UserID,
+		&loan.AccountID,
+		&loan.Currency,
 		&loan.Amount,
 		&loan.Action,
 		&loan.DailyInterestRate,
@@ -118,162 +131,215 @@ func (r *Repository) GetForUserByUserID(userID int64) ([]*Loan, error) {
 	return loans, nil
 }
 
-func (r *Repository) MakePaymentTx(loanID, userID int64, payment, totalOwed float64) (*Loan, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	// start transaction
-	tx, err := r.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// rollback if anything goes wrong
-	defer tx.Rollback()
-
-	// fetch loan with FOR UPDATE to lock the row
-	loan := &Loan{}
-	query := `
-		SELECT id, user_id, remaining_amount, daily_interest_rate, last_updated_at
-		FROM loans
-		WHERE id = $1 AND user_id = $2
-		FOR UPDATE 
-	`
+// MakePaymentTx makes a payment on the loan in one database transaction. the loan is locked first,
+// then prepare is given it as it is now, brings its RemainingAmount and LastUpdatedAt to what they
+// are after the payment, and returns the payment and the entry taking it from the account. the
+// loan is updated, and the payment recorded, posted and recorded as an event, together. prepare
+// can be called more than once if the transaction is retried
+func (r *Repository) MakePaymentTx(
+	loanID, userID int64, prepare func(loan *Loan) (*Loan, *ledger.Entry, error),
+) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		query := `
+			SELECT id, created_at, user_id, account_id, currency, amount, action,
+				daily_interest_rate, remaining_amount, last_updated_at, version
+			FROM loans
+			WHERE id = $1 AND user_id = $2
+			FOR UPDATE
+		`
+		loan := &Loan{}
+		err := tx.QueryRowContext(ctx, query, loanID, userID).Scan(
+			&loan.ID,
+			&loan.CreatedAt,
+			&loan.UserID,
+			&loan.AccountID,
+			&loan.Currency,
+			&loan.Amount,
+			&loan.Action,
+			&loan.DailyInterestRate,
+			&loan.RemainingAmount,
+			&loan.LastUpdatedAt,
+			&loan.Version,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return user.ErrNoRecord
+			default:
+				return err
+			}
+		}
 
-	err = tx.QueryRowContext(ctx, query, loanID, userID).Scan(
-		&loan.ID,
-		&loan.UserID,
-		&loan.RemainingAmount,
-		&loan.DailyInterestRate,
-		&loan.LastUpdatedAt,
-	)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, user.ErrNoRecord
-		default:
-			return nil, err
+		payment, entry, err := prepare(loan)
+		if err != nil {
+			return err
 		}
-	}
 
-	loan.RemainingAmount = math.Max(0, totalOwed-payment)
-	loan.LastUpdatedAt = time.Now().UTC()
+		// update the row in the database
+		updateQuery := `
+			UPDATE loans
+			SET remaining_amount = $1, last_updated_at = $2, version = version + 1
+			WHERE id = $3 AND user_id = $4
+		`
+		args := []any{
+			loan.RemainingAmount,
+			loan.LastUpdatedAt,
+			loan.ID,
+			loan.UserID,
+		}
+		_, err = tx.ExecContext(ctx, updateQuery, args...)
+		if err != nil {
+			return err
+		}
 
-	// update the row in the database
-	updateQuery := `
-		UPDATE loans
-		SET remaining_amount = $1, last_updated_at = $2, version = version + 1
-		WHERE id = $3 AND user_id = $4
-	`
-	args := []any{
-		loan.RemainingAmount,
-		loan.LastUpdatedAt,
-		loan.ID,
-		loan.UserID,
-	}
+		err = InsertInTx(ctx, tx, payment)
+		if err != nil {
+			return err
+		}
 
-	_, err = tx.ExecContext(ctx, updateQuery, args...)
-	if err != nil {
-		return nil, err
-	}
+		err = ledger.PostInTx(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
 
-	if err = tx.Commit(); err != nil {
-		return nil, err
-	}
+		e, err := event.New(loan.UserID, event.LoanPaid{
+			LoanID:          loan.ID,
+			Paid:            payment.Amount,
+			RemainingAmount: loan.RemainingAmount,
+			Currency:        loan.Currency,
+		})
+		if err != nil {
+			return err
+		}
 
-	return loan, nil
+		return event.RecordInTx(ctx, tx, e)
+	})
 }
 
-func (r *Repository) DeleteLoan(loanID, userID int64) error {
-	query := `
-		DELETE FROM loans
-		WHERE id = $1 AND user_id = $2
-	`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// DeleteTx records that the loan is deleted, deletes it and records the deletion as an event, all
+// in one database transaction. it is ErrNoRecord when the loan is already gone
+func (r *Repository) DeleteTx(loanDeletion *LoanDeletion) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		query := `
+			INSERT INTO deleted_loans 
+			(
+				loan_created_at, loan_last_updated_at, loan_id, debtor_id, deleted_by_id, amount, 
+				daily_interest_rate, remaining_amount, reason
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id, created_at
+		`
+		args := []any{
+			loanDeletion.LoanCreatedAt,
+			loanDeletion.LoanLastUpdatedAt,
+			loanDeletion.LoanID,
+			loanDeletion.DebtorID,
+			loanDeletion.DeletedByID,
+			loanDeletion.Amount,
+			loanDeletion.DailyInterestRate,
+			loanDeletion.RemainingAmount,
+			loanDeletion.Reason,
+		}
+		err := tx.QueryRowContext(ctx, query, args...).Scan(
+			&loanDeletion.ID,
+			&loanDeletion.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
 
-	res, err := r.DB.ExecContext(ctx, query, loanID, userID)
-	if err != nil {
-		return err
-	}
+		query = `
+			DELETE FROM loans
+			WHERE id = $1 AND user_id = $2
+		`
+		res, err := tx.ExecContext(ctx, query, loanDeletion.LoanID, loanDeletion.DebtorID)
+		if err != nil {
+			return err
+		}
+		rowsEffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsEffected == 0 {
+			return user.ErrNoRecord
+		}
 
-	rowsEffected, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
+		e, err := event.New(loanDeletion.DebtorID, event.LoanDeleted{
+			LoanID:          loanDeletion.LoanID,
+			DeletedByID:     loanDeletion.DeletedByID,
+			RemainingAmount: loanDeletion.RemainingAmount,
+			Reason:          loanDeletion.Reason,
+		})
+		if err != nil {
+			return err
+		}
 
-	if rowsEffected == 0 {
-		return user.ErrNoRecord
-	}
+		return event.RecordInTx(ctx, tx, e)
+	})
+}
 
-	return nil
+// loanHistory is how the history of a user's loans is queried, the action being whether the loan
+// was taken or paid
+var loanHistory = history.Table{
+	ID:        "id",
+	CreatedAt: "created_at",
+	Amount:    "amount",
+	Status:    "action",
+	Statuses:  []string{"took", "paid"},
 }
 
-func (r *Repository) InsertDeletion(loanDeletion *LoanDeletion) error {
-	query := `
-		INSERT INTO deleted_loans 
-		(
-			loan_created_at, loan_last_updated_at, loan_id, debtor_id, deleted_by_id, amount, 
-			daily_interest_rate, remaining_amount, reason
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id, created_at
-	`
-	args := []any{
-		loanDeletion.LoanCreatedAt,
-		loanDeletion.LoanLastUpdatedAt,
-		loanDeletion.LoanID,
-		loanDeletion.DebtorID,
-		loanDeletion.DeletedByID,
-		loanDeletion.Amount,
-		loanDeletion.DailyInterestRate,
-		loanDeletion.RemainingAmount,
-		loanDeletion.Reason,
-	}
+// GetAllUserLoans gets the page of the user's loans that the query asks for
+func (r *Repository) GetAllUserLoans(
+	userID int64, q *history.Query,
+) ([]*Loan, *history.Page, error) {
+	filter, args := loanHistory.Filter(q, []any{userID})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return r.DB.QueryRowContext(ctx, query, args...).Scan(
-		&loanDeletion.ID,
-		&loanDeletion.CreatedAt,
-	)
-}
+	page := &history.Page{}
+	query := "SELECT COUNT(*) FROM loans WHERE user_id = $1" + filter
+	if err := r.DB.QueryRowContext(ctx, query, args...).Scan(&page.Total); err != nil {
+		return nil, nil, err
+	}
 
-func (r *Repository) GetAllUserLoans(userID int64) ([]*Loan, error) {
-	query := `
-		SELECT id, created_at, user_id, amount, daily_interest_rate
+	seek, orderBy, args := loanHistory.Seek(q, args)
+	query = `
+		SELECT id, created_at, user_id, account_id, currency, amount, action, daily_interest_rate
 		FROM loans
 		WHERE user_id = $1
-	`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	rows, err := r.DB.QueryContext(ctx, query, userID)
+	` + filter + seek + orderBy
+	rows, err := r.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
-	var transfers []*Loan
+	var loans []*Loan
 	for rows.Next() {
-		transfer := &Loan{}
+		loan := &Loan{}
 		err = rows.Scan(
-			&transfer.ID,
-			&transfer.CreatedAt,
-			&transfer.UserID,
-			&transfer.Amount,
-			&transfer.DailyInterestRate,
+			&loan.ID,
+			&loan.CreatedAt,
+			&loan.UserID,
+			&loan.AccountID,
+			&loan.Currency,
+			&loan.Amount,
+			&loan.Action,
+			&loan.DailyInterestRate,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		transfers = append(transfers, transfer)
+		loans = append(loans, loan)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if q.More(len(loans)) {
+		loans = loans[:q.PageSize]
+		last := loans[len(loans)-1]
+		page.NextCursor = q.NextCursor(last.CreatedAt, last.Amount, last.ID)
 	}
 
-	return transfers, nil
+	return loans, page, nil
 }
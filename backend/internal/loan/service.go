@@ -1,9 +1,13 @@
 package loan
 
 import (
-	"math"
+	"errors"
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
@@ -11,29 +15,30 @@ import (
 type Repo interface {
 	Insert(*Loan) error
 	GetByID(loanID, userID int64) (*Loan, error)
-	InsertDeletion(loan *LoanDeletion) error
-	MakePaymentTx(loanID, userID int64, payment, totalOwed float64) (*Loan, error)
-	DeleteLoan(loanID, debtorID int64) error
-	GetAllUserLoans(userID int64) ([]*Loan, error)
+	MakePaymentTx(
+		loanID, userID int64, prepare func(loan *Loan) (*Loan, *ledger.Entry, error),
+	) error
+	DeleteTx(loanDeletion *LoanDeletion) error
+	GetAllUserLoans(userID int64, q *history.Query) ([]*Loan, *history.Page, error)
 }
 
-type UserService interface {
-	GetUser(userID int64) (*user.User, error)
-	UpdateUser(
-		userID int64, userName, userEmail string, userPasswordHash []byte,
-		userAccountBalance float64, userActivated bool) (*user.User, error)
+type AccountService interface {
+	GetAccount(accountID int64) (*account.Account, error)
 }
 
 type Service struct {
-	Repo        Repo
-	UserService UserService
+	Repo           Repo
+	AccountService AccountService
 }
 
+// GetLoan records a loan the user took, paid into the account in the account's currency
 func (s *Service) GetLoan(
-	u *user.User, amount, dailyInterestRate float64,
+	u *user.User, accountID int64, currency string, amount money.Amount, dailyInterestRate float64,
 ) error {
 	loan := Loan{
 		UserID:            u.ID,
+		AccountID:         accountID,
+		Currency:          currency,
 		Amount:            amount,
 		Action:            "took",
 		DailyInterestRate: dailyInterestRate,
@@ -50,7 +55,7 @@ func (s *Service) GetLoan(
 }
 
 func (s *Service) MakePayment(
-	v *validator.Validator, loanID, userID int64, payment float64,
+	v *validator.Validator, loanID, userID int64, payment money.Amount,
 ) (*Loan, error) {
 	if payment <= 0 {
 		v.AddError("amount", "must be more than 0")
@@ -66,53 +71,72 @@ func (s *Service) MakePayment(
 		return nil, validator.ErrFailedValidation
 	}
 
-	// get the user
-	u, err := s.UserService.GetUser(userID)
+	// the loan is paid from the account it was paid into
+	a, err := s.AccountService.GetAccount(loan.AccountID)
 	if err != nil {
 		return nil, err
 	}
 
-	// check if he has enough funds
-	if u.AccountBalance < payment {
-		v.AddError("account_balance", "insufficient funds")
+	v.CheckAddError(a.IsActive(), "account", "is not active")
+	if !v.IsValid() {
 		return nil, validator.ErrFailedValidation
 	}
 
-	// get the time since last payment was made, we use LastUpdatedAt instead of created_at to
-	// avoid over-charging in partial payments.
-	elapsedTimeDays := time.Since(loan.LastUpdatedAt).Hours() / 24
-	interest := elapsedTimeDays * (loan.RemainingAmount * (loan.DailyInterestRate / 100))
-	totalOwed := loan.RemainingAmount + interest
-
-	loan, err = s.Repo.MakePaymentTx(loan.ID, userID, payment, totalOwed)
-	if err != nil {
-		return nil, err
-	}
-
-	loanPayment := Loan{
-		UserID:            loan.UserID,
-		Amount:            math.Min(payment, totalOwed),
-		Action:            "paid",
-		RemainingAmount:   loan.RemainingAmount,
-		DailyInterestRate: loan.DailyInterestRate,
-		LastUpdatedAt:     loan.LastUpdatedAt,
-	}
-
-	err = s.Repo.Insert(&loanPayment)
-	if err != nil {
-		return nil, err
-	}
-
-	// deduct the payment from the users account
-	u.AccountBalance -= loanPayment.Amount
-	_, err = s.UserService.UpdateUser(
-		u.ID, u.Name, u.Email, u.Password.Hash, u.AccountBalance, u.Activated,
+	// what is owed is worked out from the loan as it is once it is locked, so payments made at the
+	// same time each count the other
+	var loanPayment *Loan
+	err = s.Repo.MakePaymentTx(loan.ID, userID,
+		func(loan *Loan) (*Loan, *ledger.Entry, error) {
+			if loan.RemainingAmount == 0 {
+				v.AddError("loan", "is already paid off")
+				return nil, nil, validator.ErrFailedValidation
+			}
+
+			// get the time since last payment was made, we use LastUpdatedAt instead of created_at
+			// to avoid over-charging in partial payments.
+			interest := Interest(
+				loan.RemainingAmount, loan.DailyInterestRate, time.Since(loan.LastUpdatedAt),
+			)
+			totalOwed := loan.RemainingAmount + interest
+
+			// only what is owed is taken, it has to fit in what the account can spend
+			paid := money.Min(payment, totalOwed)
+			if paid > a.Spendable() {
+				v.AddError("account_balance", "insufficient funds")
+				return nil, nil, validator.ErrFailedValidation
+			}
+
+			loan.RemainingAmount = totalOwed - paid
+			loan.LastUpdatedAt = time.Now().UTC()
+			loanPayment = &Loan{
+				UserID:            loan.UserID,
+				AccountID:         loan.AccountID,
+				Currency:          loan.Currency,
+				Amount:            paid,
+				Action:            "paid",
+				DailyInterestRate: loan.DailyInterestRate,
+				RemainingAmount:   loan.RemainingAmount,
+				LastUpdatedAt:     loan.LastUpdatedAt,
+			}
+
+			// deduct the payment from the users account
+			entry := ledger.NewEntry(
+				"loan payment",
+				ledger.AccountPosting(a.ID, loanPayment.Currency, loanPayment.Amount.Neg()),
+				ledger.SystemPosting(ledger.AccountLoans, loanPayment.Currency, loanPayment.Amount),
+			)
+			return loanPayment, entry, nil
+		},
 	)
 	if err != nil {
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			v.AddError("account_balance", "insufficient funds")
+			return nil, validator.ErrFailedValidation
+		}
 		return nil, err
 	}
 
-	return &loanPayment, nil
+	return loanPayment, nil
 }
 
 func (s *Service) DeleteLoan(
@@ -143,31 +167,9 @@ func (s *Service) DeleteLoan(
 	loanDeletion.DailyInterestRate = loan.DailyInterestRate
 	loanDeletion.Reason = reason
 
-	// first record the deletion before deleting the loan because we could get an error before
-	// recording it after removing the loan from the system. if we get an error removing the loan
-	// but the deletion is recorded we could handle it as the loan with the id exists and deal with
-	// it. we retry 5 times to record the entry
-	err = nil // clean the err var before
-	for range 5 {
-		err = s.Repo.InsertDeletion(loanDeletion)
-		if err == nil {
-			break
-		}
-		time.Sleep(100 * time.Millisecond) // wait for 100ms before retrying.
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	// delete the actual loan. we retry 5 times
-	err = nil
-	for range 5 {
-		err = s.Repo.DeleteLoan(loanID, debtorID)
-		if err == nil {
-			break
-		}
-		time.Sleep(100 * time.Millisecond)
-	}
+	// the deletion is recorded in the same database transaction the loan is deleted in, so there is
+	// never one without the other
+	err = s.Repo.DeleteTx(loanDeletion)
 	if err != nil {
 		return nil, err
 	}
@@ -175,6 +177,13 @@ func (s *Service) DeleteLoan(
 	return loanDeletion, nil
 }
 
-func (s *Service) GetAllUserLoans(userID int64) ([]*Loan, error) {
-	return s.Repo.GetAllUserLoans(userID)
+// GetAllUserLoans gets a page of the user's loans, the ones the query asks for
+func (s *Service) GetAllUserLoans(
+	v *validator.Validator, userID int64, q *history.Query,
+) ([]*Loan, *history.Page, error) {
+	if history.ValidateQuery(v, q, loanHistory); !v.IsValid() {
+		return nil, nil, validator.ErrFailedValidation
+	}
+
+	return s.Repo.GetAllUserLoans(userID, q)
 }
@@ -4,6 +4,10 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
@@ -11,25 +15,19 @@ import (
 type mockRepo struct {
 	InsertErr error
 
-	InsertDeletionErr error
-
 	GetByIDResult *Loan
 	GetByIDErr    error
 
-	DeleteLoanErr error
+	DeleteTxErr error
 
-	MakePaymentTxResult *Loan
-	MakePaymentTxErr    error
+	MakePaymentTxErr error
+	Posted           []*ledger.Entry
 }
 
 func (m *mockRepo) Insert(loan *Loan) error {
 	return m.InsertErr
 }
 
-func (m *mockRepo) InsertDeletion(loan *LoanDeletion) error {
-	return m.InsertDeletionErr
-}
-
 func (m *mockRepo) GetByID(loanID, userID int64) (*Loan, error) {
 	if m.GetByIDErr != nil {
 		return nil, m.GetByIDErr
@@ -37,141 +35,179 @@ func (m *mockRepo) GetByID(loanID, userID int64) (*Loan, error) {
 	return m.GetByIDResult, nil
 }
 
-func (m *mockRepo) DeleteLoan(loanID, debtorID int64) error {
-	return m.DeleteLoanErr
+func (m *mockRepo) DeleteTx(loanDeletion *LoanDeletion) error {
+	return m.DeleteTxErr
 }
 
-func (m *mockRepo) MakePaymentTx(loanID, userID int64, payment, totalOwed float64) (*Loan, error) {
+func (m *mockRepo) GetAllUserLoans(
+	userID int64, q *history.Query,
+) ([]*Loan, *history.Page, error) {
+	return nil, nil, nil
+}
+
+// MakePaymentTx runs prepare on a copy of the loan GetByID finds, like the real one does on the
+// locked loan
+func (m *mockRepo) MakePaymentTx(
+	loanID, userID int64, prepare func(loan *Loan) (*Loan, *ledger.Entry, error),
+) error {
+	if m.GetByIDErr != nil {
+		return m.GetByIDErr
+	}
+	loan := *m.GetByIDResult
+	_, entry, err := prepare(&loan)
+	if err != nil {
+		return err
+	}
 	if m.MakePaymentTxErr != nil {
-		return nil, m.MakePaymentTxErr
+		return m.MakePaymentTxErr
 	}
-
-	return m.MakePaymentTxResult, nil
+	m.Posted = append(m.Posted, entry)
+	return nil
 }
 
-type mockUserService struct {
-	GetUserResult *user.User
-	GetUserErr    error
-
-	UpdateUserErr error
+type mockAccountService struct {
+	GetAccountResult *account.Account
+	GetAccountErr    error
 }
 
-func (us *mockUserService) GetUser(userID int64) (*user.User, error) {
-	if us.GetUserErr != nil {
-		return nil, us.GetUserErr
+func (as *mockAccountService) GetAccount(accountID int64) (*account.Account, error) {
+	if as.GetAccountErr != nil {
+		return nil, as.GetAccountErr
 	}
 
-	return us.GetUserResult, nil
-}
-
-func (us *mockUserService) UpdateUser(
-	userID int64, userName, userEmail string, userPasswordHash []byte,
-	userAccountBalance float64, userActivated bool,
-) (*user.User, error) {
-	if us.UpdateUserErr != nil {
-		return nil, us.UpdateUserErr
-	}
-	u, err := us.GetUser(userID)
-	if err != nil {
-		return nil, err
-	}
-	u.AccountBalance = userAccountBalance
-	u.Name = userName
-	u.Email = userEmail
-	u.Password.Hash = userPasswordHash
-	u.Activated = userActivated
-	return u, nil
+	return as.GetAccountResult, nil
 }
 
 func TestMakepayment(t *testing.T) {
 	mockLoan := &Loan{
 		ID:              1,
 		UserID:          1,
-		Amount:          200,
+		AccountID:       1,
+		Amount:          money.MustParse("200"),
 		Action:          "took",
-		RemainingAmount: 200,
+		RemainingAmount: money.MustParse("200"),
 	}
-	mockUser := &user.User{
-		ID:             1,
-		Name:           "yusuf",
-		Email:          "ym@gmail.com",
-		AccountBalance: 100,
+	mockAccount := &account.Account{
+		ID:               1,
+		UserID:           1,
+		Number:           "1000000000",
+		Type:             account.TypeChecking,
+		Status:           account.StatusActive,
+		Balance:          money.MustParse("100"),
+		AvailableBalance: money.MustParse("100"),
 	}
 
 	tests := []struct {
-		name         string
-		setupRepo    func(*mockRepo)
-		setupUserSvc func(*mockUserService)
-		input        struct {
+		name            string
+		setupRepo       func(*mockRepo)
+		setupAccountSvc func(*mockAccountService)
+		input           struct {
 			v              *validator.Validator
 			loanID, userID int64
-			payment        float64
+			payment        money.Amount
 		}
-		finalLoanRemainingAmount float64
+		finalLoanRemainingAmount money.Amount
 		expectedErr              error
 	}{
 		{
 			name: "vaild input",
 			setupRepo: func(r *mockRepo) {
 				r.GetByIDResult = mockLoan
-				r.MakePaymentTxResult = &Loan{RemainingAmount: 150}
 			},
-			setupUserSvc: func(us *mockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountSvc: func(as *mockAccountService) {
+				as.GetAccountResult = mockAccount
+			},
+			input: struct {
+				v       *validator.Validator
+				loanID  int64
+				userID  int64
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("50")},
+			finalLoanRemainingAmount: money.MustParse("150"),
+		},
+		{
+			name: "paying more than is owed",
+			setupRepo: func(r *mockRepo) {
+				r.GetByIDResult = &Loan{
+					ID: 1, UserID: 1, AccountID: 1, Amount: money.MustParse("200"),
+					Action: "took", RemainingAmount: money.MustParse("80"),
+				}
+			},
+			setupAccountSvc: func(as *mockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v       *validator.Validator
 				loanID  int64
 				userID  int64
-				payment float64
-			}{v: validator.New(), loanID: 1, userID: 1, payment: 50},
-			finalLoanRemainingAmount: 150,
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("150")},
+			finalLoanRemainingAmount: 0,
+		},
+		{
+			name: "paid into the overdraft",
+			setupRepo: func(r *mockRepo) {
+				r.GetByIDResult = mockLoan
+			},
+			setupAccountSvc: func(as *mockAccountService) {
+				as.GetAccountResult = &account.Account{
+					ID: 1, UserID: 1, Status: account.StatusActive, Balance: money.MustParse("20"),
+					AvailableBalance: money.MustParse("20"), OverdraftLimit: money.MustParse("100"),
+				}
+			},
+			input: struct {
+				v       *validator.Validator
+				loanID  int64
+				userID  int64
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("50")},
+			finalLoanRemainingAmount: money.MustParse("150"),
 		},
 		{
 			name: "insufficient funds",
 			setupRepo: func(r *mockRepo) {
 				r.GetByIDResult = mockLoan
 			},
-			setupUserSvc: func(us *mockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountSvc: func(as *mockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v       *validator.Validator
 				loanID  int64
 				userID  int64
-				payment float64
-			}{v: validator.New(), loanID: 1, userID: 1, payment: 200},
-			finalLoanRemainingAmount: 200,
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("200")},
+			finalLoanRemainingAmount: money.MustParse("200"),
 			expectedErr:              validator.ErrFailedValidation,
 		},
 		{
 			name: "loan already paid off",
 			setupRepo: func(r *mockRepo) {
-				r.GetByIDResult = &Loan{RemainingAmount: 0}
+				r.GetByIDResult = &Loan{RemainingAmount: money.MustParse("0")}
 			},
-			setupUserSvc: func(us *mockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountSvc: func(as *mockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v       *validator.Validator
 				loanID  int64
 				userID  int64
-				payment float64
-			}{v: validator.New(), loanID: 1, userID: 1, payment: 200},
-			finalLoanRemainingAmount: 200,
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("200")},
+			finalLoanRemainingAmount: money.MustParse("200"),
 			expectedErr:              validator.ErrFailedValidation,
 		},
 		{
-			name:         "negative amount",
-			setupRepo:    func(r *mockRepo) {},
-			setupUserSvc: func(us *mockUserService) {},
+			name:            "negative amount",
+			setupRepo:       func(r *mockRepo) {},
+			setupAccountSvc: func(as *mockAccountService) {},
 			input: struct {
 				v       *validator.Validator
 				loanID  int64
 				userID  int64
-				payment float64
-			}{v: validator.New(), loanID: 1, userID: 1, payment: -100},
-			finalLoanRemainingAmount: 200,
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("-100")},
+			finalLoanRemainingAmount: money.MustParse("200"),
 			expectedErr:              validator.ErrFailedValidation,
 		},
 		{
@@ -179,31 +215,31 @@ func TestMakepayment(t *testing.T) {
 			setupRepo: func(r *mockRepo) {
 				r.GetByIDErr = user.ErrNoRecord
 			},
-			setupUserSvc: func(us *mockUserService) {},
+			setupAccountSvc: func(as *mockAccountService) {},
 			input: struct {
 				v       *validator.Validator
 				loanID  int64
 				userID  int64
-				payment float64
-			}{v: validator.New(), loanID: 1, userID: 1, payment: 100},
-			finalLoanRemainingAmount: 200,
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("100")},
+			finalLoanRemainingAmount: money.MustParse("200"),
 			expectedErr:              user.ErrNoRecord,
 		},
 		{
-			name: "GetUser failure",
+			name: "GetAccount failure",
 			setupRepo: func(r *mockRepo) {
 				r.GetByIDResult = mockLoan
 			},
-			setupUserSvc: func(us *mockUserService) {
-				us.GetUserErr = user.ErrNoRecord
+			setupAccountSvc: func(as *mockAccountService) {
+				as.GetAccountErr = user.ErrNoRecord
 			},
 			input: struct {
 				v       *validator.Validator
 				loanID  int64
 				userID  int64
-				payment float64
-			}{v: validator.New(), loanID: 1, userID: 1, payment: 100},
-			finalLoanRemainingAmount: 200,
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("100")},
+			finalLoanRemainingAmount: money.MustParse("200"),
 			expectedErr:              user.ErrNoRecord,
 		},
 		{
@@ -212,70 +248,70 @@ func TestMakepayment(t *testing.T) {
 				r.GetByIDResult = mockLoan
 				r.MakePaymentTxErr = errors.New("db MakePaymentTx error")
 			},
-			setupUserSvc: func(us *mockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountSvc: func(as *mockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v       *validator.Validator
 				loanID  int64
 				userID  int64
-				payment float64
-			}{v: validator.New(), loanID: 1, userID: 1, payment: 100},
-			finalLoanRemainingAmount: 200,
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("100")},
+			finalLoanRemainingAmount: money.MustParse("200"),
 			expectedErr:              errors.New("db MakePaymentTx error"),
 		},
 		{
-			name: "Insert failure",
+			name: "account frozen",
 			setupRepo: func(r *mockRepo) {
 				r.GetByIDResult = mockLoan
-				r.MakePaymentTxResult = mockLoan
-				r.InsertErr = errors.New("db Insert error")
 			},
-			setupUserSvc: func(us *mockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountSvc: func(as *mockAccountService) {
+				as.GetAccountResult = &account.Account{
+					ID: 1, UserID: 1, Status: account.StatusFrozen, Balance: money.MustParse("100"),
+					AvailableBalance: money.MustParse("100"),
+				}
 			},
 			input: struct {
 				v       *validator.Validator
 				loanID  int64
 				userID  int64
-				payment float64
-			}{v: validator.New(), loanID: 1, userID: 1, payment: 100},
-			finalLoanRemainingAmount: 200,
-			expectedErr:              errors.New("db Insert error"),
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("50")},
+			finalLoanRemainingAmount: money.MustParse("200"),
+			expectedErr:              validator.ErrFailedValidation,
 		},
 		{
-			name: "UpdateUser failure",
+			name: "balance changed before the posting",
 			setupRepo: func(r *mockRepo) {
 				r.GetByIDResult = mockLoan
-				r.MakePaymentTxResult = mockLoan
+				r.MakePaymentTxErr = ledger.ErrInsufficientFunds
 			},
-			setupUserSvc: func(us *mockUserService) {
-				us.GetUserResult = mockUser
-				us.UpdateUserErr = errors.New("db UpdateUser error")
+			setupAccountSvc: func(as *mockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v       *validator.Validator
 				loanID  int64
 				userID  int64
-				payment float64
-			}{v: validator.New(), loanID: 1, userID: 1, payment: 100},
-			finalLoanRemainingAmount: 200,
-			expectedErr:              errors.New("db UpdateUser error"),
+				payment money.Amount
+			}{v: validator.New(), loanID: 1, userID: 1, payment: money.MustParse("100")},
+			finalLoanRemainingAmount: money.MustParse("200"),
+			expectedErr:              validator.ErrFailedValidation,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			// reset the user AccountBalance to avoid confusion and unexpected behaviour
-			mockUser.AccountBalance = 100
+			// reset the account balance to avoid confusion and unexpected behaviour
+			mockAccount.Balance = money.MustParse("100")
 			repo := &mockRepo{}
-			userSvc := &mockUserService{}
+			accountSvc := &mockAccountService{}
 			tc.setupRepo(repo)
-			tc.setupUserSvc(userSvc)
+			tc.setupAccountSvc(accountSvc)
 
 			svc := Service{
-				Repo:        repo,
-				UserService: userSvc,
+				Repo:           repo,
+				AccountService: accountSvc,
 			}
 
 			gotLoan, gotErr := svc.MakePayment(
@@ -291,10 +327,17 @@ func TestMakepayment(t *testing.T) {
 			}
 			if gotLoan.RemainingAmount != tc.finalLoanRemainingAmount {
 				t.Fatalf(
-					"expected remaining amount %f, got %f", tc.finalLoanRemainingAmount,
+					"expected remaining amount %v, got %v", tc.finalLoanRemainingAmount,
 					gotLoan.RemainingAmount,
 				)
 			}
+
+			if len(repo.Posted) != 1 {
+				t.Fatalf("expected the payment to be posted to the ledger once")
+			}
+			if posting := repo.Posted[0].Postings[0]; posting.Amount != -gotLoan.Amount {
+				t.Errorf("expected account to be debited %v, got %v", gotLoan.Amount, posting.Amount)
+			}
 		})
 	}
 }
@@ -303,9 +346,9 @@ func TestDeleteLoan(t *testing.T) {
 	mockLoan := &Loan{
 		ID:              1,
 		UserID:          1,
-		Amount:          200,
+		Amount:          money.MustParse("200"),
 		Action:          "took",
-		RemainingAmount: 200,
+		RemainingAmount: money.MustParse("200"),
 	}
 
 	tests := []struct {
@@ -358,25 +401,10 @@ func TestDeleteLoan(t *testing.T) {
 			expectedErr: validator.ErrFailedValidation,
 		},
 		{
-			name: "InsertDeletion failure",
-			setupRepo: func(r *mockRepo) {
-				r.GetByIDResult = mockLoan
-				r.InsertDeletionErr = errors.New("db InsertDeletion error")
-			},
-			input: struct {
-				v           *validator.Validator
-				loanID      int64
-				debtorID    int64
-				deletedByID int64
-				reason      string
-			}{v: validator.New(), loanID: 1, debtorID: 1, deletedByID: 1, reason: "some reason"},
-			expectedErr: errors.New("db InsertDeletion error"),
-		},
-		{
-			name: "DeleteLoan failure",
+			name: "DeleteTx failure",
 			setupRepo: func(r *mockRepo) {
 				r.GetByIDResult = mockLoan
-				r.DeleteLoanErr = errors.New("db DeleteLoan error")
+				r.DeleteTxErr = errors.New("db DeleteTx error")
 			},
 			input: struct {
 				v           *validator.Validator
@@ -385,7 +413,7 @@ func TestDeleteLoan(t *testing.T) {
 				deletedByID int64
 				reason      string
 			}{v: validator.New(), loanID: 1, debtorID: 1, deletedByID: 1, reason: "some reason"},
-			expectedErr: errors.New("db DeleteLoan error"),
+			expectedErr: errors.New("db DeleteTx error"),
 		},
 	}
 
@@ -410,7 +438,7 @@ func TestDeleteLoan(t *testing.T) {
 			}
 
 			if gotLoan.Amount != mockLoan.Amount {
-				t.Errorf("expected amount %f, got %f", mockLoan.Amount, gotLoan.Amount)
+				t.Errorf("expected amount %v, got %v", mockLoan.Amount, gotLoan.Amount)
 			}
 
 			if gotLoan.LoanID != mockLoan.ID {
@@ -3,6 +3,7 @@ package loanrequests
 import (
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
@@ -10,7 +11,9 @@ type LoanRequest struct {
 	ID                int64
 	CreatedAt         time.Time
 	UserID            int64
-	Amount            float64
+	AccountID         int64
+	Currency          string
+	Amount            money.Amount
 	DailyInterestRate float64
 	Status            string
 }
@@ -6,6 +6,11 @@ import (
 	"errors"
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/loan"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 )
 
@@ -13,15 +18,18 @@ type Repository struct {
 	DB *sql.DB
 }
 
+// Insert records the loan request, and that it was made as an event with it
 func (r *Repository) Insert(loanRequest *LoanRequest) error {
 	query := `
 		INSERT INTO loan_requests
-			(user_id, amount, daily_interest_rate, status)
-		VALUES ($1, $2, $3, $4)
+			(user_id, account_id, currency, amount, daily_interest_rate, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at
 	`
 	args := []any{
 		loanRequest.UserID,
+		loanRequest.AccountID,
+		loanRequest.Currency,
 		loanRequest.Amount,
 		loanRequest.DailyInterestRate,
 		loanRequest.Status,
@@ -30,7 +38,13 @@ func (r *Repository) Insert(loanRequest *LoanRequest) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := r.DB.QueryRowContext(ctx, query, args...).Scan(
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&loanRequest.ID,
 		&loanRequest.CreatedAt,
 	)
@@ -38,12 +52,22 @@ func (r *Repository) Insert(loanRequest *LoanRequest) error {
 		return err
 	}
 
-	return nil
+	e, err := event.New(
+		loanRequest.UserID, event.LoanRequested{LoanRequest: eventLoanRequest(loanRequest)},
+	)
+	if err != nil {
+		return err
+	}
+	if err = event.RecordInTx(ctx, tx, e); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *Repository) Get(loanRequestID, userID int64) (*LoanRequest, error) {
 	query := `
-		SELECT id, created_at, user_id, amount, daily_interest_rate, status
+		SELECT id, created_at, user_id, account_id, currency, amount, daily_interest_rate, status
 		FROM loan_requests
 		WHERE id = $1
 		AND user_id = $2
@@ -57,6 +81,8 @@ func (r *Repository) Get(loanRequestID, userID int64) (*LoanRequest, error) {
 		&loanRequest.ID,
 		&loanRequest.CreatedAt,
 		&loanRequest.UserID,
+		&loanRequest.AccountID,
+		&loanRequest.Currency,
 		&loanRequest.Amount,
 		&loanRequest.DailyInterestRate,
 		&loanRequest.Status,
@@ -73,31 +99,74 @@ func (r *Repository) Get(loanRequestID, userID int64) (*LoanRequest, error) {
 	return loanRequest, nil
 }
 
+// UpdateTx declines or accepts the loan request if it is still pending, and records that as an
+// event. it is ErrNoRecord once the request has been responded to
 func (r *Repository) UpdateTx(loanRequestID, userID int64, newStatus string) (*LoanRequest, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	var loanRequest *LoanRequest
+	err := dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		loanRequest, err = updateTx(ctx, tx, loanRequestID, userID, newStatus)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	tx, err := r.DB.BeginTx(ctx, nil)
+	return loanRequest, nil
+}
+
+// AcceptTx accepts the loan request if it is still pending. the request is locked first, then
+// prepare is given it and returns the entry paying the loan out and the loan to record, and the
+// acceptance, the entry and the loan are saved together. prepare can be called more than once if
+// the transaction is retried
+func (r *Repository) AcceptTx(
+	loanRequestID, userID int64,
+	prepare func(loanRequest *LoanRequest) (*ledger.Entry, *loan.Loan),
+) (*LoanRequest, error) {
+	var loanRequest *LoanRequest
+	err := dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		loanRequest, err = updateTx(ctx, tx, loanRequestID, userID, "ACCEPTED")
+		if err != nil {
+			return err
+		}
+
+		entry, l := prepare(loanRequest)
+		err = ledger.PostInTx(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
+
+		return loan.InsertInTx(ctx, tx, l)
+	})
 	if err != nil {
 		return nil, err
 	}
-	// in case of any issues
-	defer tx.Rollback()
 
-	// fetch loanRequest request, use FOR UPDATE to lock the row from others trying to update at the same
-	// time
+	return loanRequest, nil
+}
+
+// updateTx moves the pending loan request to the new status in the transaction and records the
+// event for it
+func updateTx(
+	ctx context.Context, tx *sql.Tx, loanRequestID, userID int64, newStatus string,
+) (*LoanRequest, error) {
+	// fetch loanRequest request, use FOR UPDATE to lock the row from others trying to update at
+	// the same time
 	query := `
-		SELECT id, created_at, user_id, amount, daily_interest_rate, status 
+		SELECT id, created_at, user_id, account_id, currency, amount, daily_interest_rate, status 
 		FROM  loan_requests
 		WHERE id = $1 
 		AND user_id = $2
 		FOR UPDATE
 	`
 	loanRequest := &LoanRequest{}
-	err = tx.QueryRowContext(ctx, query, loanRequestID, userID).Scan(
+	err := tx.QueryRowContext(ctx, query, loanRequestID, userID).Scan(
 		&loanRequest.ID,
 		&loanRequest.CreatedAt,
 		&loanRequest.UserID,
+		&loanRequest.AccountID,
+		&loanRequest.Currency,
 		&loanRequest.Amount,
 		&loanRequest.DailyInterestRate,
 		&loanRequest.Status,
@@ -111,41 +180,91 @@ func (r *Repository) UpdateTx(loanRequestID, userID int64, newStatus string) (*L
 		}
 	}
 
+	// a request that was already accepted or declined can't be responded to again
 	updateQuery := `
 		UPDATE loan_requests
 		SET status = $1
 		WHERE id = $2
 		AND user_id = $3
+		AND status = 'PENDING'
 		RETURNING status
 	`
-
 	err = tx.QueryRowContext(ctx, updateQuery, newStatus, loanRequest.ID, loanRequest.UserID).Scan(
 		&loanRequest.Status,
 	)
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
 	}
 
-	if err = tx.Commit(); err != nil {
-		return nil, err
+	// accepting or declining the request is recorded as an event
+	var payload event.Payload
+	switch loanRequest.Status {
+	case "ACCEPTED":
+		payload = event.LoanAccepted{LoanRequest: eventLoanRequest(loanRequest)}
+	case "DECLINED":
+		payload = event.LoanDeclined{LoanRequest: eventLoanRequest(loanRequest)}
+	}
+	if payload != nil {
+		e, err := event.New(loanRequest.UserID, payload)
+		if err != nil {
+			return nil, err
+		}
+		if err = event.RecordInTx(ctx, tx, e); err != nil {
+			return nil, err
+		}
 	}
 
 	return loanRequest, nil
 }
 
-func (r *Repository) GetAllUserLoanRequests(userID int64) ([]*LoanRequest, error) {
-	query := `
-		SELECT id, created_at, amount, daily_interest_rate, status
-		FROM loan_requests
-		WHERE user_id = $1
-	`
+func eventLoanRequest(loanRequest *LoanRequest) event.LoanRequest {
+	return event.LoanRequest{
+		LoanRequestID:     loanRequest.ID,
+		AccountID:         loanRequest.AccountID,
+		Amount:            loanRequest.Amount,
+		Currency:          loanRequest.Currency,
+		DailyInterestRate: loanRequest.DailyInterestRate,
+	}
+}
+
+// loanRequestHistory is how the history of a user's loan requests is queried
+var loanRequestHistory = history.Table{
+	ID:        "id",
+	CreatedAt: "created_at",
+	Amount:    "amount",
+	Status:    "status",
+	Statuses:  []string{"PENDING", "ACCEPTED", "DECLINED"},
+}
+
+// GetAllUserLoanRequests gets the page of the user's loan requests that the query asks for
+func (r *Repository) GetAllUserLoanRequests(
+	userID int64, q *history.Query,
+) ([]*LoanRequest, *history.Page, error) {
+	filter, args := loanRequestHistory.Filter(q, []any{userID})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := r.DB.QueryContext(ctx, query, userID)
+	page := &history.Page{}
+	query := "SELECT COUNT(*) FROM loan_requests WHERE user_id = $1" + filter
+	if err := r.DB.QueryRowContext(ctx, query, args...).Scan(&page.Total); err != nil {
+		return nil, nil, err
+	}
+
+	seek, orderBy, args := loanRequestHistory.Seek(q, args)
+	query = `
+		SELECT id, created_at, account_id, currency, amount, daily_interest_rate, status
+		FROM loan_requests
+		WHERE user_id = $1
+	` + filter + seek + orderBy
+	rows, err := r.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 	var loanRequests []*LoanRequest
@@ -154,18 +273,25 @@ func (r *Repository) GetAllUserLoanRequests(userID int64) ([]*LoanRequest, error
 		err = rows.Scan(
 			&loanRequest.ID,
 			&loanRequest.CreatedAt,
+			&loanRequest.AccountID,
+			&loanRequest.Currency,
 			&loanRequest.Amount,
 			&loanRequest.DailyInterestRate,
 			&loanRequest.Status,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		loanRequests = append(loanRequests, loanRequest)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if q.More(len(loanRequests)) {
+		loanRequests = loanRequests[:q.PageSize]
+		last := loanRequests[len(loanRequests)-1]
+		page.NextCursor = q.NextCursor(last.CreatedAt, last.Amount, last.ID)
 	}
 
-	return loanRequests, nil
+	return loanRequests, page, nil
 }
@@ -3,51 +3,61 @@ package loanrequests
 import (
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/loan"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
 type Repo interface {
 	Insert(loanRequest *LoanRequest) error
-	Get(loanRequestID, userID int64) (*LoanRequest, error)
 	UpdateTx(loanRequestID, userID int64, newStatus string) (*LoanRequest, error)
-	GetAllUserLoanRequests(userID int64) ([]*LoanRequest, error)
+	AcceptTx(
+		loanRequestID, userID int64,
+		prepare func(loanRequest *LoanRequest) (*ledger.Entry, *loan.Loan),
+	) (*LoanRequest, error)
+	GetAllUserLoanRequests(userID int64, q *history.Query) ([]*LoanRequest, *history.Page, error)
 }
 
-type UserService interface {
-	GetUser(userID int64) (*user.User, error)
-	UpdateUser(
-		userID int64, userName, userEmail string, userPasswordHash []byte,
-		userAccountBalance float64, userActivated bool,
-	) (*user.User, error)
-}
-
-type LoanService interface {
-	GetLoan(u *user.User, amount, dailyInterestRate float64) error
+type AccountService interface {
+	GetUserAccount(userID int64, number string) (*account.Account, error)
 }
 
 type Service struct {
-	Repo        Repo
-	UserService UserService
-	LoanService LoanService
+	Repo           Repo
+	AccountService AccountService
 }
 
+// New requests a loan to be paid into the user's account with the given number, or their primary
+// account when no number is given
 func (s *Service) New(
-	v *validator.Validator, u *user.User, amount, dailyInterestRate float64,
+	v *validator.Validator, u *user.User, accountNumber string, amount money.Amount,
+	dailyInterestRate float64,
 ) (*LoanRequest, error) {
+	a, err := s.AccountService.GetUserAccount(u.ID, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
 	loanRequest := LoanRequest{
 		CreatedAt:         time.Now(),
 		UserID:            u.ID,
+		AccountID:         a.ID,
+		Currency:          a.Currency,
 		Amount:            amount,
 		DailyInterestRate: dailyInterestRate,
 		Status:            "PENDING",
 	}
 
+	v.CheckAddError(a.IsActive(), "account", "is not active")
 	if ValidateLoanRequest(v, &loanRequest); !v.IsValid() {
 		return nil, validator.ErrFailedValidation
 	}
 
-	err := s.Repo.Insert(&loanRequest)
+	err = s.Repo.Insert(&loanRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -55,53 +65,47 @@ func (s *Service) New(
 	return &loanRequest, nil
 }
 
+// AcceptLoanRequest accepts the pending loan request, pays the loan into the account it was
+// requested for and records it on the loans table, all at once
 func (s *Service) AcceptLoanRequest(loanRequestID, userID int64) (*LoanRequest, error) {
-	loanRequest, err := s.Repo.Get(loanRequestID, userID)
-	if err != nil {
-		return nil, err
-	}
-
-	if loanRequest.Status != "PENDING" {
-		return nil, user.ErrNoRecord
-	}
-
-	loanRequest, err = s.Repo.UpdateTx(loanRequestID, userID, "ACCEPTED")
-	if err != nil {
-		return nil, err
-	}
-
-	// update the user account, add the loan to the account balance
-	u, err := s.UserService.GetUser(userID)
-	if err != nil {
-		return nil, err
-	}
-
-	u.AccountBalance += loanRequest.Amount
-	_, err = s.UserService.UpdateUser(
-		userID, u.Name, u.Email, u.Password.Hash, u.AccountBalance, u.Activated,
+	return s.Repo.AcceptTx(loanRequestID, userID,
+		func(loanRequest *LoanRequest) (*ledger.Entry, *loan.Loan) {
+			entry := ledger.NewEntry(
+				"loan disbursement",
+				ledger.SystemPosting(
+					ledger.AccountLoans, loanRequest.Currency, loanRequest.Amount.Neg(),
+				),
+				ledger.AccountPosting(
+					loanRequest.AccountID, loanRequest.Currency, loanRequest.Amount,
+				),
+			)
+			l := &loan.Loan{
+				UserID:            loanRequest.UserID,
+				AccountID:         loanRequest.AccountID,
+				Currency:          loanRequest.Currency,
+				Amount:            loanRequest.Amount,
+				Action:            "took",
+				DailyInterestRate: loanRequest.DailyInterestRate,
+				RemainingAmount:   loanRequest.Amount,
+				LastUpdatedAt:     time.Now(),
+			}
+			return entry, l
+		},
 	)
-	if err != nil {
-		return nil, err
-	}
-
-	// record the loan on the loans table
-	err = s.LoanService.GetLoan(u, loanRequest.Amount, loanRequest.DailyInterestRate)
-	if err != nil {
-		return nil, err
-	}
-
-	return loanRequest, nil
 }
 
+// DeclineLoanRequest declines the loan request, one already responded to can't be declined
 func (s *Service) DeclineLoanRequest(loanRequestID, userID int64) (*LoanRequest, error) {
-	loanRequest, err := s.Repo.UpdateTx(loanRequestID, userID, "DECLINED")
-	if err != nil {
-		return nil, err
-	}
-
-	return loanRequest, nil
+	return s.Repo.UpdateTx(loanRequestID, userID, "DECLINED")
 }
 
-func (s *Service) GetAllUserLoanRequests(userID int64) ([]*LoanRequest, error) {
-	return s.Repo.GetAllUserLoanRequests(userID)
+// GetAllUserLoanRequests gets a page of the user's loan requests, the ones the query asks for
+func (s *Service) GetAllUserLoanRequests(
+	v *validator.Validator, userID int64, q *history.Query,
+) ([]*LoanRequest, *history.Page, error) {
+	if history.ValidateQuery(v, q, loanRequestHistory); !v.IsValid() {
+		return nil, nil, validator.ErrFailedValidation
+	}
+
+	return s.Repo.GetAllUserLoanRequests(userID, q)
 }
@@ -4,16 +4,25 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/loan"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
 // ---MOCKS---
+
+// MockRepo accepts GetResult when it is pending, applying the postings made against the account to
+// the account it holds and keeping the loan recorded
 type MockRepo struct {
 	InsertErr error
 
 	GetResult *LoanRequest
-	GetErr    error
+	Account   *account.Account
+	Loan      *loan.Loan
 
 	UpdateTxResult *LoanRequest
 	UpdateTxErr    error
@@ -23,13 +32,6 @@ func (r *MockRepo) Insert(loanRequest *LoanRequest) error {
 	return r.InsertErr
 }
 
-func (r *MockRepo) Get(loanRequestID, userID int64) (*LoanRequest, error) {
-	if r.GetErr != nil {
-		return nil, r.GetErr
-	}
-	return r.GetResult, nil
-}
-
 func (r *MockRepo) UpdateTx(loanRequestID, userID int64, newStatus string) (*LoanRequest, error) {
 	if r.UpdateTxErr != nil {
 		return nil, r.UpdateTxErr
@@ -37,37 +39,45 @@ func (r *MockRepo) UpdateTx(loanRequestID, userID int64, newStatus string) (*Loa
 	return r.UpdateTxResult, nil
 }
 
-type MockUserService struct {
-	GetUserResult *user.User
-	GetUserErr    error
-
-	UpdateUserResult *user.User
-	UpdateUserErr    error
-}
+func (r *MockRepo) AcceptTx(
+	loanRequestID, userID int64,
+	prepare func(loanRequest *LoanRequest) (*ledger.Entry, *loan.Loan),
+) (*LoanRequest, error) {
+	if r.UpdateTxErr != nil {
+		return nil, r.UpdateTxErr
+	}
+	if r.GetResult.Status != "PENDING" {
+		return nil, user.ErrNoRecord
+	}
 
-func (us *MockUserService) GetUser(userID int64) (*user.User, error) {
-	if us.GetUserErr != nil {
-		return nil, us.GetUserErr
+	loanRequest := *r.GetResult
+	loanRequest.Status = "ACCEPTED"
+	entry, l := prepare(&loanRequest)
+	for _, posting := range entry.Postings {
+		if r.Account != nil && posting.AccountID == r.Account.ID {
+			r.Account.Balance += posting.Amount
+		}
 	}
-	return us.GetUserResult, nil
+	r.Loan = l
+	return &loanRequest, nil
 }
 
-func (us *MockUserService) UpdateUser(
-	userID int64, userName, userEmail string, userPasswordHash []byte,
-	userAccountBalance float64, userActivated bool,
-) (*user.User, error) {
-	if us.UpdateUserErr != nil {
-		return nil, us.UpdateUserErr
-	}
-	return us.UpdateUserResult, nil
+func (r *MockRepo) GetAllUserLoanRequests(
+	userID int64, q *history.Query,
+) ([]*LoanRequest, *history.Page, error) {
+	return nil, nil, nil
 }
 
-type MockLoanService struct {
-	GetLoanErr error
+type MockAccountService struct {
+	GetUserAccountResult *account.Account
+	GetUserAccountErr    error
 }
 
-func (ls *MockLoanService) GetLoan(u *user.User, amount, dialyInterestRate float64) error {
-	return ls.GetLoanErr
+func (as *MockAccountService) GetUserAccount(userID int64, number string) (*account.Account, error) {
+	if as.GetUserAccountErr != nil {
+		return nil, as.GetUserAccountErr
+	}
+	return as.GetUserAccountResult, nil
 }
 
 func TestNew(t *testing.T) {
@@ -75,16 +85,26 @@ func TestNew(t *testing.T) {
 		ID:             1,
 		Name:           "yusuf",
 		Email:          "ym@gmail.com",
-		AccountBalance: 100,
+		AccountBalance: money.MustParse("100"),
+	}
+	mockAccount := &account.Account{
+		ID:      1,
+		UserID:  1,
+		Number:  "1000000000",
+		Type:    account.TypeChecking,
+		Status:  account.StatusActive,
+		Balance: money.MustParse("100"),
 	}
 
 	tests := []struct {
-		name      string
-		setupRepo func(*MockRepo)
-		input     struct {
-			v                         *validator.Validator
-			u                         *user.User
-			amount, dialyInterestRate float64
+		name                string
+		setupRepo           func(*MockRepo)
+		setupAccountService func(*MockAccountService)
+		input               struct {
+			v                 *validator.Validator
+			u                 *user.User
+			amount            money.Amount
+			dialyInterestRate float64
 		}
 		expectedErr error
 	}{
@@ -94,9 +114,9 @@ func TestNew(t *testing.T) {
 			input: struct {
 				v                 *validator.Validator
 				u                 *user.User
-				amount            float64
+				amount            money.Amount
 				dialyInterestRate float64
-			}{v: validator.New(), u: mockUser, amount: 100, dialyInterestRate: 5},
+			}{v: validator.New(), u: mockUser, amount: money.MustParse("100"), dialyInterestRate: 5},
 		},
 		{
 			name:      "amount = 0",
@@ -104,9 +124,9 @@ func TestNew(t *testing.T) {
 			input: struct {
 				v                 *validator.Validator
 				u                 *user.User
-				amount            float64
+				amount            money.Amount
 				dialyInterestRate float64
-			}{v: validator.New(), u: mockUser, amount: 0, dialyInterestRate: 5},
+			}{v: validator.New(), u: mockUser, amount: money.MustParse("0"), dialyInterestRate: 5},
 			expectedErr: validator.ErrFailedValidation,
 		},
 		{
@@ -115,9 +135,9 @@ func TestNew(t *testing.T) {
 			input: struct {
 				v                 *validator.Validator
 				u                 *user.User
-				amount            float64
+				amount            money.Amount
 				dialyInterestRate float64
-			}{v: validator.New(), u: mockUser, amount: -100, dialyInterestRate: 5},
+			}{v: validator.New(), u: mockUser, amount: money.MustParse("-100"), dialyInterestRate: 5},
 			expectedErr: validator.ErrFailedValidation,
 		},
 		{
@@ -126,9 +146,9 @@ func TestNew(t *testing.T) {
 			input: struct {
 				v                 *validator.Validator
 				u                 *user.User
-				amount            float64
+				amount            money.Amount
 				dialyInterestRate float64
-			}{v: validator.New(), u: mockUser, amount: 100, dialyInterestRate: -5},
+			}{v: validator.New(), u: mockUser, amount: money.MustParse("100"), dialyInterestRate: -5},
 			expectedErr: validator.ErrFailedValidation,
 		},
 		{
@@ -139,21 +159,55 @@ func TestNew(t *testing.T) {
 			input: struct {
 				v                 *validator.Validator
 				u                 *user.User
-				amount            float64
+				amount            money.Amount
 				dialyInterestRate float64
-			}{v: validator.New(), u: mockUser, amount: 100, dialyInterestRate: 5},
+			}{v: validator.New(), u: mockUser, amount: money.MustParse("100"), dialyInterestRate: 5},
 			expectedErr: errors.New("db error"),
 		},
+		{
+			name:      "account not found",
+			setupRepo: func(r *MockRepo) {},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetUserAccountErr = user.ErrNoRecord
+			},
+			input: struct {
+				v                 *validator.Validator
+				u                 *user.User
+				amount            money.Amount
+				dialyInterestRate float64
+			}{v: validator.New(), u: mockUser, amount: money.MustParse("100"), dialyInterestRate: 5},
+			expectedErr: user.ErrNoRecord,
+		},
+		{
+			name:      "account not active",
+			setupRepo: func(r *MockRepo) {},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetUserAccountResult = &account.Account{
+					ID: 1, UserID: 1, Status: account.StatusFrozen,
+				}
+			},
+			input: struct {
+				v                 *validator.Validator
+				u                 *user.User
+				amount            money.Amount
+				dialyInterestRate float64
+			}{v: validator.New(), u: mockUser, amount: money.MustParse("100"), dialyInterestRate: 5},
+			expectedErr: validator.ErrFailedValidation,
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			repo := &MockRepo{}
 			tc.setupRepo(repo)
-			svc := Service{Repo: repo}
+			accountSvc := &MockAccountService{GetUserAccountResult: mockAccount}
+			if tc.setupAccountService != nil {
+				tc.setupAccountService(accountSvc)
+			}
+			svc := Service{Repo: repo, AccountService: accountSvc}
 
 			loanRequest, gotErr := svc.New(
-				tc.input.v, tc.input.u, tc.input.amount, tc.input.dialyInterestRate,
+				tc.input.v, tc.input.u, "", tc.input.amount, tc.input.dialyInterestRate,
 			)
 			if tc.expectedErr != nil {
 				if gotErr.Error() != tc.expectedErr.Error() {
@@ -168,8 +222,12 @@ func TestNew(t *testing.T) {
 				t.Errorf("expected user id %d, got %d", mockUser.ID, loanRequest.UserID)
 			}
 
+			if loanRequest.AccountID != mockAccount.ID {
+				t.Errorf("expected account id %d, got %d", mockAccount.ID, loanRequest.AccountID)
+			}
+
 			if loanRequest.Amount != tc.input.amount {
-				t.Errorf("expected amount %f, got %f", tc.input.amount, loanRequest.Amount)
+				t.Errorf("expected amount %v, got %v", tc.input.amount, loanRequest.Amount)
 			}
 
 			if loanRequest.DailyInterestRate != tc.input.dialyInterestRate {
@@ -191,159 +249,56 @@ func TestAcceptLoanRequest(t *testing.T) {
 	mockLoanRequest := &LoanRequest{
 		ID:                1,
 		UserID:            1,
-		Amount:            100,
+		AccountID:         1,
+		Amount:            money.MustParse("100"),
 		DailyInterestRate: 5,
 	}
-	mockUser := &user.User{
-		ID:             1,
-		Name:           "yusuf",
-		Email:          "ym@gmail",
-		AccountBalance: 0,
+	mockAccount := &account.Account{
+		ID:      1,
+		UserID:  1,
+		Number:  "1000000000",
+		Type:    account.TypeChecking,
+		Status:  account.StatusActive,
+		Balance: money.MustParse("0"),
 	}
 
 	tests := []struct {
-		name             string
-		setupRepo        func(*MockRepo)
-		setupUserService func(*MockUserService)
-		setupLoanService func(*MockLoanService)
-		input            struct {
+		name      string
+		setupRepo func(*MockRepo)
+		input     struct {
 			loanRequestID, userID int64
 		}
 		loanRequestOriginalStatus string
 		expectedErr               error
 	}{
 		{
-			name: "valid",
-			setupRepo: func(r *MockRepo) {
-				r.GetResult = mockLoanRequest
-				r.UpdateTxResult = &LoanRequest{
-					ID: mockLoanRequest.ID, UserID: mockLoanRequest.UserID,
-					Amount: mockLoanRequest.Amount, Status: "ACCEPTED",
-					DailyInterestRate: mockLoanRequest.DailyInterestRate,
-				}
-			},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
-			},
-			setupLoanService: func(ls *MockLoanService) {},
+			name:      "valid",
+			setupRepo: func(r *MockRepo) {},
 			input: struct {
 				loanRequestID int64
 				userID        int64
-			}{loanRequestID: mockLoanRequest.ID, userID: mockUser.ID},
+			}{loanRequestID: mockLoanRequest.ID, userID: mockLoanRequest.UserID},
 			loanRequestOriginalStatus: "PENDING",
 		},
 		{
-			name: "loan request already responded to",
-			setupRepo: func(r *MockRepo) {
-				r.GetResult = mockLoanRequest
-				r.UpdateTxResult = &LoanRequest{
-					ID: mockLoanRequest.ID, UserID: mockLoanRequest.UserID,
-					Amount: mockLoanRequest.Amount, Status: "ACCEPTED",
-					DailyInterestRate: mockLoanRequest.DailyInterestRate,
-				}
-			},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
-			},
-			setupLoanService: func(ls *MockLoanService) {},
+			name:      "loan request already responded to",
+			setupRepo: func(r *MockRepo) {},
 			input: struct {
 				loanRequestID int64
 				userID        int64
-			}{loanRequestID: mockLoanRequest.ID, userID: mockUser.ID},
+			}{loanRequestID: mockLoanRequest.ID, userID: mockLoanRequest.UserID},
 			loanRequestOriginalStatus: "ACCEPTED",
 			expectedErr:               user.ErrNoRecord,
 		},
-		{
-			name: "Get loan failure",
-			setupRepo: func(r *MockRepo) {
-				r.GetErr = errors.New("db error")
-			},
-			setupUserService: func(us *MockUserService) {},
-			setupLoanService: func(ls *MockLoanService) {},
-			input: struct {
-				loanRequestID int64
-				userID        int64
-			}{loanRequestID: mockLoanRequest.ID, userID: mockUser.ID},
-			expectedErr: errors.New("db error"),
-		},
-		{
-			name: "Get user failure",
-			setupRepo: func(r *MockRepo) {
-				r.GetResult = mockLoanRequest
-				r.UpdateTxResult = &LoanRequest{
-					ID: mockLoanRequest.ID, UserID: mockLoanRequest.UserID,
-					Amount: mockLoanRequest.Amount, Status: "ACCEPTED",
-					DailyInterestRate: mockLoanRequest.DailyInterestRate,
-				}
-			},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserErr = user.ErrNoRecord
-			},
-			setupLoanService: func(ls *MockLoanService) {},
-			input: struct {
-				loanRequestID int64
-				userID        int64
-			}{loanRequestID: mockLoanRequest.ID, userID: mockUser.ID},
-			expectedErr:               user.ErrNoRecord,
-			loanRequestOriginalStatus: "PENDING",
-		},
-		{
-			name: "update user failure",
-			setupRepo: func(r *MockRepo) {
-				r.GetResult = mockLoanRequest
-				r.UpdateTxResult = &LoanRequest{
-					ID: mockLoanRequest.ID, UserID: mockLoanRequest.UserID,
-					Amount: mockLoanRequest.Amount, Status: "ACCEPTED",
-					DailyInterestRate: mockLoanRequest.DailyInterestRate,
-				}
-			},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
-				us.UpdateUserErr = errors.New("db error")
-			},
-			setupLoanService: func(ls *MockLoanService) {},
-			input: struct {
-				loanRequestID int64
-				userID        int64
-			}{loanRequestID: mockLoanRequest.ID, userID: mockUser.ID},
-			expectedErr:               errors.New("db error"),
-			loanRequestOriginalStatus: "PENDING",
-		},
 		{
 			name: "update loan failure",
 			setupRepo: func(r *MockRepo) {
-				r.GetResult = mockLoanRequest
 				r.UpdateTxErr = errors.New("db error")
 			},
-			setupUserService: func(us *MockUserService) {},
-			setupLoanService: func(ls *MockLoanService) {},
-			input: struct {
-				loanRequestID int64
-				userID        int64
-			}{loanRequestID: mockLoanRequest.ID, userID: mockUser.ID},
-			loanRequestOriginalStatus: "PENDING",
-			expectedErr:               errors.New("db error"),
-		},
-		{
-			name: "GetLoan failure",
-			setupRepo: func(r *MockRepo) {
-				r.GetResult = mockLoanRequest
-				r.UpdateTxResult = &LoanRequest{
-					ID: mockLoanRequest.ID, UserID: mockLoanRequest.UserID,
-					Amount: mockLoanRequest.Amount, Status: "ACCEPTED",
-					DailyInterestRate: mockLoanRequest.DailyInterestRate,
-				}
-			},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
-			},
-			setupLoanService: func(ls *MockLoanService) {
-				ls.GetLoanErr = errors.New("db error")
-			},
 			input: struct {
 				loanRequestID int64
 				userID        int64
-			}{loanRequestID: mockLoanRequest.ID, userID: mockUser.ID},
+			}{loanRequestID: mockLoanRequest.ID, userID: mockLoanRequest.UserID},
 			loanRequestOriginalStatus: "PENDING",
 			expectedErr:               errors.New("db error"),
 		},
@@ -351,18 +306,13 @@ func TestAcceptLoanRequest(t *testing.T) {
 
 	for _, tc := range tests {
 		mockLoanRequest.Status = tc.loanRequestOriginalStatus
+		mockAccount.Balance = 0
 		t.Run(tc.name, func(t *testing.T) {
-			repo := &MockRepo{}
-			userSvc := &MockUserService{}
-			loanSvc := &MockLoanService{}
+			repo := &MockRepo{GetResult: mockLoanRequest, Account: mockAccount}
 			tc.setupRepo(repo)
-			tc.setupUserService(userSvc)
-			tc.setupLoanService(loanSvc)
 
 			svc := Service{
-				Repo:        repo,
-				UserService: userSvc,
-				LoanService: loanSvc,
+				Repo: repo,
 			}
 
 			loanRequest, gotErr := svc.AcceptLoanRequest(tc.input.loanRequestID, tc.input.userID)
@@ -371,37 +321,37 @@ func TestAcceptLoanRequest(t *testing.T) {
 				if gotErr.Error() != tc.expectedErr.Error() {
 					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
 				}
+				if mockAccount.Balance != 0 {
+					t.Errorf("expected nothing paid out, got balance %v", mockAccount.Balance)
+				}
 				return
 			} else if gotErr != nil {
 				t.Fatalf("unexpected error :%v", gotErr)
 			}
 
-			if loanRequest.UserID != mockUser.ID {
-				t.Errorf("expected user id %d, got %d", mockUser.ID, loanRequest.UserID)
-			}
-
-			if loanRequest.Amount != mockLoanRequest.Amount {
-				t.Errorf("expected amount %f, got %f", mockLoanRequest.Amount, loanRequest.Amount)
-			}
-
-			if loanRequest.DailyInterestRate != mockLoanRequest.DailyInterestRate {
-				t.Errorf(
-					"expected dialy interest rate %f, got %f", mockLoanRequest.DailyInterestRate,
-					loanRequest.DailyInterestRate,
-				)
+			if loanRequest.UserID != mockLoanRequest.UserID {
+				t.Errorf("expected user id %d, got %d", mockLoanRequest.UserID, loanRequest.UserID)
 			}
 
 			if loanRequest.Status != "ACCEPTED" {
 				t.Errorf("expected status %s, got %s", "ACCEPTED", loanRequest.Status)
 			}
 
-			// check if the money is getting added to the users account
-			if mockUser.AccountBalance != loanRequest.Amount {
+			// check if the money is getting added to the account
+			if mockAccount.Balance != loanRequest.Amount {
 				t.Errorf(
-					"expected user account balance %f, got %f",
-					loanRequest.Amount, mockUser.AccountBalance,
+					"expected account balance %v, got %v",
+					loanRequest.Amount, mockAccount.Balance,
 				)
 			}
+
+			// and the loan is recorded with the request's terms
+			l := repo.Loan
+			if l == nil || l.Amount != mockLoanRequest.Amount ||
+				l.RemainingAmount != mockLoanRequest.Amount ||
+				l.DailyInterestRate != mockLoanRequest.DailyInterestRate {
+				t.Errorf("expected the loan recorded from the request, got %+v", l)
+			}
 		})
 	}
 }
@@ -410,7 +360,7 @@ func TestDeclineLoanRequest(t *testing.T) {
 	mockLoanRequest := &LoanRequest{
 		ID:                1,
 		UserID:            1,
-		Amount:            100,
+		Amount:            money.MustParse("100"),
 		DailyInterestRate: 5,
 		Status:            "PENDING",
 	}
@@ -53,7 +53,20 @@ func New(host string, port int, username, password, sender string) *Mailer {
 	}
 }
 
+// Attachment is a file sent along with an email
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
 func (mailer *Mailer) Send(recipient, templateFile string, data map[string]any) error {
+	return mailer.SendWithAttachments(recipient, templateFile, data)
+}
+
+// SendWithAttachments is Send with files attached to the email
+func (mailer *Mailer) SendWithAttachments(
+	recipient, templateFile string, data map[string]any, attachments ...Attachment,
+) error {
 	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
 	if err != nil {
 		return err
@@ -87,6 +100,9 @@ func (mailer *Mailer) Send(recipient, templateFile string, data map[string]any)
 	msg.SetHeader("Subject", subject.String())
 	msg.SetBody("text/plain", plainBody.String())
 	msg.AddAlternative("text/html", htmlBody.String())
+	for _, attachment := range attachments {
+		msg.AttachReader(attachment.Name, bytes.NewReader(attachment.Data))
+	}
 
 	// send the email
 	return mailer.dialer.DialAndSend(msg)
@@ -97,3 +97,29 @@ func TestMailerSend(t *testing.T) {
 		})
 	}
 }
+
+func TestMailerSendWithAttachments(t *testing.T) {
+	fake := &fakeDialer{}
+	m := &Mailer{dialer: fake, sender: "me@example.com"}
+
+	err := m.SendWithAttachments(
+		"yusuf", "statement_monthly.html",
+		map[string]any{"userName": "yusuf", "accountNumber": "1000000000", "period": "September 2026"},
+		Attachment{Name: "statement.csv", Data: []byte("account,1000000000\n")},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(fake.sent) != 1 {
+		t.Fatalf("expected 1 email sent, got %d", len(fake.sent))
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = fake.sent[0].WriteTo(buf)
+	if err != nil {
+		t.Fatalf("failed to write the email: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`filename="statement.csv"`)) {
+		t.Errorf("expected the attachment in the email")
+	}
+}
@@ -0,0 +1,15 @@
+package money
+
+import "slices"
+
+// DefaultCurrency is the currency of everything that was recorded before accounts had a currency,
+// and of new accounts opened without one
+const DefaultCurrency = "USD"
+
+// Currencies are the ISO 4217 codes of the currencies accounts can be held in. amounts are always
+// held in hundredths of the unit, so only currencies with 2 minor digits can be added here
+var Currencies = []string{"USD", "EUR", "GBP", "CAD", "AUD", "CHF", "KES"}
+
+func ValidCurrency(code string) bool {
+	return slices.Contains(Currencies, code)
+}
@@ -0,0 +1,284 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount = errors.New("invalid amount")
+	ErrTooPrecise    = errors.New("amount cannot have more than 2 decimal places")
+)
+
+// Amount is a sum of money held as a whole number of cents, so that adding, subtracting and
+// comparing amounts is always exact. it matches the DECIMAL(12, 2) columns in the database
+type Amount int64
+
+const centsPerUnit = 100
+
+// RoundingMode decides what happens to the fraction of a cent left over by a calculation
+type RoundingMode int8
+
+const (
+	// RoundHalfEven rounds to the nearest cent and ties to the even cent, banker's rounding. it is
+	// the default for interest so that rounding errors don't build up in one direction
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest cent and ties away from zero
+	RoundHalfUp
+	// RoundDown drops the fraction, rounding towards zero
+	RoundDown
+	// RoundUp rounds away from zero whenever there is a fraction
+	RoundUp
+)
+
+func FromCents(cents int64) Amount {
+	return Amount(cents)
+}
+
+func (a Amount) Cents() int64 {
+	return int64(a)
+}
+
+// Parse reads a decimal string such as "12", "12.3" or "-12.34". it is exact, amounts with more than
+// 2 decimal places are rejected rather than silently rounded
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	units, fraction, hasFraction := strings.Cut(s, ".")
+	if units == "" && fraction == "" || hasFraction && fraction == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	// drop trailing zeros so "1.500" is accepted as 1.50
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > 2 {
+		return 0, ErrTooPrecise
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	if units == "" {
+		units = "0"
+	}
+	for _, digits := range []string{units, fraction} {
+		for _, c := range digits {
+			if c < '0' || c > '9' {
+				return 0, ErrInvalidAmount
+			}
+		}
+	}
+
+	u, err := strconv.ParseInt(units, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	f, _ := strconv.ParseInt(fraction, 10, 64)
+
+	cents := u*centsPerUnit + f
+	if cents/centsPerUnit != u {
+		return 0, ErrInvalidAmount
+	}
+	if negative {
+		cents = -cents
+	}
+
+	return Amount(cents), nil
+}
+
+// MustParse is like Parse but panics on bad input. it is meant for constants and tests
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(fmt.Sprintf("money: MustParse(%q): %v", s, err))
+	}
+	return a
+}
+
+// FromRat converts an exact rational amount of money to cents using the rounding mode
+func FromRat(r *big.Rat, mode RoundingMode) Amount {
+	cents := new(big.Rat).Mul(r, big.NewRat(centsPerUnit, 1))
+
+	quotient, remainder := new(big.Int).QuoRem(cents.Num(), cents.Denom(), new(big.Int))
+	if remainder.Sign() == 0 {
+		return Amount(quotient.Int64())
+	}
+
+	// away is +1 or -1, the direction to move the truncated quotient when rounding away from zero
+	away := big.NewInt(int64(cents.Sign()))
+
+	// compare twice the remainder with the denominator to tell if we are below, at or above half
+	twice := new(big.Int).Abs(remainder)
+	twice.Lsh(twice, 1)
+	half := twice.Cmp(cents.Denom())
+
+	roundAway := false
+	switch mode {
+	case RoundHalfEven:
+		roundAway = half > 0 || half == 0 && quotient.Bit(0) == 1
+	case RoundHalfUp:
+		roundAway = half >= 0
+	case RoundUp:
+		roundAway = true
+	case RoundDown:
+		roundAway = false
+	}
+
+	if roundAway {
+		quotient.Add(quotient, away)
+	}
+
+	return Amount(quotient.Int64())
+}
+
+// FromFloat converts a float, such as one read from a config flag, to cents using the rounding
+// mode. it goes through the shortest decimal representation of the float so that 0.1 is read as
+// exactly 0.1
+func FromFloat(f float64, mode RoundingMode) Amount {
+	return FromRat(RatFromFloat(f), mode)
+}
+
+// RatFromFloat returns the exact decimal value a float is printed as, useful for rates
+func RatFromFloat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r
+}
+
+func (a Amount) Rat() *big.Rat {
+	return big.NewRat(int64(a), centsPerUnit)
+}
+
+// Mul multiplies the amount by an exact factor, such as an interest rate, and rounds the result to
+// the cent with the rounding mode
+func (a Amount) Mul(factor *big.Rat, mode RoundingMode) Amount {
+	return FromRat(new(big.Rat).Mul(a.Rat(), factor), mode)
+}
+
+func (a Amount) Neg() Amount {
+	return -a
+}
+
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func (a Amount) IsZero() bool {
+	return a == 0
+}
+
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func Max(a, b Amount) Amount {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Float64 returns the amount as a float, only for display and logging, never for arithmetic
+func (a Amount) Float64() float64 {
+	return float64(a) / centsPerUnit
+}
+
+// String formats the amount with exactly 2 decimal places, e.g. "-12.05"
+func (a Amount) String() string {
+	sign := ""
+	cents := int64(a)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/centsPerUnit, cents%centsPerUnit)
+}
+
+// MarshalJSON writes the amount as a JSON number with 2 decimal places
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and strings, the digits are parsed exactly without going
+// through a float
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+// Set parses the amount from a command line flag, so an Amount can be used with flag.Var
+func (a *Amount) Set(s string) error {
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+// Scan reads a DECIMAL column, which the postgres driver hands over as text
+func (a *Amount) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case []byte:
+		amount, err := Parse(string(value))
+		if err != nil {
+			return err
+		}
+		*a = amount
+		return nil
+	case string:
+		amount, err := Parse(value)
+		if err != nil {
+			return err
+		}
+		*a = amount
+		return nil
+	case int64:
+		*a = Amount(value * centsPerUnit)
+		return nil
+	case float64:
+		*a = FromFloat(value, RoundHalfEven)
+		return nil
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+}
+
+// Value writes the amount as a decimal string so that postgres stores it without any rounding
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
@@ -0,0 +1,155 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		want        Amount
+		expectedErr error
+	}{
+		{name: "whole", input: "12", want: 1200},
+		{name: "one decimal", input: "12.3", want: 1230},
+		{name: "two decimals", input: "12.34", want: 1234},
+		{name: "negative", input: "-12.05", want: -1205},
+		{name: "no units", input: ".5", want: 50},
+		{name: "trailing zeros", input: "1.500", want: 150},
+		{name: "too precise", input: "0.105", expectedErr: ErrTooPrecise},
+		{name: "empty", input: "", expectedErr: ErrInvalidAmount},
+		{name: "letters", input: "12a", expectedErr: ErrInvalidAmount},
+		{name: "dangling point", input: "12.", expectedErr: ErrInvalidAmount},
+		{name: "overflow", input: "999999999999999999999", expectedErr: ErrInvalidAmount},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, gotErr := Parse(tc.input)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if got != tc.want {
+				t.Errorf("expected %d cents, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		input Amount
+		want  string
+	}{
+		{input: 0, want: "0.00"},
+		{input: 5, want: "0.05"},
+		{input: 1234, want: "12.34"},
+		{input: -1205, want: "-12.05"},
+		{input: -5, want: "-0.05"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			if got := tc.input.String(); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFromRat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input *big.Rat
+		mode  RoundingMode
+		want  Amount
+	}{
+		{name: "half even, tie to even", input: big.NewRat(1005, 1000), mode: RoundHalfEven, want: 100},
+		{name: "half even, tie to even up", input: big.NewRat(1015, 1000), mode: RoundHalfEven, want: 102},
+		{name: "half even, above half", input: big.NewRat(10051, 10000), mode: RoundHalfEven, want: 101},
+		{name: "half even, negative tie", input: big.NewRat(-1025, 1000), mode: RoundHalfEven, want: -102},
+		{name: "half up, tie", input: big.NewRat(1005, 1000), mode: RoundHalfUp, want: 101},
+		{name: "half up, negative tie", input: big.NewRat(-1005, 1000), mode: RoundHalfUp, want: -101},
+		{name: "down", input: big.NewRat(1009, 1000), mode: RoundDown, want: 100},
+		{name: "down, negative", input: big.NewRat(-1009, 1000), mode: RoundDown, want: -100},
+		{name: "up", input: big.NewRat(1001, 1000), mode: RoundUp, want: 101},
+		{name: "exact", input: big.NewRat(101, 100), mode: RoundUp, want: 101},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := FromRat(tc.input, tc.mode); got != tc.want {
+				t.Errorf("expected %d cents, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	// 0.1 + 0.2 is 0.30000000000000004 as a float, it should still come out as 30 cents
+	if got := FromFloat(0.1+0.2, RoundHalfEven); got != 30 {
+		t.Errorf("expected 30 cents, got %d", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var input struct {
+		Amount Amount `json:"amount"`
+	}
+
+	for _, body := range []string{`{"amount": 10.10}`, `{"amount": "10.10"}`} {
+		err := json.Unmarshal([]byte(body), &input)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if input.Amount != 1010 {
+			t.Errorf("%s: expected 1010 cents, got %d", body, input.Amount)
+		}
+	}
+
+	err := json.Unmarshal([]byte(`{"amount": 10.101}`), &input)
+	if !errors.Is(err, ErrTooPrecise) {
+		t.Errorf("expected error %v, got %v", ErrTooPrecise, err)
+	}
+
+	got, err := json.Marshal(input)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(got) != `{"amount":10.10}` {
+		t.Errorf("expected %s, got %s", `{"amount":10.10}`, got)
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    Amount
+		wantErr bool
+	}{
+		{name: "bytes", src: []byte("100.25"), want: 10025},
+		{name: "string", src: "-0.50", want: -50},
+		{name: "int", src: int64(3), want: 300},
+		{name: "float", src: 0.29, want: 29},
+		{name: "nil", src: nil, want: 0},
+		{name: "unsupported", src: true, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got Amount
+			err := got.Scan(tc.src)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %d cents, got %d", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package overdraft
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/loan"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// the most interest an overdraft can be charged, in percent a day
+var maxDailyInterestRate = fx.MustParseDecimal("10")
+
+// Overdraft is how far an account can go below zero, set by an admin, and the interest charged on
+// what it owes in percent a day. a limit of 0 means the account can't go below zero
+type Overdraft struct {
+	AccountID         int64        `json:"account_id"`
+	AccountNumber     string       `json:"account_number"`
+	Currency          string       `json:"currency"`
+	Limit             money.Amount `json:"limit"`
+	DailyInterestRate fx.Decimal   `json:"daily_interest_rate"`
+}
+
+func ValidateOverdraft(v *validator.Validator, overdraft *Overdraft) {
+	v.CheckAddError(overdraft.Limit >= 0, "limit", "must not be negative")
+	v.CheckAddError(
+		overdraft.DailyInterestRate.Sign() >= 0, "daily interest rate", "must not be negative",
+	)
+	v.CheckAddError(
+		overdraft.DailyInterestRate.Cmp(maxDailyInterestRate) <= 0, "daily interest rate",
+		"must not be more than "+maxDailyInterestRate.String(),
+	)
+	// the rate is stored to 4 decimal places
+	scaled := new(big.Rat).Mul(overdraft.DailyInterestRate.Rat(), big.NewRat(10_000, 1))
+	v.CheckAddError(
+		scaled.IsInt(), "daily interest rate", "must not have more than 4 decimal places",
+	)
+}
+
+// Accrual is where an account that is, or just was, overdrawn stands with its interest, read with
+// the account row locked. InterestFrom is when interest is next counted from, nil while the account
+// is not overdrawn
+type Accrual struct {
+	AccountID         int64
+	UserID            int64
+	Currency          string
+	Balance           money.Amount
+	DailyInterestRate fx.Decimal
+	InterestFrom      *time.Time
+}
+
+// Charge is what is done to an account in one run of the interest job: the interest posted, if any,
+// and when interest is counted from after it
+type Charge struct {
+	Amount       money.Amount
+	Entry        *ledger.Entry
+	InterestFrom *time.Time
+}
+
+// charge works out the interest the account owes at now. interest is charged on the balance for
+// each whole day since it was last counted, and is only counted from the first time the account is
+// seen overdrawn. the job runs more often than once a day, so the balance is sampled often enough
+// for it to be fair, and what is left of a day carries over to the next run
+func (a *Accrual) charge(now time.Time) *Charge {
+	if a.Balance >= 0 {
+		return &Charge{}
+	}
+	if a.InterestFrom == nil {
+		return &Charge{InterestFrom: &now}
+	}
+
+	days := now.Sub(*a.InterestFrom) / (24 * time.Hour)
+	if days < 1 {
+		return &Charge{InterestFrom: a.InterestFrom}
+	}
+	elapsed := days * 24 * time.Hour
+	from := a.InterestFrom.Add(elapsed)
+	c := &Charge{
+		Amount:       loan.InterestAt(a.Balance.Neg(), a.DailyInterestRate.Rat(), elapsed),
+		InterestFrom: &from,
+	}
+	if c.Amount <= 0 {
+		return c
+	}
+
+	// interest is charged even when it takes the account past its limit
+	c.Entry = ledger.NewEntry(
+		"overdraft interest",
+		ledger.AccountPosting(a.AccountID, a.Currency, c.Amount.Neg()),
+		ledger.SystemPosting(ledger.AccountOverdraftInterest, a.Currency, c.Amount),
+	)
+	c.Entry.Forced = true
+
+	return c
+}
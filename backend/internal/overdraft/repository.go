@@ -0,0 +1,149 @@
+package overdraft
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// Save sets the overdraft of the account, closed accounts are reported as not found
+func (r *Repository) Save(overdraft *Overdraft) error {
+	query := `
+		UPDATE accounts
+		SET overdraft_limit = $1, overdraft_daily_interest_rate = $2, version = version + 1
+		WHERE id = $3 AND status <> 'CLOSED'
+		RETURNING number, currency
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(
+		ctx, query, overdraft.Limit, overdraft.DailyInterestRate, overdraft.AccountID,
+	).Scan(&overdraft.AccountNumber, &overdraft.Currency)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return user.ErrNoRecord
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAccrualAccountIDs gets the accounts the interest job has to look at, the ones that are
+// overdrawn and the ones that were when it last ran
+func (r *Repository) GetAccrualAccountIDs() ([]int64, error) {
+	query := `
+		SELECT id
+		FROM accounts
+		WHERE balance < 0 OR overdraft_interest_from IS NOT NULL
+		ORDER BY id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// ChargeTx locks the account and hands where it stands to prepare, then posts the interest prepare
+// charges, records it as a transaction and saves when interest is counted from, all in one database
+// transaction. it returns the interest charged
+func (r *Repository) ChargeTx(
+	accountID int64, prepare func(accrual *Accrual) *Charge,
+) (money.Amount, error) {
+	var charged money.Amount
+	err := dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		charged = 0
+
+		query := `
+			SELECT id, user_id, currency, balance, overdraft_daily_interest_rate,
+				overdraft_interest_from
+			FROM accounts
+			WHERE id = $1
+			FOR UPDATE
+		`
+		accrual := &Accrual{}
+		err := tx.QueryRowContext(ctx, query, accountID).Scan(
+			&accrual.AccountID,
+			&accrual.UserID,
+			&accrual.Currency,
+			&accrual.Balance,
+			&accrual.DailyInterestRate,
+			&accrual.InterestFrom,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return user.ErrNoRecord
+			default:
+				return err
+			}
+		}
+
+		charge := prepare(accrual)
+		if charge.Entry != nil {
+			err = ledger.PostInTx(ctx, tx, charge.Entry)
+			if err != nil {
+				return err
+			}
+
+			query = `
+				INSERT INTO transactions
+					(user_id, account_id, currency, action, amount, performed_by)
+				VALUES ($1, $2, $3, 'OVERDRAFT_INTEREST', $4, 'system')
+			`
+			_, err = tx.ExecContext(
+				ctx, query, accrual.UserID, accrual.AccountID, accrual.Currency, charge.Amount,
+			)
+			if err != nil {
+				return err
+			}
+			charged = charge.Amount
+		}
+
+		query = `
+			UPDATE accounts
+			SET overdraft_interest_from = $1
+			WHERE id = $2
+		`
+		_, err = tx.ExecContext(ctx, query, charge.InterestFrom, accountID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return charged, nil
+}
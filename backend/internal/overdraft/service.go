@@ -0,0 +1,82 @@
+package overdraft
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type OverdraftRepo interface {
+	Save(overdraft *Overdraft) error
+	GetAccrualAccountIDs() ([]int64, error)
+	ChargeTx(accountID int64, prepare func(accrual *Accrual) *Charge) (money.Amount, error)
+}
+
+type AccountService interface {
+	GetAccountByNumber(number string) (*account.Account, error)
+}
+
+type Service struct {
+	Repo           OverdraftRepo
+	AccountService AccountService
+}
+
+// SetOverdraft sets how far the account can go below zero and the interest it is charged when it
+// does. lowering the limit below what the account already owes is allowed, the account then can't
+// spend any more until it is paid back above it
+func (s *Service) SetOverdraft(
+	v *validator.Validator, accountNumber string, limit money.Amount, dailyInterestRate fx.Decimal,
+) (*Overdraft, error) {
+	overdraft := &Overdraft{
+		AccountNumber:     accountNumber,
+		Limit:             limit,
+		DailyInterestRate: dailyInterestRate,
+	}
+	if ValidateOverdraft(v, overdraft); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	a, err := s.AccountService.GetAccountByNumber(accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status == account.StatusClosed {
+		v.AddError("account", "is closed")
+		return nil, validator.ErrFailedValidation
+	}
+	overdraft.AccountID = a.ID
+
+	err = s.Repo.Save(overdraft)
+	if err != nil {
+		return nil, err
+	}
+
+	return overdraft, nil
+}
+
+// ChargeInterest charges every overdrawn account the interest it owes at now, and returns how many
+// were charged
+func (s *Service) ChargeInterest(now time.Time) (int, error) {
+	accountIDs, err := s.Repo.GetAccrualAccountIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	charged := 0
+	for _, accountID := range accountIDs {
+		amount, err := s.Repo.ChargeTx(accountID, func(accrual *Accrual) *Charge {
+			return accrual.charge(now)
+		})
+		if err != nil {
+			return charged, err
+		}
+		if amount > 0 {
+			charged++
+		}
+	}
+
+	return charged, nil
+}
@@ -0,0 +1,264 @@
+package overdraft
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// MockRepo keeps the accruals of the accounts and applies the charges made to them
+type MockRepo struct {
+	Saved     *Overdraft
+	SaveErr   error
+	Accruals  []*Accrual
+	ChargeErr error
+	Posted    []*ledger.Entry
+}
+
+func (r *MockRepo) Save(overdraft *Overdraft) error {
+	if r.SaveErr != nil {
+		return r.SaveErr
+	}
+	r.Saved = overdraft
+	return nil
+}
+
+func (r *MockRepo) GetAccrualAccountIDs() ([]int64, error) {
+	var ids []int64
+	for _, a := range r.Accruals {
+		ids = append(ids, a.AccountID)
+	}
+	return ids, nil
+}
+
+func (r *MockRepo) ChargeTx(
+	accountID int64, prepare func(accrual *Accrual) *Charge,
+) (money.Amount, error) {
+	if r.ChargeErr != nil {
+		return 0, r.ChargeErr
+	}
+	for _, a := range r.Accruals {
+		if a.AccountID != accountID {
+			continue
+		}
+		charge := prepare(a)
+		if charge.Entry != nil {
+			r.Posted = append(r.Posted, charge.Entry)
+			a.Balance -= charge.Amount
+		}
+		a.InterestFrom = charge.InterestFrom
+		return charge.Amount, nil
+	}
+	return 0, user.ErrNoRecord
+}
+
+type MockAccountService struct {
+	Accounts []*account.Account
+}
+
+func (as *MockAccountService) GetAccountByNumber(number string) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.Number == number {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func TestSetOverdraft(t *testing.T) {
+	accounts := []*account.Account{
+		{ID: 1, UserID: 1, Number: "1000000000", Status: account.StatusActive, Currency: "USD"},
+		{ID: 2, UserID: 1, Number: "1000000001", Status: account.StatusClosed, Currency: "USD"},
+	}
+
+	tests := []struct {
+		name        string
+		number      string
+		limit       money.Amount
+		rate        fx.Decimal
+		saveErr     error
+		expectedErr error
+	}{
+		{
+			name: "valid", number: "1000000000", limit: money.MustParse("500"),
+			rate: fx.MustParseDecimal("0.05"),
+		},
+		{name: "no overdraft", number: "1000000000"},
+		{
+			name: "negative limit", number: "1000000000", limit: money.MustParse("-500"),
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "rate too high", number: "1000000000", limit: money.MustParse("500"),
+			rate:        fx.MustParseDecimal("11"),
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "rate too precise", number: "1000000000", limit: money.MustParse("500"),
+			rate:        fx.MustParseDecimal("0.00001"),
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "closed account", number: "1000000001", limit: money.MustParse("500"),
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "account not found", number: "9999999999", limit: money.MustParse("500"),
+			expectedErr: user.ErrNoRecord,
+		},
+		{
+			name: "Save failure", number: "1000000000", limit: money.MustParse("500"),
+			saveErr: errors.New("db error"), expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{SaveErr: tc.saveErr}
+			svc := Service{Repo: repo, AccountService: &MockAccountService{Accounts: accounts}}
+
+			overdraft, gotErr := svc.SetOverdraft(validator.New(), tc.number, tc.limit, tc.rate)
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if repo.Saved != overdraft || overdraft.AccountID != 1 || overdraft.Limit != tc.limit ||
+				overdraft.DailyInterestRate.Cmp(tc.rate) != 0 {
+				t.Errorf("unexpected overdraft %+v", overdraft)
+			}
+		})
+	}
+}
+
+func TestChargeInterest(t *testing.T) {
+	now := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+
+	tests := []struct {
+		name        string
+		accrual     *Accrual
+		chargeErr   error
+		wantCharged money.Amount
+		wantFrom    *time.Time
+		expectedErr error
+	}{
+		{
+			name: "first seen overdrawn",
+			accrual: &Accrual{
+				Balance: money.MustParse("-100"), DailyInterestRate: fx.MustParseDecimal("0.5"),
+			},
+			wantFrom: &now,
+		},
+		{
+			name: "less than a day",
+			accrual: &Accrual{
+				Balance: money.MustParse("-100"), DailyInterestRate: fx.MustParseDecimal("0.5"),
+				InterestFrom: at(23 * time.Hour),
+			},
+			wantFrom: at(23 * time.Hour),
+		},
+		{
+			name: "one day",
+			accrual: &Accrual{
+				Balance: money.MustParse("-100"), DailyInterestRate: fx.MustParseDecimal("0.5"),
+				InterestFrom: at(25 * time.Hour),
+			},
+			wantCharged: money.MustParse("0.50"),
+			wantFrom:    at(time.Hour),
+		},
+		{
+			name: "missed runs",
+			accrual: &Accrual{
+				Balance: money.MustParse("-100"), DailyInterestRate: fx.MustParseDecimal("0.5"),
+				InterestFrom: at(72 * time.Hour),
+			},
+			wantCharged: money.MustParse("1.50"),
+			wantFrom:    &now,
+		},
+		{
+			name: "paid back",
+			accrual: &Accrual{
+				Balance: money.MustParse("20"), DailyInterestRate: fx.MustParseDecimal("0.5"),
+				InterestFrom: at(72 * time.Hour),
+			},
+		},
+		{
+			name: "no interest",
+			accrual: &Accrual{
+				Balance: money.MustParse("-100"), InterestFrom: at(25 * time.Hour),
+			},
+			wantFrom: at(time.Hour),
+		},
+		{
+			name: "ChargeTx failure",
+			accrual: &Accrual{
+				Balance: money.MustParse("-100"), DailyInterestRate: fx.MustParseDecimal("0.5"),
+				InterestFrom: at(25 * time.Hour),
+			},
+			chargeErr:   errors.New("db error"),
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.accrual.AccountID = 1
+			tc.accrual.Currency = "USD"
+			balance := tc.accrual.Balance
+			repo := &MockRepo{Accruals: []*Accrual{tc.accrual}, ChargeErr: tc.chargeErr}
+			svc := Service{Repo: repo}
+
+			charged, gotErr := svc.ChargeInterest(now)
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			wantCount := 0
+			if tc.wantCharged > 0 {
+				wantCount = 1
+			}
+			if charged != wantCount || balance-tc.accrual.Balance != tc.wantCharged {
+				t.Errorf(
+					"expected %d charged for %v, got %d charged for %v", wantCount, tc.wantCharged,
+					charged, balance-tc.accrual.Balance,
+				)
+			}
+			gotFrom := tc.accrual.InterestFrom
+			if (gotFrom == nil) != (tc.wantFrom == nil) ||
+				gotFrom != nil && !gotFrom.Equal(*tc.wantFrom) {
+				t.Errorf("expected interest from %v, got %v", tc.wantFrom, gotFrom)
+			}
+
+			// interest goes through past the limit, and the entry has to balance
+			for _, entry := range repo.Posted {
+				if !entry.Forced {
+					t.Errorf("expected the interest entry to be forced")
+				}
+				v := validator.New()
+				if ledger.ValidateEntry(v, entry); !v.IsValid() {
+					t.Errorf("unbalanced entry: %v", v.Errors)
+				}
+			}
+		})
+	}
+}
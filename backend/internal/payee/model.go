@@ -0,0 +1,40 @@
+package payee
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// Payee is a recipient the user has saved under a name of their own. RecipientName is the masked
+// name of the user who owns the account, enough for the user to check who they are really paying.
+// FirstPaidAt is nil until the user first pays them
+type Payee struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UserID        int64      `json:"user_id"`
+	Name          string     `json:"name"`
+	AccountID     int64      `json:"-"`
+	AccountNumber string     `json:"account_number"`
+	Currency      string     `json:"currency"`
+	RecipientName string     `json:"recipient_name"`
+	FirstPaidAt   *time.Time `json:"first_paid_at"`
+}
+
+func ValidatePayee(v *validator.Validator, payee *Payee) {
+	v.CheckAddError(payee.Name != "", "name", "must be given")
+	v.CheckAddError(len(payee.Name) <= 100, "name", "must not be more than 100 bytes long")
+}
+
+// MaskName keeps the first letter of each word of the name and hides the rest, "Mohamed Ali" is
+// "M****** A**". saving an account number as a payee must not tell anyone who owns it
+func MaskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+	}
+	return strings.Join(words, " ")
+}
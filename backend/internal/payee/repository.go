@@ -0,0 +1,158 @@
+package payee
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+var (
+	ErrDuplicateAccount = errors.New("duplicate payee account")
+	ErrDuplicateName    = errors.New("duplicate payee name")
+)
+
+// what postgres says when a payee breaks one of the unique constraints, followed by its name
+const duplicateKey = "pq: duplicate key value violates unique constraint "
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// the payees are always read with the number and currency of their account and the name of the user
+// who owns it
+const selectPayees = `
+	SELECT payees.id, payees.created_at, payees.user_id, payees.name, payees.account_id,
+		accounts.number, accounts.currency, users.name, payees.first_paid_at
+	FROM payees
+	INNER JOIN accounts ON accounts.id = payees.account_id
+	INNER JOIN users ON users.id = accounts.user_id
+`
+
+func (r *Repository) Insert(payee *Payee) error {
+	query := `
+		INSERT INTO payees (user_id, name, account_id)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(ctx, query, payee.UserID, payee.Name, payee.AccountID).Scan(
+		&payee.ID,
+		&payee.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case err.Error() == duplicateKey+`"payees_user_id_account_id_key"`:
+			return ErrDuplicateAccount
+		case err.Error() == duplicateKey+`"payees_user_id_name_key"`:
+			return ErrDuplicateName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) Get(payeeID, userID int64) (*Payee, error) {
+	query := selectPayees + `
+		WHERE payees.id = $1 AND payees.user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	payee, err := scanPayee(r.DB.QueryRowContext(ctx, query, payeeID, userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return payee, nil
+}
+
+func (r *Repository) GetAllUserPayees(userID int64) ([]*Payee, error) {
+	query := selectPayees + `
+		WHERE payees.user_id = $1
+		ORDER BY payees.name
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payees []*Payee
+	for rows.Next() {
+		payee, err := scanPayee(rows)
+		if err != nil {
+			return nil, err
+		}
+		payees = append(payees, payee)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payees, nil
+}
+
+func (r *Repository) Delete(payeeID, userID int64) error {
+	query := `
+		DELETE FROM payees
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, payeeID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return user.ErrNoRecord
+	}
+
+	return nil
+}
+
+// scanPayee scans a row of selectPayees, from either a *sql.Row or *sql.Rows
+func scanPayee(row interface{ Scan(dest ...any) error }) (*Payee, error) {
+	payee := &Payee{}
+	err := row.Scan(
+		&payee.ID,
+		&payee.CreatedAt,
+		&payee.UserID,
+		&payee.Name,
+		&payee.AccountID,
+		&payee.AccountNumber,
+		&payee.Currency,
+		&payee.RecipientName,
+		&payee.FirstPaidAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	payee.RecipientName = MaskName(payee.RecipientName)
+
+	return payee, nil
+}
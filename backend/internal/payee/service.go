@@ -0,0 +1,94 @@
+package payee
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type Repo interface {
+	Insert(payee *Payee) error
+	Get(payeeID, userID int64) (*Payee, error)
+	GetAllUserPayees(userID int64) ([]*Payee, error)
+	Delete(payeeID, userID int64) error
+}
+
+// Recipients finds the account money sent to an account number or email goes to, the transfer
+// service does
+type Recipients interface {
+	RecipientAccount(
+		v *validator.Validator, toAccountNumber, toUserEmail string,
+	) (*account.Account, error)
+}
+
+type UserService interface {
+	GetUser(userID int64) (*user.User, error)
+}
+
+type Service struct {
+	Repo        Repo
+	Recipients  Recipients
+	UserService UserService
+}
+
+// Save saves the recipient named by account number or email under the name the user gives it. an
+// email is saved as the primary account of its user at the time, like a standing order
+func (s *Service) Save(
+	v *validator.Validator, userID int64, name, toAccountNumber, toUserEmail string,
+) (*Payee, error) {
+	toAccount, err := s.Recipients.RecipientAccount(v, toAccountNumber, toUserEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := s.UserService.GetUser(toAccount.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	payee := &Payee{
+		UserID:        userID,
+		Name:          strings.TrimSpace(name),
+		AccountID:     toAccount.ID,
+		AccountNumber: toAccount.Number,
+		Currency:      toAccount.Currency,
+		RecipientName: MaskName(recipient.Name),
+	}
+
+	v.CheckAddError(toAccount.CanReceive(), "to account", "is closed")
+	if ValidatePayee(v, payee); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	err = s.Repo.Insert(payee)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrDuplicateAccount):
+			v.AddError("to account", "already saved as a payee")
+			return nil, validator.ErrFailedValidation
+		case errors.Is(err, ErrDuplicateName):
+			v.AddError("name", "already used for another payee")
+			return nil, validator.ErrFailedValidation
+		default:
+			return nil, err
+		}
+	}
+
+	return payee, nil
+}
+
+func (s *Service) GetPayee(payeeID, userID int64) (*Payee, error) {
+	return s.Repo.Get(payeeID, userID)
+}
+
+func (s *Service) GetAllUserPayees(userID int64) ([]*Payee, error) {
+	return s.Repo.GetAllUserPayees(userID)
+}
+
+// Delete deletes one of the user's payees, the transfers made to it are kept
+func (s *Service) Delete(payeeID, userID int64) error {
+	return s.Repo.Delete(payeeID, userID)
+}
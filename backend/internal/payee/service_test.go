@@ -0,0 +1,254 @@
+package payee
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// MockRepo keeps the payees in memory, refusing duplicates like the unique constraints do
+type MockRepo struct {
+	Payees    []*Payee
+	InsertErr error
+}
+
+func (r *MockRepo) Insert(payee *Payee) error {
+	if r.InsertErr != nil {
+		return r.InsertErr
+	}
+	for _, p := range r.Payees {
+		if p.UserID != payee.UserID {
+			continue
+		}
+		if p.AccountID == payee.AccountID {
+			return ErrDuplicateAccount
+		}
+		if p.Name == payee.Name {
+			return ErrDuplicateName
+		}
+	}
+	payee.ID = int64(len(r.Payees) + 1)
+	r.Payees = append(r.Payees, payee)
+	return nil
+}
+
+func (r *MockRepo) Get(payeeID, userID int64) (*Payee, error) {
+	for _, p := range r.Payees {
+		if p.ID == payeeID && p.UserID == userID {
+			return p, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (r *MockRepo) GetAllUserPayees(userID int64) ([]*Payee, error) {
+	var payees []*Payee
+	for _, p := range r.Payees {
+		if p.UserID == userID {
+			payees = append(payees, p)
+		}
+	}
+	return payees, nil
+}
+
+func (r *MockRepo) Delete(payeeID, userID int64) error {
+	for i, p := range r.Payees {
+		if p.ID == payeeID && p.UserID == userID {
+			r.Payees = append(r.Payees[:i], r.Payees[i+1:]...)
+			return nil
+		}
+	}
+	return user.ErrNoRecord
+}
+
+// MockRecipients finds the accounts it holds by number, or the first account of the user with the
+// email
+type MockRecipients struct {
+	Accounts []*account.Account
+	Users    []*user.User
+}
+
+func (m *MockRecipients) RecipientAccount(
+	v *validator.Validator, toAccountNumber, toUserEmail string,
+) (*account.Account, error) {
+	for _, a := range m.Accounts {
+		if toAccountNumber != "" && a.Number == toAccountNumber {
+			return a, nil
+		}
+		for _, u := range m.Users {
+			if toAccountNumber == "" && u.Email == toUserEmail && u.ID == a.UserID {
+				return a, nil
+			}
+		}
+	}
+	v.AddError("to account", "not found")
+	return nil, validator.ErrFailedValidation
+}
+
+func (m *MockRecipients) GetUser(userID int64) (*user.User, error) {
+	for _, u := range m.Users {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func TestSave(t *testing.T) {
+	errDB := errors.New("db error")
+
+	type input struct {
+		name            string
+		toAccountNumber string
+		toUserEmail     string
+	}
+	tests := []struct {
+		name          string
+		existing      []*Payee
+		insertErr     error
+		input         input
+		wantName      string
+		wantAccountID int64
+		wantRecipient string
+		expectedErr   error
+		wantErrKey    string
+	}{
+		{
+			name:          "by account number",
+			input:         input{name: "rent", toAccountNumber: "1000000001"},
+			wantName:      "rent",
+			wantAccountID: 2,
+			wantRecipient: "m******",
+		},
+		{
+			name:          "by email",
+			input:         input{name: "  mohamed  ", toUserEmail: "m@gmail.com"},
+			wantName:      "mohamed",
+			wantAccountID: 2,
+			wantRecipient: "m******",
+		},
+		{
+			name:        "no name",
+			input:       input{toAccountNumber: "1000000001"},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "name",
+		},
+		{
+			name:        "recipient not found",
+			input:       input{name: "rent", toUserEmail: "nobody@gmail.com"},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "to account",
+		},
+		{
+			name:        "closed account",
+			input:       input{name: "old", toAccountNumber: "1000000002"},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "to account",
+		},
+		{
+			name:        "account already saved",
+			existing:    []*Payee{{ID: 1, UserID: 1, Name: "rent", AccountID: 2}},
+			input:       input{name: "landlord", toAccountNumber: "1000000001"},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "to account",
+		},
+		{
+			name:        "name already used",
+			existing:    []*Payee{{ID: 1, UserID: 1, Name: "rent", AccountID: 3}},
+			input:       input{name: "rent", toAccountNumber: "1000000001"},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "name",
+		},
+		{
+			name:          "another user's payee does not clash",
+			existing:      []*Payee{{ID: 1, UserID: 3, Name: "rent", AccountID: 2}},
+			input:         input{name: "rent", toAccountNumber: "1000000001"},
+			wantName:      "rent",
+			wantAccountID: 2,
+			wantRecipient: "m******",
+		},
+		{
+			name:        "Insert failure",
+			insertErr:   errDB,
+			input:       input{name: "rent", toAccountNumber: "1000000001"},
+			expectedErr: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recipients := &MockRecipients{
+				Accounts: []*account.Account{
+					{ID: 2, UserID: 2, Number: "1000000001", Status: account.StatusActive},
+					{ID: 3, UserID: 2, Number: "1000000002", Status: account.StatusClosed},
+				},
+				Users: []*user.User{{ID: 2, Name: "mohamed", Email: "m@gmail.com"}},
+			}
+			repo := &MockRepo{Payees: tc.existing, InsertErr: tc.insertErr}
+			svc := Service{Repo: repo, Recipients: recipients, UserService: recipients}
+
+			v := validator.New()
+			got, gotErr := svc.Save(
+				v, 1, tc.input.name, tc.input.toAccountNumber, tc.input.toUserEmail,
+			)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if tc.wantErrKey != "" {
+				if _, ok := v.Errors[tc.wantErrKey]; !ok {
+					t.Errorf("expected an error on %q, got %v", tc.wantErrKey, v.Errors)
+				}
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if got.AccountID != tc.wantAccountID || got.RecipientName != tc.wantRecipient {
+				t.Errorf(
+					"expected account %d of %s, got account %d of %s", tc.wantAccountID,
+					tc.wantRecipient, got.AccountID, got.RecipientName,
+				)
+			}
+			if got.Name != tc.wantName {
+				t.Errorf("expected name %q, got %q", tc.wantName, got.Name)
+			}
+			if got.FirstPaidAt != nil {
+				t.Errorf("expected a new payee to be unpaid, got first paid at %v", got.FirstPaidAt)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	repo := &MockRepo{Payees: []*Payee{{ID: 1, UserID: 1, Name: "rent", AccountID: 2}}}
+	svc := Service{Repo: repo}
+
+	if err := svc.Delete(1, 2); !errors.Is(err, user.ErrNoRecord) {
+		t.Fatalf("expected error %v deleting another user's payee, got %v", user.ErrNoRecord, err)
+	}
+	if err := svc.Delete(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if payees, _ := svc.GetAllUserPayees(1); len(payees) != 0 {
+		t.Errorf("expected no payees left, got %d", len(payees))
+	}
+}
+
+func TestMaskName(t *testing.T) {
+	tests := map[string]string{
+		"mohamed":          "m******",
+		"Mohamed Ali":      "M****** A**",
+		"  yusuf   omar  ": "y**** o***",
+		"Zoë":              "Z**",
+		"":                 "",
+	}
+
+	for name, want := range tests {
+		if got := MaskName(name); got != want {
+			t.Errorf("MaskName(%q): expected %q, got %q", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package paymentrequest
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+const (
+	StatusPending  = "PENDING"
+	StatusPaid     = "PAID"
+	StatusDeclined = "DECLINED"
+	StatusExpired  = "EXPIRED"
+)
+
+const (
+	// how long a request stays open when no expiry is given, and the longest it can
+	defaultExpiry = 7 * 24 * time.Hour
+	maxExpiry     = 30 * 24 * time.Hour
+	maxNoteLength = 200
+)
+
+// PaymentRequest is a user asking another user, the payer, for an amount with a note. the money is
+// paid into ToAccountID in its currency. TransferID is the transfer that paid the request, only set
+// once it is paid
+type PaymentRequest struct {
+	ID              int64        `json:"id"`
+	CreatedAt       time.Time    `json:"created_at"`
+	RequesterID     int64        `json:"requester_id"`
+	RequesterName   string       `json:"requester_name"`
+	ToAccountID     int64        `json:"-"`
+	ToAccountNumber string       `json:"to_account"`
+	PayerID         int64        `json:"payer_id"`
+	PayerName       string       `json:"payer_name"`
+	Amount          money.Amount `json:"amount"`
+	Currency        string       `json:"currency"`
+	Note            string       `json:"note"`
+	Status          string       `json:"status"`
+	ExpiresAt       time.Time    `json:"expires_at"`
+	RespondedAt     *time.Time   `json:"responded_at"`
+	TransferID      *int64       `json:"transfer_id"`
+	Version         int32        `json:"version"`
+}
+
+// Expired is whether the request is still waiting on the payer but has run out of time, before the
+// scheduler has got round to marking it expired
+func (r *PaymentRequest) Expired(now time.Time) bool {
+	return r.Status == StatusPending && !now.Before(r.ExpiresAt)
+}
+
+func ValidatePaymentRequest(v *validator.Validator, request *PaymentRequest, now time.Time) {
+	v.CheckAddError(request.Amount != 0, "amount", "must be given")
+	v.CheckAddError(request.Amount > 0, "amount", "must be greater than 0")
+	v.CheckAddError(request.PayerID != request.RequesterID, "payer", "cannot be yourself")
+	v.CheckAddError(
+		len(request.Note) <= maxNoteLength, "note", "must not be more than 200 bytes long",
+	)
+	v.CheckAddError(request.ExpiresAt.After(now), "expires at", "must be in the future")
+	v.CheckAddError(
+		!request.ExpiresAt.After(now.Add(maxExpiry)), "expires at",
+		"must not be more than 30 days away",
+	)
+}
@@ -0,0 +1,204 @@
+package paymentrequest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+var ErrEditConflict = errors.New("edit conflict")
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// the requests are always read with the names of both users, the number and currency of the
+// account the money goes to and the transfer that paid them
+const selectRequests = `
+	SELECT payment_requests.id, payment_requests.created_at, payment_requests.requester_id,
+		requesters.name, payment_requests.to_account_id, accounts.number, accounts.currency,
+		payment_requests.payer_id, payers.name, payment_requests.amount, payment_requests.note,
+		payment_requests.status, payment_requests.expires_at, payment_requests.responded_at,
+		transfers.id, payment_requests.version
+	FROM payment_requests
+	INNER JOIN users requesters ON requesters.id = payment_requests.requester_id
+	INNER JOIN users payers ON payers.id = payment_requests.payer_id
+	INNER JOIN accounts ON accounts.id = payment_requests.to_account_id
+	LEFT JOIN transfers ON transfers.payment_request_id = payment_requests.id
+`
+
+func (r *Repository) Insert(request *PaymentRequest) error {
+	query := `
+		INSERT INTO payment_requests
+			(requester_id, to_account_id, payer_id, amount, note, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, version
+	`
+	args := []any{
+		request.RequesterID,
+		request.ToAccountID,
+		request.PayerID,
+		request.Amount,
+		request.Note,
+		request.Status,
+		request.ExpiresAt,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.DB.QueryRowContext(ctx, query, args...).Scan(
+		&request.ID,
+		&request.CreatedAt,
+		&request.Version,
+	)
+}
+
+func (r *Repository) Get(requestID int64) (*PaymentRequest, error) {
+	query := selectRequests + `
+		WHERE payment_requests.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	request, err := scanRequest(r.DB.QueryRowContext(ctx, query, requestID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return request, nil
+}
+
+// GetIncoming gets the requests the user has been asked to pay, the newest first
+func (r *Repository) GetIncoming(userID int64) ([]*PaymentRequest, error) {
+	query := selectRequests + `
+		WHERE payment_requests.payer_id = $1
+		ORDER BY payment_requests.id DESC
+	`
+
+	return r.query(query, userID)
+}
+
+// GetOutgoing gets the requests the user has sent, the newest first
+func (r *Repository) GetOutgoing(userID int64) ([]*PaymentRequest, error) {
+	query := selectRequests + `
+		WHERE payment_requests.requester_id = $1
+		ORDER BY payment_requests.id DESC
+	`
+
+	return r.query(query, userID)
+}
+
+// Update saves the status of the request, as long as no one else has changed it since it was read
+func (r *Repository) Update(request *PaymentRequest) error {
+	query := `
+		UPDATE payment_requests
+		SET status = $1, responded_at = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(
+		ctx, query, request.Status, request.RespondedAt, request.ID, request.Version,
+	).Scan(&request.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExpireDue marks every pending request that ran out of time by the given time expired, and
+// returns how many there were
+func (r *Repository) ExpireDue(at time.Time) (int, error) {
+	query := `
+		UPDATE payment_requests
+		SET status = 'EXPIRED', version = version + 1
+		WHERE status = 'PENDING' AND expires_at <= $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, at)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
+
+func (r *Repository) query(query string, args ...any) ([]*PaymentRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*PaymentRequest
+	for rows.Next() {
+		request, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// scanRequest scans a row of selectRequests, from either a *sql.Row or *sql.Rows
+func scanRequest(row interface{ Scan(dest ...any) error }) (*PaymentRequest, error) {
+	request := &PaymentRequest{}
+	err := row.Scan(
+		&request.ID,
+		&request.CreatedAt,
+		&request.RequesterID,
+		&request.RequesterName,
+		&request.ToAccountID,
+		&request.ToAccountNumber,
+		&request.Currency,
+		&request.PayerID,
+		&request.PayerName,
+		&request.Amount,
+		&request.Note,
+		&request.Status,
+		&request.ExpiresAt,
+		&request.RespondedAt,
+		&request.TransferID,
+		&request.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
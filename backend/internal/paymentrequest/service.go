@@ -0,0 +1,242 @@
+package paymentrequest
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type Repo interface {
+	Insert(request *PaymentRequest) error
+	Get(requestID int64) (*PaymentRequest, error)
+	GetIncoming(userID int64) ([]*PaymentRequest, error)
+	GetOutgoing(userID int64) ([]*PaymentRequest, error)
+	Update(request *PaymentRequest) error
+	ExpireDue(at time.Time) (int, error)
+}
+
+type UserService interface {
+	GetUserByEmail(email string) (*user.User, error)
+}
+
+type AccountService interface {
+	GetAccountByNumber(number string) (*account.Account, error)
+	GetUserAccount(userID int64, number string) (*account.Account, error)
+}
+
+type TransferService interface {
+	PayRequest(
+		v *validator.Validator, fromUser *user.User, fromAccountNumber string, requestID,
+		toAccountID int64, amount money.Amount, memo string,
+	) (*transfer.Transfer, *user.User, error)
+}
+
+type Service struct {
+	Repo            Repo
+	UserService     UserService
+	AccountService  AccountService
+	TransferService TransferService
+}
+
+// New asks the payer, named by email or by the number of one of their accounts, for the amount. it
+// is to be paid into one of the requester's accounts, their primary account when no number is
+// given, in the currency of that account. a request without an expiry is open for a week
+func (s *Service) New(
+	v *validator.Validator, requester *user.User, toAccountNumber, payerEmail,
+	payerAccountNumber string, amount money.Amount, note string, expiresAt time.Time,
+) (*PaymentRequest, error) {
+	toAccount, err := s.AccountService.GetUserAccount(requester.ID, toAccountNumber)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("to account", "not found")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	payerID, err := s.payer(v, payerEmail, payerAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(defaultExpiry)
+	}
+
+	request := &PaymentRequest{
+		RequesterID: requester.ID,
+		ToAccountID: toAccount.ID,
+		PayerID:     payerID,
+		Amount:      amount,
+		Currency:    toAccount.Currency,
+		Note:        strings.TrimSpace(note),
+		Status:      StatusPending,
+		ExpiresAt:   expiresAt.UTC(),
+	}
+
+	v.CheckAddError(toAccount.CanReceive(), "to account", "is closed")
+	if ValidatePaymentRequest(v, request, now); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	err = s.Repo.Insert(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// read back with the names and account number filled in
+	return s.Repo.Get(request.ID)
+}
+
+// payer finds the user being asked for money, by the number of one of their accounts if one is
+// given or else by their email
+func (s *Service) payer(v *validator.Validator, email, accountNumber string) (int64, error) {
+	if accountNumber != "" {
+		if !account.ValidNumber(accountNumber) {
+			v.AddError("payer account", "invalid account number")
+			return 0, validator.ErrFailedValidation
+		}
+
+		a, err := s.AccountService.GetAccountByNumber(accountNumber)
+		if err != nil {
+			if errors.Is(err, user.ErrNoRecord) {
+				v.AddError("payer account", "not found")
+				return 0, validator.ErrFailedValidation
+			}
+			return 0, err
+		}
+		return a.UserID, nil
+	}
+
+	if email == "" {
+		v.AddError("payer", "must be given")
+		return 0, validator.ErrFailedValidation
+	}
+
+	u, err := s.UserService.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("payer email", "email not found")
+			return 0, validator.ErrFailedValidation
+		}
+		return 0, err
+	}
+	return u.ID, nil
+}
+
+// Pay pays a request the user was sent, from one of their accounts or their primary account when
+// no number is given. the account has to be in the currency of the request, and the payment is a
+// normal transfer, limits and all
+func (s *Service) Pay(
+	v *validator.Validator, payer *user.User, requestID int64, fromAccountNumber string,
+) (*PaymentRequest, *transfer.Transfer, error) {
+	request, err := s.getIncoming(requestID, payer.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fromAccount, err := s.AccountService.GetUserAccount(payer.ID, fromAccountNumber)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("from account", "not found")
+			return nil, nil, validator.ErrFailedValidation
+		}
+		return nil, nil, err
+	}
+
+	checkPending(v, request, time.Now())
+	v.CheckAddError(
+		fromAccount.Currency == request.Currency, "from account", "must be in "+request.Currency,
+	)
+	if !v.IsValid() {
+		return nil, nil, validator.ErrFailedValidation
+	}
+
+	tr, _, err := s.TransferService.PayRequest(
+		v, payer, fromAccount.Number, request.ID, request.ToAccountID, request.Amount,
+		request.Note,
+	)
+	if err != nil {
+		// declined or expired since it was read
+		if errors.Is(err, transfer.ErrRequestNotPending) {
+			v.AddError("payment request", "is no longer pending")
+			return nil, nil, validator.ErrFailedValidation
+		}
+		return nil, nil, err
+	}
+
+	request, err = s.Repo.Get(request.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return request, tr, nil
+}
+
+func (s *Service) Decline(
+	v *validator.Validator, requestID, payerID int64,
+) (*PaymentRequest, error) {
+	request, err := s.getIncoming(requestID, payerID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if checkPending(v, request, now); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	request.Status = StatusDeclined
+	request.RespondedAt = &now
+	err = s.Repo.Update(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// ExpireDue expires the pending requests that have run out of time, and returns how many there were
+func (s *Service) ExpireDue(now time.Time) (int, error) {
+	return s.Repo.ExpireDue(now)
+}
+
+func (s *Service) GetIncoming(userID int64) ([]*PaymentRequest, error) {
+	return s.Repo.GetIncoming(userID)
+}
+
+func (s *Service) GetOutgoing(userID int64) ([]*PaymentRequest, error) {
+	return s.Repo.GetOutgoing(userID)
+}
+
+// getIncoming gets a request the user was sent, the ones they sent or that were sent to others are
+// not found
+func (s *Service) getIncoming(requestID, payerID int64) (*PaymentRequest, error) {
+	request, err := s.Repo.Get(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.PayerID != payerID {
+		return nil, user.ErrNoRecord
+	}
+	return request, nil
+}
+
+// checkPending adds an error unless the request is still waiting on the payer
+func checkPending(v *validator.Validator, request *PaymentRequest, now time.Time) {
+	if request.Expired(now) {
+		v.AddError("payment request", "has expired")
+		return
+	}
+	v.CheckAddError(
+		request.Status == StatusPending, "payment request",
+		"is already "+strings.ToLower(request.Status),
+	)
+}
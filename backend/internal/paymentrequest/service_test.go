@@ -0,0 +1,460 @@
+package paymentrequest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// ---MOCKS---
+
+// MockRepo keeps the requests in memory, filling in the currency and transfer the way the joins do
+type MockRepo struct {
+	Requests  []*PaymentRequest
+	Accounts  []*account.Account
+	InsertErr error
+	UpdateErr error
+}
+
+func (r *MockRepo) Insert(request *PaymentRequest) error {
+	if r.InsertErr != nil {
+		return r.InsertErr
+	}
+	request.ID = int64(len(r.Requests) + 1)
+	request.Version = 1
+	r.Requests = append(r.Requests, request)
+	return nil
+}
+
+func (r *MockRepo) Get(requestID int64) (*PaymentRequest, error) {
+	for _, request := range r.Requests {
+		if request.ID == requestID {
+			copied := *request
+			return &copied, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (r *MockRepo) GetIncoming(userID int64) ([]*PaymentRequest, error) {
+	return nil, nil
+}
+
+func (r *MockRepo) GetOutgoing(userID int64) ([]*PaymentRequest, error) {
+	return nil, nil
+}
+
+func (r *MockRepo) Update(request *PaymentRequest) error {
+	if r.UpdateErr != nil {
+		return r.UpdateErr
+	}
+	for i, saved := range r.Requests {
+		if saved.ID == request.ID {
+			if saved.Version != request.Version {
+				return ErrEditConflict
+			}
+			request.Version++
+			copied := *request
+			r.Requests[i] = &copied
+			return nil
+		}
+	}
+	return ErrEditConflict
+}
+
+func (r *MockRepo) ExpireDue(at time.Time) (int, error) {
+	expired := 0
+	for _, request := range r.Requests {
+		if request.Expired(at) {
+			request.Status = StatusExpired
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+type MockUserService struct {
+	Users []*user.User
+}
+
+func (us *MockUserService) GetUserByEmail(email string) (*user.User, error) {
+	for _, u := range us.Users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+// MockAccountService looks the accounts it holds up by number or by their user, the first account
+// of a user being their primary account
+type MockAccountService struct {
+	Accounts []*account.Account
+}
+
+func (as *MockAccountService) GetAccountByNumber(number string) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.Number == number {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (as *MockAccountService) GetUserAccount(userID int64, number string) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.UserID == userID && (number == "" || a.Number == number) {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+// MockTransferService pays the request in the repo like the real transfer does, or fails with Err
+type MockTransferService struct {
+	Repo *MockRepo
+	Err  error
+	Paid []*transfer.Transfer
+}
+
+func (ts *MockTransferService) PayRequest(
+	v *validator.Validator, fromUser *user.User, fromAccountNumber string, requestID,
+	toAccountID int64, amount money.Amount, memo string,
+) (*transfer.Transfer, *user.User, error) {
+	if ts.Err != nil {
+		return nil, nil, ts.Err
+	}
+	tr := &transfer.Transfer{
+		ID: int64(len(ts.Paid) + 1), FromUserID: fromUser.ID, ToAccountID: toAccountID,
+		Amount: amount, PaymentRequestID: &requestID,
+	}
+	ts.Paid = append(ts.Paid, tr)
+	for _, request := range ts.Repo.Requests {
+		if request.ID == requestID {
+			request.Status = StatusPaid
+			request.TransferID = &tr.ID
+		}
+	}
+	return tr, fromUser, nil
+}
+
+var (
+	requester = &user.User{ID: 1, Name: "yusuf", Email: "y@gmail.com"}
+	payer     = &user.User{ID: 2, Name: "mohamed", Email: "m@gmail.com"}
+)
+
+func newAccounts() []*account.Account {
+	return []*account.Account{
+		{
+			ID: 1, UserID: 1, Number: "100000000093", Status: account.StatusActive,
+			Currency: "USD",
+		},
+		{
+			ID: 2, UserID: 2, Number: "100000000190", Status: account.StatusActive,
+			Currency: "USD",
+		},
+		{
+			ID: 3, UserID: 2, Number: "100000000287", Status: account.StatusActive,
+			Currency: "EUR",
+		},
+	}
+}
+
+func newService(repo *MockRepo) (Service, *MockTransferService) {
+	transfers := &MockTransferService{Repo: repo}
+	return Service{
+		Repo:            repo,
+		UserService:     &MockUserService{Users: []*user.User{requester, payer}},
+		AccountService:  &MockAccountService{Accounts: newAccounts()},
+		TransferService: transfers,
+	}, transfers
+}
+
+func TestNew(t *testing.T) {
+	errDB := errors.New("db error")
+	now := time.Now()
+
+	type input struct {
+		toAccountNumber    string
+		payerEmail         string
+		payerAccountNumber string
+		amount             money.Amount
+		note               string
+		expiresAt          time.Time
+	}
+	tests := []struct {
+		name        string
+		insertErr   error
+		input       input
+		wantPayerID int64
+		wantNote    string
+		wantExpiry  time.Duration
+		expectedErr error
+		wantErrKey  string
+	}{
+		{
+			name: "by email",
+			input: input{
+				payerEmail: payer.Email, amount: money.MustParse("10"), note: " dinner ",
+			},
+			wantPayerID: 2,
+			wantNote:    "dinner",
+			wantExpiry:  defaultExpiry,
+		},
+		{
+			name: "by account number",
+			input: input{
+				payerAccountNumber: "100000000287", amount: money.MustParse("10"),
+				expiresAt: now.Add(48 * time.Hour),
+			},
+			wantPayerID: 2,
+			wantExpiry:  48 * time.Hour,
+		},
+		{
+			name: "bad check digits",
+			input: input{
+				payerAccountNumber: "100000000209", amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "payer account",
+		},
+		{
+			name:        "no payer",
+			input:       input{amount: money.MustParse("10")},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "payer",
+		},
+		{
+			name:        "payer not found",
+			input:       input{payerEmail: "nobody@gmail.com", amount: money.MustParse("10")},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "payer email",
+		},
+		{
+			name:        "asking yourself",
+			input:       input{payerEmail: requester.Email, amount: money.MustParse("10")},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "payer",
+		},
+		{
+			name:        "to someone else's account",
+			input:       input{toAccountNumber: "100000000190", payerEmail: payer.Email},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "to account",
+		},
+		{
+			name:        "no amount",
+			input:       input{payerEmail: payer.Email},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "amount",
+		},
+		{
+			name: "expiry too far away",
+			input: input{
+				payerEmail: payer.Email, amount: money.MustParse("10"),
+				expiresAt: now.Add(31 * 24 * time.Hour),
+			},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "expires at",
+		},
+		{
+			name:        "Insert failure",
+			insertErr:   errDB,
+			input:       input{payerEmail: payer.Email, amount: money.MustParse("10")},
+			expectedErr: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{InsertErr: tc.insertErr}
+			svc, _ := newService(repo)
+
+			v := validator.New()
+			got, gotErr := svc.New(
+				v, requester, tc.input.toAccountNumber, tc.input.payerEmail,
+				tc.input.payerAccountNumber, tc.input.amount, tc.input.note, tc.input.expiresAt,
+			)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if tc.wantErrKey != "" {
+				if _, ok := v.Errors[tc.wantErrKey]; !ok {
+					t.Errorf("expected an error on %q, got %v", tc.wantErrKey, v.Errors)
+				}
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if got.PayerID != tc.wantPayerID || got.ToAccountID != 1 || got.Currency != "USD" {
+				t.Errorf(
+					"expected payer %d paying account 1 in USD, got payer %d, account %d in %s",
+					tc.wantPayerID, got.PayerID, got.ToAccountID, got.Currency,
+				)
+			}
+			if got.Status != StatusPending || got.Note != tc.wantNote {
+				t.Errorf("expected a pending request, got %s with note %q", got.Status, got.Note)
+			}
+			if expiry := got.ExpiresAt.Sub(now); expiry < tc.wantExpiry-time.Minute ||
+				expiry > tc.wantExpiry+time.Minute {
+				t.Errorf("expected it to expire in %v, got %v", tc.wantExpiry, expiry)
+			}
+		})
+	}
+}
+
+func TestPay(t *testing.T) {
+	errDB := errors.New("db error")
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name              string
+		request           PaymentRequest
+		fromAccountNumber string
+		transferErr       error
+		wantPaid          bool
+		expectedErr       error
+		wantErrKey        string
+	}{
+		{
+			name:     "pending",
+			request:  PaymentRequest{PayerID: 2, Status: StatusPending, ExpiresAt: future},
+			wantPaid: true,
+		},
+		{
+			name:        "not the payer",
+			request:     PaymentRequest{PayerID: 3, Status: StatusPending, ExpiresAt: future},
+			expectedErr: user.ErrNoRecord,
+		},
+		{
+			name:        "already declined",
+			request:     PaymentRequest{PayerID: 2, Status: StatusDeclined, ExpiresAt: future},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "payment request",
+		},
+		{
+			name: "expired but not marked yet",
+			request: PaymentRequest{
+				PayerID: 2, Status: StatusPending, ExpiresAt: time.Now().Add(-time.Minute),
+			},
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "payment request",
+		},
+		{
+			name:              "from an account in another currency",
+			request:           PaymentRequest{PayerID: 2, Status: StatusPending, ExpiresAt: future},
+			fromAccountNumber: "100000000287",
+			expectedErr:       validator.ErrFailedValidation,
+			wantErrKey:        "from account",
+		},
+		{
+			name:        "declined while paying",
+			request:     PaymentRequest{PayerID: 2, Status: StatusPending, ExpiresAt: future},
+			transferErr: transfer.ErrRequestNotPending,
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "payment request",
+		},
+		{
+			name:        "transfer failure",
+			request:     PaymentRequest{PayerID: 2, Status: StatusPending, ExpiresAt: future},
+			transferErr: errDB,
+			expectedErr: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			request := tc.request
+			request.ID, request.RequesterID, request.ToAccountID = 1, 1, 1
+			request.Amount, request.Currency = money.MustParse("10"), "USD"
+			repo := &MockRepo{Requests: []*PaymentRequest{&request}}
+			svc, transfers := newService(repo)
+			transfers.Err = tc.transferErr
+
+			v := validator.New()
+			got, tr, gotErr := svc.Pay(v, payer, 1, tc.fromAccountNumber)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if tc.wantErrKey != "" {
+				if _, ok := v.Errors[tc.wantErrKey]; !ok {
+					t.Errorf("expected an error on %q, got %v", tc.wantErrKey, v.Errors)
+				}
+			}
+			if gotPaid := len(transfers.Paid) == 1; gotPaid != tc.wantPaid {
+				t.Fatalf("expected paid=%v, got %v", tc.wantPaid, gotPaid)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if got.Status != StatusPaid || got.TransferID == nil || *got.TransferID != tr.ID {
+				t.Errorf("expected the request paid by transfer %d, got %+v", tr.ID, got)
+			}
+			if tr.Amount != request.Amount || tr.ToAccountID != request.ToAccountID {
+				t.Errorf(
+					"expected %v paid into account %d, got %v into %d", request.Amount,
+					request.ToAccountID, tr.Amount, tr.ToAccountID,
+				)
+			}
+		})
+	}
+}
+
+func TestDecline(t *testing.T) {
+	repo := &MockRepo{Requests: []*PaymentRequest{
+		{
+			ID: 1, RequesterID: 1, PayerID: 2, Status: StatusPending,
+			ExpiresAt: time.Now().Add(time.Hour),
+		},
+	}}
+	svc, _ := newService(repo)
+
+	if _, err := svc.Decline(validator.New(), 1, 1); !errors.Is(err, user.ErrNoRecord) {
+		t.Fatalf("expected error %v declining your own request, got %v", user.ErrNoRecord, err)
+	}
+
+	got, err := svc.Decline(validator.New(), 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusDeclined || got.RespondedAt == nil {
+		t.Errorf("expected the request declined, got %+v", got)
+	}
+
+	v := validator.New()
+	if _, err := svc.Decline(v, 1, 2); !errors.Is(err, validator.ErrFailedValidation) {
+		t.Errorf("expected error %v declining twice, got %v", validator.ErrFailedValidation, err)
+	}
+}
+
+func TestExpireDue(t *testing.T) {
+	now := time.Now()
+	repo := &MockRepo{Requests: []*PaymentRequest{
+		{ID: 1, Status: StatusPending, ExpiresAt: now.Add(-time.Hour)},
+		{ID: 2, Status: StatusPending, ExpiresAt: now.Add(time.Hour)},
+		{ID: 3, Status: StatusPaid, ExpiresAt: now.Add(-time.Hour)},
+	}}
+	svc, _ := newService(repo)
+
+	expired, err := svc.ExpireDue(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 || repo.Requests[0].Status != StatusExpired {
+		t.Errorf("expected only the first request expired, got %d expired", expired)
+	}
+	if repo.Requests[1].Status != StatusPending || repo.Requests[2].Status != StatusPaid {
+		t.Errorf(
+			"expected the others left alone, got %s and %s", repo.Requests[1].Status,
+			repo.Requests[2].Status,
+		)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/event"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/lib/pq"
 )
@@ -95,18 +96,47 @@ func (r *Repository) Delete(code ...string) error {
 	return nil
 }
 
+// Grant gives the user the permissions with the codes, and records the ones they didn't already
+// have as an event
 func (r *Repository) Grant(userID int64, code ...string) error {
 	query := `
-		INSERT INTO users_permissions
-		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
-		ON CONFLICT DO NOTHING
+		WITH granted AS (
+			INSERT INTO users_permissions
+			SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+			ON CONFLICT DO NOTHING
+			RETURNING permission_id
+		)
+		SELECT COALESCE(array_agg(permissions.code), '{}')
+		FROM permissions
+		INNER JOIN granted ON granted.permission_id = permissions.id
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := r.DB.ExecContext(ctx, query, userID, pq.Array(code))
-	return err
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var granted []string
+	err = tx.QueryRowContext(ctx, query, userID, pq.Array(code)).Scan(pq.Array(&granted))
+	if err != nil {
+		return err
+	}
+
+	if len(granted) > 0 {
+		e, err := event.New(userID, event.PermissionsGranted{Codes: granted})
+		if err != nil {
+			return err
+		}
+		if err = event.RecordInTx(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (r *Repository) Revoke(userID int64, code ...string) error {
@@ -0,0 +1,57 @@
+package reconcile
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+)
+
+// Run is one reconciliation of every account
+type Run struct {
+	ID              int64          `json:"id"`
+	StartedAt       time.Time      `json:"started_at"`
+	FinishedAt      *time.Time     `json:"finished_at"`
+	TriggeredBy     string         `json:"triggered_by"`
+	AccountsChecked int            `json:"accounts_checked"`
+	Discrepancies   []*Discrepancy `json:"discrepancies"`
+}
+
+// AccountBalances is an account's balance three ways. the expected balance is worked out from the
+// records of the money movements, deposits and withdrawals, transfers in and out, accepted loans,
+// loan payments and captured holds, the ledger balance is the sum of its postings and the account
+// balance is the balance cached on the account
+type AccountBalances struct {
+	AccountID       int64        `json:"account_id"`
+	UserID          int64        `json:"user_id"`
+	Currency        string       `json:"currency"`
+	ExpectedBalance money.Amount `json:"expected_balance"`
+	LedgerBalance   money.Amount `json:"ledger_balance"`
+	AccountBalance  money.Amount `json:"account_balance"`
+}
+
+// Discrepancy is an account whose balances don't all agree
+type Discrepancy struct {
+	ID    int64 `json:"id"`
+	RunID int64 `json:"run_id"`
+	AccountBalances
+}
+
+// Matches reports whether all three balances agree
+func (b *AccountBalances) Matches() bool {
+	return b.ExpectedBalance == b.LedgerBalance && b.LedgerBalance == b.AccountBalance
+}
+
+// Summary is the run in the form it is logged
+func (r *Run) Summary() map[string]string {
+	summary := map[string]string{
+		"run_id":           strconv.FormatInt(r.ID, 10),
+		"triggered_by":     r.TriggeredBy,
+		"accounts_checked": strconv.Itoa(r.AccountsChecked),
+		"discrepancies":    strconv.Itoa(len(r.Discrepancies)),
+	}
+	if r.FinishedAt != nil {
+		summary["duration"] = r.FinishedAt.Sub(r.StartedAt).String()
+	}
+	return summary
+}
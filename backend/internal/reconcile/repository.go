@@ -0,0 +1,210 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// GetAccountBalances works out the balances of every account. the opening balances carried over
+// into the ledger when it was added have no other record, so they are counted from the ledger. a
+// movement that is being made while this runs can show up in one balance and not yet another
+func (r *Repository) GetAccountBalances() ([]*AccountBalances, error) {
+	query := `
+		WITH movements AS (
+			SELECT account_id,
+				CASE WHEN action IN ('DEPOSIT', 'INTEREST') THEN amount ELSE -amount END AS amount
+			FROM transactions
+			UNION ALL
+			SELECT from_account_id, -amount FROM transfers
+			UNION ALL
+			SELECT to_account_id, to_amount FROM transfers
+			UNION ALL
+			SELECT account_id, amount FROM loan_requests WHERE status = 'ACCEPTED'
+			UNION ALL
+			SELECT account_id, -amount FROM loans WHERE action = 'paid'
+			UNION ALL
+			SELECT account_id, -captured_amount FROM holds WHERE status = 'CAPTURED'
+			UNION ALL
+			SELECT ledger_accounts.account_id, postings.amount
+			FROM postings
+			INNER JOIN ledger_accounts
+			ON ledger_accounts.id = postings.account_id
+			INNER JOIN journal_entries
+			ON journal_entries.id = postings.entry_id
+			WHERE journal_entries.description = 'opening balance'
+				AND ledger_accounts.account_id IS NOT NULL
+		),
+		expected AS (
+			SELECT account_id, SUM(amount) AS balance
+			FROM movements
+			GROUP BY account_id
+		),
+		ledger AS (
+			SELECT ledger_accounts.account_id, SUM(postings.amount) AS balance
+			FROM postings
+			INNER JOIN ledger_accounts
+			ON ledger_accounts.id = postings.account_id
+			WHERE ledger_accounts.account_id IS NOT NULL
+			GROUP BY ledger_accounts.account_id
+		)
+		SELECT accounts.id, accounts.user_id, accounts.currency, COALESCE(expected.balance, 0),
+			COALESCE(ledger.balance, 0), accounts.balance
+		FROM accounts
+		LEFT JOIN expected
+		ON expected.account_id = accounts.id
+		LEFT JOIN ledger
+		ON ledger.account_id = accounts.id
+		ORDER BY accounts.id
+	`
+
+	// this reads every movement there is, so it gets longer than the usual queries
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []*AccountBalances
+	for rows.Next() {
+		var b AccountBalances
+		err = rows.Scan(
+			&b.AccountID,
+			&b.UserID,
+			&b.Currency,
+			&b.ExpectedBalance,
+			&b.LedgerBalance,
+			&b.AccountBalance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, &b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+func (r *Repository) InsertRun(run *Run) error {
+	query := `
+		INSERT INTO reconciliation_runs (triggered_by)
+		VALUES ($1)
+		RETURNING id, started_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.DB.QueryRowContext(ctx, query, run.TriggeredBy).Scan(&run.ID, &run.StartedAt)
+}
+
+// FinishRunTx records the discrepancies the run found and what it checked in one transaction
+func (r *Repository) FinishRunTx(run *Run) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		query := `
+			INSERT INTO reconciliation_discrepancies
+				(run_id, account_id, user_id, currency, expected_balance, ledger_balance,
+				account_balance)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`
+		for _, d := range run.Discrepancies {
+			d.RunID = run.ID
+			err := tx.QueryRowContext(
+				ctx, query, d.RunID, d.AccountID, d.UserID, d.Currency, d.ExpectedBalance,
+				d.LedgerBalance, d.AccountBalance,
+			).Scan(&d.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		query = `
+			UPDATE reconciliation_runs
+			SET finished_at = NOW(), accounts_checked = $1, discrepancies = $2
+			WHERE id = $3
+			RETURNING finished_at
+		`
+		return tx.QueryRowContext(
+			ctx, query, run.AccountsChecked, len(run.Discrepancies), run.ID,
+		).Scan(&run.FinishedAt)
+	})
+}
+
+// GetRun returns the run with its discrepancies, the latest run when runID is 0
+func (r *Repository) GetRun(runID int64) (*Run, error) {
+	query := `
+		SELECT id, started_at, finished_at, triggered_by, accounts_checked
+		FROM reconciliation_runs
+		WHERE id = $1 OR ($1 = 0 AND finished_at IS NOT NULL)
+		ORDER BY id DESC
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var run Run
+	err := r.DB.QueryRowContext(ctx, query, runID).Scan(
+		&run.ID, &run.StartedAt, &run.FinishedAt, &run.TriggeredBy, &run.AccountsChecked,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+		SELECT id, run_id, account_id, user_id, currency, expected_balance, ledger_balance,
+			account_balance
+		FROM reconciliation_discrepancies
+		WHERE run_id = $1
+		ORDER BY account_id
+	`
+	rows, err := r.DB.QueryContext(ctx, query, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	run.Discrepancies = []*Discrepancy{}
+	for rows.Next() {
+		var d Discrepancy
+		err = rows.Scan(
+			&d.ID,
+			&d.RunID,
+			&d.AccountID,
+			&d.UserID,
+			&d.Currency,
+			&d.ExpectedBalance,
+			&d.LedgerBalance,
+			&d.AccountBalance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		run.Discrepancies = append(run.Discrepancies, &d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
@@ -0,0 +1,47 @@
+package reconcile
+
+type ReconcileRepo interface {
+	GetAccountBalances() ([]*AccountBalances, error)
+	InsertRun(run *Run) error
+	FinishRunTx(run *Run) error
+	GetRun(runID int64) (*Run, error)
+}
+
+type Service struct {
+	Repo ReconcileRepo
+}
+
+// Reconcile checks the balance of every account against its money movements and its ledger
+// postings, and records each account where they don't agree. the balances are updated in steps that
+// aren't all in one transaction, so this is how drift between them is found
+func (s *Service) Reconcile(triggeredBy string) (*Run, error) {
+	run := &Run{TriggeredBy: triggeredBy, Discrepancies: []*Discrepancy{}}
+	err := s.Repo.InsertRun(run)
+	if err != nil {
+		return nil, err
+	}
+
+	balances, err := s.Repo.GetAccountBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	run.AccountsChecked = len(balances)
+	for _, b := range balances {
+		if !b.Matches() {
+			run.Discrepancies = append(run.Discrepancies, &Discrepancy{AccountBalances: *b})
+		}
+	}
+
+	err = s.Repo.FinishRunTx(run)
+	if err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// GetRun returns the run with its discrepancies, the latest finished run when runID is 0
+func (s *Service) GetRun(runID int64) (*Run, error) {
+	return s.Repo.GetRun(runID)
+}
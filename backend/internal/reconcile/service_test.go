@@ -0,0 +1,139 @@
+package reconcile
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+)
+
+type MockRepo struct {
+	Balances    []*AccountBalances
+	Finished    *Run
+	InsertErr   error
+	BalancesErr error
+	FinishErr   error
+}
+
+func (r *MockRepo) GetAccountBalances() ([]*AccountBalances, error) {
+	return r.Balances, r.BalancesErr
+}
+
+func (r *MockRepo) InsertRun(run *Run) error {
+	run.ID = 1
+	return r.InsertErr
+}
+
+func (r *MockRepo) FinishRunTx(run *Run) error {
+	if r.FinishErr != nil {
+		return r.FinishErr
+	}
+	r.Finished = run
+	return nil
+}
+
+func (r *MockRepo) GetRun(runID int64) (*Run, error) {
+	return r.Finished, nil
+}
+
+func TestReconcile(t *testing.T) {
+	errDB := errors.New("db error")
+	balances := func(expected, ledger, cached string) *AccountBalances {
+		return &AccountBalances{
+			Currency:        "USD",
+			ExpectedBalance: money.MustParse(expected),
+			LedgerBalance:   money.MustParse(ledger),
+			AccountBalance:  money.MustParse(cached),
+		}
+	}
+
+	tests := []struct {
+		name            string
+		setupRepo       func(*MockRepo)
+		wantChecked     int
+		wantDiscrepancy []int64
+		expectedErr     error
+	}{
+		{
+			name: "all balanced",
+			setupRepo: func(r *MockRepo) {
+				r.Balances = []*AccountBalances{balances("10", "10", "10"), balances("0", "0", "0")}
+			},
+			wantChecked: 2,
+		},
+		{
+			name: "drift",
+			setupRepo: func(r *MockRepo) {
+				r.Balances = []*AccountBalances{
+					balances("10", "10", "10"),
+					// a movement posted to the ledger without its record
+					balances("10", "15", "15"),
+					// the cached balance out of step with the ledger
+					balances("5", "5", "4.99"),
+				}
+			},
+			wantChecked:     3,
+			wantDiscrepancy: []int64{2, 3},
+		},
+		{
+			name:        "no accounts",
+			setupRepo:   func(r *MockRepo) {},
+			wantChecked: 0,
+		},
+		{
+			name: "GetAccountBalances failure",
+			setupRepo: func(r *MockRepo) {
+				r.BalancesErr = errDB
+			},
+			expectedErr: errDB,
+		},
+		{
+			name: "FinishRunTx failure",
+			setupRepo: func(r *MockRepo) {
+				r.FinishErr = errDB
+			},
+			expectedErr: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			tc.setupRepo(repo)
+			for i, b := range repo.Balances {
+				b.AccountID = int64(i + 1)
+			}
+			svc := Service{Repo: repo}
+
+			run, gotErr := svc.Reconcile("test")
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if repo.Finished != run {
+				t.Fatalf("expected the run to be recorded")
+			}
+			if run.AccountsChecked != tc.wantChecked {
+				t.Errorf("expected %d accounts checked, got %d", tc.wantChecked, run.AccountsChecked)
+			}
+			if len(run.Discrepancies) != len(tc.wantDiscrepancy) {
+				t.Fatalf(
+					"expected %d discrepancies, got %d", len(tc.wantDiscrepancy),
+					len(run.Discrepancies),
+				)
+			}
+			for i, d := range run.Discrepancies {
+				if d.AccountID != tc.wantDiscrepancy[i] {
+					t.Errorf("expected account %d off, got %d", tc.wantDiscrepancy[i], d.AccountID)
+				}
+			}
+			if got := run.Summary()["discrepancies"]; got != strconv.Itoa(len(tc.wantDiscrepancy)) {
+				t.Errorf("expected summary of %d discrepancies, got %s", len(tc.wantDiscrepancy), got)
+			}
+		})
+	}
+}
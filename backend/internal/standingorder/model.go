@@ -0,0 +1,137 @@
+package standingorder
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+const (
+	FrequencyOnce    = "ONCE"
+	FrequencyWeekly  = "WEEKLY"
+	FrequencyMonthly = "MONTHLY"
+	// the last weekday of every month, there is no holiday calendar so holidays are not skipped
+	FrequencyLastBusinessDay = "LAST_BUSINESS_DAY"
+)
+
+const (
+	StatusActive    = "ACTIVE"
+	StatusPaused    = "PAUSED"
+	StatusCancelled = "CANCELLED"
+	StatusCompleted = "COMPLETED"
+	// a one-off order whose only run failed
+	StatusFailed = "FAILED"
+)
+
+const (
+	RunSucceeded = "SUCCEEDED"
+	RunFailed    = "FAILED"
+)
+
+// StandingOrder is a transfer the user has set up to be made for them, once at a later time or
+// again and again on a schedule. the schedule keeps the time of day of StartAt, and monthly orders
+// keep its day of the month, or the last day of shorter months
+type StandingOrder struct {
+	ID                int64        `json:"id"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UserID            int64        `json:"user_id"`
+	FromAccountID     int64        `json:"-"`
+	FromAccountNumber string       `json:"from_account"`
+	ToAccountID       int64        `json:"-"`
+	ToAccountNumber   string       `json:"to_account"`
+	Amount            money.Amount `json:"amount"`
+	Frequency         string       `json:"frequency"`
+	StartAt           time.Time    `json:"start_at"`
+	NextRunAt         time.Time    `json:"next_run_at"`
+	LastRunAt         *time.Time   `json:"last_run_at"`
+	LastError         string       `json:"last_error,omitempty"` // empty if the last run went through
+	Status            string       `json:"status"`
+	Version           int32        `json:"version"`
+}
+
+// Run is one execution of a standing order, TransferID is only set if the transfer went through
+type Run struct {
+	ID              int64
+	CreatedAt       time.Time
+	StandingOrderID int64
+	TransferID      *int64
+	Status          string
+	Error           string
+}
+
+// FirstRun is when the order is first due, which is the start unless the order only runs on certain
+// days
+func (o *StandingOrder) FirstRun() time.Time {
+	start := o.StartAt.UTC()
+	if o.Frequency != FrequencyLastBusinessDay {
+		return start
+	}
+
+	first := lastBusinessDay(start.Year(), start.Month(), start)
+	if first.Before(start) {
+		first = lastBusinessDay(start.Year(), start.Month()+1, start)
+	}
+	return first
+}
+
+// NextRun is when the order is due next after the given time. occurrences that were missed, because
+// the server was down or the order paused, are skipped rather than all made at once. a one-off order
+// has no next run
+func (o *StandingOrder) NextRun(after time.Time) (time.Time, bool) {
+	if o.Frequency == FrequencyOnce {
+		return time.Time{}, false
+	}
+
+	next := o.NextRunAt.UTC()
+	for !next.After(after) {
+		switch o.Frequency {
+		case FrequencyWeekly:
+			next = next.AddDate(0, 0, 7)
+		case FrequencyMonthly:
+			next = dayOfMonth(next.Year(), next.Month()+1, o.StartAt.UTC())
+		case FrequencyLastBusinessDay:
+			next = lastBusinessDay(next.Year(), next.Month()+1, o.StartAt.UTC())
+		default:
+			return time.Time{}, false
+		}
+	}
+	return next, true
+}
+
+// dayOfMonth is the day of the month of start in the given month, at the time of day of start. the
+// day is moved back to the end of the month if the month is too short. months past 12 roll over into
+// the next year
+func dayOfMonth(year int, month time.Month, start time.Time) time.Time {
+	// day 0 of the month after is the last day of this one
+	daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return time.Date(
+		year, month, min(start.Day(), daysInMonth),
+		start.Hour(), start.Minute(), start.Second(), 0, time.UTC,
+	)
+}
+
+// lastBusinessDay is the last weekday of the given month, at the time of day of start
+func lastBusinessDay(year int, month time.Month, start time.Time) time.Time {
+	day := time.Date(year, month+1, 0, start.Hour(), start.Minute(), start.Second(), 0, time.UTC)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+func ValidateStandingOrder(v *validator.Validator, order *StandingOrder) {
+	v.CheckAddError(order.Amount != 0, "amount", "must be given")
+	v.CheckAddError(order.Amount > 0, "amount", "must be greater than 0")
+	v.CheckAddError(
+		order.FromAccountID != order.ToAccountID, "to account", "cannot be the from account",
+	)
+	v.CheckAddError(
+		validator.ValueInList(
+			order.Frequency, FrequencyOnce, FrequencyWeekly, FrequencyMonthly,
+			FrequencyLastBusinessDay,
+		),
+		"frequency", "invalid",
+	)
+	v.CheckAddError(!order.StartAt.IsZero(), "start at", "must be given")
+}
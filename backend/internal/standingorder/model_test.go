@@ -0,0 +1,161 @@
+package standingorder
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestFirstRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency string
+		startAt   time.Time
+		want      time.Time
+	}{
+		{
+			name:      "once",
+			frequency: FrequencyOnce,
+			startAt:   date(2025, time.May, 10, 9),
+			want:      date(2025, time.May, 10, 9),
+		},
+		{
+			name:      "monthly",
+			frequency: FrequencyMonthly,
+			startAt:   date(2025, time.May, 10, 9),
+			want:      date(2025, time.May, 10, 9),
+		},
+		{
+			name:      "last business day, later in the month",
+			frequency: FrequencyLastBusinessDay,
+			startAt:   date(2025, time.May, 10, 9),
+			want:      date(2025, time.May, 30, 9), // the 31st is a saturday
+		},
+		{
+			name:      "last business day, already passed",
+			frequency: FrequencyLastBusinessDay,
+			startAt:   date(2025, time.May, 31, 9),
+			want:      date(2025, time.June, 30, 9),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			order := &StandingOrder{Frequency: tc.frequency, StartAt: tc.startAt}
+			if got := order.FirstRun(); !got.Equal(tc.want) {
+				t.Errorf("expected first run %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency string
+		startAt   time.Time
+		nextRunAt time.Time
+		after     time.Time
+		want      time.Time
+		wantOK    bool
+	}{
+		{
+			name:      "once",
+			frequency: FrequencyOnce,
+			startAt:   date(2025, time.January, 1, 9),
+			nextRunAt: date(2025, time.January, 1, 9),
+			after:     date(2025, time.January, 1, 9),
+		},
+		{
+			name:      "weekly",
+			frequency: FrequencyWeekly,
+			startAt:   date(2025, time.January, 1, 9),
+			nextRunAt: date(2025, time.January, 1, 9),
+			after:     date(2025, time.January, 1, 9),
+			want:      date(2025, time.January, 8, 9),
+			wantOK:    true,
+		},
+		{
+			name:      "weekly, missed runs skipped",
+			frequency: FrequencyWeekly,
+			startAt:   date(2025, time.January, 1, 9),
+			nextRunAt: date(2025, time.January, 1, 9),
+			after:     date(2025, time.January, 20, 9),
+			want:      date(2025, time.January, 22, 9),
+			wantOK:    true,
+		},
+		{
+			name:      "weekly, not due yet",
+			frequency: FrequencyWeekly,
+			startAt:   date(2025, time.January, 1, 9),
+			nextRunAt: date(2025, time.January, 8, 9),
+			after:     date(2025, time.January, 5, 9),
+			want:      date(2025, time.January, 8, 9),
+			wantOK:    true,
+		},
+		{
+			name:      "monthly into a shorter month",
+			frequency: FrequencyMonthly,
+			startAt:   date(2025, time.January, 31, 9),
+			nextRunAt: date(2025, time.January, 31, 9),
+			after:     date(2025, time.January, 31, 9),
+			want:      date(2025, time.February, 28, 9),
+			wantOK:    true,
+		},
+		{
+			name:      "monthly back to the day it started on",
+			frequency: FrequencyMonthly,
+			startAt:   date(2025, time.January, 31, 9),
+			nextRunAt: date(2025, time.February, 28, 9),
+			after:     date(2025, time.February, 28, 9),
+			want:      date(2025, time.March, 31, 9),
+			wantOK:    true,
+		},
+		{
+			name:      "monthly over the new year",
+			frequency: FrequencyMonthly,
+			startAt:   date(2025, time.January, 15, 9),
+			nextRunAt: date(2025, time.December, 15, 9),
+			after:     date(2025, time.December, 15, 9),
+			want:      date(2026, time.January, 15, 9),
+			wantOK:    true,
+		},
+		{
+			name:      "last business day",
+			frequency: FrequencyLastBusinessDay,
+			startAt:   date(2025, time.May, 10, 9),
+			nextRunAt: date(2025, time.May, 30, 9),
+			after:     date(2025, time.May, 30, 9),
+			want:      date(2025, time.June, 30, 9),
+			wantOK:    true,
+		},
+		{
+			name:      "last business day, month ending on a sunday",
+			frequency: FrequencyLastBusinessDay,
+			startAt:   date(2025, time.July, 1, 9),
+			nextRunAt: date(2025, time.July, 31, 9),
+			after:     date(2025, time.July, 31, 9),
+			want:      date(2025, time.August, 29, 9), // the 31st is a sunday
+			wantOK:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			order := &StandingOrder{
+				Frequency: tc.frequency, StartAt: tc.startAt, NextRunAt: tc.nextRunAt,
+			}
+
+			got, gotOK := order.NextRun(tc.after)
+			if gotOK != tc.wantOK {
+				t.Fatalf("expected ok=%v, got ok=%v", tc.wantOK, gotOK)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("expected next run %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
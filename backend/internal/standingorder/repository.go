@@ -0,0 +1,223 @@
+package standingorder
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+)
+
+var ErrEditConflict = errors.New("edit conflict")
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// the orders are always read with the numbers of their accounts, which is what the transfers are made
+// with and what the users know them by
+const selectOrders = `
+	SELECT standing_orders.id, standing_orders.created_at, standing_orders.user_id,
+		from_account_id, from_accounts.number, to_account_id, to_accounts.number, amount, frequency,
+		start_at, next_run_at, last_run_at, last_error, standing_orders.status,
+		standing_orders.version
+	FROM standing_orders
+	INNER JOIN accounts from_accounts ON from_accounts.id = standing_orders.from_account_id
+	INNER JOIN accounts to_accounts ON to_accounts.id = standing_orders.to_account_id
+`
+
+func (r *Repository) Insert(order *StandingOrder) error {
+	query := `
+		INSERT INTO standing_orders
+			(user_id, from_account_id, to_account_id, amount, frequency, start_at, next_run_at,
+			status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, version
+	`
+	args := []any{
+		order.UserID,
+		order.FromAccountID,
+		order.ToAccountID,
+		order.Amount,
+		order.Frequency,
+		order.StartAt,
+		order.NextRunAt,
+		order.Status,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.DB.QueryRowContext(ctx, query, args...).Scan(
+		&order.ID,
+		&order.CreatedAt,
+		&order.Version,
+	)
+}
+
+func (r *Repository) Get(orderID, userID int64) (*StandingOrder, error) {
+	query := selectOrders + `
+		WHERE standing_orders.id = $1 AND standing_orders.user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	order, err := scanOrder(r.DB.QueryRowContext(ctx, query, orderID, userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, user.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+func (r *Repository) GetAllUserStandingOrders(userID int64) ([]*StandingOrder, error) {
+	query := selectOrders + `
+		WHERE standing_orders.user_id = $1
+		ORDER BY standing_orders.id
+	`
+
+	return r.query(query, userID)
+}
+
+// GetDue gets up to limit active orders that were due by the given time, the longest overdue first
+func (r *Repository) GetDue(at time.Time, limit int) ([]*StandingOrder, error) {
+	query := selectOrders + `
+		WHERE standing_orders.status = 'ACTIVE' AND next_run_at <= $1
+		ORDER BY next_run_at, standing_orders.id
+		LIMIT $2
+	`
+
+	return r.query(query, at, limit)
+}
+
+// Update saves the schedule and status of the order, as long as no one else has changed it since it
+// was read
+func (r *Repository) Update(order *StandingOrder) error {
+	query := `
+		UPDATE standing_orders
+		SET next_run_at = $1, last_run_at = $2, status = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version
+	`
+	args := []any{
+		order.NextRunAt,
+		order.LastRunAt,
+		order.Status,
+		order.ID,
+		order.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(ctx, query, args...).Scan(&order.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InsertRunTx records the run and its outcome on the order in one database transaction. a failed run
+// of a one-off order, which was marked completed when it was picked up, marks it failed
+func (r *Repository) InsertRunTx(run *Run) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// in case of any issues
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO standing_order_runs (standing_order_id, transfer_id, status, error)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRowContext(
+		ctx, query, run.StandingOrderID, run.TransferID, run.Status, run.Error,
+	).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	// the status is left alone otherwise, the user may have paused or cancelled the order while it ran
+	query = `
+		UPDATE standing_orders
+		SET last_error = $1,
+			status = CASE WHEN $2 = 'FAILED' AND status = 'COMPLETED' THEN 'FAILED' ELSE status END
+		WHERE id = $3
+	`
+	_, err = tx.ExecContext(ctx, query, run.Error, run.Status, run.StandingOrderID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) query(query string, args ...any) ([]*StandingOrder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*StandingOrder
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// scanOrder scans a row of selectOrders, from either a *sql.Row or *sql.Rows
+func scanOrder(row interface{ Scan(dest ...any) error }) (*StandingOrder, error) {
+	order := &StandingOrder{}
+	err := row.Scan(
+		&order.ID,
+		&order.CreatedAt,
+		&order.UserID,
+		&order.FromAccountID,
+		&order.FromAccountNumber,
+		&order.ToAccountID,
+		&order.ToAccountNumber,
+		&order.Amount,
+		&order.Frequency,
+		&order.StartAt,
+		&order.NextRunAt,
+		&order.LastRunAt,
+		&order.LastError,
+		&order.Status,
+		&order.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
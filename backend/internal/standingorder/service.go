@@ -0,0 +1,257 @@
+package standingorder
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// how many due orders are picked up at a time
+const dueBatchSize = 100
+
+type Repo interface {
+	Insert(order *StandingOrder) error
+	Get(orderID, userID int64) (*StandingOrder, error)
+	GetAllUserStandingOrders(userID int64) ([]*StandingOrder, error)
+	GetDue(at time.Time, limit int) ([]*StandingOrder, error)
+	Update(order *StandingOrder) error
+	InsertRunTx(run *Run) error
+}
+
+type UserService interface {
+	GetUser(userID int64) (*user.User, error)
+}
+
+type AccountService interface {
+	GetUserAccount(userID int64, number string) (*account.Account, error)
+}
+
+type TransferService interface {
+	TransferMoney(
+		v *validator.Validator, fromUser *user.User, fromAccountNumber, toAccountNumber,
+		toUserEmail string, amount money.Amount, memo, reference string,
+	) (*transfer.Transfer, *user.User, error)
+	RecipientAccount(
+		v *validator.Validator, toAccountNumber, toUserEmail string,
+	) (*account.Account, error)
+}
+
+type Service struct {
+	Repo            Repo
+	UserService     UserService
+	AccountService  AccountService
+	TransferService TransferService
+}
+
+// New sets up a standing order from one of the user's accounts, their primary account when no number
+// is given, to the recipient named by account number or email. the recipient's account is fixed when
+// the order is made. an order without a start is due straight away
+func (s *Service) New(
+	v *validator.Validator, u *user.User, fromAccountNumber, toAccountNumber, toUserEmail string,
+	amount money.Amount, frequency string, startAt time.Time,
+) (*StandingOrder, error) {
+	fromAccount, err := s.AccountService.GetUserAccount(u.ID, fromAccountNumber)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("from account", "not found")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	toAccount, err := s.TransferService.RecipientAccount(v, toAccountNumber, toUserEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if startAt.IsZero() {
+		startAt = now
+	}
+	v.CheckAddError(!startAt.Before(now.Add(-time.Minute)), "start at", "cannot be in the past")
+
+	order := StandingOrder{
+		UserID:            u.ID,
+		FromAccountID:     fromAccount.ID,
+		FromAccountNumber: fromAccount.Number,
+		ToAccountID:       toAccount.ID,
+		ToAccountNumber:   toAccount.Number,
+		Amount:            amount,
+		Frequency:         frequency,
+		StartAt:           startAt.UTC(),
+		Status:            StatusActive,
+	}
+	order.NextRunAt = order.FirstRun()
+
+	v.CheckAddError(fromAccount.IsActive(), "from account", "is not active")
+	if ValidateStandingOrder(v, &order); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	err = s.Repo.Insert(&order)
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+func (s *Service) Pause(v *validator.Validator, orderID, userID int64) (*StandingOrder, error) {
+	return s.setStatus(v, orderID, userID, StatusPaused, StatusActive)
+}
+
+// Resume puts a paused order back on its schedule, the runs it missed while paused are not made. a
+// one-off order that was due while paused is made straight away
+func (s *Service) Resume(v *validator.Validator, orderID, userID int64) (*StandingOrder, error) {
+	return s.setStatus(v, orderID, userID, StatusActive, StatusPaused)
+}
+
+func (s *Service) Cancel(v *validator.Validator, orderID, userID int64) (*StandingOrder, error) {
+	return s.setStatus(v, orderID, userID, StatusCancelled, StatusActive, StatusPaused)
+}
+
+// setStatus moves the order to the new status if it is in one of the statuses it can be moved from
+func (s *Service) setStatus(
+	v *validator.Validator, orderID, userID int64, newStatus string, from ...string,
+) (*StandingOrder, error) {
+	order, err := s.Repo.Get(orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(from, order.Status) {
+		v.AddError("status", fmt.Sprintf("cannot be changed from %s to %s", order.Status, newStatus))
+		return nil, validator.ErrFailedValidation
+	}
+
+	if newStatus == StatusActive {
+		if next, ok := order.NextRun(time.Now()); ok {
+			order.NextRunAt = next
+		}
+	}
+	order.Status = newStatus
+
+	err = s.Repo.Update(order)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// ExecuteDue makes the transfers of all the orders that are due by the given time and returns how
+// many were executed. the errors of single orders do not stop the others from being executed, they
+// are returned together at the end
+func (s *Service) ExecuteDue(now time.Time) (int, error) {
+	executed := 0
+	var errs []error
+	for {
+		orders, err := s.Repo.GetDue(now, dueBatchSize)
+		if err != nil {
+			return executed, errors.Join(append(errs, err)...)
+		}
+
+		ranInBatch := 0
+		for _, order := range orders {
+			ran, err := s.execute(order, now)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("standing order %d: %w", order.ID, err))
+			}
+			if ran {
+				ranInBatch++
+			}
+		}
+		executed += ranInBatch
+
+		// the orders that ran have been moved past now, so a full batch means there may be more. if
+		// none of them ran they are still due and would only be picked up again
+		if len(orders) < dueBatchSize || ranInBatch == 0 {
+			return executed, errors.Join(errs...)
+		}
+	}
+}
+
+// execute claims the order by moving it on to its next run before making the transfer, so that two
+// schedulers never make the same transfer twice. the flip side is that a run that fails for a reason
+// other than the transfer being refused is not retried, it is recorded as failed like any other
+func (s *Service) execute(order *StandingOrder, now time.Time) (bool, error) {
+	next, ok := order.NextRun(now)
+	if ok {
+		order.NextRunAt = next
+	} else {
+		order.Status = StatusCompleted
+	}
+	order.LastRunAt = &now
+
+	err := s.Repo.Update(order)
+	if err != nil {
+		// paused, cancelled or picked up by someone else since it was read
+		if errors.Is(err, ErrEditConflict) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	run := Run{StandingOrderID: order.ID, Status: RunSucceeded}
+	tr, runErr := s.transfer(order)
+	if runErr != nil {
+		run.Status = RunFailed
+		run.Error = runErr.Error()
+	} else {
+		run.TransferID = &tr.ID
+	}
+
+	err = s.Repo.InsertRunTx(&run)
+	if err != nil {
+		return true, err
+	}
+
+	// a refused transfer, like one without enough money in the account, is the customer's business
+	// and is only recorded on the order. anything else is ours
+	var refused *refusedError
+	if runErr != nil && !errors.As(runErr, &refused) {
+		return true, runErr
+	}
+
+	return true, nil
+}
+
+func (s *Service) transfer(order *StandingOrder) (*transfer.Transfer, error) {
+	u, err := s.UserService.GetUser(order.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	v := validator.New()
+	tr, _, err := s.TransferService.TransferMoney(
+		v, u, order.FromAccountNumber, order.ToAccountNumber, "", order.Amount, "", "",
+	)
+	if err != nil {
+		if errors.Is(err, validator.ErrFailedValidation) {
+			return nil, &refusedError{errors: v.Errors}
+		}
+		return nil, err
+	}
+
+	return tr, nil
+}
+
+// refusedError is a transfer that was turned down, with the reasons it was
+type refusedError struct {
+	errors map[string]string
+}
+
+func (e *refusedError) Error() string {
+	return validator.Reasons(e.errors)
+}
+
+func (s *Service) GetAllUserStandingOrders(userID int64) ([]*StandingOrder, error) {
+	return s.Repo.GetAllUserStandingOrders(userID)
+}
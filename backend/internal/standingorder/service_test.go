@@ -0,0 +1,548 @@
+package standingorder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// ---MOCKS---
+
+type MockRepo struct {
+	Orders []*StandingOrder
+	Runs   []*Run
+
+	InsertErr error
+	UpdateErr error
+	GetDueErr error
+}
+
+func (r *MockRepo) Insert(order *StandingOrder) error {
+	if r.InsertErr != nil {
+		return r.InsertErr
+	}
+	order.ID = int64(len(r.Orders) + 1)
+	order.Version = 1
+	r.Orders = append(r.Orders, order)
+	return nil
+}
+
+func (r *MockRepo) Get(orderID, userID int64) (*StandingOrder, error) {
+	for _, o := range r.Orders {
+		if o.ID == orderID && o.UserID == userID {
+			return o, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (r *MockRepo) GetAllUserStandingOrders(userID int64) ([]*StandingOrder, error) {
+	return nil, nil
+}
+
+func (r *MockRepo) GetDue(at time.Time, limit int) ([]*StandingOrder, error) {
+	if r.GetDueErr != nil {
+		return nil, r.GetDueErr
+	}
+	var due []*StandingOrder
+	for _, o := range r.Orders {
+		if o.Status == StatusActive && !o.NextRunAt.After(at) && len(due) < limit {
+			due = append(due, o)
+		}
+	}
+	return due, nil
+}
+
+func (r *MockRepo) Update(order *StandingOrder) error {
+	if r.UpdateErr != nil {
+		return r.UpdateErr
+	}
+	order.Version++
+	return nil
+}
+
+// InsertRunTx records the run and fails a one-off order like the real one does
+func (r *MockRepo) InsertRunTx(run *Run) error {
+	r.Runs = append(r.Runs, run)
+	for _, o := range r.Orders {
+		if o.ID == run.StandingOrderID {
+			o.LastError = run.Error
+			if run.Status == RunFailed && o.Status == StatusCompleted {
+				o.Status = StatusFailed
+			}
+		}
+	}
+	return nil
+}
+
+type MockUserService struct{}
+
+func (us *MockUserService) GetUser(userID int64) (*user.User, error) {
+	return &user.User{ID: userID}, nil
+}
+
+type MockAccountService struct {
+	Accounts []*account.Account
+}
+
+func (as *MockAccountService) GetUserAccount(userID int64, number string) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.UserID == userID && (number == "" || a.Number == number) {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+// MockTransferService refuses the transfers when it is given validation errors and records the ones
+// it makes
+type MockTransferService struct {
+	Accounts []*account.Account
+
+	ValidationErrors map[string]string
+	TransferErr      error
+	Transfers        []*transfer.Transfer
+}
+
+func (ts *MockTransferService) TransferMoney(
+	v *validator.Validator, fromUser *user.User, fromAccountNumber, toAccountNumber,
+	toUserEmail string, amount money.Amount, memo, reference string,
+) (*transfer.Transfer, *user.User, error) {
+	if ts.TransferErr != nil {
+		return nil, nil, ts.TransferErr
+	}
+	if len(ts.ValidationErrors) > 0 {
+		for key, message := range ts.ValidationErrors {
+			v.AddError(key, message)
+		}
+		return nil, nil, validator.ErrFailedValidation
+	}
+	tr := &transfer.Transfer{ID: int64(len(ts.Transfers) + 1), FromUserID: fromUser.ID, Amount: amount}
+	ts.Transfers = append(ts.Transfers, tr)
+	return tr, fromUser, nil
+}
+
+func (ts *MockTransferService) RecipientAccount(
+	v *validator.Validator, toAccountNumber, toUserEmail string,
+) (*account.Account, error) {
+	for _, a := range ts.Accounts {
+		if a.Number == toAccountNumber {
+			return a, nil
+		}
+	}
+	v.AddError("to account", "not found")
+	return nil, validator.ErrFailedValidation
+}
+
+func newAccounts() []*account.Account {
+	return []*account.Account{
+		{ID: 1, UserID: 1, Number: "1000000000", Status: account.StatusActive, Currency: "USD"},
+		{ID: 2, UserID: 2, Number: "1000000001", Status: account.StatusActive, Currency: "USD"},
+		{ID: 3, UserID: 1, Number: "1000000002", Status: account.StatusFrozen, Currency: "USD"},
+	}
+}
+
+func TestNew(t *testing.T) {
+	errDB := errors.New("db error")
+	future := time.Now().Add(24 * time.Hour)
+
+	type input struct {
+		fromAccountNumber string
+		toAccountNumber   string
+		amount            money.Amount
+		frequency         string
+		startAt           time.Time
+	}
+	tests := []struct {
+		name        string
+		setupRepo   func(*MockRepo)
+		input       input
+		expectedErr error
+	}{
+		{
+			name:      "future one-off",
+			setupRepo: func(r *MockRepo) {},
+			input: input{
+				toAccountNumber: "1000000001", amount: money.MustParse("10"),
+				frequency: FrequencyOnce, startAt: future,
+			},
+		},
+		{
+			name:      "monthly starting now",
+			setupRepo: func(r *MockRepo) {},
+			input: input{
+				toAccountNumber: "1000000001", amount: money.MustParse("10"),
+				frequency: FrequencyMonthly,
+			},
+		},
+		{
+			name:      "start in the past",
+			setupRepo: func(r *MockRepo) {},
+			input: input{
+				toAccountNumber: "1000000001", amount: money.MustParse("10"),
+				frequency: FrequencyWeekly, startAt: time.Now().Add(-24 * time.Hour),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "unknown frequency",
+			setupRepo: func(r *MockRepo) {},
+			input: input{
+				toAccountNumber: "1000000001", amount: money.MustParse("10"), frequency: "DAILY",
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "no amount",
+			setupRepo: func(r *MockRepo) {},
+			input: input{
+				toAccountNumber: "1000000001", frequency: FrequencyWeekly,
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "from account not active",
+			setupRepo: func(r *MockRepo) {},
+			input: input{
+				fromAccountNumber: "1000000002", toAccountNumber: "1000000001",
+				amount: money.MustParse("10"), frequency: FrequencyWeekly,
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "from account belongs to someone else",
+			setupRepo: func(r *MockRepo) {},
+			input: input{
+				fromAccountNumber: "1000000001", toAccountNumber: "1000000000",
+				amount: money.MustParse("10"), frequency: FrequencyWeekly,
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "to the same account",
+			setupRepo: func(r *MockRepo) {},
+			input: input{
+				toAccountNumber: "1000000000", amount: money.MustParse("10"),
+				frequency: FrequencyWeekly,
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "recipient not found",
+			setupRepo: func(r *MockRepo) {},
+			input: input{
+				toAccountNumber: "9999999999", amount: money.MustParse("10"),
+				frequency: FrequencyWeekly,
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "Insert failure",
+			setupRepo: func(r *MockRepo) {
+				r.InsertErr = errDB
+			},
+			input: input{
+				toAccountNumber: "1000000001", amount: money.MustParse("10"),
+				frequency: FrequencyWeekly,
+			},
+			expectedErr: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			tc.setupRepo(repo)
+			accounts := newAccounts()
+			svc := Service{
+				Repo:            repo,
+				UserService:     &MockUserService{},
+				AccountService:  &MockAccountService{Accounts: accounts},
+				TransferService: &MockTransferService{Accounts: accounts},
+			}
+
+			order, gotErr := svc.New(
+				validator.New(), &user.User{ID: 1}, tc.input.fromAccountNumber,
+				tc.input.toAccountNumber, "", tc.input.amount, tc.input.frequency,
+				tc.input.startAt,
+			)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if len(repo.Orders) != 1 {
+				t.Fatalf("expected 1 order to be inserted, got %d", len(repo.Orders))
+			}
+			if order.Status != StatusActive || order.NextRunAt.IsZero() {
+				t.Errorf("expected an active order with a next run, got %+v", order)
+			}
+			if order.FromAccountID != 1 || order.ToAccountNumber != tc.input.toAccountNumber {
+				t.Errorf("unexpected accounts %+v", order)
+			}
+		})
+	}
+}
+
+func TestChangeStatus(t *testing.T) {
+	past := time.Now().Add(-30 * 24 * time.Hour)
+
+	tests := []struct {
+		name        string
+		status      string
+		frequency   string
+		change      func(*Service, *validator.Validator, int64, int64) (*StandingOrder, error)
+		orderID     int64
+		wantStatus  string
+		wantNextRun func(time.Time) bool
+		expectedErr error
+	}{
+		{
+			name:       "pause",
+			status:     StatusActive,
+			frequency:  FrequencyWeekly,
+			change:     (*Service).Pause,
+			orderID:    1,
+			wantStatus: StatusPaused,
+		},
+		{
+			name:        "pause a paused order",
+			status:      StatusPaused,
+			frequency:   FrequencyWeekly,
+			change:      (*Service).Pause,
+			orderID:     1,
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:       "resume skips the missed runs",
+			status:     StatusPaused,
+			frequency:  FrequencyWeekly,
+			change:     (*Service).Resume,
+			orderID:    1,
+			wantStatus: StatusActive,
+			wantNextRun: func(next time.Time) bool {
+				return next.After(time.Now())
+			},
+		},
+		{
+			name:       "resume a one-off that was due",
+			status:     StatusPaused,
+			frequency:  FrequencyOnce,
+			change:     (*Service).Resume,
+			orderID:    1,
+			wantStatus: StatusActive,
+			wantNextRun: func(next time.Time) bool {
+				return next.Equal(past)
+			},
+		},
+		{
+			name:       "cancel a paused order",
+			status:     StatusPaused,
+			frequency:  FrequencyMonthly,
+			change:     (*Service).Cancel,
+			orderID:    1,
+			wantStatus: StatusCancelled,
+		},
+		{
+			name:        "cancel a completed order",
+			status:      StatusCompleted,
+			frequency:   FrequencyOnce,
+			change:      (*Service).Cancel,
+			orderID:     1,
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:        "someone else's order",
+			status:      StatusActive,
+			frequency:   FrequencyWeekly,
+			change:      (*Service).Pause,
+			orderID:     2,
+			expectedErr: user.ErrNoRecord,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{Orders: []*StandingOrder{
+				{
+					ID: 1, UserID: 1, Frequency: tc.frequency, StartAt: past, NextRunAt: past,
+					Status: tc.status,
+				},
+				{ID: 2, UserID: 2, Frequency: FrequencyWeekly, Status: StatusActive},
+			}}
+			svc := &Service{Repo: repo}
+
+			order, gotErr := tc.change(svc, validator.New(), tc.orderID, 1)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if order.Status != tc.wantStatus {
+				t.Errorf("expected status %s, got %s", tc.wantStatus, order.Status)
+			}
+			if tc.wantNextRun != nil && !tc.wantNextRun(order.NextRunAt) {
+				t.Errorf("unexpected next run %v", order.NextRunAt)
+			}
+		})
+	}
+}
+
+func TestExecuteDue(t *testing.T) {
+	errDB := errors.New("db error")
+	now := date(2025, time.March, 10, 12)
+
+	newOrders := func() []*StandingOrder {
+		return []*StandingOrder{
+			{
+				ID: 1, UserID: 1, FromAccountNumber: "1000000000", ToAccountNumber: "1000000001",
+				Amount: money.MustParse("10"), Frequency: FrequencyWeekly,
+				StartAt: date(2025, time.March, 3, 9), NextRunAt: date(2025, time.March, 10, 9),
+				Status: StatusActive,
+			},
+			{
+				ID: 2, UserID: 1, FromAccountNumber: "1000000000", ToAccountNumber: "1000000001",
+				Amount: money.MustParse("20"), Frequency: FrequencyOnce,
+				StartAt: date(2025, time.March, 10, 9), NextRunAt: date(2025, time.March, 10, 9),
+				Status: StatusActive,
+			},
+			// not due yet
+			{
+				ID: 3, UserID: 1, FromAccountNumber: "1000000000", ToAccountNumber: "1000000001",
+				Amount: money.MustParse("30"), Frequency: FrequencyOnce,
+				StartAt: date(2025, time.March, 11, 9), NextRunAt: date(2025, time.March, 11, 9),
+				Status: StatusActive,
+			},
+			// paused
+			{
+				ID: 4, UserID: 1, FromAccountNumber: "1000000000", ToAccountNumber: "1000000001",
+				Amount: money.MustParse("40"), Frequency: FrequencyWeekly,
+				StartAt: date(2025, time.March, 3, 9), NextRunAt: date(2025, time.March, 10, 9),
+				Status: StatusPaused,
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		setupRepo     func(*MockRepo)
+		setupTransfer func(*MockTransferService)
+		wantExecuted  int
+		wantTransfers int
+		wantStatuses  map[int64]string
+		wantRunError  string
+		expectedErr   error
+	}{
+		{
+			name:          "due orders",
+			setupRepo:     func(r *MockRepo) {},
+			setupTransfer: func(ts *MockTransferService) {},
+			wantExecuted:  2,
+			wantTransfers: 2,
+			wantStatuses: map[int64]string{
+				1: StatusActive, 2: StatusCompleted, 3: StatusActive, 4: StatusPaused,
+			},
+		},
+		{
+			name:      "insufficient funds",
+			setupRepo: func(r *MockRepo) {},
+			setupTransfer: func(ts *MockTransferService) {
+				ts.ValidationErrors = map[string]string{"account balance": "insufficient funds"}
+			},
+			wantExecuted: 2,
+			wantStatuses: map[int64]string{
+				1: StatusActive, 2: StatusFailed, 3: StatusActive, 4: StatusPaused,
+			},
+			wantRunError: "account balance: insufficient funds",
+		},
+		{
+			name:      "transfer failure",
+			setupRepo: func(r *MockRepo) {},
+			setupTransfer: func(ts *MockTransferService) {
+				ts.TransferErr = errDB
+			},
+			wantExecuted: 2,
+			wantStatuses: map[int64]string{
+				1: StatusActive, 2: StatusFailed, 3: StatusActive, 4: StatusPaused,
+			},
+			wantRunError: errDB.Error(),
+			expectedErr:  errDB,
+		},
+		{
+			name: "changed under us",
+			setupRepo: func(r *MockRepo) {
+				r.UpdateErr = ErrEditConflict
+			},
+			setupTransfer: func(ts *MockTransferService) {},
+		},
+		{
+			name: "GetDue failure",
+			setupRepo: func(r *MockRepo) {
+				r.GetDueErr = errDB
+			},
+			setupTransfer: func(ts *MockTransferService) {},
+			expectedErr:   errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{Orders: newOrders()}
+			tc.setupRepo(repo)
+			transferSvc := &MockTransferService{}
+			tc.setupTransfer(transferSvc)
+			svc := Service{
+				Repo:            repo,
+				UserService:     &MockUserService{},
+				TransferService: transferSvc,
+			}
+
+			executed, gotErr := svc.ExecuteDue(now)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+
+			if executed != tc.wantExecuted || len(repo.Runs) != tc.wantExecuted {
+				t.Errorf(
+					"expected %d executed, got %d executed and %d runs", tc.wantExecuted, executed,
+					len(repo.Runs),
+				)
+			}
+			if len(transferSvc.Transfers) != tc.wantTransfers {
+				t.Errorf("expected %d transfers, got %d", tc.wantTransfers, len(transferSvc.Transfers))
+			}
+			for _, o := range repo.Orders {
+				if want, ok := tc.wantStatuses[o.ID]; ok && o.Status != want {
+					t.Errorf("expected order %d to be %s, got %s", o.ID, want, o.Status)
+				}
+			}
+			for _, run := range repo.Runs {
+				if !strings.Contains(run.Error, tc.wantRunError) {
+					t.Errorf("expected run error %q, got %q", tc.wantRunError, run.Error)
+				}
+				if (run.Status == RunSucceeded) != (run.TransferID != nil) {
+					t.Errorf("expected a transfer only on a successful run, got %+v", run)
+				}
+			}
+
+			// the weekly order is moved on to the next week whether the transfer went through or not
+			if tc.wantExecuted > 0 && !repo.Orders[0].NextRunAt.Equal(date(2025, time.March, 17, 9)) {
+				t.Errorf(
+					"expected the weekly order to be next due a week on, got %v",
+					repo.Orders[0].NextRunAt,
+				)
+			}
+		})
+	}
+}
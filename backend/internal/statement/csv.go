@@ -0,0 +1,41 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteCSV writes the statement as CSV, a few rows about the account followed by the movements
+func WriteCSV(w io.Writer, s *Statement) error {
+	cw := csv.NewWriter(w)
+
+	records := [][]string{
+		{"account", s.AccountNumber},
+		{"currency", s.Currency},
+		{"period", s.Period()},
+		{"opening balance", s.OpeningBalance.String()},
+		{"total in", s.TotalIn.String()},
+		{"total out", s.TotalOut.String()},
+		{"closing balance", s.ClosingBalance.String()},
+		{},
+		{"date", "entry", "description", "amount", "balance"},
+	}
+	for _, line := range s.Lines {
+		records = append(records, []string{
+			line.Date.UTC().Format(time.RFC3339),
+			strconv.FormatInt(line.EntryID, 10),
+			line.Description,
+			line.Amount.String(),
+			line.Balance.String(),
+		})
+	}
+
+	err := cw.WriteAll(records)
+	if err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
@@ -0,0 +1,76 @@
+package statement
+
+import (
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// the longest period a statement can cover
+const maxPeriod = 366 * 24 * time.Hour
+
+// Statement is everything that went in and out of one account over a period, read from the ledger
+// so that it covers transfers, transactions, loans and anything else that moves money alike
+type Statement struct {
+	UserID         int64        `json:"user_id"`
+	AccountID      int64        `json:"account_id"`
+	AccountNumber  string       `json:"account_number"`
+	Currency       string       `json:"currency"`
+	From           time.Time    `json:"from"`
+	To             time.Time    `json:"to"` // the last day of the period, included
+	OpeningBalance money.Amount `json:"opening_balance"`
+	TotalIn        money.Amount `json:"total_in"`
+	TotalOut       money.Amount `json:"total_out"`
+	ClosingBalance money.Amount `json:"closing_balance"`
+	Lines          []*Line      `json:"lines"`
+	GeneratedAt    time.Time    `json:"generated_at"`
+}
+
+// Line is one movement on the account, with the balance after it
+type Line struct {
+	EntryID     int64        `json:"entry_id"`
+	Date        time.Time    `json:"date"`
+	Description string       `json:"description"`
+	Amount      money.Amount `json:"amount"`
+	Balance     money.Amount `json:"balance"`
+}
+
+// Period is how the statement is named, e.g. "2026-09-01 to 2026-09-30"
+func (s *Statement) Period() string {
+	return s.From.Format(time.DateOnly) + " to " + s.To.Format(time.DateOnly)
+}
+
+// Filename is the name the statement is downloaded or attached as, without the extension
+func (s *Statement) Filename() string {
+	return "statement-" + s.AccountNumber + "-" + s.From.Format(time.DateOnly) + "-" +
+		s.To.Format(time.DateOnly)
+}
+
+// end is when the period ends, the start of the day after the last one
+func (s *Statement) end() time.Time {
+	return s.To.AddDate(0, 0, 1)
+}
+
+// addLines adds the movements in order, keeping the running balance and the totals
+func (s *Statement) addLines(lines []*Line) {
+	balance := s.OpeningBalance
+	for _, line := range lines {
+		balance += line.Amount
+		line.Balance = balance
+		if line.Amount > 0 {
+			s.TotalIn += line.Amount
+		} else {
+			s.TotalOut -= line.Amount
+		}
+	}
+	s.Lines = lines
+	s.ClosingBalance = balance
+}
+
+func ValidatePeriod(v *validator.Validator, from, to time.Time) {
+	v.CheckAddError(!from.IsZero(), "from", "must be given")
+	v.CheckAddError(!to.IsZero(), "to", "must be given")
+	v.CheckAddError(!to.Before(from), "to", "must not be before from")
+	v.CheckAddError(to.Sub(from) < maxPeriod, "period", "must not be longer than a year")
+}
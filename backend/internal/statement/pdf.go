@@ -0,0 +1,169 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// the page is A4 in points, the text is set in the standard Courier fonts, which every PDF reader
+// has and which are monospaced, so the columns line up without measuring the text
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	margin       = 50
+	fontSize     = 9
+	lineHeight   = 13
+	linesPerPage = (pageHeight - 2*margin) / lineHeight
+)
+
+// line of the statement's table, the description is cut to fit
+const rowFormat = "%-19s  %-32.32s  %14s  %14s"
+
+// WritePDF writes the statement as a PDF document, rendered without any dependencies
+func WritePDF(w io.Writer, s *Statement) error {
+	var lines []pdfLine
+	add := func(bold bool, format string, args ...any) {
+		lines = append(lines, pdfLine{text: fmt.Sprintf(format, args...), bold: bold})
+	}
+
+	add(true, "Account statement")
+	add(false, "")
+	add(false, "Account:          %s", s.AccountNumber)
+	add(false, "Currency:         %s", s.Currency)
+	add(false, "Period:           %s", s.Period())
+	add(false, "Generated:        %s", s.GeneratedAt.UTC().Format("2006-01-02 15:04 MST"))
+	add(false, "")
+	add(false, "Opening balance:  %s", s.OpeningBalance)
+	add(false, "Total in:         %s", s.TotalIn)
+	add(false, "Total out:        %s", s.TotalOut)
+	add(true, "Closing balance:  %s", s.ClosingBalance)
+	add(false, "")
+
+	header := fmt.Sprintf(rowFormat, "Date", "Description", "Amount", "Balance")
+	lines = append(lines, pdfLine{text: header, bold: true, header: true})
+	if len(s.Lines) == 0 {
+		add(false, "No movements in this period")
+	}
+	for _, line := range s.Lines {
+		add(false, rowFormat,
+			line.Date.UTC().Format("2006-01-02 15:04"), line.Description, line.Amount, line.Balance,
+		)
+	}
+
+	return writePDF(w, paginate(lines, header))
+}
+
+type pdfLine struct {
+	text   string
+	bold   bool
+	header bool // repeated at the top of every page the table runs onto
+}
+
+// paginate splits the lines into pages, starting each page after the first with the table header
+func paginate(lines []pdfLine, header string) [][]pdfLine {
+	var pages [][]pdfLine
+	var page []pdfLine
+	inTable := false
+	for _, line := range lines {
+		if len(page) == linesPerPage {
+			pages = append(pages, page)
+			page = nil
+			if inTable && !line.header {
+				page = append(page, pdfLine{text: header, bold: true})
+			}
+		}
+		inTable = inTable || line.header
+		page = append(page, line)
+	}
+	return append(pages, page)
+}
+
+// writePDF writes the pages of text as a PDF 1.4 document. the objects are the catalog, the page
+// tree, the two fonts and then a page and its content stream for each page
+func writePDF(w io.Writer, pages [][]pdfLine) error {
+	buf := &bytes.Buffer{}
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	const firstPage = 5
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = strconv.Itoa(firstPage+2*i) + " 0 R"
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf(
+		"<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages),
+	))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range pages {
+		object(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+				"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, firstPage+2*i+1,
+		))
+
+		content := pageContent(page, i+1, len(pages))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(
+		buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref,
+	)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// pageContent draws the lines down the page from the top margin, with the page number at the bottom
+func pageContent(lines []pdfLine, pageNumber, pageCount int) string {
+	var b strings.Builder
+	b.WriteString("BT\n")
+	fmt.Fprintf(&b, "%d TL\n%d %d Td\n", lineHeight, margin, pageHeight-margin)
+	for _, line := range lines {
+		font := "F1"
+		if line.bold {
+			font = "F2"
+		}
+		fmt.Fprintf(&b, "/%s %d Tf\n(%s) Tj\nT*\n", font, fontSize, escapePDF(line.text))
+	}
+	b.WriteString("ET\n")
+
+	fmt.Fprintf(
+		&b, "BT\n/F1 %d Tf\n%d %d Td\n(Page %d of %d) Tj\nET",
+		fontSize, margin, margin/2, pageNumber, pageCount,
+	)
+	return b.String()
+}
+
+// escapePDF makes the text safe to put in a PDF string. anything outside printable ASCII is
+// replaced, the standard fonts can't be relied on to have it
+func escapePDF(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
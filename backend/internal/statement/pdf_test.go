@@ -0,0 +1,112 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+)
+
+func newStatement(lines int) *Statement {
+	s := &Statement{
+		AccountNumber:  "1000000000",
+		Currency:       "USD",
+		From:           date("2026-09-01T00:00:00Z"),
+		To:             date("2026-09-30T00:00:00Z"),
+		OpeningBalance: money.MustParse("100"),
+		GeneratedAt:    time.Now(),
+	}
+	var movements []*Line
+	for i := 0; i < lines; i++ {
+		movements = append(movements, &Line{
+			EntryID:     int64(i + 1),
+			Date:        s.From.Add(time.Duration(i) * time.Minute),
+			Description: "transfer (to a friend)",
+			Amount:      money.MustParse("-1"),
+		})
+	}
+	s.addLines(movements)
+	return s
+}
+
+func TestWritePDF(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     int
+		wantPages int
+	}{
+		{name: "no movements", lines: 0, wantPages: 1},
+		{name: "one page", lines: 10, wantPages: 1},
+		{name: "several pages", lines: 150, wantPages: 3},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := WritePDF(buf, newStatement(tc.lines))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pdf := buf.String()
+
+			if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+				t.Fatalf("not a PDF document")
+			}
+			if got := strings.Count(pdf, "/Type /Page "); got != tc.wantPages {
+				t.Errorf("expected %d pages, got %d", tc.wantPages, got)
+			}
+			if !strings.Contains(pdf, `transfer \(to a friend\)`) && tc.lines > 0 {
+				t.Errorf("expected the description escaped")
+			}
+
+			// every object has to be where the cross-reference table says it is
+			xref, err := strconv.Atoi(regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(pdf)[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(pdf[xref:], "xref\n") {
+				t.Fatalf("startxref does not point at the xref table")
+			}
+			offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(pdf[xref:], -1)
+			for i, offset := range offsets {
+				at, _ := strconv.Atoi(offset[1])
+				if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(pdf[at:], want) {
+					t.Errorf("object %d is not at offset %d", i+1, at)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteCSV(buf, newStatement(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rows about the account are shorter than the movements
+	r := csv.NewReader(buf)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"closing balance", "98.00"},
+		{"2026-09-01T00:01:00Z", "2", "transfer (to a friend)", "-1.00", "98.00"},
+	}
+	if got := records[6]; strings.Join(got, ",") != strings.Join(want[0], ",") {
+		t.Errorf("expected %v, got %v", want[0], got)
+	}
+	if got := records[len(records)-1]; strings.Join(got, ",") != strings.Join(want[1], ",") {
+		t.Errorf("expected %v, got %v", want[1], got)
+	}
+}
@@ -0,0 +1,175 @@
+package statement
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+// Balance is the balance of the account before the time, the sum of its postings until then
+func (r *Repository) Balance(accountID int64, before time.Time) (money.Amount, error) {
+	query := `
+		SELECT COALESCE(SUM(postings.amount), 0)
+		FROM postings
+		INNER JOIN ledger_accounts
+		ON ledger_accounts.id = postings.account_id
+		INNER JOIN journal_entries
+		ON journal_entries.id = postings.entry_id
+		WHERE ledger_accounts.account_id = $1 AND journal_entries.created_at < $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var balance money.Amount
+	err := r.DB.QueryRowContext(ctx, query, accountID, before).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// GetLines returns the account's movements from from until before to, oldest first
+func (r *Repository) GetLines(accountID int64, from, to time.Time) ([]*Line, error) {
+	query := `
+		SELECT journal_entries.id, journal_entries.created_at, journal_entries.description,
+			SUM(postings.amount)
+		FROM journal_entries
+		INNER JOIN postings
+		ON postings.entry_id = journal_entries.id
+		INNER JOIN ledger_accounts
+		ON ledger_accounts.id = postings.account_id
+		WHERE ledger_accounts.account_id = $1
+			AND journal_entries.created_at >= $2 AND journal_entries.created_at < $3
+		GROUP BY journal_entries.id
+		ORDER BY journal_entries.created_at, journal_entries.id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, accountID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []*Line
+	for rows.Next() {
+		var line Line
+		err = rows.Scan(&line.EntryID, &line.Date, &line.Description, &line.Amount)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, &line)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// Recipient is an account whose monthly statement is waiting to be emailed
+type Recipient struct {
+	AccountID     int64
+	UserID        int64
+	Name          string
+	Email         string
+	AccountNumber string
+}
+
+// GetUndelivered returns up to limit accounts, after the one with the ID afterID, that were open
+// before the end of the period and have not been sent their statement for it yet
+func (r *Repository) GetUndelivered(
+	periodStart, periodEnd time.Time, afterID int64, limit int,
+) ([]*Recipient, error) {
+	query := `
+		SELECT accounts.id, users.id, users.name, users.email, accounts.number
+		FROM accounts
+		INNER JOIN users
+		ON users.id = accounts.user_id
+		WHERE users.activated = TRUE AND accounts.status <> 'CLOSED'
+			AND accounts.created_at < $2 AND accounts.id > $3
+			AND NOT EXISTS (
+				SELECT 1 FROM statement_deliveries
+				WHERE statement_deliveries.account_id = accounts.id
+					AND statement_deliveries.period_start = $1
+			)
+		ORDER BY accounts.id
+		LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, periodStart, periodEnd, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []*Recipient
+	for rows.Next() {
+		var recipient Recipient
+		err = rows.Scan(
+			&recipient.AccountID, &recipient.UserID, &recipient.Name, &recipient.Email, &recipient.AccountNumber,
+		)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, &recipient)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return recipients, nil
+}
+
+// MarkDelivered records that the account's statement for the period is being sent. it reports false
+// if it already had been, by another server at the same time
+func (r *Repository) MarkDelivered(accountID int64, periodStart time.Time) (bool, error) {
+	query := `
+		INSERT INTO statement_deliveries (account_id, period_start)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, accountID, periodStart)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// UnmarkDelivered forgets that the statement was sent, when sending it failed, so that it is tried
+// again
+func (r *Repository) UnmarkDelivered(accountID int64, periodStart time.Time) error {
+	query := `
+		DELETE FROM statement_deliveries
+		WHERE account_id = $1 AND period_start = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, accountID, periodStart)
+	return err
+}
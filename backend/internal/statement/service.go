@@ -0,0 +1,177 @@
+package statement
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/mailer"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// how many statements are emailed per query for the accounts still waiting for theirs
+const deliveryBatchSize = 100
+
+type StatementRepo interface {
+	Balance(accountID int64, before time.Time) (money.Amount, error)
+	GetLines(accountID int64, from, to time.Time) ([]*Line, error)
+	GetUndelivered(
+		periodStart, periodEnd time.Time, afterID int64, limit int,
+	) ([]*Recipient, error)
+	MarkDelivered(accountID int64, periodStart time.Time) (bool, error)
+	UnmarkDelivered(accountID int64, periodStart time.Time) error
+}
+
+type AccountService interface {
+	GetUserAccount(userID int64, number string) (*account.Account, error)
+}
+
+type Mailer interface {
+	SendWithAttachments(
+		recipient, templateFile string, data map[string]any, attachments ...mailer.Attachment,
+	) error
+}
+
+type Service struct {
+	Repo           StatementRepo
+	AccountService AccountService
+	Mailer         Mailer
+}
+
+// Generate makes the statement of one of the user's accounts, their primary account when no number
+// is given, for the days from from to to, both included. the days are in UTC
+func (s *Service) Generate(
+	v *validator.Validator, userID int64, accountNumber string, from, to time.Time,
+) (*Statement, error) {
+	from, to = day(from), day(to)
+	if ValidatePeriod(v, from, to); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	a, err := s.AccountService.GetUserAccount(userID, accountNumber)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("account", "not found")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	return s.generate(a, from, to)
+}
+
+func (s *Service) generate(a *account.Account, from, to time.Time) (*Statement, error) {
+	statement := &Statement{
+		UserID:        a.UserID,
+		AccountID:     a.ID,
+		AccountNumber: a.Number,
+		Currency:      a.Currency,
+		From:          from,
+		To:            to,
+		GeneratedAt:   time.Now(),
+	}
+
+	var err error
+	statement.OpeningBalance, err = s.Repo.Balance(a.ID, statement.From)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := s.Repo.GetLines(a.ID, statement.From, statement.end())
+	if err != nil {
+		return nil, err
+	}
+	statement.addLines(lines)
+
+	return statement, nil
+}
+
+// SendMonthly emails every account its statement for the month before now, as a PDF and a CSV
+// attachment, unless it has been sent already. a statement that fails to send is left for the next
+// call to try again. it returns how many were sent and the last error, if any
+func (s *Service) SendMonthly(now time.Time) (int, error) {
+	periodEnd := day(now).AddDate(0, 0, 1-day(now).Day())
+	periodStart := periodEnd.AddDate(0, -1, 0)
+
+	sent := 0
+	var lastErr error
+	afterID := int64(0)
+	for {
+		recipients, err := s.Repo.GetUndelivered(
+			periodStart, periodEnd, afterID, deliveryBatchSize,
+		)
+		if err != nil {
+			return sent, err
+		}
+		if len(recipients) == 0 {
+			return sent, lastErr
+		}
+
+		for _, recipient := range recipients {
+			afterID = recipient.AccountID
+
+			// claim the statement first so that no other server sends it as well
+			claimed, err := s.Repo.MarkDelivered(recipient.AccountID, periodStart)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if !claimed {
+				continue
+			}
+
+			err = s.send(recipient, periodStart, periodEnd.AddDate(0, 0, -1))
+			if err != nil {
+				lastErr = err
+				if err := s.Repo.UnmarkDelivered(recipient.AccountID, periodStart); err != nil {
+					lastErr = err
+				}
+				continue
+			}
+			sent++
+		}
+	}
+}
+
+func (s *Service) send(recipient *Recipient, from, to time.Time) error {
+	a, err := s.AccountService.GetUserAccount(recipient.UserID, recipient.AccountNumber)
+	if err != nil {
+		return err
+	}
+
+	statement, err := s.generate(a, from, to)
+	if err != nil {
+		return err
+	}
+
+	csvFile, pdfFile := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := WriteCSV(csvFile, statement); err != nil {
+		return err
+	}
+	if err := WritePDF(pdfFile, statement); err != nil {
+		return err
+	}
+
+	data := map[string]any{
+		"userName":       recipient.Name,
+		"accountNumber":  statement.AccountNumber,
+		"period":         statement.From.Format("January 2006"),
+		"currency":       statement.Currency,
+		"openingBalance": statement.OpeningBalance,
+		"closingBalance": statement.ClosingBalance,
+	}
+	return s.Mailer.SendWithAttachments(
+		recipient.Email, "statement_monthly.html", data,
+		mailer.Attachment{Name: statement.Filename() + ".pdf", Data: pdfFile.Bytes()},
+		mailer.Attachment{Name: statement.Filename() + ".csv", Data: csvFile.Bytes()},
+	)
+}
+
+// day is the start of the day the time is on, in UTC
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
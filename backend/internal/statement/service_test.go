@@ -0,0 +1,360 @@
+package statement
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/mailer"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// MockRepo keeps the lines of every account and works balances and periods out from them like the
+// real one
+type MockRepo struct {
+	Lines      map[int64][]*Line
+	Recipients []*Recipient
+	Delivered  map[int64]bool
+	BalanceErr error
+}
+
+func (r *MockRepo) Balance(accountID int64, before time.Time) (money.Amount, error) {
+	if r.BalanceErr != nil {
+		return 0, r.BalanceErr
+	}
+	balance := money.Amount(0)
+	for _, line := range r.Lines[accountID] {
+		if line.Date.Before(before) {
+			balance += line.Amount
+		}
+	}
+	return balance, nil
+}
+
+func (r *MockRepo) GetLines(accountID int64, from, to time.Time) ([]*Line, error) {
+	var lines []*Line
+	for _, line := range r.Lines[accountID] {
+		if !line.Date.Before(from) && line.Date.Before(to) {
+			copied := *line
+			lines = append(lines, &copied)
+		}
+	}
+	return lines, nil
+}
+
+func (r *MockRepo) GetUndelivered(
+	periodStart, periodEnd time.Time, afterID int64, limit int,
+) ([]*Recipient, error) {
+	var recipients []*Recipient
+	for _, recipient := range r.Recipients {
+		if recipient.AccountID > afterID && !r.Delivered[recipient.AccountID] &&
+			len(recipients) < limit {
+			recipients = append(recipients, recipient)
+		}
+	}
+	return recipients, nil
+}
+
+func (r *MockRepo) MarkDelivered(accountID int64, periodStart time.Time) (bool, error) {
+	if r.Delivered[accountID] {
+		return false, nil
+	}
+	r.Delivered[accountID] = true
+	return true, nil
+}
+
+func (r *MockRepo) UnmarkDelivered(accountID int64, periodStart time.Time) error {
+	delete(r.Delivered, accountID)
+	return nil
+}
+
+type MockAccountService struct {
+	Accounts []*account.Account
+}
+
+func (as *MockAccountService) GetUserAccount(userID int64, number string) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.UserID == userID && (number == "" || a.Number == number) {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+type sentEmail struct {
+	recipient   string
+	data        map[string]any
+	attachments []mailer.Attachment
+}
+
+// MockMailer fails for the recipients in FailFor and keeps everything else it sends
+type MockMailer struct {
+	Sent    []sentEmail
+	FailFor map[string]bool
+}
+
+func (m *MockMailer) SendWithAttachments(
+	recipient, templateFile string, data map[string]any, attachments ...mailer.Attachment,
+) error {
+	if m.FailFor[recipient] {
+		return errors.New("dialer failed")
+	}
+	m.Sent = append(m.Sent, sentEmail{recipient, data, attachments})
+	return nil
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func newLines() map[int64][]*Line {
+	return map[int64][]*Line{
+		1: {
+			{EntryID: 1, Date: date("2026-08-20T10:00:00Z"), Description: "deposit",
+				Amount: money.MustParse("100")},
+			{EntryID: 2, Date: date("2026-09-01T00:00:00Z"), Description: "transfer",
+				Amount: money.MustParse("-30")},
+			{EntryID: 3, Date: date("2026-09-15T12:30:00Z"), Description: "loan disbursement",
+				Amount: money.MustParse("50")},
+			{EntryID: 4, Date: date("2026-09-30T23:59:59Z"), Description: "loan payment",
+				Amount: money.MustParse("-10.50")},
+			{EntryID: 5, Date: date("2026-10-01T00:00:00Z"), Description: "withdrawal",
+				Amount: money.MustParse("-5")},
+		},
+	}
+}
+
+func newAccounts() []*account.Account {
+	return []*account.Account{
+		{ID: 1, UserID: 1, Number: "1000000000", Currency: "USD", Status: account.StatusActive},
+		{ID: 2, UserID: 2, Number: "1000000001", Currency: "USD", Status: account.StatusActive},
+		{ID: 3, UserID: 3, Number: "1000000002", Currency: "EUR", Status: account.StatusActive},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupRepo     func(*MockRepo)
+		userID        int64
+		from          time.Time
+		to            time.Time
+		wantOpening   money.Amount
+		wantBalances  []money.Amount
+		wantIn        money.Amount
+		wantOut       money.Amount
+		wantClosing   money.Amount
+		expectedErr   error
+		expectedField string
+	}{
+		{
+			name:        "a month",
+			userID:      1,
+			from:        date("2026-09-01T00:00:00Z"),
+			to:          date("2026-09-30T00:00:00Z"),
+			wantOpening: money.MustParse("100"),
+			wantBalances: []money.Amount{
+				money.MustParse("70"), money.MustParse("120"), money.MustParse("109.50"),
+			},
+			wantIn:      money.MustParse("50"),
+			wantOut:     money.MustParse("40.50"),
+			wantClosing: money.MustParse("109.50"),
+		},
+		{
+			name:        "a time of day is the whole day",
+			userID:      1,
+			from:        date("2026-09-15T18:00:00Z"),
+			to:          date("2026-09-15T01:00:00Z"),
+			wantOpening: money.MustParse("70"),
+			wantBalances: []money.Amount{
+				money.MustParse("120"),
+			},
+			wantIn:      money.MustParse("50"),
+			wantClosing: money.MustParse("120"),
+		},
+		{
+			name:        "no movements",
+			userID:      2,
+			from:        date("2026-09-01T00:00:00Z"),
+			to:          date("2026-09-30T00:00:00Z"),
+			wantOpening: 0,
+			wantClosing: 0,
+		},
+		{
+			name:          "to before from",
+			userID:        1,
+			from:          date("2026-09-30T00:00:00Z"),
+			to:            date("2026-09-01T00:00:00Z"),
+			expectedErr:   validator.ErrFailedValidation,
+			expectedField: "to",
+		},
+		{
+			name:          "longer than a year",
+			userID:        1,
+			from:          date("2025-01-01T00:00:00Z"),
+			to:            date("2026-01-02T00:00:00Z"),
+			expectedErr:   validator.ErrFailedValidation,
+			expectedField: "period",
+		},
+		{
+			name:          "no account",
+			userID:        4,
+			from:          date("2026-09-01T00:00:00Z"),
+			to:            date("2026-09-30T00:00:00Z"),
+			expectedErr:   validator.ErrFailedValidation,
+			expectedField: "account",
+		},
+		{
+			name: "Balance failure",
+			setupRepo: func(r *MockRepo) {
+				r.BalanceErr = errors.New("db error")
+			},
+			userID:      1,
+			from:        date("2026-09-01T00:00:00Z"),
+			to:          date("2026-09-30T00:00:00Z"),
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{Lines: newLines()}
+			if tc.setupRepo != nil {
+				tc.setupRepo(repo)
+			}
+			svc := Service{Repo: repo, AccountService: &MockAccountService{Accounts: newAccounts()}}
+
+			v := validator.New()
+			got, gotErr := svc.Generate(v, tc.userID, "", tc.from, tc.to)
+			if tc.expectedErr != nil {
+				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+				}
+				if _, ok := v.Errors[tc.expectedField]; tc.expectedField != "" && !ok {
+					t.Errorf("expected an error for %s, got %v", tc.expectedField, v.Errors)
+				}
+				return
+			} else if gotErr != nil {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+
+			if got.OpeningBalance != tc.wantOpening {
+				t.Errorf("expected opening balance=%v, got %v", tc.wantOpening, got.OpeningBalance)
+			}
+			if len(got.Lines) != len(tc.wantBalances) {
+				t.Fatalf("expected %d lines, got %d", len(tc.wantBalances), len(got.Lines))
+			}
+			for i, line := range got.Lines {
+				if line.Balance != tc.wantBalances[i] {
+					t.Errorf(
+						"line %d: expected balance=%v, got %v", i, tc.wantBalances[i], line.Balance,
+					)
+				}
+			}
+			if got.TotalIn != tc.wantIn || got.TotalOut != tc.wantOut {
+				t.Errorf(
+					"expected in=%v and out=%v, got %v and %v",
+					tc.wantIn, tc.wantOut, got.TotalIn, got.TotalOut,
+				)
+			}
+			if got.ClosingBalance != tc.wantClosing {
+				t.Errorf("expected closing balance=%v, got %v", tc.wantClosing, got.ClosingBalance)
+			}
+		})
+	}
+}
+
+func TestSendMonthly(t *testing.T) {
+	now := date("2026-10-03T08:00:00Z")
+	recipients := []*Recipient{
+		{AccountID: 1, UserID: 1, Name: "yusuf", Email: "y@gmail.com", AccountNumber: "1000000000"},
+		{AccountID: 2, UserID: 2, Name: "mohamed", Email: "m@gmail.com", AccountNumber: "1000000001"},
+		{AccountID: 3, UserID: 3, Name: "ali", Email: "a@gmail.com", AccountNumber: "1000000002"},
+	}
+
+	tests := []struct {
+		name          string
+		delivered     map[int64]bool
+		failFor       map[string]bool
+		wantSent      int
+		wantDelivered map[int64]bool
+		wantErr       bool
+	}{
+		{
+			name:          "every account",
+			delivered:     map[int64]bool{},
+			wantSent:      3,
+			wantDelivered: map[int64]bool{1: true, 2: true, 3: true},
+		},
+		{
+			name:          "already sent",
+			delivered:     map[int64]bool{1: true, 3: true},
+			wantSent:      1,
+			wantDelivered: map[int64]bool{1: true, 2: true, 3: true},
+		},
+		{
+			name:          "failed to send",
+			delivered:     map[int64]bool{},
+			failFor:       map[string]bool{"m@gmail.com": true},
+			wantSent:      2,
+			wantDelivered: map[int64]bool{1: true, 3: true},
+			wantErr:       true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{Lines: newLines(), Recipients: recipients, Delivered: tc.delivered}
+			mail := &MockMailer{FailFor: tc.failFor}
+			svc := Service{
+				Repo:           repo,
+				AccountService: &MockAccountService{Accounts: newAccounts()},
+				Mailer:         mail,
+			}
+
+			sent, gotErr := svc.SendMonthly(now)
+			if (gotErr != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, gotErr)
+			}
+			if sent != tc.wantSent || len(mail.Sent) != tc.wantSent {
+				t.Errorf("expected %d sent, got %d and %d emails", tc.wantSent, sent, len(mail.Sent))
+			}
+			if len(repo.Delivered) != len(tc.wantDelivered) {
+				t.Errorf("expected delivered %v, got %v", tc.wantDelivered, repo.Delivered)
+			}
+			for accountID := range tc.wantDelivered {
+				if !repo.Delivered[accountID] {
+					t.Errorf("expected account %d delivered", accountID)
+				}
+			}
+
+			for _, email := range mail.Sent {
+				if email.recipient != "y@gmail.com" {
+					continue
+				}
+				// the statement is for september
+				if email.data["closingBalance"] != money.MustParse("109.50") {
+					t.Errorf("expected closing balance=109.50, got %v", email.data["closingBalance"])
+				}
+				if len(email.attachments) != 2 {
+					t.Fatalf("expected 2 attachments, got %d", len(email.attachments))
+				}
+				want := "statement-1000000000-2026-09-01-2026-09-30.pdf"
+				if email.attachments[0].Name != want {
+					t.Errorf("expected %s, got %s", want, email.attachments[0].Name)
+				}
+				if !strings.HasPrefix(string(email.attachments[0].Data), "%PDF-") {
+					t.Errorf("expected a PDF attachment")
+				}
+			}
+		})
+	}
+}
@@ -3,19 +3,35 @@ package transaction
 import (
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
+const (
+	maxMemoLength      = 200
+	maxReferenceLength = 35
+)
+
+// Transaction is money put into or taken out of an account over the counter. Memo and Reference are
+// what the staff say about it, and Category is the category the user filed it under
 type Transaction struct {
 	ID          int64
 	CreatedAt   time.Time
 	UserID      int64
+	AccountID   int64
+	Currency    string
 	Action      string
-	Amount      float64
+	Amount      money.Amount
 	PerformedBy string
+	Memo        string
+	Reference   string
+	Category    string
 }
 
-func ValidateTransaction(v *validator.Validator, transaction *Transaction) {
+// ValidateTransaction checks the transaction against the account it is made on. a withdrawal can
+// take the account below zero as far as its overdraft goes
+func ValidateTransaction(v *validator.Validator, transaction *Transaction, a *account.Account) {
 	v.CheckAddError(transaction.Amount != 0, "amount", "must be given")
 	v.CheckAddError(transaction.Amount > 0, "amount", "must be more than 0")
 
@@ -26,4 +42,17 @@ func ValidateTransaction(v *validator.Validator, transaction *Transaction) {
 	v.CheckAddError(validator.ValueInList(transaction.Action, safeActions...), "action", "invalid")
 
 	v.CheckAddError(transaction.PerformedBy != "", "performed by", "must be given")
+	v.CheckAddError(
+		len(transaction.Memo) <= maxMemoLength, "memo", "must not be more than 200 bytes long",
+	)
+	v.CheckAddError(
+		len(transaction.Reference) <= maxReferenceLength, "reference",
+		"must not be more than 35 bytes long",
+	)
+
+	if transaction.Action == "WITHDRAW" {
+		v.CheckAddError(
+			a.Spendable() >= transaction.Amount, "account balance", "insufficient funds",
+		)
+	}
 }
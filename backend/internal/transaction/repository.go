@@ -4,47 +4,131 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
 )
 
 type Repository struct {
 	DB *sql.DB
 }
 
-func (r *Repository) Insert(transaction *Transaction) error {
+// InsertTx posts the entry that moves the money and records the transaction in one database
+// transaction, with the deposit or withdrawal as an event, so either all of them are there or none.
+// the checks are run in the transaction once the transaction has its ID, before the money moves,
+// and nothing is saved if one fails
+func (r *Repository) InsertTx(
+	transaction *Transaction, entry *ledger.Entry, checks ...dbtx.Func,
+) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		err := insertTx(ctx, tx, transaction)
+		if err != nil {
+			return err
+		}
+
+		for _, check := range checks {
+			err = check(ctx, tx)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = ledger.PostInTx(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
+
+		return recordTx(ctx, tx, transaction)
+	})
+}
+
+func insertTx(ctx context.Context, tx *sql.Tx, transaction *Transaction) error {
 	query := `
-		INSERT INTO transactions (user_id, action, amount, performed_by)
-		VALUES ($1, $2, $3, $4)	
+		INSERT INTO transactions
+			(user_id, account_id, currency, action, amount, performed_by, memo, reference)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at
 	`
 	args := []any{
 		transaction.UserID,
+		transaction.AccountID,
+		transaction.Currency,
 		transaction.Action,
 		transaction.Amount,
 		transaction.PerformedBy,
+		transaction.Memo,
+		transaction.Reference,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	return r.DB.QueryRowContext(ctx, query, args...).Scan(
+	return tx.QueryRowContext(ctx, query, args...).Scan(
 		&transaction.ID,
 		&transaction.CreatedAt,
 	)
 }
 
-func (r *Repository) GetAllUserTransactions(userID int64) ([]*Transaction, error) {
-	query := `
-		SELECT id, created_at, user_id, action, amount, performed_by
+// recordTx records the deposit or withdrawal as an event in the transaction
+func recordTx(ctx context.Context, tx *sql.Tx, transaction *Transaction) error {
+	made := event.Transaction{
+		TransactionID: transaction.ID,
+		AccountID:     transaction.AccountID,
+		Amount:        transaction.Amount,
+		Currency:      transaction.Currency,
+		PerformedBy:   transaction.PerformedBy,
+	}
+	var payload event.Payload = event.Deposit{Transaction: made}
+	if transaction.Action == "WITHDRAW" {
+		payload = event.Withdrawal{Transaction: made}
+	}
+	e, err := event.New(transaction.UserID, payload)
+	if err != nil {
+		return err
+	}
+
+	return event.RecordInTx(ctx, tx, e)
+}
+
+// transactionHistory is how the history of a user's transactions is queried. they are over the
+// counter, so there is no counterparty
+var transactionHistory = history.Table{
+	ID:        "transactions.id",
+	CreatedAt: "transactions.created_at",
+	Amount:    "transactions.amount",
+	Status:    "transactions.action",
+	Statuses:  []string{"DEPOSIT", "WITHDRAW"},
+	Category:  "categories.name = %[1]s::citext",
+}
+
+// GetAllUserTransactions gets the page of the user's transactions that the query asks for, each
+// with the category the user filed it under
+func (r *Repository) GetAllUserTransactions(
+	userID int64, q *history.Query,
+) ([]*Transaction, *history.Page, error) {
+	from := `
 		FROM transactions
-		WHERE user_id = $1
+		LEFT JOIN categories ON categories.id = transactions.category_id
+		WHERE transactions.user_id = $1
 	`
+	filter, args := transactionHistory.Filter(q, []any{userID})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := r.DB.QueryContext(ctx, query, userID)
+	page := &history.Page{}
+	err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*)"+from+filter, args...).Scan(&page.Total)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	seek, orderBy, args := transactionHistory.Seek(q, args)
+	query := `
+		SELECT transactions.id, transactions.created_at, transactions.user_id, account_id,
+			currency, action, amount, performed_by, memo, reference, COALESCE(categories.name, '')
+	` + from + filter + seek + orderBy
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer rows.Close()
 	var transactions []*Transaction
@@ -54,18 +138,28 @@ func (r *Repository) GetAllUserTransactions(userID int64) ([]*Transaction, error
 			&transaction.ID,
 			&transaction.CreatedAt,
 			&transaction.UserID,
+			&transaction.AccountID,
+			&transaction.Currency,
 			&transaction.Action,
 			&transaction.Amount,
 			&transaction.PerformedBy,
+			&transaction.Memo,
+			&transaction.Reference,
+			&transaction.Category,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		transactions = append(transactions, transaction)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if q.More(len(transactions)) {
+		transactions = transactions[:q.PageSize]
+		last := transactions[len(transactions)-1]
+		page.NextCursor = q.NextCursor(last.CreatedAt, last.Amount, last.ID)
 	}
 
-	return transactions, nil
+	return transactions, page, nil
 }
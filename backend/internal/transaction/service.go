@@ -1,54 +1,92 @@
 package transaction
 
 import (
-	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
 type Repo interface {
-	Insert(transaction *Transaction) error
-	GetAllUserTransactions(userID int64) ([]*Transaction, error)
+	InsertTx(transaction *Transaction, entry *ledger.Entry, checks ...dbtx.Func) error
+	GetAllUserTransactions(userID int64, q *history.Query) ([]*Transaction, *history.Page, error)
+}
+
+type AccountService interface {
+	GetAccount(accountID int64) (*account.Account, error)
+	GetAccountByNumber(number string) (*account.Account, error)
+	GetUserAccount(userID int64, number string) (*account.Account, error)
+}
+
+// Limits checks the user can spend the amount, CheckInTx again in the database transaction it is
+// spent in
+type Limits interface {
+	Check(v *validator.Validator, userID int64, currency string, amount money.Amount) error
+	CheckInTx(
+		v *validator.Validator, userID int64, currency string, amount money.Amount,
+	) dbtx.Func
 }
 
-type UserService interface {
-	GetUser(userID int64) (*user.User, error)
-	UpdateUser(
-		userID int64, userName, userEmail string, userPasswordHash []byte,
-		userAccountBalance float64, userActivated bool,
-	) (*user.User, error)
+type Fraud interface {
+	Screen(v *validator.Validator, op *fraud.Operation) error
 }
 
+// Service makes deposits and withdrawals. when Fraud is set withdrawals are screened by it before
+// they are made, after the limits
 type Service struct {
-	Repo        Repo
-	UserService UserService
+	Repo           Repo
+	AccountService AccountService
+	Limits         Limits
+	Fraud          Fraud
+}
+
+// account finds the account the transaction is for. the account can be named by its number, by
+// the user it belongs to in which case it is their primary account, or both
+func (s *Service) account(userID int64, accountNumber string) (*account.Account, error) {
+	if userID == 0 {
+		return s.AccountService.GetAccountByNumber(accountNumber)
+	}
+	return s.AccountService.GetUserAccount(userID, accountNumber)
 }
 
 func (s *Service) Deposit(
-	v *validator.Validator, userID int64, amount float64, performedBy string,
+	v *validator.Validator, userID int64, accountNumber string, amount money.Amount,
+	performedBy, memo, reference string,
 ) (*Transaction, error) {
 	transaction := &Transaction{
-		UserID:      userID,
 		Amount:      amount,
 		Action:      "DEPOSIT",
 		PerformedBy: performedBy,
+		Memo:        memo,
+		Reference:   reference,
 	}
-	if ValidateTransaction(v, transaction); !v.IsValid() {
-		return nil, validator.ErrFailedValidation
-	}
-	u, err := s.UserService.GetUser(userID)
+	a, err := s.account(userID, accountNumber)
 	if err != nil {
 		return nil, err
 	}
+	transaction.UserID = a.UserID
+	transaction.AccountID = a.ID
+	transaction.Currency = a.Currency
 
-	err = s.Repo.Insert(transaction)
-	if err != nil {
-		return nil, err
+	v.CheckAddError(a.CanReceive(), "account", "is closed")
+	if ValidateTransaction(v, transaction, a); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
 	}
 
-	u.AccountBalance += transaction.Amount
-	_, err = s.UserService.UpdateUser(
-		u.ID, u.Name, u.Email, u.Password.Hash, u.AccountBalance, u.Activated,
+	// cash coming in over the counter is credited to the account
+	entry := ledger.NewEntry(
+		"deposit",
+		ledger.SystemPosting(ledger.AccountCash, transaction.Currency, transaction.Amount.Neg()),
+		ledger.AccountPosting(a.ID, transaction.Currency, transaction.Amount),
 	)
+	err = s.Repo.InsertTx(transaction, entry)
 	if err != nil {
 		return nil, err
 	}
@@ -57,40 +95,132 @@ func (s *Service) Deposit(
 }
 
 func (s *Service) Withdraw(
-	v *validator.Validator, userID int64, amount float64, performedBy string,
+	v *validator.Validator, userID int64, accountNumber string, amount money.Amount,
+	performedBy, memo, reference string,
 ) (*Transaction, error) {
 	transaction := &Transaction{
-		UserID:      userID,
 		Amount:      amount,
 		Action:      "WITHDRAW",
 		PerformedBy: performedBy,
+		Memo:        memo,
+		Reference:   reference,
 	}
-	u, err := s.UserService.GetUser(userID)
+	a, err := s.account(userID, accountNumber)
 	if err != nil {
 		return nil, err
 	}
+	transaction.UserID = a.UserID
+	transaction.AccountID = a.ID
+	transaction.Currency = a.Currency
 
-	v.CheckAddError(u.AccountBalance >= amount, "account balance", "insufficient funds")
-	if ValidateTransaction(v, transaction); !v.IsValid() {
-		return nil, validator.ErrFailedValidation
+	err = s.checkWithdrawal(v, transaction, a)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Fraud != nil {
+		err = s.Fraud.Screen(v, &fraud.Operation{
+			Kind:             fraud.KindWithdrawal,
+			UserID:           transaction.UserID,
+			AccountID:        a.ID,
+			AccountCreatedAt: a.CreatedAt,
+			Balance:          a.AvailableBalance,
+			Amount:           transaction.Amount,
+			Currency:         transaction.Currency,
+			Memo:             transaction.Memo,
+			Reference:        transaction.Reference,
+			PerformedBy:      transaction.PerformedBy,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = s.Repo.Insert(transaction)
+	err = s.withdraw(v, transaction)
 	if err != nil {
 		return nil, err
 	}
 
-	u.AccountBalance -= transaction.Amount
-	_, err = s.UserService.UpdateUser(
-		u.ID, u.Name, u.Email, u.Password.Hash, u.AccountBalance, u.Activated,
+	return transaction, nil
+}
+
+// MakeApproved makes the withdrawal of a screening held for review that staff approved, checked
+// again as things are now. the approval is saved, and the money held for the withdrawal released,
+// in the database transaction the withdrawal is made in, so the screening stays waiting if it
+// can't be made
+func (s *Service) MakeApproved(v *validator.Validator, screening *fraud.Screening) error {
+	a, err := s.AccountService.GetAccount(screening.AccountID)
+	if err != nil {
+		return err
+	}
+	// the hold is released as the money is withdrawn, what it sets aside is there to pay for it
+	a.AvailableBalance += screening.Amount
+
+	transaction := &Transaction{
+		UserID:      a.UserID,
+		AccountID:   a.ID,
+		Currency:    a.Currency,
+		Amount:      screening.Amount,
+		Action:      "WITHDRAW",
+		PerformedBy: screening.PerformedBy,
+		Memo:        screening.Memo,
+		Reference:   screening.Reference,
+	}
+	err = s.checkWithdrawal(v, transaction, a)
+	if err != nil {
+		return err
+	}
+
+	return s.withdraw(v, transaction, func(ctx context.Context, tx *sql.Tx) error {
+		screening.TransactionID = &transaction.ID
+		return fraud.ApproveInTx(ctx, tx, screening)
+	})
+}
+
+// checkWithdrawal checks the money can be withdrawn from the account, within the user's limits
+func (s *Service) checkWithdrawal(
+	v *validator.Validator, transaction *Transaction, a *account.Account,
+) error {
+	v.CheckAddError(a.IsActive(), "account", "is not active")
+	if ValidateTransaction(v, transaction, a); !v.IsValid() {
+		return validator.ErrFailedValidation
+	}
+
+	return s.Limits.Check(v, transaction.UserID, transaction.Currency, transaction.Amount)
+}
+
+// withdraw makes the withdrawal, running the checks and the limits in the database transaction it
+// is made in
+func (s *Service) withdraw(
+	v *validator.Validator, transaction *Transaction, checks ...dbtx.Func,
+) error {
+	amount, currency := transaction.Amount, transaction.Currency
+	entry := ledger.NewEntry(
+		"withdrawal",
+		ledger.AccountPosting(transaction.AccountID, currency, amount.Neg()),
+		ledger.SystemPosting(ledger.AccountCash, currency, amount),
 	)
+	checks = append(checks, s.Limits.CheckInTx(v, transaction.UserID, currency, amount))
+	err := s.Repo.InsertTx(transaction, entry, checks...)
 	if err != nil {
-		return nil, err
+		// the balance can change between the check above and the posting
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			v.AddError("account balance", "insufficient funds")
+			return validator.ErrFailedValidation
+		}
+		return err
 	}
 
-	return transaction, nil
+	return nil
 }
 
-func (s *Service) GetAllUserTransactions(userID int64) ([]*Transaction, error) {
-	return s.Repo.GetAllUserTransactions(userID)
+// GetAllUserTransactions gets a page of the user's transactions, the ones the query asks for
+func (s *Service) GetAllUserTransactions(
+	v *validator.Validator, userID int64, q *history.Query,
+) ([]*Transaction, *history.Page, error) {
+	if history.ValidateQuery(v, q, transactionHistory); !v.IsValid() {
+		return nil, nil, validator.ErrFailedValidation
+	}
+
+	return s.Repo.GetAllUserTransactions(userID, q)
 }
@@ -1,61 +1,125 @@
 package transaction
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"strings"
 	"testing"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
+// MockRepo applies the postings made against the account to the account it holds
 type MockRepo struct {
+	Account   *account.Account
 	InsertErr error
 }
 
-func (r *MockRepo) Insert(transaction *Transaction) error {
-	return r.InsertErr
+func (r *MockRepo) InsertTx(
+	transaction *Transaction, entry *ledger.Entry, checks ...dbtx.Func,
+) error {
+	if r.InsertErr != nil {
+		return r.InsertErr
+	}
+	for _, posting := range entry.Postings {
+		if r.Account != nil && posting.AccountID == r.Account.ID {
+			r.Account.Balance += posting.Amount
+		}
+	}
+	return nil
+}
+
+func (r *MockRepo) GetAllUserTransactions(
+	userID int64, q *history.Query,
+) ([]*Transaction, *history.Page, error) {
+	return nil, nil, nil
+}
+
+// MockAccountService hands out the same account however it is looked up
+type MockAccountService struct {
+	GetAccountResult *account.Account
+	GetAccountErr    error
+}
+
+func (as *MockAccountService) GetAccount(accountID int64) (*account.Account, error) {
+	if as.GetAccountErr != nil {
+		return nil, as.GetAccountErr
+	}
+	return as.GetAccountResult, nil
+}
+
+func (as *MockAccountService) GetAccountByNumber(number string) (*account.Account, error) {
+	if as.GetAccountErr != nil {
+		return nil, as.GetAccountErr
+	}
+	return as.GetAccountResult, nil
 }
 
-type MockUserService struct {
-	GetUserResult *user.User
-	GetUserErr    error
+func (as *MockAccountService) GetUserAccount(userID int64, number string) (*account.Account, error) {
+	if as.GetAccountErr != nil {
+		return nil, as.GetAccountErr
+	}
+	return as.GetAccountResult, nil
+}
 
-	UpdateUserResult *user.User
-	UpdateUserErr    error
+// MockLimits refuses every amount over Max, when one is set
+type MockLimits struct {
+	Max money.Amount
 }
 
-func (us *MockUserService) GetUser(userID int64) (*user.User, error) {
-	if us.GetUserErr != nil {
-		return nil, us.GetUserErr
+func (l *MockLimits) Check(
+	v *validator.Validator, userID int64, currency string, amount money.Amount,
+) error {
+	if l.Max != 0 && amount > l.Max {
+		v.AddError("daily limit", "exceeded, "+l.Max.String()+" "+currency+" remaining")
+		return validator.ErrFailedValidation
 	}
-	return us.GetUserResult, nil
+	return nil
 }
 
-func (us *MockUserService) UpdateUser(
-	userID int64, userName, userEmail string, userPasswordHash []byte,
-	userAccountBalance float64, userActivated bool,
-) (*user.User, error) {
-	if us.UpdateUserErr != nil {
-		return nil, us.UpdateUserErr
+// CheckInTx checks nothing more, the mock repositories don't run the checks
+func (l *MockLimits) CheckInTx(
+	v *validator.Validator, userID int64, currency string, amount money.Amount,
+) dbtx.Func {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		return nil
 	}
-	return us.UpdateUserResult, nil
+}
+
+// MockFraud returns Err for every operation it screens
+type MockFraud struct {
+	Err error
+}
+
+func (f *MockFraud) Screen(v *validator.Validator, op *fraud.Operation) error {
+	return f.Err
 }
 
 func TestDeposit(t *testing.T) {
-	mockUser := &user.User{
-		ID:             1,
-		Name:           "yusuf",
-		Email:          "ym@gmail.com",
-		AccountBalance: 0,
+	mockAccount := &account.Account{
+		ID:      1,
+		UserID:  1,
+		Number:  "1000000000",
+		Type:    account.TypeChecking,
+		Status:  account.StatusActive,
+		Balance: money.MustParse("0"),
 	}
 	tests := []struct {
-		name             string
-		setupRepo        func(*MockRepo)
-		setupUserService func(*MockUserService)
-		input            struct {
+		name                string
+		setupRepo           func(*MockRepo)
+		setupAccountService func(*MockAccountService)
+		input               struct {
 			v           *validator.Validator
 			userID      int64
-			amount      float64
+			amount      money.Amount
 			performedBy string
 		}
 		expectedErr error
@@ -63,105 +127,106 @@ func TestDeposit(t *testing.T) {
 		{
 			name:      "valid",
 			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 100, performedBy: "yusuf"},
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
 		},
 		{
 			name:      "amount = 0",
 			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 0, performedBy: "yusuf"},
+			}{v: validator.New(), userID: 1, amount: money.MustParse("0"), performedBy: "yusuf"},
 			expectedErr: validator.ErrFailedValidation,
 		},
 		{
 			name:      "amount < 0",
 			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: -100, performedBy: "yusuf"},
+			}{v: validator.New(), userID: 1, amount: money.MustParse("-100"), performedBy: "yusuf"},
 			expectedErr: validator.ErrFailedValidation,
 		},
 		{
-			name:      "GetUser failure",
+			name:      "account closed",
 			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserErr = user.ErrNoRecord
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = &account.Account{
+					ID: 2, UserID: 1, Status: account.StatusClosed,
+				}
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 100, performedBy: "yusuf"},
-			expectedErr: user.ErrNoRecord,
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
+			expectedErr: validator.ErrFailedValidation,
 		},
 		{
-			name: "Insert error",
-			setupRepo: func(r *MockRepo) {
-				r.InsertErr = errors.New("db Insert error")
-			},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
+			name:      "GetAccount failure",
+			setupRepo: func(r *MockRepo) {},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountErr = user.ErrNoRecord
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 100, performedBy: "yusuf"},
-			expectedErr: errors.New("db Insert error"),
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
+			expectedErr: user.ErrNoRecord,
 		},
 		{
-			name:      "UpdateUser failure",
-			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
-				us.UpdateUserErr = errors.New("db UpdateUser error")
+			name: "Insert error",
+			setupRepo: func(r *MockRepo) {
+				r.InsertErr = errors.New("db Insert error")
+			},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 100, performedBy: "yusuf"},
-			expectedErr: errors.New("db UpdateUser error"),
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
+			expectedErr: errors.New("db Insert error"),
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			repo := &MockRepo{}
-			userService := &MockUserService{}
+			repo := &MockRepo{Account: mockAccount}
+			accountService := &MockAccountService{}
 			tc.setupRepo(repo)
-			tc.setupUserService(userService)
+			tc.setupAccountService(accountService)
 
 			svc := Service{
-				Repo:        repo,
-				UserService: userService,
+				Repo:           repo,
+				AccountService: accountService,
 			}
 
 			transaction, gotErr := svc.Deposit(
-				tc.input.v, tc.input.userID, tc.input.amount, tc.input.performedBy,
+				tc.input.v, tc.input.userID, "", tc.input.amount, tc.input.performedBy, "", "",
 			)
 
 			if tc.expectedErr != nil {
@@ -183,14 +248,14 @@ func TestDeposit(t *testing.T) {
 
 			if transaction.Amount != tc.input.amount {
 				t.Errorf(
-					"expected transaction amount=%f, got amount=%f", tc.input.amount,
+					"expected transaction amount=%v, got amount=%v", tc.input.amount,
 					transaction.Amount,
 				)
 			}
-			if mockUser.AccountBalance != transaction.Amount {
+			if mockAccount.Balance != transaction.Amount {
 				t.Errorf(
-					"expected user account balance=%f, got account balance=%f", transaction.Amount,
-					user.AnonymousUser.AccountBalance,
+					"expected account balance=%v, got account balance=%v", transaction.Amount,
+					mockAccount.Balance,
 				)
 			}
 		})
@@ -198,91 +263,194 @@ func TestDeposit(t *testing.T) {
 }
 
 func TestWithdraw(t *testing.T) {
-	mockUser := &user.User{
-		ID:             1,
-		Name:           "yusuf",
-		Email:          "ym@gmail.com",
-		AccountBalance: 100,
+	mockAccount := &account.Account{
+		ID:               1,
+		UserID:           1,
+		Number:           "1000000000",
+		Type:             account.TypeChecking,
+		Status:           account.StatusActive,
+		Balance:          money.MustParse("100"),
+		AvailableBalance: money.MustParse("100"),
 	}
 	tests := []struct {
-		name             string
-		setupRepo        func(*MockRepo)
-		setupUserService func(*MockUserService)
-		input            struct {
+		name                string
+		setupRepo           func(*MockRepo)
+		setupAccountService func(*MockAccountService)
+		setupLimits         func(*MockLimits)
+		setupFraud          func(*MockFraud)
+		input               struct {
 			v           *validator.Validator
 			userID      int64
-			amount      float64
+			amount      money.Amount
 			performedBy string
 		}
+		wantBalance money.Amount
 		expectedErr error
 	}{
 		{
 			name:      "valid",
 			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 100, performedBy: "yusuf"},
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
 		},
 		{
 			name:      "amount = 0",
 			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 0, performedBy: "yusuf"},
+			}{v: validator.New(), userID: 1, amount: money.MustParse("0"), performedBy: "yusuf"},
 			expectedErr: validator.ErrFailedValidation,
 		},
 		{
 			name:      "amount < 0",
 			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
+			},
+			input: struct {
+				v           *validator.Validator
+				userID      int64
+				amount      money.Amount
+				performedBy string
+			}{v: validator.New(), userID: 1, amount: money.MustParse("-100"), performedBy: "yusuf"},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "over the limit",
+			setupRepo: func(r *MockRepo) {},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
+			},
+			setupLimits: func(l *MockLimits) {
+				l.Max = money.MustParse("50")
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: -100, performedBy: "yusuf"},
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
 			expectedErr: validator.ErrFailedValidation,
 		},
 		{
-			name:      "amount > user account balance",
+			name:      "held for review",
+			setupRepo: func(r *MockRepo) {},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
+			},
+			setupFraud: func(f *MockFraud) {
+				f.Err = fraud.ErrHeldForReview
+			},
+			input: struct {
+				v           *validator.Validator
+				userID      int64
+				amount      money.Amount
+				performedBy string
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
+			expectedErr: fraud.ErrHeldForReview,
+		},
+		{
+			name:      "amount > account balance",
 			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 200, performedBy: "yusuf"},
+			}{v: validator.New(), userID: 1, amount: money.MustParse("200"), performedBy: "yusuf"},
 			expectedErr: validator.ErrFailedValidation,
 		},
 		{
-			name:      "GetUser failure",
+			name:      "into the overdraft",
+			setupRepo: func(r *MockRepo) {},
+			setupAccountService: func(as *MockAccountService) {
+				mockAccount.OverdraftLimit = money.MustParse("100")
+				as.GetAccountResult = mockAccount
+			},
+			input: struct {
+				v           *validator.Validator
+				userID      int64
+				amount      money.Amount
+				performedBy string
+			}{v: validator.New(), userID: 1, amount: money.MustParse("150"), performedBy: "yusuf"},
+			wantBalance: money.MustParse("-50"),
+		},
+		{
+			name:      "past the overdraft",
 			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserErr = user.ErrNoRecord
+			setupAccountService: func(as *MockAccountService) {
+				mockAccount.OverdraftLimit = money.MustParse("100")
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 100, performedBy: "yusuf"},
+			}{v: validator.New(), userID: 1, amount: money.MustParse("250"), performedBy: "yusuf"},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "amount > available balance",
+			setupRepo: func(r *MockRepo) {},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = &account.Account{
+					ID: 1, UserID: 1, Status: account.StatusActive, Balance: money.MustParse("100"),
+					HeldAmount: money.MustParse("60"), AvailableBalance: money.MustParse("40"),
+				}
+			},
+			input: struct {
+				v           *validator.Validator
+				userID      int64
+				amount      money.Amount
+				performedBy string
+			}{v: validator.New(), userID: 1, amount: money.MustParse("50"), performedBy: "yusuf"},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "account not active",
+			setupRepo: func(r *MockRepo) {},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = &account.Account{
+					ID: 1, UserID: 1, Status: account.StatusFrozen, Balance: money.MustParse("100"),
+				}
+			},
+			input: struct {
+				v           *validator.Validator
+				userID      int64
+				amount      money.Amount
+				performedBy string
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "GetAccount failure",
+			setupRepo: func(r *MockRepo) {},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountErr = user.ErrNoRecord
+			},
+			input: struct {
+				v           *validator.Validator
+				userID      int64
+				amount      money.Amount
+				performedBy string
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
 			expectedErr: user.ErrNoRecord,
 		},
 		{
@@ -290,52 +458,64 @@ func TestWithdraw(t *testing.T) {
 			setupRepo: func(r *MockRepo) {
 				r.InsertErr = errors.New("db Insert error")
 			},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 100, performedBy: "yusuf"},
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
 			expectedErr: errors.New("db Insert error"),
 		},
 		{
-			name:      "UpdateUser failure",
-			setupRepo: func(r *MockRepo) {},
-			setupUserService: func(us *MockUserService) {
-				us.GetUserResult = mockUser
-				us.UpdateUserErr = errors.New("db UpdateUser error")
+			name: "balance changed before the posting",
+			setupRepo: func(r *MockRepo) {
+				r.InsertErr = ledger.ErrInsufficientFunds
+			},
+			setupAccountService: func(as *MockAccountService) {
+				as.GetAccountResult = mockAccount
 			},
 			input: struct {
 				v           *validator.Validator
 				userID      int64
-				amount      float64
+				amount      money.Amount
 				performedBy string
-			}{v: validator.New(), userID: 1, amount: 100, performedBy: "yusuf"},
-			expectedErr: errors.New("db UpdateUser error"),
+			}{v: validator.New(), userID: 1, amount: money.MustParse("100"), performedBy: "yusuf"},
+			expectedErr: validator.ErrFailedValidation,
 		},
 	}
 
-	resetUser := func(u *user.User) {
-		u.AccountBalance = 100
+	resetAccount := func(a *account.Account) {
+		a.Balance = money.MustParse("100")
+		a.OverdraftLimit = 0
 	}
 	for _, tc := range tests {
-		resetUser(mockUser)
+		resetAccount(mockAccount)
 		t.Run(tc.name, func(t *testing.T) {
-			repo := &MockRepo{}
-			userService := &MockUserService{}
+			repo := &MockRepo{Account: mockAccount}
+			accountService := &MockAccountService{}
 			tc.setupRepo(repo)
-			tc.setupUserService(userService)
+			tc.setupAccountService(accountService)
+			limits := &MockLimits{}
+			if tc.setupLimits != nil {
+				tc.setupLimits(limits)
+			}
+			fraudSvc := &MockFraud{}
+			if tc.setupFraud != nil {
+				tc.setupFraud(fraudSvc)
+			}
 
 			svc := Service{
-				Repo:        repo,
-				UserService: userService,
+				Repo:           repo,
+				AccountService: accountService,
+				Limits:         limits,
+				Fraud:          fraudSvc,
 			}
 
 			transaction, gotErr := svc.Withdraw(
-				tc.input.v, tc.input.userID, tc.input.amount, tc.input.performedBy,
+				tc.input.v, tc.input.userID, "", tc.input.amount, tc.input.performedBy, "", "",
 			)
 
 			if tc.expectedErr != nil {
@@ -357,14 +537,74 @@ func TestWithdraw(t *testing.T) {
 
 			if transaction.Amount != tc.input.amount {
 				t.Errorf(
-					"expected transaction amount=%f, got amount=%f", tc.input.amount,
+					"expected transaction amount=%v, got amount=%v", tc.input.amount,
 					transaction.Amount,
 				)
 			}
-			if mockUser.AccountBalance != 0 {
+			if mockAccount.Balance != tc.wantBalance {
+				t.Errorf(
+					"expected account balance=%v, got account balance=%v", tc.wantBalance,
+					mockAccount.Balance,
+				)
+			}
+		})
+	}
+}
+
+func TestMemoAndReference(t *testing.T) {
+	tests := []struct {
+		name        string
+		memo        string
+		reference   string
+		expectedErr error
+		wantErrKey  string
+	}{
+		{name: "both given", memo: "cash from the shop", reference: "SLIP-2231"},
+		{name: "neither given"},
+		{
+			name:        "memo too long",
+			memo:        strings.Repeat("a", 201),
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "memo",
+		},
+		{
+			name:        "reference too long",
+			reference:   strings.Repeat("a", 36),
+			expectedErr: validator.ErrFailedValidation,
+			wantErrKey:  "reference",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &account.Account{
+				ID: 1, UserID: 1, Status: account.StatusActive, Balance: money.MustParse("100"),
+				AvailableBalance: money.MustParse("100"),
+			}
+			svc := Service{
+				Repo:           &MockRepo{Account: a},
+				AccountService: &MockAccountService{GetAccountResult: a},
+				Limits:         &MockLimits{},
+			}
+
+			v := validator.New()
+			got, gotErr := svc.Withdraw(
+				v, 1, "", money.MustParse("10"), "yusuf", tc.memo, tc.reference,
+			)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				if _, ok := v.Errors[tc.wantErrKey]; !ok {
+					t.Errorf("expected a %q error, got %v", tc.wantErrKey, v.Errors)
+				}
+				return
+			}
+
+			if got.Memo != tc.memo || got.Reference != tc.reference {
 				t.Errorf(
-					"expected user account balance=%f, got account balance=%f", transaction.Amount,
-					user.AnonymousUser.AccountBalance,
+					"expected memo %q and reference %q, got %q and %q", tc.memo, tc.reference,
+					got.Memo, got.Reference,
 				)
 			}
 		})
@@ -3,23 +3,119 @@ package transfer
 import (
 	"time"
 
-	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
+const (
+	KindTransfer = "TRANSFER"
+	// sent back by the recipient
+	KindRefund = "REFUND"
+	// sent back by staff, for payments made by mistake
+	KindReversal = "REVERSAL"
+)
+
+const (
+	StatusCompleted         = "COMPLETED"
+	StatusPartiallyReversed = "PARTIALLY_REVERSED"
+	StatusReversed          = "REVERSED"
+)
+
+const (
+	maxMemoLength      = 200
+	maxReferenceLength = 35
+)
+
+// Transfer moves Amount out of the from account in its currency and pays ToAmount into the to
+// account in its own. the two are the same unless the accounts are held in different currencies, in
+// which case the amount is converted at ExchangeRate and the bank keeps SpreadAmount, FXSpread of it.
+// refunds and reversals are transfers going the other way, linked to the one they send back by
+// ReversalOf, and ReversedAmount is how much of the ToAmount of a transfer has been sent back.
+// PayeeID is the saved payee the transfer was made to, if any, and FirstPayment flags the first
+// transfer to it. PaymentRequestID is the payment request the transfer paid, if any. Memo and
+// Reference are what the sender says about it, and Category is the category the user it is read
+// for filed it under
 type Transfer struct {
-	ID         int64
-	CreatedAt  time.Time
-	FromUserID int64
-	ToUserID   int64
-	Amount     float64
+	ID               int64
+	CreatedAt        time.Time
+	FromUserID       int64
+	FromAccountID    int64
+	ToUserID         int64
+	ToAccountID      int64
+	Amount           money.Amount
+	Currency         string
+	ToAmount         money.Amount
+	ToCurrency       string
+	ExchangeRate     fx.Decimal
+	FXSpread         fx.Decimal
+	SpreadAmount     money.Amount
+	Kind             string
+	Status           string
+	ReversalOf       *int64
+	ReversedBy       *int64
+	Reason           string
+	ReversedAmount   money.Amount
+	PayeeID          *int64
+	FirstPayment     bool
+	PaymentRequestID *int64
+	Memo             string
+	Reference        string
+	Category         string
 }
 
-func ValidateTransfer(v *validator.Validator, transfer *Transfer, fromUser *user.User) {
-	v.CheckAddError(transfer.FromUserID != transfer.ToUserID, "to account", "cannot be your account")
+// Payment is one of the transfers made together by TransferAll, to the recipient named by account
+// number or email. Transfer is set once it is made, and Errors holds the reasons it was refused
+type Payment struct {
+	ToAccountNumber string
+	ToUserEmail     string
+	Amount          money.Amount
+	Memo            string
+	Reference       string
+	Transfer        *Transfer
+	Errors          map[string]string
+}
+
+func ValidateTransfer(
+	v *validator.Validator, transfer *Transfer, fromAccount, toAccount *account.Account,
+) {
+	v.CheckAddError(
+		transfer.FromAccountID != transfer.ToAccountID, "to account", "cannot be the from account",
+	)
 	v.CheckAddError(transfer.Amount != 0, "amount", "must be given")
 	v.CheckAddError(transfer.Amount > 0, "amount", "must be greater than 0")
+	v.CheckAddError(fromAccount.IsActive(), "from account", "is not active")
+	// a frozen account can still be paid into
+	v.CheckAddError(toAccount.CanReceive(), "to account", "is closed")
+	v.CheckAddError(
+		fromAccount.Spendable() >= transfer.Amount, "account balance", "insufficient funds",
+	)
+	v.CheckAddError(
+		len(transfer.Memo) <= maxMemoLength, "memo", "must not be more than 200 bytes long",
+	)
+	v.CheckAddError(
+		len(transfer.Reference) <= maxReferenceLength, "reference",
+		"must not be more than 35 bytes long",
+	)
+}
+
+// RemainingAmount is how much of what the recipient got can still be sent back
+func (t *Transfer) RemainingAmount() money.Amount {
+	return t.ToAmount - t.ReversedAmount
+}
+
+func ValidateReversal(v *validator.Validator, original, reversal *Transfer) {
+	v.CheckAddError(
+		original.Kind == KindTransfer, "transfer", "refunds and reversals cannot be sent back",
+	)
+	v.CheckAddError(original.Status != StatusReversed, "transfer", "already reversed in full")
+	v.CheckAddError(reversal.Amount > 0, "amount", "must be greater than 0")
 	v.CheckAddError(
-		fromUser.AccountBalance >= transfer.Amount, "account balance", "insufficient funds",
+		reversal.Amount <= original.RemainingAmount(), "amount",
+		"cannot be more than the "+original.RemainingAmount().String()+" left to send back",
 	)
+	if reversal.Kind == KindReversal {
+		v.CheckAddError(reversal.Reason != "", "reason", "must be given")
+	}
 }
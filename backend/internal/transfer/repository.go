@@ -3,64 +3,414 @@ package transfer
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/lib/pq"
 )
 
+// ErrRequestNotPending is returned paying a payment request that has been paid, declined or has
+// expired since it was read
+var ErrRequestNotPending = errors.New("payment request not pending")
+
 type Repository struct {
 	DB *sql.DB
 }
 
-func (r *Repository) Insert(transfer *Transfer) error {
+// transferHistory is how the history of a user's transfers is queried. the counterparty is the
+// other side of a transfer, by the number of its account or the email of its user
+var transferHistory = history.Table{
+	ID:        "id",
+	CreatedAt: "created_at",
+	Amount:    "amount",
+	Status:    "status",
+	Statuses:  []string{StatusCompleted, StatusPartiallyReversed, StatusReversed},
+	Counterparty: `
+		CASE WHEN from_user_id = $1 THEN to_account_id ELSE from_account_id END IN (
+			SELECT accounts.id
+			FROM accounts
+			INNER JOIN users ON users.id = accounts.user_id
+			WHERE accounts.number = %[1]s OR users.email = %[1]s::citext
+		)
+	`,
+	Category: `
+		id IN (
+			SELECT transfer_id
+			FROM transfer_categories
+			INNER JOIN categories ON categories.id = transfer_categories.category_id
+			WHERE transfer_categories.user_id = $1 AND categories.name = %[1]s::citext
+		)
+	`,
+}
+
+const selectTransfers = `
+	SELECT id, created_at, from_user_id, from_account_id, to_user_id, to_account_id, amount,
+		currency, to_amount, to_currency, exchange_rate, fx_spread, spread_amount, kind, status,
+		reversal_of, reversed_by, reason, reversed_amount, payee_id, first_payment,
+		payment_request_id, memo, reference
+	FROM transfers
+`
+
+// InsertTx posts the entry that moves the money and records the transfer in one database
+// transaction, so the debit, the credit and the record are either all there or none of them is. a
+// transfer to a payee marks the payee paid, and is flagged as the first payment if it never was. a
+// transfer paying a payment request marks it paid, and nothing is saved if it is no longer pending.
+// the transfer is recorded as an event too. the checks are run in the transaction once the
+// transfer has its ID, before the money moves, and nothing is saved if one fails
+func (r *Repository) InsertTx(
+	transfer *Transfer, entry *ledger.Entry, checks ...dbtx.Func,
+) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		return postTx(ctx, tx, transfer, entry, checks)
+	})
+}
+
+// InsertAllTx is InsertTx for many transfers, the entry of each at the same index, in one database
+// transaction. either all of them are made or none is. the checks are run once, after all of them
+func (r *Repository) InsertAllTx(
+	transfers []*Transfer, entries []*ledger.Entry, checks ...dbtx.Func,
+) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		for i, transfer := range transfers {
+			err := postTx(ctx, tx, transfer, entries[i], nil)
+			if err != nil {
+				return err
+			}
+		}
+		for _, check := range checks {
+			err := check(ctx, tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// postTx records the transfer, runs the checks and posts the entry in the transaction
+func postTx(
+	ctx context.Context, tx *sql.Tx, transfer *Transfer, entry *ledger.Entry, checks []dbtx.Func,
+) error {
+	transfer.FirstPayment = false
+	if transfer.PayeeID != nil {
+		query := `
+			UPDATE payees
+			SET first_paid_at = $1
+			WHERE id = $2 AND first_paid_at IS NULL
+		`
+		result, err := tx.ExecContext(ctx, query, transfer.CreatedAt, *transfer.PayeeID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		transfer.FirstPayment = rowsAffected == 1
+	}
+
+	if transfer.PaymentRequestID != nil {
+		query := `
+			UPDATE payment_requests
+			SET status = 'PAID', responded_at = $1, version = version + 1
+			WHERE id = $2 AND status = 'PENDING' AND expires_at > $1
+		`
+		result, err := tx.ExecContext(ctx, query, transfer.CreatedAt, *transfer.PaymentRequestID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrRequestNotPending
+		}
+	}
+
+	err := insertTx(ctx, tx, transfer)
+	if err != nil {
+		return err
+	}
+
+	for _, check := range checks {
+		err = check(ctx, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ledger.PostInTx(ctx, tx, entry)
+	if err != nil {
+		return err
+	}
+
+	return recordCompletedTx(ctx, tx, transfer)
+}
+
+// ReverseTx sends money back on the transfer with the given ID. the transfer is locked first, then
+// prepare is given it and how much of its Amount has already been sent back, and fills in the
+// reversal and returns the entry for it. the entry, the reversal and what has been sent back of the
+// transfer are saved together, with the events of both. prepare can be called more than once if
+// the transaction is retried
+func (r *Repository) ReverseTx(
+	transferID int64, reversal *Transfer,
+	prepare func(original *Transfer, returned money.Amount) (*ledger.Entry, error),
+) error {
+	return dbtx.Run(r.DB, func(ctx context.Context, tx *sql.Tx) error {
+		query := selectTransfers + `
+			WHERE id = $1
+			FOR UPDATE
+		`
+		original, err := scanTransfer(tx.QueryRowContext(ctx, query, transferID))
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return user.ErrNoRecord
+			default:
+				return err
+			}
+		}
+
+		// the reversals go the other way, what they paid in is what the sender has got back
+		query = `
+			SELECT COALESCE(SUM(to_amount), 0)
+			FROM transfers
+			WHERE reversal_of = $1
+		`
+		var returned money.Amount
+		err = tx.QueryRowContext(ctx, query, transferID).Scan(&returned)
+		if err != nil {
+			return err
+		}
+
+		entry, err := prepare(original, returned)
+		if err != nil {
+			return err
+		}
+
+		err = ledger.PostInTx(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
+
+		err = insertTx(ctx, tx, reversal)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE transfers
+			SET reversed_amount = $1, status = $2
+			WHERE id = $3
+		`
+		_, err = tx.ExecContext(ctx, query, original.ReversedAmount, original.Status, original.ID)
+		if err != nil {
+			return err
+		}
+
+		err = recordCompletedTx(ctx, tx, reversal)
+		if err != nil {
+			return err
+		}
+		e, err := event.New(original.FromUserID, event.TransferReversed{
+			Transfer:   eventTransfer(original),
+			ReversalID: reversal.ID,
+			Status:     original.Status,
+		})
+		if err != nil {
+			return err
+		}
+		return event.RecordInTx(ctx, tx, e)
+	})
+}
+
+// recordCompletedTx records that the transfer was made, as an event of its sender
+func recordCompletedTx(ctx context.Context, tx *sql.Tx, transfer *Transfer) error {
+	e, err := event.New(
+		transfer.FromUserID, event.TransferCompleted{Transfer: eventTransfer(transfer)},
+	)
+	if err != nil {
+		return err
+	}
+
+	return event.RecordInTx(ctx, tx, e)
+}
+
+func eventTransfer(transfer *Transfer) event.Transfer {
+	return event.Transfer{
+		TransferID:    transfer.ID,
+		Kind:          transfer.Kind,
+		FromUserID:    transfer.FromUserID,
+		FromAccountID: transfer.FromAccountID,
+		ToUserID:      transfer.ToUserID,
+		ToAccountID:   transfer.ToAccountID,
+		Amount:        transfer.Amount,
+		Currency:      transfer.Currency,
+		ToAmount:      transfer.ToAmount,
+		ToCurrency:    transfer.ToCurrency,
+	}
+}
+
+func insertTx(ctx context.Context, tx *sql.Tx, transfer *Transfer) error {
 	query := `
-		INSERT INTO transfers (from_user_id, to_user_id, amount)
-		VALUES ($1, $2, $3)
-		RETURNING id
+		INSERT INTO transfers
+			(from_user_id, from_account_id, to_user_id, to_account_id, amount, currency,
+			to_amount, to_currency, exchange_rate, fx_spread, spread_amount, kind, status,
+			reversal_of, reversed_by, reason, payee_id, first_payment, payment_request_id, memo,
+			reference)
+		VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+			$20, $21
+		)
+		RETURNING id, created_at
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	return r.DB.QueryRowContext(
+	return tx.QueryRowContext(
 		ctx, query,
 		transfer.FromUserID,
+		transfer.FromAccountID,
 		transfer.ToUserID,
+		transfer.ToAccountID,
 		transfer.Amount,
-	).Scan(&transfer.ID)
+		transfer.Currency,
+		transfer.ToAmount,
+		transfer.ToCurrency,
+		transfer.ExchangeRate,
+		transfer.FXSpread,
+		transfer.SpreadAmount,
+		transfer.Kind,
+		transfer.Status,
+		transfer.ReversalOf,
+		transfer.ReversedBy,
+		transfer.Reason,
+		transfer.PayeeID,
+		transfer.FirstPayment,
+		transfer.PaymentRequestID,
+		transfer.Memo,
+		transfer.Reference,
+	).Scan(&transfer.ID, &transfer.CreatedAt)
 }
 
-func (r *Repository) GetAllUserTransfers(userID int64) ([]*Transfer, error) {
-	query := `
-		SELECT id, created_at, from_user_id, to_user_id, amount
-		FROM transfers
-		WHERE from_user_id = $1 OR to_user_id = $1
+// GetAllUserTransfers gets the page of the transfers the user sent or received that the query asks
+// for, each with the category the user filed it under
+func (r *Repository) GetAllUserTransfers(
+	userID int64, q *history.Query,
+) ([]*Transfer, *history.Page, error) {
+	where := `
+		WHERE (from_user_id = $1 OR to_user_id = $1)
 	`
+	filter, args := transferHistory.Filter(q, []any{userID})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := r.DB.QueryContext(ctx, query, userID)
+	page := &history.Page{}
+	err := r.DB.QueryRowContext(
+		ctx, "SELECT COUNT(*) FROM transfers"+where+filter, args...,
+	).Scan(&page.Total)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	seek, orderBy, args := transferHistory.Seek(q, args)
+	rows, err := r.DB.QueryContext(ctx, selectTransfers+where+filter+seek+orderBy, args...)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer rows.Close()
 	var transfers []*Transfer
 	for rows.Next() {
-		transfer := &Transfer{}
-		err = rows.Scan(
-			&transfer.ID,
-			&transfer.CreatedAt,
-			&transfer.FromUserID,
-			&transfer.ToUserID,
-			&transfer.Amount,
-		)
+		transfer, err := scanTransfer(rows)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		transfers = append(transfers, transfer)
 	}
 	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if q.More(len(transfers)) {
+		transfers = transfers[:q.PageSize]
+		last := transfers[len(transfers)-1]
+		page.NextCursor = q.NextCursor(last.CreatedAt, last.Amount, last.ID)
+	}
+
+	// the categories are the user's own, the other side of a transfer may have filed it elsewhere
+	transferIDs := make([]int64, len(transfers))
+	for i, transfer := range transfers {
+		transferIDs[i] = transfer.ID
+	}
+	query := `
+		SELECT transfer_id, categories.name
+		FROM transfer_categories
+		INNER JOIN categories ON categories.id = transfer_categories.category_id
+		WHERE transfer_categories.user_id = $1 AND transfer_id = ANY($2)
+	`
+	rows, err = r.DB.QueryContext(ctx, query, userID, pq.Array(transferIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	categories := make(map[int64]string)
+	for rows.Next() {
+		var transferID int64
+		var name string
+		err = rows.Scan(&transferID, &name)
+		if err != nil {
+			return nil, nil, err
+		}
+		categories[transferID] = name
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, transfer := range transfers {
+		transfer.Category = categories[transfer.ID]
+	}
+
+	return transfers, page, nil
+}
+
+// scanTransfer scans a row of selectTransfers, from either a *sql.Row or *sql.Rows
+func scanTransfer(row interface{ Scan(dest ...any) error }) (*Transfer, error) {
+	transfer := &Transfer{}
+	err := row.Scan(
+		&transfer.ID,
+		&transfer.CreatedAt,
+		&transfer.FromUserID,
+		&transfer.FromAccountID,
+		&transfer.ToUserID,
+		&transfer.ToAccountID,
+		&transfer.Amount,
+		&transfer.Currency,
+		&transfer.ToAmount,
+		&transfer.ToCurrency,
+		&transfer.ExchangeRate,
+		&transfer.FXSpread,
+		&transfer.SpreadAmount,
+		&transfer.Kind,
+		&transfer.Status,
+		&transfer.ReversalOf,
+		&transfer.ReversedBy,
+		&transfer.Reason,
+		&transfer.ReversedAmount,
+		&transfer.PayeeID,
+		&transfer.FirstPayment,
+		&transfer.PaymentRequestID,
+		&transfer.Memo,
+		&transfer.Reference,
+	)
+	if err != nil {
 		return nil, err
 	}
 
-	return transfers, nil
+	return transfer, nil
 }
@@ -1,63 +1,669 @@
 package transfer
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+	"strings"
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/payee"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
 
 type TransferRepo interface {
-	Insert(transfer *Transfer) error
-	GetAllUserTransfers(userID int64) ([]*Transfer, error)
+	InsertTx(transfer *Transfer, entry *ledger.Entry, checks ...dbtx.Func) error
+	InsertAllTx(transfers []*Transfer, entries []*ledger.Entry, checks ...dbtx.Func) error
+	ReverseTx(
+		transferID int64, reversal *Transfer,
+		prepare func(original *Transfer, returned money.Amount) (*ledger.Entry, error),
+	) error
+	GetAllUserTransfers(userID int64, q *history.Query) ([]*Transfer, *history.Page, error)
 }
 
 type UserService interface {
-	TransferMoney(fromUser, toUser *user.User, amount float64) (*user.User, error)
+	GetUser(userID int64) (*user.User, error)
 	GetUserByEmail(email string) (*user.User, error)
 }
 
+type AccountService interface {
+	GetAccount(accountID int64) (*account.Account, error)
+	GetAccountByNumber(number string) (*account.Account, error)
+	GetUserAccount(userID int64, number string) (*account.Account, error)
+}
+
+type FX interface {
+	GetRate(fromCurrency, toCurrency string) (*fx.Rate, error)
+}
+
+// Limits checks the user can spend the amount, CheckInTx again in the database transaction it is
+// spent in
+type Limits interface {
+	Check(v *validator.Validator, userID int64, currency string, amount money.Amount) error
+	CheckInTx(
+		v *validator.Validator, userID int64, currency string, amount money.Amount,
+	) dbtx.Func
+}
+
+type Payees interface {
+	GetPayee(payeeID, userID int64) (*payee.Payee, error)
+}
+
+type Fraud interface {
+	Screen(v *validator.Validator, op *fraud.Operation) error
+}
+
+// Service makes transfers. when Fraud is set each transfer, batch rows included, is screened by it
+// before it is made, after the limits. Unattended is for transfers no one waits on, like batch rows
+// and standing orders, a transfer screening would hold for review is refused instead
 type Service struct {
-	Repo        TransferRepo
-	UserService UserService
+	Repo           TransferRepo
+	UserService    UserService
+	AccountService AccountService
+	FX             FX
+	Limits         Limits
+	Payees         Payees
+	Fraud          Fraud
+	Unattended     bool
 }
 
+// TransferMoney moves the amount from one of the sender's accounts, their primary account when no
+// number is given, to the recipient. the recipient is named by account number, or by email in which
+// case the money goes to their primary account. the amount is in the currency of the sender's
+// account and is converted at the current rate if the recipient's account is in another currency.
+// the memo and reference are optional and both sides see them
 func (s *Service) TransferMoney(
-	v *validator.Validator, fromUser *user.User, toUserEmail string, amount float64,
+	v *validator.Validator, fromUser *user.User, fromAccountNumber, toAccountNumber,
+	toUserEmail string, amount money.Amount, memo, reference string,
 ) (*Transfer, *user.User, error) {
-	toUser, err := s.UserService.GetUserByEmail(toUserEmail)
+	fromAccount, err := s.fromAccount(v, fromUser.ID, fromAccountNumber)
 	if err != nil {
-		if err == user.ErrNoRecord {
-			v.AddError("recipient email", "email not found")
+		return nil, nil, err
+	}
+
+	toAccount, err := s.RecipientAccount(v, toAccountNumber, toUserEmail)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transfer := newTransfer(fromAccount, toAccount, amount)
+	transfer.Memo, transfer.Reference = memo, reference
+	return s.send(v, fromUser, transfer, fromAccount, toAccount)
+}
+
+// PayPayee moves the amount from one of the sender's accounts, their primary account when no number
+// is given, to the account of one of their saved payees. it is otherwise the same as TransferMoney,
+// and the transfer is flagged if it is the first one to the payee
+func (s *Service) PayPayee(
+	v *validator.Validator, fromUser *user.User, fromAccountNumber string, payeeID int64,
+	amount money.Amount, memo, reference string,
+) (*Transfer, *user.User, error) {
+	fromAccount, err := s.fromAccount(v, fromUser.ID, fromAccountNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p, err := s.Payees.GetPayee(payeeID, fromUser.ID)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("payee", "not found")
 			return nil, nil, validator.ErrFailedValidation
 		}
 		return nil, nil, err
 	}
 
-	transfer := Transfer{
-		CreatedAt:  time.Now(),
-		FromUserID: fromUser.ID,
-		ToUserID:   toUser.ID,
-		Amount:     amount,
+	toAccount, err := s.AccountService.GetAccount(p.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transfer := newTransfer(fromAccount, toAccount, amount)
+	transfer.PayeeID = &p.ID
+	transfer.Memo, transfer.Reference = memo, reference
+	return s.send(v, fromUser, transfer, fromAccount, toAccount)
+}
+
+// PayRequest pays the amount of a payment request into the account it was asked to be paid into,
+// from one of the payer's accounts or their primary account when no number is given. it is
+// otherwise the same as TransferMoney. the request is marked paid together with the transfer, and
+// ErrRequestNotPending is returned, with nothing moved, if it can't be paid any more
+func (s *Service) PayRequest(
+	v *validator.Validator, fromUser *user.User, fromAccountNumber string, requestID,
+	toAccountID int64, amount money.Amount, memo string,
+) (*Transfer, *user.User, error) {
+	fromAccount, err := s.fromAccount(v, fromUser.ID, fromAccountNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toAccount, err := s.AccountService.GetAccount(toAccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transfer := newTransfer(fromAccount, toAccount, amount)
+	transfer.PaymentRequestID = &requestID
+	transfer.Memo = memo
+	return s.send(v, fromUser, transfer, fromAccount, toAccount)
+}
+
+// TransferAll makes the payments from one of the sender's accounts, their primary account when no
+// number is given, all of them or none. each is checked like a transfer made by TransferMoney and
+// gets the reasons it is refused, if any, in its Errors. the balance and the limits are checked for
+// the total, and the reasons the whole lot is refused for are added to v
+func (s *Service) TransferAll(
+	v *validator.Validator, fromUser *user.User, fromAccountNumber string, payments []*Payment,
+) error {
+	fromAccount, err := s.fromAccount(v, fromUser.ID, fromAccountNumber)
+	if err != nil {
+		return err
 	}
 
-	if ValidateTransfer(v, &transfer, fromUser); !v.IsValid() {
+	transfers := make([]*Transfer, 0, len(payments))
+	entries := make([]*ledger.Entry, 0, len(payments))
+	total := money.Amount(0)
+	refused := false
+	for _, p := range payments {
+		pv := validator.New()
+		transfer, err := s.preparePayment(pv, fromAccount, p)
+		if err != nil {
+			if errors.Is(err, validator.ErrFailedValidation) {
+				p.Errors = pv.Errors
+				refused = true
+				continue
+			}
+			return err
+		}
+
+		transfers = append(transfers, transfer)
+		entries = append(entries, transferEntry(transfer))
+		total += transfer.Amount
+	}
+	if refused {
+		return validator.ErrFailedValidation
+	}
+
+	v.CheckAddError(
+		total <= fromAccount.Spendable(), "account balance",
+		"insufficient funds for the total of "+total.String(),
+	)
+	if !v.IsValid() {
+		return validator.ErrFailedValidation
+	}
+
+	err = s.Limits.Check(v, fromAccount.UserID, fromAccount.Currency, total)
+	if err != nil {
+		return err
+	}
+
+	// each row is screened once the batch as a whole can be made. the rows are made together or not
+	// at all, so a row that needs a review refuses the batch and nothing is held for it
+	for i, transfer := range transfers {
+		pv := validator.New()
+		err = s.screen(pv, transfer, fromAccount, true)
+		if err != nil {
+			if errors.Is(err, validator.ErrFailedValidation) {
+				payments[i].Errors = pv.Errors
+				refused = true
+				continue
+			}
+			return err
+		}
+	}
+	if refused {
+		return validator.ErrFailedValidation
+	}
+
+	err = s.Repo.InsertAllTx(
+		transfers, entries,
+		s.Limits.CheckInTx(v, fromAccount.UserID, fromAccount.Currency, total),
+	)
+	if err != nil {
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			v.AddError("account balance", "insufficient funds")
+			return validator.ErrFailedValidation
+		}
+		return err
+	}
+
+	for i, p := range payments {
+		p.Transfer = transfers[i]
+	}
+
+	return nil
+}
+
+// preparePayment is the transfer for one of the payments of TransferAll, checked and converted
+func (s *Service) preparePayment(
+	v *validator.Validator, fromAccount *account.Account, p *Payment,
+) (*Transfer, error) {
+	toAccount, err := s.RecipientAccount(v, p.ToAccountNumber, p.ToUserEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := newTransfer(fromAccount, toAccount, p.Amount)
+	transfer.Memo, transfer.Reference = p.Memo, p.Reference
+	if ValidateTransfer(v, transfer, fromAccount, toAccount); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	if transfer.Currency != transfer.ToCurrency {
+		err = s.convert(v, transfer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return transfer, nil
+}
+
+func (s *Service) fromAccount(
+	v *validator.Validator, userID int64, number string,
+) (*account.Account, error) {
+	fromAccount, err := s.AccountService.GetUserAccount(userID, number)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("from account", "not found")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+	return fromAccount, nil
+}
+
+// newTransfer is a transfer of the amount between the two accounts, not yet converted
+func newTransfer(fromAccount, toAccount *account.Account, amount money.Amount) *Transfer {
+	return &Transfer{
+		CreatedAt:     time.Now(),
+		FromUserID:    fromAccount.UserID,
+		FromAccountID: fromAccount.ID,
+		ToUserID:      toAccount.UserID,
+		ToAccountID:   toAccount.ID,
+		Amount:        amount,
+		Currency:      fromAccount.Currency,
+		ToAmount:      amount,
+		ToCurrency:    toAccount.Currency,
+		ExchangeRate:  fx.MustParseDecimal("1"),
+		Kind:          KindTransfer,
+		Status:        StatusCompleted,
+	}
+}
+
+// send makes the transfer between the two accounts once they are known
+func (s *Service) send(
+	v *validator.Validator, fromUser *user.User, transfer *Transfer,
+	fromAccount, toAccount *account.Account,
+) (*Transfer, *user.User, error) {
+	if ValidateTransfer(v, transfer, fromAccount, toAccount); !v.IsValid() {
 		return nil, nil, validator.ErrFailedValidation
 	}
 
-	fromUser, err = s.UserService.TransferMoney(fromUser, toUser, transfer.Amount)
+	err := s.Limits.Check(v, transfer.FromUserID, transfer.Currency, transfer.Amount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.screen(v, transfer, fromAccount, s.Unattended)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	err = s.Repo.Insert(&transfer)
+	err = s.post(v, transfer)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return &transfer, fromUser, nil
+	// return the updated state of the sender account
+	fromUser, err = s.UserService.GetUser(fromUser.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return transfer, fromUser, nil
+}
+
+// MakeApproved makes the transfer of a screening held for review that staff approved, checked again
+// as things are now. the approval is saved, and the money held for the transfer released, in the
+// database transaction the transfer is made in, so the screening stays waiting if it can't be made
+func (s *Service) MakeApproved(v *validator.Validator, screening *fraud.Screening) error {
+	fromAccount, err := s.AccountService.GetAccount(screening.AccountID)
+	if err != nil {
+		return err
+	}
+	toAccount, err := s.AccountService.GetAccount(*screening.ToAccountID)
+	if err != nil {
+		return err
+	}
+	// the hold is released as the transfer is made, what it sets aside is there to pay for it
+	fromAccount.AvailableBalance += screening.Amount
+
+	transfer := newTransfer(fromAccount, toAccount, screening.Amount)
+	transfer.Memo, transfer.Reference = screening.Memo, screening.Reference
+	transfer.PayeeID, transfer.PaymentRequestID = screening.PayeeID, screening.PaymentRequestID
+	if ValidateTransfer(v, transfer, fromAccount, toAccount); !v.IsValid() {
+		return validator.ErrFailedValidation
+	}
+
+	err = s.Limits.Check(v, transfer.FromUserID, transfer.Currency, transfer.Amount)
+	if err != nil {
+		return err
+	}
+
+	err = s.post(v, transfer, func(ctx context.Context, tx *sql.Tx) error {
+		screening.TransferID = &transfer.ID
+		return fraud.ApproveInTx(ctx, tx, screening)
+	})
+	if err != nil {
+		// paid or declined while the transfer was held
+		if errors.Is(err, ErrRequestNotPending) {
+			v.AddError("payment request", "is no longer pending")
+			return validator.ErrFailedValidation
+		}
+		return err
+	}
+
+	return nil
+}
+
+// post converts the transfer if it needs to be and makes it, running the checks and the limits in
+// the database transaction it is made in
+func (s *Service) post(v *validator.Validator, transfer *Transfer, checks ...dbtx.Func) error {
+	if transfer.Currency != transfer.ToCurrency {
+		err := s.convert(v, transfer)
+		if err != nil {
+			return err
+		}
+	}
+
+	checks = append(checks, s.Limits.CheckInTx(
+		v, transfer.FromUserID, transfer.Currency, transfer.Amount,
+	))
+	err := s.Repo.InsertTx(transfer, transferEntry(transfer), checks...)
+	if err != nil {
+		// the balance can change between the validation and the posting
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			v.AddError("account balance", "insufficient funds")
+			return validator.ErrFailedValidation
+		}
+		return err
+	}
+
+	return nil
 }
 
-func (s *Service) GetAllUserTransfers(userID int64) ([]*Transfer, error) {
-	return s.Repo.GetAllUserTransfers(userID)
+// screen checks the transfer from the account with Fraud, when it is set. noReview refuses a
+// transfer that would be held for review
+func (s *Service) screen(
+	v *validator.Validator, transfer *Transfer, fromAccount *account.Account, noReview bool,
+) error {
+	if s.Fraud == nil {
+		return nil
+	}
+
+	return s.Fraud.Screen(v, &fraud.Operation{
+		Kind:             fraud.KindTransfer,
+		UserID:           transfer.FromUserID,
+		AccountID:        fromAccount.ID,
+		AccountCreatedAt: fromAccount.CreatedAt,
+		Balance:          fromAccount.AvailableBalance,
+		ToAccountID:      transfer.ToAccountID,
+		ToUserID:         transfer.ToUserID,
+		Amount:           transfer.Amount,
+		Currency:         transfer.Currency,
+		NoReview:         noReview,
+		Memo:             transfer.Memo,
+		Reference:        transfer.Reference,
+		PayeeID:          transfer.PayeeID,
+		PaymentRequestID: transfer.PaymentRequestID,
+	})
+}
+
+// convert works out what the recipient gets in their currency at the current rate
+func (s *Service) convert(v *validator.Validator, transfer *Transfer) error {
+	rate, err := s.FX.GetRate(transfer.Currency, transfer.ToCurrency)
+	if err != nil {
+		if errors.Is(err, fx.ErrNoRate) {
+			v.AddError(
+				"currency", "no exchange rate from "+transfer.Currency+" to "+transfer.ToCurrency,
+			)
+			return validator.ErrFailedValidation
+		}
+		return err
+	}
+
+	transfer.ToAmount, transfer.SpreadAmount = rate.Convert(transfer.Amount)
+	transfer.ExchangeRate = rate.Rate
+	transfer.FXSpread = rate.Spread
+
+	if transfer.ToAmount <= 0 {
+		v.AddError("amount", "too small to convert")
+		return validator.ErrFailedValidation
+	}
+
+	return nil
+}
+
+// transferEntry is the ledger entry for the transfer. when the amount is converted the bank's FX
+// account takes it in one currency and pays it out in the other, less the spread the bank keeps, so
+// that each currency balances on its own
+func transferEntry(transfer *Transfer) *ledger.Entry {
+	entry := ledger.NewEntry(
+		strings.ToLower(transfer.Kind),
+		ledger.AccountPosting(transfer.FromAccountID, transfer.Currency, transfer.Amount.Neg()),
+		ledger.AccountPosting(transfer.ToAccountID, transfer.ToCurrency, transfer.ToAmount),
+	)
+	if transfer.Currency == transfer.ToCurrency {
+		return entry
+	}
+
+	entry.Postings = append(entry.Postings,
+		ledger.SystemPosting(ledger.AccountFX, transfer.Currency, transfer.Amount),
+		ledger.SystemPosting(
+			ledger.AccountFX, transfer.ToCurrency, -(transfer.ToAmount+transfer.SpreadAmount),
+		),
+	)
+	if transfer.SpreadAmount != 0 {
+		entry.Postings = append(entry.Postings, ledger.SystemPosting(
+			ledger.AccountFXSpread, transfer.ToCurrency, transfer.SpreadAmount,
+		))
+	}
+
+	return entry
+}
+
+// RecipientAccount finds the account the money is going to, by its number if one is given or else
+// the primary account of the user with the email. a number with the wrong check digits is refused
+// before it is looked up, a mistyped number could belong to someone else
+func (s *Service) RecipientAccount(
+	v *validator.Validator, toAccountNumber, toUserEmail string,
+) (*account.Account, error) {
+	if toAccountNumber != "" {
+		if !account.ValidNumber(toAccountNumber) {
+			v.AddError("to account", "invalid account number")
+			return nil, validator.ErrFailedValidation
+		}
+
+		toAccount, err := s.AccountService.GetAccountByNumber(toAccountNumber)
+		if err != nil {
+			if errors.Is(err, user.ErrNoRecord) {
+				v.AddError("to account", "not found")
+				return nil, validator.ErrFailedValidation
+			}
+			return nil, err
+		}
+		return toAccount, nil
+	}
+
+	if toUserEmail == "" {
+		v.AddError("to account", "must be given")
+		return nil, validator.ErrFailedValidation
+	}
+
+	toUser, err := s.UserService.GetUserByEmail(toUserEmail)
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("recipient email", "email not found")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	toAccount, err := s.AccountService.GetUserAccount(toUser.ID, "")
+	if err != nil {
+		if errors.Is(err, user.ErrNoRecord) {
+			v.AddError("recipient email", "has no open account")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	return toAccount, nil
+}
+
+// Refund is the recipient of the transfer sending the amount back to the sender, all that is left
+// of what they got when no amount is given
+func (s *Service) Refund(
+	v *validator.Validator, u *user.User, transferID int64, amount money.Amount, reason string,
+) (*Transfer, error) {
+	return s.reverse(v, KindRefund, u.ID, transferID, amount, reason)
+}
+
+// Reverse is staff sending the amount of the transfer back to the sender, for a payment made by
+// mistake. unlike a refund it does not need the accounts to be active, only open, and needs a reason
+func (s *Service) Reverse(
+	v *validator.Validator, reversedByID, transferID int64, amount money.Amount, reason string,
+) (*Transfer, error) {
+	return s.reverse(v, KindReversal, reversedByID, transferID, amount, reason)
+}
+
+// reverse makes a transfer of the given kind going back the other way on the transfer. the amount
+// is in the currency the recipient got, and the sender gets back the same share of what they sent,
+// so undoing a converted transfer in full also gives back the spread
+func (s *Service) reverse(
+	v *validator.Validator, kind string, reversedByID, transferID int64, amount money.Amount,
+	reason string,
+) (*Transfer, error) {
+	reversal := &Transfer{}
+	err := s.Repo.ReverseTx(transferID, reversal,
+		func(original *Transfer, returned money.Amount) (*ledger.Entry, error) {
+			// to anyone else, someone else's transfer doesn't exist
+			if kind == KindRefund && original.ToUserID != reversedByID {
+				return nil, user.ErrNoRecord
+			}
+
+			*reversal = Transfer{
+				CreatedAt:     time.Now(),
+				FromUserID:    original.ToUserID,
+				FromAccountID: original.ToAccountID,
+				ToUserID:      original.FromUserID,
+				ToAccountID:   original.FromAccountID,
+				Amount:        amount,
+				Currency:      original.ToCurrency,
+				ToCurrency:    original.Currency,
+				ExchangeRate:  fx.MustParseDecimal("1"),
+				Kind:          kind,
+				Status:        StatusCompleted,
+				ReversalOf:    &original.ID,
+				ReversedBy:    &reversedByID,
+				Reason:        reason,
+			}
+			if amount == 0 {
+				reversal.Amount = original.RemainingAmount()
+			}
+
+			if ValidateReversal(v, original, reversal); !v.IsValid() {
+				return nil, validator.ErrFailedValidation
+			}
+
+			err := s.checkReversalAccounts(v, reversal)
+			if err != nil {
+				return nil, err
+			}
+
+			reversal.ToAmount = returnedAmount(original, reversal.Amount, returned)
+			if reversal.Currency != reversal.ToCurrency {
+				reversal.ExchangeRate = fx.NewDecimal(
+					new(big.Rat).Quo(reversal.ToAmount.Rat(), reversal.Amount.Rat()),
+				)
+			}
+
+			original.ReversedAmount += reversal.Amount
+			original.Status = StatusPartiallyReversed
+			if original.RemainingAmount() == 0 {
+				original.Status = StatusReversed
+			}
+
+			return transferEntry(reversal), nil
+		},
+	)
+	if err != nil {
+		// the recipient may have spent the money since
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			v.AddError("account balance", "insufficient funds")
+			return nil, validator.ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	return reversal, nil
+}
+
+// checkReversalAccounts checks the money can leave the account it is going back from and arrive in
+// the one it is going back to
+func (s *Service) checkReversalAccounts(v *validator.Validator, reversal *Transfer) error {
+	fromAccount, err := s.AccountService.GetAccount(reversal.FromAccountID)
+	if err != nil {
+		return err
+	}
+	toAccount, err := s.AccountService.GetAccount(reversal.ToAccountID)
+	if err != nil {
+		return err
+	}
+
+	if reversal.Kind == KindRefund {
+		v.CheckAddError(fromAccount.IsActive(), "from account", "is not active")
+		v.CheckAddError(toAccount.CanReceive(), "to account", "is closed")
+	} else {
+		v.CheckAddError(fromAccount.Status != account.StatusClosed, "from account", "is closed")
+		v.CheckAddError(toAccount.Status != account.StatusClosed, "to account", "is closed")
+	}
+	if !v.IsValid() {
+		return validator.ErrFailedValidation
+	}
+
+	return nil
+}
+
+// returnedAmount is what the sender gets back when amount of what the recipient got is sent back,
+// the same share of what they sent. the last of it is whatever has not been sent back yet, so that
+// the shares, rounded down, can never add up to more or less than the sender sent
+func returnedAmount(original *Transfer, amount, returned money.Amount) money.Amount {
+	if amount == original.RemainingAmount() {
+		return original.Amount - returned
+	}
+
+	share := big.NewRat(int64(original.Amount), int64(original.ToAmount))
+	return amount.Mul(share, money.RoundDown)
+}
+
+// GetAllUserTransfers gets a page of the transfers the user sent or received, the ones the query
+// asks for
+func (s *Service) GetAllUserTransfers(
+	v *validator.Validator, userID int64, q *history.Query,
+) ([]*Transfer, *history.Page, error) {
+	if history.ValidateQuery(v, q, transferHistory); !v.IsValid() {
+		return nil, nil, validator.ErrFailedValidation
+	}
+
+	return s.Repo.GetAllUserTransfers(userID, q)
 }
@@ -1,8 +1,20 @@
 package transfer
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/dbtx"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/payee"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 )
@@ -10,19 +22,84 @@ import (
 // ---MOCKS---
 
 type MockRepo struct {
-	InsertErr error
+	InsertTxErr error
+	Posted      []*ledger.Entry
+
+	// what ReverseTx finds and adds to
+	Transfers []*Transfer
+
+	// the payees that have been paid before
+	PaidPayees map[int64]bool
+}
+
+// InsertTx records the entry so the tests can check what would have been posted with the transfer,
+// and flags the first payment to a payee like the real one
+func (r *MockRepo) InsertTx(transfer *Transfer, entry *ledger.Entry, checks ...dbtx.Func) error {
+	if r.InsertTxErr != nil {
+		return r.InsertTxErr
+	}
+	r.Posted = append(r.Posted, entry)
+	if transfer.PayeeID != nil {
+		transfer.FirstPayment = !r.PaidPayees[*transfer.PayeeID]
+	}
+	return nil
+}
+
+func (r *MockRepo) InsertAllTx(
+	transfers []*Transfer, entries []*ledger.Entry, checks ...dbtx.Func,
+) error {
+	if r.InsertTxErr != nil {
+		return r.InsertTxErr
+	}
+	r.Posted = append(r.Posted, entries...)
+	return nil
+}
+
+// ReverseTx runs prepare on the transfer like the real one, only saving anything if it succeeds
+func (r *MockRepo) ReverseTx(
+	transferID int64, reversal *Transfer,
+	prepare func(original *Transfer, returned money.Amount) (*ledger.Entry, error),
+) error {
+	var original *Transfer
+	returned := money.Amount(0)
+	for _, t := range r.Transfers {
+		if t.ID == transferID {
+			original = t
+		}
+		if t.ReversalOf != nil && *t.ReversalOf == transferID {
+			returned += t.ToAmount
+		}
+	}
+	if original == nil {
+		return user.ErrNoRecord
+	}
+
+	entry, err := prepare(original, returned)
+	if err != nil {
+		return err
+	}
+	if r.InsertTxErr != nil {
+		return r.InsertTxErr
+	}
+
+	reversal.ID = int64(len(r.Transfers) + 1)
+	r.Transfers = append(r.Transfers, reversal)
+	r.Posted = append(r.Posted, entry)
+	return nil
 }
 
-func (r *MockRepo) Insert(transfer *Transfer) error {
-	return r.InsertErr
+func (r *MockRepo) GetAllUserTransfers(
+	userID int64, q *history.Query,
+) ([]*Transfer, *history.Page, error) {
+	return nil, nil, nil
 }
 
 type MockUserService struct {
 	GetUserByEmailResult *user.User
 	GetUserByEmailErr    error
 
-	TransferMoneyResult *user.User
-	TransferMoneyErr    error
+	GetUserResult *user.User
+	GetUserErr    error
 }
 
 func (us *MockUserService) GetUserByEmail(email string) (*user.User, error) {
@@ -32,55 +109,390 @@ func (us *MockUserService) GetUserByEmail(email string) (*user.User, error) {
 	return us.GetUserByEmailResult, nil
 }
 
-func (us *MockUserService) TransferMoney(
-	fromUser, toUser *user.User, amount float64,
-) (*user.User, error) {
-	if us.TransferMoneyErr != nil {
-		return nil, us.TransferMoneyErr
+func (us *MockUserService) GetUser(userID int64) (*user.User, error) {
+	return us.GetUserResult, us.GetUserErr
+}
+
+// MockAccountService looks the accounts it holds up by number or by their user, the first account
+// of a user being their primary account
+type MockAccountService struct {
+	Accounts []*account.Account
+}
+
+func (as *MockAccountService) GetAccount(accountID int64) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.ID == accountID {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (as *MockAccountService) GetAccountByNumber(number string) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.Number == number {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func (as *MockAccountService) GetUserAccount(userID int64, number string) (*account.Account, error) {
+	for _, a := range as.Accounts {
+		if a.UserID == userID && (number == "" || a.Number == number) {
+			return a, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+// MockFX has the one rate it is given, in the direction it is given
+type MockFX struct {
+	Rate *fx.Rate
+	Err  error
+}
+
+func (f *MockFX) GetRate(fromCurrency, toCurrency string) (*fx.Rate, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if f.Rate == nil || f.Rate.BaseCurrency != fromCurrency || f.Rate.QuoteCurrency != toCurrency {
+		return nil, fx.ErrNoRate
+	}
+	return f.Rate, nil
+}
+
+// MockLimits refuses every amount over Max, when one is set, the way the real limits add their error
+type MockLimits struct {
+	Max money.Amount
+	Err error
+}
+
+func (l *MockLimits) Check(
+	v *validator.Validator, userID int64, currency string, amount money.Amount,
+) error {
+	if l.Err != nil {
+		return l.Err
 	}
+	if l.Max != 0 && amount > l.Max {
+		v.AddError("daily limit", "exceeded, "+l.Max.String()+" "+currency+" remaining")
+		return validator.ErrFailedValidation
+	}
+	return nil
+}
 
-	toUser.AccountBalance += amount
-	return us.TransferMoneyResult, us.TransferMoneyErr
+// CheckInTx checks nothing more, the mock repositories don't run the checks
+func (l *MockLimits) CheckInTx(
+	v *validator.Validator, userID int64, currency string, amount money.Amount,
+) dbtx.Func {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	}
+}
+
+// MockFraud returns Err for every operation it screens, and keeps the last one
+type MockFraud struct {
+	Err      error
+	Screened *fraud.Operation
+}
+
+func (f *MockFraud) Screen(v *validator.Validator, op *fraud.Operation) error {
+	f.Screened = op
+	return f.Err
 }
 
 func TestTransferMoney(t *testing.T) {
+	errDB := errors.New("db error")
 	fromUser := &user.User{
-		ID: 1, Name: "yusuf", Email: "a@b.com", AccountBalance: 100,
+		ID: 1, Name: "yusuf", Email: "a@b.com", AccountBalance: money.MustParse("100"),
 	}
 	toUser := &user.User{
-		ID: 2, Name: "mohamed", Email: "b@a.com", AccountBalance: 50,
+		ID: 2, Name: "mohamed", Email: "b@a.com", AccountBalance: money.MustParse("50"),
+	}
+	newAccounts := func() []*account.Account {
+		return []*account.Account{
+			{
+				ID: 1, UserID: 1, Number: "100000000093", Status: account.StatusActive,
+				Currency: "USD", Balance: money.MustParse("100"),
+				AvailableBalance: money.MustParse("100"),
+			},
+			{
+				ID: 2, UserID: 2, Number: "100000000190", Status: account.StatusActive,
+				Currency: "USD", Balance: money.MustParse("50"),
+				AvailableBalance: money.MustParse("50"),
+			},
+			{
+				ID: 3, UserID: 1, Number: "100000000287", Status: account.StatusActive,
+				Currency: "USD", Balance: money.MustParse("0"),
+				AvailableBalance: money.MustParse("0"),
+			},
+			{
+				ID: 4, UserID: 2, Number: "100000000384", Status: account.StatusActive,
+				Currency: "EUR", Balance: money.MustParse("0"),
+				AvailableBalance: money.MustParse("0"),
+			},
+		}
 	}
 
+	type input struct {
+		v                 *validator.Validator
+		fromUser          *user.User
+		fromAccountNumber string
+		toAccountNumber   string
+		toUserEmail       string
+		amount            money.Amount
+		memo              string
+		reference         string
+	}
 	tests := []struct {
-		name         string
-		setupRepo    func(*MockRepo)
-		setupUserSvc func(*MockUserService)
-		input        struct {
-			v           *validator.Validator
-			fromUser    *user.User
-			toUserEmail string
-			amount      float64
-		}
-		finalFrom   float64
-		finalTo     float64
-		expectedErr error
+		name          string
+		setupRepo     func(*MockRepo)
+		setupUserSvc  func(*MockUserService)
+		setupAccounts func([]*account.Account)
+		setupFX       func(*MockFX)
+		setupLimits   func(*MockLimits)
+		setupFraud    func(*MockFraud)
+		input         input
+		finalFrom     money.Amount
+		wantPosted    bool
+		wantFromID    int64
+		wantToID      int64
+		wantToAmount  money.Amount
+		wantSpread    money.Amount
+		expectedErr   error
 	}{
 		{
 			name:      "valid input",
 			setupRepo: func(m *MockRepo) {},
 			setupUserSvc: func(us *MockUserService) {
 				us.GetUserByEmailResult = toUser
-				us.TransferMoneyResult = &user.User{AccountBalance: 90}
+				us.GetUserResult = &user.User{ID: 1, AccountBalance: money.MustParse("90")}
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toUserEmail: toUser.Email,
+				amount: money.MustParse("10"),
+			},
+			finalFrom:    money.MustParse("90"),
+			wantPosted:   true,
+			wantFromID:   1,
+			wantToID:     2,
+			wantToAmount: money.MustParse("10"),
+		},
+		{
+			name:      "between the user's own accounts",
+			setupRepo: func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {
+				us.GetUserResult = &user.User{ID: 1, AccountBalance: money.MustParse("100")}
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, fromAccountNumber: "100000000093",
+				toAccountNumber: "100000000287", amount: money.MustParse("10"),
+			},
+			finalFrom:    money.MustParse("100"),
+			wantPosted:   true,
+			wantFromID:   1,
+			wantToID:     3,
+			wantToAmount: money.MustParse("10"),
+		},
+		{
+			name:      "into another currency",
+			setupRepo: func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {
+				us.GetUserResult = &user.User{ID: 1, AccountBalance: money.MustParse("90")}
+			},
+			setupFX: func(f *MockFX) {
+				f.Rate = &fx.Rate{
+					BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: fx.MustParseDecimal("0.9"),
+					Spread: fx.MustParseDecimal("0.01"),
+				}
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000384",
+				amount: money.MustParse("10"),
+			},
+			finalFrom:    money.MustParse("90"),
+			wantPosted:   true,
+			wantFromID:   1,
+			wantToID:     4,
+			wantToAmount: money.MustParse("8.91"),
+			wantSpread:   money.MustParse("0.09"),
+		},
+		{
+			name:         "no exchange rate",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000384",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "too small to convert",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupFX: func(f *MockFX) {
+				f.Rate = &fx.Rate{
+					BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: fx.MustParseDecimal("0.4"),
+				}
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000384",
+				amount: money.MustParse("0.01"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "FX failure",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupFX: func(f *MockFX) {
+				f.Err = errDB
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000384",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: errDB,
+		},
+		{
+			name:         "same account",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			input: input{
+				v: validator.New(), fromUser: fromUser, fromAccountNumber: "100000000093",
+				toAccountNumber: "100000000093", amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "from account belongs to someone else",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			input: input{
+				v: validator.New(), fromUser: fromUser, fromAccountNumber: "100000000190",
+				toAccountNumber: "100000000287", amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "to account not found",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000009987",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			// the last two digits swapped, it must not get as far as being looked up
+			name:         "to account with bad check digits",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[1].Number = "100000000109"
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000109",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "to account frozen",
+			setupRepo: func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {
+				us.GetUserResult = &user.User{ID: 1, AccountBalance: money.MustParse("90")}
+			},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[1].Status = account.StatusFrozen
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000190",
+				amount: money.MustParse("10"),
+			},
+			finalFrom:    money.MustParse("90"),
+			wantPosted:   true,
+			wantFromID:   1,
+			wantToID:     2,
+			wantToAmount: money.MustParse("10"),
+		},
+		{
+			name:         "to account closed",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[1].Status = account.StatusClosed
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000190",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "from account frozen",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[0].Status = account.StatusFrozen
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000190",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "with a memo and reference",
+			setupRepo: func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {
+				us.GetUserByEmailResult = toUser
+				us.GetUserResult = &user.User{ID: 1, AccountBalance: money.MustParse("90")}
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toUserEmail: toUser.Email,
+				amount: money.MustParse("10"), memo: "rent for march", reference: "INV-0042",
+			},
+			finalFrom:    money.MustParse("90"),
+			wantPosted:   true,
+			wantFromID:   1,
+			wantToID:     2,
+			wantToAmount: money.MustParse("10"),
+		},
+		{
+			name:      "reference too long",
+			setupRepo: func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {
+				us.GetUserByEmailResult = toUser
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toUserEmail: toUser.Email,
+				amount: money.MustParse("10"), reference: strings.Repeat("x", 36),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "memo too long",
+			setupRepo: func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {
+				us.GetUserByEmailResult = toUser
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toUserEmail: toUser.Email,
+				amount: money.MustParse("10"), memo: strings.Repeat("x", 201),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "no recipient",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			input: input{
+				v: validator.New(), fromUser: fromUser, amount: money.MustParse("10"),
 			},
-			input: struct {
-				v           *validator.Validator
-				fromUser    *user.User
-				toUserEmail string
-				amount      float64
-			}{v: validator.New(), fromUser: fromUser, toUserEmail: toUser.Email, amount: 10},
-			finalFrom:   90,
-			finalTo:     60,
-			expectedErr: nil,
+			expectedErr: validator.ErrFailedValidation,
 		},
 		{
 			name:      "insuffient funds",
@@ -88,30 +500,138 @@ func TestTransferMoney(t *testing.T) {
 			setupUserSvc: func(us *MockUserService) {
 				us.GetUserByEmailResult = toUser
 			},
-			input: struct {
-				v           *validator.Validator
-				fromUser    *user.User
-				toUserEmail string
-				amount      float64
-			}{v: validator.New(), fromUser: fromUser, toUserEmail: toUser.Email, amount: 1000},
-			finalFrom:   100,
-			finalTo:     50,
+			input: input{
+				v: validator.New(), fromUser: fromUser, toUserEmail: toUser.Email,
+				amount: money.MustParse("1000"),
+			},
+			finalFrom:   money.MustParse("100"),
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "into the overdraft",
+			setupRepo: func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {
+				us.GetUserResult = &user.User{ID: 1, AccountBalance: money.MustParse("-50")}
+			},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[0].OverdraftLimit = money.MustParse("100")
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000190",
+				amount: money.MustParse("150"),
+			},
+			finalFrom:    money.MustParse("-50"),
+			wantPosted:   true,
+			wantFromID:   1,
+			wantToID:     2,
+			wantToAmount: money.MustParse("150"),
+		},
+		{
+			name:         "past the overdraft",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[0].OverdraftLimit = money.MustParse("100")
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000190",
+				amount: money.MustParse("200.01"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "money on hold",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[0].HeldAmount = money.MustParse("95")
+				accounts[0].AvailableBalance = money.MustParse("5")
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000190",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "over the limit",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupLimits: func(l *MockLimits) {
+				l.Max = money.MustParse("5")
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000190",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "limits failure",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupLimits: func(l *MockLimits) {
+				l.Err = errDB
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000190",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: errDB,
+		},
+		{
+			name:         "held for review",
+			setupRepo:    func(m *MockRepo) {},
+			setupUserSvc: func(us *MockUserService) {},
+			setupFraud: func(f *MockFraud) {
+				f.Err = fraud.ErrHeldForReview
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toAccountNumber: "100000000190",
+				amount: money.MustParse("10"),
+			},
+			expectedErr: fraud.ErrHeldForReview,
+		},
+		{
+			name: "insufficient funds when posting",
+			setupRepo: func(m *MockRepo) {
+				m.InsertTxErr = ledger.ErrInsufficientFunds
+			},
+			setupUserSvc: func(us *MockUserService) {
+				us.GetUserByEmailResult = toUser
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toUserEmail: toUser.Email,
+				amount: money.MustParse("10"),
+			},
 			expectedErr: validator.ErrFailedValidation,
 		},
+		{
+			name: "InsertTx failure",
+			setupRepo: func(m *MockRepo) {
+				m.InsertTxErr = errDB
+			},
+			setupUserSvc: func(us *MockUserService) {
+				us.GetUserByEmailResult = toUser
+			},
+			input: input{
+				v: validator.New(), fromUser: fromUser, toUserEmail: toUser.Email,
+				amount: money.MustParse("10"),
+			},
+			expectedErr: errDB,
+		},
 		{
 			name:      "to user not found",
 			setupRepo: func(m *MockRepo) {},
 			setupUserSvc: func(us *MockUserService) {
 				us.GetUserByEmailErr = user.ErrNoRecord
 			},
-			input: struct {
-				v           *validator.Validator
-				fromUser    *user.User
-				toUserEmail string
-				amount      float64
-			}{v: validator.New(), fromUser: fromUser, toUserEmail: "random@email.gmail", amount: 10},
-			finalFrom:   100,
-			expectedErr: user.ErrNoRecord,
+			input: input{
+				v: validator.New(), fromUser: fromUser, toUserEmail: "random@email.gmail",
+				amount: money.MustParse("10"),
+			},
+			finalFrom:   money.MustParse("100"),
+			expectedErr: validator.ErrFailedValidation,
 		},
 	}
 
@@ -119,30 +639,672 @@ func TestTransferMoney(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			repo := &MockRepo{}
 			userSvc := &MockUserService{}
+			accountSvc := &MockAccountService{Accounts: newAccounts()}
 			tc.setupRepo(repo)
 			tc.setupUserSvc(userSvc)
+			if tc.setupAccounts != nil {
+				tc.setupAccounts(accountSvc.Accounts)
+			}
+			fxSvc := &MockFX{}
+			if tc.setupFX != nil {
+				tc.setupFX(fxSvc)
+			}
+			limits := &MockLimits{}
+			if tc.setupLimits != nil {
+				tc.setupLimits(limits)
+			}
+			fraudSvc := &MockFraud{}
+			if tc.setupFraud != nil {
+				tc.setupFraud(fraudSvc)
+			}
+			svc := Service{
+				Repo:           repo,
+				UserService:    userSvc,
+				AccountService: accountSvc,
+				FX:             fxSvc,
+				Limits:         limits,
+				Fraud:          fraudSvc,
+			}
+
+			gotTransfer, gotUser, gotErr := svc.TransferMoney(
+				tc.input.v, tc.input.fromUser, tc.input.fromAccountNumber,
+				tc.input.toAccountNumber, tc.input.toUserEmail, tc.input.amount, tc.input.memo,
+				tc.input.reference,
+			)
+
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if gotErr != nil {
+				if len(repo.Posted) != 0 {
+					t.Errorf("expected nothing posted, got %d entries", len(repo.Posted))
+				}
+				return
+			}
+
+			op := fraudSvc.Screened
+			if op == nil || op.AccountID != tc.wantFromID || op.ToAccountID != tc.wantToID ||
+				op.Amount != tc.input.amount {
+				t.Errorf("expected the transfer screened, got %+v", op)
+			}
+
+			if gotUser.AccountBalance != tc.finalFrom {
+				t.Errorf("expected from balance=%v, got %v", tc.finalFrom, gotUser.AccountBalance)
+			}
+			if gotTransfer.FromAccountID != tc.wantFromID || gotTransfer.ToAccountID != tc.wantToID {
+				t.Errorf(
+					"expected accounts %d -> %d, got %d -> %d", tc.wantFromID, tc.wantToID,
+					gotTransfer.FromAccountID, gotTransfer.ToAccountID,
+				)
+			}
+
+			if gotTransfer.Memo != tc.input.memo || gotTransfer.Reference != tc.input.reference {
+				t.Errorf(
+					"expected memo=%q reference=%q, got memo=%q reference=%q", tc.input.memo,
+					tc.input.reference, gotTransfer.Memo, gotTransfer.Reference,
+				)
+			}
+
+			// the debit and the credit must go to the repo as one entry with the transfer
+			if gotPosted := len(repo.Posted) == 1; gotPosted != tc.wantPosted {
+				t.Fatalf("expected posted=%v, got posted=%v", tc.wantPosted, gotPosted)
+			}
+			if gotTransfer.ToAmount != tc.wantToAmount || gotTransfer.SpreadAmount != tc.wantSpread {
+				t.Errorf(
+					"expected to amount=%v spread=%v, got to amount=%v spread=%v", tc.wantToAmount,
+					tc.wantSpread, gotTransfer.ToAmount, gotTransfer.SpreadAmount,
+				)
+			}
+			postings := repo.Posted[0].Postings
+			if postings[0].AccountID != tc.wantFromID || postings[0].Amount != -tc.input.amount ||
+				postings[1].AccountID != tc.wantToID || postings[1].Amount != tc.wantToAmount {
+				t.Errorf("unexpected postings %+v %+v", postings[0], postings[1])
+			}
+			// converted or not, the entry has to balance in every currency
+			v := validator.New()
+			if ledger.ValidateEntry(v, repo.Posted[0]); !v.IsValid() {
+				t.Errorf("unbalanced entry: %v", v.Errors)
+			}
+		})
+	}
+}
+
+// MockPayees has the payees it is given
+type MockPayees struct {
+	Payees []*payee.Payee
+}
+
+func (m *MockPayees) GetPayee(payeeID, userID int64) (*payee.Payee, error) {
+	for _, p := range m.Payees {
+		if p.ID == payeeID && p.UserID == userID {
+			return p, nil
+		}
+	}
+	return nil, user.ErrNoRecord
+}
+
+func TestPayPayee(t *testing.T) {
+	fromUser := &user.User{ID: 1, Name: "yusuf", Email: "a@b.com"}
+	payees := []*payee.Payee{
+		{ID: 1, UserID: 1, Name: "rent", AccountID: 2},
+		{ID: 2, UserID: 1, Name: "old flat", AccountID: 3},
+		{ID: 3, UserID: 2, Name: "yusuf", AccountID: 1},
+	}
+
+	tests := []struct {
+		name             string
+		payeeID          int64
+		paidPayees       map[int64]bool
+		wantToID         int64
+		wantFirstPayment bool
+		expectedErr      error
+		wantErrKey       string
+	}{
+		{name: "first payment", payeeID: 1, wantToID: 2, wantFirstPayment: true},
+		{name: "paid before", payeeID: 1, paidPayees: map[int64]bool{1: true}, wantToID: 2},
+		{
+			name: "payee not found", payeeID: 9, expectedErr: validator.ErrFailedValidation,
+			wantErrKey: "payee",
+		},
+		{
+			name: "another user's payee", payeeID: 3, expectedErr: validator.ErrFailedValidation,
+			wantErrKey: "payee",
+		},
+		{
+			name: "payee account closed", payeeID: 2, expectedErr: validator.ErrFailedValidation,
+			wantErrKey: "to account",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{PaidPayees: tc.paidPayees}
 			svc := Service{
 				Repo:        repo,
-				UserService: userSvc,
+				UserService: &MockUserService{GetUserResult: fromUser},
+				AccountService: &MockAccountService{Accounts: []*account.Account{
+					{
+						ID: 1, UserID: 1, Number: "100000000093", Status: account.StatusActive,
+						Currency: "USD", Balance: money.MustParse("100"),
+						AvailableBalance: money.MustParse("100"),
+					},
+					{
+						ID: 2, UserID: 2, Number: "100000000190", Status: account.StatusActive,
+						Currency: "USD",
+					},
+					{
+						ID: 3, UserID: 2, Number: "100000000287", Status: account.StatusClosed,
+						Currency: "USD",
+					},
+				}},
+				FX:     &MockFX{},
+				Limits: &MockLimits{},
+				Payees: &MockPayees{Payees: payees},
 			}
 
-			_, gotUser, gotErr := svc.TransferMoney(
-				tc.input.v, tc.input.fromUser, tc.input.toUserEmail, tc.input.amount,
+			v := validator.New()
+			got, _, gotErr := svc.PayPayee(
+				v, fromUser, "", tc.payeeID, money.MustParse("10"), "", "",
 			)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if tc.wantErrKey != "" {
+				if _, ok := v.Errors[tc.wantErrKey]; !ok {
+					t.Errorf("expected an error on %q, got %v", tc.wantErrKey, v.Errors)
+				}
+			}
+			if gotErr != nil {
+				if len(repo.Posted) != 0 {
+					t.Errorf("expected nothing posted, got %d entries", len(repo.Posted))
+				}
+				return
+			}
+
+			if got.ToAccountID != tc.wantToID || got.PayeeID == nil || *got.PayeeID != tc.payeeID {
+				t.Errorf(
+					"expected payee %d's account %d, got %v's account %d", tc.payeeID, tc.wantToID,
+					got.PayeeID, got.ToAccountID,
+				)
+			}
+			if got.FirstPayment != tc.wantFirstPayment {
+				t.Errorf("expected first payment=%v, got %v", tc.wantFirstPayment, got.FirstPayment)
+			}
+		})
+	}
+}
+
+func TestPayRequest(t *testing.T) {
+	fromUser := &user.User{ID: 1, Name: "yusuf", Email: "a@b.com"}
+	repo := &MockRepo{}
+	svc := Service{
+		Repo:        repo,
+		UserService: &MockUserService{GetUserResult: fromUser},
+		AccountService: &MockAccountService{Accounts: []*account.Account{
+			{
+				ID: 1, UserID: 1, Number: "100000000093", Status: account.StatusActive,
+				Currency: "USD", Balance: money.MustParse("100"),
+				AvailableBalance: money.MustParse("100"),
+			},
+			{
+				ID: 2, UserID: 2, Number: "100000000190", Status: account.StatusActive,
+				Currency: "USD",
+			},
+		}},
+		FX:     &MockFX{},
+		Limits: &MockLimits{},
+	}
+
+	got, _, err := svc.PayRequest(validator.New(), fromUser, "", 7, 2, money.MustParse("10"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ToAccountID != 2 || got.PaymentRequestID == nil || *got.PaymentRequestID != 7 {
+		t.Errorf("expected request 7 paid into account 2, got %+v", got)
+	}
+	if len(repo.Posted) != 1 {
+		t.Errorf("expected the payment posted, got %d entries", len(repo.Posted))
+	}
+
+	// the payment is a normal transfer, it can't go past the balance
+	_, _, err = svc.PayRequest(validator.New(), fromUser, "", 8, 2, money.MustParse("1000"), "")
+	if !errors.Is(err, validator.ErrFailedValidation) {
+		t.Errorf("expected error %v, got %v", validator.ErrFailedValidation, err)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	errDB := errors.New("db error")
+	newAccounts := func() []*account.Account {
+		return []*account.Account{
+			{
+				ID: 1, UserID: 1, Number: "100000000093", Status: account.StatusActive,
+				Currency: "USD",
+			},
+			{
+				ID: 2, UserID: 2, Number: "100000000190", Status: account.StatusActive,
+				Currency: "USD",
+			},
+			{
+				ID: 3, UserID: 2, Number: "100000000287", Status: account.StatusActive,
+				Currency: "EUR",
+			},
+		}
+	}
+	// 1 is 10 dollars from user 1 to user 2, 2 is 10 dollars converted into 8.91 euros
+	newTransfers := func() []*Transfer {
+		return []*Transfer{
+			{
+				ID: 1, FromUserID: 1, FromAccountID: 1, ToUserID: 2, ToAccountID: 2,
+				Amount: money.MustParse("10"), Currency: "USD", ToAmount: money.MustParse("10"),
+				ToCurrency: "USD", Kind: KindTransfer, Status: StatusCompleted,
+			},
+			{
+				ID: 2, FromUserID: 1, FromAccountID: 1, ToUserID: 2, ToAccountID: 3,
+				Amount: money.MustParse("10"), Currency: "USD", ToAmount: money.MustParse("8.91"),
+				ToCurrency: "EUR", SpreadAmount: money.MustParse("0.09"), Kind: KindTransfer,
+				Status: StatusCompleted,
+			},
+		}
+	}
+
+	type input struct {
+		reverse    bool
+		userID     int64
+		transferID int64
+		amount     money.Amount
+		reason     string
+	}
+	tests := []struct {
+		name          string
+		setupRepo     func(*MockRepo)
+		setupAccounts func([]*account.Account)
+		inputs        []input // sent one after the other, the last one is checked
+		wantAmount    money.Amount
+		wantToAmount  money.Amount
+		wantStatus    string
+		expectedErr   error
+	}{
+		{
+			name:       "full refund",
+			setupRepo:  func(r *MockRepo) {},
+			inputs:     []input{{userID: 2, transferID: 1}},
+			wantAmount: money.MustParse("10"), wantToAmount: money.MustParse("10"),
+			wantStatus: StatusReversed,
+		},
+		{
+			name:       "partial refund",
+			setupRepo:  func(r *MockRepo) {},
+			inputs:     []input{{userID: 2, transferID: 1, amount: money.MustParse("4")}},
+			wantAmount: money.MustParse("4"), wantToAmount: money.MustParse("4"),
+			wantStatus: StatusPartiallyReversed,
+		},
+		{
+			name:      "refund of the rest",
+			setupRepo: func(r *MockRepo) {},
+			inputs: []input{
+				{userID: 2, transferID: 1, amount: money.MustParse("4")},
+				{userID: 2, transferID: 1},
+			},
+			wantAmount: money.MustParse("6"), wantToAmount: money.MustParse("6"),
+			wantStatus: StatusReversed,
+		},
+		{
+			name:      "refund more than is left",
+			setupRepo: func(r *MockRepo) {},
+			inputs: []input{
+				{userID: 2, transferID: 1, amount: money.MustParse("4")},
+				{userID: 2, transferID: 1, amount: money.MustParse("7")},
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "refund an already refunded transfer",
+			setupRepo: func(r *MockRepo) {},
+			inputs: []input{
+				{userID: 2, transferID: 1},
+				{userID: 2, transferID: 1},
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "refund a refund",
+			setupRepo: func(r *MockRepo) {},
+			inputs: []input{
+				{userID: 2, transferID: 1},
+				{userID: 1, transferID: 3},
+			},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:        "refund by the sender",
+			setupRepo:   func(r *MockRepo) {},
+			inputs:      []input{{userID: 1, transferID: 1}},
+			expectedErr: user.ErrNoRecord,
+		},
+		{
+			name:        "transfer not found",
+			setupRepo:   func(r *MockRepo) {},
+			inputs:      []input{{userID: 2, transferID: 9}},
+			expectedErr: user.ErrNoRecord,
+		},
+		{
+			name:      "refund into a frozen account",
+			setupRepo: func(r *MockRepo) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[0].Status = account.StatusFrozen
+			},
+			inputs:     []input{{userID: 2, transferID: 1}},
+			wantAmount: money.MustParse("10"), wantToAmount: money.MustParse("10"),
+			wantStatus: StatusReversed,
+		},
+		{
+			name:      "refund into a closed account",
+			setupRepo: func(r *MockRepo) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[0].Status = account.StatusClosed
+			},
+			inputs:      []input{{userID: 2, transferID: 1}},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "refund from a frozen account",
+			setupRepo: func(r *MockRepo) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[1].Status = account.StatusFrozen
+			},
+			inputs:      []input{{userID: 2, transferID: 1}},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "reversal into a frozen account",
+			setupRepo: func(r *MockRepo) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[0].Status = account.StatusFrozen
+			},
+			inputs:     []input{{reverse: true, userID: 5, transferID: 1, reason: "sent twice"}},
+			wantAmount: money.MustParse("10"), wantToAmount: money.MustParse("10"),
+			wantStatus: StatusReversed,
+		},
+		{
+			name:        "reversal without a reason",
+			setupRepo:   func(r *MockRepo) {},
+			inputs:      []input{{reverse: true, userID: 5, transferID: 1}},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:       "partial refund of a converted transfer",
+			setupRepo:  func(r *MockRepo) {},
+			inputs:     []input{{userID: 2, transferID: 2, amount: money.MustParse("3")}},
+			wantAmount: money.MustParse("3"), wantToAmount: money.MustParse("3.36"),
+			wantStatus: StatusPartiallyReversed,
+		},
+		{
+			name:      "the rest of a converted transfer gives back the spread",
+			setupRepo: func(r *MockRepo) {},
+			inputs: []input{
+				{userID: 2, transferID: 2, amount: money.MustParse("3")},
+				{userID: 2, transferID: 2},
+			},
+			wantAmount: money.MustParse("5.91"), wantToAmount: money.MustParse("6.64"),
+			wantStatus: StatusReversed,
+		},
+		{
+			name: "recipient spent the money",
+			setupRepo: func(r *MockRepo) {
+				r.InsertTxErr = ledger.ErrInsufficientFunds
+			},
+			inputs:      []input{{userID: 2, transferID: 1}},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "ReverseTx failure",
+			setupRepo: func(r *MockRepo) {
+				r.InsertTxErr = errDB
+			},
+			inputs:      []input{{userID: 2, transferID: 1}},
+			expectedErr: errDB,
+		},
+	}
 
-			if gotErr != tc.expectedErr {
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{Transfers: newTransfers()}
+			tc.setupRepo(repo)
+			accountSvc := &MockAccountService{Accounts: newAccounts()}
+			if tc.setupAccounts != nil {
+				tc.setupAccounts(accountSvc.Accounts)
+			}
+			svc := Service{Repo: repo, AccountService: accountSvc}
+
+			var gotReversal *Transfer
+			var gotErr error
+			for _, in := range tc.inputs {
+				if in.reverse {
+					gotReversal, gotErr = svc.Reverse(
+						validator.New(), in.userID, in.transferID, in.amount, in.reason,
+					)
+				} else {
+					gotReversal, gotErr = svc.Refund(
+						validator.New(), &user.User{ID: in.userID}, in.transferID, in.amount,
+						in.reason,
+					)
+				}
+			}
+			if !errors.Is(gotErr, tc.expectedErr) {
 				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
 			}
 			if gotErr != nil {
 				return
 			}
 
-			if toUser.AccountBalance != tc.finalTo {
+			last := tc.inputs[len(tc.inputs)-1]
+			original := repo.Transfers[last.transferID-1]
+			if gotReversal.Amount != tc.wantAmount || gotReversal.ToAmount != tc.wantToAmount {
 				t.Errorf(
-					"expected balances from=%v, to=%v; got from=%v, to=%v", tc.finalFrom, tc.finalTo,
-					gotUser.AccountBalance, toUser.AccountBalance,
+					"expected %v back, %v to the sender, got %v, %v", tc.wantAmount,
+					tc.wantToAmount, gotReversal.Amount, gotReversal.ToAmount,
 				)
 			}
+			if *gotReversal.ReversalOf != original.ID || *gotReversal.ReversedBy != last.userID {
+				t.Errorf("unexpected links %+v", gotReversal)
+			}
+			if gotReversal.FromAccountID != original.ToAccountID ||
+				gotReversal.ToAccountID != original.FromAccountID {
+				t.Errorf("expected the reversal to go the other way, got %+v", gotReversal)
+			}
+			if original.Status != tc.wantStatus {
+				t.Errorf("expected status %s, got %s", tc.wantStatus, original.Status)
+			}
+
+			v := validator.New()
+			if ledger.ValidateEntry(v, repo.Posted[len(repo.Posted)-1]); !v.IsValid() {
+				t.Errorf("unbalanced entry: %v", v.Errors)
+			}
+		})
+	}
+}
+
+func TestTransferAll(t *testing.T) {
+	errDB := errors.New("db error")
+	fromUser := &user.User{ID: 1}
+	newAccounts := func() []*account.Account {
+		return []*account.Account{
+			{
+				ID: 1, UserID: 1, Number: "100000000093", Status: account.StatusActive,
+				Currency: "USD", Balance: money.MustParse("100"),
+				AvailableBalance: money.MustParse("100"),
+			},
+			{
+				ID: 2, UserID: 2, Number: "100000000190", Status: account.StatusActive,
+				Currency: "USD",
+			},
+			{
+				ID: 3, UserID: 3, Number: "100000000287", Status: account.StatusActive,
+				Currency: "USD",
+			},
+			{
+				ID: 4, UserID: 2, Number: "100000000384", Status: account.StatusActive,
+				Currency: "EUR",
+			},
+		}
+	}
+	newPayments := func(amounts ...string) []*Payment {
+		numbers := []string{"100000000190", "100000000287", "100000000384"}
+		payments := make([]*Payment, len(amounts))
+		for i, amount := range amounts {
+			payments[i] = &Payment{ToAccountNumber: numbers[i], Amount: money.MustParse(amount)}
+		}
+		return payments
+	}
+
+	tests := []struct {
+		name          string
+		setupRepo     func(*MockRepo)
+		setupAccounts func([]*account.Account)
+		setupLimits   func(*MockLimits)
+		setupFraud    func(*MockFraud)
+		payments      []*Payment
+		wantPosted    int
+		wantRefused   []bool
+		wantBatchErr  string
+		expectedErr   error
+	}{
+		{
+			name:       "all made",
+			setupRepo:  func(r *MockRepo) {},
+			payments:   newPayments("10", "20", "30"),
+			wantPosted: 3,
+		},
+		{
+			name:      "one refused",
+			setupRepo: func(r *MockRepo) {},
+			setupAccounts: func(accounts []*account.Account) {
+				accounts[2].Status = account.StatusClosed
+			},
+			payments:    newPayments("10", "20", "30"),
+			wantRefused: []bool{false, true, false},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:      "recipient not found",
+			setupRepo: func(r *MockRepo) {},
+			payments: []*Payment{
+				{ToAccountNumber: "100000009987", Amount: money.MustParse("10")},
+			},
+			wantRefused: []bool{true},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name:         "total more than the balance",
+			setupRepo:    func(r *MockRepo) {},
+			payments:     newPayments("40", "40", "40"),
+			wantRefused:  []bool{false, false, false},
+			wantBatchErr: "account balance",
+			expectedErr:  validator.ErrFailedValidation,
+		},
+		{
+			name:      "total over the limit",
+			setupRepo: func(r *MockRepo) {},
+			setupLimits: func(l *MockLimits) {
+				l.Max = money.MustParse("25")
+			},
+			payments:     newPayments("10", "20"),
+			wantRefused:  []bool{false, false},
+			wantBatchErr: "daily limit",
+			expectedErr:  validator.ErrFailedValidation,
+		},
+		{
+			name:      "rows refused by the screening",
+			setupRepo: func(r *MockRepo) {},
+			setupFraud: func(f *MockFraud) {
+				f.Err = validator.ErrFailedValidation
+			},
+			payments:    newPayments("10", "20"),
+			wantRefused: []bool{true, true},
+			expectedErr: validator.ErrFailedValidation,
+		},
+		{
+			name: "balance changed before posting",
+			setupRepo: func(r *MockRepo) {
+				r.InsertTxErr = ledger.ErrInsufficientFunds
+			},
+			payments:     newPayments("10"),
+			wantRefused:  []bool{false},
+			wantBatchErr: "account balance",
+			expectedErr:  validator.ErrFailedValidation,
+		},
+		{
+			name: "InsertAllTx failure",
+			setupRepo: func(r *MockRepo) {
+				r.InsertTxErr = errDB
+			},
+			payments:    newPayments("10"),
+			wantRefused: []bool{false},
+			expectedErr: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockRepo{}
+			tc.setupRepo(repo)
+			accountSvc := &MockAccountService{Accounts: newAccounts()}
+			if tc.setupAccounts != nil {
+				tc.setupAccounts(accountSvc.Accounts)
+			}
+			limits := &MockLimits{}
+			if tc.setupLimits != nil {
+				tc.setupLimits(limits)
+			}
+			fraudSvc := &MockFraud{}
+			if tc.setupFraud != nil {
+				tc.setupFraud(fraudSvc)
+			}
+			fxSvc := &MockFX{
+				Rate: &fx.Rate{
+					BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: fx.MustParseDecimal("0.9"),
+				},
+			}
+			svc := Service{
+				Repo: repo, AccountService: accountSvc, FX: fxSvc, Limits: limits,
+				Fraud: fraudSvc,
+			}
+
+			v := validator.New()
+			gotErr := svc.TransferAll(v, fromUser, "", tc.payments)
+			if !errors.Is(gotErr, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, gotErr)
+			}
+			if len(repo.Posted) != tc.wantPosted {
+				t.Errorf("expected %d entries posted, got %d", tc.wantPosted, len(repo.Posted))
+			}
+			// a batch can't wait for a review of one of its rows
+			if fraudSvc.Screened != nil && !fraudSvc.Screened.NoReview {
+				t.Errorf("expected the rows screened without review, got %+v", fraudSvc.Screened)
+			}
+			if tc.wantBatchErr != "" {
+				if _, ok := v.Errors[tc.wantBatchErr]; !ok {
+					t.Errorf("expected a %q error, got %v", tc.wantBatchErr, v.Errors)
+				}
+			}
+
+			for i, p := range tc.payments {
+				if gotErr == nil {
+					if p.Transfer == nil || p.Errors != nil {
+						t.Errorf("payment %d: expected it made, got %+v", i, p)
+					}
+					continue
+				}
+				if p.Transfer != nil {
+					t.Errorf("payment %d: expected nothing made, got %+v", i, p.Transfer)
+				}
+				if (p.Errors != nil) != tc.wantRefused[i] {
+					t.Errorf(
+						"payment %d: expected refused %t, got %v", i, tc.wantRefused[i], p.Errors,
+					)
+				}
+			}
 		})
 	}
 }
@@ -3,20 +3,22 @@ package user
 import (
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // User is custom struct to hold the user information and details
 type User struct {
-	ID             int64     `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	Name           string    `json:"name"`
-	Email          string    `json:"email"`
-	Password       password  `json:"-"`
-	Activated      bool      `json:"activated"`
-	AccountBalance float64   `json:"account_balance"`
-	Version        int32     `json:"version"`
+	ID               int64        `json:"id"`
+	CreatedAt        time.Time    `json:"created_at"`
+	Name             string       `json:"name"`
+	Email            string       `json:"email"`
+	Password         password     `json:"-"`
+	Activated        bool         `json:"activated"`
+	AccountBalance   money.Amount `json:"account_balance"`   // sum of the default currency accounts
+	AvailableBalance money.Amount `json:"available_balance"` // less what holds set aside
+	Version          int32        `json:"version"`
 }
 
 // AnonymousUser is for use not signed in
@@ -6,6 +6,8 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/event"
 )
 
 var (
@@ -17,23 +19,43 @@ type Repository struct {
 	DB *sql.DB
 }
 
-func (r *Repository) Insert(user *User) error {
-	query := `
-		INSERT INTO users (name, email, password_hash, account_balance)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, activated, version
-	`
+// balanceColumns are the user's ledger and available balances, the sums of the balances of their
+// accounts in the default currency. balances in other currencies can't be added to them, they are
+// only on the accounts
+const balanceColumns = `
+	(SELECT COALESCE(SUM(accounts.balance), 0) FROM accounts
+	WHERE accounts.user_id = users.id AND accounts.currency = 'USD'),
+	(SELECT COALESCE(SUM(accounts.balance), 0) FROM accounts
+	WHERE accounts.user_id = users.id AND accounts.currency = 'USD') -
+	(SELECT COALESCE(SUM(holds.amount), 0) FROM holds
+	INNER JOIN accounts ON accounts.id = holds.account_id
+	WHERE accounts.user_id = users.id AND accounts.currency = 'USD' AND holds.status = 'ACTIVE'
+		AND holds.expires_at > NOW())
+`
 
+// Insert creates the user together with their first account, a checking account, so that every
+// user has somewhere to receive money from the start, and records that they registered
+func (r *Repository) Insert(user *User) error {
 	// create a 3 sec context so that the request doesnt take too long and hold the resources
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := r.DB.QueryRowContext(
-		ctx, query, user.Name, user.Email, user.Password.Hash, user.AccountBalance,
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO users (name, email, password_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, activated, version
+	`
+	err = tx.QueryRowContext(
+		ctx, query, user.Name, user.Email, user.Password.Hash,
 	).Scan(
 		&user.ID,
 		&user.CreatedAt,
-		// &user.AccountBalance,
 		&user.Activated,
 		&user.Version,
 	)
@@ -48,12 +70,32 @@ func (r *Repository) Insert(user *User) error {
 		}
 	}
 
-	return nil
+	// the balance always starts at 0, money only enters an account through the ledger
+	user.AccountBalance = 0
+	user.AvailableBalance = 0
+	query = `
+		INSERT INTO accounts (user_id, type)
+		VALUES ($1, 'CHECKING')
+	`
+	_, err = tx.ExecContext(ctx, query, user.ID)
+	if err != nil {
+		return err
+	}
+
+	e, err := event.New(user.ID, event.UserRegistered{Name: user.Name, Email: user.Email})
+	if err != nil {
+		return err
+	}
+	if err = event.RecordInTx(ctx, tx, e); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *Repository) Get(userID int64) (*User, error) {
 	query := `
-		SELECT id, created_at, name, email, password_hash, account_balance, activated, version
+		SELECT id, created_at, name, email, password_hash, ` + balanceColumns + `, activated, version
 		FROM users
 		WHERE id = $1
 	`
@@ -70,6 +112,7 @@ func (r *Repository) Get(userID int64) (*User, error) {
 		&user.Email,
 		&user.Password.Hash,
 		&user.AccountBalance,
+		&user.AvailableBalance,
 		&user.Activated,
 		&user.Version,
 	)
@@ -87,7 +130,7 @@ func (r *Repository) Get(userID int64) (*User, error) {
 
 func (r *Repository) GetByEmail(email string) (*User, error) {
 	query := `
-		SELECT id, created_at, name, email, password_hash, account_balance, activated, version
+		SELECT id, created_at, name, email, password_hash, ` + balanceColumns + `, activated, version
 		FROM users
 		WHERE email = $1
 	`
@@ -104,6 +147,7 @@ func (r *Repository) GetByEmail(email string) (*User, error) {
 		&user.Email,
 		&user.Password.Hash,
 		&user.AccountBalance,
+		&user.AvailableBalance,
 		&user.Activated,
 		&user.Version,
 	)
@@ -122,7 +166,7 @@ func (r *Repository) GetByEmail(email string) (*User, error) {
 func (r *Repository) GetForToken(tokenPlaintext, scope string) (*User, error) {
 	query := `
 		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, 
-			users.account_balance, users.activated, users.version
+			` + balanceColumns + `, users.activated, users.version
 		FROM users
 		INNER JOIN tokens 
 		ON users.id = tokens.user_id
@@ -150,6 +194,7 @@ func (r *Repository) GetForToken(tokenPlaintext, scope string) (*User, error) {
 		&user.Email,
 		&user.Password.Hash,
 		&user.AccountBalance,
+		&user.AvailableBalance,
 		&user.Activated,
 		&user.Version,
 	)
@@ -165,8 +210,10 @@ func (r *Repository) GetForToken(tokenPlaintext, scope string) (*User, error) {
 	return &user, nil
 }
 
+// UpdateTx updates the user's details. the balance is left alone, it is only ever changed by posting
+// to the ledger. activating the user is recorded
 func (r *Repository) UpdateTx(
-	userID int64, name, email string, passwordHash []byte, accountBalance float64, activated bool,
+	userID int64, name, email string, passwordHash []byte, activated bool,
 ) (*User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -178,7 +225,7 @@ func (r *Repository) UpdateTx(
 	defer tx.Rollback()
 
 	query := `
-		SELECT id, created_at, name, email, password_hash, account_balance, activated, version
+		SELECT id, created_at, name, email, password_hash, ` + balanceColumns + `, activated, version
 		FROM users
 		WHERE id = $1
 		FOR UPDATE
@@ -192,6 +239,7 @@ func (r *Repository) UpdateTx(
 		&user.Email,
 		&user.Password.Hash,
 		&user.AccountBalance,
+		&user.AvailableBalance,
 		&user.Activated,
 		&user.Version,
 	)
@@ -201,32 +249,40 @@ func (r *Repository) UpdateTx(
 
 	updateQuery := `
 		UPDATE users
-		set name = $1, email = $2, password_hash = $3, account_balance = $4, activated = $5, 
-			version = version + 1
-		WHERE id = $6
-		RETURNING name, email, password_hash, account_balance, activated
+		set name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
+		WHERE id = $5
+		RETURNING name, email, password_hash, activated
 	`
 
 	args := []any{
 		name,
 		email,
 		passwordHash,
-		accountBalance,
 		activated,
 		userID,
 	}
 
+	wasActivated := user.Activated
 	err = tx.QueryRowContext(ctx, updateQuery, args...).Scan(
 		&user.Name,
 		&user.Email,
 		&user.Password.Hash,
-		&user.AccountBalance,
 		&user.Activated,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if user.Activated && !wasActivated {
+		e, err := event.New(user.ID, event.UserActivated{Email: user.Email})
+		if err != nil {
+			return nil, err
+		}
+		if err = event.RecordInTx(ctx, tx, e); err != nil {
+			return nil, err
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
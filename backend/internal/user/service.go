@@ -13,10 +13,7 @@ type UserRepo interface {
 	Get(userID int64) (*User, error)
 	GetByEmail(email string) (*User, error)
 	GetForToken(tokenPlaintext, scope string) (*User, error)
-	UpdateTx(
-		userID int64, name, email string, passwordHash []byte,
-		accountBalance float64, activated bool,
-	) (*User, error)
+	UpdateTx(userID int64, name, email string, passwordHash []byte, activated bool) (*User, error)
 }
 
 type Mailer interface {
@@ -53,9 +50,9 @@ func (s *Service) GetUserByEmail(email string) (*User, error) {
 }
 
 func (s *Service) UpdateUser(
-	userID int64, name, email string, passwordHash []byte, accountBalance float64, activated bool,
+	userID int64, name, email string, passwordHash []byte, activated bool,
 ) (*User, error) {
-	user, err := s.Repo.UpdateTx(userID, name, email, passwordHash, accountBalance, activated)
+	user, err := s.Repo.UpdateTx(userID, name, email, passwordHash, activated)
 	return user, err
 }
 
@@ -114,7 +111,7 @@ func (s *Service) Activate(tokenPlaintext string) (*User, error) {
 
 	u.Activated = true
 
-	u, err = s.Repo.UpdateTx(u.ID, u.Name, u.Email, u.Password.Hash, u.AccountBalance, u.Activated)
+	u, err = s.Repo.UpdateTx(u.ID, u.Name, u.Email, u.Password.Hash, u.Activated)
 	if err != nil {
 		return u, err
 	}
@@ -126,33 +123,3 @@ func (s *Service) Activate(tokenPlaintext string) (*User, error) {
 
 	return u, nil
 }
-
-func (s *Service) TransferMoney(fromUser, toUser *User, amount float64) (*User, error) {
-	fromUser.AccountBalance -= amount
-	fromUser, err := s.Repo.UpdateTx(
-		fromUser.ID, fromUser.Name, fromUser.Email, fromUser.Password.Hash,
-		fromUser.AccountBalance, fromUser.Activated,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	// update the recipient account
-	toUser.AccountBalance += amount
-	_, err = s.Repo.UpdateTx(
-		toUser.ID, toUser.Name, toUser.Email, toUser.Password.Hash,
-		toUser.AccountBalance, toUser.Activated,
-	)
-	// if no error, return the updated state of the sender account
-	if err == nil {
-		return fromUser, nil
-	}
-
-	// in case of an error transferring the money, try to put the amount taken from the sender back
-	fromUser.AccountBalance += amount
-	_, err = s.Repo.UpdateTx(
-		fromUser.ID, fromUser.Name, fromUser.Email, fromUser.Password.Hash,
-		fromUser.AccountBalance, fromUser.Activated,
-	)
-	return nil, err
-}
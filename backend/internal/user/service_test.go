@@ -14,6 +14,9 @@ import (
 type MockRepo struct {
 	InsertErr error
 
+	GetResult *User
+	GetErr    error
+
 	GetForTokenResult *User
 	GetForTokenErr    error
 
@@ -26,7 +29,7 @@ func (r *MockRepo) Insert(user *User) error {
 }
 
 func (r *MockRepo) Get(userID int64) (*User, error) {
-	return nil, nil
+	return r.GetResult, r.GetErr
 }
 
 func (r *MockRepo) GetByEmail(email string) (*User, error) {
@@ -38,7 +41,7 @@ func (r *MockRepo) GetForToken(tokenPlaintext, scope string) (*User, error) {
 }
 
 func (r *MockRepo) UpdateTx(
-	userID int64, name, email string, passwordHash []byte, balance float64, activate bool,
+	userID int64, name, email string, passwordHash []byte, activate bool,
 ) (*User, error) {
 	return r.UpdateTxResult, r.UpdateTxErr
 }
@@ -228,70 +231,3 @@ func TestActivate(t *testing.T) {
 		})
 	}
 }
-
-func TestTransferMoney(t *testing.T) {
-	fromUser := &User{
-		ID: 1, Name: "yusuf", Email: "a@b.com", AccountBalance: 100,
-	}
-	toUser := &User{
-		ID: 2, Name: "mohamed", Email: "b@a.com", AccountBalance: 50,
-	}
-
-	tests := []struct {
-		name        string
-		amount      float64
-		setupRepo   func(*MockRepo)
-		expectedErr error
-		finalFrom   float64
-		finalTo     float64
-	}{
-		{
-			name:   "valid input",
-			amount: 10,
-			setupRepo: func(r *MockRepo) {
-				// after deduct
-				r.UpdateTxResult = &User{ID: 1, AccountBalance: 90}
-			},
-			finalFrom:   90,
-			finalTo:     60,
-			expectedErr: nil,
-		},
-		{
-			name:   "update failure",
-			amount: 10,
-			setupRepo: func(r *MockRepo) {
-				r.UpdateTxErr = errors.New("db error")
-			},
-			finalFrom:   100,
-			finalTo:     50,
-			expectedErr: errors.New("db error"),
-		},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			repo := &MockRepo{}
-			tc.setupRepo(repo)
-			svc := Service{
-				Repo: repo,
-			}
-
-			gotUser, gotErr := svc.TransferMoney(fromUser, toUser, tc.amount)
-			if tc.expectedErr != nil {
-				if gotErr == nil || gotErr.Error() != tc.expectedErr.Error() {
-					t.Fatalf("expected error %v, got error %v", tc.expectedErr, gotErr)
-				}
-				return
-			} else if gotErr != nil {
-				t.Fatalf("unexpected error %v", gotErr)
-			}
-
-			if gotUser.AccountBalance != tc.finalFrom {
-				t.Fatalf(
-					"expected balances from=%v, to=%v; got from=%v, to=%v", tc.finalFrom,
-					tc.finalTo, fromUser.AccountBalance, toUser.AccountBalance,
-				)
-			}
-		})
-	}
-}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"regexp"
 	"slices"
+	"strings"
 )
 
 var (
@@ -52,3 +53,14 @@ func ValueInList(value string, list ...string) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// Reasons puts the errors into one message, "key: message" for each joined by commas. they are
+// sorted, so the same errors always make the same message
+func Reasons(errs map[string]string) string {
+	list := make([]string, 0, len(errs))
+	for key, message := range errs {
+		list = append(list, key+": "+message)
+	}
+	slices.Sort(list)
+	return strings.Join(list, ", ")
+}
@@ -159,3 +159,31 @@ func TestMatches(t *testing.T) {
 		})
 	}
 }
+
+func TestReasons(t *testing.T) {
+	tests := []struct {
+		name string
+		errs map[string]string
+		want string
+	}{
+		{name: "none", errs: map[string]string{}, want: ""},
+		{
+			name: "one",
+			errs: map[string]string{"amount": "must be given"},
+			want: "amount: must be given",
+		},
+		{
+			name: "sorted",
+			errs: map[string]string{"to account": "not found", "amount": "must be given"},
+			want: "amount: must be given, to account: not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Reasons(tc.errs); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+const (
+	StatusPending   = "PENDING"
+	StatusDelivered = "DELIVERED"
+	// tried MaxAttempts times without the endpoint taking it, it is only sent again if replayed
+	StatusFailed = "FAILED"
+)
+
+// the headers a delivery is sent with. the signature is of the timestamp and the body, so a
+// receiver can tell the delivery came from the bank and refuse old ones sent again
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is sent before it is given up on
+	MaxAttempts = 8
+	// the wait after the first failed attempt, doubled after every one after it up to maxBackoff
+	firstBackoff = 30 * time.Second
+	maxBackoff   = 6 * time.Hour
+
+	maxURLLength = 2000
+	secretBytes  = 32
+)
+
+// Webhook is an endpoint that is sent the events of EventTypes, or all of them if one is event.All,
+// that happen to its user, or to any user when it is an ADMIN's or SUPERUSER's. Secret signs the
+// deliveries, and is only shown when the webhook is registered
+type Webhook struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UserID     int64     `json:"user_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+}
+
+// Delivery is an event to be sent to a webhook. Attempts is how many times it has been sent, and
+// NextAttemptAt when it is sent next while it is pending. History is what happened each time
+type Delivery struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	WebhookID     int64      `json:"webhook_id"`
+	EventID       int64      `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	History       []*Attempt `json:"history,omitempty"`
+
+	// where and what is sent, read when the delivery is claimed to be sent
+	URL    string       `json:"-"`
+	Secret string       `json:"-"`
+	Event  *event.Event `json:"-"`
+}
+
+// Attempt is one time a delivery was sent. StatusCode is 0 when the endpoint couldn't be reached,
+// and Error says why it didn't take the delivery
+type Attempt struct {
+	ID          int64     `json:"id"`
+	DeliveryID  int64     `json:"delivery_id"`
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int       `json:"status_code"`
+	Error       string    `json:"error,omitempty"`
+	DurationMS  int64     `json:"duration_ms"`
+}
+
+// body is what a delivery sends, the event with its payload under data
+type body struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    int64     `json:"user_id"`
+	Data      any       `json:"data"`
+}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	v.CheckAddError(webhook.URL != "", "url", "must be given")
+	v.CheckAddError(
+		len(webhook.URL) <= maxURLLength, "url", "must not be more than 2000 bytes long",
+	)
+	u, err := url.Parse(webhook.URL)
+	v.CheckAddError(
+		err == nil && u.Scheme == "https" && u.Host != "", "url", "must be an https URL",
+	)
+	if err == nil && u.Host != "" {
+		// a name is checked again for the address it resolves to when a delivery is sent
+		host := strings.ToLower(u.Hostname())
+		ip := net.ParseIP(host)
+		v.CheckAddError(
+			host != "localhost" && !strings.HasSuffix(host, ".localhost") &&
+				(ip == nil || publicIP(ip)),
+			"url", "must not be a local or private address",
+		)
+	}
+
+	v.CheckAddError(len(webhook.EventTypes) > 0, "event types", "must be given")
+	for _, t := range webhook.EventTypes {
+		v.CheckAddError(
+			t == event.All || slices.Contains(event.Types, t), "event types", "unknown type "+t,
+		)
+	}
+}
+
+// publicIP reports whether the address is on the internet. webhooks are not sent to the bank's own
+// machines or network, like the cloud metadata address 169.254.169.254
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// Sign returns the signature of a delivery's body sent at timestamp, in Unix seconds, as it is in
+// the signature header: sha256= and the hex of the HMAC-SHA256 of the timestamp, a dot and the body
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature is of the body sent at timestamp, it is how receivers check
+// a delivery
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// backoff is how long a delivery waits to be sent again after failing attempts times
+func backoff(attempts int) time.Duration {
+	wait := firstBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
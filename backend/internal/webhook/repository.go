@@ -0,0 +1,342 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/lib/pq"
+)
+
+type Repository struct {
+	DB *sql.DB
+}
+
+const selectDeliveries = `
+	SELECT webhook_deliveries.id, webhook_deliveries.created_at, webhook_id, event_id,
+		outbox.type, status, webhook_deliveries.attempts, next_attempt_at, delivered_at
+	FROM webhook_deliveries
+	INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+	INNER JOIN outbox ON outbox.id = webhook_deliveries.event_id
+`
+
+func scanDelivery(row interface{ Scan(dest ...any) error }, dest ...any) (*Delivery, error) {
+	d := &Delivery{}
+	err := row.Scan(append([]any{
+		&d.ID,
+		&d.CreatedAt,
+		&d.WebhookID,
+		&d.EventID,
+		&d.EventType,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.DeliveredAt,
+	}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r *Repository) Insert(webhook *Webhook) error {
+	query := `
+		INSERT INTO webhooks (user_id, url, secret, event_types)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, active
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.DB.QueryRowContext(
+		ctx, query, webhook.UserID, webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes),
+	).Scan(&webhook.ID, &webhook.CreatedAt, &webhook.Active)
+}
+
+// GetAllUserWebhooks gets the webhooks the user registered, without their secrets
+func (r *Repository) GetAllUserWebhooks(userID int64) ([]*Webhook, error) {
+	query := `
+		SELECT id, created_at, user_id, url, event_types, active
+		FROM webhooks
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var webhooks []*Webhook
+	for rows.Next() {
+		webhook := &Webhook{}
+		err = rows.Scan(
+			&webhook.ID,
+			&webhook.CreatedAt,
+			&webhook.UserID,
+			&webhook.URL,
+			pq.Array(&webhook.EventTypes),
+			&webhook.Active,
+		)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Delete deletes the user's webhook and its deliveries
+func (r *Repository) Delete(webhookID, userID int64) error {
+	query := `
+		DELETE FROM webhooks
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, webhookID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return user.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Enqueue makes a delivery of the event for every active webhook registered for its type, of the
+// user it happened to or of an ADMIN or SUPERUSER, who are sent the events of every user. an event
+// already queued for a webhook isn't queued again, so it is safe when the event is published twice
+func (r *Repository) Enqueue(e *event.Event) (int, error) {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id)
+		SELECT id, $1
+		FROM webhooks
+		WHERE active AND ($2 = ANY(event_types) OR $3 = ANY(event_types))
+		AND (
+			webhooks.user_id = $4
+			OR EXISTS (
+				SELECT 1
+				FROM users_permissions
+				INNER JOIN permissions ON permissions.id = users_permissions.permission_id
+				WHERE users_permissions.user_id = webhooks.user_id
+				AND permissions.code IN ('ADMIN', 'SUPERUSER')
+			)
+		)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, e.ID, e.Type, event.All, e.UserID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// ClaimDue gets up to limit of the pending deliveries that are due at now, oldest first, with where
+// they go and their events. they aren't due again until leaseUntil, so they are only sent once at a
+// time even when the servers look for them together, and are sent again if one stops while sending
+func (r *Repository) ClaimDue(now, leaseUntil time.Time, limit int) ([]*Delivery, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET next_attempt_at = $2
+			WHERE id IN (
+				SELECT id
+				FROM webhook_deliveries
+				WHERE status = 'PENDING' AND next_attempt_at <= $1
+				ORDER BY next_attempt_at, id
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT claimed.id, claimed.created_at, webhook_id, event_id, outbox.type, claimed.status,
+			claimed.attempts, next_attempt_at, delivered_at, webhooks.url, webhooks.secret,
+			outbox.created_at, outbox.user_id, outbox.payload
+		FROM claimed
+		INNER JOIN webhooks ON webhooks.id = claimed.webhook_id
+		INNER JOIN outbox ON outbox.id = claimed.event_id
+		ORDER BY claimed.id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deliveries []*Delivery
+	for rows.Next() {
+		e := &event.Event{}
+		var url, secret string
+		d, err := scanDelivery(rows, &url, &secret, &e.CreatedAt, &e.UserID, &e.Payload)
+		if err != nil {
+			return nil, err
+		}
+		e.ID, e.Type = d.EventID, d.EventType
+		d.URL, d.Secret, d.Event = url, secret, e
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// RecordAttempt saves the attempt and where it left the delivery
+func (r *Repository) RecordAttempt(d *Delivery, attempt *Attempt) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO webhook_delivery_attempts
+			(delivery_id, attempted_at, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	err = tx.QueryRowContext(
+		ctx, query, d.ID, attempt.AttemptedAt, attempt.StatusCode, attempt.Error,
+		attempt.DurationMS,
+	).Scan(&attempt.ID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, delivered_at = $4
+		WHERE id = $5
+	`
+	_, err = tx.ExecContext(
+		ctx, query, d.Status, d.Attempts, d.NextAttemptAt, d.DeliveredAt, d.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetDeliveries gets the newest limit deliveries of the user's webhook, only the ones with the
+// status if one is given, each with its attempts
+func (r *Repository) GetDeliveries(
+	webhookID, userID int64, status string, limit int,
+) ([]*Delivery, error) {
+	query := selectDeliveries + `
+		WHERE webhook_id = $1 AND webhooks.user_id = $2 AND ($3 = '' OR status = $3)
+		ORDER BY webhook_deliveries.id DESC
+		LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, webhookID, userID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deliveries []*Delivery
+	byID := make(map[int64]*Delivery)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+		byID[d.ID] = d
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return deliveries, nil
+	}
+
+	ids := make([]int64, 0, len(deliveries))
+	for _, d := range deliveries {
+		ids = append(ids, d.ID)
+	}
+	query = `
+		SELECT id, delivery_id, attempted_at, status_code, error, duration_ms
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ANY($1)
+		ORDER BY id
+	`
+	attemptRows, err := r.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer attemptRows.Close()
+	for attemptRows.Next() {
+		a := &Attempt{}
+		err = attemptRows.Scan(
+			&a.ID, &a.DeliveryID, &a.AttemptedAt, &a.StatusCode, &a.Error, &a.DurationMS,
+		)
+		if err != nil {
+			return nil, err
+		}
+		byID[a.DeliveryID].History = append(byID[a.DeliveryID].History, a)
+	}
+	if err = attemptRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Replay has deliveries of the user's webhooks sent again from now, as if they were new: the
+// delivery with the ID, or when it is 0 every failed delivery of the webhook. it returns how many
+func (r *Repository) Replay(userID, deliveryID, webhookID int64, now time.Time) (int, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'PENDING', attempts = 0, next_attempt_at = $1, delivered_at = NULL
+		FROM webhooks
+		WHERE webhooks.id = webhook_deliveries.webhook_id AND webhooks.user_id = $2
+			AND (
+				webhook_deliveries.id = $3
+				OR ($3 = 0 AND webhook_id = $4 AND webhook_deliveries.status = 'FAILED')
+			)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, now, userID, deliveryID, webhookID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
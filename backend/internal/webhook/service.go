@@ -0,0 +1,248 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+const (
+	// how many deliveries are sent per query for the due ones
+	deliveryBatchSize = 50
+	// how long a claimed delivery has to be sent before it is due again
+	claimLease = time.Minute
+	// how long an endpoint has to answer
+	sendTimeout = 10 * time.Second
+	// how many deliveries are listed at most
+	maxDeliveriesListed = 100
+)
+
+type Repo interface {
+	Insert(webhook *Webhook) error
+	GetAllUserWebhooks(userID int64) ([]*Webhook, error)
+	Delete(webhookID, userID int64) error
+	Enqueue(e *event.Event) (int, error)
+	ClaimDue(now, leaseUntil time.Time, limit int) ([]*Delivery, error)
+	RecordAttempt(d *Delivery, attempt *Attempt) error
+	GetDeliveries(webhookID, userID int64, status string, limit int) ([]*Delivery, error)
+	Replay(userID, deliveryID, webhookID int64, now time.Time) (int, error)
+}
+
+// ErrPrivateAddress is why a delivery isn't sent to a webhook whose name resolves to a local or
+// private address
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// Service registers webhooks and sends them their deliveries with Client, defaultClient when it is
+// nil
+type Service struct {
+	Repo   Repo
+	Client *http.Client
+}
+
+// defaultClient sends the deliveries with a timeout of its own. its dialer checks every address it
+// connects to, so a webhook whose name resolves to a private address, or that redirects to one, is
+// not sent anything. it doesn't go through a proxy, which would be the address checked instead
+var defaultClient = &http.Client{
+	Timeout: sendTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: sendTimeout,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+					return ErrPrivateAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: sendTimeout,
+	},
+}
+
+// Register registers the url to be sent the events of the types, with a new secret to check the
+// deliveries with
+func (s *Service) Register(
+	v *validator.Validator, userID int64, url string, eventTypes []string,
+) (*Webhook, error) {
+	webhook := &Webhook{UserID: userID, URL: strings.TrimSpace(url), EventTypes: eventTypes}
+	if ValidateWebhook(v, webhook); !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	webhook.Secret = hex.EncodeToString(secret)
+
+	err := s.Repo.Insert(webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (s *Service) GetAllUserWebhooks(userID int64) ([]*Webhook, error) {
+	return s.Repo.GetAllUserWebhooks(userID)
+}
+
+func (s *Service) Delete(webhookID, userID int64) error {
+	return s.Repo.Delete(webhookID, userID)
+}
+
+// Enqueue queues the event for the webhooks registered for it that may see it, it is subscribed to
+// every event
+func (s *Service) Enqueue(e *event.Event) error {
+	_, err := s.Repo.Enqueue(e)
+	return err
+}
+
+// DeliverDue sends the deliveries that are due at now and returns how many the endpoints took.
+// one that isn't taken is sent again after a wait that doubles every time, until it has been sent
+// MaxAttempts times
+func (s *Service) DeliverDue(now time.Time) (int, error) {
+	delivered := 0
+	for {
+		deliveries, err := s.Repo.ClaimDue(now, now.Add(claimLease), deliveryBatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, d := range deliveries {
+			attempt := s.send(d, now)
+			d.Attempts++
+			switch {
+			case attempt.Error == "":
+				d.Status = StatusDelivered
+				d.DeliveredAt = &attempt.AttemptedAt
+				delivered++
+			case d.Attempts >= MaxAttempts:
+				d.Status = StatusFailed
+			default:
+				d.NextAttemptAt = now.Add(backoff(d.Attempts))
+			}
+
+			if err := s.Repo.RecordAttempt(d, attempt); err != nil {
+				return delivered, err
+			}
+		}
+
+		if len(deliveries) < deliveryBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// send posts the delivery's event to its webhook, signed with its secret. the endpoint takes it by
+// answering with a 2xx status
+func (s *Service) send(d *Delivery, now time.Time) *Attempt {
+	attempt := &Attempt{DeliveryID: d.ID, AttemptedAt: now}
+
+	payload, err := json.Marshal(body{
+		ID:        d.Event.ID,
+		Type:      d.Event.Type,
+		CreatedAt: d.Event.CreatedAt,
+		UserID:    d.Event.UserID,
+		Data:      d.Event.Payload,
+	})
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.Event.Type)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, timestamp, payload))
+
+	client := s.Client
+	if client == nil {
+		client = defaultClient
+	}
+	start := time.Now()
+	res, err := client.Do(req)
+	attempt.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer res.Body.Close()
+	// read a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	attempt.StatusCode = res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("endpoint answered %s", res.Status)
+	}
+
+	return attempt
+}
+
+// GetDeliveries gets the newest deliveries of the user's webhook with what happened each time they
+// were sent, only the ones with the status if one is given
+func (s *Service) GetDeliveries(
+	v *validator.Validator, webhookID, userID int64, status string,
+) ([]*Delivery, error) {
+	status = strings.ToUpper(strings.TrimSpace(status))
+	v.CheckAddError(
+		status == "" || validator.ValueInList(status, StatusPending, StatusDelivered, StatusFailed),
+		"status", "must be PENDING, DELIVERED or FAILED",
+	)
+	if !v.IsValid() {
+		return nil, validator.ErrFailedValidation
+	}
+
+	return s.Repo.GetDeliveries(webhookID, userID, status, maxDeliveriesListed)
+}
+
+// Replay sends the delivery with the ID again, or when none is given every delivery of the webhook
+// that failed, and returns how many will be
+func (s *Service) Replay(
+	v *validator.Validator, userID, deliveryID, webhookID int64, now time.Time,
+) (int, error) {
+	v.CheckAddError(
+		deliveryID != 0 || webhookID != 0, "delivery id", "or webhook id must be given",
+	)
+	if !v.IsValid() {
+		return 0, validator.ErrFailedValidation
+	}
+
+	replayed, err := s.Repo.Replay(userID, deliveryID, webhookID, now)
+	if err != nil {
+		return 0, err
+	}
+	if replayed == 0 && deliveryID != 0 {
+		return 0, user.ErrNoRecord
+	}
+
+	return replayed, nil
+}
@@ -0,0 +1,367 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+type MockRepo struct {
+	Due      []*Delivery
+	Recorded map[int64]*Attempt
+
+	ReplayResult int
+}
+
+func (r *MockRepo) Insert(webhook *Webhook) error {
+	webhook.ID = 1
+	webhook.Active = true
+	return nil
+}
+
+func (r *MockRepo) GetAllUserWebhooks(userID int64) ([]*Webhook, error) {
+	return nil, nil
+}
+
+func (r *MockRepo) Delete(webhookID, userID int64) error {
+	return nil
+}
+
+func (r *MockRepo) Enqueue(e *event.Event) (int, error) {
+	return 0, nil
+}
+
+func (r *MockRepo) ClaimDue(now, leaseUntil time.Time, limit int) ([]*Delivery, error) {
+	var due []*Delivery
+	for _, d := range r.Due {
+		if d.Status == StatusPending && !d.NextAttemptAt.After(now) && len(due) < limit {
+			d.NextAttemptAt = leaseUntil
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (r *MockRepo) RecordAttempt(d *Delivery, attempt *Attempt) error {
+	if r.Recorded == nil {
+		r.Recorded = make(map[int64]*Attempt)
+	}
+	r.Recorded[d.ID] = attempt
+	return nil
+}
+
+func (r *MockRepo) GetDeliveries(
+	webhookID, userID int64, status string, limit int,
+) ([]*Delivery, error) {
+	return nil, nil
+}
+
+func (r *MockRepo) Replay(userID, deliveryID, webhookID int64, now time.Time) (int, error) {
+	return r.ReplayResult, nil
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		eventTypes []string
+		wantErrKey string
+	}{
+		{
+			name:       "valid",
+			url:        " https://accounting.example.com/hooks ",
+			eventTypes: []string{event.TypeTransferCompleted, event.TypeLoanAccepted},
+		},
+		{
+			name:       "every type",
+			url:        "https://accounting.example.com:8443/hooks",
+			eventTypes: []string{event.All},
+		},
+		{
+			name:       "not https",
+			url:        "http://accounting.example.com/hooks",
+			eventTypes: []string{event.TypeDeposit},
+			wantErrKey: "url",
+		},
+		{
+			name:       "localhost",
+			url:        "https://localhost:9000/hooks",
+			eventTypes: []string{event.TypeDeposit},
+			wantErrKey: "url",
+		},
+		{
+			name:       "loopback address",
+			url:        "https://127.0.0.1/hooks",
+			eventTypes: []string{event.TypeDeposit},
+			wantErrKey: "url",
+		},
+		{
+			name:       "private address",
+			url:        "https://10.0.0.7/hooks",
+			eventTypes: []string{event.TypeDeposit},
+			wantErrKey: "url",
+		},
+		{
+			name:       "metadata address",
+			url:        "https://169.254.169.254/latest/meta-data",
+			eventTypes: []string{event.TypeDeposit},
+			wantErrKey: "url",
+		},
+		{
+			name:       "ipv6 loopback",
+			url:        "https://[::1]/hooks",
+			eventTypes: []string{event.TypeDeposit},
+			wantErrKey: "url",
+		},
+		{
+			name:       "no url",
+			eventTypes: []string{event.TypeDeposit},
+			wantErrKey: "url",
+		},
+		{
+			name:       "not http",
+			url:        "ftp://example.com",
+			eventTypes: []string{event.TypeDeposit},
+			wantErrKey: "url",
+		},
+		{
+			name:       "relative url",
+			url:        "/hooks",
+			eventTypes: []string{event.TypeDeposit},
+			wantErrKey: "url",
+		},
+		{
+			name:       "no event types",
+			url:        "https://example.com",
+			wantErrKey: "event types",
+		},
+		{
+			name:       "unknown event type",
+			url:        "https://example.com",
+			eventTypes: []string{"transfer.exploded"},
+			wantErrKey: "event types",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Service{Repo: &MockRepo{}}
+			v := validator.New()
+			webhook, err := s.Register(v, 7, tc.url, tc.eventTypes)
+
+			if tc.wantErrKey != "" {
+				if !errors.Is(err, validator.ErrFailedValidation) {
+					t.Fatalf("expected error %v, got %v", validator.ErrFailedValidation, err)
+				}
+				if _, ok := v.Errors[tc.wantErrKey]; !ok {
+					t.Errorf("expected an error on %q, got %v", tc.wantErrKey, v.Errors)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v %v", err, v.Errors)
+			}
+			if len(webhook.Secret) != 2*secretBytes {
+				t.Errorf(
+					"expected a secret of %d hex digits, got %q", 2*secretBytes, webhook.Secret,
+				)
+			}
+		})
+	}
+}
+
+func TestDeliverDue(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	const secret = "shh"
+
+	tests := []struct {
+		name            string
+		status          int
+		attempts        int
+		unreachable     bool
+		private         bool
+		wantStatus      string
+		wantNextAttempt time.Time
+		wantDelivered   int
+	}{
+		{
+			name:          "taken",
+			status:        http.StatusNoContent,
+			wantStatus:    StatusDelivered,
+			wantDelivered: 1,
+		},
+		{
+			name:            "first failure",
+			status:          http.StatusInternalServerError,
+			wantStatus:      StatusPending,
+			wantNextAttempt: now.Add(30 * time.Second),
+		},
+		{
+			name:            "third failure waits longer",
+			status:          http.StatusServiceUnavailable,
+			attempts:        2,
+			wantStatus:      StatusPending,
+			wantNextAttempt: now.Add(2 * time.Minute),
+		},
+		{
+			name:            "unreachable",
+			unreachable:     true,
+			wantStatus:      StatusPending,
+			wantNextAttempt: now.Add(30 * time.Second),
+		},
+		{
+			name:            "private address",
+			private:         true,
+			wantStatus:      StatusPending,
+			wantNextAttempt: now.Add(30 * time.Second),
+		},
+		{
+			name:       "last attempt",
+			status:     http.StatusBadGateway,
+			attempts:   MaxAttempts - 1,
+			wantStatus: StatusFailed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := event.New(3, event.TransferCompleted{Transfer: event.Transfer{
+				TransferID: 9, Amount: money.MustParse("12.50"), Currency: "USD",
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.ID = 42
+
+			var received map[string]any
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					payload, _ := io.ReadAll(r.Body)
+					timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+					if !Verify(secret, timestamp, payload, r.Header.Get(HeaderSignature)) {
+						t.Errorf("the signature didn't verify")
+					}
+					if r.Header.Get(HeaderEvent) != event.TypeTransferCompleted {
+						t.Errorf(
+							"expected the event type header, got %q", r.Header.Get(HeaderEvent),
+						)
+					}
+					json.Unmarshal(payload, &received)
+					w.WriteHeader(tc.status)
+				},
+			))
+			url := server.URL
+			if tc.unreachable {
+				server.Close()
+			} else {
+				defer server.Close()
+			}
+
+			d := &Delivery{
+				ID: 5, WebhookID: 1, EventID: e.ID, EventType: e.Type, Status: StatusPending,
+				Attempts: tc.attempts, NextAttemptAt: now, URL: url, Secret: secret, Event: e,
+			}
+			repo := &MockRepo{Due: []*Delivery{d}}
+			s := &Service{Repo: repo, Client: server.Client()}
+			// the test server is on the loopback address the default client won't connect to
+			if tc.private {
+				s.Client = nil
+			}
+
+			delivered, err := s.DeliverDue(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if delivered != tc.wantDelivered {
+				t.Errorf("expected %d delivered, got %d", tc.wantDelivered, delivered)
+			}
+			if d.Status != tc.wantStatus || d.Attempts != tc.attempts+1 {
+				t.Errorf(
+					"expected status %s after %d attempts, got %s after %d", tc.wantStatus,
+					tc.attempts+1, d.Status, d.Attempts,
+				)
+			}
+			if d.Status == StatusPending && !d.NextAttemptAt.Equal(tc.wantNextAttempt) {
+				t.Errorf(
+					"expected the next attempt at %v, got %v", tc.wantNextAttempt, d.NextAttemptAt,
+				)
+			}
+
+			attempt := repo.Recorded[d.ID]
+			if attempt == nil {
+				t.Fatal("expected the attempt to be recorded")
+			}
+			if tc.unreachable {
+				if attempt.StatusCode != 0 || attempt.Error == "" {
+					t.Errorf("expected an error without a status, got %+v", attempt)
+				}
+				return
+			}
+			if tc.private {
+				if attempt.StatusCode != 0 ||
+					!strings.Contains(attempt.Error, ErrPrivateAddress.Error()) || received != nil {
+					t.Errorf("expected nothing sent to a private address, got %+v", attempt)
+				}
+				return
+			}
+			if attempt.StatusCode != tc.status {
+				t.Errorf("expected status code %d recorded, got %d", tc.status, attempt.StatusCode)
+			}
+			data, _ := received["data"].(map[string]any)
+			if received["type"] != event.TypeTransferCompleted || data["transfer_id"] != 9.0 {
+				t.Errorf("expected the event in the body, got %v", received)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{20, maxBackoff},
+	}
+
+	for _, tc := range tests {
+		if got := backoff(tc.attempts); got != tc.want {
+			t.Errorf("after %d attempts expected %v, got %v", tc.attempts, tc.want, got)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	s := &Service{Repo: &MockRepo{}}
+
+	v := validator.New()
+	_, err := s.Replay(v, 7, 0, 0, time.Now())
+	if !errors.Is(err, validator.ErrFailedValidation) {
+		t.Errorf("expected error %v without a delivery or webhook, got %v",
+			validator.ErrFailedValidation, err)
+	}
+
+	_, err = s.Replay(validator.New(), 7, 5, 0, time.Now())
+	if !errors.Is(err, user.ErrNoRecord) {
+		t.Errorf("expected error %v for a delivery of someone else, got %v", user.ErrNoRecord, err)
+	}
+
+	// nothing failed is not an error
+	replayed, err := s.Replay(validator.New(), 7, 0, 1, time.Now())
+	if err != nil || replayed != 0 {
+		t.Errorf("expected nothing replayed, got %d %v", replayed, err)
+	}
+}
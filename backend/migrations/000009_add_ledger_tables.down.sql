@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS postings_balanced ON postings;
+
+DROP FUNCTION IF EXISTS check_journal_entry_balanced;
+
+ALTER TABLE postings DROP CONSTRAINT IF EXISTS amount_check;
+
+DROP TABLE IF EXISTS postings;
+
+DROP TABLE IF EXISTS journal_entries;
+
+DROP TABLE IF EXISTS ledger_accounts;
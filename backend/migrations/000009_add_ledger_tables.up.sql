@@ -0,0 +1,70 @@
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    code TEXT UNIQUE NOT NULL, -- 'USER:<id>' for customers, 'SYSTEM:<name>' for the bank's own
+    user_id BIGINT REFERENCES users ON DELETE RESTRICT
+);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    description TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES journal_entries ON DELETE RESTRICT,
+    account_id BIGINT NOT NULL REFERENCES ledger_accounts ON DELETE RESTRICT,
+    amount DECIMAL(12, 2) NOT NULL
+);
+
+ALTER TABLE postings ADD CONSTRAINT amount_check CHECK(amount <> 0);
+
+CREATE INDEX IF NOT EXISTS postings_entry_id_idx ON postings (entry_id);
+CREATE INDEX IF NOT EXISTS postings_account_id_idx ON postings (account_id);
+
+INSERT INTO ledger_accounts (code)
+VALUES
+    ('SYSTEM:CASH'),
+    ('SYSTEM:LOANS'),
+    ('SYSTEM:OPENING_BALANCES');
+
+INSERT INTO ledger_accounts (code, user_id)
+SELECT 'USER:' || id, id FROM users;
+
+-- carry the existing balances over as opening entries so that every balance is backed by postings
+DO $$
+DECLARE
+    u RECORD;
+    new_entry_id BIGINT;
+BEGIN
+    FOR u IN SELECT id, account_balance FROM users WHERE account_balance <> 0 LOOP
+        INSERT INTO journal_entries (description)
+        VALUES ('opening balance')
+        RETURNING id INTO new_entry_id;
+
+        INSERT INTO postings (entry_id, account_id, amount)
+        SELECT new_entry_id, id, u.account_balance
+        FROM ledger_accounts WHERE code = 'USER:' || u.id;
+
+        INSERT INTO postings (entry_id, account_id, amount)
+        SELECT new_entry_id, id, -u.account_balance
+        FROM ledger_accounts WHERE code = 'SYSTEM:OPENING_BALANCES';
+    END LOOP;
+END $$;
+
+-- the postings of an entry must sum to zero, checked when the transaction commits so that all the
+-- legs of the entry can be inserted first
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT COALESCE(SUM(amount), 0) FROM postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % does not balance', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER postings_balanced
+AFTER INSERT OR UPDATE ON postings
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION check_journal_entry_balanced();
@@ -0,0 +1,3 @@
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_user_key;
+
+DROP TABLE IF EXISTS idempotency_keys;
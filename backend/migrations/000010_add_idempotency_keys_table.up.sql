@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expiry TIMESTAMPTZ NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status_code INTEGER, -- null until the first request with the key has finished
+    content_type TEXT NOT NULL DEFAULT '',
+    response_body BYTEA
+);
+
+-- keys are chosen by the clients so they only have to be unique per user
+ALTER TABLE idempotency_keys ADD CONSTRAINT idempotency_keys_user_key UNIQUE (user_id, key);
@@ -0,0 +1,42 @@
+ALTER TABLE loan_requests DROP COLUMN IF EXISTS account_id;
+
+ALTER TABLE loans DROP COLUMN IF EXISTS account_id;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS account_id;
+
+ALTER TABLE transfers DROP COLUMN IF EXISTS to_account_id;
+ALTER TABLE transfers DROP COLUMN IF EXISTS from_account_id;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS account_balance DECIMAL(12, 2) NOT NULL DEFAULT 0.00;
+UPDATE users
+SET account_balance = (SELECT COALESCE(SUM(balance), 0) FROM accounts WHERE user_id = users.id);
+
+-- fold the ledger accounts back into one per user, keeping the oldest of each user's accounts
+ALTER TABLE ledger_accounts ADD COLUMN IF NOT EXISTS user_id BIGINT REFERENCES users ON DELETE RESTRICT;
+
+UPDATE postings
+SET account_id = (
+    SELECT MIN(ledger_accounts.id)
+    FROM ledger_accounts
+    INNER JOIN accounts ON accounts.id = ledger_accounts.account_id
+    WHERE accounts.user_id = (
+        SELECT accounts.user_id FROM accounts
+        INNER JOIN ledger_accounts AS posted ON posted.account_id = accounts.id
+        WHERE posted.id = postings.account_id
+    )
+)
+WHERE account_id IN (SELECT id FROM ledger_accounts WHERE account_id IS NOT NULL);
+
+DELETE FROM ledger_accounts
+WHERE account_id IS NOT NULL AND id NOT IN (SELECT DISTINCT account_id FROM postings);
+
+UPDATE ledger_accounts
+SET user_id = accounts.user_id, code = 'USER:' || accounts.user_id
+FROM accounts
+WHERE ledger_accounts.account_id = accounts.id;
+
+ALTER TABLE ledger_accounts DROP COLUMN IF EXISTS account_id;
+
+DROP TABLE IF EXISTS accounts;
+
+DROP SEQUENCE IF EXISTS account_number_seq;
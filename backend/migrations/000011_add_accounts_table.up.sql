@@ -0,0 +1,56 @@
+CREATE SEQUENCE IF NOT EXISTS account_number_seq START WITH 1000000000;
+
+CREATE TABLE IF NOT EXISTS accounts (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE RESTRICT,
+    number TEXT UNIQUE NOT NULL DEFAULT nextval('account_number_seq')::TEXT,
+    type TEXT NOT NULL DEFAULT 'CHECKING', -- 'CHECKING' or 'SAVINGS'
+    status TEXT NOT NULL DEFAULT 'ACTIVE', -- 'ACTIVE', 'FROZEN' or 'CLOSED'
+    balance DECIMAL(12, 2) NOT NULL DEFAULT 0.00,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS accounts_user_id_idx ON accounts (user_id);
+
+-- every existing user gets a checking account that takes over their balance
+INSERT INTO accounts (user_id, type, balance)
+SELECT id, 'CHECKING', account_balance FROM users ORDER BY id;
+
+-- the user ledger accounts become the ledger accounts of the new accounts, keeping their postings
+ALTER TABLE ledger_accounts ADD COLUMN account_id BIGINT REFERENCES accounts ON DELETE RESTRICT;
+
+UPDATE ledger_accounts
+SET account_id = accounts.id, code = 'ACCOUNT:' || accounts.id
+FROM accounts
+WHERE ledger_accounts.user_id = accounts.user_id;
+
+ALTER TABLE ledger_accounts DROP COLUMN user_id;
+
+-- the balance of a user is now the sum of their accounts
+ALTER TABLE users DROP COLUMN account_balance;
+
+-- point the existing money movements at the accounts that took over the balances
+ALTER TABLE transfers ADD COLUMN from_account_id BIGINT REFERENCES accounts;
+ALTER TABLE transfers ADD COLUMN to_account_id BIGINT REFERENCES accounts;
+UPDATE transfers
+SET from_account_id = (SELECT id FROM accounts WHERE user_id = transfers.from_user_id),
+    to_account_id = (SELECT id FROM accounts WHERE user_id = transfers.to_user_id);
+ALTER TABLE transfers ALTER COLUMN from_account_id SET NOT NULL;
+ALTER TABLE transfers ALTER COLUMN to_account_id SET NOT NULL;
+
+ALTER TABLE transactions ADD COLUMN account_id BIGINT REFERENCES accounts;
+UPDATE transactions
+SET account_id = (SELECT id FROM accounts WHERE user_id = transactions.user_id);
+ALTER TABLE transactions ALTER COLUMN account_id SET NOT NULL;
+
+ALTER TABLE loans ADD COLUMN account_id BIGINT REFERENCES accounts;
+UPDATE loans
+SET account_id = (SELECT id FROM accounts WHERE user_id = loans.user_id);
+ALTER TABLE loans ALTER COLUMN account_id SET NOT NULL;
+
+-- the account an accepted loan is paid into
+ALTER TABLE loan_requests ADD COLUMN account_id BIGINT REFERENCES accounts;
+UPDATE loan_requests
+SET account_id = (SELECT id FROM accounts WHERE user_id = loan_requests.user_id);
+ALTER TABLE loan_requests ALTER COLUMN account_id SET NOT NULL;
@@ -0,0 +1,36 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'MANAGE_FX_RATES');
+DELETE FROM permissions WHERE code = 'MANAGE_FX_RATES';
+
+DROP TABLE IF EXISTS exchange_rates;
+
+ALTER TABLE loan_requests DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE loans DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE transfers DROP COLUMN IF EXISTS spread_amount;
+ALTER TABLE transfers DROP COLUMN IF EXISTS fx_spread;
+ALTER TABLE transfers DROP COLUMN IF EXISTS exchange_rate;
+ALTER TABLE transfers DROP COLUMN IF EXISTS to_currency;
+ALTER TABLE transfers DROP COLUMN IF EXISTS to_amount;
+ALTER TABLE transfers DROP COLUMN IF EXISTS currency;
+
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT COALESCE(SUM(amount), 0) FROM postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % does not balance', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- this only works while nothing but dollars has been posted, the codes are unique again after it
+ALTER TABLE postings DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_code_currency_key;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_code_key UNIQUE (code);
+ALTER TABLE ledger_accounts DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS currency;
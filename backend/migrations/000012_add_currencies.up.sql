@@ -0,0 +1,74 @@
+-- everything recorded so far was in dollars
+ALTER TABLE accounts ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+-- the bank's own ledger accounts are kept once per currency, customer accounts only ever have the
+-- currency of the account they belong to
+ALTER TABLE ledger_accounts ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE ledger_accounts ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE ledger_accounts DROP CONSTRAINT ledger_accounts_code_key;
+ALTER TABLE ledger_accounts
+ADD CONSTRAINT ledger_accounts_code_currency_key UNIQUE (code, currency);
+
+ALTER TABLE postings ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE postings ALTER COLUMN currency DROP DEFAULT;
+
+-- an entry can move money between currencies through the bank's FX accounts, so it has to balance
+-- in each currency on its own rather than overall
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM postings
+        WHERE entry_id = NEW.entry_id
+        GROUP BY currency
+        HAVING SUM(amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'journal entry % does not balance', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- the amount leaves the sender in the currency of their account and reaches the recipient as
+-- to_amount in the currency of theirs, exchange_rate is 1 and fx_spread 0 when nothing is converted
+ALTER TABLE transfers ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE transfers ADD COLUMN to_amount DECIMAL(12, 2);
+ALTER TABLE transfers ADD COLUMN to_currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE transfers ADD COLUMN exchange_rate NUMERIC(20, 10) NOT NULL DEFAULT 1;
+ALTER TABLE transfers ADD COLUMN fx_spread NUMERIC(8, 6) NOT NULL DEFAULT 0;
+ALTER TABLE transfers ADD COLUMN spread_amount DECIMAL(12, 2) NOT NULL DEFAULT 0.00;
+UPDATE transfers SET to_amount = amount;
+ALTER TABLE transfers ALTER COLUMN to_amount SET NOT NULL;
+ALTER TABLE transfers ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE transfers ALTER COLUMN to_currency DROP DEFAULT;
+
+ALTER TABLE transactions ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE transactions ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE loans ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE loans ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE loan_requests ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE loan_requests ALTER COLUMN currency DROP DEFAULT;
+
+-- one unit of the base currency buys rate units of the quote currency. a rate applies from its
+-- effective_at until a newer one for the same pair takes over, older rates are kept for the record
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    base_currency CHAR(3) NOT NULL,
+    quote_currency CHAR(3) NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL,
+    spread NUMERIC(8, 6) NOT NULL DEFAULT 0, -- the fraction of the converted amount the bank keeps
+    effective_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE exchange_rates ADD CONSTRAINT rate_check CHECK(rate > 0);
+ALTER TABLE exchange_rates ADD CONSTRAINT spread_check CHECK(spread >= 0 AND spread < 1);
+ALTER TABLE exchange_rates ADD CONSTRAINT currencies_check CHECK(base_currency <> quote_currency);
+
+CREATE INDEX IF NOT EXISTS exchange_rates_pair_idx
+ON exchange_rates (base_currency, quote_currency, effective_at DESC);
+
+INSERT INTO permissions (code)
+VALUES ('MANAGE_FX_RATES')
+ON CONFLICT (code) DO NOTHING;
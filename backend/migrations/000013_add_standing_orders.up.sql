@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS standing_orders (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE RESTRICT,
+    from_account_id BIGINT NOT NULL REFERENCES accounts ON DELETE RESTRICT,
+    to_account_id BIGINT NOT NULL REFERENCES accounts ON DELETE RESTRICT,
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    frequency TEXT NOT NULL, -- 'ONCE', 'WEEKLY', 'MONTHLY' or 'LAST_BUSINESS_DAY'
+    start_at TIMESTAMPTZ NOT NULL,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'ACTIVE', -- 'ACTIVE', 'PAUSED', 'CANCELLED', 'COMPLETED' or 'FAILED'
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS standing_orders_user_id_idx ON standing_orders (user_id);
+
+-- the scheduler only ever looks for active orders that are due
+CREATE INDEX IF NOT EXISTS standing_orders_due_idx
+ON standing_orders (next_run_at) WHERE status = 'ACTIVE';
+
+-- every time an order is executed, whether the transfer went through or not
+CREATE TABLE IF NOT EXISTS standing_order_runs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    standing_order_id BIGINT NOT NULL REFERENCES standing_orders ON DELETE CASCADE,
+    transfer_id BIGINT REFERENCES transfers ON DELETE RESTRICT,
+    status TEXT NOT NULL, -- 'SUCCEEDED' or 'FAILED'
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS standing_order_runs_standing_order_id_idx
+ON standing_order_runs (standing_order_id);
@@ -0,0 +1,15 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'REVERSE_TRANSFERS');
+DELETE FROM permissions WHERE code = 'REVERSE_TRANSFERS';
+
+DROP INDEX IF EXISTS transfers_reversal_of_idx;
+
+ALTER TABLE transfers DROP CONSTRAINT IF EXISTS transfers_reversal_check;
+ALTER TABLE transfers DROP CONSTRAINT IF EXISTS transfers_reversed_amount_check;
+
+ALTER TABLE transfers DROP COLUMN IF EXISTS reversed_amount;
+ALTER TABLE transfers DROP COLUMN IF EXISTS reason;
+ALTER TABLE transfers DROP COLUMN IF EXISTS reversed_by;
+ALTER TABLE transfers DROP COLUMN IF EXISTS reversal_of;
+ALTER TABLE transfers DROP COLUMN IF EXISTS status;
+ALTER TABLE transfers DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,20 @@
+-- a reversal is a transfer of its own going the other way, linked to the one it reverses
+ALTER TABLE transfers ADD COLUMN kind TEXT NOT NULL DEFAULT 'TRANSFER'; -- 'TRANSFER', 'REFUND' or 'REVERSAL'
+ALTER TABLE transfers ADD COLUMN status TEXT NOT NULL DEFAULT 'COMPLETED'; -- 'COMPLETED', 'PARTIALLY_REVERSED' or 'REVERSED'
+ALTER TABLE transfers ADD COLUMN reversal_of BIGINT REFERENCES transfers ON DELETE RESTRICT;
+ALTER TABLE transfers ADD COLUMN reversed_by BIGINT REFERENCES users ON DELETE RESTRICT;
+ALTER TABLE transfers ADD COLUMN reason TEXT NOT NULL DEFAULT '';
+
+-- how much of what the recipient got has been sent back, never more than they got
+ALTER TABLE transfers ADD COLUMN reversed_amount DECIMAL(12, 2) NOT NULL DEFAULT 0.00;
+ALTER TABLE transfers ADD CONSTRAINT transfers_reversed_amount_check
+CHECK (reversed_amount >= 0 AND reversed_amount <= to_amount);
+
+ALTER TABLE transfers ADD CONSTRAINT transfers_reversal_check
+CHECK ((kind = 'TRANSFER') = (reversal_of IS NULL));
+
+CREATE INDEX IF NOT EXISTS transfers_reversal_of_idx ON transfers (reversal_of);
+
+INSERT INTO permissions (code)
+VALUES ('REVERSE_TRANSFERS')
+ON CONFLICT (code) DO NOTHING;
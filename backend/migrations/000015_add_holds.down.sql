@@ -0,0 +1,5 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'MANAGE_HOLDS');
+DELETE FROM permissions WHERE code = 'MANAGE_HOLDS';
+
+DROP TABLE IF EXISTS holds;
@@ -0,0 +1,30 @@
+-- a hold sets aside part of an account's balance for a debit that is still pending. it only counts
+-- while it is active and has not expired, so an expired hold frees the money without anything
+-- having to run
+CREATE TABLE IF NOT EXISTS holds (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE RESTRICT,
+    account_id BIGINT NOT NULL REFERENCES accounts ON DELETE RESTRICT,
+    currency CHAR(3) NOT NULL,
+    kind TEXT NOT NULL, -- 'CARD', 'WITHDRAWAL' or 'REVIEW'
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    description TEXT NOT NULL DEFAULT '',
+    placed_by TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL DEFAULT 'ACTIVE', -- 'ACTIVE', 'CAPTURED' or 'RELEASED'
+    captured_amount DECIMAL(12, 2) NOT NULL DEFAULT 0.00,
+    resolved_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1,
+    CHECK (captured_amount >= 0 AND captured_amount <= amount)
+);
+
+CREATE INDEX IF NOT EXISTS holds_user_id_idx ON holds (user_id);
+
+-- the available balance of an account sums its active holds on every debit
+CREATE INDEX IF NOT EXISTS holds_active_account_id_idx
+ON holds (account_id, expires_at) WHERE status = 'ACTIVE';
+
+INSERT INTO permissions (code)
+VALUES ('MANAGE_HOLDS')
+ON CONFLICT (code) DO NOTHING;
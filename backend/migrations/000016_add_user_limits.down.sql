@@ -0,0 +1,8 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'MANAGE_LIMITS');
+DELETE FROM permissions WHERE code = 'MANAGE_LIMITS';
+
+DROP INDEX IF EXISTS transactions_user_id_created_at_idx;
+DROP INDEX IF EXISTS transfers_from_user_id_created_at_idx;
+
+DROP TABLE IF EXISTS user_limits;
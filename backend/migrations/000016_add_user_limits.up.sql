@@ -0,0 +1,19 @@
+-- a user's own transfer and withdrawal limits, set by an admin. a NULL limit falls back to the
+-- default the server is configured with
+CREATE TABLE IF NOT EXISTS user_limits (
+    user_id BIGINT PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    per_transaction DECIMAL(12, 2) CHECK (per_transaction >= 0),
+    daily DECIMAL(12, 2) CHECK (daily >= 0),
+    weekly DECIMAL(12, 2) CHECK (weekly >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- the limits sum what the user sent and withdrew over the last day and week
+CREATE INDEX IF NOT EXISTS transfers_from_user_id_created_at_idx
+ON transfers (from_user_id, created_at);
+CREATE INDEX IF NOT EXISTS transactions_user_id_created_at_idx
+ON transactions (user_id, created_at);
+
+INSERT INTO permissions (code)
+VALUES ('MANAGE_LIMITS')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS journal_entries_created_at_idx;
+
+DROP TABLE IF EXISTS statement_deliveries;
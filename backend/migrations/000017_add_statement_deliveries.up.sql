@@ -0,0 +1,10 @@
+-- the monthly statements that have been emailed, so that each is only sent once
+CREATE TABLE IF NOT EXISTS statement_deliveries (
+    account_id BIGINT NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    period_start DATE NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (account_id, period_start)
+);
+
+-- statements read an account's postings by date
+CREATE INDEX IF NOT EXISTS journal_entries_created_at_idx ON journal_entries (created_at);
@@ -0,0 +1,6 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'RECONCILE');
+DELETE FROM permissions WHERE code = 'RECONCILE';
+
+DROP TABLE IF EXISTS reconciliation_discrepancies;
+DROP TABLE IF EXISTS reconciliation_runs;
@@ -0,0 +1,28 @@
+-- every reconciliation run, with how many accounts it checked and how many were off
+CREATE TABLE IF NOT EXISTS reconciliation_runs (
+    id BIGSERIAL PRIMARY KEY,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    triggered_by TEXT NOT NULL,
+    accounts_checked INTEGER NOT NULL DEFAULT 0,
+    discrepancies INTEGER NOT NULL DEFAULT 0
+);
+
+-- an account whose balance did not match what its money movements add up to, or its ledger postings
+CREATE TABLE IF NOT EXISTS reconciliation_discrepancies (
+    id BIGSERIAL PRIMARY KEY,
+    run_id BIGINT NOT NULL REFERENCES reconciliation_runs ON DELETE CASCADE,
+    account_id BIGINT NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    currency CHAR(3) NOT NULL,
+    expected_balance DECIMAL(12, 2) NOT NULL,
+    ledger_balance DECIMAL(12, 2) NOT NULL,
+    account_balance DECIMAL(12, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS reconciliation_discrepancies_run_id_idx
+ON reconciliation_discrepancies (run_id);
+
+INSERT INTO permissions (code)
+VALUES ('RECONCILE')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,11 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'MANAGE_ACCOUNTS');
+DELETE FROM permissions WHERE code = 'MANAGE_ACCOUNTS';
+
+DROP INDEX IF EXISTS loans_account_id_idx;
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_status_check;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS closed_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status_changed_by;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status_reason;
@@ -0,0 +1,14 @@
+-- why an account was frozen, unfrozen or closed and who did it, and when it was closed
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_changed_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ;
+
+ALTER TABLE accounts ADD CONSTRAINT accounts_status_check
+CHECK (status IN ('ACTIVE', 'FROZEN', 'CLOSED'));
+
+-- closing an account checks for loans on it that are not paid off
+CREATE INDEX IF NOT EXISTS loans_account_id_idx ON loans (account_id);
+
+INSERT INTO permissions (code)
+VALUES ('MANAGE_ACCOUNTS')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,9 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'MANAGE_OVERDRAFTS');
+DELETE FROM permissions WHERE code = 'MANAGE_OVERDRAFTS';
+
+DROP INDEX IF EXISTS accounts_overdrawn_idx;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_interest_from;
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_daily_interest_rate;
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_limit;
@@ -0,0 +1,16 @@
+-- how far an account may go below zero and the daily interest, in percent, charged on what it owes.
+-- overdraft_interest_from is when interest is next counted from, NULL while the account is not
+-- overdrawn
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit DECIMAL(12, 2) NOT NULL DEFAULT 0.00
+CHECK (overdraft_limit >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_daily_interest_rate DECIMAL(6, 4)
+NOT NULL DEFAULT 0 CHECK (overdraft_daily_interest_rate >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_interest_from TIMESTAMPTZ;
+
+-- the interest job only looks at the accounts that are, or just were, overdrawn
+CREATE INDEX IF NOT EXISTS accounts_overdrawn_idx ON accounts (id)
+WHERE balance < 0 OR overdraft_interest_from IS NOT NULL;
+
+INSERT INTO permissions (code)
+VALUES ('MANAGE_OVERDRAFTS')
+ON CONFLICT (code) DO NOTHING;
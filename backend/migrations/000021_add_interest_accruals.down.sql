@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS interest_accrual_days;
+
+DROP INDEX IF EXISTS interest_accruals_unpaid_idx;
+DROP TABLE IF EXISTS interest_accruals;
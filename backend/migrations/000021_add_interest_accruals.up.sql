@@ -0,0 +1,20 @@
+-- the interest a savings account earned each day, kept to a fraction of a cent until it is paid
+-- into the account with the rest of the month's. transaction_id is the INTEREST transaction it was
+-- paid in, NULL until then. a day can only be accrued once for an account
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    account_id BIGINT NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    day DATE NOT NULL,
+    balance DECIMAL(12, 2) NOT NULL,
+    amount NUMERIC(20, 8) NOT NULL CHECK (amount >= 0),
+    transaction_id BIGINT REFERENCES transactions,
+    PRIMARY KEY (account_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS interest_accruals_unpaid_idx
+ON interest_accruals (account_id) WHERE transaction_id IS NULL;
+
+-- the days interest has been accrued for, so the engine knows where to carry on after a restart
+CREATE TABLE IF NOT EXISTS interest_accrual_days (
+    day DATE PRIMARY KEY,
+    accrued_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+ALTER TABLE transfers
+    DROP COLUMN IF EXISTS first_payment,
+    DROP COLUMN IF EXISTS payee_id;
+
+DROP TABLE IF EXISTS payees;
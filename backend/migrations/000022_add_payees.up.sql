@@ -0,0 +1,18 @@
+-- the recipients a user has saved under a name of their own, so they don't have to type the email
+-- or account number every time. first_paid_at is when the user first sent the payee money, NULL
+-- until then
+CREATE TABLE IF NOT EXISTS payees (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    account_id BIGINT NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    first_paid_at TIMESTAMPTZ,
+    CONSTRAINT payees_user_id_account_id_key UNIQUE (user_id, account_id),
+    CONSTRAINT payees_user_id_name_key UNIQUE (user_id, name)
+);
+
+-- the payee a transfer was made to, if any, and whether it was the first payment to them
+ALTER TABLE transfers
+    ADD COLUMN IF NOT EXISTS payee_id BIGINT REFERENCES payees ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS first_payment BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,6 @@
+UPDATE accounts
+SET number = LEFT(number, LENGTH(number) - 2);
+
+ALTER TABLE accounts ALTER COLUMN number SET DEFAULT nextval('account_number_seq')::TEXT;
+
+DROP FUNCTION IF EXISTS next_account_number();
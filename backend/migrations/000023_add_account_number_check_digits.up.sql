@@ -0,0 +1,13 @@
+-- account numbers end in two check digits, worked out like an IBAN's (ISO 7064 mod 97-10), so that
+-- a mistyped number is caught before it is looked up. the whole number leaves 1 divided by 97
+CREATE OR REPLACE FUNCTION next_account_number() RETURNS TEXT AS $$
+    SELECT n::TEXT || LPAD((98 - (n * 100) % 97)::TEXT, 2, '0')
+    FROM nextval('account_number_seq') AS n
+$$ LANGUAGE SQL;
+
+ALTER TABLE accounts ALTER COLUMN number SET DEFAULT next_account_number();
+
+-- the existing accounts get check digits on the end of their numbers, which can't clash with any
+-- number before them as they are two digits longer
+UPDATE accounts
+SET number = number || LPAD((98 - (number::BIGINT * 100) % 97)::TEXT, 2, '0');
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS transfers_payment_request_id_key;
+ALTER TABLE transfers DROP COLUMN IF EXISTS payment_request_id;
+
+DROP TABLE IF EXISTS payment_requests;
@@ -0,0 +1,29 @@
+-- a user asking another for money, to be paid into to_account_id in its currency. a request that
+-- is not paid or declined by expires_at expires
+CREATE TABLE IF NOT EXISTS payment_requests (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    requester_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    to_account_id BIGINT NOT NULL REFERENCES accounts ON DELETE RESTRICT,
+    payer_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    note TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'PENDING', -- 'PENDING', 'PAID', 'DECLINED' or 'EXPIRED'
+    expires_at TIMESTAMPTZ NOT NULL,
+    responded_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS payment_requests_requester_id_idx ON payment_requests (requester_id);
+CREATE INDEX IF NOT EXISTS payment_requests_payer_id_idx ON payment_requests (payer_id);
+
+-- the scheduler only ever looks for pending requests that have run out of time
+CREATE INDEX IF NOT EXISTS payment_requests_expiry_idx
+ON payment_requests (expires_at) WHERE status = 'PENDING';
+
+-- the transfer that paid a request, a request can only ever be paid once
+ALTER TABLE transfers
+    ADD COLUMN IF NOT EXISTS payment_request_id BIGINT REFERENCES payment_requests ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS transfers_payment_request_id_key
+ON transfers (payment_request_id);
@@ -0,0 +1,6 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'BULK_TRANSFERS');
+DELETE FROM permissions WHERE code = 'BULK_TRANSFERS';
+
+DROP TABLE IF EXISTS transfer_batch_rows;
+DROP TABLE IF EXISTS transfer_batches;
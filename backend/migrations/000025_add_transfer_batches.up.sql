@@ -0,0 +1,43 @@
+-- a file of transfers uploaded to be made from one account, like a payroll. the batch is picked up
+-- by the scheduler, and either all its transfers are made or none (ALL_OR_NOTHING), or each is made
+-- on its own (BEST_EFFORT)
+CREATE TABLE IF NOT EXISTS transfer_batches (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    from_account_id BIGINT NOT NULL REFERENCES accounts ON DELETE RESTRICT,
+    mode TEXT NOT NULL, -- 'ALL_OR_NOTHING' or 'BEST_EFFORT'
+    total DECIMAL(12, 2) NOT NULL CHECK (total > 0),
+    row_count INTEGER NOT NULL,
+    -- 'PENDING', 'PROCESSING', 'COMPLETED', 'PARTIALLY_COMPLETED' or 'FAILED'
+    status TEXT NOT NULL DEFAULT 'PENDING',
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS transfer_batches_user_id_idx ON transfer_batches (user_id);
+
+-- the scheduler only ever looks for the batches still to be run
+CREATE INDEX IF NOT EXISTS transfer_batches_pending_idx
+ON transfer_batches (created_at) WHERE status = 'PENDING';
+
+-- one row of the file, the recipient as it was given. transfer_id is only set once it is made
+CREATE TABLE IF NOT EXISTS transfer_batch_rows (
+    id BIGSERIAL PRIMARY KEY,
+    batch_id BIGINT NOT NULL REFERENCES transfer_batches ON DELETE CASCADE,
+    line INTEGER NOT NULL,
+    to_account_number TEXT NOT NULL DEFAULT '',
+    to_email CITEXT NOT NULL DEFAULT '',
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    reference TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'PENDING', -- 'PENDING', 'SUCCEEDED', 'FAILED' or 'SKIPPED'
+    error TEXT NOT NULL DEFAULT '',
+    transfer_id BIGINT REFERENCES transfers ON DELETE SET NULL,
+    UNIQUE (batch_id, line)
+);
+
+INSERT INTO permissions (code)
+VALUES ('BULK_TRANSFERS')
+ON CONFLICT (code) DO NOTHING;
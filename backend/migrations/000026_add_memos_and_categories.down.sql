@@ -0,0 +1,10 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE IF EXISTS transfer_categories;
+DROP TABLE IF EXISTS categories;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS reference;
+ALTER TABLE transactions DROP COLUMN IF EXISTS memo;
+
+ALTER TABLE transfers DROP COLUMN IF EXISTS reference;
+ALTER TABLE transfers DROP COLUMN IF EXISTS memo;
@@ -0,0 +1,33 @@
+-- what the sender says about a transfer, or the staff about a deposit or withdrawal. the reference
+-- is a short one the recipient can match the money up with, like an invoice number
+ALTER TABLE transfers
+    ADD COLUMN IF NOT EXISTS memo TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS reference TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS memo TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS reference TEXT NOT NULL DEFAULT '';
+
+-- the categories a user files their own transfers and transactions under, like rent or groceries
+CREATE TABLE IF NOT EXISTS categories (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    name CITEXT NOT NULL,
+    CONSTRAINT categories_user_id_name_key UNIQUE (user_id, name)
+);
+
+-- both sides of a transfer can file it under a category of their own
+CREATE TABLE IF NOT EXISTS transfer_categories (
+    transfer_id BIGINT NOT NULL REFERENCES transfers ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    category_id BIGINT NOT NULL REFERENCES categories ON DELETE CASCADE,
+    PRIMARY KEY (transfer_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS transfer_categories_category_id_idx
+ON transfer_categories (category_id);
+
+-- a transaction only has the one user
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS category_id BIGINT REFERENCES categories ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS loans_user_id_created_at_idx;
+
+DROP TABLE IF EXISTS budget_alerts;
+DROP TABLE IF EXISTS budgets;
@@ -0,0 +1,27 @@
+-- how much a user means to spend a month on one of their categories. spending is never converted,
+-- so a budget only counts what was spent in its own currency
+CREATE TABLE IF NOT EXISTS budgets (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    category_id BIGINT NOT NULL REFERENCES categories ON DELETE CASCADE,
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    -- whether the user is emailed when they go over it
+    alert BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT budgets_category_id_currency_key UNIQUE (category_id, currency)
+);
+
+CREATE INDEX IF NOT EXISTS budgets_user_id_idx ON budgets (user_id);
+
+-- the months a budget's alert has been sent for, so that it is only sent once a month
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    budget_id BIGINT NOT NULL REFERENCES budgets ON DELETE CASCADE,
+    month DATE NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (budget_id, month)
+);
+
+-- the insights read a user's loan payments by month
+CREATE INDEX IF NOT EXISTS loans_user_id_created_at_idx ON loans (user_id, created_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS loan_requests_user_id_created_at_idx;
+DROP INDEX IF EXISTS transfers_to_user_id_created_at_idx;
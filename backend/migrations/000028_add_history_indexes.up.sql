@@ -0,0 +1,6 @@
+-- the history endpoints page through what a user received and requested newest first, what they
+-- sent, transacted and borrowed already has an index
+CREATE INDEX IF NOT EXISTS transfers_to_user_id_created_at_idx
+ON transfers (to_user_id, created_at);
+CREATE INDEX IF NOT EXISTS loan_requests_user_id_created_at_idx
+ON loan_requests (user_id, created_at);
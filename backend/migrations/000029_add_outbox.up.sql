@@ -0,0 +1,15 @@
+-- every change to the state of the bank writes an event here in the same transaction, and the
+-- dispatcher publishes them to the subscribers in the server. the events are kept once published
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    type TEXT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_user_id_idx ON outbox (user_id);
@@ -0,0 +1,7 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'MANAGE_WEBHOOKS');
+DELETE FROM permissions WHERE code = 'MANAGE_WEBHOOKS';
+
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,48 @@
+-- endpoints outside the bank that are sent the events of the types they were registered for,
+-- signed with their secret. a user's webhooks are only sent their own events, an ADMIN's or
+-- SUPERUSER's those of every user
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types TEXT[] NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_user_id_idx ON webhooks (user_id);
+
+-- an event to be sent to a webhook. it is tried until the endpoint takes it or it has been tried
+-- too many times, waiting longer after each failure
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    webhook_id BIGINT NOT NULL REFERENCES webhooks ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES outbox ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'PENDING',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ,
+    CONSTRAINT webhook_deliveries_webhook_id_event_id_key UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx
+ON webhook_deliveries (next_attempt_at) WHERE status = 'PENDING';
+
+-- every time a delivery was sent, what the endpoint answered or why it couldn't be reached
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries ON DELETE CASCADE,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    status_code INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery_id_idx
+ON webhook_delivery_attempts (delivery_id);
+
+INSERT INTO permissions (code)
+VALUES ('MANAGE_WEBHOOKS')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,6 @@
+DELETE FROM users_permissions
+WHERE permission_id = (SELECT id FROM permissions WHERE code = 'REVIEW_FRAUD');
+DELETE FROM permissions WHERE code = 'REVIEW_FRAUD';
+
+DROP TABLE IF EXISTS fraud_rule_hits;
+DROP TABLE IF EXISTS fraud_screenings;
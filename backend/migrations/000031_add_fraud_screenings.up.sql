@@ -0,0 +1,49 @@
+-- the decision on every transfer and withdrawal screened for fraud. the ones held for review wait
+-- with review_status PENDING, their money set aside by the hold hold_id, until staff approve or
+-- reject them. an approved one is made as it is approved, and keeps the transfer or transaction
+-- it made
+CREATE TABLE IF NOT EXISTS fraud_screenings (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    kind TEXT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    account_id BIGINT NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    to_account_id BIGINT REFERENCES accounts ON DELETE CASCADE,
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    currency TEXT NOT NULL,
+    decision TEXT NOT NULL,
+    memo TEXT NOT NULL DEFAULT '',
+    reference TEXT NOT NULL DEFAULT '',
+    performed_by TEXT NOT NULL DEFAULT '',
+    payee_id BIGINT REFERENCES payees ON DELETE SET NULL,
+    payment_request_id BIGINT REFERENCES payment_requests ON DELETE SET NULL,
+    review_status TEXT,
+    hold_id BIGINT REFERENCES holds ON DELETE SET NULL,
+    reviewed_by BIGINT REFERENCES users ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    review_note TEXT NOT NULL DEFAULT '',
+    transfer_id BIGINT REFERENCES transfers ON DELETE SET NULL,
+    transaction_id BIGINT REFERENCES transactions ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS fraud_screenings_review_idx
+ON fraud_screenings (review_status, id) WHERE review_status IS NOT NULL;
+
+-- a payment request can't be paid again while a payment of it waits for review
+CREATE UNIQUE INDEX IF NOT EXISTS fraud_screenings_payment_request_review_idx
+ON fraud_screenings (payment_request_id) WHERE review_status = 'PENDING';
+
+-- the rules each screening set off
+CREATE TABLE IF NOT EXISTS fraud_rule_hits (
+    id BIGSERIAL PRIMARY KEY,
+    screening_id BIGINT NOT NULL REFERENCES fraud_screenings ON DELETE CASCADE,
+    rule TEXT NOT NULL,
+    action TEXT NOT NULL,
+    reason TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS fraud_rule_hits_screening_id_idx ON fraud_rule_hits (screening_id);
+
+INSERT INTO permissions (code)
+VALUES ('REVIEW_FRAUD')
+ON CONFLICT (code) DO NOTHING;
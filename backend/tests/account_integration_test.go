@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestAccountLifecycle freezes an account, which should still be paid into but not send, and then
+// closes it, paying out what is left so that nothing stays behind in it
+func TestAccountLifecycle(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	frozen, err := accountSvc.GetUserAccount(users[1].ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.ValidNumber(frozen.Number) {
+		t.Errorf("expected a number with valid check digits, got %s", frozen.Number)
+	}
+	frozen, err = accountSvc.Freeze(validator.New(), frozen.Number, "suspicious logins", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(from, to *user.User) error {
+		_, _, err := transferSvc.TransferMoney(
+			validator.New(), from, "", "", to.Email, money.MustParse("10"), "", "",
+		)
+		return err
+	}
+
+	if err := send(users[0], users[1]); err != nil {
+		t.Fatalf("expected a frozen account to be paid into, got %v", err)
+	}
+	if err := send(users[1], users[0]); !errors.Is(err, validator.ErrFailedValidation) {
+		t.Fatalf("expected error %v sending from a frozen account, got %v",
+			validator.ErrFailedValidation, err)
+	}
+
+	_, err = accountSvc.Close(validator.New(), frozen.Number, "customer left", "admin", false)
+	if !errors.Is(err, validator.ErrFailedValidation) {
+		t.Fatalf("expected error %v closing an account with money in it, got %v",
+			validator.ErrFailedValidation, err)
+	}
+
+	closed, err := accountSvc.Close(validator.New(), frozen.Number, "customer left", "admin", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.Status != account.StatusClosed || closed.Balance != 0 || closed.ClosedAt == nil {
+		t.Errorf("expected a closed, empty account, got %+v", closed)
+	}
+
+	got, err := userSvc.GetUser(users[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccountBalance != 0 {
+		t.Errorf("expected account balance=0.00 after the payout, got %v", got.AccountBalance)
+	}
+
+	if err := send(users[0], users[1]); !errors.Is(err, validator.ErrFailedValidation) {
+		t.Fatalf("expected error %v sending to a user with no open account, got %v",
+			validator.ErrFailedValidation, err)
+	}
+}
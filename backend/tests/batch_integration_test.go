@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/batch"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestTransferBatches runs three batches, the first goes through, then the payer spends some of
+// the money before the other two are run. the all or nothing one fails without moving anything and
+// the best effort one makes what it still can
+func TestTransferBatches(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc := &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	batchSvc := &batch.Service{
+		Repo:            &batch.Repository{DB: testDB},
+		UserService:     userSvc,
+		AccountService:  accountSvc,
+		TransferService: transferSvc,
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+		{Name: "ali", Email: "a@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payer := users[0]
+	payer.AccountBalance = money.MustParse("100")
+	seedBalance(payer)
+
+	upload := func(mode string, amounts ...string) *batch.Batch {
+		t.Helper()
+		file := "to_email,amount,reference\n" +
+			users[1].Email + "," + amounts[0] + ",first\n" +
+			users[2].Email + "," + amounts[1] + ",second\n"
+		v := validator.New()
+		b, err := batchSvc.Upload(v, payer, "", mode, strings.NewReader(file))
+		if err != nil {
+			t.Fatalf("%v %v", err, v.Errors)
+		}
+		return b
+	}
+	process := func(want int) {
+		t.Helper()
+		processed, err := batchSvc.ProcessPending(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if processed != want {
+			t.Errorf("expected %d batches processed, got %d", want, processed)
+		}
+	}
+
+	first := upload(batch.ModeAllOrNothing, "30", "20")
+	process(1)
+
+	// more than the 50 left in total
+	v := validator.New()
+	_, err := batchSvc.Upload(
+		v, payer, "", batch.ModeBestEffort,
+		strings.NewReader("to_email,amount\n"+users[1].Email+",60\n"),
+	)
+	checkErr(t, err, validator.ErrFailedValidation, "uploading more than the balance")
+
+	allOrNothing := upload(batch.ModeAllOrNothing, "30", "20")
+	bestEffort := upload(batch.ModeBestEffort, "30", "15")
+	_, _, err = transferSvc.TransferMoney(
+		validator.New(), payer, "", "", users[1].Email, money.MustParse("20"), "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	process(2)
+
+	tests := []struct {
+		b          *batch.Batch
+		wantStatus string
+		wantRows   []string
+	}{
+		{first, batch.StatusCompleted, []string{batch.RowSucceeded, batch.RowSucceeded}},
+		{allOrNothing, batch.StatusFailed, []string{batch.RowSkipped, batch.RowSkipped}},
+		{
+			bestEffort, batch.StatusPartiallyCompleted,
+			[]string{batch.RowSucceeded, batch.RowFailed},
+		},
+	}
+	for _, tc := range tests {
+		got, err := batchSvc.GetBatch(tc.b.ID, payer.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != tc.wantStatus {
+			t.Errorf("batch %d: expected status %s, got %s", got.ID, tc.wantStatus, got.Status)
+		}
+		for i, row := range got.Rows {
+			if row.Status != tc.wantRows[i] {
+				t.Errorf(
+					"batch %d line %d: expected status %s, got %s", got.ID, row.Line,
+					tc.wantRows[i], row.Status,
+				)
+			}
+		}
+	}
+
+	wantBalances := []string{"0", "80", "20"}
+	for i, u := range users {
+		a, err := accountSvc.GetUserAccount(u.ID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := money.MustParse(wantBalances[i]); a.Balance != want {
+			t.Errorf("%s: expected balance=%v, got %v", u.Name, want, a.Balance)
+		}
+	}
+}
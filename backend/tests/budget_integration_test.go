@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/budget"
+	"github.com/Yusufdot101/goBankBackend/internal/category"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transaction"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// budgetMailer keeps who it emailed
+type budgetMailer struct {
+	sent []string
+}
+
+func (m *budgetMailer) Send(recipient, templateFile string, data map[string]any) error {
+	m.sent = append(m.sent, recipient)
+	return nil
+}
+
+// TestBudgets spends on a category by a transfer and a withdrawal, and moves money between the
+// user's own accounts, which is not spending. the budget for the category is gone over, so its
+// alert is sent, once
+func TestBudgets(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	ledgerSvc = &ledger.Service{Repo: &ledger.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	transactionSvc := &transaction.Service{
+		Repo:           &transaction.Repository{DB: testDB},
+		AccountService: accountSvc,
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	categorySvc := &category.Service{Repo: &category.Repository{DB: testDB}}
+	mail := &budgetMailer{}
+	budgetSvc := &budget.Service{
+		Repo:           &budget.Repository{DB: testDB},
+		Categories:     categorySvc,
+		AccountService: accountSvc,
+		Mailer:         mail,
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+	// only activated users are sent alerts
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := testDB.ExecContext(ctx, "UPDATE users SET activated = TRUE"); err != nil {
+		t.Fatal(err)
+	}
+	savings, err := accountSvc.Open(validator.New(), users[0].ID, account.TypeSavings, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := categorySvc.New(validator.New(), users[0].ID, "rent"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := budgetSvc.Save(validator.New(), users[0].ID, "rent", money.MustParse("25"), "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Currency != money.DefaultCurrency {
+		t.Errorf("expected the budget in %s, got %s", money.DefaultCurrency, b.Currency)
+	}
+
+	tr, _, err := transferSvc.TransferMoney(
+		validator.New(), users[0], "", "", users[1].Email, money.MustParse("20"), "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = categorySvc.CategorizeTransfer(validator.New(), users[0].ID, tr.ID, "rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = transferSvc.TransferMoney(
+		validator.New(), users[0], "", savings.Number, "", money.MustParse("30"), "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := transactionSvc.Withdraw(
+		validator.New(), users[0].ID, "", money.MustParse("10"), "teller", "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = categorySvc.CategorizeTransaction(validator.New(), users[0].ID, tx.ID, "rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	spending, err := budgetSvc.Insights(validator.New(), users[0].ID, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spending) != 1 {
+		t.Fatalf("expected only the spending on rent, got %d rows", len(spending))
+	}
+	got := spending[0]
+	if got.Category != "rent" || got.Transfers != money.MustParse("20") ||
+		got.Withdrawals != money.MustParse("10") || got.Total != money.MustParse("30") {
+		t.Errorf("unexpected spending %+v", got)
+	}
+
+	progress, err := budgetSvc.GetUserProgress(users[0].ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 1 || !progress[0].Exceeded || progress[0].Spent != money.MustParse("30") {
+		t.Fatalf("expected the budget to be gone over by 30, got %+v", progress)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := budgetSvc.SendAlerts(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(mail.sent) != 1 || mail.sent[0] != users[0].Email {
+		t.Errorf("expected one alert to %s, got %v", users[0].Email, mail.sent)
+	}
+}
@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/category"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transaction"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestCategories sends a transfer with a memo and reference that both users file under categories
+// of their own, and files a deposit too, then filters the history by them
+func TestCategories(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	ledgerSvc = &ledger.Service{Repo: &ledger.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	transactionSvc := &transaction.Service{
+		Repo:           &transaction.Repository{DB: testDB},
+		AccountService: accountSvc,
+	}
+	categorySvc := &category.Service{Repo: &category.Repository{DB: testDB}}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	for _, c := range []struct {
+		userID int64
+		name   string
+	}{{users[0].ID, "rent"}, {users[0].ID, "groceries"}, {users[1].ID, "income"}} {
+		if _, err := categorySvc.New(validator.New(), c.userID, c.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := categorySvc.New(validator.New(), users[0].ID, "Rent"); err == nil {
+		t.Error("expected a category differing only in case to be refused")
+	}
+
+	tr, _, err := transferSvc.TransferMoney(
+		validator.New(), users[0], "", "", users[1].Email, money.MustParse("10"), "march rent",
+		"INV-0042",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = categorySvc.CategorizeTransfer(validator.New(), users[0].ID, tr.ID, "RENT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = categorySvc.CategorizeTransfer(validator.New(), users[1].ID, tr.ID, "income")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each user sees the transfer under their own category
+	for _, c := range []struct {
+		userID   int64
+		category string
+		want     int
+	}{
+		{users[0].ID, "", 1}, {users[0].ID, "rent", 1}, {users[0].ID, "income", 0},
+		{users[1].ID, "income", 1}, {users[1].ID, "rent", 0},
+	} {
+		transfers, _, err := transferSvc.GetAllUserTransfers(
+			validator.New(), c.userID, &history.Query{Category: c.category},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(transfers) != c.want {
+			t.Fatalf(
+				"user %d category %q: expected %d transfers, got %d", c.userID, c.category,
+				c.want, len(transfers),
+			)
+		}
+		if c.want == 0 {
+			continue
+		}
+		got := transfers[0]
+		if got.Memo != "march rent" || got.Reference != "INV-0042" {
+			t.Errorf(
+				"expected the memo and reference to be kept, got %q %q", got.Memo, got.Reference,
+			)
+		}
+		if c.category != "" && got.Category != c.category {
+			t.Errorf("expected category %q, got %q", c.category, got.Category)
+		}
+	}
+
+	tx, err := transactionSvc.Deposit(
+		validator.New(), users[0].ID, "", money.MustParse("5"), "teller", "birthday", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = categorySvc.CategorizeTransaction(validator.New(), users[0].ID, tx.ID, "groceries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions, _, err := transactionSvc.GetAllUserTransactions(
+		validator.New(), users[0].ID, &history.Query{Category: "groceries"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 1 || transactions[0].Memo != "birthday" {
+		t.Fatalf("expected the deposit under groceries, got %+v", transactions)
+	}
+
+	// deleting a category leaves what was filed under it uncategorized
+	categories, err := categorySvc.GetAllUserCategories(users[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range categories {
+		if err := categorySvc.Delete(c.ID, users[0].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transfers, _, err := transferSvc.GetAllUserTransfers(
+		validator.New(), users[0].ID, &history.Query{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 1 || transfers[0].Category != "" {
+		t.Errorf("expected the transfer to be uncategorized, got %+v", transfers)
+	}
+	transactions, _, err = transactionSvc.GetAllUserTransactions(
+		validator.New(), users[0].ID, &history.Query{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 1 || transactions[0].Category != "" {
+		t.Errorf("expected the deposit to be uncategorized, got %+v", transactions)
+	}
+}
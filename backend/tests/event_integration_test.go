@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transaction"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestEvents registers two users and has one deposit and send money to the other, then publishes
+// the events those wrote to the outbox, each once and in order
+func TestEvents(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	transactionSvc := &transaction.Service{
+		Repo:           &transaction.Repository{DB: testDB},
+		AccountService: accountSvc,
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := transactionSvc.Deposit(
+		validator.New(), users[0].ID, "", money.MustParse("50"), "teller", "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, _, err := transferSvc.TransferMoney(
+		validator.New(), users[0], "", "", users[1].Email, money.MustParse("20"), "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := &event.Dispatcher{Repo: &event.Repository{DB: testDB}}
+	var got []string
+	dispatcher.Subscribe(event.All, func(e *event.Event) error {
+		got = append(got, e.Type)
+		return nil
+	})
+	var completed event.TransferCompleted
+	dispatcher.Subscribe(event.TypeTransferCompleted, func(e *event.Event) error {
+		return e.Decode(&completed)
+	})
+
+	published, err := dispatcher.Dispatch(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		event.TypeUserRegistered, event.TypeUserRegistered, event.TypeDeposit,
+		event.TypeTransferCompleted,
+	}
+	if published != len(want) || !slices.Equal(got, want) {
+		t.Fatalf("expected %v published, got %d %v", want, published, got)
+	}
+	if completed.TransferID != tr.ID || completed.ToUserID != users[1].ID ||
+		completed.Amount != money.MustParse("20") {
+		t.Errorf("expected the transfer in the event, got %+v", completed)
+	}
+
+	// published events aren't published again
+	published, err = dispatcher.Dispatch(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != 0 {
+		t.Errorf("expected nothing left to publish, got %d", published)
+	}
+}
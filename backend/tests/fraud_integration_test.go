@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fraud"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transaction"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestFraudScreening sends a large transfer that is held for review and approves it, which makes
+// it, and another that is rejected, then makes withdrawals until the velocity rule blocks them
+func TestFraudScreening(t *testing.T) {
+	resetDB()
+
+	fraudSvc := &fraud.Service{
+		Repo: &fraud.Repository{DB: testDB},
+		Rules: fraud.Rules{
+			LargeAmount:        money.MustParse("1000"),
+			LargeAmountAction:  fraud.ActionReview,
+			VelocityCount:      3,
+			VelocityWindow:     10 * time.Minute,
+			VelocityAction:     fraud.ActionBlock,
+			NewRecipientAction: fraud.ActionAllow,
+		},
+	}
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+		Fraud:          fraudSvc,
+	}
+	transactionSvc := &transaction.Service{
+		Repo:           &transaction.Repository{DB: testDB},
+		AccountService: accountSvc,
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+		Fraud:          fraudSvc,
+	}
+	fraudSvc.Makers = map[string]fraud.Maker{
+		fraud.KindTransfer:   transferSvc,
+		fraud.KindWithdrawal: transactionSvc,
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+		{Name: "staff", Email: "s@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := transactionSvc.Deposit(
+		validator.New(), users[0].ID, "", money.MustParse("5000"), "teller", "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(amount string) error {
+		_, _, err := transferSvc.TransferMoney(
+			validator.New(), users[0], "", "", users[1].Email, money.MustParse(amount), "", "",
+		)
+		return err
+	}
+
+	// the first transfer to someone is only recorded
+	if err := send("50"); err != nil {
+		t.Fatal(err)
+	}
+
+	balances := func() (money.Amount, money.Amount) {
+		a, err := accountSvc.GetUserAccount(users[0].ID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.Balance, a.AvailableBalance
+	}
+
+	if err := send("1500"); !errors.Is(err, fraud.ErrHeldForReview) {
+		t.Fatalf("expected error %v, got %v", fraud.ErrHeldForReview, err)
+	}
+	balance, available := balances()
+	if balance != money.MustParse("4950") || available != money.MustParse("3450") {
+		t.Fatalf("expected nothing moved and the money held, got %v %v", balance, available)
+	}
+
+	queue, err := fraudSvc.GetReviewQueue(validator.New(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || len(queue[0].Hits) != 1 ||
+		queue[0].Hits[0].Rule != fraud.RuleLargeAmount {
+		t.Fatalf("expected the large transfer waiting for review, got %+v", queue)
+	}
+
+	// the approval makes the transfer
+	screening, err := fraudSvc.Review(validator.New(), queue[0].ID, users[2].ID, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if screening.TransferID == nil {
+		t.Fatalf("expected the transfer made kept, got %+v", screening)
+	}
+	balance, available = balances()
+	if balance != money.MustParse("3450") || available != money.MustParse("3450") {
+		t.Fatalf("expected the held money sent, got %v %v", balance, available)
+	}
+	_, err = fraudSvc.Review(validator.New(), queue[0].ID, users[2].ID, true, "")
+	checkErr(t, err, user.ErrNoRecord, "reviewing a screening twice")
+
+	queue, _ = fraudSvc.GetReviewQueue(validator.New(), fraud.ReviewApproved)
+	if len(queue) != 1 || queue[0].TransferID == nil ||
+		*queue[0].TransferID != *screening.TransferID {
+		t.Errorf("expected the approval with its transfer, got %+v", queue)
+	}
+
+	// the rejection frees the money held
+	if err := send("1500"); !errors.Is(err, fraud.ErrHeldForReview) {
+		t.Fatalf("expected the next one held again, got %v", err)
+	}
+	queue, _ = fraudSvc.GetReviewQueue(validator.New(), "")
+	if len(queue) != 1 {
+		t.Fatalf("expected the second large transfer waiting, got %+v", queue)
+	}
+	_, err = fraudSvc.Review(validator.New(), queue[0].ID, users[2].ID, false, "not them")
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, available = balances()
+	if balance != money.MustParse("3450") || available != money.MustParse("3450") {
+		t.Fatalf("expected nothing moved and nothing held, got %v %v", balance, available)
+	}
+
+	// two transfers made so far, the velocity rule allows one more operation
+	_, err = transactionSvc.Withdraw(
+		validator.New(), users[0].ID, "", money.MustParse("10"), "teller", "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := validator.New()
+	_, err = transactionSvc.Withdraw(
+		v, users[0].ID, "", money.MustParse("10"), "teller", "", "",
+	)
+	if !errors.Is(err, validator.ErrFailedValidation) || v.Errors["fraud screening"] == "" {
+		t.Errorf("expected the withdrawal blocked, got %v %v", err, v.Errors)
+	}
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestTransferHistory sends transfers of different amounts to two users, then pages through them
+// with filters, checking no transfer is skipped or seen twice
+func TestTransferHistory(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+		{Name: "ali", Email: "a@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("1000")
+		seedBalance(u)
+	}
+
+	// 1 to 7 to mohamed and 1 to 3 to ali
+	for i := 1; i <= 10; i++ {
+		to, amount := users[1], money.FromCents(int64(i)*100)
+		if i > 7 {
+			to, amount = users[2], money.FromCents(int64(i-7)*100)
+		}
+		_, _, err := transferSvc.TransferMoney(
+			validator.New(), users[0], "", "", to.Email, amount, "", "",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query history.Query
+		want  []money.Amount
+	}{
+		{
+			name:  "newest first",
+			query: history.Query{PageSize: 3},
+			want: []money.Amount{
+				money.MustParse("3"), money.MustParse("2"), money.MustParse("1"),
+				money.MustParse("7"), money.MustParse("6"), money.MustParse("5"),
+				money.MustParse("4"), money.MustParse("3"), money.MustParse("2"),
+				money.MustParse("1"),
+			},
+		},
+		{
+			name:  "to one counterparty, largest first",
+			query: history.Query{Counterparty: "m@gmail.com", Sort: "-amount", PageSize: 2},
+			want: []money.Amount{
+				money.MustParse("7"), money.MustParse("6"), money.MustParse("5"),
+				money.MustParse("4"), money.MustParse("3"), money.MustParse("2"),
+				money.MustParse("1"),
+			},
+		},
+		{
+			name: "amount range, smallest first",
+			query: history.Query{
+				MinAmount: amountPtr("2"), MaxAmount: amountPtr("3"), Sort: "amount", PageSize: 3,
+			},
+			want: []money.Amount{
+				money.MustParse("2"), money.MustParse("2"), money.MustParse("3"),
+				money.MustParse("3"),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []money.Amount
+			seen := map[int64]bool{}
+			q := tc.query
+			for {
+				v := validator.New()
+				transfers, page, err := transferSvc.GetAllUserTransfers(v, users[0].ID, &q)
+				if err != nil {
+					t.Fatalf("unexpected error %v %v", err, v.Errors)
+				}
+				if page.Total != len(tc.want) {
+					t.Fatalf("expected a total of %d, got %d", len(tc.want), page.Total)
+				}
+				for _, tr := range transfers {
+					if seen[tr.ID] {
+						t.Fatalf("transfer %d was on two pages", tr.ID)
+					}
+					seen[tr.ID] = true
+					got = append(got, tr.Amount)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("expected %v, got %v", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func amountPtr(s string) *money.Amount {
+	a := money.MustParse(s)
+	return &a
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/hold"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestHolds places a hold, which should stop the money it sets aside from being sent, then
+// captures part of it, after which only what was captured should have left the account
+func TestHolds(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	holdSvc := &hold.Service{Repo: &hold.Repository{DB: testDB}, AccountService: accountSvc}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	h, err := holdSvc.Place(
+		validator.New(), users[0].ID, "", hold.KindCard, money.MustParse("80"), "", "yusuf",
+		time.Time{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = transferSvc.TransferMoney(
+		validator.New(), users[0], "", "", users[1].Email, money.MustParse("30"), "", "",
+	)
+	if !errors.Is(err, validator.ErrFailedValidation) {
+		t.Fatalf(
+			"expected error %v sending money that is on hold, got %v",
+			validator.ErrFailedValidation, err,
+		)
+	}
+
+	got, err := userSvc.GetUser(users[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccountBalance != money.MustParse("100") || got.AvailableBalance != money.MustParse("20") {
+		t.Errorf(
+			"expected account balance=100.00 and available balance=20.00, got %v and %v",
+			got.AccountBalance, got.AvailableBalance,
+		)
+	}
+
+	h, err = holdSvc.Capture(validator.New(), h.ID, money.MustParse("60"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Status != hold.StatusCaptured {
+		t.Errorf("expected status=%s, got %s", hold.StatusCaptured, h.Status)
+	}
+
+	_, err = holdSvc.Release(validator.New(), h.ID)
+	if !errors.Is(err, validator.ErrFailedValidation) {
+		t.Fatalf(
+			"expected error %v releasing a captured hold, got %v", validator.ErrFailedValidation, err,
+		)
+	}
+
+	got, err = userSvc.GetUser(users[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccountBalance != money.MustParse("40") || got.AvailableBalance != money.MustParse("40") {
+		t.Errorf(
+			"expected account balance=40.00 and available balance=40.00, got %v and %v",
+			got.AccountBalance, got.AvailableBalance,
+		)
+	}
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/interest"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestSavingsInterest accrues a day of interest on a savings account and pays it at the start of
+// the next month, once, however many times the job runs
+func TestSavingsInterest(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	ledgerSvc = &ledger.Service{Repo: &ledger.Repository{DB: testDB}}
+	interestSvc := &interest.Service{
+		Repo:  &interest.Repository{DB: testDB},
+		Tiers: interest.Tiers{{From: 0, AnnualRate: fx.MustParseDecimal("1")}},
+	}
+
+	u := &user.User{Name: "yusuf", Email: "y@gmail.com"}
+	u.Password.Set("12345678", 12)
+	if err := userRepo.Insert(u); err != nil {
+		t.Fatal(err)
+	}
+	savings, err := accountSvc.Open(validator.New(), u.ID, account.TypeSavings, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit := money.MustParse("36500")
+	err = ledgerSvc.Post(ledger.NewEntry(
+		"opening balance",
+		ledger.SystemPosting(ledger.AccountOpeningBalances, savings.Currency, deposit.Neg()),
+		ledger.AccountPosting(savings.ID, savings.Currency, deposit),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first run accrues the day before it, which is tomorrow here
+	now := time.Now().Add(48 * time.Hour)
+	if days, err := interestSvc.Accrue(now); err != nil || days != 1 {
+		t.Fatalf("expected 1 day accrued, got %d and %v", days, err)
+	}
+
+	nextMonth := now.AddDate(0, 1, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := interestSvc.PayMonthly(nextMonth); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := accountSvc.GetAccount(savings.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := money.MustParse("36501"); got.Balance != want {
+		t.Errorf("expected balance=%v after a day of interest, got %v", want, got.Balance)
+	}
+}
@@ -3,7 +3,9 @@ package tests
 import (
 	"testing"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
 	"github.com/Yusufdot101/goBankBackend/internal/loan"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 	_ "github.com/lib/pq"
@@ -20,7 +22,7 @@ func TestLoanLifecycle(t *testing.T) {
 		ID:             1,
 		Name:           "yusuf",
 		Email:          "y@gmail.com",
-		AccountBalance: 100, // needed to make the payment in the test
+		AccountBalance: money.MustParse("100"), // needed to make the payment in the test
 	}
 	user1.Password.Set("12345678", 12)
 
@@ -33,17 +35,22 @@ func TestLoanLifecycle(t *testing.T) {
 
 	setupUserSevice := func(us *user.Service) {
 		// seed the users table
+		balance := user1.AccountBalance
 		us.Repo.Insert(user1)
 		us.Repo.Insert(user2)
+		user1.AccountBalance = balance
+		seedBalance(user1)
 	}
 
 	tests := []struct {
 		name  string
 		input struct {
-			user                               *user.User
-			reason                             string
-			loanID, userID, deletedByID        int64
-			amount, dailyInterestRate, payment float64
+			user                        *user.User
+			reason                      string
+			loanID, userID, deletedByID int64
+			amount                      money.Amount
+			dailyInterestRate           float64
+			payment                     money.Amount
 		}
 		expectedErr error
 	}{
@@ -55,18 +62,18 @@ func TestLoanLifecycle(t *testing.T) {
 				loanID            int64
 				userID            int64
 				deletedByID       int64
-				amount            float64
+				amount            money.Amount
 				dailyInterestRate float64
-				payment           float64
+				payment           money.Amount
 			}{
 				user:              user1,
 				reason:            "why not",
 				loanID:            1,
 				userID:            user1.ID,
 				deletedByID:       user2.ID,
-				amount:            100,
+				amount:            money.MustParse("100"),
 				dailyInterestRate: 5,
-				payment:           50,
+				payment:           money.MustParse("50"),
 			},
 		},
 		{
@@ -77,18 +84,18 @@ func TestLoanLifecycle(t *testing.T) {
 				loanID            int64
 				userID            int64
 				deletedByID       int64
-				amount            float64
+				amount            money.Amount
 				dailyInterestRate float64
-				payment           float64
+				payment           money.Amount
 			}{
 				user:              user1,
 				reason:            "why not",
 				loanID:            1,
 				userID:            user1.ID,
 				deletedByID:       user2.ID,
-				amount:            100,
+				amount:            money.MustParse("100"),
 				dailyInterestRate: 5,
-				payment:           0,
+				payment:           money.MustParse("0"),
 			},
 			expectedErr: validator.ErrFailedValidation,
 		},
@@ -102,13 +109,20 @@ func TestLoanLifecycle(t *testing.T) {
 			}
 			setupUserSevice(userSvc)
 
+			accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
 			loanSvc = &loan.Service{
-				Repo:        loanRepo,
-				UserService: userSvc,
+				Repo:           loanRepo,
+				AccountService: accountSvc,
 			}
 			v := validator.New()
+			// the loan is paid into, and paid back from, the user's primary account
+			a, gotErr := accountSvc.GetUserAccount(tc.input.user.ID, "")
+			if !checkErr(t, gotErr, nil, "GetUserAccount") {
+				return
+			}
+
 			// step 1: create loan
-			gotErr := loanSvc.GetLoan(tc.input.user, tc.input.amount, tc.input.dailyInterestRate)
+			gotErr = loanSvc.GetLoan(tc.input.user, a.ID, a.Currency, tc.input.amount, tc.input.dailyInterestRate)
 			if !checkErr(t, gotErr, tc.expectedErr, "GetLoan") {
 				return
 			}
@@ -119,11 +133,14 @@ func TestLoanLifecycle(t *testing.T) {
 				return
 			}
 			if dbLoan.RemainingAmount != tc.input.amount {
-				t.Errorf("expected remaining = %f, got %f", tc.input.amount, dbLoan.RemainingAmount)
+				t.Errorf("expected remaining = %v, got %v", tc.input.amount, dbLoan.RemainingAmount)
 			}
 			if dbLoan.UserID != tc.input.userID {
 				t.Errorf("expected user id=%d, got %d", tc.input.userID, dbLoan.UserID)
 			}
+			if dbLoan.AccountID != a.ID {
+				t.Errorf("expected account id=%d, got %d", a.ID, dbLoan.AccountID)
+			}
 
 			// step 2: make payment
 			_, gotErr = loanSvc.MakePayment(v, tc.input.loanID, tc.input.userID, tc.input.payment)
@@ -138,7 +155,7 @@ func TestLoanLifecycle(t *testing.T) {
 			}
 
 			if dbLoan.RemainingAmount >= tc.input.amount {
-				t.Errorf("expected remaining reduced, got %f", dbLoan.RemainingAmount)
+				t.Errorf("expected remaining reduced, got %v", dbLoan.RemainingAmount)
 			}
 
 			// step 3: delete loan
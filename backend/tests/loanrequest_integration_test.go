@@ -3,8 +3,10 @@ package tests
 import (
 	"testing"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
 	"github.com/Yusufdot101/goBankBackend/internal/loan"
 	"github.com/Yusufdot101/goBankBackend/internal/loanrequests"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 	_ "github.com/lib/pq"
@@ -21,9 +23,8 @@ func TestLoanRequest(t *testing.T) {
 		Repo: &user.Repository{DB: loanrequestRepo.DB},
 	}
 	loanrequestSvc = &loanrequests.Service{
-		Repo:        loanrequestRepo,
-		LoanService: loanSvc,
-		UserService: userSvc,
+		Repo:           loanrequestRepo,
+		AccountService: &account.Service{Repo: &account.Repository{DB: testDB}},
 	}
 
 	user1 = &user.User{
@@ -42,8 +43,9 @@ func TestLoanRequest(t *testing.T) {
 		setup           func()
 		setupUserSevice func(*user.Service)
 		input           struct {
-			u                         *user.User
-			amount, dailyInterestRate float64
+			u                 *user.User
+			amount            money.Amount
+			dailyInterestRate float64
 		}
 		expectedErr error
 	}{
@@ -57,11 +59,11 @@ func TestLoanRequest(t *testing.T) {
 			},
 			input: struct {
 				u                 *user.User
-				amount            float64
+				amount            money.Amount
 				dailyInterestRate float64
 			}{
 				u:                 user1,
-				amount:            100,
+				amount:            money.MustParse("100"),
 				dailyInterestRate: 5,
 			},
 		},
@@ -75,11 +77,11 @@ func TestLoanRequest(t *testing.T) {
 			},
 			input: struct {
 				u                 *user.User
-				amount            float64
+				amount            money.Amount
 				dailyInterestRate float64
 			}{
 				u:                 user1,
-				amount:            0,
+				amount:            money.MustParse("0"),
 				dailyInterestRate: 5,
 			},
 			expectedErr: validator.ErrFailedValidation,
@@ -94,11 +96,11 @@ func TestLoanRequest(t *testing.T) {
 			},
 			input: struct {
 				u                 *user.User
-				amount            float64
+				amount            money.Amount
 				dailyInterestRate float64
 			}{
 				u:                 user1,
-				amount:            -100,
+				amount:            money.MustParse("-100"),
 				dailyInterestRate: 5,
 			},
 			expectedErr: validator.ErrFailedValidation,
@@ -112,14 +114,14 @@ func TestLoanRequest(t *testing.T) {
 			v := validator.New()
 			// step 1: loan creation
 			loanRequest, gotErr := loanrequestSvc.New(
-				v, tc.input.u, tc.input.amount, tc.input.dailyInterestRate,
+				v, tc.input.u, "", tc.input.amount, tc.input.dailyInterestRate,
 			)
 			if !checkErr(t, gotErr, tc.expectedErr, "New") {
 				return
 			}
 
 			// fetch the loan
-			loanRequest, gotErr = loanrequestSvc.Repo.Get(loanRequest.ID, tc.input.u.ID)
+			loanRequest, gotErr = loanrequestRepo.Get(loanRequest.ID, tc.input.u.ID)
 			if !checkErr(t, gotErr, tc.expectedErr, "New") {
 				return
 			}
@@ -138,7 +140,7 @@ func TestLoanRequest(t *testing.T) {
 			}
 
 			// fetch the loan
-			loanRequest, gotErr = loanrequestSvc.Repo.Get(loanRequest.ID, tc.input.u.ID)
+			loanRequest, gotErr = loanrequestRepo.Get(loanRequest.ID, tc.input.u.ID)
 			if !checkErr(t, gotErr, tc.expectedErr, "New") {
 				return
 			}
@@ -169,14 +171,20 @@ func TestLoanRequest(t *testing.T) {
 			}
 			if gotUser.AccountBalance != tc.input.amount {
 				t.Errorf(
-					"expected user account balance=%f, got account balance=%f",
+					"expected user account balance=%v, got account balance=%v",
 					tc.input.amount, gotUser.AccountBalance,
 				)
 			}
 
+			// a request that was already accepted isn't paid out again
+			_, gotErr = loanrequestSvc.AcceptLoanRequest(loanRequest.ID, tc.input.u.ID)
+			if !checkErr(t, gotErr, user.ErrNoRecord, "AcceptLoanRequest twice") {
+				return
+			}
+
 			// step 3: new loan request
 			loanRequest, gotErr = loanrequestSvc.New(
-				v, tc.input.u, tc.input.amount, tc.input.dailyInterestRate,
+				v, tc.input.u, "", tc.input.amount, tc.input.dailyInterestRate,
 			)
 			if !checkErr(t, gotErr, tc.expectedErr, "New") {
 				return
@@ -189,7 +197,7 @@ func TestLoanRequest(t *testing.T) {
 			}
 
 			// fetch it
-			loanRequest, gotErr = loanrequestSvc.Repo.Get(loanRequest.ID, tc.input.u.ID)
+			loanRequest, gotErr = loanrequestRepo.Get(loanRequest.ID, tc.input.u.ID)
 			if !checkErr(t, gotErr, tc.expectedErr, "New") {
 				return
 			}
@@ -221,7 +229,7 @@ func TestLoanRequest(t *testing.T) {
 			// it should only have the balance from the first loan
 			if gotUser.AccountBalance > tc.input.amount {
 				t.Errorf(
-					"expected user account balance=%f, got account balance=%f",
+					"expected user account balance=%v, got account balance=%v",
 					tc.input.amount, gotUser.AccountBalance,
 				)
 			}
@@ -235,7 +243,7 @@ func TestLoanRequest(t *testing.T) {
 }
 
 func checkLoan(
-	t *testing.T, gotLoan *loan.Loan, userID int64, amount, dailyInterestRate float64, msg string,
+	t *testing.T, gotLoan *loan.Loan, userID int64, amount money.Amount, dailyInterestRate float64, msg string,
 ) bool {
 	passed := true
 	if gotLoan.UserID != userID {
@@ -244,7 +252,7 @@ func checkLoan(
 	}
 	if gotLoan.Amount != amount {
 		t.Errorf(
-			"%s: expected account balance=%f, got account balance=%f", msg, amount,
+			"%s: expected account balance=%v, got account balance=%v", msg, amount,
 			gotLoan.Amount,
 		)
 		passed = false
@@ -260,7 +268,7 @@ func checkLoan(
 }
 
 func checkLoanRequest(
-	t *testing.T, gotLoanRequest *loanrequests.LoanRequest, userID int64, amount, dailyInterestRate float64,
+	t *testing.T, gotLoanRequest *loanrequests.LoanRequest, userID int64, amount money.Amount, dailyInterestRate float64,
 	status, msg string,
 ) bool {
 	passed := true
@@ -270,7 +278,7 @@ func checkLoanRequest(
 	}
 	if gotLoanRequest.Amount != amount {
 		t.Errorf(
-			"%s: expected account balance=%f, got account balance=%f", msg, amount,
+			"%s: expected account balance=%v, got account balance=%v", msg, amount,
 			gotLoanRequest.Amount,
 		)
 		passed = false
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/overdraft"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestOverdraft sends money past the balance of an account with an overdraft, as far as the limit
+// and no further, and then charges a day of interest on what it owes
+func TestOverdraft(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	overdraftSvc := &overdraft.Service{
+		Repo:           &overdraft.Repository{DB: testDB},
+		AccountService: accountSvc,
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	a, err := accountSvc.GetUserAccount(users[0].ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = overdraftSvc.SetOverdraft(
+		validator.New(), a.Number, money.MustParse("50"), fx.MustParseDecimal("1"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(amount money.Amount) error {
+		_, _, err := transferSvc.TransferMoney(
+			validator.New(), users[0], "", "", users[1].Email, amount, "", "",
+		)
+		return err
+	}
+
+	if err := send(money.MustParse("140")); err != nil {
+		t.Fatalf("expected to send into the overdraft, got %v", err)
+	}
+	if err := send(money.MustParse("20")); !errors.Is(err, validator.ErrFailedValidation) {
+		t.Fatalf("expected error %v going past the overdraft, got %v",
+			validator.ErrFailedValidation, err)
+	}
+
+	// the first run only starts counting, the interest comes a day later
+	now := time.Now()
+	for _, run := range []time.Time{now, now.Add(25 * time.Hour)} {
+		if _, err := overdraftSvc.ChargeInterest(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := accountSvc.GetAccount(a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := money.MustParse("-40.40"); got.Balance != want {
+		t.Errorf("expected balance=%v after a day of interest, got %v", want, got.Balance)
+	}
+}
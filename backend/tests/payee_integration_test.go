@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/payee"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestPayee saves a recipient by email and pays them twice by the payee, only the first of which
+// should be flagged as a first payment
+func TestPayee(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	payeeSvc := &payee.Service{Repo: &payee.Repository{DB: testDB}, UserService: userSvc}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+		Payees:         payeeSvc,
+	}
+	payeeSvc.Recipients = transferSvc
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	p, err := payeeSvc.Save(validator.New(), users[0].ID, "brother", "", users[1].Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := payee.MaskName(users[1].Name); p.RecipientName != want {
+		t.Errorf("expected recipient %s, got %s", want, p.RecipientName)
+	}
+
+	for i, wantFirst := range []bool{true, false} {
+		tr, _, err := transferSvc.PayPayee(
+			validator.New(), users[0], "", p.ID, money.MustParse("10"), "", "",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tr.FirstPayment != wantFirst {
+			t.Errorf(
+				"payment %d: expected first payment=%v, got %v", i+1, wantFirst, tr.FirstPayment,
+			)
+		}
+	}
+
+	p, err = payeeSvc.GetPayee(p.ID, users[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.FirstPaidAt == nil {
+		t.Error("expected the payee to be marked paid")
+	}
+
+	// the transfers outlive the payee
+	if err := payeeSvc.Delete(p.ID, users[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	transfers, _, err := transferSvc.GetAllUserTransfers(
+		validator.New(), users[0].ID, &history.Query{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 2 {
+		t.Errorf("expected 2 transfers after deleting the payee, got %d", len(transfers))
+	}
+}
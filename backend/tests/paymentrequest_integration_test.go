@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/paymentrequest"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestPaymentRequests asks for money three times, one request is paid, and only once, one declined
+// and the last left to expire
+func TestPaymentRequests(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	requestSvc := &paymentrequest.Service{
+		Repo:           &paymentrequest.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		TransferService: &transfer.Service{
+			Repo:           &transfer.Repository{DB: testDB},
+			UserService:    userSvc,
+			AccountService: accountSvc,
+			FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+			Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+		},
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+	requester, payer := users[0], users[1]
+
+	var requests []*paymentrequest.PaymentRequest
+	for range 3 {
+		request, err := requestSvc.New(
+			validator.New(), requester, "", payer.Email, "", money.MustParse("30"), "dinner",
+			time.Time{},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, request)
+	}
+
+	paid, tr, err := requestSvc.Pay(validator.New(), payer, requests[0].ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paid.Status != paymentrequest.StatusPaid || paid.TransferID == nil ||
+		*paid.TransferID != tr.ID {
+		t.Errorf("expected the request paid by transfer %d, got %+v", tr.ID, paid)
+	}
+	_, _, err = requestSvc.Pay(validator.New(), payer, requests[0].ID, "")
+	if !errors.Is(err, validator.ErrFailedValidation) {
+		t.Errorf("expected error %v paying twice, got %v", validator.ErrFailedValidation, err)
+	}
+
+	if _, err := requestSvc.Decline(validator.New(), requests[1].ID, payer.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := requestSvc.ExpireDue(time.Now().Add(8 * 24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 {
+		t.Errorf("expected 1 request expired, got %d", expired)
+	}
+
+	outgoing, err := requestSvc.GetOutgoing(requester.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the newest first
+	want := []string{
+		paymentrequest.StatusExpired, paymentrequest.StatusDeclined, paymentrequest.StatusPaid,
+	}
+	for i, request := range outgoing {
+		if request.Status != want[i] {
+			t.Errorf("request %d: expected status %s, got %s", request.ID, want[i], request.Status)
+		}
+	}
+
+	a, err := accountSvc.GetUserAccount(requester.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := money.MustParse("130"); a.Balance != want {
+		t.Errorf("expected balance=%v after being paid once, got %v", want, a.Balance)
+	}
+}
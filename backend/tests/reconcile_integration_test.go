@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/reconcile"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestReconcile reconciles after a transfer, when everything should add up, and again after the
+// cached balance of one account is changed behind the ledger's back, which should be found
+func TestReconcile(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	reconcileSvc := &reconcile.Service{Repo: &reconcile.Repository{DB: testDB}}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	_, _, err := transferSvc.TransferMoney(
+		validator.New(), users[0], "", "", users[1].Email, money.MustParse("25"), "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := reconcileSvc.Reconcile("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.AccountsChecked != 2 || len(run.Discrepancies) != 0 {
+		t.Fatalf(
+			"expected 2 accounts checked and none off, got %d and %d", run.AccountsChecked,
+			len(run.Discrepancies),
+		)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = testDB.ExecContext(
+		ctx, "UPDATE accounts SET balance = balance + 1 WHERE user_id = $1", users[1].ID,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, err = reconcileSvc.Reconcile("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Discrepancies) != 1 {
+		t.Fatalf("expected 1 discrepancy, got %d", len(run.Discrepancies))
+	}
+	d := run.Discrepancies[0]
+	if d.UserID != users[1].ID || d.ExpectedBalance != money.MustParse("125") ||
+		d.AccountBalance != money.MustParse("126") {
+		t.Errorf(
+			"expected user %d's account at 125.00 but cached at 126.00, got user %d at %v and %v",
+			users[1].ID, d.UserID, d.ExpectedBalance, d.AccountBalance,
+		)
+	}
+
+	got, err := reconcileSvc.GetRun(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != run.ID || len(got.Discrepancies) != 1 {
+		t.Errorf("expected the latest run %d with 1 discrepancy, got run %d", run.ID, got.ID)
+	}
+}
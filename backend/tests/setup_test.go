@@ -8,30 +8,36 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/ledger"
 	"github.com/Yusufdot101/goBankBackend/internal/loan"
 	"github.com/Yusufdot101/goBankBackend/internal/loanrequests"
 	"github.com/Yusufdot101/goBankBackend/internal/token"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 )
 
 var (
 	testDB *sql.DB
 
-	userRepo  *user.Repository
-	tokenRepo *token.Repository
+	userRepo    *user.Repository
+	tokenRepo   *token.Repository
+	accountRepo *account.Repository
 	// permissionRepo  *permission.Repository
 	loanrequestRepo *loanrequests.Repository
 	loanRepo        *loan.Repository
-	// transferRepo    *transfer.Repository
+	transferRepo    *transfer.Repository
 	// transactionRepo *transaction.Repository
 
-	userSvc  *user.Service
-	tokenSvc *token.Service
+	userSvc    *user.Service
+	tokenSvc   *token.Service
+	accountSvc *account.Service
 	// permissionSvc  *permission.Service
 	loanrequestSvc *loanrequests.Service
 	loanSvc        *loan.Service
-	// transferSvc    *transfer.Service
+	transferSvc    *transfer.Service
 	// transactionSvc *transaction.Service
+	ledgerSvc *ledger.Service
 
 	user1 *user.User
 	user2 *user.User
@@ -55,8 +61,15 @@ func TestMain(m *testing.M) {
 
 func resetDB() {
 	query := `
-		TRUNCATE loans, deleted_loans, loan_requests, permissions, users_permissions, tokens, 
-			transactions, transfers, users RESTART IDENTITY CASCADE
+		TRUNCATE fraud_rule_hits, fraud_screenings, webhook_delivery_attempts, webhook_deliveries,
+			webhooks, outbox, budget_alerts, budgets, transfer_categories, categories,
+			transfer_batch_rows, transfer_batches, payment_requests, payees, interest_accruals,
+			interest_accrual_days, reconciliation_discrepancies, reconciliation_runs,
+			idempotency_keys, holds, user_limits, statement_deliveries, standing_order_runs,
+			standing_orders, postings, journal_entries, ledger_accounts, loans, deleted_loans,
+			loan_requests, permissions, users_permissions, tokens, transactions, transfers,
+			accounts, exchange_rates, users
+			RESTART IDENTITY CASCADE
 	`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -66,6 +79,27 @@ func resetDB() {
 	}
 }
 
+// seedBalance gives the user's primary account an opening balance. balances can only change
+// through the ledger so the users are seeded the same way
+func seedBalance(u *user.User) {
+	if u.AccountBalance == 0 {
+		return
+	}
+	a, err := (&account.Repository{DB: testDB}).GetPrimary(u.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ledgerSvc = &ledger.Service{Repo: &ledger.Repository{DB: testDB}}
+	entry := ledger.NewEntry(
+		"opening balance",
+		ledger.SystemPosting(ledger.AccountOpeningBalances, a.Currency, -u.AccountBalance),
+		ledger.AccountPosting(a.ID, a.Currency, u.AccountBalance),
+	)
+	if err := ledgerSvc.Post(entry); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func checkErr(t *testing.T, got, expected error, msg string) bool {
 	if expected != nil {
 		if got != nil && got.Error() != expected.Error() {
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/standingorder"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestStandingOrders runs a weekly order until the money runs out. the order keeps its schedule and
+// the run that could not be paid is recorded on it
+func TestStandingOrders(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	standingOrderSvc := &standingorder.Service{
+		Repo:            &standingorder.Repository{DB: testDB},
+		UserService:     userSvc,
+		AccountService:  accountSvc,
+		TransferService: transferSvc,
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users[0].AccountBalance = money.MustParse("15")
+	seedBalance(users[0])
+
+	order, err := standingOrderSvc.New(
+		validator.New(), users[0], "", "", users[1].Email, money.MustParse("10"),
+		standingorder.FrequencyWeekly, time.Time{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first run goes through, the second a week later finds only 5 in the account
+	firstRun := order.NextRunAt
+	for week := 0; week < 2; week++ {
+		executed, err := standingOrderSvc.ExecuteDue(firstRun.AddDate(0, 0, 7*week))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if executed != 1 {
+			t.Fatalf("week %d: expected 1 order executed, got %d", week, executed)
+		}
+	}
+
+	orders, err := standingOrderSvc.GetAllUserStandingOrders(users[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("expected 1 order, got %d", len(orders))
+	}
+	if orders[0].Status != standingorder.StatusActive {
+		t.Errorf("expected the order to stay active, got %s", orders[0].Status)
+	}
+	if orders[0].LastError == "" {
+		t.Errorf("expected the failed run to be recorded on the order")
+	}
+	if !orders[0].NextRunAt.Equal(firstRun.AddDate(0, 0, 14)) {
+		t.Errorf("expected next run %v, got %v", firstRun.AddDate(0, 0, 14), orders[0].NextRunAt)
+	}
+
+	got, err := userSvc.GetUser(users[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccountBalance != money.MustParse("5") {
+		t.Errorf("expected account balance=5.00, got %v", got.AccountBalance)
+	}
+}
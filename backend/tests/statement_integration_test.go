@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/statement"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestStatement sends money and then reads the sender's statement for today, which should start from
+// nothing and show the opening balance and the transfer
+func TestStatement(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+	statementSvc := &statement.Service{
+		Repo:           &statement.Repository{DB: testDB},
+		AccountService: accountSvc,
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	_, _, err := transferSvc.TransferMoney(
+		validator.New(), users[0], "", "", users[1].Email, money.MustParse("25"), "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	s, err := statementSvc.Generate(validator.New(), users[0].ID, "", now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.OpeningBalance != 0 {
+		t.Errorf("expected opening balance=0.00, got %v", s.OpeningBalance)
+	}
+	if len(s.Lines) != 2 {
+		t.Fatalf("expected the opening balance and the transfer, got %d lines", len(s.Lines))
+	}
+	if s.Lines[1].Description != "transfer" || s.Lines[1].Amount != money.MustParse("-25") {
+		t.Errorf(
+			"expected the transfer of -25.00, got %s of %v", s.Lines[1].Description,
+			s.Lines[1].Amount,
+		)
+	}
+	if s.ClosingBalance != money.MustParse("75") {
+		t.Errorf("expected closing balance=75.00, got %v", s.ClosingBalance)
+	}
+}
@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/history"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+)
+
+// TestConcurrentTransfers sends money both ways between two users at the same time. the account
+// rows are locked in ID order and aborted transactions are retried, so every transfer should go through
+// and no money should be created or lost
+func TestConcurrentTransfers(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	transferRepo = &transfer.Repository{DB: testDB}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           transferRepo,
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	const transfersEachWay = 10
+	amount := money.MustParse("1")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*transfersEachWay)
+	send := func(fromUser, toUser *user.User) {
+		defer wg.Done()
+		_, _, err := transferSvc.TransferMoney(
+			validator.New(), fromUser, "", "", toUser.Email, amount, "", "",
+		)
+		errs <- err
+	}
+	for i := 0; i < transfersEachWay; i++ {
+		wg.Add(2)
+		go send(users[0], users[1])
+		go send(users[1], users[0])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	transfers, _, err := transferSvc.GetAllUserTransfers(
+		validator.New(), users[0].ID, &history.Query{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 2*transfersEachWay {
+		t.Errorf("expected %d transfers, got %d", 2*transfersEachWay, len(transfers))
+	}
+
+	// the same amount went each way so both balances should be back where they started
+	for _, u := range users {
+		got, err := userSvc.GetUser(u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.AccountBalance != money.MustParse("100") {
+			t.Errorf("%s: expected account balance=100.00, got %v", u.Name, got.AccountBalance)
+		}
+	}
+}
+
+// TestRefund sends a transfer back in two parts, after which both balances should be where they
+// started and no more can be sent back
+func TestRefund(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	transferRepo = &transfer.Repository{DB: testDB}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           transferRepo,
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	tr, _, err := transferSvc.TransferMoney(
+		validator.New(), users[0], "", "", users[1].Email, money.MustParse("10"), "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, amount := range []money.Amount{money.MustParse("4"), 0} {
+		_, err = transferSvc.Refund(validator.New(), users[1], tr.ID, amount, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = transferSvc.Refund(validator.New(), users[1], tr.ID, money.MustParse("1"), "")
+	if !errors.Is(err, validator.ErrFailedValidation) {
+		t.Fatalf(
+			"expected error %v refunding more than was sent, got %v",
+			validator.ErrFailedValidation, err,
+		)
+	}
+
+	transfers, _, err := transferSvc.GetAllUserTransfers(
+		validator.New(), users[0].ID, &history.Query{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 3 {
+		t.Fatalf("expected the transfer and 2 refunds, got %d transfers", len(transfers))
+	}
+	for _, got := range transfers {
+		if got.ID == tr.ID && got.Status != transfer.StatusReversed {
+			t.Errorf("expected the transfer to be reversed, got %s", got.Status)
+		}
+	}
+
+	for _, u := range users {
+		got, err := userSvc.GetUser(u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.AccountBalance != money.MustParse("100") {
+			t.Errorf("%s: expected account balance=100.00, got %v", u.Name, got.AccountBalance)
+		}
+	}
+}
+
+// TestTransferLimits sends money up to the user's daily limit, after which the next transfer should
+// be refused with what is left of the limit, until an admin raises it. transfers sent at the same
+// time must not go over the raised limit together
+func TestTransferLimits(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	userSvc = &user.Service{Repo: userRepo}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	limitSvc := &limit.Service{
+		Repo:     &limit.Repository{DB: testDB},
+		Defaults: limit.Limits{Daily: money.MustParse("15")},
+	}
+	transferSvc = &transfer.Service{
+		Repo:           &transfer.Repository{DB: testDB},
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         limitSvc,
+	}
+
+	users := []*user.User{
+		{Name: "yusuf", Email: "y@gmail.com"},
+		{Name: "mohamed", Email: "m@gmail.com"},
+	}
+	for _, u := range users {
+		u.Password.Set("12345678", 12)
+		if err := userRepo.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+		u.AccountBalance = money.MustParse("100")
+		seedBalance(u)
+	}
+
+	send := func(v *validator.Validator) error {
+		_, _, err := transferSvc.TransferMoney(
+			v, users[0], "", "", users[1].Email, money.MustParse("10"), "", "",
+		)
+		return err
+	}
+
+	if err := send(validator.New()); err != nil {
+		t.Fatal(err)
+	}
+
+	v := validator.New()
+	err := send(v)
+	if !errors.Is(err, validator.ErrFailedValidation) {
+		t.Fatalf("expected error %v going over the limit, got %v", validator.ErrFailedValidation, err)
+	}
+	if want := "exceeded, 5.00 USD remaining"; v.Errors["daily limit"] != want {
+		t.Errorf("expected daily limit error %q, got %v", want, v.Errors)
+	}
+
+	raised := money.MustParse("50")
+	_, err = limitSvc.SetUserLimits(validator.New(), &limit.Override{
+		UserID: users[0].ID, Daily: &raised,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := send(validator.New()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 spent, transfers sent at the same time can't go over the 30 left together
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- send(validator.New())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	made := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			made++
+		case !errors.Is(err, validator.ErrFailedValidation):
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if made > 3 {
+		t.Errorf("expected at most 3 of the transfers made within the limit, got %d", made)
+	}
+}
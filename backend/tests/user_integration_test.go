@@ -3,7 +3,12 @@ package tests
 import (
 	"testing"
 
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/fx"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
 	"github.com/Yusufdot101/goBankBackend/internal/token"
+	"github.com/Yusufdot101/goBankBackend/internal/transfer"
 	"github.com/Yusufdot101/goBankBackend/internal/user"
 	"github.com/Yusufdot101/goBankBackend/internal/validator"
 
@@ -24,6 +29,16 @@ func TestUser(t *testing.T) {
 		TokenService: tokenSvc,
 	}
 
+	transferRepo = &transfer.Repository{DB: testDB}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transferSvc = &transfer.Service{
+		Repo:           transferRepo,
+		UserService:    userSvc,
+		AccountService: accountSvc,
+		FX:             &fx.Service{Repo: &fx.Repository{DB: testDB}},
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+
 	user1 = &user.User{
 		ID:    1,
 		Name:  "yusuf",
@@ -35,13 +50,16 @@ func TestUser(t *testing.T) {
 		ID:             2,
 		Name:           "mohamed",
 		Email:          "m@gmail.com",
-		AccountBalance: 100, // needed to make the tranfer money in the test
+		AccountBalance: money.MustParse("100"), // needed to make the tranfer money in the test
 	}
 	user2.Password.Set("12345678", 12)
 
 	setupUserSevice := func(us *user.Service, user *user.User) {
 		// seed the users table, this will be used in transferring of money
+		balance := user.AccountBalance
 		us.Repo.Insert(user)
+		user.AccountBalance = balance
+		seedBalance(user)
 	}
 
 	tests := []struct {
@@ -51,7 +69,7 @@ func TestUser(t *testing.T) {
 			v              *validator.Validator
 			user, fromUser *user.User
 			userPassword   string
-			amount         float64
+			amount         money.Amount
 		}
 		expectedErr error
 	}{
@@ -65,12 +83,12 @@ func TestUser(t *testing.T) {
 				user         *user.User
 				fromUser     *user.User
 				userPassword string
-				amount       float64
+				amount       money.Amount
 			}{
 				user:         user1,
 				userPassword: "12345678",
 				fromUser:     user2,
-				amount:       100,
+				amount:       money.MustParse("100"),
 			},
 		},
 		{
@@ -81,12 +99,12 @@ func TestUser(t *testing.T) {
 				user         *user.User
 				fromUser     *user.User
 				userPassword string
-				amount       float64
+				amount       money.Amount
 			}{
 				user:         user1,
 				fromUser:     user2,
 				userPassword: "12345678",
-				amount:       100,
+				amount:       money.MustParse("100"),
 			},
 			expectedErr: user.ErrDuplicateEmail,
 		},
@@ -140,7 +158,10 @@ func TestUser(t *testing.T) {
 			// step 3: transfer money into the user account
 			// add new account to transfer from
 			setupUserSevice(userSvc, user2)
-			gotUser, gotErr = userSvc.TransferMoney(tc.input.fromUser, tc.input.user, tc.input.amount)
+			_, gotUser, gotErr = transferSvc.TransferMoney(
+				validator.New(), tc.input.fromUser, "", "", tc.input.user.Email, tc.input.amount,
+				"", "",
+			)
 			if !checkErr(t, gotErr, tc.expectedErr, "TransferMoney") {
 				return
 			}
@@ -200,7 +221,7 @@ func checkFromUserAfterTransfer(
 	passed := checkUser(t, got, expected, msg)
 	if got.AccountBalance != 0 {
 		t.Errorf(
-			"%s: expected user account balance=0, got account balance=%f", msg, got.AccountBalance,
+			"%s: expected user account balance=0, got account balance=%v", msg, got.AccountBalance,
 		)
 		passed = false
 	}
@@ -208,12 +229,12 @@ func checkFromUserAfterTransfer(
 }
 
 func checkToUserAfterTransfer(
-	t *testing.T, got, expected *user.User, amount float64, msg string,
+	t *testing.T, got, expected *user.User, amount money.Amount, msg string,
 ) bool {
 	passed := checkUser(t, got, expected, msg)
 	if got.AccountBalance != amount {
 		t.Errorf(
-			"%s: expected user account balance=%f, got account balance=%f", msg,
+			"%s: expected user account balance=%v, got account balance=%v", msg,
 			amount, got.AccountBalance,
 		)
 		passed = false
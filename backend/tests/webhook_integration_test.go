@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Yusufdot101/goBankBackend/internal/account"
+	"github.com/Yusufdot101/goBankBackend/internal/event"
+	"github.com/Yusufdot101/goBankBackend/internal/limit"
+	"github.com/Yusufdot101/goBankBackend/internal/money"
+	"github.com/Yusufdot101/goBankBackend/internal/transaction"
+	"github.com/Yusufdot101/goBankBackend/internal/user"
+	"github.com/Yusufdot101/goBankBackend/internal/validator"
+	"github.com/Yusufdot101/goBankBackend/internal/webhook"
+)
+
+// TestWebhooks registers a webhook for deposits on an endpoint that refuses the first delivery,
+// then checks it is retried after the backoff, signed, and can be replayed
+func TestWebhooks(t *testing.T) {
+	resetDB()
+
+	userRepo = &user.Repository{DB: testDB}
+	accountSvc = &account.Service{Repo: &account.Repository{DB: testDB}}
+	transactionSvc := &transaction.Service{
+		Repo:           &transaction.Repository{DB: testDB},
+		AccountService: accountSvc,
+		Limits:         &limit.Service{Repo: &limit.Repository{DB: testDB}},
+	}
+
+	u := &user.User{Name: "yusuf", Email: "y@gmail.com"}
+	u.Password.Set("12345678", 12)
+	if err := userRepo.Insert(u); err != nil {
+		t.Fatal(err)
+	}
+
+	received := 0
+	var secret string
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		payload, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		if !webhook.Verify(secret, timestamp, payload, r.Header.Get(webhook.HeaderSignature)) {
+			t.Errorf("the signature didn't verify")
+		}
+		json.Unmarshal(payload, &got)
+		if received == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	webhookRepo := &webhook.Repository{DB: testDB}
+	webhookSvc := &webhook.Service{Repo: webhookRepo, Client: server.Client()}
+	// the test server is on a loopback address, which Register refuses, so the webhooks are saved
+	// as they are
+	secret = "shh"
+	hook := &webhook.Webhook{
+		UserID: u.ID, URL: server.URL, Secret: secret, EventTypes: []string{event.TypeDeposit},
+	}
+	if err := webhookRepo.Insert(hook); err != nil {
+		t.Fatal(err)
+	}
+
+	// another user's webhook is not sent the deposit
+	other := &user.User{Name: "other", Email: "other@gmail.com"}
+	other.Password.Set("12345678", 12)
+	if err := userRepo.Insert(other); err != nil {
+		t.Fatal(err)
+	}
+	otherHook := &webhook.Webhook{
+		UserID: other.ID, URL: server.URL, Secret: secret, EventTypes: []string{event.TypeDeposit},
+	}
+	if err := webhookRepo.Insert(otherHook); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := transactionSvc.Deposit(
+		validator.New(), u.ID, "", money.MustParse("50"), "teller", "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := &event.Dispatcher{Repo: &event.Repository{DB: testDB}}
+	dispatcher.Subscribe(event.All, webhookSvc.Enqueue)
+	if _, err := dispatcher.Dispatch(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the endpoint refuses the first one, it is only due again after the backoff
+	now := time.Now().UTC()
+	delivered, err := webhookSvc.DeliverDue(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 0 || received != 1 {
+		t.Fatalf("expected 1 refused delivery, got %d delivered of %d", delivered, received)
+	}
+	delivered, _ = webhookSvc.DeliverDue(now.Add(time.Second))
+	if delivered != 0 || received != 1 {
+		t.Fatalf("expected nothing due before the backoff, got %d sent", received-1)
+	}
+
+	delivered, err = webhookSvc.DeliverDue(now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 1 || received != 2 {
+		t.Fatalf("expected the retry to be delivered, got %d delivered of %d", delivered, received)
+	}
+	if got["type"] != event.TypeDeposit || got["user_id"] != float64(u.ID) {
+		t.Errorf("expected the deposit in the body, got %v", got)
+	}
+
+	deliveries, err := webhookSvc.GetDeliveries(validator.New(), hook.ID, u.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(deliveries))
+	}
+	d := deliveries[0]
+	if d.Status != webhook.StatusDelivered || d.Attempts != 2 || len(d.History) != 2 {
+		t.Errorf("expected delivered after 2 attempts, got %+v", d)
+	}
+
+	deliveries, _ = webhookSvc.GetDeliveries(validator.New(), otherHook.ID, other.ID, "")
+	if len(deliveries) != 0 {
+		t.Errorf("expected no deliveries of another user's events, got %d", len(deliveries))
+	}
+
+	// someone else can't see or replay the deliveries
+	deliveries, _ = webhookSvc.GetDeliveries(validator.New(), hook.ID, u.ID+1, "")
+	if len(deliveries) != 0 {
+		t.Errorf("expected no deliveries for another user, got %d", len(deliveries))
+	}
+	_, err = webhookSvc.Replay(validator.New(), u.ID+1, d.ID, 0, now)
+	checkErr(t, err, user.ErrNoRecord, "replay of another user's delivery")
+
+	replayed, err := webhookSvc.Replay(validator.New(), u.ID, d.ID, 0, now.Add(time.Minute))
+	if err != nil || replayed != 1 {
+		t.Fatalf("expected the delivery replayed, got %d %v", replayed, err)
+	}
+	delivered, _ = webhookSvc.DeliverDue(now.Add(time.Minute))
+	if delivered != 1 || received != 3 {
+		t.Errorf("expected the replay to be delivered, got %d delivered of %d", delivered, received)
+	}
+}